@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -23,8 +26,11 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
 	_ "net/http/pprof"
@@ -34,9 +40,17 @@ func main() {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal("Failed to load config", "error", err)
 	}
 
+	// Set up structured logging; anything still written through the standard log
+	// package goes to the same handler at info level
+	logger, err := observability.NewLogger(os.Stderr, cfg.Server.LogLevel)
+	if err != nil {
+		fatal("Failed to create logger", "error", err)
+	}
+	slog.SetDefault(logger)
+
 	// Set up observability
 	metrics := observability.NewMetrics()
 
@@ -51,7 +65,7 @@ func main() {
 			cfg.Observability.TracingEndpoint,
 		)
 		if err != nil {
-			log.Fatalf("Failed to create tracer: %v", err)
+			fatal("Failed to create tracer", "error", err)
 		}
 		defer tracerCleanup()
 	} else {
@@ -62,121 +76,216 @@ func main() {
 
 	// Set up Redis cache
 	redisCache, err := cache.NewRedisCache(
-		cfg.Redis.URL,
-		cfg.Redis.Password,
-		cfg.Redis.DB,
+		cache.RedisOptions{
+			Mode:              cfg.Redis.Mode,
+			URL:               cfg.Redis.URL,
+			Password:          cfg.Redis.Password,
+			DB:                cfg.Redis.DB,
+			PoolSize:          cfg.Redis.PoolSize,
+			MasterName:        cfg.Redis.SentinelMasterName,
+			SentinelAddrs:     cfg.Redis.SentinelAddrs,
+			SentinelPassword:  cfg.Redis.SentinelPassword,
+			ClusterAddrs:      cfg.Redis.ClusterAddrs,
+			CompressThreshold: cfg.Redis.CompressThreshold,
+		},
 		cache.TTLConfig{
 			RepositoryRouting: cfg.Redis.TTL.RepositoryRouting,
 			QueryResults:      cfg.Redis.TTL.QueryResults,
 			UploadStatus:      cfg.Redis.TTL.UploadStatus,
+			Embeddings:        cfg.Redis.TTL.Embeddings,
+			MissingRepository: cfg.Redis.TTL.MissingRepository,
 		},
 	)
 	if err != nil {
-		log.Fatalf("Failed to create Redis cache: %v", err)
+		fatal("Failed to create Redis cache", "error", err)
 	}
 	defer redisCache.Close()
 
-	// Set up OpenAI embedding client
-	embeddingClient := composer.NewOpenAIEmbeddingClient(cfg.OpenAI, metrics, tracer)
+	// Set up embedding provider
+	embeddingClient, embeddingHealth := newEmbeddingClient(cfg, metrics, tracer)
+	slog.Info("Embedding provider", "provider", cfg.Embedding.Provider, "model", embeddingClient.GetDefaultModel(), "dimensions", embeddingClient.Dimensions())
 
-	// Set up Weaviate client
-	weaviateClient, err := query.NewWeaviateClient(cfg.Weaviate, metrics, tracer)
+	// Set up the vector store (Weaviate or Qdrant)
+	vectorStore, err := query.NewVectorStore(cfg, metrics, tracer)
 	if err != nil {
-		log.Fatalf("Failed to create Weaviate client: %v", err)
+		fatal("Failed to create vector store client", "backend", cfg.VectorStore.Backend, "error", err)
+	}
+	slog.Info("Vector backend", "backend", cfg.VectorStore.Backend)
+
+	// Older collections may lack properties searches now fetch; a failure here
+	// leaves them to be fixed up when they are next indexed
+	if migrator, ok := vectorStore.(query.SchemaMigrator); ok {
+		migrateCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := migrator.MigrateSchema(migrateCtx); err != nil {
+			slog.Warn("Failed to migrate vector store schema", "backend", cfg.VectorStore.Backend, "error", err)
+		}
+		cancel()
 	}
 
-	// Set up Ripgrep client
-	ripgrepClient := query.NewRipgrepClient(metrics, tracer, cfg.Upload.StorageDir)
+	// Set up lexical search (ripgrep, or the native searcher without rg)
+	lexicalClient, err := query.NewLexicalClient(cfg.Lexical, cfg.Defaults.SearchTimeout, metrics, tracer, cfg.Upload.StorageDir)
+	if err != nil {
+		fatal("Failed to create lexical search client", "error", err)
+	}
 
 	// Set up result merger
-	resultMerger := query.NewResultMerger(cfg.Defaults.MaxSearchResults)
+	resultMerger := query.NewResultMerger(cfg.Defaults.MaxSearchResults, cfg.Merge)
 
-	// Set up DeepSeek client
-	deepSeekClient := composer.NewDeepSeekClient(cfg.DeepSeek, metrics, tracer)
+	// Set up answer composer; a broken system prompt template fails startup
+	systemPrompt, err := composer.NewSystemPrompt(cfg.Composer)
+	if err != nil {
+		fatal("Failed to load system prompt", "error", err)
+	}
+	var chatComposer composer.Composer
+	// DeepSeek is only health checked when it composes answers
+	var deepSeekHealth api.ProviderHealthChecker
+	switch cfg.Composer.Provider {
+	case "openai":
+		chatComposer = composer.NewOpenAIChatClient(cfg.OpenAI, systemPrompt, metrics, tracer)
+	default:
+		deepSeekClient := composer.NewDeepSeekClient(cfg.DeepSeek, systemPrompt, metrics, tracer)
+		chatComposer = deepSeekClient
+		deepSeekHealth = deepSeekClient
+	}
 
 	// Set up ingestion provider
 	ingestProvider := ingest.NewInlineProcessor(
 		redisCache,
 		metrics,
 		tracer,
+		logger,
 		embeddingClient,
-		weaviateClient,
+		vectorStore,
+		cfg.Upload,
+		cfg.Defaults,
 		cfg.Upload.StorageDir,
 		cfg.Upload.TempDir,
 	)
 
+	// Clean up working trees of failed or deleted ingestions; the grace period keeps
+	// anything recent, so this can run alongside new uploads
+	if cfg.Upload.ReconcileOnStartup {
+		go func() {
+			removed, err := ingestProvider.ReconcileWorkDir(context.Background(), cfg.Upload.ReconcileGracePeriod)
+			if err != nil {
+				slog.Warn("Failed to reconcile work directory", "error", err)
+				return
+			}
+			slog.Info("Removed orphaned repository directories", "removed", removed)
+		}()
+	}
+
 	// Set up query service
 	queryService := api.NewQueryService(
-		ripgrepClient,
-		weaviateClient,
+		lexicalClient,
+		vectorStore,
 		resultMerger,
 		redisCache,
 		metrics,
 		tracer,
 	)
 
+	// Backend health checks, shared by the gRPC health services and the admin probes
+	healthServer := api.NewHealthServer(
+		cfg,
+		redisCache,
+		queryService.GetLexicalClient(),
+		queryService.GetSemanticClient(),
+		deepSeekHealth,
+		embeddingHealth,
+		metrics,
+		tracer,
+	)
+
+	// Shared by gRPC and the plain HTTP routes
+	authInterceptor, err := interceptors.NewAuthInterceptor(&cfg.Security, redisCache)
+	if err != nil {
+		fatal("Failed to create auth interceptor", "error", err)
+	}
+	requestIDInterceptor := interceptors.NewRequestIDInterceptor(logger)
+	rateLimitInterceptor := interceptors.NewRateLimitInterceptor(&cfg.Security.RateLimit, redisCache)
+
+	// Cancelled when shutdown starts, stopping the background sweepers
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go rateLimitInterceptor.SweepLimiters(ctx)
+
+	tlsConfig, dialCreds, err := loadTLS(cfg)
+	if err != nil {
+		fatal("Failed to set up TLS", "error", err)
+	}
+	if tlsConfig == nil && !cfg.IsDevelopment() {
+		slog.Warn("TLS_CERT_FILE is not set; the gRPC and HTTP servers are serving plaintext")
+	}
+
 	// Create gRPC server
-	grpcServer := createGRPCServer(cfg, redisCache, ingestProvider, queryService, deepSeekClient, embeddingClient, metrics, tracer)
+	grpcServer := createGRPCServer(ctx, cfg, tlsConfig, redisCache, authInterceptor, requestIDInterceptor, rateLimitInterceptor, ingestProvider, queryService, healthServer, chatComposer, embeddingClient, metrics, tracer)
 
 	// Create HTTP gateway server
-	httpServer := createHTTPServer(cfg, grpcServer, redisCache, queryService, deepSeekClient, embeddingClient, metrics, tracer)
+	httpServer, wsHandler, sseHandler := createHTTPServer(ctx, cfg, tlsConfig, dialCreds, grpcServer, redisCache, authInterceptor, requestIDInterceptor, rateLimitInterceptor, ingestProvider, queryService, chatComposer, embeddingClient, metrics, tracer)
 
 	// Start admin server (metrics, pprof)
-	adminServer := createAdminServer(cfg, metrics)
-
-	// Start servers
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	adminServer := createAdminServer(cfg, healthServer, metrics)
 
 	// Start gRPC server
 	go func() {
-		log.Printf("Starting gRPC server on port %d", cfg.Server.GRPCPort)
+		slog.Info("Starting gRPC server", "port", cfg.Server.GRPCPort, "tls", tlsConfig != nil)
 		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Server.GRPCPort))
 		if err != nil {
-			log.Fatalf("Failed to listen on gRPC port: %v", err)
+			fatal("Failed to listen on gRPC port", "error", err)
 		}
 
 		if err := grpcServer.Serve(lis); err != nil {
-			log.Fatalf("gRPC server failed: %v", err)
+			fatal("gRPC server failed", "error", err)
 		}
 	}()
 
 	// Start HTTP server
 	go func() {
-		log.Printf("Starting HTTP server on port %d", cfg.Server.HTTPPort)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("HTTP server failed: %v", err)
+		slog.Info("Starting HTTP server", "port", cfg.Server.HTTPPort, "tls", tlsConfig != nil)
+		var err error
+		if tlsConfig != nil {
+			// The certificate is already in httpServer.TLSConfig
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("HTTP server failed", "error", err)
 		}
 	}()
 
 	// Start admin server
 	go func() {
-		log.Printf("Starting admin server on port %d", cfg.Server.AdminPort)
+		slog.Info("Starting admin server", "port", cfg.Server.AdminPort)
 		if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Admin server failed: %v", err)
+			fatal("Admin server failed", "error", err)
 		}
 	}()
 
 	// Wait for shutdown signal
-	waitForShutdown(ctx, cancel, cfg.Server.GracefulShutdownTimeout, grpcServer, httpServer, adminServer)
+	waitForShutdown(ctx, cancel, cfg.Server.GracefulShutdownTimeout, grpcServer, httpServer, wsHandler, sseHandler, adminServer)
 }
 
 func createGRPCServer(
+	ctx context.Context,
 	cfg *config.Config,
+	tlsConfig *tls.Config,
 	cache *cache.RedisCache,
+	authInterceptor *interceptors.AuthInterceptor,
+	requestIDInterceptor *interceptors.RequestIDInterceptor,
+	rateLimitInterceptor *interceptors.RateLimitInterceptor,
 	ingestProvider ingest.Provider,
 	queryService *api.QueryService,
-	deepSeekClient *composer.DeepSeekClient,
-	embeddingClient *composer.OpenAIEmbeddingClient,
+	healthServer *api.HealthServer,
+	chatComposer composer.Composer,
+	embeddingClient ingest.EmbeddingClient,
 	metrics *observability.Metrics,
 	tracer *observability.Tracer,
 ) *grpc.Server {
-	// Create interceptors
-	authInterceptor := interceptors.NewAuthInterceptor(&cfg.Security)
-	rateLimitInterceptor := interceptors.NewRateLimitInterceptor(&cfg.Security.RateLimit)
-
-	// Set up interceptor chain
+	// Set up interceptor chain; the request ID comes first so everything after it can log it
 	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		requestIDInterceptor.UnaryServerInterceptor(),
 		authInterceptor.UnaryServerInterceptor(),
 		rateLimitInterceptor.UnaryServerInterceptor(),
 		tracer.UnaryServerInterceptor(),
@@ -184,6 +293,7 @@ func createGRPCServer(
 	}
 
 	streamInterceptors := []grpc.StreamServerInterceptor{
+		requestIDInterceptor.StreamServerInterceptor(),
 		authInterceptor.StreamServerInterceptor(),
 		rateLimitInterceptor.StreamServerInterceptor(),
 		tracer.StreamServerInterceptor(),
@@ -191,24 +301,33 @@ func createGRPCServer(
 	}
 
 	// Create gRPC server with interceptors
-	server := grpc.NewServer(
+	serverOpts := []grpc.ServerOption{
 		grpc.ChainUnaryInterceptor(unaryInterceptors...),
 		grpc.ChainStreamInterceptor(streamInterceptors...),
-	)
+	}
+	if tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	server := grpc.NewServer(serverOpts...)
 
 	// Register services
 	uploadServer := api.NewUploadServer(cfg, cache, ingestProvider, metrics, tracer)
 	repocontextv1.RegisterUploadServiceServer(server, uploadServer)
 
-	repositoryServer := api.NewRepositoryServer(cfg, cache, ingestProvider, metrics, tracer)
+	repositoryServer := api.NewRepositoryServer(cfg, cache, ingestProvider, queryService.GetLexicalClient(), metrics, tracer)
 	repocontextv1.RegisterRepositoryServiceServer(server, repositoryServer)
 
-	chatServer := api.NewChatServer(cfg, cache, queryService, deepSeekClient, embeddingClient, metrics, tracer)
+	chatServer := api.NewChatServer(cfg, cache, queryService, chatComposer, embeddingClient, metrics, tracer)
 	repocontextv1.RegisterChatServiceServer(server, chatServer)
+	go chatServer.SweepSessions(ctx)
+
+	adminServer := api.NewAdminServer(cfg, cache, metrics, tracer)
+	repocontextv1.RegisterAdminServiceServer(server, adminServer)
 
-	healthServer := api.NewHealthServer(cfg, cache, queryService.GetLexicalClient(), queryService.GetSemanticClient(), metrics, tracer)
 	repocontextv1.RegisterHealthServiceServer(server, healthServer)
 
+	registerGRPCHealth(ctx, server, healthServer, cfg.Server.HealthCheckInterval)
+
 	// Enable reflection for grpcurl
 	reflection.Register(server)
 
@@ -216,71 +335,185 @@ func createGRPCServer(
 }
 
 func createHTTPServer(
+	ctx context.Context,
 	cfg *config.Config,
+	tlsConfig *tls.Config,
+	dialCreds credentials.TransportCredentials,
 	grpcServer *grpc.Server,
 	cache *cache.RedisCache,
+	authInterceptor *interceptors.AuthInterceptor,
+	requestIDInterceptor *interceptors.RequestIDInterceptor,
+	rateLimitInterceptor *interceptors.RateLimitInterceptor,
+	ingestProvider ingest.Provider,
 	queryService *api.QueryService,
-	deepSeekClient *composer.DeepSeekClient,
-	embeddingClient *composer.OpenAIEmbeddingClient,
+	chatComposer composer.Composer,
+	embeddingClient ingest.EmbeddingClient,
 	metrics *observability.Metrics,
 	tracer *observability.Tracer,
-) *http.Server {
-	// Create Gorilla Mux router for WebSocket and other routes
+) (*http.Server, *api.ChatWebSocketHandler, *api.ChatSSEHandler) {
+	// Create Gorilla Mux router for WebSocket and other routes. Every request gets
+	// an ID, which the routes below pass on to the gRPC server
 	router := mux.NewRouter()
+	router.Use(requestIDInterceptor.HTTPMiddleware)
+
+	// Create gRPC-Gateway mux. X-Request-Id is forwarded as gRPC metadata; the
+	// middleware already returns it, so the server's copy isn't mapped back
+	gwMux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(func(key string) (string, bool) {
+			if strings.EqualFold(key, interceptors.RequestIDHeader) {
+				return interceptors.RequestIDHeader, true
+			}
+			return runtime.DefaultHeaderMatcher(key)
+		}),
+		runtime.WithOutgoingHeaderMatcher(func(key string) (string, bool) {
+			if strings.EqualFold(key, interceptors.RequestIDHeader) {
+				return "", false
+			}
+			return fmt.Sprintf("%s%s", runtime.MetadataHeaderPrefix, key), true
+		}),
+	)
 
-	// Create gRPC-Gateway mux
-	gwMux := runtime.NewServeMux()
-
-	// Register gRPC-Gateway
-	ctx := context.Background()
-	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	// Register gRPC-Gateway. Its connections to the gRPC server stay open through
+	// shutdown, until the HTTP server has drained
+	gatewayCtx := context.Background()
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(dialCreds)}
 	grpcEndpoint := fmt.Sprintf("localhost:%d", cfg.Server.GRPCPort)
 
-	if err := repocontextv1.RegisterUploadServiceHandlerFromEndpoint(ctx, gwMux, grpcEndpoint, opts); err != nil {
-		log.Fatalf("Failed to register upload service handler: %v", err)
+	if err := repocontextv1.RegisterUploadServiceHandlerFromEndpoint(gatewayCtx, gwMux, grpcEndpoint, opts); err != nil {
+		fatal("Failed to register upload service handler", "error", err)
 	}
 
-	if err := repocontextv1.RegisterRepositoryServiceHandlerFromEndpoint(ctx, gwMux, grpcEndpoint, opts); err != nil {
-		log.Fatalf("Failed to register repository service handler: %v", err)
+	if err := repocontextv1.RegisterRepositoryServiceHandlerFromEndpoint(gatewayCtx, gwMux, grpcEndpoint, opts); err != nil {
+		fatal("Failed to register repository service handler", "error", err)
 	}
 
-	// Skip ChatService registration - it's gRPC-only (bidirectional streaming)
-	// WebSocket chat is handled separately via our custom WebSocket bridge
-	// if err := repocontextv1.RegisterChatServiceHandlerFromEndpoint(ctx, gwMux, grpcEndpoint, opts); err != nil {
-	// 	log.Fatalf("Failed to register chat service handler: %v", err)
-	// }
+	// Only ChatService's unary Search is mapped to HTTP. ChatWithRepository stays
+	// gRPC-only; WebSocket chat is handled separately via our custom WebSocket bridge
+	if err := repocontextv1.RegisterChatServiceHandlerFromEndpoint(gatewayCtx, gwMux, grpcEndpoint, opts); err != nil {
+		fatal("Failed to register chat service handler", "error", err)
+	}
 	/*
-		Why ChatService is excluded from gRPC-Gateway:
+		Why ChatWithRepository isn't exposed through gRPC-Gateway:
 		- gRPC-Gateway can't handle bidirectional streaming
 		- Chat requires real-time token-by-token streaming
 		- WebSocket provides better user experience for chat
 	*/
 
-	if err := repocontextv1.RegisterHealthServiceHandlerFromEndpoint(ctx, gwMux, grpcEndpoint, opts); err != nil {
-		log.Fatalf("Failed to register health service handler: %v", err)
+	if err := repocontextv1.RegisterAdminServiceHandlerFromEndpoint(gatewayCtx, gwMux, grpcEndpoint, opts); err != nil {
+		fatal("Failed to register admin service handler", "error", err)
 	}
 
-	// Create ChatServer for WebSocket handler
-	chatServer := api.NewChatServer(cfg, cache, queryService, deepSeekClient, embeddingClient, metrics, tracer)
+	if err := repocontextv1.RegisterHealthServiceHandlerFromEndpoint(gatewayCtx, gwMux, grpcEndpoint, opts); err != nil {
+		fatal("Failed to register health service handler", "error", err)
+	}
 
-	// Create WebSocket handler and register BEFORE gRPC-Gateway
-	wsHandler := api.NewChatWebSocketHandler(chatServer, cfg, metrics, tracer)
-	wsHandler.RegisterRoutes(router)
+	// Create ChatServer for WebSocket handler
+	chatServer := api.NewChatServer(cfg, cache, queryService, chatComposer, embeddingClient, metrics, tracer)
+	go chatServer.SweepSessions(ctx)
+
+	// Create WebSocket handler and register BEFORE gRPC-Gateway. Chat connections
+	// outlive the server's write timeout, so it is lifted for them
+	wsHandler := api.NewChatWebSocketHandler(chatServer, cfg, opts, metrics, tracer)
+	wsRouter := router.NewRoute().Subrouter()
+	wsRouter.Use(withoutWriteTimeout)
+	wsHandler.RegisterRoutes(wsRouter)
+
+	// Archive uploads over multipart/form-data, which the gateway can't map to the
+	// streaming UploadRepository RPC; they need the same scope and rate limit as the RPC
+	uploadServer := api.NewUploadServer(cfg, cache, ingestProvider, metrics, tracer)
+	uploadHandler := api.NewUploadHTTPHandler(uploadServer, metrics, tracer)
+	uploadRouter := router.NewRoute().Subrouter()
+	// The response is only written once the archive has been read, which for large
+	// archives is well past the write timeout
+	uploadRouter.Use(withoutWriteTimeout)
+	uploadRouter.Use(func(next http.Handler) http.Handler {
+		return corsMiddleware(next, &cfg.Security.CORS)
+	})
+	uploadRouter.Use(authInterceptor.HTTPMiddleware("/repocontext.v1.UploadService/UploadRepository"))
+	uploadRouter.Use(rateLimitInterceptor.HTTPMiddleware)
+	uploadHandler.RegisterRoutes(uploadRouter)
+
+	// Chat as Server-Sent Events, for clients that can't use WebSockets; it needs
+	// the same scope and rate limit as the chat RPC and streams past the write timeout
+	sseHandler := api.NewChatSSEHandler(chatServer, metrics, tracer)
+	sseRouter := router.NewRoute().Subrouter()
+	sseRouter.Use(withoutWriteTimeout)
+	sseRouter.Use(func(next http.Handler) http.Handler {
+		return corsMiddleware(next, &cfg.Security.CORS)
+	})
+	sseRouter.Use(metrics.HTTPMiddleware("/repocontext.v1.ChatService/ChatEvents"))
+	sseRouter.Use(authInterceptor.HTTPMiddleware("/repocontext.v1.ChatService/ChatWithRepository"))
+	sseRouter.Use(rateLimitInterceptor.HTTPMiddleware)
+	sseHandler.RegisterRoutes(sseRouter)
+
+	// Index exports stream through the gateway for as long as the index takes to read
+	router.Path("/v1/repositories/{repository_id}/index:export").Methods(http.MethodGet).
+		Handler(withoutWriteTimeout(corsMiddleware(gwMux, &cfg.Security.CORS)))
+
+	// gRPC-Web for browser clients, served by the gRPC server itself so its
+	// interceptors apply. Requests are told apart by content type; CORS uses the
+	// same allowed headers as corsMiddleware. grpcweb echoes the origin and allows
+	// credentials, so a "*" origin doesn't apply: only listed origins are allowed
+	if grpcWebOrigins := explicitOrigins(&cfg.Security.CORS); len(grpcWebOrigins) < len(cfg.Security.CORS.AllowedOrigins) {
+		slog.Warn("gRPC-Web ignores the \"*\" in CORS_ALLOWED_ORIGINS; cross-origin gRPC-Web calls are only allowed from the origins listed by name", "origins", len(grpcWebOrigins))
+	}
+	grpcWebServer := grpcweb.WrapServer(grpcServer,
+		grpcweb.WithOriginFunc(func(origin string) bool {
+			return originListed(origin, &cfg.Security.CORS)
+		}),
+		grpcweb.WithAllowedRequestHeaders(append([]string(nil), cfg.Security.CORS.AllowedHeaders...)),
+	)
+	router.MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+		return grpcWebServer.IsGrpcWebRequest(r) || grpcWebServer.IsAcceptableGrpcCorsRequest(r)
+	}).Handler(grpcWebServer)
 
 	// Mount gRPC-Gateway AFTER WebSocket routes to avoid conflicts
 	router.PathPrefix("/").Handler(corsMiddleware(gwMux, &cfg.Security.CORS))
 
-	// Create HTTP server
-	return &http.Server{
+	// Create HTTP server. The timeouts suit the gRPC-Gateway REST endpoints; streaming
+	// routes lift them per request (see withoutWriteTimeout)
+	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.HTTPPort),
 		Handler:      router,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  60 * time.Second,
+		TLSConfig:    tlsConfig,
+	}
+	return server, wsHandler, sseHandler
+}
+
+// newEmbeddingClient returns the embedding provider selected by cfg, and its health
+// checker. Only the providers behind an API are health checked, so the mock
+// provider's is nil.
+func newEmbeddingClient(cfg *config.Config, metrics *observability.Metrics, tracer *observability.Tracer) (ingest.EmbeddingClient, api.ProviderHealthChecker) {
+	switch cfg.Embedding.Provider {
+	case "http":
+		httpEmbeddingClient := composer.NewHTTPEmbeddingClient(cfg.Embedding, metrics, tracer)
+		return httpEmbeddingClient, httpEmbeddingClient
+	case "mock":
+		return composer.NewMockEmbeddingClient(cfg.Embedding.Dimensions), nil
+	default:
+		openAIEmbeddingClient := composer.NewOpenAIEmbeddingClient(cfg.OpenAI, metrics, tracer)
+		return openAIEmbeddingClient, openAIEmbeddingClient
 	}
 }
 
-func createAdminServer(cfg *config.Config, metrics *observability.Metrics) *http.Server {
+// registerGRPCHealth registers the standard grpc.health.v1 service for load balancers
+// and probes, kept in sync with backend health every interval until ctx is cancelled
+func registerGRPCHealth(ctx context.Context, server *grpc.Server, healthServer *api.HealthServer, interval time.Duration) {
+	grpcHealth := health.NewServer()
+	healthpb.RegisterHealthServer(server, grpcHealth)
+	go healthServer.WatchServingStatus(ctx, grpcHealth, interval, []string{
+		repocontextv1.UploadService_ServiceDesc.ServiceName,
+		repocontextv1.RepositoryService_ServiceDesc.ServiceName,
+		repocontextv1.ChatService_ServiceDesc.ServiceName,
+		repocontextv1.AdminService_ServiceDesc.ServiceName,
+		repocontextv1.HealthService_ServiceDesc.ServiceName,
+	})
+}
+
+func createAdminServer(cfg *config.Config, healthServer *api.HealthServer, metrics *observability.Metrics) *http.Server {
 	mux := http.NewServeMux()
 
 	// Metrics endpoint
@@ -288,11 +521,16 @@ func createAdminServer(cfg *config.Config, metrics *observability.Metrics) *http
 		mux.Handle("/metrics", metrics.Handler())
 	}
 
-	// Health endpoint
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	// Liveness: the process is up and serving HTTP
+	livez := func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
-	})
+	}
+	mux.HandleFunc("/livez", livez)
+	mux.HandleFunc("/health", livez)
+
+	// Readiness: Redis and the vector store are reachable
+	mux.HandleFunc("/readyz", healthServer.ReadinessHandler(cfg.Server.ReadinessTimeout))
 
 	// pprof endpoints (only accessible from localhost)
 	if cfg.Observability.PProfEnabled && cfg.IsDevelopment() {
@@ -305,6 +543,17 @@ func createAdminServer(cfg *config.Config, metrics *observability.Metrics) *http
 	}
 }
 
+// withoutWriteTimeout clears the write deadline the server sets on each request, for
+// routes whose responses stream or are written long after the request arrived
+func withoutWriteTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			observability.Logger(r.Context()).Warn("Failed to clear write deadline", "path", r.URL.Path, "error", err)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func corsMiddleware(handler http.Handler, corsConfig *config.CORSConfig) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Set CORS headers
@@ -336,13 +585,35 @@ func corsMiddleware(handler http.Handler, corsConfig *config.CORSConfig) http.Ha
 	})
 }
 
-func waitForShutdown(ctx context.Context, cancel context.CancelFunc, timeout time.Duration, grpcServer *grpc.Server, httpServer *http.Server, adminServer *http.Server) {
+// originListed reports whether origin is one of the allowed origins listed by name;
+// a "*" entry doesn't count
+func originListed(origin string, corsConfig *config.CORSConfig) bool {
+	for _, allowedOrigin := range explicitOrigins(corsConfig) {
+		if allowedOrigin == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// explicitOrigins returns the allowed origins other than "*"
+func explicitOrigins(corsConfig *config.CORSConfig) []string {
+	var origins []string
+	for _, allowedOrigin := range corsConfig.AllowedOrigins {
+		if allowedOrigin != "*" {
+			origins = append(origins, allowedOrigin)
+		}
+	}
+	return origins
+}
+
+func waitForShutdown(ctx context.Context, cancel context.CancelFunc, timeout time.Duration, grpcServer *grpc.Server, httpServer *http.Server, wsHandler *api.ChatWebSocketHandler, sseHandler *api.ChatSSEHandler, adminServer *http.Server) {
 	// Wait for interrupt signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	sig := <-sigChan
-	log.Printf("Received signal %v, starting graceful shutdown...", sig)
+	slog.Info("Received signal, starting graceful shutdown", "signal", sig.String())
 
 	// Cancel context to signal all goroutines to stop
 	cancel()
@@ -357,34 +628,52 @@ func waitForShutdown(ctx context.Context, cancel context.CancelFunc, timeout tim
 	go func() {
 		defer close(shutdownComplete)
 
+		// Close WebSocket chats first: each holds a gRPC stream open, which the gRPC
+		// server's graceful stop would otherwise wait on, and the HTTP server's
+		// shutdown does not track upgraded connections
+		slog.Info("Closing WebSocket connections")
+		if err := wsHandler.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("WebSocket shutdown failed", "error", err)
+		}
+		slog.Info("Cancelling chat event streams")
+		if err := sseHandler.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("Chat event stream shutdown failed", "error", err)
+		}
+
 		// Stop gRPC server
-		log.Println("Stopping gRPC server...")
+		slog.Info("Stopping gRPC server")
 		grpcServer.GracefulStop()
 
 		// Stop HTTP server
-		log.Println("Stopping HTTP server...")
+		slog.Info("Stopping HTTP server")
 		if err := httpServer.Shutdown(shutdownCtx); err != nil {
-			log.Printf("HTTP server shutdown error: %v", err)
+			slog.Warn("HTTP server shutdown failed", "error", err)
 		}
 
 		// Stop admin server
-		log.Println("Stopping admin server...")
+		slog.Info("Stopping admin server")
 		if err := adminServer.Shutdown(shutdownCtx); err != nil {
-			log.Printf("Admin server shutdown error: %v", err)
+			slog.Warn("Admin server shutdown failed", "error", err)
 		}
 
-		log.Println("All servers stopped")
+		slog.Info("All servers stopped")
 	}()
 
 	// Wait for shutdown to complete or timeout
 	select {
 	case <-shutdownComplete:
-		log.Println("Graceful shutdown completed")
+		slog.Info("Graceful shutdown completed")
 	case <-shutdownCtx.Done():
-		log.Println("Shutdown timeout exceeded, forcing exit")
+		slog.Info("Shutdown timeout exceeded, forcing exit")
 	}
 }
 
+// fatal logs msg at error level and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
 // Helper function to join strings
 func joinStrings(slice []string, separator string) string {
 	if len(slice) == 0 {
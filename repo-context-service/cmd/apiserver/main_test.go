@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/api"
+	"repo-context-service/internal/cache"
+	"repo-context-service/internal/composer"
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/interceptors"
+	"repo-context-service/internal/observability"
+	"repo-context-service/internal/query"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"github.com/alicebob/miniredis/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// switchableBackend is a lexical and semantic client whose health check fails while down is set
+type switchableBackend struct {
+	down atomic.Bool
+}
+
+func (b *switchableBackend) SearchLexical(ctx context.Context, repoID, query string, limit int, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	return nil, nil
+}
+
+func (b *switchableBackend) ReadFile(ctx context.Context, repoID, path string, startLine, endLine int) (*repocontextv1.CodeChunk, error) {
+	return nil, nil
+}
+
+func (b *switchableBackend) SearchSemantic(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	return nil, nil
+}
+
+func (b *switchableBackend) HealthCheck(ctx context.Context) error {
+	if b.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestGRPCHealthFollowsBackends(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	redisCache, err := cache.NewRedisCache(cache.RedisOptions{URL: "redis://" + miniredis.RunT(t).Addr()}, cache.TTLConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer redisCache.Close()
+	backend := &switchableBackend{}
+	cfg := &config.Config{VectorStore: config.VectorStoreConfig{Backend: "weaviate"}}
+	healthServer := api.NewHealthServer(cfg, redisCache, backend, backend, nil, nil, observability.NewMetrics(), observability.NewNoOpTracer())
+
+	server := grpc.NewServer()
+	registerGRPCHealth(ctx, server, healthServer, 10*time.Millisecond)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(lis)
+	defer server.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	// waitForStatus polls the standard health check of service until it reports want
+	waitForStatus := func(service string, want healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+			if err == nil && resp.Status == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("health of %q = %v, %v; want %v", service, resp.GetStatus(), err, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	services := []string{"", repocontextv1.RepositoryService_ServiceDesc.ServiceName, repocontextv1.ChatService_ServiceDesc.ServiceName}
+	for _, service := range services {
+		waitForStatus(service, healthpb.HealthCheckResponse_SERVING)
+	}
+	backend.down.Store(true)
+	for _, service := range services {
+		waitForStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	backend.down.Store(false)
+	waitForStatus("", healthpb.HealthCheckResponse_SERVING)
+}
+
+func TestAdminProbes(t *testing.T) {
+	tests := []struct {
+		name         string
+		redisDown    bool
+		vectorDown   bool
+		lexicalDown  bool
+		wantReadyz   int
+		wantDownName string
+	}{
+		{"all up", false, false, false, http.StatusOK, ""},
+		{"vector store down", false, true, false, http.StatusServiceUnavailable, "weaviate"},
+		{"redis down", true, false, false, http.StatusServiceUnavailable, "redis"},
+		// Lexical search degrades, it doesn't stop the service
+		{"lexical search down", false, false, true, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redis := miniredis.RunT(t)
+			redisCache, err := cache.NewRedisCache(cache.RedisOptions{URL: "redis://" + redis.Addr()}, cache.TTLConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer redisCache.Close()
+			lexical, semantic := &switchableBackend{}, &switchableBackend{}
+			lexical.down.Store(tt.lexicalDown)
+			semantic.down.Store(tt.vectorDown)
+			if tt.redisDown {
+				redis.Close()
+			}
+			cfg := &config.Config{
+				Server:      config.ServerConfig{ReadinessTimeout: time.Second},
+				VectorStore: config.VectorStoreConfig{Backend: "weaviate"},
+			}
+			healthServer := api.NewHealthServer(cfg, redisCache, lexical, semantic, nil, nil, observability.NewMetrics(), observability.NewNoOpTracer())
+			handler := createAdminServer(cfg, healthServer, observability.NewMetrics()).Handler
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("/livez = %d, want 200 whatever the backends", rec.Code)
+			}
+
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.wantReadyz {
+				t.Errorf("/readyz = %d, want %d", rec.Code, tt.wantReadyz)
+			}
+			var body struct {
+				Ready      bool `json:"ready"`
+				Components []struct {
+					Name   string `json:"name"`
+					Status string `json:"status"`
+				} `json:"components"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("/readyz body %q: %v", rec.Body.String(), err)
+			}
+			if body.Ready != (tt.wantReadyz == http.StatusOK) {
+				t.Errorf("/readyz ready = %v", body.Ready)
+			}
+			for _, component := range body.Components {
+				serving := component.Status == repocontextv1.HealthCheckResponse_SERVING_STATUS_SERVING.String()
+				if serving == (component.Name == tt.wantDownName) {
+					t.Errorf("/readyz reports %s as %s", component.Name, component.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestNewEmbeddingClientSelectsProvider(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DEEPSEEK_API_KEY", "test-key")
+	t.Setenv("EMBEDDING_PROVIDER", "mock")
+	t.Setenv("EMBEDDING_DIMENSIONS", "8")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load error = %v", err)
+	}
+
+	client, health := newEmbeddingClient(cfg, observability.NewMetrics(), observability.NewNoOpTracer())
+
+	if _, ok := client.(*composer.MockEmbeddingClient); !ok {
+		t.Fatalf("embedding client = %T, want the mock provider", client)
+	}
+	if health != nil {
+		t.Errorf("mock provider health checker = %T, want none", health)
+	}
+	embeddings, err := client.GenerateEmbeddings(context.Background(), []string{"func main() {}"}, client.GetDefaultModel())
+	if err != nil || len(embeddings) != 1 || len(embeddings[0]) != 8 || client.Dimensions() != 8 {
+		t.Errorf("mock embeddings = %v, %v with %d dimensions; want one 8-dimensional vector", embeddings, err, client.Dimensions())
+	}
+
+	// Other providers are health checked
+	for provider, want := range map[string]string{"openai": "*composer.OpenAIEmbeddingClient", "http": "*composer.HTTPEmbeddingClient"} {
+		cfg.Embedding.Provider = provider
+		client, health := newEmbeddingClient(cfg, observability.NewMetrics(), observability.NewNoOpTracer())
+		if got := fmt.Sprintf("%T", client); got != want || health == nil {
+			t.Errorf("%s provider = %s with health checker %v, want %s with one", provider, got, health, want)
+		}
+	}
+}
+
+func TestWithoutWriteTimeoutOutlivesTheDeadline(t *testing.T) {
+	// Writes a line every 50ms, for longer than the server's write timeout
+	stream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 6; i++ {
+			if _, err := fmt.Fprintf(w, "event %d\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	})
+
+	tests := []struct {
+		name         string
+		handler      http.Handler
+		wantComplete bool
+	}{
+		{"write timeout applies", stream, false},
+		{"write timeout lifted", withoutWriteTimeout(stream), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewUnstartedServer(tt.handler)
+			// Stands in for the 10s WriteTimeout of the HTTP server
+			server.Config.WriteTimeout = 100 * time.Millisecond
+			server.Start()
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			if err != nil {
+				t.Fatalf("GET error = %v", err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			complete := err == nil && strings.Count(string(body), "event") == 6
+			if complete != tt.wantComplete {
+				t.Errorf("read %q, %v; want the whole stream = %v", body, err, tt.wantComplete)
+			}
+		})
+	}
+}
+
+func TestGRPCWebListRepositories(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DEEPSEEK_API_KEY", "test-key")
+	t.Setenv("EMBEDDING_PROVIDER", "mock")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load error = %v", err)
+	}
+	cfg.Security.CORS.AllowedOrigins = []string{"https://app.example.com"}
+	redisCache, err := cache.NewRedisCache(cache.RedisOptions{URL: "redis://" + miniredis.RunT(t).Addr()}, cache.TTLConfig{RepositoryRouting: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer redisCache.Close()
+	for _, id := range []string{"repo-2", "repo-1"} {
+		if err := redisCache.SetRepositoryMetadata(ctx, cfg.Security.DefaultTenant, &repocontextv1.Repository{RepositoryId: id, Name: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	metrics, tracer := observability.NewMetrics(), observability.NewNoOpTracer()
+	backend := &switchableBackend{}
+	queryService := api.NewQueryService(backend, backend, query.NewResultMerger(10, cfg.Merge), redisCache, metrics, tracer)
+	grpcServer := grpc.NewServer()
+	repocontextv1.RegisterRepositoryServiceServer(grpcServer, api.NewRepositoryServer(cfg, redisCache, nil, backend, metrics, tracer))
+	authInterceptor, err := interceptors.NewAuthInterceptor(&cfg.Security, redisCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer, _, _ := createHTTPServer(ctx, cfg, nil, insecure.NewCredentials(), grpcServer, redisCache, authInterceptor,
+		interceptors.NewRequestIDInterceptor(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		interceptors.NewRateLimitInterceptor(&cfg.Security.RateLimit, redisCache),
+		nil, queryService, nil, nil, metrics, tracer)
+	server := httptest.NewServer(httpServer.Handler)
+	defer server.Close()
+
+	// A gRPC-Web body is a length-prefixed message frame
+	message, err := proto.Marshal(&repocontextv1.ListRepositoriesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(message)))
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/repocontext.v1.RepositoryService/ListRepositories", bytes.NewReader(append(frame, message...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	req.Header.Set("X-Grpc-Web", "1")
+	req.Header.Set("Origin", "https://app.example.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/grpc-web") {
+		t.Fatalf("response %d with content type %q, want a gRPC-Web response", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the listed origin", got)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The response is a message frame followed by a trailer frame, flagged 0x80
+	var listResp repocontextv1.ListRepositoriesResponse
+	var trailers string
+	for len(body) >= 5 {
+		flag, size := body[0], binary.BigEndian.Uint32(body[1:5])
+		if uint32(len(body)-5) < size {
+			t.Fatalf("truncated frame of %d bytes", size)
+		}
+		payload := body[5 : 5+size]
+		body = body[5+size:]
+		if flag&0x80 != 0 {
+			trailers = string(payload)
+			continue
+		}
+		if err := proto.Unmarshal(payload, &listResp); err != nil {
+			t.Fatalf("unmarshal response: %v", err)
+		}
+	}
+	if !strings.Contains(trailers, "grpc-status: 0") {
+		t.Errorf("trailers %q, want grpc-status 0", trailers)
+	}
+	var ids []string
+	for _, repo := range listResp.Repositories {
+		ids = append(ids, repo.RepositoryId)
+	}
+	if fmt.Sprint(ids) != "[repo-1 repo-2]" {
+		t.Errorf("listed %v, want [repo-1 repo-2]", ids)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"repo-context-service/internal/config"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// loadTLS returns the TLS config the gRPC and HTTP servers serve with, and the
+// credentials the gateway and WebSocket bridge use to dial the gRPC server over
+// loopback. Without TLS_CERT_FILE both servers are plaintext and tlsConfig is nil.
+func loadTLS(cfg *config.Config) (*tls.Config, credentials.TransportCredentials, error) {
+	if !cfg.Server.TLSEnabled() {
+		return nil, insecure.NewCredentials(), nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse TLS certificate: %w", err)
+	}
+
+	serverTLS := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	// Loopback dials trust the system roots and the server's own certificate, so a
+	// self-signed one works too
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	roots.AddCert(leaf)
+
+	serverName, err := loopbackServerName(leaf, cfg.Server.TLSServerName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clientCreds := credentials.NewTLS(&tls.Config{
+		RootCAs:    roots,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	})
+	return serverTLS, clientCreds, nil
+}
+
+// loopbackServerName picks the name loopback dials verify the certificate against.
+// They connect to localhost, which the certificate may not name, so without a
+// configured name they fall back to an IP address and then a DNS name it covers. A
+// wildcard name is verified through a host under it.
+func loopbackServerName(leaf *x509.Certificate, configured string) (string, error) {
+	if configured != "" {
+		if err := leaf.VerifyHostname(configured); err != nil {
+			return "", fmt.Errorf("TLS_SERVER_NAME doesn't match the TLS certificate: %w", err)
+		}
+		return configured, nil
+	}
+
+	candidates := []string{"localhost"}
+	for _, ip := range leaf.IPAddresses {
+		candidates = append(candidates, ip.String())
+	}
+	for _, name := range leaf.DNSNames {
+		candidates = append(candidates, strings.Replace(name, "*", "loopback", 1))
+	}
+	for _, name := range candidates {
+		if leaf.VerifyHostname(name) == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("the TLS certificate names no host or IP address to verify loopback dials against; set TLS_SERVER_NAME")
+}
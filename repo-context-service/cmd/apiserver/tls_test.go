@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func testCertificate(t *testing.T, dnsNames []string, ips []net.IP) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf
+}
+
+func TestLoopbackServerName(t *testing.T) {
+	tests := []struct {
+		name       string
+		dnsNames   []string
+		ips        []net.IP
+		configured string
+		want       string
+		wantErr    bool
+	}{
+		{"localhost", []string{"api.example.com", "localhost"}, nil, "", "localhost", false},
+		{"loopback IP", []string{"api.example.com"}, []net.IP{net.ParseIP("127.0.0.1")}, "", "127.0.0.1", false},
+		{"IP only", nil, []net.IP{net.ParseIP("10.0.0.5")}, "", "10.0.0.5", false},
+		{"DNS name", []string{"api.example.com"}, nil, "", "api.example.com", false},
+		{"wildcard", []string{"*.example.com"}, nil, "", "loopback.example.com", false},
+		{"configured", []string{"*.example.com"}, nil, "grpc.example.com", "grpc.example.com", false},
+		{"configured mismatch", []string{"api.example.com"}, nil, "grpc.example.com", "", true},
+		{"no names", nil, nil, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loopbackServerName(testCertificate(t, tt.dnsNames, tt.ips), tt.configured)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loopbackServerName error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("loopbackServerName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeSelfSignedKeyPair writes a self-signed certificate for dnsNames and its key as
+// PEM files, returning their paths
+func writeSelfSignedKeyPair(t *testing.T, dnsNames []string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     dnsNames,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestLoadTLSServesSelfSignedCertificate(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile = writeSelfSignedKeyPair(t, []string{"localhost"})
+	serverTLS, dialCreds, err := loadTLS(cfg)
+	if err != nil {
+		t.Fatalf("loadTLS error = %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(serverTLS)))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	// The loopback credentials the gateway dials with trust the self-signed certificate
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(dialCreds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("health check over TLS error = %v", err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health = %v, want SERVING", resp.Status)
+	}
+
+	// A client that doesn't trust the certificate is refused the handshake
+	_, err = tls.Dial("tcp", listener.Addr().String(), &tls.Config{ServerName: "localhost", NextProtos: []string{"h2"}})
+	if err == nil {
+		t.Error("handshake without trusting the self-signed certificate succeeded")
+	}
+}
+
+func TestLoadTLSServesHTTPS(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile = writeSelfSignedKeyPair(t, []string{"localhost"})
+	serverTLS, _, err := loadTLS(cfg)
+	if err != nil {
+		t.Fatalf("loadTLS error = %v", err)
+	}
+
+	// As in main, the certificate comes from TLSConfig rather than files
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }),
+		TLSConfig: serverTLS,
+	}
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverTLS.Certificates[0].Leaf)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost"}}}
+	resp, err := client.Get("https://" + listener.Addr().String() + "/livez")
+	if err != nil {
+		t.Fatalf("HTTPS request error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || resp.TLS == nil {
+		t.Errorf("response = %d over TLS %v, want 204 over TLS", resp.StatusCode, resp.TLS != nil)
+	}
+}
+
+func TestLoadTLSDisabled(t *testing.T) {
+	serverTLS, dialCreds, err := loadTLS(&config.Config{})
+	if err != nil || serverTLS != nil {
+		t.Fatalf("loadTLS without a certificate = %v, %v; want plaintext", serverTLS, err)
+	}
+	if dialCreds.Info().SecurityProtocol != "insecure" {
+		t.Errorf("loopback credentials = %q, want insecure", dialCreds.Info().SecurityProtocol)
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"repo-context-service/internal/cache"
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/interceptors"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// apiKeyPrefix marks keys issued by this service so they're easy to spot in leaks
+const apiKeyPrefix = "rcs"
+
+type AdminServer struct {
+	repocontextv1.UnimplementedAdminServiceServer
+	config  *config.Config
+	cache   *cache.RedisCache
+	metrics *observability.Metrics
+	tracer  *observability.Tracer
+}
+
+func NewAdminServer(
+	cfg *config.Config,
+	cache *cache.RedisCache,
+	metrics *observability.Metrics,
+	tracer *observability.Tracer,
+) *AdminServer {
+	return &AdminServer{
+		config:  cfg,
+		cache:   cache,
+		metrics: metrics,
+		tracer:  tracer,
+	}
+}
+
+func (s *AdminServer) CreateAPIKey(ctx context.Context, req *repocontextv1.CreateAPIKeyRequest) (*repocontextv1.CreateAPIKeyResponse, error) {
+	ctx, span := s.tracer.StartRPC(ctx, "CreateAPIKey")
+	defer span.End()
+
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.TenantAttr(tenantID),
+	)
+
+	if len(req.Scopes) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "at least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if !isKnownScope(scope) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown scope %q", scope)
+		}
+	}
+
+	keyID, apiKey, err := generateAPIKey()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate API key: %v", err)
+	}
+
+	record := &cache.APIKeyRecord{
+		KeyID:       keyID,
+		TenantID:    tenantID,
+		Scopes:      req.Scopes,
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := s.cache.SetAPIKey(ctx, apiKey, record); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to store API key: %v", err)
+	}
+
+	return &repocontextv1.CreateAPIKeyResponse{
+		KeyId:     keyID,
+		ApiKey:    apiKey,
+		TenantId:  tenantID,
+		Scopes:    record.Scopes,
+		CreatedAt: timestamppb.New(record.CreatedAt),
+	}, nil
+}
+
+func (s *AdminServer) RevokeAPIKey(ctx context.Context, req *repocontextv1.RevokeAPIKeyRequest) (*emptypb.Empty, error) {
+	ctx, span := s.tracer.StartRPC(ctx, "RevokeAPIKey")
+	defer span.End()
+
+	if req.KeyId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "key_id is required")
+	}
+
+	revoked, err := s.cache.DeleteAPIKey(ctx, req.KeyId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to revoke API key: %v", err)
+	}
+	if !revoked {
+		return nil, status.Errorf(codes.NotFound, "API key not found")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// generateAPIKey returns a new key ID and the raw key, formatted as rcs_<id>_<secret>
+func generateAPIKey() (string, string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	keyID := hex.EncodeToString(id)
+	return keyID, fmt.Sprintf("%s_%s_%s", apiKeyPrefix, keyID, base64.RawURLEncoding.EncodeToString(secret)), nil
+}
+
+func isKnownScope(scope string) bool {
+	for _, known := range interceptors.AllScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
@@ -2,8 +2,13 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"repo-context-service/internal/cache"
@@ -22,12 +27,14 @@ type ChatServer struct {
 	config          *config.Config
 	cache           *cache.RedisCache
 	queryService    *QueryService
-	composer        *composer.DeepSeekClient
+	composer        composer.Composer
 	embeddingClient ingest.EmbeddingClient
 	metrics         *observability.Metrics
 	tracer          *observability.Tracer
 	sessions        map[string]*ChatSession
 	sessionsMutex   sync.RWMutex
+	// When logThresholdMiss last probed each repository
+	thresholdProbes sync.Map
 }
 
 type ChatSession struct {
@@ -38,18 +45,51 @@ type ChatSession struct {
 	CreatedAt    time.Time
 	Active       bool
 	CancelFunc   context.CancelFunc
+
+	// ctx scopes searches and composition for the session; CancelFunc cancels it
+	ctx context.Context
+	// When a message last came in, in unix nanoseconds; zero until the first one
+	lastActive atomic.Int64
+
+	// Previous exchanges, oldest first; messages are handled one at a time so no lock is needed
+	history []composer.Turn
+	// Repository details the system prompt template can refer to
+	promptVars composer.PromptVars
+}
+
+func (cs *ChatSession) touch(now time.Time) {
+	cs.lastActive.Store(now.UnixNano())
+}
+
+// idleFor returns how long the session has gone without a message, counting from
+// when it was created until it gets one
+func (cs *ChatSession) idleFor(now time.Time) time.Duration {
+	if last := cs.lastActive.Load(); last != 0 {
+		return now.Sub(time.Unix(0, last))
+	}
+	return now.Sub(cs.CreatedAt)
+}
+
+// maxSessionTurns bounds the history kept per session; the composer trims further by tokens
+const maxSessionTurns = 20
+
+func (cs *ChatSession) addTurn(query, answer string) {
+	cs.history = append(cs.history, composer.Turn{Query: query, Answer: answer})
+	if len(cs.history) > maxSessionTurns {
+		cs.history = cs.history[len(cs.history)-maxSessionTurns:]
+	}
 }
 
 func NewChatServer(
 	cfg *config.Config,
 	cache *cache.RedisCache,
 	queryService *QueryService,
-	composer *composer.DeepSeekClient,
+	composer composer.Composer,
 	embeddingClient ingest.EmbeddingClient,
 	metrics *observability.Metrics,
 	tracer *observability.Tracer,
 ) *ChatServer {
-	return &ChatServer{
+	s := &ChatServer{
 		config:          cfg,
 		cache:           cache,
 		queryService:    queryService,
@@ -59,6 +99,51 @@ func NewChatServer(
 		tracer:          tracer,
 		sessions:        make(map[string]*ChatSession),
 	}
+
+	return s
+}
+
+// SweepSessions evicts sessions whose streams went away without a clean close,
+// every ChatSessionSweepInterval evicting those that have had no message for
+// ChatSessionTTL. It runs until ctx is cancelled.
+func (s *ChatServer) SweepSessions(ctx context.Context) {
+	interval, ttl := s.config.Server.ChatSessionSweepInterval, s.config.Server.ChatSessionTTL
+	if interval <= 0 || ttl <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.evictExpiredSessions(now, ttl)
+		}
+	}
+}
+
+func (s *ChatServer) evictExpiredSessions(now time.Time, ttl time.Duration) int {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+
+	evicted := 0
+	for id, session := range s.sessions {
+		if session.idleFor(now) < ttl {
+			continue
+		}
+		if session.CancelFunc != nil {
+			session.CancelFunc()
+		}
+		session.Active = false
+		delete(s.sessions, id)
+		s.metrics.DecActiveChatSessions()
+		evicted++
+	}
+
+	return evicted
 }
 
 func (s *ChatServer) ChatWithRepository(stream repocontextv1.ChatService_ChatWithRepositoryServer) error {
@@ -73,48 +158,103 @@ func (s *ChatServer) ChatWithRepository(stream repocontextv1.ChatService_ChatWit
 		}
 	}()
 
-	// Main message processing loop
-	for {
-		req, err := stream.Recv()
-		if err != nil {
-			return err
-		}
-
-		switch msg := req.Message.(type) {
-		case *repocontextv1.ChatRequest_Start:
-			// Initialize session
-			session, err = s.handleChatStart(ctx, stream, msg.Start)
+	// Receive in the background so a cancel can arrive while a message is being handled
+	requests := make(chan *repocontextv1.ChatRequest)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
 			if err != nil {
-				return err
+				recvErr <- err
+				return
 			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-		case *repocontextv1.ChatRequest_ChatMessage:
-			// Handle chat message
-			if session == nil {
-				return status.Errorf(codes.FailedPrecondition, "session not initialized")
+	// Non-nil while a chat message is being handled; messages are handled one at a time
+	var handling chan error
+
+	// Main message processing loop
+	for {
+		select {
+		case err := <-recvErr:
+			if handling != nil {
+				<-handling
 			}
-			err = s.handleChatMessage(ctx, stream, session, msg.ChatMessage)
+			return err
+
+		case err := <-handling:
+			handling = nil
 			if err != nil {
 				return err
 			}
 
-		case *repocontextv1.ChatRequest_Cancel:
-			// Handle cancellation
-			if session != nil && session.CancelFunc != nil {
-				session.CancelFunc()
+		case req := <-requests:
+			switch msg := req.Message.(type) {
+			case *repocontextv1.ChatRequest_Start:
+				if handling != nil {
+					return status.Errorf(codes.FailedPrecondition, "cannot restart session while a message is in progress")
+				}
+				// Initialize session
+				var err error
+				session, err = s.handleChatStart(ctx, msg.Start)
+				if err != nil {
+					return err
+				}
+
+			case *repocontextv1.ChatRequest_ChatMessage:
+				// Handle chat message
+				if session == nil {
+					return status.Errorf(codes.FailedPrecondition, "session not initialized")
+				}
+				if handling != nil {
+					if err := <-handling; err != nil {
+						return err
+					}
+				}
+				handling = make(chan error, 1)
+				go func(done chan<- error, session *ChatSession, message *repocontextv1.ChatMessage) {
+					done <- s.handleChatMessage(session.ctx, stream, session, message)
+				}(handling, session, msg.ChatMessage)
+
+			case *repocontextv1.ChatRequest_Cancel:
+				// Handle cancellation: abort the in-flight search/composition and wait for it to stop
+				if session != nil && session.CancelFunc != nil {
+					session.CancelFunc()
+				}
+				if handling != nil {
+					<-handling
+				}
+				return nil
+
+			default:
+				return status.Errorf(codes.InvalidArgument, "unknown message type")
 			}
-			return nil
-
-		default:
-			return status.Errorf(codes.InvalidArgument, "unknown message type")
 		}
 	}
 }
 
-func (s *ChatServer) handleChatStart(ctx context.Context, stream repocontextv1.ChatService_ChatWithRepositoryServer, start *repocontextv1.ChatStart) (*ChatSession, error) {
-	tenantID := start.TenantId
-	if tenantID == "" {
-		tenantID = s.config.Security.DefaultTenant
+func (s *ChatServer) handleChatStart(ctx context.Context, start *repocontextv1.ChatStart) (*ChatSession, error) {
+	tenantID, err := resolveTenantID(ctx, start.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
+	}
+
+	if start.Options != nil {
+		if _, ok := repocontextv1.SearchMode_name[int32(start.Options.SearchMode)]; !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown search mode %d", start.Options.SearchMode)
+		}
+		if start.Options.ContextLines < 0 || start.Options.ContextLines > config.MaxContextLines {
+			return nil, status.Errorf(codes.InvalidArgument, "context_lines must be between 0 and %d", config.MaxContextLines)
+		}
+		if err := validateSearchFilters(start.Options.Filters); err != nil {
+			return nil, err
+		}
 	}
 
 	// Validate repository exists and is ready
@@ -133,7 +273,7 @@ func (s *ChatServer) handleChatStart(ctx context.Context, stream repocontextv1.C
 
 	// Create session
 	sessionID := generateSessionID()
-	_, cancel := context.WithCancel(ctx)
+	sessionCtx, cancel := context.WithCancel(ctx)
 
 	session := &ChatSession{
 		ID:           sessionID,
@@ -143,12 +283,18 @@ func (s *ChatServer) handleChatStart(ctx context.Context, stream repocontextv1.C
 		CreatedAt:    time.Now(),
 		Active:       true,
 		CancelFunc:   cancel,
+		ctx:          sessionCtx,
+		promptVars: composer.PromptVars{
+			RepositoryName: repo.Name,
+			Language:       primaryLanguage(repo.Stats),
+		},
 	}
 
 	// Store session
 	s.sessionsMutex.Lock()
 	s.sessions[sessionID] = session
 	s.sessionsMutex.Unlock()
+	s.metrics.IncActiveChatSessions()
 
 	// Create a no-op span for tracing
 	span := &observability.Span{}
@@ -160,7 +306,37 @@ func (s *ChatServer) handleChatStart(ctx context.Context, stream repocontextv1.C
 	return session, nil
 }
 
-func (s *ChatServer) handleChatMessage(ctx context.Context, stream repocontextv1.ChatService_ChatWithRepositoryServer, session *ChatSession, message *repocontextv1.ChatMessage) error {
+// primaryLanguage is the language with the most lines in stats, or "" if unknown
+func primaryLanguage(stats *repocontextv1.RepositoryStats) string {
+	var primary *repocontextv1.LanguageStats
+	for _, language := range stats.GetLanguages() {
+		if language.Language == "unknown" {
+			continue
+		}
+		if primary == nil || language.LineCount > primary.LineCount {
+			primary = language
+		}
+	}
+	return primary.GetLanguage()
+}
+
+// chatSender receives the events of a chat message: the gRPC stream, or the SSE
+// handler's event writer
+type chatSender interface {
+	Send(*repocontextv1.ChatResponse) error
+}
+
+func (s *ChatServer) handleChatMessage(ctx context.Context, stream chatSender, session *ChatSession, message *repocontextv1.ChatMessage) error {
+	session.touch(time.Now())
+
+	if err := validateLexicalOptions(message.Query, message.LexicalOptions); err != nil {
+		return err
+	}
+	if err := validateSearchFilters(message.Filters); err != nil {
+		return err
+	}
+	filters := messageFilters(session, message)
+
 	queryID := generateQueryID()
 
 	// Send search started event
@@ -179,11 +355,12 @@ func (s *ChatServer) handleChatMessage(ctx context.Context, stream repocontextv1
 	// Record start time for metrics
 	timer := observability.StartTimer()
 
-	// Perform dual search (lexical + semantic)
-	searchResults, err := s.performDualSearch(ctx, session.RepositoryID, message.Query, getTopK(session.Options))
+	// Search the backends selected for this session (lexical + semantic by default)
+	merged, err := s.performSearch(ctx, session.RepositoryID, message.Query, getTopK(session.Options), getSearchMode(session.Options), withSearchFilters(lexicalFilters(message.LexicalOptions), filters), s.getContextLines(session.Options))
 	if err != nil {
-		return status.Errorf(codes.Internal, "search failed: %v", err)
+		return searchStatus(err)
 	}
+	searchResults := merged.Chunks
 
 	// Send early hits after getting first few results
 	earlyHitsSent := false
@@ -231,6 +408,12 @@ func (s *ChatServer) handleChatMessage(ctx context.Context, stream repocontextv1
 		}
 	}
 
+	// Nothing to answer from: the model would only guess, so the configured
+	// message is sent instead of calling it
+	if len(searchResults) == 0 {
+		return s.sendNoResultsAnswer(stream, session, message, queryID, merged)
+	}
+
 	// Start composition phase
 	err = stream.Send(&repocontextv1.ChatResponse{
 		Message: &repocontextv1.ChatResponse_CompositionStarted{
@@ -246,11 +429,57 @@ func (s *ChatServer) handleChatMessage(ctx context.Context, stream repocontextv1
 	}
 
 	compositionTimer := observability.StartTimer()
+	ctx = composer.WithPromptVars(ctx, session.promptVars)
+
+	// The answer is kept in the session history for follow-up questions
+	var answer string
+	var usage *repocontextv1.TokenUsage
 
 	// Compose answer using LLM
-	if session.Options != nil && session.Options.StreamTokens {
+	if toolComposer, ok := s.composer.(composer.ToolComposer); ok && s.config.DeepSeek.MaxToolIterations > 0 {
+		// Tool-calling composition; the answer is only known once the model stops fetching context
+		fetcher := &repositoryFetcher{server: s, repositoryID: session.RepositoryID, searchMode: getSearchMode(session.Options), filters: filters, contextLines: s.getContextLines(session.Options)}
+		result, err := toolComposer.ComposeAnswerWithTools(ctx, message.Query, searchResults, session.history, fetcher)
+		if err != nil {
+			return status.Errorf(codes.Internal, "composition failed: %v", err)
+		}
+		answer = result.FullResponse
+		usage = tokenUsage(result)
+
+		if session.Options != nil && session.Options.StreamTokens {
+			// Send full response at once
+			err = stream.Send(&repocontextv1.ChatResponse{
+				Message: &repocontextv1.ChatResponse_CompositionToken{
+					CompositionToken: &repocontextv1.CompositionToken{
+						SessionId: session.ID,
+						QueryId:   queryID,
+						Text:      result.FullResponse,
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		err = stream.Send(&repocontextv1.ChatResponse{
+			Message: &repocontextv1.ChatResponse_CompositionComplete{
+				CompositionComplete: &repocontextv1.CompositionComplete{
+					SessionId:    session.ID,
+					QueryId:      queryID,
+					FullResponse: result.FullResponse,
+					Citations:    result.Citations,
+					Usage:        usage,
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+	} else if session.Options != nil && session.Options.StreamTokens {
 		// Streaming composition
-		result, err := s.composer.ComposeAnswerStream(ctx, message.Query, searchResults, func(token string) error {
+		result, err := s.composer.ComposeAnswerStream(ctx, message.Query, searchResults, session.history, func(token string) error {
 			return stream.Send(&repocontextv1.ChatResponse{
 				Message: &repocontextv1.ChatResponse_CompositionToken{
 					CompositionToken: &repocontextv1.CompositionToken{
@@ -264,6 +493,8 @@ func (s *ChatServer) handleChatMessage(ctx context.Context, stream repocontextv1
 		if err != nil {
 			return status.Errorf(codes.Internal, "composition failed: %v", err)
 		}
+		answer = result.FullResponse
+		usage = tokenUsage(result)
 
 		// Send final composition
 		err = stream.Send(&repocontextv1.ChatResponse{
@@ -273,6 +504,7 @@ func (s *ChatServer) handleChatMessage(ctx context.Context, stream repocontextv1
 					QueryId:      queryID,
 					FullResponse: result.FullResponse,
 					Citations:    result.Citations,
+					Usage:        usage,
 				},
 			},
 		})
@@ -282,10 +514,12 @@ func (s *ChatServer) handleChatMessage(ctx context.Context, stream repocontextv1
 
 	} else {
 		// Non-streaming composition
-		result, err := s.composer.ComposeAnswer(ctx, message.Query, searchResults)
+		result, err := s.composer.ComposeAnswer(ctx, message.Query, searchResults, session.history)
 		if err != nil {
 			return status.Errorf(codes.Internal, "composition failed: %v", err)
 		}
+		answer = result.FullResponse
+		usage = tokenUsage(result)
 
 		err = stream.Send(&repocontextv1.ChatResponse{
 			Message: &repocontextv1.ChatResponse_CompositionComplete{
@@ -294,6 +528,7 @@ func (s *ChatServer) handleChatMessage(ctx context.Context, stream repocontextv1
 					QueryId:      queryID,
 					FullResponse: result.FullResponse,
 					Citations:    result.Citations,
+					Usage:        usage,
 				},
 			},
 		})
@@ -303,27 +538,18 @@ func (s *ChatServer) handleChatMessage(ctx context.Context, stream repocontextv1
 	}
 
 	s.metrics.RecordTimeToSummary(compositionTimer.Duration())
+	session.addTurn(message.Query, answer)
 
-	// Send completion message
+	// Send completion message with the measured search timings
+	merged.Timings.CompositionMs = int32(compositionTimer.Duration().Milliseconds())
 	err = stream.Send(&repocontextv1.ChatResponse{
 		Message: &repocontextv1.ChatResponse_Complete{
 			Complete: &repocontextv1.ChatComplete{
 				SessionId: session.ID,
 				QueryId:   queryID,
-				Timings: &repocontextv1.SearchTimings{
-					// These would be filled from actual search timings
-					LexicalMs:     100, // Placeholder
-					SemanticMs:    200, // Placeholder
-					MergeMs:       10,  // Placeholder
-					CompositionMs: int32(compositionTimer.Duration().Milliseconds()),
-					CacheHit:      false,
-				},
-				Stats: &repocontextv1.SearchStats{
-					LexicalCandidates:  int32(len(searchResults)),
-					SemanticCandidates: int32(len(searchResults)),
-					MergedResults:      int32(len(searchResults)),
-					ResultsTruncated:   false,
-				},
+				Timings:   merged.Timings,
+				Stats:     merged.Stats,
+				Usage:     usage,
 			},
 		},
 	})
@@ -331,6 +557,65 @@ func (s *ChatServer) handleChatMessage(ctx context.Context, stream repocontextv1
 	return err
 }
 
+// sendNoResultsAnswer completes a chat turn whose search found nothing with the
+// configured no-results message, without calling the composer. The turn is kept in
+// the session history so follow-ups see the question was asked.
+func (s *ChatServer) sendNoResultsAnswer(stream chatSender, session *ChatSession, message *repocontextv1.ChatMessage, queryID string, merged *query.MergedResults) error {
+	answer := s.config.Composer.NoResultsMessage
+
+	if session.Options != nil && session.Options.StreamTokens {
+		err := stream.Send(&repocontextv1.ChatResponse{
+			Message: &repocontextv1.ChatResponse_CompositionToken{
+				CompositionToken: &repocontextv1.CompositionToken{
+					SessionId: session.ID,
+					QueryId:   queryID,
+					Text:      answer,
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err := stream.Send(&repocontextv1.ChatResponse{
+		Message: &repocontextv1.ChatResponse_CompositionComplete{
+			CompositionComplete: &repocontextv1.CompositionComplete{
+				SessionId:    session.ID,
+				QueryId:      queryID,
+				FullResponse: answer,
+				Usage:        &repocontextv1.TokenUsage{},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	session.addTurn(message.Query, answer)
+
+	return stream.Send(&repocontextv1.ChatResponse{
+		Message: &repocontextv1.ChatResponse_Complete{
+			Complete: &repocontextv1.ChatComplete{
+				SessionId: session.ID,
+				QueryId:   queryID,
+				Timings:   merged.Timings,
+				Stats:     merged.Stats,
+				Usage:     &repocontextv1.TokenUsage{},
+			},
+		},
+	})
+}
+
+// tokenUsage reports the tokens a composition used
+func tokenUsage(result *composer.CompositionResult) *repocontextv1.TokenUsage {
+	return &repocontextv1.TokenUsage{
+		PromptTokens:     int32(result.PromptTokens),
+		CompletionTokens: int32(result.CompletionTokens),
+		TotalTokens:      int32(result.TokenCount),
+	}
+}
+
 func (s *ChatServer) cleanupSession(sessionID string) {
 	s.sessionsMutex.Lock()
 	defer s.sessionsMutex.Unlock()
@@ -340,6 +625,7 @@ func (s *ChatServer) cleanupSession(sessionID string) {
 			session.CancelFunc()
 		}
 		delete(s.sessions, sessionID)
+		s.metrics.DecActiveChatSessions()
 	}
 }
 
@@ -360,51 +646,296 @@ func getTopK(options *repocontextv1.ChatOptions) int32 {
 	return 10 // Default
 }
 
-// performDualSearch performs both lexical and semantic search and merges results
-func (s *ChatServer) performDualSearch(ctx context.Context, repositoryID, queryText string, limit int32) ([]*repocontextv1.CodeChunk, error) {
-	// Perform lexical search using ripgrep
-	lexicalResults, err := s.queryService.lexicalClient.SearchLexical(ctx, repositoryID, queryText, int(limit), nil)
+// getContextLines returns the lines of context to add around semantic hits
+func (s *ChatServer) getContextLines(options *repocontextv1.ChatOptions) int {
+	if options != nil && options.ContextLines > 0 {
+		return int(options.ContextLines)
+	}
+	return s.config.Defaults.SemanticContextLines
+}
+
+// getSearchMode returns the backends selected in the chat options, defaulting to both
+func getSearchMode(options *repocontextv1.ChatOptions) repocontextv1.SearchMode {
+	if options != nil && options.SearchMode != repocontextv1.SearchMode_SEARCH_MODE_UNSPECIFIED {
+		return options.SearchMode
+	}
+	return repocontextv1.SearchMode_SEARCH_MODE_BOTH
+}
+
+// validateLexicalOptions checks the lexical options sent with a query
+func validateLexicalOptions(queryText string, options *repocontextv1.LexicalOptions) error {
+	if lines := options.GetContextLines(); lines < 0 || lines > config.MaxContextLines {
+		return status.Errorf(codes.InvalidArgument, "lexical context_lines must be between 0 and %d", config.MaxContextLines)
+	}
+	if options.GetRegex() {
+		if _, err := regexp.Compile(queryText); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid regex query: %v", err)
+		}
+	}
+	return nil
+}
+
+// lexicalFilters turns the lexical options of a chat message into lexical search filters
+func lexicalFilters(options *repocontextv1.LexicalOptions) map[string]interface{} {
+	if options == nil {
+		return nil
+	}
+	filters := map[string]interface{}{
+		"regex":          options.Regex,
+		"case_sensitive": options.CaseSensitive,
+		"whole_word":     options.WholeWord,
+	}
+	if options.ContextLines != nil {
+		filters["context_lines"] = int(options.GetContextLines())
+	}
+	return filters
+}
+
+// validateSearchFilters checks the file patterns of a query's search filters
+func validateSearchFilters(filters *repocontextv1.SearchFilters) error {
+	for _, pattern := range filters.GetFilePatterns() {
+		if _, err := filepath.Match(strings.TrimPrefix(pattern, "!"), ""); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid file pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// messageFilters returns the search filters of a chat message, or the session's
+// when the message has none
+func messageFilters(session *ChatSession, message *repocontextv1.ChatMessage) *repocontextv1.SearchFilters {
+	if message.Filters != nil {
+		return message.Filters
+	}
+	return session.Options.GetFilters()
+}
+
+// withSearchFilters adds the languages, file patterns and path prefix of a query to
+// its search filters, which both the lexical and the semantic backends read
+func withSearchFilters(filters map[string]interface{}, searchFilters *repocontextv1.SearchFilters) map[string]interface{} {
+	if searchFilters == nil {
+		return filters
+	}
+	if filters == nil {
+		filters = make(map[string]interface{})
+	}
+	if len(searchFilters.Languages) > 0 {
+		filters["languages"] = searchFilters.Languages
+	}
+	if len(searchFilters.FilePatterns) > 0 {
+		filters["file_patterns"] = searchFilters.FilePatterns
+	}
+	if searchFilters.PathPrefix != "" {
+		filters["path_prefix"] = searchFilters.PathPrefix
+	}
+	return filters
+}
+
+// performSearch runs the lexical and/or semantic search selected by mode and merges
+// the results. Semantic hits are widened by contextLines lines read from the repository.
+// The returned timings and stats reflect the backend calls made for this query.
+func (s *ChatServer) performSearch(ctx context.Context, repositoryID, queryText string, limit int32, mode repocontextv1.SearchMode, filters map[string]interface{}, contextLines int) (*query.MergedResults, error) {
+	// Semantic time covers both the query embedding and the vector search
+	embeddingTimer := observability.StartTimer()
+	queryEmbedding, err := s.searchEmbedding(ctx, queryText, mode)
 	if err != nil {
-		return nil, fmt.Errorf("lexical search failed: %w", err)
+		return nil, err
 	}
+	embeddingTime := embeddingTimer.Duration()
 
-	// Generate embedding for semantic search
-	queryEmbedding, err := s.generateQueryEmbedding(ctx, queryText)
+	searchResults, err := s.searchRepository(ctx, repositoryID, queryText, queryEmbedding, limit, 0, s.config.Defaults.SemanticMinCertainty, mode, filters, contextLines)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+		return nil, err
+	}
+	if mode != repocontextv1.SearchMode_SEARCH_MODE_LEXICAL {
+		searchResults.SemanticTime += embeddingTime
 	}
 
-	// Perform semantic search using Weaviate
-	semanticResults, err := s.queryService.semanticClient.SearchSemantic(ctx, repositoryID, queryEmbedding, int(limit), nil)
+	return limitResults(s.queryService.merger.MergeAndRank(searchResults), limit), nil
+}
+
+// searchEmbedding embeds the query for the semantic search selected by mode. Without
+// an embedding a combined search degrades to lexical-only, so nil is returned with no
+// error unless the search is semantic-only.
+func (s *ChatServer) searchEmbedding(ctx context.Context, queryText string, mode repocontextv1.SearchMode) ([]float32, error) {
+	if mode == repocontextv1.SearchMode_SEARCH_MODE_LEXICAL {
+		return nil, nil
+	}
+
+	queryEmbedding, err := s.generateQueryEmbedding(ctx, queryText)
 	if err != nil {
-		return nil, fmt.Errorf("semantic search failed: %w", err)
+		reason := "error"
+		if errors.Is(err, errEmptyEmbedding) {
+			reason = "empty"
+		}
+		s.metrics.RecordQueryEmbeddingFailure(reason)
+		if mode == repocontextv1.SearchMode_SEARCH_MODE_SEMANTIC {
+			return nil, fmt.Errorf("semantic search failed: %w", err)
+		}
+		observability.Logger(ctx).Warn("searchEmbedding: Skipping semantic search", "error", err)
+		return nil, nil
 	}
 
-	// Merge and rank results
-	mergedResults := s.queryService.merger.MergeAndRank(&query.SearchResults{
-		LexicalChunks:  lexicalResults,
-		SemanticChunks: semanticResults,
-	})
+	return queryEmbedding, nil
+}
 
-	// Convert to final results (take top results based on limit)
-	maxResults := int(limit)
-	if len(mergedResults.Chunks) < maxResults {
-		maxResults = len(mergedResults.Chunks)
+// searchRepository runs the searches selected by mode against one repository without
+// merging them. Semantic search is skipped when queryEmbedding is nil, keeps hits
+// with a certainty of at least minCertainty, and skips the first semanticOffset.
+func (s *ChatServer) searchRepository(ctx context.Context, repositoryID, queryText string, queryEmbedding []float32, limit, semanticOffset int32, minCertainty float32, mode repocontextv1.SearchMode, filters map[string]interface{}, contextLines int) (*query.SearchResults, error) {
+	searchResults := &query.SearchResults{}
+
+	if hybrid, ok := s.queryService.semanticClient.(query.HybridSearcher); ok && s.config.Weaviate.HybridSearch &&
+		mode == repocontextv1.SearchMode_SEARCH_MODE_BOTH && queryEmbedding != nil {
+		// One fused query over the indexed chunks replaces both backends
+		hybridTimer := observability.StartTimer()
+		hybridResults, err := hybrid.SearchHybrid(ctx, repositoryID, queryText, queryEmbedding, int(limit), int(semanticOffset), filters)
+		if err == nil {
+			searchResults.SemanticChunks = query.ExpandContext(ctx, s.queryService.lexicalClient, hybridResults, contextLines)
+			searchResults.SemanticTime = hybridTimer.Duration()
+			return searchResults, nil
+		}
+		if !errors.Is(err, query.ErrCollectionNotFound) {
+			return nil, fmt.Errorf("hybrid search failed: %w", err)
+		}
+		// A repository that isn't indexed yet can still be searched with ripgrep
+		observability.Logger(ctx).Info("searchRepository: Skipping hybrid search", observability.LogKeyRepositoryID, repositoryID, "error", err)
 	}
 
-	return mergedResults.Chunks[:maxResults], nil
+	if mode != repocontextv1.SearchMode_SEARCH_MODE_SEMANTIC {
+		// Perform lexical search using ripgrep
+		lexicalTimer := observability.StartTimer()
+		lexicalResults, err := s.queryService.lexicalClient.SearchLexical(ctx, repositoryID, queryText, int(limit), filters)
+		if err != nil {
+			return nil, fmt.Errorf("lexical search failed: %w", err)
+		}
+		searchResults.LexicalChunks = lexicalResults
+		searchResults.LexicalTime = lexicalTimer.Duration()
+	}
+
+	if mode != repocontextv1.SearchMode_SEARCH_MODE_LEXICAL && queryEmbedding != nil {
+		// Perform semantic search against the vector store
+		semanticTimer := observability.StartTimer()
+		var semanticResults []*repocontextv1.CodeChunk
+		var semanticVectors map[*repocontextv1.CodeChunk][]float32
+		var err error
+		if s.queryService.merger.UsesVectors() {
+			semanticResults, semanticVectors, err = query.SearchSemanticVectors(ctx, s.queryService.semanticClient, repositoryID, queryEmbedding, int(limit), int(semanticOffset), minCertainty, filters)
+		} else {
+			semanticResults, err = s.queryService.semanticClient.SearchSemantic(ctx, repositoryID, queryEmbedding, int(limit), int(semanticOffset), minCertainty, filters)
+		}
+		if errors.Is(err, query.ErrCollectionNotFound) && mode != repocontextv1.SearchMode_SEARCH_MODE_SEMANTIC {
+			// Lexical search still works without the vector index
+			observability.Logger(ctx).Info("searchRepository: Skipping semantic search", observability.LogKeyRepositoryID, repositoryID, "error", err)
+		} else if err != nil {
+			return nil, fmt.Errorf("semantic search failed: %w", err)
+		} else {
+			// An empty later page is expected, so only a first page is checked
+			if len(semanticResults) == 0 && minCertainty > 0 && semanticOffset == 0 {
+				s.logThresholdMiss(ctx, repositoryID, queryEmbedding, minCertainty, filters)
+			}
+			searchResults.SemanticChunks = query.ExpandContext(ctx, s.queryService.lexicalClient, semanticResults, contextLines)
+			// Context widens each hit into a new chunk, in the same order
+			if semanticVectors != nil {
+				searchResults.SemanticVectors = make(map[*repocontextv1.CodeChunk][]float32, len(semanticVectors))
+				for i, chunk := range semanticResults {
+					if vector, ok := semanticVectors[chunk]; ok {
+						searchResults.SemanticVectors[searchResults.SemanticChunks[i]] = vector
+					}
+				}
+			}
+		}
+		searchResults.SemanticTime = semanticTimer.Duration()
+	}
+
+	return searchResults, nil
+}
+
+// thresholdProbeInterval is how often logThresholdMiss probes a repository, and
+// thresholdProbeTimeout bounds each probe
+const (
+	thresholdProbeInterval = time.Minute
+	thresholdProbeTimeout  = 5 * time.Second
+)
+
+// logThresholdMiss tells an empty repository apart from one whose chunks all fall
+// below the certainty threshold, logging the nearest chunk's certainty. The extra
+// search runs in the background, at most once per repository per
+// thresholdProbeInterval, so it adds no latency or load to a busy repository.
+func (s *ChatServer) logThresholdMiss(ctx context.Context, repositoryID string, queryEmbedding []float32, minCertainty float32, filters map[string]interface{}) {
+	now := time.Now()
+	if last, ok := s.thresholdProbes.Load(repositoryID); ok && now.Sub(last.(time.Time)) < thresholdProbeInterval {
+		return
+	}
+	s.thresholdProbes.Store(repositoryID, now)
+
+	// The request may end before the probe does
+	probeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), thresholdProbeTimeout)
+	go func() {
+		defer cancel()
+		nearest, err := s.queryService.semanticClient.SearchSemantic(probeCtx, repositoryID, queryEmbedding, 1, 0, 0, filters)
+		if err != nil || len(nearest) == 0 {
+			return
+		}
+		observability.Logger(probeCtx).Info("searchRepository: No semantic results above the certainty threshold (see SEMANTIC_MIN_CERTAINTY)",
+			observability.LogKeyRepositoryID, repositoryID, "min_certainty", minCertainty, "nearest_certainty", nearest[0].Score)
+	}()
+}
+
+// pageResults keeps the limit merged chunks that follow the first offset
+func pageResults(merged *query.MergedResults, offset, limit int32) *query.MergedResults {
+	if int(offset) >= len(merged.Chunks) {
+		merged.Chunks = nil
+	} else {
+		merged.Chunks = merged.Chunks[offset:]
+	}
+	merged.Stats.MergedResults = int32(len(merged.Chunks))
+	return limitResults(merged, limit)
+}
+
+// limitResults keeps the top limit merged chunks
+func limitResults(merged *query.MergedResults, limit int32) *query.MergedResults {
+	if len(merged.Chunks) > int(limit) {
+		merged.Chunks = merged.Chunks[:limit]
+		merged.Stats.MergedResults = limit
+		merged.Stats.ResultsTruncated = true
+	}
+	return merged
 }
 
-// generateQueryEmbedding generates an embedding for the search query
+// repositoryFetcher lets the composer pull extra context from a repository through tool calls
+type repositoryFetcher struct {
+	server       *ChatServer
+	repositoryID string
+	searchMode   repocontextv1.SearchMode
+	filters      *repocontextv1.SearchFilters
+	contextLines int
+}
+
+func (f *repositoryFetcher) GetFile(ctx context.Context, path string, startLine, endLine int) (*repocontextv1.CodeChunk, error) {
+	return f.server.queryService.lexicalClient.ReadFile(ctx, f.repositoryID, path, startLine, endLine)
+}
+
+func (f *repositoryFetcher) Search(ctx context.Context, queryText string, limit int) ([]*repocontextv1.CodeChunk, error) {
+	merged, err := f.server.performSearch(ctx, f.repositoryID, queryText, int32(limit), f.searchMode, withSearchFilters(nil, f.filters), f.contextLines)
+	if err != nil {
+		return nil, err
+	}
+	return merged.Chunks, nil
+}
+
+var errEmptyEmbedding = errors.New("received empty embedding")
+
+// generateQueryEmbedding generates an embedding for the search query. It uses the
+// same model as ingestion so the query lands in the same vector space as the chunks.
 func (s *ChatServer) generateQueryEmbedding(ctx context.Context, queryText string) ([]float32, error) {
-	// Use the embedding client to generate query embeddings
-	embeddings, err := s.embeddingClient.GenerateEmbeddings(ctx, []string{queryText}, "text-embedding-ada-002")
+	embeddings, err := ingest.EmbedTexts(ctx, s.embeddingClient, []string{queryText}, s.embeddingClient.GetDefaultModel())
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate embedding: %w", err)
 	}
 
 	if len(embeddings) == 0 || len(embeddings[0]) == 0 {
-		return nil, fmt.Errorf("received empty embedding")
+		return nil, errEmptyEmbedding
 	}
 
 	return embeddings[0], nil
@@ -412,8 +943,8 @@ func (s *ChatServer) generateQueryEmbedding(ctx context.Context, queryText strin
 
 // QueryService interface for compatibility
 type QueryService struct {
-	lexicalClient  *query.RipgrepClient
-	semanticClient *query.WeaviateClient
+	lexicalClient  query.LexicalClient
+	semanticClient query.SemanticClient
 	merger         *query.ResultMerger
 	cache          *cache.RedisCache
 	metrics        *observability.Metrics
@@ -421,8 +952,8 @@ type QueryService struct {
 }
 
 func NewQueryService(
-	lexicalClient *query.RipgrepClient,
-	semanticClient *query.WeaviateClient,
+	lexicalClient query.LexicalClient,
+	semanticClient query.SemanticClient,
 	merger *query.ResultMerger,
 	cache *cache.RedisCache,
 	metrics *observability.Metrics,
@@ -439,11 +970,11 @@ func NewQueryService(
 }
 
 // Getter methods for QueryService clients
-func (qs *QueryService) GetLexicalClient() *query.RipgrepClient {
+func (qs *QueryService) GetLexicalClient() query.LexicalClient {
 	return qs.lexicalClient
 }
 
-func (qs *QueryService) GetSemanticClient() *query.WeaviateClient {
+func (qs *QueryService) GetSemanticClient() query.SemanticClient {
 	return qs.semanticClient
 }
 
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode"
+
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxSSERequestSize bounds the JSON body of a chat event stream request
+const maxSSERequestSize = 64 * 1024
+
+// SSEChatRequest is the body of POST /v1/chat/{repository_id}/events. Options,
+// filters and lexical options take the same JSON as the WebSocket start and
+// chat_message.
+type SSEChatRequest struct {
+	TenantID       string            `json:"tenant_id"`
+	Query          string            `json:"query"`
+	Options        *WSChatOptions    `json:"options,omitempty"`
+	Filters        *WSSearchFilters  `json:"filters,omitempty"`
+	LexicalOptions *WSLexicalOptions `json:"lexical_options,omitempty"`
+}
+
+// ChatSSEHandler answers one chat message as a Server-Sent Events stream, for
+// clients and proxies that handle SSE better than WebSockets. It runs the same
+// session start and message handling as ChatWithRepository; each event's data is
+// the JSON the WebSocket bridge sends under the same name.
+type ChatSSEHandler struct {
+	chatServer *ChatServer
+	metrics    *observability.Metrics
+	tracer     *observability.Tracer
+
+	// Cancels the streams in flight, keyed by a per-stream ID, and whether Shutdown
+	// has started (new streams are refused from then on)
+	streams      map[uint64]context.CancelFunc
+	nextStreamID uint64
+	streamsMutex sync.Mutex
+	active       sync.WaitGroup
+	shuttingDown bool
+}
+
+func NewChatSSEHandler(
+	chatServer *ChatServer,
+	metrics *observability.Metrics,
+	tracer *observability.Tracer,
+) *ChatSSEHandler {
+	return &ChatSSEHandler{
+		chatServer: chatServer,
+		metrics:    metrics,
+		tracer:     tracer,
+		streams:    make(map[uint64]context.CancelFunc),
+	}
+}
+
+func (h *ChatSSEHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/v1/chat/{repository_id}/events", h.HandleChatEvents).Methods("POST")
+}
+
+// HandleChatEvents streams search_started, search_hit, composition_started,
+// composition_token (with stream_tokens), composition_complete and complete
+// events. Requests that fail before the stream starts get an HTTP error; later
+// failures end the stream with an error event.
+func (h *ChatSSEHandler) HandleChatEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, done, ok := h.trackStream(r.Context())
+	if !ok {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer done()
+
+	ctx, span := h.tracer.StartRPC(ctx, "ChatEvents")
+	defer span.End()
+
+	var req SSEChatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSSERequestSize)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		http.Error(w, "query is required", http.StatusBadRequest)
+		return
+	}
+
+	var options *repocontextv1.ChatOptions
+	if req.Options != nil {
+		searchMode, ok := wsSearchModes[req.Options.SearchMode]
+		if !ok {
+			http.Error(w, "search_mode must be one of both, lexical or semantic", http.StatusBadRequest)
+			return
+		}
+		options = &repocontextv1.ChatOptions{
+			MaxResults:   req.Options.MaxResults,
+			StreamTokens: req.Options.StreamTokens,
+			Model:        req.Options.Model,
+			SearchMode:   searchMode,
+			ContextLines: req.Options.ContextLines,
+			Filters:      searchFiltersFromWS(req.Options.Filters),
+		}
+	}
+
+	s := h.chatServer
+	session, err := s.handleChatStart(ctx, &repocontextv1.ChatStart{
+		RepositoryId: mux.Vars(r)["repository_id"],
+		TenantId:     req.TenantID,
+		Options:      options,
+	})
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	defer s.cleanupSession(session.ID)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Keep reverse proxies such as nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	events := &sseWriter{w: w, rc: http.NewResponseController(w)}
+	if err := events.rc.Flush(); err != nil {
+		observability.Logger(r.Context()).Warn("HandleChatEvents: Failed to flush response", "error", err)
+		return
+	}
+
+	err = s.handleChatMessage(session.ctx, events, session, &repocontextv1.ChatMessage{
+		Query:          req.Query,
+		SessionId:      session.ID,
+		Filters:        searchFiltersFromWS(req.Filters),
+		LexicalOptions: lexicalOptionsFromWS(req.LexicalOptions),
+	})
+	if err != nil && !events.failed {
+		st := status.Convert(err)
+		observability.Logger(r.Context()).Warn("HandleChatEvents: Chat failed", "session_id", session.ID, "error", err)
+		events.writeEvent("error", &WSError{
+			SessionID:    session.ID,
+			ErrorCode:    errorCodeName(st.Code()),
+			ErrorMessage: st.Message(),
+		})
+	}
+}
+
+// trackStream registers a stream so Shutdown can cancel it. It returns the stream's
+// context and a func to call when the stream ends, or false once shutting down.
+func (h *ChatSSEHandler) trackStream(ctx context.Context) (context.Context, func(), bool) {
+	h.streamsMutex.Lock()
+	defer h.streamsMutex.Unlock()
+
+	if h.shuttingDown {
+		return nil, nil, false
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	id := h.nextStreamID
+	h.nextStreamID++
+	h.streams[id] = cancel
+	h.active.Add(1)
+
+	return ctx, func() {
+		h.streamsMutex.Lock()
+		delete(h.streams, id)
+		h.streamsMutex.Unlock()
+		cancel()
+		h.active.Done()
+	}, true
+}
+
+// Shutdown cancels the chats being streamed and waits for their handlers to return,
+// or for ctx to end. The HTTP server's own shutdown would otherwise wait on streams
+// that only end when the answer is complete.
+func (h *ChatSSEHandler) Shutdown(ctx context.Context) error {
+	h.streamsMutex.Lock()
+	h.shuttingDown = true
+	slog.Info("Shutdown: Cancelling chat event streams", "streams", len(h.streams))
+	for _, cancel := range h.streams {
+		cancel()
+	}
+	h.streamsMutex.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		h.active.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sseWriter sends chat responses as SSE events, flushing after each one
+type sseWriter struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+	rc *http.ResponseController
+	// Set once a write fails; the client is gone and the chat is abandoned
+	failed bool
+}
+
+func (e *sseWriter) Send(resp *repocontextv1.ChatResponse) error {
+	event, data := sseEvent(convertGRPCToWebSocket(resp))
+	if event == "" {
+		return nil
+	}
+	return e.writeEvent(event, data)
+}
+
+func (e *sseWriter) writeEvent(event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", event, err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, err := fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		e.failed = true
+		return err
+	}
+	if err := e.rc.Flush(); err != nil {
+		e.failed = true
+		return err
+	}
+	return nil
+}
+
+// sseEvent names the event a WebSocket response is sent as, with its data
+func sseEvent(resp *WSResponse) (string, interface{}) {
+	switch {
+	case resp.SearchStarted != nil:
+		return "search_started", resp.SearchStarted
+	case resp.SearchHit != nil:
+		return "search_hit", resp.SearchHit
+	case resp.CompositionStarted != nil:
+		return "composition_started", resp.CompositionStarted
+	case resp.CompositionToken != nil:
+		return "composition_token", resp.CompositionToken
+	case resp.CompositionComplete != nil:
+		return "composition_complete", resp.CompositionComplete
+	case resp.Error != nil:
+		return "error", resp.Error
+	case resp.Complete != nil:
+		return "complete", resp.Complete
+	default:
+		return "", nil
+	}
+}
+
+// errorCodeName spells a status code the way WebSocket error codes are spelled,
+// e.g. DeadlineExceeded as "deadline_exceeded"
+func errorCodeName(code codes.Code) string {
+	var b strings.Builder
+	for i, r := range code.String() {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
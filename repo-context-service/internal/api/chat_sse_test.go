@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"github.com/gorilla/mux"
+)
+
+// sseEventRecord is one event read from a Server-Sent Events stream
+type sseEventRecord struct {
+	name string
+	data string
+}
+
+// readSSEEvents reads events from an SSE body until it ends
+func readSSEEvents(t *testing.T, resp *http.Response) []sseEventRecord {
+	t.Helper()
+	var events []sseEventRecord
+	var current sseEventRecord
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		case line == "" && current.name != "":
+			events = append(events, current)
+			current = sseEventRecord{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestChatSSEShutdownCancelsStreams(t *testing.T) {
+	h := NewChatSSEHandler(nil, observability.NewMetrics(), observability.NewNoOpTracer())
+
+	ctx, done, ok := h.trackStream(context.Background())
+	if !ok {
+		t.Fatal("trackStream refused a stream before shutdown")
+	}
+	go func() {
+		<-ctx.Done()
+		done()
+	}()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := h.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Shutdown error = %v, want the stream cancelled and drained", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.HandleChatEvents(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/repo-1/events", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("stream after shutdown got %d, want 503", rec.Code)
+	}
+}
+
+func TestChatSSEEventSequence(t *testing.T) {
+	search := &fixedSearchClient{}
+	for i := 1; i <= 4; i++ {
+		search.chunks = append(search.chunks, &repocontextv1.CodeChunk{FilePath: fmt.Sprintf("file%d.go", i), StartLine: 1, EndLine: 10, Content: "func f() {}", Score: float32(5 - i)})
+	}
+	chatServer, _ := newTestChatServer(t, search, &scriptedComposer{tokens: []string{"The ", "answer."}})
+	router := mux.NewRouter()
+	NewChatSSEHandler(chatServer, observability.NewMetrics(), observability.NewNoOpTracer()).RegisterRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		streamTokens bool
+		want         []string
+	}{
+		{"whole answer", false, []string{"search_started", "search_hit", "search_hit", "search_hit", "search_hit", "composition_started", "composition_complete", "complete"}},
+		{"streamed tokens", true, []string{"search_started", "search_hit", "search_hit", "search_hit", "search_hit", "composition_started", "composition_token", "composition_token", "composition_complete", "complete"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"query": "how are widgets built?", "options": {"stream_tokens": %v}}`, tt.streamTokens)
+			resp, err := http.Post(server.URL+"/v1/chat/repo-1/events", "application/json", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+				t.Fatalf("response = %d %q, want a 200 event stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+			}
+
+			events := readSSEEvents(t, resp)
+			var names []string
+			for _, event := range events {
+				names = append(names, event.name)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.want) {
+				t.Fatalf("events = %v, want %v", names, tt.want)
+			}
+
+			var started WSSearchStarted
+			if err := json.Unmarshal([]byte(events[0].data), &started); err != nil || started.SessionID == "" || started.QueryID == "" {
+				t.Errorf("search_started = %s, %v; want a session and query", events[0].data, err)
+			}
+			var complete WSCompositionComplete
+			if err := json.Unmarshal([]byte(events[len(events)-2].data), &complete); err != nil {
+				t.Fatal(err)
+			}
+			if complete.FullResponse != "The answer." || complete.SessionID != started.SessionID || complete.QueryID != started.QueryID {
+				t.Errorf("composition_complete = %+v, want the whole answer to query %s", complete, started.QueryID)
+			}
+		})
+	}
+
+	// A repository that doesn't exist fails before the stream starts
+	resp, err := http.Post(server.URL+"/v1/chat/repo-missing/events", "application/json", strings.NewReader(`{"query": "anything"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("chat about a missing repository got %d, want 404", resp.StatusCode)
+	}
+}
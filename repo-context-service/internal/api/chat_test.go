@@ -0,0 +1,958 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/cache"
+	"repo-context-service/internal/composer"
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	"repo-context-service/internal/query"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// fixedSearchClient is a lexical and semantic client answering every search with
+// the same chunks, recording the searches it gets
+type fixedSearchClient struct {
+	chunks      []*repocontextv1.CodeChunk
+	semanticErr error
+
+	mu               sync.Mutex
+	lexicalSearches  int
+	semanticSearches []semanticSearch
+}
+
+// semanticSearch is the arguments of one semantic search
+type semanticSearch struct {
+	repoID        string
+	limit, offset int
+	minCertainty  float32
+}
+
+func (c *fixedSearchClient) SearchLexical(ctx context.Context, repoID, queryText string, limit int, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	c.mu.Lock()
+	c.lexicalSearches++
+	c.mu.Unlock()
+	return c.results(), nil
+}
+
+func (c *fixedSearchClient) ReadFile(ctx context.Context, repoID, path string, startLine, endLine int) (*repocontextv1.CodeChunk, error) {
+	return nil, fmt.Errorf("not found")
+}
+
+func (c *fixedSearchClient) SearchSemantic(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	c.mu.Lock()
+	c.semanticSearches = append(c.semanticSearches, semanticSearch{repoID: repoID, limit: limit, offset: offset, minCertainty: minCertainty})
+	c.mu.Unlock()
+	if c.semanticErr != nil {
+		return nil, c.semanticErr
+	}
+	return c.results(), nil
+}
+
+func (c *fixedSearchClient) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+func (c *fixedSearchClient) results() []*repocontextv1.CodeChunk {
+	results := make([]*repocontextv1.CodeChunk, len(c.chunks))
+	for i, chunk := range c.chunks {
+		results[i] = proto.Clone(chunk).(*repocontextv1.CodeChunk)
+	}
+	return results
+}
+
+// scriptedComposer answers with tokens, streamed one at a time, and records the
+// history of every composition
+type scriptedComposer struct {
+	tokens []string
+
+	mu        sync.Mutex
+	histories [][]composer.Turn
+}
+
+func (c *scriptedComposer) ComposeAnswer(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []composer.Turn) (*composer.CompositionResult, error) {
+	return c.ComposeAnswerStream(ctx, query, chunks, history, func(string) error { return nil })
+}
+
+func (c *scriptedComposer) ComposeAnswerStream(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []composer.Turn, callback func(string) error) (*composer.CompositionResult, error) {
+	c.mu.Lock()
+	c.histories = append(c.histories, append([]composer.Turn(nil), history...))
+	c.mu.Unlock()
+
+	for _, token := range c.tokens {
+		if err := callback(token); err != nil {
+			return nil, err
+		}
+	}
+	return &composer.CompositionResult{
+		FullResponse:     strings.Join(c.tokens, ""),
+		PromptTokens:     100,
+		CompletionTokens: len(c.tokens),
+		TokenCount:       100 + len(c.tokens),
+	}, nil
+}
+
+// newTestChatServer returns a chat server searching with search and answering with
+// comp, for the default tenant's ready repository repo-1
+func newTestChatServer(t *testing.T, search *fixedSearchClient, comp composer.Composer) (*ChatServer, *cache.RedisCache) {
+	t.Helper()
+	cfg := newTestConfig(t)
+	cfg.Defaults.MaxSearchResults = 50
+	cfg.Defaults.MaxSearchRepositories = 10
+	cfg.Defaults.SearchConcurrency = 2
+	redisCache := newTestCache(t)
+	if err := redisCache.SetRepositoryMetadata(context.Background(), "default", &repocontextv1.Repository{
+		RepositoryId:    "repo-1",
+		Name:            "widgets",
+		IngestionStatus: &repocontextv1.IngestionStatus{State: repocontextv1.IngestionStatus_STATE_READY},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	queryService := &QueryService{lexicalClient: search, semanticClient: search, merger: query.NewResultMerger(10, config.MergeConfig{})}
+	return NewChatServer(cfg, redisCache, queryService, comp, &gatedEmbeddings{}, observability.NewMetrics(), observability.NewNoOpTracer()), redisCache
+}
+
+// probeSemanticClient blocks each search until release is closed
+type probeSemanticClient struct {
+	mu       sync.Mutex
+	searches int
+	release  chan struct{}
+	done     chan struct{}
+}
+
+func (c *probeSemanticClient) SearchSemantic(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	c.mu.Lock()
+	c.searches++
+	c.mu.Unlock()
+	defer func() { c.done <- struct{}{} }()
+
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return []*repocontextv1.CodeChunk{{Score: 0.4}}, nil
+}
+
+func (c *probeSemanticClient) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+func TestLogThresholdMissRunsInBackgroundOncePerInterval(t *testing.T) {
+	semantic := &probeSemanticClient{release: make(chan struct{}), done: make(chan struct{}, 4)}
+	s := &ChatServer{queryService: &QueryService{semanticClient: semantic}}
+
+	// A cancelled request doesn't cancel the probe, and the caller doesn't wait for it
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan struct{})
+	go func() {
+		s.logThresholdMiss(ctx, "repo-1", []float32{1, 0}, 0.7, nil)
+		s.logThresholdMiss(ctx, "repo-1", []float32{1, 0}, 0.7, nil)
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("logThresholdMiss blocked on the probe search")
+	}
+	cancel()
+	close(semantic.release)
+
+	select {
+	case <-semantic.done:
+	case <-time.After(time.Second):
+		t.Fatal("probe search never finished")
+	}
+
+	semantic.mu.Lock()
+	defer semantic.mu.Unlock()
+	if semantic.searches != 1 {
+		t.Errorf("probe searches = %d, want 1 within the interval", semantic.searches)
+	}
+}
+
+// hybridSemanticClient answers hybrid searches, or fails them with hybridErr
+type hybridSemanticClient struct {
+	hybridErr      error
+	hybridSearches int
+}
+
+func (c *hybridSemanticClient) SearchSemantic(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	return nil, c.hybridErr
+}
+
+func (c *hybridSemanticClient) SearchHybrid(ctx context.Context, repoID, queryText string, queryVector []float32, limit, offset int, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	c.hybridSearches++
+	if c.hybridErr != nil {
+		return nil, c.hybridErr
+	}
+	return []*repocontextv1.CodeChunk{{FilePath: "main.go", Score: 0.9}}, nil
+}
+
+func (c *hybridSemanticClient) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+// countingLexicalClient counts lexical searches
+type countingLexicalClient struct {
+	searches int
+}
+
+func (c *countingLexicalClient) SearchLexical(ctx context.Context, repoID, queryText string, limit int, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	c.searches++
+	return []*repocontextv1.CodeChunk{{FilePath: "util.go"}}, nil
+}
+
+func (c *countingLexicalClient) ReadFile(ctx context.Context, repoID, path string, startLine, endLine int) (*repocontextv1.CodeChunk, error) {
+	return nil, fmt.Errorf("not found")
+}
+
+func (c *countingLexicalClient) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+func TestSearchRepositoryHybrid(t *testing.T) {
+	tests := []struct {
+		name             string
+		hybridSearch     bool
+		mode             repocontextv1.SearchMode
+		hybridErr        error
+		wantHybrid       int
+		wantLexical      int
+		wantSemanticHits int
+	}{
+		{"disabled", false, repocontextv1.SearchMode_SEARCH_MODE_BOTH, nil, 0, 1, 0},
+		{"both mode runs one hybrid query", true, repocontextv1.SearchMode_SEARCH_MODE_BOTH, nil, 1, 0, 1},
+		{"lexical mode stays on ripgrep", true, repocontextv1.SearchMode_SEARCH_MODE_LEXICAL, nil, 0, 1, 0},
+		{"unindexed repository falls back to ripgrep", true, repocontextv1.SearchMode_SEARCH_MODE_BOTH, fmt.Errorf("%w: test", query.ErrCollectionNotFound), 1, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			semantic := &hybridSemanticClient{hybridErr: tt.hybridErr}
+			lexical := &countingLexicalClient{}
+			cfg := &config.Config{}
+			cfg.Weaviate.HybridSearch = tt.hybridSearch
+			s := &ChatServer{
+				config:       cfg,
+				queryService: &QueryService{lexicalClient: lexical, semanticClient: semantic, merger: query.NewResultMerger(10, config.MergeConfig{})},
+			}
+
+			results, err := s.searchRepository(context.Background(), "repo-1", "parse config", []float32{1, 0}, 10, 0, 0, tt.mode, nil, 0)
+			if err != nil {
+				t.Fatalf("searchRepository error = %v", err)
+			}
+			if semantic.hybridSearches != tt.wantHybrid {
+				t.Errorf("hybrid searches = %d, want %d", semantic.hybridSearches, tt.wantHybrid)
+			}
+			if lexical.searches != tt.wantLexical {
+				t.Errorf("lexical searches = %d, want %d", lexical.searches, tt.wantLexical)
+			}
+			if len(results.SemanticChunks) != tt.wantSemanticHits {
+				t.Errorf("semantic chunks = %d, want %d", len(results.SemanticChunks), tt.wantSemanticHits)
+			}
+		})
+	}
+}
+
+func TestSweepSessions(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.ChatSessionTTL = time.Hour
+	cfg.Server.ChatSessionSweepInterval = 5 * time.Millisecond
+	s := &ChatServer{config: cfg, metrics: observability.NewMetrics(), sessions: make(map[string]*ChatSession)}
+
+	staleCtx, staleCancel := context.WithCancel(context.Background())
+	s.sessions["stale"] = &ChatSession{ID: "stale", CreatedAt: time.Now().Add(-2 * time.Hour), CancelFunc: staleCancel, Active: true}
+	s.sessions["fresh"] = &ChatSession{ID: "fresh", CreatedAt: time.Now(), CancelFunc: func() {}, Active: true}
+	// Created long ago, but still getting messages
+	activeCtx, activeCancel := context.WithCancel(context.Background())
+	defer activeCancel()
+	active := &ChatSession{ID: "active", CreatedAt: time.Now().Add(-2 * time.Hour), CancelFunc: activeCancel, Active: true}
+	active.touch(time.Now())
+	s.sessions["active"] = active
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.SweepSessions(ctx)
+		close(stopped)
+	}()
+
+	select {
+	case <-staleCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("stale session was never cancelled")
+	}
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("SweepSessions kept running after its context was cancelled")
+	}
+
+	s.sessionsMutex.RLock()
+	defer s.sessionsMutex.RUnlock()
+	if _, ok := s.sessions["stale"]; ok {
+		t.Error("stale session not evicted")
+	}
+	if _, ok := s.sessions["fresh"]; !ok {
+		t.Error("fresh session evicted")
+	}
+	if _, ok := s.sessions["active"]; !ok || activeCtx.Err() != nil || !active.Active {
+		t.Error("long-lived session still getting messages was evicted")
+	}
+}
+
+// recordingSender collects the responses a chat turn sends
+type recordingSender struct {
+	responses []*repocontextv1.ChatResponse
+}
+
+func (r *recordingSender) Send(response *repocontextv1.ChatResponse) error {
+	r.responses = append(r.responses, response)
+	return nil
+}
+
+func TestSendNoResultsAnswer(t *testing.T) {
+	tests := []struct {
+		name         string
+		streamTokens bool
+		want         []string
+	}{
+		{"whole answer", false, []string{"composition_complete", "complete"}},
+		{"streamed tokens", true, []string{"composition_token", "composition_complete", "complete"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Composer.NoResultsMessage = "Nothing in this repository matches."
+			s := &ChatServer{config: cfg}
+			session := &ChatSession{ID: "session-1", Options: &repocontextv1.ChatOptions{StreamTokens: tt.streamTokens}}
+			message := &repocontextv1.ChatMessage{Query: "where is the retry policy?"}
+			merged := &query.MergedResults{Timings: &repocontextv1.SearchTimings{}, Stats: &repocontextv1.SearchStats{}}
+
+			stream := &recordingSender{}
+			if err := s.sendNoResultsAnswer(stream, session, message, "query-1", merged); err != nil {
+				t.Fatalf("sendNoResultsAnswer error = %v", err)
+			}
+
+			var got []string
+			for _, response := range stream.responses {
+				switch m := response.Message.(type) {
+				case *repocontextv1.ChatResponse_CompositionToken:
+					got = append(got, "composition_token")
+					if m.CompositionToken.Text != cfg.Composer.NoResultsMessage {
+						t.Errorf("token text = %q", m.CompositionToken.Text)
+					}
+				case *repocontextv1.ChatResponse_CompositionComplete:
+					got = append(got, "composition_complete")
+					if m.CompositionComplete.FullResponse != cfg.Composer.NoResultsMessage {
+						t.Errorf("full response = %q", m.CompositionComplete.FullResponse)
+					}
+					if m.CompositionComplete.Usage.GetTotalTokens() != 0 {
+						t.Errorf("no-results answer reports %d tokens used", m.CompositionComplete.Usage.GetTotalTokens())
+					}
+				case *repocontextv1.ChatResponse_Complete:
+					got = append(got, "complete")
+					if m.Complete.QueryId != "query-1" || m.Complete.Stats != merged.Stats {
+						t.Errorf("complete = %+v", m.Complete)
+					}
+				default:
+					got = append(got, fmt.Sprintf("%T", m))
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("responses = %v, want %v", got, tt.want)
+			}
+
+			if len(session.history) != 1 || session.history[0].Answer != cfg.Composer.NoResultsMessage {
+				t.Errorf("history = %+v, want the question answered with the no-results message", session.history)
+			}
+		})
+	}
+}
+
+// filterRecordingClient is a lexical and semantic client recording the filters of
+// each search
+type filterRecordingClient struct {
+	lexicalFilters  map[string]interface{}
+	semanticFilters map[string]interface{}
+}
+
+func (c *filterRecordingClient) SearchLexical(ctx context.Context, repoID, queryText string, limit int, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	c.lexicalFilters = filters
+	return nil, nil
+}
+
+func (c *filterRecordingClient) ReadFile(ctx context.Context, repoID, path string, startLine, endLine int) (*repocontextv1.CodeChunk, error) {
+	return nil, fmt.Errorf("not found")
+}
+
+func (c *filterRecordingClient) SearchSemantic(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	c.semanticFilters = filters
+	return nil, nil
+}
+
+func (c *filterRecordingClient) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+func TestChatFiltersReachBothBackends(t *testing.T) {
+	sessionFilters := &repocontextv1.SearchFilters{Languages: []string{"python"}}
+	messageFilter := &repocontextv1.SearchFilters{
+		Languages:    []string{"go"},
+		FilePatterns: []string{"*.go", "!*_test.go"},
+		PathPrefix:   "internal/api/",
+	}
+
+	tests := []struct {
+		name    string
+		message *repocontextv1.ChatMessage
+		want    map[string]interface{}
+	}{
+		{
+			"session filters",
+			&repocontextv1.ChatMessage{Query: "auth"},
+			map[string]interface{}{"languages": []string{"python"}},
+		},
+		{
+			"message filters replace the session's",
+			&repocontextv1.ChatMessage{Query: "auth", Filters: messageFilter},
+			map[string]interface{}{"languages": []string{"go"}, "file_patterns": []string{"*.go", "!*_test.go"}, "path_prefix": "internal/api/"},
+		},
+		{
+			"with lexical options",
+			&repocontextv1.ChatMessage{Query: "auth", Filters: &repocontextv1.SearchFilters{PathPrefix: "cmd/"}, LexicalOptions: &repocontextv1.LexicalOptions{WholeWord: true}},
+			map[string]interface{}{"path_prefix": "cmd/", "regex": false, "case_sensitive": false, "whole_word": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &filterRecordingClient{}
+			s := &ChatServer{
+				config:       &config.Config{},
+				queryService: &QueryService{lexicalClient: client, semanticClient: client, merger: query.NewResultMerger(10, config.MergeConfig{})},
+			}
+			session := &ChatSession{Options: &repocontextv1.ChatOptions{Filters: sessionFilters}}
+
+			filters := withSearchFilters(lexicalFilters(tt.message.LexicalOptions), messageFilters(session, tt.message))
+			if _, err := s.searchRepository(context.Background(), "repo-1", tt.message.Query, []float32{1, 0}, 10, 0, 0, repocontextv1.SearchMode_SEARCH_MODE_BOTH, filters, 0); err != nil {
+				t.Fatalf("searchRepository error = %v", err)
+			}
+
+			for backend, got := range map[string]map[string]interface{}{"lexical": client.lexicalFilters, "semantic": client.semanticFilters} {
+				if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+					t.Errorf("%s filters = %v, want %v", backend, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSearchFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters *repocontextv1.SearchFilters
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"globs", &repocontextv1.SearchFilters{FilePatterns: []string{"*.go", "!vendor/*"}}, false},
+		{"malformed glob", &repocontextv1.SearchFilters{FilePatterns: []string{"[*.go"}}, true},
+		{"malformed exclusion", &repocontextv1.SearchFilters{FilePatterns: []string{"!src/[a-"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateSearchFilters(tt.filters); (err != nil) != tt.wantErr {
+				t.Errorf("validateSearchFilters error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSearchMinCertainty(t *testing.T) {
+	search := &fixedSearchClient{chunks: []*repocontextv1.CodeChunk{{FilePath: "main.go", Score: 0.9}}}
+	s, _ := newTestChatServer(t, search, &scriptedComposer{})
+	s.config.Defaults.SemanticMinCertainty = 0.7
+	override := float32(0.25)
+
+	tests := []struct {
+		name         string
+		minCertainty *float32
+		want         float32
+	}{
+		{"configured default", nil, 0.7},
+		{"request override", &override, 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search.semanticSearches = nil
+			if _, err := s.Search(context.Background(), &repocontextv1.SearchRequest{Query: "widgets", RepositoryIds: []string{"repo-1"}, MinCertainty: tt.minCertainty}); err != nil {
+				t.Fatalf("Search error = %v", err)
+			}
+			if len(search.semanticSearches) != 1 || search.semanticSearches[0].minCertainty != tt.want {
+				t.Errorf("semantic searches = %+v, want one with certainty %v", search.semanticSearches, tt.want)
+			}
+		})
+	}
+
+	// Chat searches use the configured certainty too
+	search.semanticSearches = nil
+	if _, err := s.performSearch(context.Background(), "repo-1", "widgets", 10, repocontextv1.SearchMode_SEARCH_MODE_BOTH, nil, 0); err != nil {
+		t.Fatalf("performSearch error = %v", err)
+	}
+	if len(search.semanticSearches) != 1 || search.semanticSearches[0].minCertainty != 0.7 {
+		t.Errorf("chat semantic searches = %+v, want one with certainty 0.7", search.semanticSearches)
+	}
+}
+
+func TestChatMessageKeepsSessionAlive(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestChatServer(t, &fixedSearchClient{}, &scriptedComposer{})
+	session, err := s.handleChatStart(ctx, &repocontextv1.ChatStart{RepositoryId: "repo-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.CreatedAt = time.Now().Add(-2 * time.Hour)
+
+	if err := s.handleChatMessage(session.ctx, &recordingSender{}, session, &repocontextv1.ChatMessage{Query: "widgets"}); err != nil {
+		t.Fatalf("handleChatMessage error = %v", err)
+	}
+	if evicted := s.evictExpiredSessions(time.Now(), time.Hour); evicted != 0 {
+		t.Errorf("evicted %d sessions, want the one that just got a message kept", evicted)
+	}
+	if evicted := s.evictExpiredSessions(time.Now().Add(2*time.Hour), time.Hour); evicted != 1 {
+		t.Errorf("evicted %d sessions once idle past the TTL, want 1", evicted)
+	}
+}
+
+// chatStream is a ChatWithRepository stream fed from requests. Recv blocks until a
+// request arrives or ctx is done.
+type chatStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	requests chan *repocontextv1.ChatRequest
+
+	mu        sync.Mutex
+	responses []*repocontextv1.ChatResponse
+}
+
+func (s *chatStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *chatStream) Recv() (*repocontextv1.ChatRequest, error) {
+	select {
+	case req := <-s.requests:
+		return req, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+func (s *chatStream) Send(response *repocontextv1.ChatResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, response)
+	return nil
+}
+
+// blockingComposer blocks every composition until its context is done, reporting
+// the context's error on done
+type blockingComposer struct {
+	started chan struct{}
+	done    chan error
+}
+
+func (c *blockingComposer) ComposeAnswer(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []composer.Turn) (*composer.CompositionResult, error) {
+	return c.ComposeAnswerStream(ctx, query, chunks, history, func(string) error { return nil })
+}
+
+func (c *blockingComposer) ComposeAnswerStream(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []composer.Turn, callback func(string) error) (*composer.CompositionResult, error) {
+	close(c.started)
+	<-ctx.Done()
+	c.done <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func TestChatCancelReachesComposer(t *testing.T) {
+	search := &fixedSearchClient{chunks: []*repocontextv1.CodeChunk{{FilePath: "widget.go", StartLine: 1, EndLine: 5, Content: "type Widget struct{}"}}}
+	comp := &blockingComposer{started: make(chan struct{}), done: make(chan error, 1)}
+	s, _ := newTestChatServer(t, search, comp)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &chatStream{ctx: ctx, requests: make(chan *repocontextv1.ChatRequest)}
+
+	returned := make(chan error, 1)
+	go func() { returned <- s.ChatWithRepository(stream) }()
+	stream.requests <- &repocontextv1.ChatRequest{Message: &repocontextv1.ChatRequest_Start{Start: &repocontextv1.ChatStart{RepositoryId: "repo-1"}}}
+	stream.requests <- &repocontextv1.ChatRequest{Message: &repocontextv1.ChatRequest_ChatMessage{ChatMessage: &repocontextv1.ChatMessage{Query: "widgets"}}}
+	select {
+	case <-comp.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("composition never started")
+	}
+	stream.requests <- &repocontextv1.ChatRequest{Message: &repocontextv1.ChatRequest_Cancel{Cancel: &repocontextv1.ChatCancel{}}}
+
+	select {
+	case err := <-comp.done:
+		if err != context.Canceled {
+			t.Errorf("composer context ended with %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancel did not reach the composer's context")
+	}
+	select {
+	case err := <-returned:
+		if err != nil {
+			t.Errorf("ChatWithRepository error = %v, want nil after a cancel", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ChatWithRepository did not return after the cancel")
+	}
+}
+
+func TestChatHistoryReachesNextComposition(t *testing.T) {
+	search := &fixedSearchClient{chunks: []*repocontextv1.CodeChunk{{FilePath: "widget.go", StartLine: 1, EndLine: 5, Content: "type Widget struct{}"}}}
+	comp := &scriptedComposer{tokens: []string{"Widgets ", "are structs."}}
+	s, _ := newTestChatServer(t, search, comp)
+	session, err := s.handleChatStart(context.Background(), &repocontextv1.ChatStart{RepositoryId: "repo-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []string{"What is a widget?", "Where is it defined?"} {
+		if err := s.handleChatMessage(session.ctx, &recordingSender{}, session, &repocontextv1.ChatMessage{Query: q}); err != nil {
+			t.Fatalf("handleChatMessage(%q) error = %v", q, err)
+		}
+	}
+
+	if len(comp.histories) != 2 {
+		t.Fatalf("composer called %d times, want 2", len(comp.histories))
+	}
+	if len(comp.histories[0]) != 0 {
+		t.Errorf("first composition got history %v, want none", comp.histories[0])
+	}
+	want := composer.Turn{Query: "What is a widget?", Answer: "Widgets are structs."}
+	if len(comp.histories[1]) != 1 || comp.histories[1][0] != want {
+		t.Errorf("second composition got history %v, want the first exchange %v", comp.histories[1], want)
+	}
+}
+
+func TestChatReportsTokenUsage(t *testing.T) {
+	search := &fixedSearchClient{chunks: []*repocontextv1.CodeChunk{{FilePath: "widget.go", StartLine: 1, EndLine: 5, Content: "type Widget struct{}"}}}
+	// The scripted composer reports 100 prompt tokens and a completion token per token
+	want := &repocontextv1.TokenUsage{PromptTokens: 100, CompletionTokens: 2, TotalTokens: 102}
+
+	for _, streamTokens := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream tokens %v", streamTokens), func(t *testing.T) {
+			s, _ := newTestChatServer(t, search, &scriptedComposer{tokens: []string{"Widgets ", "are structs."}})
+			session, err := s.handleChatStart(context.Background(), &repocontextv1.ChatStart{RepositoryId: "repo-1", Options: &repocontextv1.ChatOptions{StreamTokens: streamTokens}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			stream := &recordingSender{}
+			if err := s.handleChatMessage(session.ctx, stream, session, &repocontextv1.ChatMessage{Query: "What is a widget?"}); err != nil {
+				t.Fatalf("handleChatMessage error = %v", err)
+			}
+
+			var completions int
+			for _, response := range stream.responses {
+				var usage *repocontextv1.TokenUsage
+				switch m := response.Message.(type) {
+				case *repocontextv1.ChatResponse_CompositionComplete:
+					usage = m.CompositionComplete.Usage
+				case *repocontextv1.ChatResponse_Complete:
+					usage = m.Complete.Usage
+				default:
+					continue
+				}
+				completions++
+				if !proto.Equal(usage, want) {
+					t.Errorf("%T reports usage %v, want %v", response.Message, usage, want)
+				}
+			}
+			if completions != 2 {
+				t.Errorf("sent %d completion messages, want composition_complete and complete", completions)
+			}
+		})
+	}
+}
+
+// emptyEmbeddings embeds every text as an empty vector
+type emptyEmbeddings struct {
+	gatedEmbeddings
+}
+
+func (e *emptyEmbeddings) GenerateEmbeddings(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	return make([][]float32, len(texts)), nil
+}
+
+// counterValue reads the value of a registered counter with the given labels
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestEmptyQueryEmbeddingDegradesToLexical(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    repocontextv1.SearchMode
+		wantErr bool
+	}{
+		{"combined search falls back to lexical", repocontextv1.SearchMode_SEARCH_MODE_UNSPECIFIED, false},
+		{"semantic-only search fails", repocontextv1.SearchMode_SEARCH_MODE_SEMANTIC, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := &fixedSearchClient{chunks: []*repocontextv1.CodeChunk{{FilePath: "widget.go", StartLine: 1, EndLine: 5, Content: "type Widget struct{}"}}}
+			s, _ := newTestChatServer(t, search, &scriptedComposer{})
+			s.embeddingClient = &emptyEmbeddings{}
+			failures := counterValue(t, "query_embedding_failures_total", map[string]string{"reason": "empty"})
+
+			merged, err := s.performSearch(context.Background(), "repo-1", "widgets", 10, tt.mode, nil, 0)
+
+			if got := counterValue(t, "query_embedding_failures_total", map[string]string{"reason": "empty"}); got != failures+1 {
+				t.Errorf("empty embedding failures went from %v to %v, want one more", failures, got)
+			}
+			if len(search.semanticSearches) != 0 {
+				t.Errorf("ran %d semantic searches without a query vector", len(search.semanticSearches))
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Error("semantic-only search succeeded without a query vector")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("performSearch error = %v, want lexical-only results", err)
+			}
+			if search.lexicalSearches != 1 || len(merged.Chunks) != 1 || merged.Chunks[0].FilePath != "widget.go" {
+				t.Errorf("got %d lexical searches and chunks %v, want the lexical hit", search.lexicalSearches, merged.Chunks)
+			}
+		})
+	}
+}
+
+func TestSearchModesSelectBackends(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         repocontextv1.SearchMode
+		wantLexical  int
+		wantSemantic int
+	}{
+		{"unspecified searches both", repocontextv1.SearchMode_SEARCH_MODE_UNSPECIFIED, 1, 1},
+		{"both", repocontextv1.SearchMode_SEARCH_MODE_BOTH, 1, 1},
+		{"lexical only", repocontextv1.SearchMode_SEARCH_MODE_LEXICAL, 1, 0},
+		{"semantic only", repocontextv1.SearchMode_SEARCH_MODE_SEMANTIC, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := &fixedSearchClient{chunks: []*repocontextv1.CodeChunk{{FilePath: "widget.go", StartLine: 1, EndLine: 5, Content: "type Widget struct{}", Score: 0.8}}}
+			s, _ := newTestChatServer(t, search, &scriptedComposer{})
+			embeddings := &gatedEmbeddings{}
+			s.embeddingClient = embeddings
+
+			merged, err := s.performSearch(context.Background(), "repo-1", "widgets", 10, tt.mode, nil, 0)
+			if err != nil {
+				t.Fatalf("performSearch error = %v", err)
+			}
+
+			if search.lexicalSearches != tt.wantLexical || len(search.semanticSearches) != tt.wantSemantic {
+				t.Errorf("ran %d lexical and %d semantic searches, want %d and %d", search.lexicalSearches, len(search.semanticSearches), tt.wantLexical, tt.wantSemantic)
+			}
+			// Only a semantic search needs the query embedded
+			if embeddings.calls != tt.wantSemantic {
+				t.Errorf("embedded the query %d times, want %d", embeddings.calls, tt.wantSemantic)
+			}
+			if len(merged.Chunks) != 1 || merged.Chunks[0].FilePath != "widget.go" {
+				t.Errorf("merged chunks = %v, want the single hit", merged.Chunks)
+			}
+		})
+	}
+}
+
+func TestSearchMissingCollection(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     repocontextv1.SearchMode
+		wantCode codes.Code
+	}{
+		{"semantic only is not ready", repocontextv1.SearchMode_SEARCH_MODE_SEMANTIC, codes.FailedPrecondition},
+		{"both falls back to lexical", repocontextv1.SearchMode_SEARCH_MODE_BOTH, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := &fixedSearchClient{
+				chunks:      []*repocontextv1.CodeChunk{{FilePath: "widget.go", StartLine: 1, EndLine: 5, Content: "type Widget struct{}", Score: 0.8}},
+				semanticErr: fmt.Errorf("%w: repository repo-1 has not been indexed", query.ErrCollectionNotFound),
+			}
+			s, _ := newTestChatServer(t, search, &scriptedComposer{})
+			s.embeddingClient = &gatedEmbeddings{}
+
+			merged, err := s.performSearch(context.Background(), "repo-1", "widgets", 10, tt.mode, nil, 0)
+			if err != nil {
+				err = searchStatus(err)
+			}
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("search error = %v, want %v", err, tt.wantCode)
+			}
+			if err != nil {
+				if !strings.Contains(status.Convert(err).Message(), "repository not ready") {
+					t.Errorf("error message %q doesn't say the repository isn't ready", status.Convert(err).Message())
+				}
+				return
+			}
+			if len(merged.Chunks) != 1 || merged.Chunks[0].FilePath != "widget.go" {
+				t.Errorf("merged chunks = %v, want the lexical hit", merged.Chunks)
+			}
+		})
+	}
+}
+
+// slowSearchClient delays each search of a fixedSearchClient
+type slowSearchClient struct {
+	*fixedSearchClient
+	lexicalDelay, semanticDelay time.Duration
+}
+
+func (c *slowSearchClient) SearchLexical(ctx context.Context, repoID, queryText string, limit int, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	time.Sleep(c.lexicalDelay)
+	return c.fixedSearchClient.SearchLexical(ctx, repoID, queryText, limit, filters)
+}
+
+func (c *slowSearchClient) SearchSemantic(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	time.Sleep(c.semanticDelay)
+	return c.fixedSearchClient.SearchSemantic(ctx, repoID, queryVector, limit, offset, minCertainty, filters)
+}
+
+// slowComposer delays each composition of a scriptedComposer
+type slowComposer struct {
+	*scriptedComposer
+	delay time.Duration
+}
+
+func (c *slowComposer) ComposeAnswer(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []composer.Turn) (*composer.CompositionResult, error) {
+	time.Sleep(c.delay)
+	return c.scriptedComposer.ComposeAnswer(ctx, query, chunks, history)
+}
+
+func TestChatTimingsReflectBackendCalls(t *testing.T) {
+	search := &slowSearchClient{
+		fixedSearchClient: &fixedSearchClient{chunks: []*repocontextv1.CodeChunk{{FilePath: "widget.go", StartLine: 1, EndLine: 5, Content: "type Widget struct{}"}}},
+		lexicalDelay:      30 * time.Millisecond,
+		semanticDelay:     60 * time.Millisecond,
+	}
+	s, _ := newTestChatServer(t, search.fixedSearchClient, &slowComposer{scriptedComposer: &scriptedComposer{tokens: []string{"ok"}}, delay: 90 * time.Millisecond})
+	s.queryService.lexicalClient = search
+	s.queryService.semanticClient = search
+	session, err := s.handleChatStart(context.Background(), &repocontextv1.ChatStart{RepositoryId: "repo-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &recordingSender{}
+
+	if err := s.handleChatMessage(session.ctx, sender, session, &repocontextv1.ChatMessage{Query: "widgets"}); err != nil {
+		t.Fatalf("handleChatMessage error = %v", err)
+	}
+
+	timings := sender.responses[len(sender.responses)-1].GetComplete().GetTimings()
+	if timings == nil {
+		t.Fatal("the last response carries no timings")
+	}
+	// Each timing covers its backend call and not much else
+	for _, tt := range []struct {
+		name string
+		got  int32
+		min  int32
+	}{
+		{"lexical", timings.LexicalMs, 30},
+		{"semantic", timings.SemanticMs, 60},
+		{"composition", timings.CompositionMs, 90},
+	} {
+		if tt.got < tt.min || tt.got > tt.min+500 {
+			t.Errorf("%s timing = %dms, want the %dms the call took", tt.name, tt.got, tt.min)
+		}
+	}
+}
+
+// modelEmbeddings is a gatedEmbeddings with its own default model, recording the
+// model of each call
+type modelEmbeddings struct {
+	gatedEmbeddings
+	model string
+
+	modelsMu sync.Mutex
+	models   []string
+}
+
+func (e *modelEmbeddings) GenerateEmbeddings(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	e.modelsMu.Lock()
+	e.models = append(e.models, model)
+	e.modelsMu.Unlock()
+	return e.gatedEmbeddings.GenerateEmbeddings(ctx, texts, model)
+}
+
+func (e *modelEmbeddings) GetDefaultModel() string {
+	return e.model
+}
+
+func TestQueryUsesIngestionEmbeddingModel(t *testing.T) {
+	embeddings := &modelEmbeddings{model: "custom-embedding-v2"}
+	cfg := newTestConfig(t)
+	redisCache := newTestCache(t)
+	ip := newTestInlineProcessor(t, cfg, redisCache, embeddings, newMemoryVectorStore())
+	uploads := NewUploadServer(cfg, redisCache, ip, observability.NewMetrics(), observability.NewNoOpTracer())
+	uploadArchive(t, uploads, "repo-1", map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	if status := waitForState(t, ip, "repo-1", repocontextv1.IngestionStatus_STATE_READY, repocontextv1.IngestionStatus_STATE_FAILED); status.State != repocontextv1.IngestionStatus_STATE_READY {
+		t.Fatalf("ingestion ended %v: %s", status.State, status.ErrorMessage)
+	}
+	ingested := len(embeddings.models)
+
+	s, _ := newTestChatServer(t, &fixedSearchClient{}, &scriptedComposer{})
+	s.embeddingClient = embeddings
+	if _, err := s.performSearch(context.Background(), "repo-1", "main", 10, repocontextv1.SearchMode_SEARCH_MODE_UNSPECIFIED, nil, 0); err != nil {
+		t.Fatalf("performSearch error = %v", err)
+	}
+
+	if ingested == 0 || len(embeddings.models) != ingested+1 {
+		t.Fatalf("%d embedding calls while ingesting and %d for the query, want some and 1", ingested, len(embeddings.models)-ingested)
+	}
+	for i, model := range embeddings.models {
+		if model != "custom-embedding-v2" {
+			t.Errorf("embedding call %d used %q, want the ingestion model for chunks and queries alike", i, model)
+		}
+	}
+}
@@ -2,12 +2,17 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"time"
 
 	"repo-context-service/internal/cache"
 	"repo-context-service/internal/config"
 	"repo-context-service/internal/observability"
 	"repo-context-service/internal/query"
 	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -16,17 +21,29 @@ type HealthServer struct {
 	repocontextv1.UnimplementedHealthServiceServer
 	config         *config.Config
 	cache          *cache.RedisCache
-	lexicalClient  *query.RipgrepClient
-	semanticClient *query.WeaviateClient
+	lexicalClient  query.LexicalClient
+	semanticClient query.SemanticClient
 	metrics        *observability.Metrics
 	tracer         *observability.Tracer
+
+	// LLM/embedding providers, checked only when HEALTH_CHECK_PROVIDERS is set.
+	// Either may be nil when the provider isn't in use.
+	deepSeekClient  ProviderHealthChecker
+	embeddingClient ProviderHealthChecker
+}
+
+// ProviderHealthChecker is implemented by the external API clients
+type ProviderHealthChecker interface {
+	HealthCheck(ctx context.Context) error
 }
 
 func NewHealthServer(
 	cfg *config.Config,
 	cache *cache.RedisCache,
-	lexicalClient *query.RipgrepClient,
-	semanticClient *query.WeaviateClient,
+	lexicalClient query.LexicalClient,
+	semanticClient query.SemanticClient,
+	deepSeekClient ProviderHealthChecker,
+	embeddingClient ProviderHealthChecker,
 	metrics *observability.Metrics,
 	tracer *observability.Tracer,
 ) *HealthServer {
@@ -35,6 +52,8 @@ func NewHealthServer(
 		cache:          cache,
 		lexicalClient:  lexicalClient,
 		semanticClient: semanticClient,
+		deepSeekClient: deepSeekClient,
+		embeddingClient: embeddingClient,
 		metrics:        metrics,
 		tracer:         tracer,
 	}
@@ -44,37 +63,129 @@ func (s *HealthServer) Check(ctx context.Context, req *emptypb.Empty) (*repocont
 	ctx, span := s.tracer.StartRPC(ctx, "HealthCheck")
 	defer span.End()
 
+	components, allHealthy := s.checkComponents(ctx)
+
 	response := &repocontextv1.HealthCheckResponse{
 		Status:     repocontextv1.HealthCheckResponse_SERVING_STATUS_SERVING,
-		Components: []*repocontextv1.ComponentHealth{},
+		Components: components,
+	}
+
+	if !allHealthy {
+		response.Status = repocontextv1.HealthCheckResponse_SERVING_STATUS_NOT_SERVING
 	}
 
-	// Check Redis
-	redisHealth := s.checkRedis(ctx)
-	response.Components = append(response.Components, redisHealth)
+	return response, nil
+}
 
-	// Check Weaviate
-	weaviateHealth := s.checkWeaviate(ctx)
-	response.Components = append(response.Components, weaviateHealth)
+// checkComponents checks every backend, reporting whether all of them are serving
+func (s *HealthServer) checkComponents(ctx context.Context) ([]*repocontextv1.ComponentHealth, bool) {
+	components := []*repocontextv1.ComponentHealth{
+		s.checkRedis(ctx),
+		s.checkVectorStore(ctx),
+		s.checkRipgrep(ctx),
+	}
 
-	// Check Ripgrep
-	ripgrepHealth := s.checkRipgrep(ctx)
-	response.Components = append(response.Components, ripgrepHealth)
+	// Provider checks call external APIs and may cost quota, so they're opt-in
+	if s.config.Server.ProviderHealthChecks {
+		if s.embeddingClient != nil {
+			components = append(components, s.checkEmbedding(ctx))
+		}
+		if s.deepSeekClient != nil {
+			components = append(components, s.checkDeepSeek(ctx))
+		}
+	}
 
-	// Determine overall status
 	allHealthy := true
-	for _, component := range response.Components {
+	for _, component := range components {
 		if component.Status != repocontextv1.HealthCheckResponse_SERVING_STATUS_SERVING {
 			allHealthy = false
 			break
 		}
 	}
 
-	if !allHealthy {
-		response.Status = repocontextv1.HealthCheckResponse_SERVING_STATUS_NOT_SERVING
+	return components, allHealthy
+}
+
+// CheckReadiness checks the backends the service can't serve without (Redis and the
+// vector store), reporting whether all of them are reachable
+func (s *HealthServer) CheckReadiness(ctx context.Context) ([]*repocontextv1.ComponentHealth, bool) {
+	components := []*repocontextv1.ComponentHealth{
+		s.checkRedis(ctx),
+		s.checkVectorStore(ctx),
 	}
 
-	return response, nil
+	ready := true
+	for _, component := range components {
+		if component.Status != repocontextv1.HealthCheckResponse_SERVING_STATUS_SERVING {
+			ready = false
+			break
+		}
+	}
+
+	return components, ready
+}
+
+// ReadinessHandler serves /readyz: 200 when the backends are reachable within timeout,
+// 503 otherwise, with the component results as JSON
+func (s *HealthServer) ReadinessHandler(timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		components, ready := s.CheckReadiness(ctx)
+
+		results := make([]map[string]string, 0, len(components))
+		for _, component := range components {
+			results = append(results, map[string]string{
+				"name":    component.Name,
+				"status":  component.Status.String(),
+				"message": component.Message,
+			})
+		}
+
+		response := map[string]interface{}{
+			"ready":      ready,
+			"components": results,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if ready {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// WatchServingStatus keeps the standard grpc.health.v1 status of services in sync
+// with backend health, rechecking every interval. It runs until ctx is cancelled.
+func (s *HealthServer) WatchServingStatus(ctx context.Context, grpcHealth *health.Server, interval time.Duration, services []string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		_, allHealthy := s.checkComponents(checkCtx)
+		cancel()
+
+		servingStatus := healthpb.HealthCheckResponse_SERVING
+		if !allHealthy {
+			servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+
+		// The empty service name reports the server as a whole
+		grpcHealth.SetServingStatus("", servingStatus)
+		for _, service := range services {
+			grpcHealth.SetServingStatus(service, servingStatus)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 func (s *HealthServer) Ping(ctx context.Context, req *emptypb.Empty) (*repocontextv1.PingResponse, error) {
@@ -103,9 +214,10 @@ func (s *HealthServer) checkRedis(ctx context.Context) *repocontextv1.ComponentH
 	return health
 }
 
-func (s *HealthServer) checkWeaviate(ctx context.Context) *repocontextv1.ComponentHealth {
+// checkVectorStore reports the configured vector backend under its own name
+func (s *HealthServer) checkVectorStore(ctx context.Context) *repocontextv1.ComponentHealth {
 	health := &repocontextv1.ComponentHealth{
-		Name:   "weaviate",
+		Name:   s.config.VectorStore.Backend,
 		Status: repocontextv1.HealthCheckResponse_SERVING_STATUS_SERVING,
 	}
 
@@ -113,7 +225,7 @@ func (s *HealthServer) checkWeaviate(ctx context.Context) *repocontextv1.Compone
 		health.Status = repocontextv1.HealthCheckResponse_SERVING_STATUS_NOT_SERVING
 		health.Message = err.Error()
 	} else {
-		health.Message = "Weaviate is healthy"
+		health.Message = "Vector store is healthy"
 	}
 
 	return health
@@ -129,8 +241,39 @@ func (s *HealthServer) checkRipgrep(ctx context.Context) *repocontextv1.Componen
 		health.Status = repocontextv1.HealthCheckResponse_SERVING_STATUS_NOT_SERVING
 		health.Message = err.Error()
 	} else {
-		health.Message = "Ripgrep is healthy"
+		health.Message = "Lexical search is healthy"
 	}
 
 	return health
-}
\ No newline at end of file
+}
+
+// checkEmbedding checks the embedding provider, reported as "openai" or "embedding_http"
+func (s *HealthServer) checkEmbedding(ctx context.Context) *repocontextv1.ComponentHealth {
+	if s.config.Embedding.Provider == "http" {
+		return checkProvider(ctx, "embedding_http", "Embedding endpoint", s.embeddingClient, s.config.Server.ProviderHealthTimeout)
+	}
+	return checkProvider(ctx, "openai", "OpenAI", s.embeddingClient, s.config.Server.ProviderHealthTimeout)
+}
+
+func (s *HealthServer) checkDeepSeek(ctx context.Context) *repocontextv1.ComponentHealth {
+	return checkProvider(ctx, "deepseek", "DeepSeek", s.deepSeekClient, s.config.Server.ProviderHealthTimeout)
+}
+
+func checkProvider(ctx context.Context, name, displayName string, client ProviderHealthChecker, timeout time.Duration) *repocontextv1.ComponentHealth {
+	health := &repocontextv1.ComponentHealth{
+		Name:   name,
+		Status: repocontextv1.HealthCheckResponse_SERVING_STATUS_SERVING,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := client.HealthCheck(ctx); err != nil {
+		health.Status = repocontextv1.HealthCheckResponse_SERVING_STATUS_NOT_SERVING
+		health.Message = err.Error()
+	} else {
+		health.Message = displayName + " is healthy"
+	}
+
+	return health
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// providerCheck is a provider whose health check returns err, or blocks until its
+// context is done when hang is set
+type providerCheck struct {
+	err  error
+	hang bool
+}
+
+func (p *providerCheck) HealthCheck(ctx context.Context) error {
+	if p.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return p.err
+}
+
+func TestHealthCheckProviders(t *testing.T) {
+	tests := []struct {
+		name              string
+		enabled           bool
+		embeddingProvider string
+		embedding         *providerCheck
+		deepSeek          *providerCheck
+		want              map[string]repocontextv1.HealthCheckResponse_ServingStatus
+	}{
+		{
+			name: "disabled", enabled: false, embedding: &providerCheck{err: errors.New("down")}, deepSeek: &providerCheck{err: errors.New("down")},
+			want: map[string]repocontextv1.HealthCheckResponse_ServingStatus{},
+		},
+		{
+			name: "healthy", enabled: true, embedding: &providerCheck{}, deepSeek: &providerCheck{},
+			want: map[string]repocontextv1.HealthCheckResponse_ServingStatus{
+				"openai":   repocontextv1.HealthCheckResponse_SERVING_STATUS_SERVING,
+				"deepseek": repocontextv1.HealthCheckResponse_SERVING_STATUS_SERVING,
+			},
+		},
+		{
+			name: "unhealthy", enabled: true, embedding: &providerCheck{err: errors.New("401 unauthorized")}, deepSeek: &providerCheck{},
+			want: map[string]repocontextv1.HealthCheckResponse_ServingStatus{
+				"openai":   repocontextv1.HealthCheckResponse_SERVING_STATUS_NOT_SERVING,
+				"deepseek": repocontextv1.HealthCheckResponse_SERVING_STATUS_SERVING,
+			},
+		},
+		{
+			name: "timed out", enabled: true, embeddingProvider: "http", embedding: &providerCheck{}, deepSeek: &providerCheck{hang: true},
+			want: map[string]repocontextv1.HealthCheckResponse_ServingStatus{
+				"embedding_http": repocontextv1.HealthCheckResponse_SERVING_STATUS_SERVING,
+				"deepseek":       repocontextv1.HealthCheckResponse_SERVING_STATUS_NOT_SERVING,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.VectorStore.Backend = "weaviate"
+			cfg.Embedding.Provider = tt.embeddingProvider
+			cfg.Server.ProviderHealthChecks = tt.enabled
+			cfg.Server.ProviderHealthTimeout = 50 * time.Millisecond
+			search := &fixedSearchClient{}
+			s := NewHealthServer(cfg, newTestCache(t), search, search, tt.deepSeek, tt.embedding, observability.NewMetrics(), observability.NewNoOpTracer())
+
+			resp, err := s.Check(context.Background(), &emptypb.Empty{})
+			if err != nil {
+				t.Fatalf("Check error = %v", err)
+			}
+
+			got := make(map[string]repocontextv1.HealthCheckResponse_ServingStatus)
+			for _, component := range resp.Components {
+				if component.Status != repocontextv1.HealthCheckResponse_SERVING_STATUS_SERVING && component.Message == "" {
+					t.Errorf("%s is not serving but says nothing why", component.Name)
+				}
+				switch component.Name {
+				case "redis", "weaviate", "ripgrep":
+				default:
+					got[component.Name] = component.Status
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("provider components = %v, want %v", got, tt.want)
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("%s = %v, want %v", name, got[name], want)
+				}
+			}
+			// A failing provider takes the whole service out of rotation
+			wantStatus := repocontextv1.HealthCheckResponse_SERVING_STATUS_SERVING
+			for _, status := range tt.want {
+				if status != repocontextv1.HealthCheckResponse_SERVING_STATUS_SERVING {
+					wantStatus = status
+				}
+			}
+			if resp.Status != wantStatus {
+				t.Errorf("overall status = %v, want %v", resp.Status, wantStatus)
+			}
+		})
+	}
+}
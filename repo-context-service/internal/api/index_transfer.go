@@ -0,0 +1,322 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"repo-context-service/internal/ingest"
+	"repo-context-service/internal/observability"
+	"repo-context-service/internal/query"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// indexFormatVersion is the version of the ExportIndex format this server writes
+// and ImportIndex accepts
+const indexFormatVersion = 1
+
+// importBatchSize is how many imported chunks are written to the vector store at once
+const importBatchSize = 100
+
+// ExportIndex streams a ready repository's index: a header with the repository,
+// embedding model and file hashes, then every chunk with its embedding.
+func (s *RepositoryServer) ExportIndex(req *repocontextv1.ExportIndexRequest, stream repocontextv1.RepositoryService_ExportIndexServer) error {
+	ctx, span := s.tracer.StartRPC(stream.Context(), "ExportIndex")
+	defer span.End()
+
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return err
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.TenantAttr(tenantID),
+		observability.RepositoryAttr(req.RepositoryId),
+	)
+
+	repository, err := s.cache.GetRepositoryMetadata(ctx, tenantID, req.RepositoryId)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to get repository: %v", err)
+	}
+	if repository == nil {
+		return status.Errorf(codes.NotFound, "repository not found")
+	}
+	if repository.GetIngestionStatus().GetState() != repocontextv1.IngestionStatus_STATE_READY {
+		return status.Errorf(codes.FailedPrecondition, "only ready repositories can be exported")
+	}
+
+	hashes, err := s.cache.GetFileHashes(ctx, tenantID, req.RepositoryId)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to get file hashes: %v", err)
+	}
+	files, err := s.cache.GetFileInventory(ctx, tenantID, req.RepositoryId)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to list files: %v", err)
+	}
+
+	model, dimensions := s.ingestProvider.EmbeddingSpace()
+	if err := stream.Send(&repocontextv1.IndexRecord{
+		Record: &repocontextv1.IndexRecord_Header{
+			Header: &repocontextv1.IndexHeader{
+				FormatVersion:  indexFormatVersion,
+				Repository:     repository,
+				EmbeddingModel: model,
+				Dimensions:     int32(dimensions),
+				ExportedAt:     timestamppb.Now(),
+				FileHashes:     hashes,
+				Files:          files,
+			},
+		},
+	}); err != nil {
+		return err
+	}
+
+	exported := 0
+	err = s.ingestProvider.ExportIndex(ctx, req.RepositoryId, func(chunks []*ingest.EmbeddedChunk) error {
+		for _, chunk := range chunks {
+			record := &repocontextv1.IndexedChunk{
+				FilePath:  chunk.FilePath,
+				StartLine: int32(chunk.StartLine),
+				EndLine:   int32(chunk.EndLine),
+				Content:   chunk.Content,
+				Language:  chunk.Language,
+				Symbol:    chunk.Symbol,
+				Embedding: chunk.Embedding,
+			}
+			if !chunk.CreatedAt.IsZero() {
+				record.CreatedAt = timestamppb.New(chunk.CreatedAt)
+			}
+			if err := stream.Send(&repocontextv1.IndexRecord{
+				Record: &repocontextv1.IndexRecord_Chunk{Chunk: record},
+			}); err != nil {
+				return err
+			}
+			exported++
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, query.ErrCollectionNotFound):
+		return status.Errorf(codes.NotFound, "repository has no index")
+	case err != nil && ctx.Err() != nil:
+		return err
+	case err != nil:
+		observability.Logger(stream.Context()).Error("ExportIndex: Export failed", observability.LogKeyRepositoryID, req.RepositoryId, "error", err)
+		return status.Errorf(codes.Internal, "failed to export index: %v", err)
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(exported),
+	)
+
+	return nil
+}
+
+// ImportIndex loads an index written by ExportIndex without re-embedding it. The
+// first message carries the header and, to replace an existing repository's index,
+// its ID; the rest carry chunks. Working trees aren't exported, so an imported
+// repository is searched semantically only, and GetFile needs a reindex of it.
+func (s *RepositoryServer) ImportIndex(stream repocontextv1.RepositoryService_ImportIndexServer) error {
+	ctx, span := s.tracer.StartRPC(stream.Context(), "ImportIndex")
+	defer span.End()
+
+	firstReq, err := stream.Recv()
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to receive first request: %v", err)
+	}
+
+	tenantID, err := resolveTenantID(ctx, firstReq.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return err
+	}
+
+	header := firstReq.GetRecord().GetHeader()
+	if header == nil {
+		return status.Errorf(codes.InvalidArgument, "the first message must carry the index header")
+	}
+	if header.FormatVersion != indexFormatVersion {
+		return status.Errorf(codes.InvalidArgument, "unsupported index format version %d", header.FormatVersion)
+	}
+	model, dimensions := s.ingestProvider.EmbeddingSpace()
+	if header.EmbeddingModel != model || int(header.Dimensions) != dimensions {
+		return status.Errorf(codes.FailedPrecondition, "index was embedded with %s (%d dimensions); this server uses %s (%d dimensions)",
+			header.EmbeddingModel, header.Dimensions, model, dimensions)
+	}
+
+	// Vector collections aren't tenant-scoped, so only the tenant's own repositories
+	// can be replaced; anything else is imported under a new ID
+	var existing *repocontextv1.Repository
+	repoID := firstReq.RepositoryId
+	if repoID != "" {
+		existing, err = s.cache.GetRepositoryMetadata(ctx, tenantID, repoID)
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to get repository: %v", err)
+		}
+		if existing == nil {
+			return status.Errorf(codes.NotFound, "repository not found")
+		}
+		// Stop any running ingestion so it can't overwrite the imported index
+		if _, err := s.ingestProvider.CancelIndex(ctx, tenantID, repoID); err != nil {
+			return status.Errorf(codes.Aborted, "failed to cancel running ingestion: %v", err)
+		}
+	} else {
+		repoID = generateRepositoryID()
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.TenantAttr(tenantID),
+		observability.RepositoryAttr(repoID),
+	)
+
+	done := false
+	next := func() ([]*ingest.EmbeddedChunk, error) {
+		var chunks []*ingest.EmbeddedChunk
+		for !done && len(chunks) < importBatchSize {
+			req, err := stream.Recv()
+			if err == io.EOF {
+				done = true
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			record := req.GetRecord().GetChunk()
+			if record == nil {
+				return nil, status.Errorf(codes.InvalidArgument, "only the first message may carry the index header")
+			}
+			if record.FilePath == "" {
+				return nil, status.Errorf(codes.InvalidArgument, "chunk file_path is required")
+			}
+			if len(record.Embedding) != dimensions {
+				return nil, status.Errorf(codes.InvalidArgument, "chunk of %s:%d-%d has a %d-dimensional embedding, expected %d",
+					record.FilePath, record.StartLine, record.EndLine, len(record.Embedding), dimensions)
+			}
+			chunk := &ingest.EmbeddedChunk{
+				FileChunk: &ingest.FileChunk{
+					FilePath:  record.FilePath,
+					StartLine: int(record.StartLine),
+					EndLine:   int(record.EndLine),
+					Content:   record.Content,
+					Language:  record.Language,
+					Symbol:    record.Symbol,
+				},
+				Embedding: record.Embedding,
+				Model:     model,
+				CreatedAt: record.GetCreatedAt().AsTime(),
+			}
+			if record.CreatedAt == nil {
+				chunk.CreatedAt = header.GetExportedAt().AsTime()
+			}
+			chunks = append(chunks, chunk)
+		}
+		if len(chunks) == 0 && done {
+			return nil, io.EOF
+		}
+		return chunks, nil
+	}
+
+	imported, err := s.ingestProvider.ImportIndex(ctx, tenantID, repoID, next)
+	if errors.Is(err, ingest.ErrIngestionInProgress) {
+		return status.Errorf(codes.FailedPrecondition, "repository is already being ingested")
+	}
+	if errors.Is(err, ingest.ErrIngestionQueueFull) {
+		return status.Errorf(codes.ResourceExhausted, "too many ingestions are queued; retry later")
+	}
+	if err != nil {
+		s.failImport(stream, tenantID, repoID, existing, err)
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Errorf(codes.Internal, "failed to import index: %v", err)
+	}
+
+	repository := &repocontextv1.Repository{}
+	if header.Repository != nil {
+		repository = proto.Clone(header.Repository).(*repocontextv1.Repository)
+	}
+	repository.RepositoryId = repoID
+	repository.IngestionStatus = &repocontextv1.IngestionStatus{
+		State:     repocontextv1.IngestionStatus_STATE_READY,
+		UpdatedAt: timestamppb.Now(),
+	}
+	if repository.Stats == nil {
+		repository.Stats = &repocontextv1.RepositoryStats{}
+	}
+	repository.Stats.TotalChunks = int32(imported)
+	repository.CreatedAt = timestamppb.Now()
+	repository.UpdatedAt = timestamppb.Now()
+	if existing != nil {
+		// The replaced repository keeps its name, description and creation time
+		repository.Name = existing.Name
+		repository.Description = existing.Description
+		repository.CreatedAt = existing.CreatedAt
+		if existing.Source != nil {
+			if oldKey := generateRepoKeyFromSource(existing.Source); repository.Source == nil || oldKey != generateRepoKeyFromSource(repository.Source) {
+				s.cache.DeleteRepositoryIndex(ctx, tenantID, oldKey)
+			}
+		}
+	}
+
+	if err := s.cache.SetRepositoryMetadata(ctx, tenantID, repository); err != nil {
+		return status.Errorf(codes.Internal, "failed to store repository metadata: %v", err)
+	}
+	if err := s.cache.DeleteQueryResults(ctx, tenantID, repoID); err != nil {
+		observability.Logger(ctx).Warn("ImportIndex: Failed to delete cached query results", observability.LogKeyRepositoryID, repoID, "error", err)
+	}
+	if repository.Source != nil {
+		if err := s.cache.SetRepositoryIndex(ctx, tenantID, generateRepoKeyFromSource(repository.Source), repoID); err != nil {
+			observability.Logger(ctx).Warn("ImportIndex: Failed to set repository routing", observability.LogKeyRepositoryID, repoID, "error", err)
+		}
+	}
+	// With the hashes, a reindex of the imported repository only re-embeds changed files
+	if err := s.cache.SetFileHashes(ctx, tenantID, repoID, header.FileHashes); err != nil {
+		observability.Logger(ctx).Warn("ImportIndex: Failed to store file hashes", observability.LogKeyRepositoryID, repoID, "error", err)
+	}
+	if err := s.cache.SetFileInventory(ctx, tenantID, repoID, header.Files); err != nil {
+		observability.Logger(ctx).Warn("ImportIndex: Failed to store file inventory", observability.LogKeyRepositoryID, repoID, "error", err)
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(imported),
+	)
+
+	return stream.SendAndClose(&repocontextv1.ImportIndexResponse{
+		RepositoryId:   repoID,
+		ChunksImported: int32(imported),
+		Repository:     repository,
+	})
+}
+
+// failImport cleans up after an import that stopped part way. A replaced
+// repository's index is gone by then, so it's reported as failed; a new
+// repository's partial index is deleted.
+func (s *RepositoryServer) failImport(stream repocontextv1.RepositoryService_ImportIndexServer, tenantID, repoID string, existing *repocontextv1.Repository, importErr error) {
+	// The stream's context may be what ended the import
+	ctx := context.WithoutCancel(stream.Context())
+
+	observability.Logger(ctx).Error("ImportIndex: Import failed", observability.LogKeyRepositoryID, repoID, "error", importErr)
+	if existing == nil {
+		if err := s.ingestProvider.DeleteIndex(ctx, repoID); err != nil {
+			observability.Logger(ctx).Warn("ImportIndex: Failed to delete partial index", observability.LogKeyRepositoryID, repoID, "error", err)
+		}
+		return
+	}
+
+	if _, err := s.cache.UpdateRepositoryMetadata(ctx, tenantID, repoID, func(repo *repocontextv1.Repository) {
+		repo.IngestionStatus = &repocontextv1.IngestionStatus{
+			State:        repocontextv1.IngestionStatus_STATE_FAILED,
+			UpdatedAt:    timestamppb.Now(),
+			ErrorMessage: "index import failed",
+		}
+		repo.UpdatedAt = timestamppb.Now()
+	}); err != nil {
+		observability.Logger(ctx).Warn("ImportIndex: Failed to update repository status", observability.LogKeyRepositoryID, repoID, "error", err)
+	}
+	if err := s.cache.DeleteQueryResults(ctx, tenantID, repoID); err != nil {
+		observability.Logger(ctx).Warn("ImportIndex: Failed to delete cached query results", observability.LogKeyRepositoryID, repoID, "error", err)
+	}
+}
@@ -2,15 +2,24 @@ package api
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"repo-context-service/internal/cache"
 	"repo-context-service/internal/config"
 	"repo-context-service/internal/ingest"
 	"repo-context-service/internal/observability"
+	"repo-context-service/internal/query"
 	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type RepositoryServer struct {
@@ -18,6 +27,8 @@ type RepositoryServer struct {
 	config         *config.Config
 	cache          *cache.RedisCache
 	ingestProvider ingest.Provider
+	// Reads files from the repository working copies for GetFile
+	lexicalClient  query.LexicalClient
 	metrics        *observability.Metrics
 	tracer         *observability.Tracer
 }
@@ -26,6 +37,7 @@ func NewRepositoryServer(
 	cfg *config.Config,
 	cache *cache.RedisCache,
 	ingestProvider ingest.Provider,
+	lexicalClient query.LexicalClient,
 	metrics *observability.Metrics,
 	tracer *observability.Tracer,
 ) *RepositoryServer {
@@ -33,6 +45,7 @@ func NewRepositoryServer(
 		config:         cfg,
 		cache:          cache,
 		ingestProvider: ingestProvider,
+		lexicalClient:  lexicalClient,
 		metrics:        metrics,
 		tracer:         tracer,
 	}
@@ -42,46 +55,51 @@ func (s *RepositoryServer) ListRepositories(ctx context.Context, req *repocontex
 	ctx, span := s.tracer.StartRPC(ctx, "ListRepositories")
 	defer span.End()
 
-	tenantID := req.TenantId
-	if tenantID == "" {
-		tenantID = s.config.Security.DefaultTenant
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
 	}
 
 	observability.SetSpanAttributes(span,
 		observability.TenantAttr(tenantID),
 	)
 
-	// Get repositories from cache
-	repositories, err := s.cache.ListRepositoryMetadata(ctx, tenantID)
+	pageSize, err := s.effectivePageSize(req.PageSize)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to list repositories: %v", err)
+		return nil, err
 	}
 
-	// Simple pagination - in a real system, you'd want more sophisticated pagination
-	pageSize := int(req.PageSize)
-	if pageSize <= 0 || pageSize > 100 {
-		pageSize = 20 // Default page size
+	var after string
+	if req.PageToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(req.PageToken)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid page_token")
+		}
+		after = string(decoded)
 	}
 
-	startIdx := 0
-	if req.PageToken != "" {
-		// Parse page token (simplified - in real system use encoded tokens)
-		// For now, we'll just skip this complex logic
+	// Get repositories from cache
+	repositories, err := s.cache.ListRepositoryMetadata(ctx, tenantID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list repositories: %v", err)
 	}
 
+	// Repositories are paged in ID order; the page starts after the token's ID
+	sort.Slice(repositories, func(i, j int) bool {
+		return repositories[i].RepositoryId < repositories[j].RepositoryId
+	})
+	startIdx := sort.Search(len(repositories), func(i int) bool {
+		return repositories[i].RepositoryId > after
+	})
 	endIdx := startIdx + pageSize
 	if endIdx > len(repositories) {
 		endIdx = len(repositories)
 	}
-
-	var pagedRepos []*repocontextv1.Repository
-	if startIdx < len(repositories) {
-		pagedRepos = repositories[startIdx:endIdx]
-	}
+	pagedRepos := repositories[startIdx:endIdx]
 
 	var nextPageToken string
 	if endIdx < len(repositories) {
-		nextPageToken = "next" // Simplified token
+		nextPageToken = base64.RawURLEncoding.EncodeToString([]byte(pagedRepos[len(pagedRepos)-1].RepositoryId))
 	}
 
 	observability.SetSpanAttributes(span,
@@ -91,16 +109,32 @@ func (s *RepositoryServer) ListRepositories(ctx context.Context, req *repocontex
 	return &repocontextv1.ListRepositoriesResponse{
 		Repositories:  pagedRepos,
 		NextPageToken: nextPageToken,
+		PageSize:      int32(pageSize),
 	}, nil
 }
 
+// effectivePageSize applies the configured default and upper bound to a requested page size.
+// The applied value is echoed back in the response so clients can tell it was adjusted.
+func (s *RepositoryServer) effectivePageSize(requested int32) (int, error) {
+	if requested < 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "page_size must not be negative")
+	}
+	if requested == 0 {
+		return s.config.Defaults.PageSize, nil
+	}
+	if int(requested) > s.config.Defaults.MaxPageSize {
+		return s.config.Defaults.MaxPageSize, nil
+	}
+	return int(requested), nil
+}
+
 func (s *RepositoryServer) GetRepository(ctx context.Context, req *repocontextv1.GetRepositoryRequest) (*repocontextv1.GetRepositoryResponse, error) {
 	ctx, span := s.tracer.StartRPC(ctx, "GetRepository")
 	defer span.End()
 
-	tenantID := req.TenantId
-	if tenantID == "" {
-		tenantID = s.config.Security.DefaultTenant
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
 	}
 
 	observability.SetSpanAttributes(span,
@@ -123,10 +157,23 @@ func (s *RepositoryServer) GetRepository(ctx context.Context, req *repocontextv1
 		repository.IngestionStatus.State != repocontextv1.IngestionStatus_STATE_FAILED {
 		// Check current status from ingestion provider
 		currentStatus, err := s.ingestProvider.GetIndexStatus(ctx, req.RepositoryId)
-		if err == nil && currentStatus != nil {
+		switch {
+		case err == nil && currentStatus != nil:
 			repository.IngestionStatus = currentStatus
 			// Update cache with new status
 			s.cache.SetRepositoryMetadata(ctx, tenantID, repository)
+		case errors.Is(err, ingest.ErrNoIngestion):
+			// The ingestion may be running in another process, which refreshes the
+			// record's status as a heartbeat. A stale one is reported as interrupted,
+			// but isn't stored: the record stays the ingestion's to finish.
+			lostAfter := repository.IngestionStatus.GetUpdatedAt().AsTime().Add(interruptedIngestionGrace)
+			if time.Now().After(lostAfter) {
+				repository.IngestionStatus = &repocontextv1.IngestionStatus{
+					State:        repocontextv1.IngestionStatus_STATE_FAILED,
+					UpdatedAt:    timestamppb.Now(),
+					ErrorMessage: "ingestion was interrupted",
+				}
+			}
 		}
 	}
 
@@ -135,13 +182,71 @@ func (s *RepositoryServer) GetRepository(ctx context.Context, req *repocontextv1
 	}, nil
 }
 
+// interruptedIngestionGrace is how long a record's ingestion status may go without a
+// heartbeat before the ingestion is reported as interrupted
+const interruptedIngestionGrace = 4 * ingest.IngestionHeartbeatInterval
+
+// maxRepositoryNameLength bounds user-supplied repository names
+const maxRepositoryNameLength = 256
+
+// UpdateRepository changes a repository's name and/or description. Source, stats
+// and ingestion status are owned by ingestion and can't be changed here.
+func (s *RepositoryServer) UpdateRepository(ctx context.Context, req *repocontextv1.UpdateRepositoryRequest) (*repocontextv1.UpdateRepositoryResponse, error) {
+	ctx, span := s.tracer.StartRPC(ctx, "UpdateRepository")
+	defer span.End()
+
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.TenantAttr(tenantID),
+		observability.RepositoryAttr(req.RepositoryId),
+	)
+
+	if req.Name == nil && req.Description == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "at least one of name or description is required")
+	}
+	if req.Name != nil {
+		if strings.TrimSpace(req.GetName()) == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "name must not be empty")
+		}
+		if len(req.GetName()) > maxRepositoryNameLength {
+			return nil, status.Errorf(codes.InvalidArgument, "name must be at most %d characters", maxRepositoryNameLength)
+		}
+	}
+
+	// Metadata is stored per tenant, so another tenant's repository is simply not found
+	repository, err := s.cache.UpdateRepositoryMetadata(ctx, tenantID, req.RepositoryId, func(repo *repocontextv1.Repository) {
+		if req.Name != nil {
+			repo.Name = req.GetName()
+		}
+		if req.Description != nil {
+			repo.Description = req.GetDescription()
+		}
+		repo.UpdatedAt = timestamppb.Now()
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to update repository: %v", err)
+	}
+
+	if repository == nil {
+		return nil, status.Errorf(codes.NotFound, "repository not found")
+	}
+
+	return &repocontextv1.UpdateRepositoryResponse{
+		Repository: repository,
+	}, nil
+}
+
 func (s *RepositoryServer) DeleteRepository(ctx context.Context, req *repocontextv1.DeleteRepositoryRequest) (*emptypb.Empty, error) {
 	ctx, span := s.tracer.StartRPC(ctx, "DeleteRepository")
 	defer span.End()
 
-	tenantID := req.TenantId
-	if tenantID == "" {
-		tenantID = s.config.Security.DefaultTenant
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
 	}
 
 	observability.SetSpanAttributes(span,
@@ -149,13 +254,22 @@ func (s *RepositoryServer) DeleteRepository(ctx context.Context, req *repocontex
 		observability.RepositoryAttr(req.RepositoryId),
 	)
 
-	// Get repository metadata first
+	// Get repository metadata
 	repository, err := s.cache.GetRepositoryMetadata(ctx, tenantID, req.RepositoryId)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to get repository: %v", err)
 	}
 
-	if repository == nil {
+	// Stop any running ingestion first so it can't recreate the collection after we
+	// delete it. Only the tenant's own ingestion is cancelled.
+	cancelled, err := s.ingestProvider.CancelIndex(ctx, tenantID, req.RepositoryId)
+	if err != nil {
+		return nil, status.Errorf(codes.Aborted, "failed to cancel running ingestion: %v", err)
+	}
+
+	// Uploads in progress have no metadata yet; they are still deletable by the
+	// tenant running them
+	if repository == nil && !cancelled {
 		return nil, status.Errorf(codes.NotFound, "repository not found")
 	}
 
@@ -170,16 +284,374 @@ func (s *RepositoryServer) DeleteRepository(ctx context.Context, req *repocontex
 	}
 
 	// Delete repository routing if it exists
-	if repository.Source != nil {
+	if repository != nil && repository.Source != nil {
 		repoKey := generateRepoKeyFromSource(repository.Source)
 		s.cache.DeleteRepositoryIndex(ctx, tenantID, repoKey)
 	}
 
+	if err := s.cache.DeleteQueryResults(ctx, tenantID, req.RepositoryId); err != nil {
+		observability.Logger(ctx).Warn("DeleteRepository: Failed to delete cached query results", observability.LogKeyRepositoryID, req.RepositoryId, "error", err)
+	}
+
 	return &emptypb.Empty{}, nil
 }
 
+// ReindexRepository re-ingests a repository from its stored source under the same ID.
+// Only files whose content changed since the last ingestion are re-embedded.
+func (s *RepositoryServer) ReindexRepository(ctx context.Context, req *repocontextv1.ReindexRepositoryRequest) (*repocontextv1.ReindexRepositoryResponse, error) {
+	ctx, span := s.tracer.StartRPC(ctx, "ReindexRepository")
+	defer span.End()
+
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.TenantAttr(tenantID),
+		observability.RepositoryAttr(req.RepositoryId),
+	)
+
+	repository, err := s.cache.GetRepositoryMetadata(ctx, tenantID, req.RepositoryId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get repository: %v", err)
+	}
+
+	if repository == nil {
+		return nil, status.Errorf(codes.NotFound, "repository not found")
+	}
+
+	// Only git sources can be fetched again; uploaded archives have to be re-uploaded
+	if repository.Source.GetGitUrl() == "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "only repositories ingested from git can be reindexed")
+	}
+
+	source := proto.Clone(repository.Source).(*repocontextv1.RepositorySource)
+	if req.Ref != nil {
+		if req.GetRef() == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "ref must not be empty")
+		}
+		// The stored commit belongs to the old ref
+		source.Ref = req.GetRef()
+		source.CommitSha = ""
+	}
+
+	// The repository is re-indexed with the options it was uploaded with
+	options, err := s.cache.GetUploadOptions(ctx, tenantID, req.RepositoryId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get upload options: %v", err)
+	}
+
+	uploadID := generateUploadID()
+	ingestResp, err := s.ingestProvider.CreateRepositoryIndex(ctx, &ingest.CreateIndexRequest{
+		RepositoryID:   req.RepositoryId,
+		TenantID:       tenantID,
+		Source:         source,
+		Options:        options,
+		IdempotencyKey: uploadID,
+		Incremental:    true,
+	})
+	if errors.Is(err, ingest.ErrIngestionInProgress) {
+		return nil, status.Errorf(codes.FailedPrecondition, "repository is already being ingested")
+	}
+	if errors.Is(err, ingest.ErrIngestionQueueFull) {
+		return nil, status.Errorf(codes.ResourceExhausted, "too many ingestions are queued; retry later")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to start reindex: %v", err)
+	}
+
+	return &repocontextv1.ReindexRepositoryResponse{
+		UploadId:     uploadID,
+		RepositoryId: req.RepositoryId,
+		AcceptedAt:   timestamppb.New(ingestResp.AcceptedAt),
+		Status:       ingestResp.Status,
+	}, nil
+}
+
+// GetFile returns a file, or lines start_line..end_line of it, from the repository's
+// working copy. Paths outside the repository are rejected.
+func (s *RepositoryServer) GetFile(ctx context.Context, req *repocontextv1.GetFileRequest) (*repocontextv1.GetFileResponse, error) {
+	ctx, span := s.tracer.StartRPC(ctx, "GetFile")
+	defer span.End()
+
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.TenantAttr(tenantID),
+		observability.RepositoryAttr(req.RepositoryId),
+	)
+
+	if req.FilePath == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "file_path is required")
+	}
+	if req.StartLine < 0 || req.EndLine < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "line numbers must not be negative")
+	}
+	if req.EndLine > 0 && req.StartLine > req.EndLine {
+		return nil, status.Errorf(codes.InvalidArgument, "start_line %d is after end_line %d", req.StartLine, req.EndLine)
+	}
+
+	// The working copy isn't tenant-scoped, so check the repository belongs to the tenant
+	repository, err := s.cache.GetRepositoryMetadata(ctx, tenantID, req.RepositoryId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get repository: %v", err)
+	}
+	if repository == nil {
+		return nil, status.Errorf(codes.NotFound, "repository not found")
+	}
+
+	chunk, err := s.lexicalClient.ReadFile(ctx, req.RepositoryId, req.FilePath, int(req.StartLine), int(req.EndLine))
+	switch {
+	case errors.Is(err, query.ErrInvalidFilePath):
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	case errors.Is(err, os.ErrNotExist):
+		return nil, status.Errorf(codes.NotFound, "file not found: %s", req.FilePath)
+	case errors.Is(err, query.ErrLineRangeOutOfBounds):
+		return nil, status.Errorf(codes.OutOfRange, "%v", err)
+	case errors.Is(err, query.ErrFileTooLarge):
+		return nil, status.Errorf(codes.ResourceExhausted, "%v", err)
+	case errors.Is(err, query.ErrNotebookLineRange):
+		return nil, status.Errorf(codes.FailedPrecondition, "%v; request the whole file", err)
+	case errors.Is(err, query.ErrBinaryFile):
+		return nil, status.Errorf(codes.FailedPrecondition, "%v", err)
+	case err != nil:
+		observability.Logger(ctx).Error("GetFile: Failed to read file", observability.LogKeyRepositoryID, req.RepositoryId, "path", req.FilePath, "error", err)
+		return nil, status.Errorf(codes.Internal, "failed to read file: %v", err)
+	}
+
+	return &repocontextv1.GetFileResponse{
+		RepositoryId: req.RepositoryId,
+		FilePath:     chunk.FilePath,
+		Language:     chunk.Language,
+		Content:      chunk.Content,
+		StartLine:    chunk.StartLine,
+		EndLine:      chunk.EndLine,
+	}, nil
+}
+
+// ListFiles pages through the files found when the repository was last ingested,
+// optionally only those under path_prefix. Page tokens hold the last path returned,
+// so pages stay consistent while the inventory doesn't change.
+func (s *RepositoryServer) ListFiles(ctx context.Context, req *repocontextv1.ListFilesRequest) (*repocontextv1.ListFilesResponse, error) {
+	ctx, span := s.tracer.StartRPC(ctx, "ListFiles")
+	defer span.End()
+
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.TenantAttr(tenantID),
+		observability.RepositoryAttr(req.RepositoryId),
+	)
+
+	pageSize, err := s.effectivePageSize(req.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var after string
+	if req.PageToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(req.PageToken)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid page_token")
+		}
+		after = string(decoded)
+	}
+
+	repository, err := s.cache.GetRepositoryMetadata(ctx, tenantID, req.RepositoryId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get repository: %v", err)
+	}
+	if repository == nil {
+		return nil, status.Errorf(codes.NotFound, "repository not found")
+	}
+
+	files, err := s.cache.GetFileInventory(ctx, tenantID, req.RepositoryId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list files: %v", err)
+	}
+	// Repositories ingested before the inventory was stored have none
+	if len(files) == 0 && repository.GetStats().GetTotalFiles() > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "file list not available; reindex the repository")
+	}
+
+	// A prefix names a directory (or a single file), so "internal/api" doesn't
+	// match "internal/apiserver"
+	prefix := strings.Trim(req.PathPrefix, "/")
+	var matching []*repocontextv1.FileEntry
+	for _, file := range files {
+		if prefix == "" || file.Path == prefix || strings.HasPrefix(file.Path, prefix+"/") {
+			matching = append(matching, file)
+		}
+	}
+
+	// Files are sorted by path; the page starts after the token's path
+	startIdx := sort.Search(len(matching), func(i int) bool {
+		return matching[i].Path > after
+	})
+	endIdx := startIdx + pageSize
+	if endIdx > len(matching) {
+		endIdx = len(matching)
+	}
+	page := matching[startIdx:endIdx]
+
+	var nextPageToken string
+	if endIdx < len(matching) {
+		nextPageToken = base64.RawURLEncoding.EncodeToString([]byte(page[len(page)-1].Path))
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(len(page)),
+	)
+
+	return &repocontextv1.ListFilesResponse{
+		Files:         page,
+		NextPageToken: nextPageToken,
+		PageSize:      int32(pageSize),
+		TotalFiles:    int32(len(matching)),
+	}, nil
+}
+
+// GetRepositoryStats reports a repository's stats as they are now: files, lines and
+// languages from the file list, and chunks counted in the vector store. Stats stored
+// at ingestion drift as incremental reindexes change files; RecomputeRepositoryStats
+// rescans the working tree and stores the result in their place.
+func (s *RepositoryServer) GetRepositoryStats(ctx context.Context, req *repocontextv1.GetRepositoryStatsRequest) (*repocontextv1.GetRepositoryStatsResponse, error) {
+	ctx, span := s.tracer.StartRPC(ctx, "GetRepositoryStats")
+	defer span.End()
+
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.TenantAttr(tenantID),
+		observability.RepositoryAttr(req.RepositoryId),
+	)
+
+	return s.repositoryStats(ctx, tenantID, req.RepositoryId, false)
+}
+
+// RecomputeRepositoryStats recounts a ready repository's files, lines and languages
+// from its working tree and stores them as the repository's stats. It rewrites
+// metadata and walks the whole tree, so unlike GetRepositoryStats it needs the
+// upload scope.
+func (s *RepositoryServer) RecomputeRepositoryStats(ctx context.Context, req *repocontextv1.RecomputeRepositoryStatsRequest) (*repocontextv1.GetRepositoryStatsResponse, error) {
+	ctx, span := s.tracer.StartRPC(ctx, "RecomputeRepositoryStats")
+	defer span.End()
+
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.TenantAttr(tenantID),
+		observability.RepositoryAttr(req.RepositoryId),
+	)
+
+	return s.repositoryStats(ctx, tenantID, req.RepositoryId, true)
+}
+
 // Helper functions
 
+// repositoryStats counts a repository's stats from its file list, or from its working
+// tree when recompute is set, in which case they also replace the stored stats
+func (s *RepositoryServer) repositoryStats(ctx context.Context, tenantID, repoID string, recompute bool) (*repocontextv1.GetRepositoryStatsResponse, error) {
+	repository, err := s.cache.GetRepositoryMetadata(ctx, tenantID, repoID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get repository: %v", err)
+	}
+	if repository == nil {
+		return nil, status.Errorf(codes.NotFound, "repository not found")
+	}
+	// A running ingestion is rewriting the working tree and the collection
+	if recompute && repository.GetIngestionStatus().GetState() != repocontextv1.IngestionStatus_STATE_READY {
+		return nil, status.Errorf(codes.FailedPrecondition, "only ready repositories can have their stats recomputed")
+	}
+
+	chunks, err := s.ingestProvider.CountIndexedChunks(ctx, repoID)
+	if err != nil && !errors.Is(err, query.ErrCollectionNotFound) {
+		return nil, status.Errorf(codes.Internal, "failed to count indexed chunks: %v", err)
+	}
+
+	var stats *repocontextv1.RepositoryStats
+	if recompute {
+		stats, err = s.ingestProvider.ScanRepositoryStats(ctx, repoID)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, status.Errorf(codes.FailedPrecondition, "working tree not available; reindex the repository")
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to scan repository: %v", err)
+		}
+	} else {
+		files, err := s.cache.GetFileInventory(ctx, tenantID, repoID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to list files: %v", err)
+		}
+		// Repositories ingested before the inventory was stored only have their stored stats
+		if len(files) == 0 && repository.GetStats().GetTotalFiles() > 0 {
+			stats = proto.Clone(repository.Stats).(*repocontextv1.RepositoryStats)
+		} else {
+			stats = inventoryStats(files)
+		}
+	}
+	stats.TotalChunks = int32(chunks)
+
+	if recompute {
+		updated, err := s.cache.UpdateRepositoryMetadata(ctx, tenantID, repoID, func(repo *repocontextv1.Repository) {
+			repo.Stats = proto.Clone(stats).(*repocontextv1.RepositoryStats)
+			repo.UpdatedAt = timestamppb.Now()
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to store repository stats: %v", err)
+		}
+		if updated == nil {
+			return nil, status.Errorf(codes.NotFound, "repository not found")
+		}
+	}
+
+	return &repocontextv1.GetRepositoryStatsResponse{
+		RepositoryId: repoID,
+		Stats:        stats,
+		StoredStats:  repository.Stats,
+		Recomputed:   recompute,
+	}, nil
+}
+
+// inventoryStats totals the files of a file inventory, with languages sorted by name
+func inventoryStats(files []*repocontextv1.FileEntry) *repocontextv1.RepositoryStats {
+	stats := &repocontextv1.RepositoryStats{}
+	languages := make(map[string]*repocontextv1.LanguageStats)
+	for _, file := range files {
+		stats.TotalFiles++
+		stats.TotalLines += file.LineCount
+		stats.SizeBytes += file.SizeBytes
+
+		language, exists := languages[file.Language]
+		if !exists {
+			language = &repocontextv1.LanguageStats{Language: file.Language}
+			languages[file.Language] = language
+			stats.Languages = append(stats.Languages, language)
+		}
+		language.FileCount++
+		language.LineCount += file.LineCount
+	}
+
+	sort.Slice(stats.Languages, func(i, j int) bool {
+		return stats.Languages[i].Language < stats.Languages[j].Language
+	})
+
+	return stats
+}
+
 func generateRepoKeyFromSource(source *repocontextv1.RepositorySource) string {
 	switch src := source.Source.(type) {
 	case *repocontextv1.RepositorySource_GitUrl:
@@ -0,0 +1,657 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/cache"
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/ingest"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// statusProvider reports a fixed ingestion status
+type statusProvider struct {
+	ingest.Provider
+	status *repocontextv1.IngestionStatus
+	err    error
+}
+
+func (p *statusProvider) GetIndexStatus(ctx context.Context, repoID string) (*repocontextv1.IngestionStatus, error) {
+	return p.status, p.err
+}
+
+// newTestConfig returns a config for ingesting small archives one at a time, with a
+// few more queued
+func newTestConfig(t *testing.T) *config.Config {
+	cfg := &config.Config{}
+	cfg.Security.DefaultTenant = "default"
+	cfg.Defaults.PageSize = 20
+	cfg.Defaults.MaxPageSize = 100
+	cfg.Defaults.ChunkSize = 100
+	cfg.Defaults.ChunkOverlap = 10
+	cfg.Upload = config.UploadConfig{
+		TempDir:                 t.TempDir(),
+		MaxFileSize:             1 << 20,
+		MaxFiles:                100,
+		MaxExtractedSize:        1 << 20,
+		MaxExtractedFileSize:    1 << 20,
+		AllowedTypes:            []string{".zip", ".tar.gz", ".tgz", ".tar"},
+		MaxConcurrentIngestions: 1,
+		MaxQueuedIngestions:     4,
+	}
+	return cfg
+}
+
+func newTestRepositoryServer(t *testing.T, provider ingest.Provider) (*RepositoryServer, *cache.RedisCache) {
+	t.Helper()
+	redisCache := newTestCache(t)
+	return NewRepositoryServer(newTestConfig(t), redisCache, provider, nil, observability.NewMetrics(), observability.NewNoOpTracer()), redisCache
+}
+
+// memoryVectorStore is a VectorClient holding collections in memory
+type memoryVectorStore struct {
+	mu          sync.Mutex
+	collections map[string][]*ingest.Vector
+}
+
+func newMemoryVectorStore() *memoryVectorStore {
+	return &memoryVectorStore{collections: make(map[string][]*ingest.Vector)}
+}
+
+func (m *memoryVectorStore) CreateCollection(ctx context.Context, name string, dimensions int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.collections[name]; !ok {
+		m.collections[name] = nil
+	}
+	return nil
+}
+
+func (m *memoryVectorStore) UpsertVectors(ctx context.Context, collectionName string, vectors []*ingest.Vector) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collections[collectionName] = append(m.collections[collectionName], vectors...)
+	return nil
+}
+
+func (m *memoryVectorStore) DeleteVectorsByFile(ctx context.Context, collectionName, filePath string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var kept []*ingest.Vector
+	for _, vector := range m.collections[collectionName] {
+		if vector.Metadata["file_path"] != filePath {
+			kept = append(kept, vector)
+		}
+	}
+	deleted := len(m.collections[collectionName]) - len(kept)
+	m.collections[collectionName] = kept
+	return deleted, nil
+}
+
+func (m *memoryVectorStore) DeleteCollection(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.collections, name)
+	return nil
+}
+
+func (m *memoryVectorStore) ScanVectors(ctx context.Context, collectionName string, fn func([]*ingest.Vector) error) error {
+	vectors, ok := m.collection(collectionName)
+	if !ok {
+		return errors.New("collection not found")
+	}
+	return fn(vectors)
+}
+
+func (m *memoryVectorStore) CountVectors(ctx context.Context, collectionName string) (int, error) {
+	vectors, _ := m.collection(collectionName)
+	return len(vectors), nil
+}
+
+// collection returns a copy of a collection's vectors and whether it exists
+func (m *memoryVectorStore) collection(name string) ([]*ingest.Vector, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	vectors, ok := m.collections[name]
+	return append([]*ingest.Vector(nil), vectors...), ok
+}
+
+// gatedEmbeddings embeds every text as the same 2-dimensional vector. With gate set,
+// each request waits for a value from it, or for the gate to be closed.
+type gatedEmbeddings struct {
+	gate chan struct{}
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (e *gatedEmbeddings) GenerateEmbeddings(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	e.mu.Lock()
+	e.calls++
+	e.mu.Unlock()
+
+	if e.gate != nil {
+		select {
+		case <-e.gate:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	embeddings := make([][]float32, len(texts))
+	for i := range embeddings {
+		embeddings[i] = []float32{1, 0}
+	}
+	return embeddings, nil
+}
+
+func (e *gatedEmbeddings) GetDefaultModel() string {
+	return "test-embedding"
+}
+
+func (e *gatedEmbeddings) Dimensions() int {
+	return 2
+}
+
+// newTestInlineProcessor returns an inline processor ingesting with cfg's limits,
+// reading archives from its upload directory
+func newTestInlineProcessor(t *testing.T, cfg *config.Config, redisCache *cache.RedisCache, embeddings ingest.EmbeddingClient, vectors ingest.VectorClient) *ingest.InlineProcessor {
+	return ingest.NewInlineProcessor(redisCache, observability.NewMetrics(), observability.NewNoOpTracer(),
+		slog.New(slog.NewTextHandler(io.Discard, nil)), embeddings, vectors, cfg.Upload, cfg.Defaults, t.TempDir(), cfg.Upload.TempDir)
+}
+
+// zipArchive returns a zip archive holding files
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// uploadArchive stores a zip of files as an upload would and starts ingesting it as
+// repoID, returning the archive's path
+func uploadArchive(t *testing.T, uploads *UploadServer, repoID string, files map[string]string) string {
+	t.Helper()
+	filename, err := uploads.writeUploadFile("repo.zip", repoID, bytes.NewReader(zipArchive(t, files)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &repocontextv1.RepositorySource{Source: &repocontextv1.RepositorySource_UploadedFilename{UploadedFilename: filename}, Ref: "main"}
+	if _, err := uploads.startIngestion(context.Background(), "default", repoID, "upload-"+repoID, source, nil); err != nil {
+		t.Fatalf("startIngestion error = %v", err)
+	}
+	return filepath.Join(uploads.config.Upload.TempDir, filename)
+}
+
+// waitForState polls the provider until repoID's ingestion reaches one of states
+func waitForState(t *testing.T, provider ingest.Provider, repoID string, states ...repocontextv1.IngestionStatus_State) *repocontextv1.IngestionStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := provider.GetIndexStatus(context.Background(), repoID)
+		if err == nil {
+			for _, state := range states {
+				if status.State == state {
+					return status
+				}
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s never reached %v: last status %v, %v", repoID, states, status, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGetRepositoryIngestionStatus(t *testing.T) {
+	stale := time.Now().Add(-2 * interruptedIngestionGrace)
+
+	tests := []struct {
+		name        string
+		updatedAt   time.Time
+		status      *repocontextv1.IngestionStatus
+		err         error
+		wantState   repocontextv1.IngestionStatus_State
+		wantMessage string
+		wantStored  repocontextv1.IngestionStatus_State
+	}{
+		{
+			name:       "mid-ingestion",
+			updatedAt:  time.Now(),
+			status:     &repocontextv1.IngestionStatus{State: repocontextv1.IngestionStatus_STATE_EMBEDDING},
+			wantState:  repocontextv1.IngestionStatus_STATE_EMBEDDING,
+			wantStored: repocontextv1.IngestionStatus_STATE_EMBEDDING,
+		},
+		{
+			name:        "failed job",
+			updatedAt:   time.Now(),
+			status:      &repocontextv1.IngestionStatus{State: repocontextv1.IngestionStatus_STATE_FAILED, ErrorMessage: "failed to extract repository: bad archive"},
+			wantState:   repocontextv1.IngestionStatus_STATE_FAILED,
+			wantMessage: "failed to extract repository: bad archive",
+			wantStored:  repocontextv1.IngestionStatus_STATE_FAILED,
+		},
+		{
+			name:       "running in another process",
+			updatedAt:  time.Now(),
+			err:        ingest.ErrNoIngestion,
+			wantState:  repocontextv1.IngestionStatus_STATE_PENDING,
+			wantStored: repocontextv1.IngestionStatus_STATE_PENDING,
+		},
+		{
+			name:        "heartbeat stopped",
+			updatedAt:   stale,
+			err:         ingest.ErrNoIngestion,
+			wantState:   repocontextv1.IngestionStatus_STATE_FAILED,
+			wantMessage: "ingestion was interrupted",
+			wantStored:  repocontextv1.IngestionStatus_STATE_PENDING,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, redisCache := newTestRepositoryServer(t, &statusProvider{status: tt.status, err: tt.err})
+			if err := redisCache.SetRepositoryMetadata(ctx, "default", &repocontextv1.Repository{
+				RepositoryId: "repo-1",
+				IngestionStatus: &repocontextv1.IngestionStatus{
+					State:     repocontextv1.IngestionStatus_STATE_PENDING,
+					UpdatedAt: timestamppb.New(tt.updatedAt),
+				},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := s.GetRepository(ctx, &repocontextv1.GetRepositoryRequest{RepositoryId: "repo-1"})
+			if err != nil {
+				t.Fatalf("GetRepository error = %v", err)
+			}
+			if got := resp.Repository.IngestionStatus; got.GetState() != tt.wantState || got.GetErrorMessage() != tt.wantMessage {
+				t.Errorf("status = %v %q, want %v %q", got.GetState(), got.GetErrorMessage(), tt.wantState, tt.wantMessage)
+			}
+
+			stored, err := redisCache.GetRepositoryMetadata(ctx, "default", "repo-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stored.IngestionStatus.GetState(); got != tt.wantStored {
+				t.Errorf("stored status = %v, want %v", got, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestDeleteRepositoryDuringIngestion(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig(t)
+	redisCache := newTestCache(t)
+	embeddings := &gatedEmbeddings{gate: make(chan struct{})}
+	vectors := newMemoryVectorStore()
+	ip := newTestInlineProcessor(t, cfg, redisCache, embeddings, vectors)
+	s := NewRepositoryServer(cfg, redisCache, ip, nil, observability.NewMetrics(), observability.NewNoOpTracer())
+	uploads := NewUploadServer(cfg, redisCache, ip, observability.NewMetrics(), observability.NewNoOpTracer())
+	files := map[string]string{"main.go": "package main\n\nfunc main() {}\n"}
+
+	// repo-1 holds the only worker slot, waiting on its embeddings; repo-2 is queued
+	uploadArchive(t, uploads, "repo-1", files)
+	waitForState(t, ip, "repo-1", repocontextv1.IngestionStatus_STATE_EMBEDDING)
+	uploadArchive(t, uploads, "repo-2", files)
+	if status := waitForState(t, ip, "repo-2", repocontextv1.IngestionStatus_STATE_PENDING); status.State != repocontextv1.IngestionStatus_STATE_PENDING {
+		t.Fatalf("repo-2 = %v, want it queued", status.State)
+	}
+
+	for _, repoID := range []string{"repo-2", "repo-1"} {
+		if _, err := s.DeleteRepository(ctx, &repocontextv1.DeleteRepositoryRequest{RepositoryId: repoID}); err != nil {
+			t.Fatalf("DeleteRepository(%s) error = %v", repoID, err)
+		}
+		if status, err := ip.GetIndexStatus(ctx, repoID); err == nil && status.State != repocontextv1.IngestionStatus_STATE_FAILED {
+			t.Errorf("%s still ingesting after delete: %v", repoID, status.State)
+		}
+	}
+
+	// Nothing left running can recreate a collection once the gate opens
+	close(embeddings.gate)
+	time.Sleep(50 * time.Millisecond)
+	for _, repoID := range []string{"repo-1", "repo-2"} {
+		if _, ok := vectors.collection(ingest.CollectionName(repoID)); ok {
+			t.Errorf("%s's collection was left behind", repoID)
+		}
+		if repo, _ := redisCache.GetRepositoryMetadata(ctx, "default", repoID); repo != nil {
+			t.Errorf("%s's metadata was left behind", repoID)
+		}
+	}
+	entries, err := os.ReadDir(cfg.Upload.TempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "repo-") {
+			t.Errorf("uploaded archive %s was left behind", entry.Name())
+		}
+	}
+}
+
+func TestReindexRepository(t *testing.T) {
+	ctx := context.Background()
+	provider := &recordingProvider{}
+	s, redisCache := newTestRepositoryServer(t, provider)
+	gitSource := &repocontextv1.RepositorySource{
+		Source:    &repocontextv1.RepositorySource_GitUrl{GitUrl: "https://github.com/acme/widgets.git"},
+		Ref:       "main",
+		CommitSha: "0123456789abcdef0123456789abcdef01234567",
+	}
+	for _, repo := range []*repocontextv1.Repository{
+		{RepositoryId: "repo-git", Source: gitSource},
+		{RepositoryId: "repo-upload", Source: &repocontextv1.RepositorySource{Source: &repocontextv1.RepositorySource_UploadedFilename{UploadedFilename: "repo.zip"}}},
+	} {
+		if err := redisCache.SetRepositoryMetadata(ctx, "default", repo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ref := func(s string) *string { return &s }
+	tests := []struct {
+		name       string
+		repoID     string
+		ref        *string
+		wantCode   codes.Code
+		wantRef    string
+		wantCommit string
+	}{
+		{"same ref", "repo-git", nil, codes.OK, "main", gitSource.CommitSha},
+		{"new ref", "repo-git", ref("release"), codes.OK, "release", ""},
+		{"empty ref", "repo-git", ref(""), codes.InvalidArgument, "", ""},
+		{"uploaded archive", "repo-upload", nil, codes.FailedPrecondition, "", ""},
+		{"unknown repository", "repo-missing", nil, codes.NotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider.requests = nil
+			resp, err := s.ReindexRepository(ctx, &repocontextv1.ReindexRepositoryRequest{RepositoryId: tt.repoID, Ref: tt.ref})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("ReindexRepository error = %v, want %v", err, tt.wantCode)
+			}
+			if tt.wantCode != codes.OK {
+				if len(provider.requests) != 0 {
+					t.Error("a rejected reindex started an ingestion")
+				}
+				return
+			}
+
+			// The existing repository is re-indexed in place rather than as a new one
+			if resp.RepositoryId != tt.repoID || len(provider.requests) != 1 {
+				t.Fatalf("reindex of %s = %s with %d ingestions started", tt.repoID, resp.RepositoryId, len(provider.requests))
+			}
+			req := provider.requests[0]
+			if req.RepositoryID != tt.repoID || !req.Incremental || req.IdempotencyKey != resp.UploadId {
+				t.Errorf("ingestion request = %s incremental=%v upload %s, want %s incremental under upload %s", req.RepositoryID, req.Incremental, req.IdempotencyKey, tt.repoID, resp.UploadId)
+			}
+			if req.Source.GetGitUrl() != gitSource.GetGitUrl() || req.Source.GetRef() != tt.wantRef || req.Source.GetCommitSha() != tt.wantCommit {
+				t.Errorf("source = %v, want %s at %q %q", req.Source, gitSource.GetGitUrl(), tt.wantRef, tt.wantCommit)
+			}
+		})
+	}
+
+	// The stored source is left alone until the re-index succeeds
+	repo, err := redisCache.GetRepositoryMetadata(ctx, "default", "repo-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Source.GetRef() != "main" || repo.Source.GetCommitSha() != gitSource.CommitSha {
+		t.Errorf("stored source = %v, want it unchanged", repo.Source)
+	}
+}
+
+func TestListRepositoriesPaging(t *testing.T) {
+	ctx := context.Background()
+	s, redisCache := newTestRepositoryServer(t, &recordingProvider{})
+	s.config.Defaults.PageSize = 2
+	s.config.Defaults.MaxPageSize = 3
+	for _, id := range []string{"repo-e", "repo-b", "repo-d", "repo-a", "repo-c"} {
+		if err := redisCache.SetRepositoryMetadata(ctx, "default", &repocontextv1.Repository{RepositoryId: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		pageSize     int32
+		wantPageSize int32
+		wantPages    [][]string
+	}{
+		{"default page size", 0, 2, [][]string{{"repo-a", "repo-b"}, {"repo-c", "repo-d"}, {"repo-e"}}},
+		{"requested page size", 1, 1, [][]string{{"repo-a"}, {"repo-b"}, {"repo-c"}, {"repo-d"}, {"repo-e"}}},
+		{"clamped to the maximum", 50, 3, [][]string{{"repo-a", "repo-b", "repo-c"}, {"repo-d", "repo-e"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages [][]string
+			token := ""
+			for len(pages) <= len(tt.wantPages) {
+				resp, err := s.ListRepositories(ctx, &repocontextv1.ListRepositoriesRequest{PageSize: tt.pageSize, PageToken: token})
+				if err != nil {
+					t.Fatalf("ListRepositories error = %v", err)
+				}
+				if resp.PageSize != tt.wantPageSize {
+					t.Errorf("page size = %d, want %d", resp.PageSize, tt.wantPageSize)
+				}
+				var page []string
+				for _, repo := range resp.Repositories {
+					page = append(page, repo.RepositoryId)
+				}
+				pages = append(pages, page)
+				if token = resp.NextPageToken; token == "" {
+					break
+				}
+			}
+			if fmt.Sprint(pages) != fmt.Sprint(tt.wantPages) {
+				t.Errorf("pages = %v, want %v", pages, tt.wantPages)
+			}
+		})
+	}
+
+	if _, err := s.ListRepositories(ctx, &repocontextv1.ListRepositoriesRequest{PageSize: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("negative page size error = %v, want InvalidArgument", err)
+	}
+	if _, err := s.ListRepositories(ctx, &repocontextv1.ListRepositoriesRequest{PageToken: "not base64!"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("malformed page token error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestUpdateRepository(t *testing.T) {
+	ctx := context.Background()
+	s, redisCache := newTestRepositoryServer(t, &recordingProvider{})
+	original := &repocontextv1.Repository{
+		RepositoryId:    "repo-1",
+		Name:            "widgets",
+		Description:     "Widget factory",
+		Source:          &repocontextv1.RepositorySource{Source: &repocontextv1.RepositorySource_GitUrl{GitUrl: "https://github.com/acme/widgets.git"}, CommitSha: "abc123"},
+		Stats:           &repocontextv1.RepositoryStats{TotalFiles: 3, TotalLines: 42, TotalChunks: 5},
+		IngestionStatus: &repocontextv1.IngestionStatus{State: repocontextv1.IngestionStatus_STATE_READY},
+	}
+
+	tests := []struct {
+		name            string
+		req             *repocontextv1.UpdateRepositoryRequest
+		wantCode        codes.Code
+		wantName        string
+		wantDescription string
+	}{
+		{"rename", &repocontextv1.UpdateRepositoryRequest{RepositoryId: "repo-1", Name: proto.String("gadgets")}, codes.OK, "gadgets", "Widget factory"},
+		{"new description", &repocontextv1.UpdateRepositoryRequest{RepositoryId: "repo-1", Description: proto.String("")}, codes.OK, "widgets", ""},
+		{"both", &repocontextv1.UpdateRepositoryRequest{RepositoryId: "repo-1", Name: proto.String("gadgets"), Description: proto.String("Gadget works")}, codes.OK, "gadgets", "Gadget works"},
+		{"missing repository", &repocontextv1.UpdateRepositoryRequest{RepositoryId: "repo-2", Name: proto.String("gadgets")}, codes.NotFound, "widgets", "Widget factory"},
+		{"no fields", &repocontextv1.UpdateRepositoryRequest{RepositoryId: "repo-1"}, codes.InvalidArgument, "widgets", "Widget factory"},
+		{"blank name", &repocontextv1.UpdateRepositoryRequest{RepositoryId: "repo-1", Name: proto.String("  ")}, codes.InvalidArgument, "widgets", "Widget factory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := redisCache.SetRepositoryMetadata(ctx, "default", proto.Clone(original).(*repocontextv1.Repository)); err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := s.UpdateRepository(ctx, tt.req)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("UpdateRepository error = %v, want %v", err, tt.wantCode)
+			}
+			if err == nil && (resp.Repository.Name != tt.wantName || resp.Repository.Description != tt.wantDescription) {
+				t.Errorf("returned %q, %q; want %q, %q", resp.Repository.Name, resp.Repository.Description, tt.wantName, tt.wantDescription)
+			}
+			if missing, _ := redisCache.GetRepositoryMetadata(ctx, "default", "repo-2"); missing != nil {
+				t.Error("updating a missing repository created it")
+			}
+
+			stored, err := redisCache.GetRepositoryMetadata(ctx, "default", "repo-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Name != tt.wantName || stored.Description != tt.wantDescription {
+				t.Errorf("stored %q, %q; want %q, %q", stored.Name, stored.Description, tt.wantName, tt.wantDescription)
+			}
+			// Fields owned by ingestion are left alone
+			if !proto.Equal(stored.Source, original.Source) || !proto.Equal(stored.Stats, original.Stats) || !proto.Equal(stored.IngestionStatus, original.IngestionStatus) {
+				t.Errorf("stored source %v, stats %v, status %v changed", stored.Source, stored.Stats, stored.IngestionStatus)
+			}
+		})
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	ctx := context.Background()
+	s, redisCache := newTestRepositoryServer(t, &recordingProvider{})
+	s.config.Defaults.PageSize = 2
+	if err := redisCache.SetRepositoryMetadata(ctx, "default", &repocontextv1.Repository{
+		RepositoryId: "repo-1",
+		Stats:        &repocontextv1.RepositoryStats{TotalFiles: 5},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var files []*repocontextv1.FileEntry
+	for _, path := range []string{"main.go", "internal/apiserver/server.go", "internal/api/repository.go", "README.md", "internal/api/chat.go"} {
+		files = append(files, &repocontextv1.FileEntry{Path: path, SizeBytes: 100, LineCount: 10})
+	}
+	if err := redisCache.SetFileInventory(ctx, "default", "repo-1", files); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		pathPrefix string
+		wantPages  [][]string
+	}{
+		{"full tree", "", [][]string{{"README.md", "internal/api/chat.go"}, {"internal/api/repository.go", "internal/apiserver/server.go"}, {"main.go"}}},
+		{"sub-tree", "internal/api", [][]string{{"internal/api/chat.go", "internal/api/repository.go"}}},
+		{"trailing slash", "internal/", [][]string{{"internal/api/chat.go", "internal/api/repository.go"}, {"internal/apiserver/server.go"}}},
+		{"single file", "main.go", [][]string{{"main.go"}}},
+		{"no matches", "cmd", [][]string{nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages [][]string
+			wantTotal := 0
+			for _, page := range tt.wantPages {
+				wantTotal += len(page)
+			}
+			token := ""
+			for len(pages) <= len(tt.wantPages) {
+				resp, err := s.ListFiles(ctx, &repocontextv1.ListFilesRequest{RepositoryId: "repo-1", PathPrefix: tt.pathPrefix, PageToken: token})
+				if err != nil {
+					t.Fatalf("ListFiles error = %v", err)
+				}
+				if int(resp.TotalFiles) != wantTotal {
+					t.Errorf("total files = %d, want %d", resp.TotalFiles, wantTotal)
+				}
+				var page []string
+				for _, file := range resp.Files {
+					page = append(page, file.Path)
+				}
+				pages = append(pages, page)
+				if token = resp.NextPageToken; token == "" {
+					break
+				}
+			}
+			if fmt.Sprint(pages) != fmt.Sprint(tt.wantPages) {
+				t.Errorf("pages = %v, want %v", pages, tt.wantPages)
+			}
+		})
+	}
+
+	if _, err := s.ListFiles(ctx, &repocontextv1.ListFilesRequest{RepositoryId: "repo-2"}); status.Code(err) != codes.NotFound {
+		t.Errorf("missing repository error = %v, want NotFound", err)
+	}
+}
+
+func TestDeleteRepositoryInvalidatesQueryResults(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig(t)
+	redisCache := newTestCache(t)
+	ip := newTestInlineProcessor(t, cfg, redisCache, &gatedEmbeddings{}, newMemoryVectorStore())
+	s := NewRepositoryServer(cfg, redisCache, ip, nil, observability.NewMetrics(), observability.NewNoOpTracer())
+
+	// repo-10 shares repo-1's ID as a prefix and keeps its results
+	for _, repoID := range []string{"repo-1", "repo-10"} {
+		if err := redisCache.SetRepositoryMetadata(ctx, "default", &repocontextv1.Repository{
+			RepositoryId:    repoID,
+			IngestionStatus: &repocontextv1.IngestionStatus{State: repocontextv1.IngestionStatus_STATE_READY},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range []string{"widgets", "gadgets"} {
+			if err := redisCache.SetQueryResult(ctx, "default", repoID, q, 10, nil, &cache.CachedQueryResult{
+				Chunks: []*repocontextv1.CodeChunk{{RepositoryId: repoID, FilePath: "widget.go"}},
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if _, err := s.DeleteRepository(ctx, &repocontextv1.DeleteRepositoryRequest{RepositoryId: "repo-1"}); err != nil {
+		t.Fatalf("DeleteRepository error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		repoID     string
+		wantCached bool
+	}{
+		{"repo-1", false},
+		{"repo-10", true},
+	} {
+		for _, q := range []string{"widgets", "gadgets"} {
+			cached, err := redisCache.GetQueryResult(ctx, "default", tt.repoID, q, 10, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (cached != nil) != tt.wantCached {
+				t.Errorf("%s result for %q cached = %v, want %v", tt.repoID, q, cached != nil, tt.wantCached)
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	"repo-context-service/internal/query"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxSearchOffset bounds how deep Search pages, since every page re-runs the searches
+// for all the ranks before it
+const maxSearchOffset = 1000
+
+// Search runs one query against several repositories and merges the hits into a single
+// ranked list, returning the page of it starting at offset. The query is embedded
+// once; the repositories are searched DEFAULT_SEARCH_CONCURRENCY at a time. A
+// repository whose search fails is reported in failed_repository_ids, and the
+// request only fails when every repository did.
+func (s *ChatServer) Search(ctx context.Context, req *repocontextv1.SearchRequest) (*repocontextv1.SearchResponse, error) {
+	ctx, span := s.tracer.StartRPC(ctx, "Search")
+	defer span.End()
+
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.TenantAttr(tenantID),
+	)
+
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "query is required")
+	}
+	if _, ok := repocontextv1.SearchMode_name[int32(req.SearchMode)]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown search mode %d", req.SearchMode)
+	}
+	if req.ContextLines < 0 || req.ContextLines > config.MaxContextLines {
+		return nil, status.Errorf(codes.InvalidArgument, "context_lines must be between 0 and %d", config.MaxContextLines)
+	}
+	if req.MaxResults < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "max_results must not be negative")
+	}
+	if req.Offset < 0 || req.Offset > maxSearchOffset {
+		return nil, status.Errorf(codes.InvalidArgument, "offset must be between 0 and %d", maxSearchOffset)
+	}
+	if req.MinCertainty != nil && (*req.MinCertainty < 0 || *req.MinCertainty >= 1) {
+		return nil, status.Errorf(codes.InvalidArgument, "min_certainty must be at least 0 and less than 1")
+	}
+	if err := validateLexicalOptions(req.Query, req.LexicalOptions); err != nil {
+		return nil, err
+	}
+	if err := validateSearchFilters(req.Filters); err != nil {
+		return nil, err
+	}
+
+	repositoryIDs, truncated, err := s.searchableRepositories(ctx, tenantID, req.RepositoryIds)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := getTopK(&repocontextv1.ChatOptions{MaxResults: req.MaxResults})
+	if limit > int32(s.config.Defaults.MaxSearchResults) {
+		limit = int32(s.config.Defaults.MaxSearchResults)
+	}
+	mode := getSearchMode(&repocontextv1.ChatOptions{SearchMode: req.SearchMode})
+	contextLines := s.getContextLines(&repocontextv1.ChatOptions{ContextLines: req.ContextLines})
+	filters := withSearchFilters(lexicalFilters(req.LexicalOptions), req.Filters)
+	minCertainty := s.config.Defaults.SemanticMinCertainty
+	if req.MinCertainty != nil {
+		minCertainty = *req.MinCertainty
+	}
+
+	// A semantic search of one repository is ranked by the vector store alone, so it
+	// pages there directly, asking for one extra hit to tell whether a page follows.
+	// Otherwise each search fetches the ranks up to the end of the page and the page
+	// is cut from the merged ranking.
+	fetchLimit, semanticOffset, mergedOffset := req.Offset+limit, int32(0), req.Offset
+	if mode == repocontextv1.SearchMode_SEARCH_MODE_SEMANTIC && len(repositoryIDs) == 1 {
+		fetchLimit, semanticOffset, mergedOffset = limit+1, req.Offset, 0
+	}
+
+	// Semantic time covers both the query embedding and the vector searches
+	embeddingTimer := observability.StartTimer()
+	queryEmbedding, err := s.searchEmbedding(ctx, req.Query, mode)
+	if err != nil {
+		return nil, searchStatus(err)
+	}
+	embeddingTime := embeddingTimer.Duration()
+
+	outcomes := make([]struct {
+		results *query.SearchResults
+		err     error
+	}, len(repositoryIDs))
+
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	workers := s.config.Defaults.SearchConcurrency
+	if workers > len(repositoryIDs) {
+		workers = len(repositoryIDs)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each repository writes its own slot, so outcomes keep the request order
+			for i := range jobs {
+				outcomes[i].results, outcomes[i].err = s.searchRepository(ctx, repositoryIDs[i], req.Query, queryEmbedding, fetchLimit, semanticOffset, minCertainty, mode, filters, contextLines)
+			}
+		}()
+	}
+	for i := range repositoryIDs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Repositories are searched in parallel, so each backend's time is that of the
+	// slowest repository
+	combined := &query.SearchResults{}
+	var failed []string
+	var firstErr error
+	for i, outcome := range outcomes {
+		if outcome.err != nil {
+			observability.Logger(ctx).Warn("Search: Repository search failed", observability.LogKeyRepositoryID, repositoryIDs[i], "error", outcome.err)
+			failed = append(failed, repositoryIDs[i])
+			if firstErr == nil {
+				firstErr = outcome.err
+			}
+			continue
+		}
+		combined.LexicalChunks = append(combined.LexicalChunks, outcome.results.LexicalChunks...)
+		combined.SemanticChunks = append(combined.SemanticChunks, outcome.results.SemanticChunks...)
+		for chunk, vector := range outcome.results.SemanticVectors {
+			if combined.SemanticVectors == nil {
+				combined.SemanticVectors = make(map[*repocontextv1.CodeChunk][]float32)
+			}
+			combined.SemanticVectors[chunk] = vector
+		}
+		combined.LexicalTime = maxDuration(combined.LexicalTime, outcome.results.LexicalTime)
+		combined.SemanticTime = maxDuration(combined.SemanticTime, outcome.results.SemanticTime)
+	}
+	if len(repositoryIDs) > 0 && len(failed) == len(repositoryIDs) {
+		return nil, searchStatus(firstErr)
+	}
+	if mode != repocontextv1.SearchMode_SEARCH_MODE_LEXICAL {
+		combined.SemanticTime += embeddingTime
+	}
+
+	// Chunks carry their repository, so the merger only combines hits from the same one
+	merged := pageResults(s.queryService.merger.MergeAndRankTop(combined, int(mergedOffset+limit)), mergedOffset, limit)
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(len(merged.Chunks)),
+	)
+
+	return &repocontextv1.SearchResponse{
+		Chunks:                merged.Chunks,
+		Timings:               merged.Timings,
+		Stats:                 merged.Stats,
+		RepositoryIds:         repositoryIDs,
+		FailedRepositoryIds:   failed,
+		RepositoriesTruncated: truncated,
+	}, nil
+}
+
+// searchableRepositories resolves the repositories a search covers. Requested ones
+// must exist for the tenant and be READY; with none requested, the tenant's READY
+// repositories are searched, the most recently updated first, up to
+// DEFAULT_MAX_SEARCH_REPOSITORIES of them.
+func (s *ChatServer) searchableRepositories(ctx context.Context, tenantID string, requested []string) ([]string, bool, error) {
+	maxRepositories := s.config.Defaults.MaxSearchRepositories
+
+	if len(requested) == 0 {
+		repositories, err := s.cache.ListRepositoryMetadata(ctx, tenantID)
+		if err != nil {
+			return nil, false, status.Errorf(codes.Internal, "failed to list repositories: %v", err)
+		}
+
+		var ready []*repocontextv1.Repository
+		for _, repo := range repositories {
+			if repo.IngestionStatus.GetState() == repocontextv1.IngestionStatus_STATE_READY {
+				ready = append(ready, repo)
+			}
+		}
+		sort.SliceStable(ready, func(i, j int) bool {
+			return ready[i].UpdatedAt.AsTime().After(ready[j].UpdatedAt.AsTime())
+		})
+
+		truncated := len(ready) > maxRepositories
+		if truncated {
+			ready = ready[:maxRepositories]
+		}
+
+		repositoryIDs := make([]string, len(ready))
+		for i, repo := range ready {
+			repositoryIDs[i] = repo.RepositoryId
+		}
+		return repositoryIDs, truncated, nil
+	}
+
+	var repositoryIDs []string
+	seen := make(map[string]bool, len(requested))
+	for _, repositoryID := range requested {
+		if !seen[repositoryID] {
+			seen[repositoryID] = true
+			repositoryIDs = append(repositoryIDs, repositoryID)
+		}
+	}
+	if len(repositoryIDs) > maxRepositories {
+		return nil, false, status.Errorf(codes.InvalidArgument, "at most %d repositories can be searched at once", maxRepositories)
+	}
+
+	for _, repositoryID := range repositoryIDs {
+		repo, err := s.cache.GetRepositoryMetadata(ctx, tenantID, repositoryID)
+		if err != nil {
+			return nil, false, status.Errorf(codes.Internal, "failed to get repository: %v", err)
+		}
+		if repo == nil {
+			return nil, false, status.Errorf(codes.NotFound, "repository %s not found", repositoryID)
+		}
+		if repo.IngestionStatus.GetState() != repocontextv1.IngestionStatus_STATE_READY {
+			return nil, false, status.Errorf(codes.FailedPrecondition, "repository %s is not ready (status: %s)", repositoryID, repo.IngestionStatus.GetState())
+		}
+	}
+
+	return repositoryIDs, false, nil
+}
+
+// searchStatus maps a search failure to the status chat uses for it
+func searchStatus(err error) error {
+	switch {
+	case errors.Is(err, query.ErrSearchTimeout):
+		return status.Errorf(codes.DeadlineExceeded, "search failed: %v", err)
+	case errors.Is(err, query.ErrCollectionNotFound):
+		return status.Errorf(codes.FailedPrecondition, "repository not ready: %v", err)
+	default:
+		return status.Errorf(codes.Internal, "search failed: %v", err)
+	}
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/query"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// repoSearchClient is a lexical and semantic client answering each repository's
+// searches with its own chunks, as found in that repository. Semantic searches
+// page through them like a vector store.
+type repoSearchClient struct {
+	chunks map[string][]*repocontextv1.CodeChunk
+}
+
+func (c *repoSearchClient) SearchLexical(ctx context.Context, repoID, queryText string, limit int, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	return c.results(repoID, repocontextv1.SearchSource_SEARCH_SOURCE_LEXICAL, limit, 0), nil
+}
+
+func (c *repoSearchClient) ReadFile(ctx context.Context, repoID, path string, startLine, endLine int) (*repocontextv1.CodeChunk, error) {
+	return nil, fmt.Errorf("not found")
+}
+
+func (c *repoSearchClient) SearchSemantic(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	return c.results(repoID, repocontextv1.SearchSource_SEARCH_SOURCE_SEMANTIC, limit, offset), nil
+}
+
+func (c *repoSearchClient) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+func (c *repoSearchClient) results(repoID string, source repocontextv1.SearchSource, limit, offset int) []*repocontextv1.CodeChunk {
+	chunks := c.chunks[repoID]
+	if offset > len(chunks) {
+		offset = len(chunks)
+	}
+	chunks = chunks[offset:]
+	if len(chunks) > limit {
+		chunks = chunks[:limit]
+	}
+	var results []*repocontextv1.CodeChunk
+	for _, chunk := range chunks {
+		result := proto.Clone(chunk).(*repocontextv1.CodeChunk)
+		result.RepositoryId = repoID
+		result.Source = source
+		results = append(results, result)
+	}
+	return results
+}
+
+func TestSearchInterleavesRepositories(t *testing.T) {
+	ctx := context.Background()
+	// Both repositories have a main.go with overlapping lines, which mustn't be merged
+	search := &repoSearchClient{chunks: map[string][]*repocontextv1.CodeChunk{
+		"repo-1": {
+			{FilePath: "main.go", StartLine: 1, EndLine: 10, Content: "func main() { widgets() }", Score: 0.9},
+			{FilePath: "widget.go", StartLine: 1, EndLine: 10, Content: "func widgets() {}", Score: 0.6},
+		},
+		"repo-2": {
+			{FilePath: "main.go", StartLine: 5, EndLine: 15, Content: "func main() { gadgets() }", Score: 0.8},
+			{FilePath: "gadget.go", StartLine: 1, EndLine: 10, Content: "func gadgets() {}", Score: 0.5},
+		},
+		"repo-3": {
+			{FilePath: "main.go", StartLine: 1, EndLine: 10, Content: "func main() {}", Score: 1},
+		},
+	}}
+	s, redisCache := newTestChatServer(t, &fixedSearchClient{}, &scriptedComposer{})
+	s.queryService.lexicalClient = search
+	s.queryService.semanticClient = search
+	s.queryService.merger = query.NewResultMerger(10, config.MergeConfig{Mode: query.MergeModeZScore, LexicalWeight: 1, SemanticWeight: 1})
+	for id, state := range map[string]repocontextv1.IngestionStatus_State{
+		"repo-2": repocontextv1.IngestionStatus_STATE_READY,
+		"repo-3": repocontextv1.IngestionStatus_STATE_EMBEDDING,
+	} {
+		if err := redisCache.SetRepositoryMetadata(ctx, "default", &repocontextv1.Repository{
+			RepositoryId:    id,
+			IngestionStatus: &repocontextv1.IngestionStatus{State: state},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		mode repocontextv1.SearchMode
+	}{
+		{"lexical", repocontextv1.SearchMode_SEARCH_MODE_LEXICAL},
+		{"semantic", repocontextv1.SearchMode_SEARCH_MODE_SEMANTIC},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// With no repositories named, the tenant's ready ones are searched
+			resp, err := s.Search(ctx, &repocontextv1.SearchRequest{Query: "widgets", SearchMode: tt.mode})
+			if err != nil {
+				t.Fatalf("Search error = %v", err)
+			}
+			if len(resp.RepositoryIds) != 2 || len(resp.FailedRepositoryIds) != 0 {
+				t.Errorf("searched %v with %v failing, want repo-1 and repo-2", resp.RepositoryIds, resp.FailedRepositoryIds)
+			}
+
+			var got []string
+			for _, chunk := range resp.Chunks {
+				got = append(got, chunk.RepositoryId+"/"+chunk.FilePath)
+			}
+			if want := "[repo-1/main.go repo-2/main.go repo-1/widget.go repo-2/gadget.go]"; fmt.Sprint(got) != want {
+				t.Errorf("ranked %v, want %s", got, want)
+			}
+			// Each hit keeps the content of the repository it was found in
+			for _, chunk := range resp.Chunks {
+				if chunk.FilePath == "main.go" && (chunk.RepositoryId == "repo-1") != (chunk.Content == "func main() { widgets() }") {
+					t.Errorf("%s/main.go has content %q", chunk.RepositoryId, chunk.Content)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchPages(t *testing.T) {
+	ctx := context.Background()
+	var chunks []*repocontextv1.CodeChunk
+	for i, file := range []string{"a.go", "b.go", "c.go", "d.go", "e.go"} {
+		chunks = append(chunks, &repocontextv1.CodeChunk{FilePath: file, StartLine: 1, EndLine: 10, Content: "func " + file, Score: 1 - float32(i)*0.1})
+	}
+	search := &repoSearchClient{chunks: map[string][]*repocontextv1.CodeChunk{"repo-1": chunks}}
+	s, _ := newTestChatServer(t, &fixedSearchClient{}, &scriptedComposer{})
+	s.queryService.lexicalClient = search
+	s.queryService.semanticClient = search
+	s.queryService.merger = query.NewResultMerger(10, config.MergeConfig{Mode: query.MergeModeZScore, LexicalWeight: 1, SemanticWeight: 1})
+
+	tests := []struct {
+		name string
+		mode repocontextv1.SearchMode
+	}{
+		// Paged by the vector store
+		{"semantic", repocontextv1.SearchMode_SEARCH_MODE_SEMANTIC},
+		// Paged from the merged ranking
+		{"both", repocontextv1.SearchMode_SEARCH_MODE_BOTH},
+		{"lexical", repocontextv1.SearchMode_SEARCH_MODE_LEXICAL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages []string
+			for _, offset := range []int32{0, 2, 4} {
+				resp, err := s.Search(ctx, &repocontextv1.SearchRequest{Query: "func", SearchMode: tt.mode, RepositoryIds: []string{"repo-1"}, MaxResults: 2, Offset: offset})
+				if err != nil {
+					t.Fatalf("Search at offset %d error = %v", offset, err)
+				}
+				var files []string
+				for _, chunk := range resp.Chunks {
+					files = append(files, chunk.FilePath)
+				}
+				pages = append(pages, fmt.Sprint(files))
+			}
+			if want := "[[a.go b.go] [c.go d.go] [e.go]]"; fmt.Sprint(pages) != want {
+				t.Errorf("pages = %v, want %s", pages, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"context"
+
+	"repo-context-service/internal/interceptors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// resolveTenantID returns the tenant a request operates on. The tenant established by
+// the auth interceptor is authoritative: a conflicting tenant in the request body is
+// rejected unless the caller has the admin scope. With auth disabled the interceptor
+// establishes the default tenant without the admin scope, so no other tenant can be
+// named. Every transport passes through the interceptor (the WebSocket bridge dials
+// the gRPC server); only a server called directly, without one, falls back to the
+// body or the default tenant.
+func resolveTenantID(ctx context.Context, requested, defaultTenant string) (string, error) {
+	authenticated, ok := interceptors.AuthenticatedTenantID(ctx)
+	if !ok {
+		if requested == "" {
+			return defaultTenant, nil
+		}
+		return requested, nil
+	}
+
+	if requested == "" || requested == authenticated {
+		return authenticated, nil
+	}
+
+	if interceptors.HasScope(ctx, interceptors.ScopeAdmin) {
+		return requested, nil
+	}
+
+	return "", status.Errorf(codes.PermissionDenied, "tenant %s does not match the authenticated tenant", requested)
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/cache"
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/ingest"
+	"repo-context-service/internal/interceptors"
+	"repo-context-service/internal/observability"
+	"repo-context-service/internal/query"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// authenticatedContext returns the context the auth interceptor hands a handler for
+// a request carrying apiKey
+func authenticatedContext(t *testing.T, auth *interceptors.AuthInterceptor, apiKey string) context.Context {
+	t.Helper()
+	var authenticated context.Context
+	handler := auth.HTTPMiddleware("/repocontext.v1.RepositoryService/ListRepositories")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated = r.Context()
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/v1/repositories", nil)
+	req.Header.Set("x-api-key", apiKey)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if authenticated == nil {
+		t.Fatal("the auth interceptor rejected the API key")
+	}
+	return authenticated
+}
+
+// deleteRecordingProvider records the indexes it is asked to cancel or delete
+type deleteRecordingProvider struct {
+	ingest.Provider
+	cancelled []string
+	deleted   []string
+}
+
+func (p *deleteRecordingProvider) CancelIndex(ctx context.Context, tenantID, repoID string) (bool, error) {
+	p.cancelled = append(p.cancelled, tenantID+"/"+repoID)
+	return false, nil
+}
+
+func (p *deleteRecordingProvider) DeleteIndex(ctx context.Context, repoID string) error {
+	p.deleted = append(p.deleted, repoID)
+	return nil
+}
+
+func TestResolveTenantIDWithAuthDisabled(t *testing.T) {
+	auth, err := interceptors.NewAuthInterceptor(&config.SecurityConfig{DefaultTenant: "default"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Capture the context the interceptor hands to a handler
+	var authenticated context.Context
+	handler := auth.HTTPMiddleware("/repocontext.v1.ChatService/Search")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated = r.Context()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/search", nil))
+	if authenticated == nil {
+		t.Fatal("the auth interceptor rejected an unauthenticated request with auth disabled")
+	}
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		requested string
+		want      string
+		wantCode  codes.Code
+	}{
+		{"default tenant", authenticated, "", "default", codes.OK},
+		{"default tenant by name", authenticated, "default", "default", codes.OK},
+		{"other tenant", authenticated, "tenant-b", "", codes.PermissionDenied},
+		{"no interceptor", context.Background(), "tenant-b", "tenant-b", codes.OK},
+		{"no interceptor or tenant", context.Background(), "", "default", codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveTenantID(tt.ctx, tt.requested, "default")
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("resolveTenantID(%q) error = %v, want code %v", tt.requested, err, tt.wantCode)
+			}
+			if got != tt.want {
+				t.Errorf("resolveTenantID(%q) = %q, want %q", tt.requested, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrossTenantAccessDenied(t *testing.T) {
+	ctx := context.Background()
+	provider := &deleteRecordingProvider{}
+	repositories, redisCache := newTestRepositoryServer(t, provider)
+	repositories.config.Defaults.MaxSearchRepositories = 10
+	client := &filterRecordingClient{}
+	chat := NewChatServer(repositories.config, redisCache, &QueryService{lexicalClient: client, semanticClient: client, merger: query.NewResultMerger(10, config.MergeConfig{})}, nil, nil, observability.NewMetrics(), observability.NewNoOpTracer())
+
+	if err := redisCache.SetRepositoryMetadata(ctx, "tenant-a", &repocontextv1.Repository{
+		RepositoryId:    "repo-a",
+		IngestionStatus: &repocontextv1.IngestionStatus{State: repocontextv1.IngestionStatus_STATE_READY},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := redisCache.SetAPIKey(ctx, "rcs_tenant_b", &cache.APIKeyRecord{
+		KeyID:     "key-b",
+		TenantID:  "tenant-b",
+		Scopes:    []string{interceptors.ScopeRead, interceptors.ScopeUpload},
+		CreatedAt: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := interceptors.NewAuthInterceptor(&config.SecurityConfig{RequireAuth: true, DefaultTenant: "default"}, redisCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantB := authenticatedContext(t, auth, "rcs_tenant_b")
+
+	tests := []struct {
+		name     string
+		call     func() error
+		wantCode codes.Code
+	}{
+		{"get by ID", func() error {
+			_, err := repositories.GetRepository(tenantB, &repocontextv1.GetRepositoryRequest{RepositoryId: "repo-a"})
+			return err
+		}, codes.NotFound},
+		{"get naming the tenant", func() error {
+			_, err := repositories.GetRepository(tenantB, &repocontextv1.GetRepositoryRequest{RepositoryId: "repo-a", TenantId: "tenant-a"})
+			return err
+		}, codes.PermissionDenied},
+		{"delete by ID", func() error {
+			_, err := repositories.DeleteRepository(tenantB, &repocontextv1.DeleteRepositoryRequest{RepositoryId: "repo-a"})
+			return err
+		}, codes.NotFound},
+		{"delete naming the tenant", func() error {
+			_, err := repositories.DeleteRepository(tenantB, &repocontextv1.DeleteRepositoryRequest{RepositoryId: "repo-a", TenantId: "tenant-a"})
+			return err
+		}, codes.PermissionDenied},
+		{"search by ID", func() error {
+			_, err := chat.Search(tenantB, &repocontextv1.SearchRequest{Query: "auth", RepositoryIds: []string{"repo-a"}})
+			return err
+		}, codes.NotFound},
+		{"search naming the tenant", func() error {
+			_, err := chat.Search(tenantB, &repocontextv1.SearchRequest{Query: "auth", TenantId: "tenant-a", RepositoryIds: []string{"repo-a"}})
+			return err
+		}, codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); status.Code(err) != tt.wantCode {
+				t.Errorf("error = %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+
+	if len(provider.deleted) != 0 {
+		t.Errorf("another tenant deleted indexes %v", provider.deleted)
+	}
+	for _, cancelled := range provider.cancelled {
+		if cancelled != "tenant-b/repo-a" {
+			t.Errorf("CancelIndex called as %s, want only tenant-b's own ingestion", cancelled)
+		}
+	}
+	if client.lexicalFilters != nil || client.semanticFilters != nil {
+		t.Error("another tenant's repository was searched")
+	}
+	if repo, err := redisCache.GetRepositoryMetadata(ctx, "tenant-a", "repo-a"); err != nil || repo == nil {
+		t.Errorf("tenant-a's repository is gone: %v, %v", repo, err)
+	}
+}
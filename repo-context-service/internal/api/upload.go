@@ -2,10 +2,13 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
 	"repo-context-service/internal/cache"
@@ -15,6 +18,7 @@ import (
 	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -55,15 +59,19 @@ func (s *UploadServer) UploadRepository(stream repocontextv1.UploadService_Uploa
 	}
 
 	// Extract tenant ID and validate
-	tenantID := firstReq.TenantId
-	if tenantID == "" {
-		tenantID = s.config.Security.DefaultTenant
+	tenantID, err := resolveTenantID(ctx, firstReq.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return err
 	}
 
 	observability.SetSpanAttributes(span,
 		observability.TenantAttr(tenantID),
 	)
 
+	if err := ingest.NewChunkOptions(s.config.Defaults, firstReq.Options).Validate(); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid upload options: %v", err)
+	}
+
 	// Generate repository ID
 	repoID := generateRepositoryID()
 	uploadID := firstReq.IdempotencyKey
@@ -92,7 +100,7 @@ func (s *UploadServer) UploadRepository(stream repocontextv1.UploadService_Uploa
 	switch source := firstReq.Source.(type) {
 	case *repocontextv1.UploadRepositoryRequest_FileUpload:
 		// Handle file upload
-		filename, err := s.handleFileUpload(ctx, stream, firstReq, repoID)
+		filename, err := s.handleFileUpload(ctx, stream, firstReq, uploadID)
 		if err != nil {
 			s.metrics.RecordUploadRequest("file", "error")
 			return status.Errorf(codes.Internal, "file upload failed: %v", err)
@@ -109,6 +117,10 @@ func (s *UploadServer) UploadRepository(stream repocontextv1.UploadService_Uploa
 
 	case *repocontextv1.UploadRepositoryRequest_GitRepository:
 		// Handle Git repository
+		if err := ingest.ValidateGitSource(source.GitRepository.Url, source.GitRepository.Ref); err != nil {
+			s.metrics.RecordUploadRequest("git", "error")
+			return status.Errorf(codes.InvalidArgument, "invalid git_repository: %v", err)
+		}
 		repositorySource = &repocontextv1.RepositorySource{
 			Source: &repocontextv1.RepositorySource_GitUrl{
 				GitUrl: source.GitRepository.Url,
@@ -126,67 +138,82 @@ func (s *UploadServer) UploadRepository(stream repocontextv1.UploadService_Uploa
 		return status.Errorf(codes.InvalidArgument, "unsupported source type")
 	}
 
+	response, err := s.startIngestion(ctx, tenantID, repoID, uploadID, repositorySource, firstReq.Options)
+	if err != nil {
+		return err
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.RepositoryAttr(repoID),
+	)
+
+	return stream.SendAndClose(response)
+}
+
+// startIngestion starts indexing an uploaded source under repoID
+func (s *UploadServer) startIngestion(
+	ctx context.Context,
+	tenantID, repoID, uploadID string,
+	source *repocontextv1.RepositorySource,
+	options *repocontextv1.UploadOptions,
+) (*repocontextv1.UploadRepositoryResponse, error) {
 	// Create ingestion request
 	ingestReq := &ingest.CreateIndexRequest{
 		RepositoryID:   repoID,
 		TenantID:       tenantID,
-		Source:         repositorySource,
-		Options:        firstReq.Options,
+		Source:         source,
+		Options:        options,
 		IdempotencyKey: uploadID,
-		ProgressCallback: func(progress *repocontextv1.IngestionProgress) {
-			// Progress callback - could be used for real-time updates
-			// For now, we'll store it in cache
-		},
 	}
 
 	// Start ingestion
 	ingestResp, err := s.ingestProvider.CreateRepositoryIndex(ctx, ingestReq)
 	if err != nil {
-		return status.Errorf(codes.Internal, "failed to start ingestion: %v", err)
+		// Ingestion removes the archive once it's extracted; nothing will if it never starts
+		if filename := source.GetUploadedFilename(); filename != "" {
+			s.removeUploadFile(filename)
+		}
+		return nil, ingestionStartError(err)
 	}
 
-	// Send response
-	response := &repocontextv1.UploadRepositoryResponse{
+	return &repocontextv1.UploadRepositoryResponse{
 		UploadId:     uploadID,
 		RepositoryId: repoID,
 		AcceptedAt:   timestamppb.New(ingestResp.AcceptedAt),
 		Status:       ingestResp.Status,
-	}
-
-	observability.SetSpanAttributes(span,
-		observability.RepositoryAttr(repoID),
-	)
+	}, nil
+}
 
-	return stream.SendAndClose(response)
+// ingestionStartError converts a CreateRepositoryIndex failure to a gRPC status
+func ingestionStartError(err error) error {
+	if errors.Is(err, ingest.ErrIngestionQueueFull) {
+		return status.Errorf(codes.ResourceExhausted, "too many ingestions are queued; retry later")
+	}
+	return status.Errorf(codes.Internal, "failed to start ingestion: %v", err)
 }
 
 func (s *UploadServer) handleFileUpload(
 	ctx context.Context,
 	stream repocontextv1.UploadService_UploadRepositoryServer,
 	firstReq *repocontextv1.UploadRepositoryRequest,
-	repoID string,
+	uploadID string,
 ) (string, error) {
 	fileUpload := firstReq.GetFileUpload()
 	if fileUpload == nil {
 		return "", fmt.Errorf("no file upload data in first request")
 	}
 
-	// Create temp file
-	tempDir := s.config.Upload.TempDir
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create temp directory: %w", err)
-	}
-
-	filename := fileUpload.Filename
-	if filename == "" {
-		filename = fmt.Sprintf("upload-%s", repoID)
-	}
-
-	tempFile := filepath.Join(tempDir, filename)
-	file, err := os.Create(tempFile)
+	filename, file, err := s.createUploadFile(uploadID, fileUpload.Filename)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+		return "", err
 	}
+	// A partial upload is never ingested
+	complete := false
+	defer func() {
+		if !complete {
+			s.removeUploadFile(filename)
+		}
+	}()
 	defer file.Close()
 
 	totalSize := int64(0)
@@ -242,16 +269,116 @@ func (s *UploadServer) handleFileUpload(
 	// Record upload size
 	s.metrics.RecordUploadSize(totalSize)
 
+	complete = true
 	return filename, nil
 }
 
+// archiveExtensions are the archive formats ingestion can extract, longest first
+var archiveExtensions = []string{".tar.gz", ".tgz", ".tar", ".zip"}
+
+// archiveExtension returns the archive extension of a client filename, lowercased,
+// or "" when it has none ingestion can extract
+func archiveExtension(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// createUploadFile creates the temp file an upload's archive is written to: a
+// directory of its own holding <id><ext>, where only the extension comes from the
+// client's filename. It returns the path relative to the temp directory, which is
+// what ingestion is given.
+func (s *UploadServer) createUploadFile(id, clientName string) (string, *os.File, error) {
+	tempDir := s.config.Upload.TempDir
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	id = sanitizeUploadID(id)
+	uploadDir, err := os.MkdirTemp(tempDir, id+"-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	filename := filepath.Join(filepath.Base(uploadDir), id+archiveExtension(clientName))
+	file, err := os.Create(filepath.Join(tempDir, filename))
+	if err != nil {
+		os.RemoveAll(uploadDir)
+		return "", nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	return filename, file, nil
+}
+
+// removeUploadFile deletes an archive created by createUploadFile along with its
+// directory
+func (s *UploadServer) removeUploadFile(filename string) {
+	if dir := filepath.Dir(filename); dir != "." {
+		os.RemoveAll(filepath.Join(s.config.Upload.TempDir, dir))
+		return
+	}
+	os.Remove(filepath.Join(s.config.Upload.TempDir, filename))
+}
+
+// uploadIDPattern matches the characters kept in the file names of uploads
+var uploadIDPattern = regexp.MustCompile(`[^a-zA-Z0-9\-_]`)
+
+// sanitizeUploadID makes a client-supplied upload ID safe to use as a file name
+func sanitizeUploadID(id string) string {
+	if id == "" {
+		return "upload"
+	}
+	return uploadIDPattern.ReplaceAllString(id, "_")
+}
+
+// writeUploadFile streams an uploaded archive from src into the temp directory and
+// returns the name it's stored under, see createUploadFile. Partial files are
+// removed on failure.
+func (s *UploadServer) writeUploadFile(clientName, id string, src io.Reader) (string, error) {
+	filename, file, err := s.createUploadFile(id, clientName)
+	if err != nil {
+		return "", err
+	}
+
+	// Reading one byte past the limit tells an oversized file from one at the limit
+	totalSize, err := io.Copy(file, io.LimitReader(src, s.config.Upload.MaxFileSize+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && totalSize > s.config.Upload.MaxFileSize {
+		err = fmt.Errorf("file too large: exceeds limit of %d bytes", s.config.Upload.MaxFileSize)
+	}
+	if err != nil {
+		s.removeUploadFile(filename)
+		return "", err
+	}
+
+	// Record upload size
+	s.metrics.RecordUploadSize(totalSize)
+
+	return filename, nil
+}
+
+// isAllowedUploadType reports whether filename has one of the configured archive extensions
+func (s *UploadServer) isAllowedUploadType(filename string) bool {
+	for _, ext := range s.config.Upload.AllowedTypes {
+		if strings.HasSuffix(strings.ToLower(filename), strings.ToLower(ext)) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *UploadServer) GetUploadStatus(ctx context.Context, req *repocontextv1.GetUploadStatusRequest) (*repocontextv1.GetUploadStatusResponse, error) {
 	ctx, span := s.tracer.StartRPC(ctx, "GetUploadStatus")
 	defer span.End()
 
-	tenantID := req.TenantId
-	if tenantID == "" {
-		tenantID = s.config.Security.DefaultTenant
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
 	}
 
 	observability.SetSpanAttributes(span,
@@ -279,20 +406,77 @@ func (s *UploadServer) GetUploadStatus(ctx context.Context, req *repocontextv1.G
 	return response, nil
 }
 
+// CancelUpload stops the upload's ingestion and waits for it to exit. The ingestion
+// records itself as failed, so the response carries that final status.
+func (s *UploadServer) CancelUpload(ctx context.Context, req *repocontextv1.CancelUploadRequest) (*repocontextv1.CancelUploadResponse, error) {
+	ctx, span := s.tracer.StartRPC(ctx, "CancelUpload")
+	defer span.End()
+
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.TenantAttr(tenantID),
+	)
+
+	// Looking the upload up by tenant keeps callers to their own ingestions
+	uploadStatus, err := s.cache.GetUploadStatus(ctx, tenantID, req.UploadId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get upload status: %v", err)
+	}
+	if uploadStatus == nil {
+		return nil, status.Errorf(codes.NotFound, "upload not found")
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.RepositoryAttr(uploadStatus.RepositoryID),
+	)
+
+	response := &repocontextv1.CancelUploadResponse{
+		UploadId:     uploadStatus.UploadID,
+		RepositoryId: uploadStatus.RepositoryID,
+		Status:       uploadStatus.Status,
+	}
+
+	switch uploadStatus.Status.GetState() {
+	case repocontextv1.IngestionStatus_STATE_READY, repocontextv1.IngestionStatus_STATE_FAILED:
+		return response, nil
+	}
+
+	cancelled, err := s.ingestProvider.CancelIndex(ctx, tenantID, uploadStatus.RepositoryID)
+	if err != nil {
+		return nil, status.Errorf(codes.Aborted, "failed to cancel ingestion: %v", err)
+	}
+	response.Cancelled = cancelled
+
+	if current, err := s.cache.GetUploadStatus(ctx, tenantID, req.UploadId); err == nil && current != nil {
+		response.Status = current.Status
+	}
+
+	return response, nil
+}
+
 func (s *UploadServer) UploadGitRepository(ctx context.Context, req *repocontextv1.UploadGitRepositoryRequest) (*repocontextv1.UploadRepositoryResponse, error) {
 	ctx, span := s.tracer.StartRPC(ctx, "UploadGitRepository")
 	defer span.End()
 
 	// Extract tenant ID and validate
-	tenantID := req.TenantId
-	if tenantID == "" {
-		tenantID = s.config.Security.DefaultTenant
+	tenantID, err := resolveTenantID(ctx, req.TenantId, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, err
 	}
 
 	observability.SetSpanAttributes(span,
 		observability.TenantAttr(tenantID),
 	)
 
+	if err := ingest.NewChunkOptions(s.config.Defaults, req.Options).Validate(); err != nil {
+		s.metrics.RecordUploadRequest("git", "error")
+		return nil, status.Errorf(codes.InvalidArgument, "invalid upload options: %v", err)
+	}
+
 	// Generate repository ID
 	repoID := generateRepositoryID()
 	uploadID := req.IdempotencyKey
@@ -300,18 +484,18 @@ func (s *UploadServer) UploadGitRepository(ctx context.Context, req *repocontext
 		uploadID = generateUploadID()
 	}
 
-	// TEMPORARILY DISABLED: Check idempotency (disabled to force processing)
-	// This was preventing actual repository processing by returning cached empty results
-	/*
-	if existing, err := s.cache.GetUploadStatus(ctx, tenantID, uploadID); err == nil && existing != nil {
-		return &repocontextv1.UploadRepositoryResponse{
-			UploadId:     existing.UploadID,
-			RepositoryId: existing.RepositoryID,
-			AcceptedAt:   timestamppb.New(existing.CreatedAt),
-			Status:       existing.Status,
-		}, nil
+	// A retried request returns the upload it already started. Only caller-supplied
+	// keys are checked; a generated one can't have been used before.
+	if req.IdempotencyKey != "" {
+		if existing, err := s.cache.GetUploadStatus(ctx, tenantID, uploadID); err == nil && existing != nil {
+			return &repocontextv1.UploadRepositoryResponse{
+				UploadId:     existing.UploadID,
+				RepositoryId: existing.RepositoryID,
+				AcceptedAt:   timestamppb.New(existing.CreatedAt),
+				Status:       existing.Status,
+			}, nil
+		}
 	}
-	*/
 
 	timer := observability.StartTimer()
 	defer func() {
@@ -326,6 +510,9 @@ func (s *UploadServer) UploadGitRepository(ctx context.Context, req *repocontext
 	if gitRepo.Url == "" {
 		return nil, status.Errorf(codes.InvalidArgument, "git_repository.url is required")
 	}
+	if err := ingest.ValidateGitSource(gitRepo.Url, gitRepo.Ref); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid git_repository: %v", err)
+	}
 
 	// Create repository source
 	repositorySource := &repocontextv1.RepositorySource{
@@ -339,6 +526,13 @@ func (s *UploadServer) UploadGitRepository(ctx context.Context, req *repocontext
 		repositorySource.Ref = "main"
 	}
 
+	// The same commit of a repository is only indexed once unless forced
+	if !req.Force {
+		if existing := s.findIndexedRepository(ctx, tenantID, repositorySource); existing != nil {
+			return s.existingUploadResponse(ctx, tenantID, uploadID, existing)
+		}
+	}
+
 	// Create ingestion request
 	ingestReq := &ingest.CreateIndexRequest{
 		RepositoryID:   repoID,
@@ -346,39 +540,42 @@ func (s *UploadServer) UploadGitRepository(ctx context.Context, req *repocontext
 		Source:         repositorySource,
 		Options:        req.Options,
 		IdempotencyKey: uploadID,
-		ProgressCallback: func(progress *repocontextv1.IngestionProgress) {
-			// Progress callback - could be used for real-time updates
-		},
-	}
-
-	// Start ingestion
-	ingestResp, err := s.ingestProvider.CreateRepositoryIndex(ctx, ingestReq)
-	if err != nil {
-		s.metrics.RecordUploadRequest("git", "error")
-		return nil, status.Errorf(codes.Internal, "failed to start ingestion: %v", err)
 	}
 
-	// Create repository metadata for listing
+	// Create repository metadata for listing. It's stored before ingestion starts so
+	// the stats written when ingestion finishes always land on top of it.
+	acceptedAt := timestamppb.Now()
 	repository := &repocontextv1.Repository{
 		RepositoryId: repoID,
 		Name:         extractRepositoryName(gitRepo.Url),
 		Description:  fmt.Sprintf("Repository cloned from %s", gitRepo.Url),
 		Source:       repositorySource,
-		IngestionStatus: ingestResp.Status,
+		IngestionStatus: &repocontextv1.IngestionStatus{
+			State:     repocontextv1.IngestionStatus_STATE_PENDING,
+			UpdatedAt: acceptedAt,
+		},
 		Stats: &repocontextv1.RepositoryStats{
 			TotalFiles:  0,
 			TotalLines:  0,
 			TotalChunks: 0,
 			SizeBytes:   0,
 		},
-		CreatedAt: timestamppb.New(ingestResp.AcceptedAt),
-		UpdatedAt: timestamppb.New(ingestResp.AcceptedAt),
+		CreatedAt: acceptedAt,
+		UpdatedAt: acceptedAt,
 	}
 
 	// Store repository metadata in cache
 	if err := s.cache.SetRepositoryMetadata(ctx, tenantID, repository); err != nil {
 		// Log error but don't fail the upload
-		fmt.Printf("Warning: failed to store repository metadata: %v\n", err)
+		observability.Logger(ctx).Warn("UploadGitRepository: Failed to store repository metadata", observability.LogKeyRepositoryID, repository.RepositoryId, "error", err)
+	}
+
+	// Start ingestion
+	ingestResp, err := s.ingestProvider.CreateRepositoryIndex(ctx, ingestReq)
+	if err != nil {
+		s.cache.DeleteRepositoryMetadata(ctx, tenantID, repoID)
+		s.metrics.RecordUploadRequest("git", "error")
+		return nil, ingestionStartError(err)
 	}
 
 	s.metrics.RecordUploadRequest("git", "success")
@@ -398,6 +595,65 @@ func (s *UploadServer) UploadGitRepository(ctx context.Context, req *repocontext
 	return response, nil
 }
 
+// gitResolveTimeout bounds the remote lookup used to deduplicate git uploads
+const gitResolveTimeout = 30 * time.Second
+
+// findIndexedRepository returns the ready repository already indexed from the commit
+// source resolves to, or nil. Deduplication is skipped if the commit can't be resolved.
+func (s *UploadServer) findIndexedRepository(ctx context.Context, tenantID string, source *repocontextv1.RepositorySource) *repocontextv1.Repository {
+	resolveCtx, cancel := context.WithTimeout(ctx, gitResolveTimeout)
+	defer cancel()
+
+	commitSHA, err := ingest.ResolveGitCommit(resolveCtx, source.GetGitUrl(), source.Ref)
+	if err != nil {
+		observability.Logger(ctx).Warn("findIndexedRepository: Failed to resolve commit, not deduplicating", "git_url", source.GetGitUrl(), "error", err)
+		return nil
+	}
+
+	resolved := proto.Clone(source).(*repocontextv1.RepositorySource)
+	resolved.CommitSha = commitSHA
+	repoID, err := s.cache.GetRepositoryIndex(ctx, tenantID, generateRepoKeyFromSource(resolved))
+	if err != nil || repoID == "" {
+		return nil
+	}
+
+	repository, err := s.cache.GetRepositoryMetadata(ctx, tenantID, repoID)
+	if err != nil || repository == nil {
+		return nil
+	}
+	if repository.IngestionStatus.GetState() != repocontextv1.IngestionStatus_STATE_READY {
+		return nil
+	}
+	return repository
+}
+
+// existingUploadResponse answers a deduplicated upload with the existing repository.
+// The upload is recorded as complete so GetUploadStatus can track it like any other.
+func (s *UploadServer) existingUploadResponse(ctx context.Context, tenantID, uploadID string, repository *repocontextv1.Repository) (*repocontextv1.UploadRepositoryResponse, error) {
+	acceptedAt := time.Now()
+	cachedStatus := &cache.CachedUploadStatus{
+		UploadID:     uploadID,
+		RepositoryID: repository.RepositoryId,
+		Status:       repository.IngestionStatus,
+		Progress: &repocontextv1.IngestionProgress{
+			ProgressPercent: 100,
+		},
+		CreatedAt: acceptedAt,
+	}
+	if err := s.cache.SetUploadStatus(ctx, tenantID, cachedStatus); err != nil {
+		observability.Logger(ctx).Warn("existingUploadResponse: Failed to store upload status", observability.LogKeyUploadID, uploadID, "error", err)
+	}
+
+	s.metrics.RecordUploadRequest("git", "deduplicated")
+
+	return &repocontextv1.UploadRepositoryResponse{
+		UploadId:     uploadID,
+		RepositoryId: repository.RepositoryId,
+		AcceptedAt:   timestamppb.New(acceptedAt),
+		Status:       repository.IngestionStatus,
+	}, nil
+}
+
 // Helper functions
 
 func generateRepositoryID() string {
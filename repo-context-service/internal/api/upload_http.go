@@ -0,0 +1,246 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"repo-context-service/internal/ingest"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+	"github.com/gorilla/mux"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxUploadFieldSize bounds each non-file form field of a multipart upload
+const maxUploadFieldSize = 64 * 1024
+
+// UploadHTTPHandler accepts repository archives as multipart/form-data, for clients
+// that can't use the client-streaming UploadRepository RPC. Uploads are ingested
+// exactly as they are through gRPC.
+type UploadHTTPHandler struct {
+	uploadServer *UploadServer
+	metrics      *observability.Metrics
+	tracer       *observability.Tracer
+}
+
+func NewUploadHTTPHandler(
+	uploadServer *UploadServer,
+	metrics *observability.Metrics,
+	tracer *observability.Tracer,
+) *UploadHTTPHandler {
+	return &UploadHTTPHandler{
+		uploadServer: uploadServer,
+		metrics:      metrics,
+		tracer:       tracer,
+	}
+}
+
+func (h *UploadHTTPHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/v1/upload/archive", h.HandleUpload).Methods("POST")
+}
+
+// uploadIdleTimeout is how long an upload can go without sending any of its body
+const uploadIdleTimeout = 30 * time.Second
+
+// idleTimeoutBody extends the connection's read deadline by timeout before each read,
+// so a slow upload can take as long as it needs while a stalled one is cut off
+type idleTimeoutBody struct {
+	io.ReadCloser
+	controller *http.ResponseController
+	timeout    time.Duration
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	if err := b.controller.SetReadDeadline(time.Now().Add(b.timeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return 0, fmt.Errorf("failed to extend read deadline: %w", err)
+	}
+	return b.ReadCloser.Read(p)
+}
+
+// uploadForm holds the fields of a multipart upload
+type uploadForm struct {
+	filename       string
+	tenantID       string
+	idempotencyKey string
+	options        *repocontextv1.UploadOptions
+}
+
+// HandleUpload stores the "file" part in the temp directory and starts ingesting it.
+// The optional "tenant_id", "idempotency_key" and "options" (UploadOptions as JSON)
+// fields may come before or after the file.
+func (h *UploadHTTPHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.tracer.StartRPC(r.Context(), "UploadArchive")
+	defer span.End()
+
+	s := h.uploadServer
+
+	// Large archives take longer to send than the server's read timeout allows, so
+	// the deadline is pushed back as the body arrives instead
+	r.Body = &idleTimeoutBody{
+		ReadCloser: r.Body,
+		controller: http.NewResponseController(w),
+		timeout:    uploadIdleTimeout,
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.config.Upload.MaxFileSize+maxUploadFieldSize*4)
+
+	repoID := generateRepositoryID()
+	form, err := h.readForm(r, repoID)
+	if err != nil {
+		h.metrics.RecordUploadRequest("file", "error")
+		writeHTTPError(w, err)
+		return
+	}
+
+	response, started, err := h.startUpload(ctx, form, repoID)
+	if !started {
+		// Nothing will ingest the archive
+		s.removeUploadFile(form.filename)
+	}
+	if err != nil {
+		h.metrics.RecordUploadRequest("file", "error")
+		writeHTTPError(w, err)
+		return
+	}
+
+	h.metrics.RecordUploadRequest("file", "success")
+	observability.SetSpanAttributes(span,
+		observability.RepositoryAttr(response.RepositoryId),
+	)
+
+	body, err := protojson.Marshal(response)
+	if err != nil {
+		writeHTTPError(w, status.Errorf(codes.Internal, "failed to encode response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// readForm reads the multipart body, streaming the file part to the temp directory
+func (h *UploadHTTPHandler) readForm(r *http.Request, repoID string) (*uploadForm, error) {
+	s := h.uploadServer
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "expected a multipart/form-data body: %v", err)
+	}
+
+	form := &uploadForm{}
+	fail := func(err error) (*uploadForm, error) {
+		if form.filename != "" {
+			s.removeUploadFile(form.filename)
+		}
+		return nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(status.Errorf(codes.InvalidArgument, "failed to read form: %v", err))
+		}
+
+		switch part.FormName() {
+		case "file":
+			if form.filename != "" {
+				return fail(status.Errorf(codes.InvalidArgument, "only one file can be uploaded"))
+			}
+			if !s.isAllowedUploadType(part.FileName()) {
+				return fail(status.Errorf(codes.InvalidArgument, "unsupported file type %q; expected one of %v", part.FileName(), s.config.Upload.AllowedTypes))
+			}
+			// The idempotency key can follow the file, so the archive is named after
+			// the repository
+			filename, err := s.writeUploadFile(part.FileName(), repoID, part)
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					return fail(status.Errorf(codes.InvalidArgument, "file too large: exceeds limit of %d bytes", s.config.Upload.MaxFileSize))
+				}
+				return fail(status.Errorf(codes.InvalidArgument, "file upload failed: %v", err))
+			}
+			form.filename = filename
+
+		case "tenant_id", "idempotency_key", "options":
+			value, err := io.ReadAll(io.LimitReader(part, maxUploadFieldSize+1))
+			if err != nil {
+				return fail(status.Errorf(codes.InvalidArgument, "failed to read %s: %v", part.FormName(), err))
+			}
+			if len(value) > maxUploadFieldSize {
+				return fail(status.Errorf(codes.InvalidArgument, "%s is too long", part.FormName()))
+			}
+
+			switch part.FormName() {
+			case "tenant_id":
+				form.tenantID = string(value)
+			case "idempotency_key":
+				form.idempotencyKey = string(value)
+			case "options":
+				form.options = &repocontextv1.UploadOptions{}
+				if err := protojson.Unmarshal(value, form.options); err != nil {
+					return fail(status.Errorf(codes.InvalidArgument, "invalid options: %v", err))
+				}
+			}
+		}
+		part.Close()
+	}
+
+	if form.filename == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "file is required")
+	}
+	return form, nil
+}
+
+// startUpload validates the form fields and starts ingesting the stored archive. It
+// reports whether ingestion took ownership of the archive.
+func (h *UploadHTTPHandler) startUpload(ctx context.Context, form *uploadForm, repoID string) (*repocontextv1.UploadRepositoryResponse, bool, error) {
+	s := h.uploadServer
+
+	tenantID, err := resolveTenantID(ctx, form.tenantID, s.config.Security.DefaultTenant)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := ingest.NewChunkOptions(s.config.Defaults, form.options).Validate(); err != nil {
+		return nil, false, status.Errorf(codes.InvalidArgument, "invalid upload options: %v", err)
+	}
+
+	uploadID := form.idempotencyKey
+	if uploadID == "" {
+		uploadID = generateUploadID()
+	} else if existing, err := s.cache.GetUploadStatus(ctx, tenantID, uploadID); err == nil && existing != nil {
+		// A retried request returns the upload it already started
+		return &repocontextv1.UploadRepositoryResponse{
+			UploadId:     existing.UploadID,
+			RepositoryId: existing.RepositoryID,
+			AcceptedAt:   timestamppb.New(existing.CreatedAt),
+			Status:       existing.Status,
+		}, false, nil
+	}
+
+	source := &repocontextv1.RepositorySource{
+		Source: &repocontextv1.RepositorySource_UploadedFilename{
+			UploadedFilename: form.filename,
+		},
+		Ref: "main",
+	}
+
+	// startIngestion removes the archive itself if ingestion doesn't start
+	response, err := s.startIngestion(ctx, tenantID, repoID, uploadID, source, form.options)
+	return response, true, err
+}
+
+// writeHTTPError writes a gRPC status error with the status code the gateway would use
+func writeHTTPError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	http.Error(w, st.Message(), runtime.HTTPStatusFromCode(st.Code()))
+}
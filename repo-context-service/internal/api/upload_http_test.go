@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// multipartUpload returns a multipart body holding fields and, unless filename is
+// empty, a file part
+func multipartUpload(t *testing.T, filename string, file []byte, fields map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := w.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if filename != "" {
+		part, err := w.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(file)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &body, w.FormDataContentType()
+}
+
+func TestHandleUpload(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Upload.MaxFileSize = 4096
+	provider := &recordingProvider{}
+	handler := NewUploadHTTPHandler(NewUploadServer(cfg, newTestCache(t), provider, observability.NewMetrics(), observability.NewNoOpTracer()),
+		observability.NewMetrics(), observability.NewNoOpTracer())
+	archive := zipArchive(t, map[string]string{"main.go": "package main\n"})
+
+	tests := []struct {
+		name     string
+		filename string
+		file     []byte
+		fields   map[string]string
+		wantCode int
+	}{
+		{"small archive", "repo.zip", archive, map[string]string{"options": `{"chunkSize": 50}`}, http.StatusOK},
+		{"over the file limit", "repo.zip", bytes.Repeat([]byte{'x'}, 4097), nil, http.StatusBadRequest},
+		{"over the body limit", "repo.zip", bytes.Repeat([]byte{'x'}, 4096+4*maxUploadFieldSize+1), nil, http.StatusBadRequest},
+		{"unsupported type", "repo.rar", archive, nil, http.StatusBadRequest},
+		{"no file", "", nil, map[string]string{"tenant_id": "default"}, http.StatusBadRequest},
+		{"invalid options", "repo.zip", archive, map[string]string{"options": `{"chunkSize": "many"}`}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider.requests = nil
+			before, _ := os.ReadDir(cfg.Upload.TempDir)
+			body, contentType := multipartUpload(t, tt.filename, tt.file, tt.fields)
+			req := httptest.NewRequest(http.MethodPost, "/v1/upload/archive", body)
+			req.Header.Set("Content-Type", contentType)
+			rec := httptest.NewRecorder()
+
+			handler.HandleUpload(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d %q, want %d", rec.Code, rec.Body.String(), tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				if len(provider.requests) != 0 {
+					t.Error("a rejected upload started an ingestion")
+				}
+				if after, _ := os.ReadDir(cfg.Upload.TempDir); len(after) != len(before) {
+					t.Errorf("rejected upload left %d entries in the temp directory", len(after)-len(before))
+				}
+				return
+			}
+
+			var resp repocontextv1.UploadRepositoryResponse
+			if err := protojson.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("response %q: %v", rec.Body.String(), err)
+			}
+			if len(provider.requests) != 1 {
+				t.Fatalf("%d ingestions started, want 1", len(provider.requests))
+			}
+			started := provider.requests[0]
+			if started.RepositoryID != resp.RepositoryId || started.IdempotencyKey != resp.UploadId {
+				t.Errorf("ingestion of %s under %s, response says %s under %s", started.RepositoryID, started.IdempotencyKey, resp.RepositoryId, resp.UploadId)
+			}
+			if started.Options.GetChunkSize() != 50 {
+				t.Errorf("chunk size = %d, want the 50 from the form", started.Options.GetChunkSize())
+			}
+			stored, err := os.ReadFile(filepath.Join(cfg.Upload.TempDir, started.Source.GetUploadedFilename()))
+			if err != nil || !bytes.Equal(stored, tt.file) {
+				t.Errorf("stored archive = %d bytes, %v; want the %d uploaded", len(stored), err, len(tt.file))
+			}
+			if !strings.HasSuffix(started.Source.GetUploadedFilename(), ".zip") {
+				t.Errorf("archive stored as %q, want a .zip", started.Source.GetUploadedFilename())
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/cache"
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/ingest"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestCache returns a RedisCache backed by an in-memory Redis
+func newTestCache(t *testing.T) *cache.RedisCache {
+	t.Helper()
+	redisCache, err := cache.NewRedisCache(cache.RedisOptions{URL: "redis://" + miniredis.RunT(t).Addr()}, cache.TTLConfig{
+		RepositoryRouting: time.Hour,
+		QueryResults:      time.Hour,
+		UploadStatus:      time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { redisCache.Close() })
+	return redisCache
+}
+
+// recordingProvider accepts every ingestion without running it
+type recordingProvider struct {
+	ingest.Provider
+	requests []*ingest.CreateIndexRequest
+}
+
+func (p *recordingProvider) CreateRepositoryIndex(ctx context.Context, req *ingest.CreateIndexRequest) (*ingest.CreateIndexResponse, error) {
+	p.requests = append(p.requests, req)
+	return &ingest.CreateIndexResponse{
+		RepositoryID: req.RepositoryID,
+		Status:       &repocontextv1.IngestionStatus{State: repocontextv1.IngestionStatus_STATE_PENDING},
+		AcceptedAt:   time.Now(),
+	}, nil
+}
+
+func TestWriteUploadFileIgnoresClientName(t *testing.T) {
+	tempDir := t.TempDir()
+	s := &UploadServer{
+		config:  &config.Config{Upload: config.UploadConfig{TempDir: tempDir, MaxFileSize: 1024}},
+		metrics: observability.NewMetrics(),
+	}
+
+	tests := []struct {
+		name       string
+		clientName string
+		id         string
+		wantBase   string
+	}{
+		{"zip", "repo.zip", "upload-1", "upload-1.zip"},
+		{"uppercase extension", "REPO.TAR.GZ", "upload-2", "upload-2.tar.gz"},
+		{"traversal in name", "../../etc/cron.d/x.zip", "upload-3", "upload-3.zip"},
+		{"traversal in id", "repo.tgz", "../../evil", "______evil.tgz"},
+		{"no extension", "README", "upload-4", "upload-4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename, err := s.writeUploadFile(tt.clientName, tt.id, strings.NewReader("data"))
+			if err != nil {
+				t.Fatalf("writeUploadFile error = %v", err)
+			}
+			if got := filepath.Base(filename); got != tt.wantBase {
+				t.Errorf("archive stored as %q, want %q", got, tt.wantBase)
+			}
+			dir := filepath.Dir(filename)
+			if dir == "." || strings.Contains(dir, string(filepath.Separator)) || strings.HasPrefix(dir, "..") {
+				t.Fatalf("archive %q isn't in a directory of its own under the temp directory", filename)
+			}
+			if _, err := os.Stat(filepath.Join(tempDir, filename)); err != nil {
+				t.Fatalf("archive not written: %v", err)
+			}
+
+			s.removeUploadFile(filename)
+			if _, err := os.Stat(filepath.Join(tempDir, dir)); !os.IsNotExist(err) {
+				t.Errorf("upload directory left behind: %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteUploadFileRemovesOversizedUpload(t *testing.T) {
+	tempDir := t.TempDir()
+	s := &UploadServer{
+		config:  &config.Config{Upload: config.UploadConfig{TempDir: tempDir, MaxFileSize: 4}},
+		metrics: observability.NewMetrics(),
+	}
+
+	if _, err := s.writeUploadFile("repo.zip", "upload-1", strings.NewReader("too large")); err == nil {
+		t.Fatal("expected an error for an upload over MaxFileSize")
+	}
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("temp directory not cleaned up: %d entries left", len(entries))
+	}
+}
+
+func TestUploadGitRepositoryDeduplicatesIndexedCommit(t *testing.T) {
+	const (
+		gitURL    = "https://github.com/user/repo.git"
+		commitSHA = "0123456789abcdef0123456789abcdef01234567"
+	)
+
+	tests := []struct {
+		name        string
+		state       repocontextv1.IngestionStatus_State
+		force       bool
+		wantIngests int
+	}{
+		{"indexed commit", repocontextv1.IngestionStatus_STATE_READY, false, 0},
+		{"forced", repocontextv1.IngestionStatus_STATE_READY, true, 1},
+		{"still ingesting", repocontextv1.IngestionStatus_STATE_EMBEDDING, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			redisCache := newTestCache(t)
+			provider := &recordingProvider{}
+			cfg := &config.Config{}
+			cfg.Security.DefaultTenant = "default"
+			cfg.Defaults.ChunkSize = 100
+			s := NewUploadServer(cfg, redisCache, provider, observability.NewMetrics(), observability.NewNoOpTracer())
+
+			source := &repocontextv1.RepositorySource{Source: &repocontextv1.RepositorySource_GitUrl{GitUrl: gitURL}, CommitSha: commitSHA}
+			if err := redisCache.SetRepositoryIndex(ctx, "default", generateRepoKeyFromSource(source), "repo-existing"); err != nil {
+				t.Fatal(err)
+			}
+			existing := &repocontextv1.Repository{RepositoryId: "repo-existing", IngestionStatus: &repocontextv1.IngestionStatus{State: tt.state}}
+			if err := redisCache.SetRepositoryMetadata(ctx, "default", existing); err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := s.UploadGitRepository(ctx, &repocontextv1.UploadGitRepositoryRequest{
+				GitRepository: &repocontextv1.GitRepository{Url: gitURL, Ref: commitSHA},
+				Force:         tt.force,
+			})
+			if err != nil {
+				t.Fatalf("UploadGitRepository error = %v", err)
+			}
+			if len(provider.requests) != tt.wantIngests {
+				t.Fatalf("ingestions started = %d, want %d", len(provider.requests), tt.wantIngests)
+			}
+			if tt.wantIngests > 0 {
+				if resp.RepositoryId == "repo-existing" {
+					t.Error("re-ingestion reused the existing repository ID")
+				}
+				return
+			}
+
+			if resp.RepositoryId != "repo-existing" || resp.Status.GetState() != repocontextv1.IngestionStatus_STATE_READY {
+				t.Errorf("response = %+v, want the ready existing repository", resp)
+			}
+			uploadStatus, err := redisCache.GetUploadStatus(ctx, "default", resp.UploadId)
+			if err != nil || uploadStatus == nil || uploadStatus.RepositoryID != "repo-existing" {
+				t.Errorf("deduplicated upload status = %+v, %v; want it recorded against the existing repository", uploadStatus, err)
+			}
+		})
+	}
+}
+
+func TestUploadedArchiveRemovedAfterIngestion(t *testing.T) {
+	cfg := newTestConfig(t)
+	redisCache := newTestCache(t)
+	ip := newTestInlineProcessor(t, cfg, redisCache, &gatedEmbeddings{}, newMemoryVectorStore())
+	uploads := NewUploadServer(cfg, redisCache, ip, observability.NewMetrics(), observability.NewNoOpTracer())
+
+	archive := uploadArchive(t, uploads, "repo-1", map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	if status := waitForState(t, ip, "repo-1", repocontextv1.IngestionStatus_STATE_READY, repocontextv1.IngestionStatus_STATE_FAILED); status.State != repocontextv1.IngestionStatus_STATE_READY {
+		t.Fatalf("ingestion = %v %q, want READY", status.State, status.ErrorMessage)
+	}
+	if _, err := os.Stat(filepath.Dir(archive)); !os.IsNotExist(err) {
+		t.Errorf("upload directory left behind after ingestion: %v", err)
+	}
+
+	// An archive that can't be extracted is removed all the same
+	filename, err := uploads.writeUploadFile("repo.zip", "repo-2", strings.NewReader("not a zip archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &repocontextv1.RepositorySource{Source: &repocontextv1.RepositorySource_UploadedFilename{UploadedFilename: filename}}
+	if _, err := uploads.startIngestion(context.Background(), "default", "repo-2", "upload-repo-2", source, nil); err != nil {
+		t.Fatal(err)
+	}
+	if status := waitForState(t, ip, "repo-2", repocontextv1.IngestionStatus_STATE_READY, repocontextv1.IngestionStatus_STATE_FAILED); status.State != repocontextv1.IngestionStatus_STATE_FAILED {
+		t.Fatalf("ingestion of a corrupt archive = %v, want FAILED", status.State)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Upload.TempDir, filepath.Dir(filename))); !os.IsNotExist(err) {
+		t.Errorf("upload directory left behind after a failed ingestion: %v", err)
+	}
+}
@@ -3,7 +3,7 @@ package api
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
@@ -11,8 +11,10 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 
 	"repo-context-service/internal/config"
+	"repo-context-service/internal/interceptors"
 	"repo-context-service/internal/observability"
 	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
 )
@@ -31,8 +33,28 @@ type WSChatStart struct {
 }
 
 type WSChatMessage struct {
-	Query     string `json:"query"`
-	SessionID string `json:"session_id"`
+	Query          string            `json:"query"`
+	SessionID      string            `json:"session_id"`
+	Filters        *WSSearchFilters  `json:"filters,omitempty"`
+	LexicalOptions *WSLexicalOptions `json:"lexical_options,omitempty"`
+}
+
+// WSSearchFilters restricts search to some languages, file globs (a leading !
+// excludes) or a path prefix
+type WSSearchFilters struct {
+	Languages    []string `json:"languages,omitempty"`
+	FilePatterns []string `json:"file_patterns,omitempty"`
+	PathPrefix   string   `json:"path_prefix,omitempty"`
+}
+
+// WSLexicalOptions asks for an exact (literal, regex, case-sensitive or whole-word)
+// lexical match instead of fuzzy term expansion, and sets the lines of context
+// around each match
+type WSLexicalOptions struct {
+	Regex         bool   `json:"regex"`
+	CaseSensitive bool   `json:"case_sensitive"`
+	WholeWord     bool   `json:"whole_word"`
+	ContextLines  *int32 `json:"context_lines,omitempty"`
 }
 
 type WSChatCancel struct {
@@ -40,9 +62,20 @@ type WSChatCancel struct {
 }
 
 type WSChatOptions struct {
-	MaxResults   int32  `json:"max_results"`
-	StreamTokens bool   `json:"stream_tokens"`
-	Model        string `json:"model"`
+	MaxResults   int32            `json:"max_results"`
+	StreamTokens bool             `json:"stream_tokens"`
+	Model        string           `json:"model"`
+	SearchMode   string           `json:"search_mode"` // "both" (default), "lexical" or "semantic"
+	ContextLines int32            `json:"context_lines"`
+	Filters      *WSSearchFilters `json:"filters,omitempty"`
+}
+
+// wsSearchModes maps the WebSocket search_mode values to the gRPC enum
+var wsSearchModes = map[string]repocontextv1.SearchMode{
+	"":         repocontextv1.SearchMode_SEARCH_MODE_UNSPECIFIED,
+	"both":     repocontextv1.SearchMode_SEARCH_MODE_BOTH,
+	"lexical":  repocontextv1.SearchMode_SEARCH_MODE_LEXICAL,
+	"semantic": repocontextv1.SearchMode_SEARCH_MODE_SEMANTIC,
 }
 
 // WebSocket response types that match JavaScript client expectations
@@ -82,9 +115,10 @@ type WSCompositionToken struct {
 }
 
 type WSCompositionComplete struct {
-	SessionID    string `json:"session_id"`
-	QueryID      string `json:"query_id"`
-	FullResponse string `json:"full_response"`
+	SessionID    string        `json:"session_id"`
+	QueryID      string        `json:"query_id"`
+	FullResponse string        `json:"full_response"`
+	Usage        *WSTokenUsage `json:"usage,omitempty"`
 }
 
 type WSError struct {
@@ -94,8 +128,15 @@ type WSError struct {
 }
 
 type WSComplete struct {
-	SessionID string `json:"session_id"`
-	QueryID   string `json:"query_id"`
+	SessionID string        `json:"session_id"`
+	QueryID   string        `json:"query_id"`
+	Usage     *WSTokenUsage `json:"usage,omitempty"`
+}
+
+type WSTokenUsage struct {
+	PromptTokens     int32 `json:"prompt_tokens"`
+	CompletionTokens int32 `json:"completion_tokens"`
+	TotalTokens      int32 `json:"total_tokens"`
 }
 
 type WSCodeChunk struct {
@@ -112,17 +153,24 @@ type ChatWebSocketHandler struct {
 	upgrader   websocket.Upgrader
 	chatServer *ChatServer
 	config     *config.Config
-	metrics    *observability.Metrics
-	tracer     *observability.Tracer
+	// How the bridge dials the gRPC server, with TLS when the server serves it
+	grpcDialOptions []grpc.DialOption
+	metrics         *observability.Metrics
+	tracer          *observability.Tracer
 
 	// Connection management
 	connections map[string]*websocket.Conn
 	connMutex   sync.RWMutex
+	// Handlers of registered connections still running, and whether Shutdown has
+	// started (new connections are refused from then on)
+	active       sync.WaitGroup
+	shuttingDown bool
 }
 
 func NewChatWebSocketHandler(
 	chatServer *ChatServer,
 	cfg *config.Config,
+	grpcDialOptions []grpc.DialOption,
 	metrics *observability.Metrics,
 	tracer *observability.Tracer,
 ) *ChatWebSocketHandler {
@@ -136,11 +184,12 @@ func NewChatWebSocketHandler(
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
 		},
-		chatServer:  chatServer,
-		config:      cfg,
-		metrics:     metrics,
-		tracer:      tracer,
-		connections: make(map[string]*websocket.Conn),
+		chatServer:      chatServer,
+		config:          cfg,
+		grpcDialOptions: grpcDialOptions,
+		metrics:         metrics,
+		tracer:          tracer,
+		connections:     make(map[string]*websocket.Conn),
 	}
 }
 
@@ -157,42 +206,148 @@ func (h *ChatWebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Upgrade HTTP connection to WebSocket
-	conn, err := h.upgrader.Upgrade(w, r, nil)
+	h.connMutex.RLock()
+	shuttingDown := h.shuttingDown
+	h.connMutex.RUnlock()
+	if shuttingDown {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Upgrade HTTP connection to WebSocket. The handshake response replaces the one
+	// headers were set on, so the request ID is returned again
+	requestID := observability.RequestIDFromContext(r.Context())
+	var responseHeader http.Header
+	if requestID != "" {
+		responseHeader = http.Header{}
+		responseHeader.Set(interceptors.RequestIDHeader, requestID)
+	}
+	conn, err := h.upgrader.Upgrade(w, r, responseHeader)
 	if err != nil {
-		log.Printf("WebSocket upgrade failed: %v", err)
+		observability.Logger(r.Context()).Warn("HandleWebSocket: Upgrade failed", "error", err)
 		return
 	}
 
 	connID := fmt.Sprintf("%s_%d", repositoryID, time.Now().UnixNano())
 
-	// Register connection
+	// Register connection, unless Shutdown started during the upgrade
 	h.connMutex.Lock()
+	if h.shuttingDown {
+		h.connMutex.Unlock()
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(time.Second))
+		conn.Close()
+		return
+	}
 	h.connections[connID] = conn
+	h.active.Add(1)
 	h.connMutex.Unlock()
+	h.metrics.IncActiveWebSocketConnections()
 
 	// Clean up on exit
 	defer func() {
 		h.connMutex.Lock()
 		delete(h.connections, connID)
 		h.connMutex.Unlock()
+		h.metrics.DecActiveWebSocketConnections()
 		conn.Close()
+		h.active.Done()
 	}()
 
-	log.Printf("WebSocket connection established for repository: %s", repositoryID)
+	observability.Logger(r.Context()).Info("HandleWebSocket: Connection established", observability.LogKeyRepositoryID, repositoryID)
+
+	// Keep the connection alive; a peer that stops answering pings hits the read deadline
+	stopKeepalive := make(chan struct{})
+	defer close(stopKeepalive)
+	h.startKeepalive(conn, stopKeepalive)
 
 	// Handle the WebSocket connection
-	h.handleConnection(conn, repositoryID)
+	h.handleConnection(conn, repositoryID, requestID)
+}
+
+// startKeepalive arms the read deadline, refreshes it whenever a pong arrives
+// and pings the client every WebSocketPingInterval until stop is closed.
+func (h *ChatWebSocketHandler) startKeepalive(conn *websocket.Conn, stop <-chan struct{}) {
+	pingInterval := h.config.Server.WebSocketPingInterval
+	pongTimeout := h.config.Server.WebSocketPongTimeout
+
+	conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				deadline := time.Now().Add(pingInterval)
+				if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+					slog.Info("startKeepalive: Ping failed, closing connection", "error", err)
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
 }
 
-func (h *ChatWebSocketHandler) handleConnection(conn *websocket.Conn, repositoryID string) {
+// Shutdown sends a close frame to every open chat connection and waits for their
+// handlers to finish. Connections still open when ctx is done are closed outright.
+// Connections arriving after Shutdown starts are refused.
+func (h *ChatWebSocketHandler) Shutdown(ctx context.Context) error {
+	h.connMutex.Lock()
+	h.shuttingDown = true
+	conns := make([]*websocket.Conn, 0, len(h.connections))
+	for _, conn := range h.connections {
+		conns = append(conns, conn)
+	}
+	h.connMutex.Unlock()
+
+	slog.Info("Shutdown: Closing WebSocket connections", "connections", len(conns))
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for _, conn := range conns {
+		if err := conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second)); err != nil {
+			slog.Warn("Shutdown: Failed to send WebSocket close frame", "error", err)
+		}
+	}
+
+	// The client answers the close frame, which ends the handler's read loop
+	drained := make(chan struct{})
+	go func() {
+		h.active.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		h.connMutex.RLock()
+		for _, conn := range h.connections {
+			conn.Close()
+		}
+		h.connMutex.RUnlock()
+		return ctx.Err()
+	}
+}
+
+func (h *ChatWebSocketHandler) handleConnection(conn *websocket.Conn, repositoryID, requestID string) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	// The chat RPC runs under the same request ID as the WebSocket handshake
+	if requestID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, interceptors.RequestIDHeader, requestID)
+	}
 
 	// Create gRPC client stream
-	grpcConn, err := grpc.DialContext(ctx, "localhost:9090", grpc.WithInsecure())
+	grpcTarget := fmt.Sprintf("localhost:%d", h.config.Server.GRPCPort)
+	grpcConn, err := grpc.DialContext(ctx, grpcTarget, h.grpcDialOptions...)
 	if err != nil {
-		log.Printf("Failed to connect to gRPC server: %v", err)
+		slog.Error("handleConnection: Failed to connect to gRPC server", observability.LogKeyRequestID, requestID, "error", err)
 		h.sendError(conn, "", "connection_failed", "Failed to connect to chat service")
 		return
 	}
@@ -201,13 +356,14 @@ func (h *ChatWebSocketHandler) handleConnection(conn *websocket.Conn, repository
 	client := repocontextv1.NewChatServiceClient(grpcConn)
 	stream, err := client.ChatWithRepository(ctx)
 	if err != nil {
-		log.Printf("Failed to create gRPC stream: %v", err)
+		slog.Error("handleConnection: Failed to create gRPC stream", observability.LogKeyRequestID, requestID, "error", err)
 		h.sendError(conn, "", "stream_failed", "Failed to create chat stream")
 		return
 	}
 
-	// Channel for coordinating goroutines
-	done := make(chan bool)
+	// Channel for coordinating goroutines; both send on it when they stop, so it is
+	// buffered for the one whose signal nobody waits for
+	done := make(chan bool, 2)
 
 	// Goroutine to read gRPC responses and send to WebSocket
 	go h.grpcToWebSocket(stream, conn, done)
@@ -232,7 +388,7 @@ func (h *ChatWebSocketHandler) webSocketToGRPC(
 		err := wsConn.ReadJSON(&wsMsg)
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket read error: %v", err)
+				slog.Warn("webSocketToGRPC: WebSocket read failed", "error", err)
 			}
 			return
 		}
@@ -241,16 +397,28 @@ func (h *ChatWebSocketHandler) webSocketToGRPC(
 		var grpcReq *repocontextv1.ChatRequest
 
 		if wsMsg.Start != nil {
+			var options *repocontextv1.ChatOptions
+			if wsMsg.Start.Options != nil {
+				searchMode, ok := wsSearchModes[wsMsg.Start.Options.SearchMode]
+				if !ok {
+					h.sendError(wsConn, "", "invalid_argument", "search_mode must be one of both, lexical or semantic")
+					continue
+				}
+				options = &repocontextv1.ChatOptions{
+					MaxResults:   wsMsg.Start.Options.MaxResults,
+					StreamTokens: wsMsg.Start.Options.StreamTokens,
+					Model:        wsMsg.Start.Options.Model,
+					SearchMode:   searchMode,
+					ContextLines: wsMsg.Start.Options.ContextLines,
+					Filters:      searchFiltersFromWS(wsMsg.Start.Options.Filters),
+				}
+			}
 			grpcReq = &repocontextv1.ChatRequest{
 				Message: &repocontextv1.ChatRequest_Start{
 					Start: &repocontextv1.ChatStart{
 						RepositoryId: wsMsg.Start.RepositoryID,
 						TenantId:     wsMsg.Start.TenantID,
-						Options: &repocontextv1.ChatOptions{
-							MaxResults:   wsMsg.Start.Options.MaxResults,
-							StreamTokens: wsMsg.Start.Options.StreamTokens,
-							Model:        wsMsg.Start.Options.Model,
-						},
+						Options:      options,
 					},
 				},
 			}
@@ -258,8 +426,10 @@ func (h *ChatWebSocketHandler) webSocketToGRPC(
 			grpcReq = &repocontextv1.ChatRequest{
 				Message: &repocontextv1.ChatRequest_ChatMessage{
 					ChatMessage: &repocontextv1.ChatMessage{
-						Query:     wsMsg.ChatMessage.Query,
-						SessionId: wsMsg.ChatMessage.SessionID,
+						Query:          wsMsg.ChatMessage.Query,
+						SessionId:      wsMsg.ChatMessage.SessionID,
+						Filters:        searchFiltersFromWS(wsMsg.ChatMessage.Filters),
+						LexicalOptions: lexicalOptionsFromWS(wsMsg.ChatMessage.LexicalOptions),
 					},
 				},
 			}
@@ -272,14 +442,14 @@ func (h *ChatWebSocketHandler) webSocketToGRPC(
 				},
 			}
 		} else {
-			log.Printf("Unknown WebSocket message type")
+			slog.Warn("webSocketToGRPC: Unknown WebSocket message type")
 			continue
 		}
 
 		// Send to gRPC stream
 		err = grpcStream.Send(grpcReq)
 		if err != nil {
-			log.Printf("gRPC send error: %v", err)
+			slog.Warn("webSocketToGRPC: gRPC send failed", "error", err)
 			return
 		}
 	}
@@ -302,24 +472,24 @@ func (h *ChatWebSocketHandler) grpcToWebSocket(
 			// Read from gRPC stream
 			grpcResp, err := grpcStream.Recv()
 			if err != nil {
-				log.Printf("gRPC receive error: %v", err)
+				slog.Warn("grpcToWebSocket: gRPC receive failed", "error", err)
 				return
 			}
 
 			// Convert gRPC response to WebSocket response
-			wsResp := h.convertGRPCToWebSocket(grpcResp)
+			wsResp := convertGRPCToWebSocket(grpcResp)
 
 			// Send to WebSocket
 			err = wsConn.WriteJSON(wsResp)
 			if err != nil {
-				log.Printf("WebSocket write error: %v", err)
+				slog.Warn("grpcToWebSocket: WebSocket write failed", "error", err)
 				return
 			}
 		}
 	}
 }
 
-func (h *ChatWebSocketHandler) convertGRPCToWebSocket(grpcResp *repocontextv1.ChatResponse) *WSResponse {
+func convertGRPCToWebSocket(grpcResp *repocontextv1.ChatResponse) *WSResponse {
 	wsResp := &WSResponse{}
 
 	switch msg := grpcResp.Message.(type) {
@@ -340,7 +510,7 @@ func (h *ChatWebSocketHandler) convertGRPCToWebSocket(grpcResp *repocontextv1.Ch
 			QueryID:   msg.SearchHit.QueryId,
 			Phase:     phase,
 			Rank:      msg.SearchHit.Rank,
-			Chunk:     h.convertCodeChunk(msg.SearchHit.Chunk),
+			Chunk:     convertCodeChunk(msg.SearchHit.Chunk),
 		}
 
 	case *repocontextv1.ChatResponse_CompositionStarted:
@@ -362,6 +532,7 @@ func (h *ChatWebSocketHandler) convertGRPCToWebSocket(grpcResp *repocontextv1.Ch
 			SessionID:    msg.CompositionComplete.SessionId,
 			QueryID:      msg.CompositionComplete.QueryId,
 			FullResponse: msg.CompositionComplete.FullResponse,
+			Usage:        convertTokenUsage(msg.CompositionComplete.Usage),
 		}
 
 	case *repocontextv1.ChatResponse_Error:
@@ -375,13 +546,14 @@ func (h *ChatWebSocketHandler) convertGRPCToWebSocket(grpcResp *repocontextv1.Ch
 		wsResp.Complete = &WSComplete{
 			SessionID: msg.Complete.SessionId,
 			QueryID:   msg.Complete.QueryId,
+			Usage:     convertTokenUsage(msg.Complete.Usage),
 		}
 	}
 
 	return wsResp
 }
 
-func (h *ChatWebSocketHandler) convertCodeChunk(chunk *repocontextv1.CodeChunk) *WSCodeChunk {
+func convertCodeChunk(chunk *repocontextv1.CodeChunk) *WSCodeChunk {
 	if chunk == nil {
 		return nil
 	}
@@ -397,6 +569,18 @@ func (h *ChatWebSocketHandler) convertCodeChunk(chunk *repocontextv1.CodeChunk)
 	}
 }
 
+func convertTokenUsage(usage *repocontextv1.TokenUsage) *WSTokenUsage {
+	if usage == nil {
+		return nil
+	}
+
+	return &WSTokenUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
+
 func (h *ChatWebSocketHandler) sendError(conn *websocket.Conn, sessionID, errorCode, errorMessage string) {
 	response := &WSResponse{
 		Error: &WSError{
@@ -407,4 +591,27 @@ func (h *ChatWebSocketHandler) sendError(conn *websocket.Conn, sessionID, errorC
 	}
 
 	conn.WriteJSON(response)
-}
\ No newline at end of file
+}
+
+func lexicalOptionsFromWS(options *WSLexicalOptions) *repocontextv1.LexicalOptions {
+	if options == nil {
+		return nil
+	}
+	return &repocontextv1.LexicalOptions{
+		Regex:         options.Regex,
+		CaseSensitive: options.CaseSensitive,
+		WholeWord:     options.WholeWord,
+		ContextLines:  options.ContextLines,
+	}
+}
+
+func searchFiltersFromWS(filters *WSSearchFilters) *repocontextv1.SearchFilters {
+	if filters == nil {
+		return nil
+	}
+	return &repocontextv1.SearchFilters{
+		Languages:    filters.Languages,
+		FilePatterns: filters.FilePatterns,
+		PathPrefix:   filters.PathPrefix,
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// keepaliveServer upgrades each request, starts the keepalive and reports the error
+// that ends its read loop
+func keepaliveServer(t *testing.T, h *ChatWebSocketHandler) (string, <-chan error) {
+	readErrs := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := h.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade error = %v", err)
+			return
+		}
+		defer conn.Close()
+		stop := make(chan struct{})
+		defer close(stop)
+		h.startKeepalive(conn, stop)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				readErrs <- err
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http"), readErrs
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.WebSocketPingInterval = 10 * time.Millisecond
+	cfg.Server.WebSocketPongTimeout = 50 * time.Millisecond
+	h := &ChatWebSocketHandler{config: cfg}
+
+	t.Run("answered pings keep the connection open", func(t *testing.T) {
+		url, readErrs := keepaliveServer(t, h)
+		client, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+
+		var pings int32
+		client.SetPingHandler(func(data string) error {
+			atomic.AddInt32(&pings, 1)
+			return client.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		go func() {
+			for {
+				if _, _, err := client.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		select {
+		case err := <-readErrs:
+			t.Fatalf("connection closed while the client answered pings: %v", err)
+		case <-time.After(4 * cfg.Server.WebSocketPongTimeout):
+		}
+		if atomic.LoadInt32(&pings) == 0 {
+			t.Error("client was never pinged")
+		}
+	})
+
+	t.Run("silent peer hits the read deadline", func(t *testing.T) {
+		url, readErrs := keepaliveServer(t, h)
+		// The client never reads, so it never answers a ping
+		client, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+
+		select {
+		case err := <-readErrs:
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				t.Errorf("read ended with %v, want a timeout", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("connection to a silent peer stayed open")
+		}
+	})
+}
+
+func TestWebSocketShutdownSendsCloseFrame(t *testing.T) {
+	chat, _ := newTestChatServer(t, &fixedSearchClient{}, &scriptedComposer{})
+	grpcServer := grpc.NewServer()
+	repocontextv1.RegisterChatServiceServer(grpcServer, chat)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	cfg := newTestConfig(t)
+	cfg.Server.GRPCPort = lis.Addr().(*net.TCPAddr).Port
+	cfg.Server.WebSocketPingInterval = time.Minute
+	cfg.Server.WebSocketPongTimeout = 2 * time.Minute
+	h := NewChatWebSocketHandler(chat, cfg, []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, observability.NewMetrics(), observability.NewNoOpTracer())
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/chat/repo-1/stream"
+
+	client, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		h.connMutex.RLock()
+		registered := len(h.connections)
+		h.connMutex.RUnlock()
+		if registered == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("connection was never registered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownErr <- h.Shutdown(ctx)
+	}()
+
+	// Reading the close frame answers it, which lets the handler finish
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = client.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway || closeErr.Text != "server shutting down" {
+		t.Fatalf("read ended with %v, want a going-away close frame", err)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown error = %v, want the connection drained", err)
+	}
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("dial after shutdown = %v, want 503", err)
+	}
+}
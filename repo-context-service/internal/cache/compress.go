@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// gzipMagic starts every gzip stream. Uncompressed values are JSON objects, so a
+// value with this prefix is compressed and anything else is read as is, including
+// values written before compression was enabled.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// encodeValue gzips data when it is at least the compression threshold
+func (r *RedisCache) encodeValue(data []byte) ([]byte, error) {
+	if r.compressThreshold <= 0 || len(data) < r.compressThreshold {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress value: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress value: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeValue returns the JSON stored in a cached value, decompressing it if needed
+func decodeValue(value string) ([]byte, error) {
+	data := []byte(value)
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress value: %w", err)
+	}
+	defer zr.Close()
+
+	decoded, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress value: %w", err)
+	}
+	return decoded, nil
+}
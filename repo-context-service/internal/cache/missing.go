@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// maxMissingEntries bounds the negative cache; past it, expired entries are dropped
+// and, if that is not enough, the cache starts over
+const maxMissingEntries = 10000
+
+// missingSet remembers repository metadata keys recently found missing, so clients
+// polling an unknown repository ID don't cost a Redis lookup per request. It is
+// local to the process: a repository created through another replica stays
+// "missing" here until the entry expires, which is why the TTL is kept short.
+type missingSet struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]time.Time
+	// Bumped by every remove; an add for a lookup that started before the latest
+	// remove is dropped, as the repository may have been created in between
+	generation uint64
+}
+
+func newMissingSet(ttl time.Duration) *missingSet {
+	return &missingSet{
+		ttl:     ttl,
+		entries: make(map[string]time.Time),
+	}
+}
+
+// contains reports whether key was found missing within the TTL
+func (m *missingSet) contains(key string) bool {
+	if m.ttl <= 0 {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expiresAt, ok := m.entries[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expiresAt) {
+		delete(m.entries, key)
+		return false
+	}
+	return true
+}
+
+// lookupStarted returns the generation to pass to add once the lookup has missed
+func (m *missingSet) lookupStarted() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.generation
+}
+
+func (m *missingSet) add(key string, generation uint64) {
+	if m.ttl <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if generation != m.generation {
+		return
+	}
+
+	if len(m.entries) >= maxMissingEntries {
+		now := time.Now()
+		for k, expiresAt := range m.entries {
+			if now.After(expiresAt) {
+				delete(m.entries, k)
+			}
+		}
+		if len(m.entries) >= maxMissingEntries {
+			m.entries = make(map[string]time.Time)
+		}
+	}
+	m.entries[key] = time.Now().Add(m.ttl)
+}
+
+func (m *missingSet) remove(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	m.generation++
+}
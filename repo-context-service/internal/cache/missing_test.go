@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMissingSet(t *testing.T) {
+	m := newMissingSet(50 * time.Millisecond)
+
+	m.add("repo-1", m.lookupStarted())
+	if !m.contains("repo-1") {
+		t.Error("a miss isn't remembered")
+	}
+	if m.contains("repo-2") {
+		t.Error("a key never found missing is reported missing")
+	}
+	m.remove("repo-1")
+	if m.contains("repo-1") {
+		t.Error("a removed miss is still remembered")
+	}
+
+	// A lookup that started before the repository was created doesn't record a miss
+	generation := m.lookupStarted()
+	m.remove("repo-2")
+	m.add("repo-2", generation)
+	if m.contains("repo-2") {
+		t.Error("a stale lookup recorded a miss")
+	}
+
+	m.add("repo-3", m.lookupStarted())
+	time.Sleep(60 * time.Millisecond)
+	if m.contains("repo-3") {
+		t.Error("a miss is remembered past its TTL")
+	}
+}
@@ -3,10 +3,14 @@ package cache
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"math"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -15,14 +19,43 @@ import (
 )
 
 type RedisCache struct {
-	client *redis.Client
-	ttl    TTLConfig
+	client redis.UniversalClient
+	// Cluster nodes each hold part of the keyspace, so KEYS, MGET and multi-key DEL
+	// are spread over the nodes instead of sent as one command
+	cluster bool
+	ttl     TTLConfig
+	missing *missingSet
+	// Query results and repository metadata at least this many bytes are stored
+	// gzipped; 0 stores them uncompressed
+	compressThreshold int
+}
+
+// RedisOptions selects how to connect: Mode "standalone" (or empty) uses URL,
+// "sentinel" asks the sentinels for MasterName's current master, and "cluster"
+// discovers the cluster from ClusterAddrs.
+type RedisOptions struct {
+	Mode             string
+	URL              string
+	Password         string
+	DB               int
+	PoolSize         int
+	MasterName       string
+	SentinelAddrs    []string
+	SentinelPassword string
+	ClusterAddrs     []string
+	// CompressThreshold is the size in bytes from which query results and repository
+	// metadata are gzipped (0 disables compression)
+	CompressThreshold int
 }
 
 type TTLConfig struct {
 	RepositoryRouting time.Duration
 	QueryResults      time.Duration
 	UploadStatus      time.Duration
+	Embeddings        time.Duration
+	// How long a repository ID found missing is answered as not found without a
+	// Redis lookup; 0 disables negative caching
+	MissingRepository time.Duration
 }
 
 type CachedUploadStatus struct {
@@ -56,34 +89,121 @@ type CachedRepositoryMetadata struct {
 	UpdatedAt       time.Time                      `json:"updated_at"`
 }
 
-func NewRedisCache(redisURL string, password string, db int, ttl TTLConfig) (*RedisCache, error) {
-	opts, err := redis.ParseURL(redisURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
-	}
+// Connection settings applied unless the Redis URL sets them (e.g. ?read_timeout=5s)
+const (
+	redisDialTimeout  = 5 * time.Second
+	redisReadTimeout  = 3 * time.Second
+	redisWriteTimeout = 3 * time.Second
+	redisPoolTimeout  = 4 * time.Second
+)
 
-	if password != "" {
-		opts.Password = password
-	}
-	if db != 0 {
-		opts.DB = db
+// NewRedisCache connects to Redis. PoolSize is the most connections the client opens
+// per node (0 keeps go-redis's default of 10 per CPU), and a quarter of them are kept
+// open idle.
+func NewRedisCache(opts RedisOptions, ttl TTLConfig) (*RedisCache, error) {
+	client, err := newRedisClient(opts)
+	if err != nil {
+		return nil, err
 	}
 
-	client := redis.NewClient(opts)
-
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
+	_, cluster := client.(*redis.ClusterClient)
 	return &RedisCache{
-		client: client,
-		ttl:    ttl,
+		client:            client,
+		cluster:           cluster,
+		ttl:               ttl,
+		missing:           newMissingSet(ttl.MissingRepository),
+		compressThreshold: opts.CompressThreshold,
 	}, nil
 }
 
+func newRedisClient(opts RedisOptions) (redis.UniversalClient, error) {
+	switch opts.Mode {
+	case "", "standalone":
+		clientOpts, err := redis.ParseURL(opts.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		}
+		if opts.Password != "" {
+			clientOpts.Password = opts.Password
+		}
+		if opts.DB != 0 {
+			clientOpts.DB = opts.DB
+		}
+		applyPoolOptions(clientOpts, opts.PoolSize)
+		return redis.NewClient(clientOpts), nil
+
+	case "sentinel":
+		if opts.MasterName == "" || len(opts.SentinelAddrs) == 0 {
+			return nil, fmt.Errorf("sentinel mode requires a master name and sentinel addresses")
+		}
+		// Same pool sizing and timeouts as a standalone client
+		pool := &redis.Options{}
+		applyPoolOptions(pool, opts.PoolSize)
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       opts.MasterName,
+			SentinelAddrs:    opts.SentinelAddrs,
+			SentinelPassword: opts.SentinelPassword,
+			Password:         opts.Password,
+			DB:               opts.DB,
+			PoolSize:         pool.PoolSize,
+			MinIdleConns:     pool.MinIdleConns,
+			DialTimeout:      pool.DialTimeout,
+			ReadTimeout:      pool.ReadTimeout,
+			WriteTimeout:     pool.WriteTimeout,
+			PoolTimeout:      pool.PoolTimeout,
+		}), nil
+
+	case "cluster":
+		if len(opts.ClusterAddrs) == 0 {
+			return nil, fmt.Errorf("cluster mode requires cluster node addresses")
+		}
+		pool := &redis.Options{}
+		applyPoolOptions(pool, opts.PoolSize)
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        opts.ClusterAddrs,
+			Password:     opts.Password,
+			PoolSize:     pool.PoolSize,
+			MinIdleConns: pool.MinIdleConns,
+			DialTimeout:  pool.DialTimeout,
+			ReadTimeout:  pool.ReadTimeout,
+			WriteTimeout: pool.WriteTimeout,
+			PoolTimeout:  pool.PoolTimeout,
+		}), nil
+
+	default:
+		return nil, fmt.Errorf("unknown Redis mode %q", opts.Mode)
+	}
+}
+
+func applyPoolOptions(opts *redis.Options, poolSize int) {
+	if poolSize > 0 {
+		opts.PoolSize = poolSize
+		if opts.MinIdleConns == 0 {
+			opts.MinIdleConns = poolSize / 4
+		}
+	}
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = redisDialTimeout
+	}
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = redisReadTimeout
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = redisWriteTimeout
+	}
+	if opts.PoolTimeout == 0 {
+		opts.PoolTimeout = redisPoolTimeout
+	}
+}
+
 func (r *RedisCache) Close() error {
 	return r.client.Close()
 }
@@ -141,17 +261,21 @@ func (r *RedisCache) DeleteUploadStatus(ctx context.Context, tenantID, uploadID
 }
 
 // Query results cache
-func (r *RedisCache) SetQueryResult(ctx context.Context, tenantID, repoID, query string, topK int, result *CachedQueryResult) error {
-	key := r.queryResultKey(tenantID, repoID, query, topK)
+func (r *RedisCache) SetQueryResult(ctx context.Context, tenantID, repoID, query string, topK int, filters map[string]interface{}, result *CachedQueryResult) error {
+	key := r.queryResultKey(tenantID, repoID, query, topK, filters)
 	data, err := json.Marshal(result)
 	if err != nil {
 		return fmt.Errorf("failed to marshal query result: %w", err)
 	}
+	data, err = r.encodeValue(data)
+	if err != nil {
+		return err
+	}
 	return r.client.Set(ctx, key, data, r.ttl.QueryResults).Err()
 }
 
-func (r *RedisCache) GetQueryResult(ctx context.Context, tenantID, repoID, query string, topK int) (*CachedQueryResult, error) {
-	key := r.queryResultKey(tenantID, repoID, query, topK)
+func (r *RedisCache) GetQueryResult(ctx context.Context, tenantID, repoID, query string, topK int, filters map[string]interface{}) (*CachedQueryResult, error) {
+	key := r.queryResultKey(tenantID, repoID, query, topK, filters)
 	data, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
 		return nil, nil
@@ -160,18 +284,37 @@ func (r *RedisCache) GetQueryResult(ctx context.Context, tenantID, repoID, query
 		return nil, err
 	}
 
+	decoded, err := decodeValue(data)
+	if err != nil {
+		return nil, err
+	}
+
 	var result CachedQueryResult
-	if err := json.Unmarshal([]byte(data), &result); err != nil {
+	if err := json.Unmarshal(decoded, &result); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal query result: %w", err)
 	}
 	return &result, nil
 }
 
-func (r *RedisCache) DeleteQueryResult(ctx context.Context, tenantID, repoID, query string, topK int) error {
-	key := r.queryResultKey(tenantID, repoID, query, topK)
+func (r *RedisCache) DeleteQueryResult(ctx context.Context, tenantID, repoID, query string, topK int, filters map[string]interface{}) error {
+	key := r.queryResultKey(tenantID, repoID, query, topK, filters)
 	return r.client.Del(ctx, key).Err()
 }
 
+// DeleteQueryResults removes every cached query result for a repository, so results
+// from a deleted or re-indexed index are not served until they expire
+func (r *RedisCache) DeleteQueryResults(ctx context.Context, tenantID, repoID string) error {
+	pattern := fmt.Sprintf("ctx_res:%s:%s|*", sanitizeTenantID(tenantID), sanitizeID(repoID))
+	keys, err := r.scan(ctx, pattern)
+	if err != nil {
+		return fmt.Errorf("failed to scan query results: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.del(ctx, keys...)
+}
+
 // Repository metadata cache
 func (r *RedisCache) SetRepositoryMetadata(ctx context.Context, tenantID string, repo *repocontextv1.Repository) error {
 	key := r.repositoryMetadataKey(tenantID, repo.RepositoryId)
@@ -182,22 +325,41 @@ func (r *RedisCache) SetRepositoryMetadata(ctx context.Context, tenantID string,
 	if err != nil {
 		return fmt.Errorf("failed to marshal repository metadata: %w", err)
 	}
+	data, err = r.encodeValue(data)
+	if err != nil {
+		return err
+	}
 
-	return r.client.Set(ctx, key, data, r.ttl.RepositoryRouting).Err()
+	if err := r.client.Set(ctx, key, data, r.ttl.RepositoryRouting).Err(); err != nil {
+		return err
+	}
+	r.missing.remove(key)
+	return nil
 }
 
 func (r *RedisCache) GetRepositoryMetadata(ctx context.Context, tenantID, repoID string) (*repocontextv1.Repository, error) {
 	key := r.repositoryMetadataKey(tenantID, repoID)
+	if r.missing.contains(key) {
+		return nil, nil
+	}
+
+	generation := r.missing.lookupStarted()
 	data, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
+		r.missing.add(key, generation)
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
 
+	decoded, err := decodeValue(data)
+	if err != nil {
+		return nil, err
+	}
+
 	var cached CachedRepositoryMetadata
-	if err := json.Unmarshal([]byte(data), &cached); err != nil {
+	if err := json.Unmarshal(decoded, &cached); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal repository metadata: %w", err)
 	}
 
@@ -205,10 +367,76 @@ func (r *RedisCache) GetRepositoryMetadata(ctx context.Context, tenantID, repoID
 	return r.fromCachedRepo(&cached), nil
 }
 
+// maxMetadataUpdateAttempts bounds the retries of an UpdateRepositoryMetadata
+// transaction that keeps losing to concurrent writers
+const maxMetadataUpdateAttempts = 5
+
+// UpdateRepositoryMetadata applies update to the stored repository metadata in an
+// optimistic transaction, retrying if the record changes concurrently. It returns
+// the updated repository, or nil if there is no metadata for repoID.
+func (r *RedisCache) UpdateRepositoryMetadata(ctx context.Context, tenantID, repoID string, update func(*repocontextv1.Repository)) (*repocontextv1.Repository, error) {
+	key := r.repositoryMetadataKey(tenantID, repoID)
+
+	var updated *repocontextv1.Repository
+	txf := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Result()
+		if err == redis.Nil {
+			updated = nil
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		decoded, err := decodeValue(data)
+		if err != nil {
+			return err
+		}
+
+		var cached CachedRepositoryMetadata
+		if err := json.Unmarshal(decoded, &cached); err != nil {
+			return fmt.Errorf("failed to unmarshal repository metadata: %w", err)
+		}
+		repo := r.fromCachedRepo(&cached)
+		update(repo)
+
+		updatedData, err := json.Marshal(r.toCachedRepo(repo))
+		if err != nil {
+			return fmt.Errorf("failed to marshal repository metadata: %w", err)
+		}
+		updatedData, err = r.encodeValue(updatedData)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, updatedData, r.ttl.RepositoryRouting)
+			return nil
+		})
+		if err == nil {
+			updated = repo
+		}
+		return err
+	}
+
+	for attempt := 0; attempt < maxMetadataUpdateAttempts; attempt++ {
+		err := r.client.Watch(ctx, txf, key)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+
+	return nil, fmt.Errorf("repository metadata for %s changed concurrently, giving up after %d attempts", repoID, maxMetadataUpdateAttempts)
+}
+
 func (r *RedisCache) ListRepositoryMetadata(ctx context.Context, tenantID string) ([]*repocontextv1.Repository, error) {
 	// Build pattern manually to avoid sanitizing the wildcard
 	pattern := fmt.Sprintf("repo_meta:%s:*", sanitizeTenantID(tenantID))
-	keys, err := r.client.Keys(ctx, pattern).Result()
+	keys, err := r.keys(ctx, pattern)
 	if err != nil {
 		return nil, err
 	}
@@ -217,7 +445,7 @@ func (r *RedisCache) ListRepositoryMetadata(ctx context.Context, tenantID string
 		return nil, nil
 	}
 
-	values, err := r.client.MGet(ctx, keys...).Result()
+	values, err := r.mget(ctx, keys)
 	if err != nil {
 		return nil, err
 	}
@@ -228,8 +456,13 @@ func (r *RedisCache) ListRepositoryMetadata(ctx context.Context, tenantID string
 			continue
 		}
 
+		decoded, err := decodeValue(value.(string))
+		if err != nil {
+			continue
+		}
+
 		var cached CachedRepositoryMetadata
-		if err := json.Unmarshal([]byte(value.(string)), &cached); err != nil {
+		if err := json.Unmarshal(decoded, &cached); err != nil {
 			continue // Skip invalid entries
 		}
 
@@ -240,9 +473,380 @@ func (r *RedisCache) ListRepositoryMetadata(ctx context.Context, tenantID string
 	return repositories, nil
 }
 
+// ListAllRepositoryIDs returns the IDs of the repositories with metadata, across
+// all tenants
+func (r *RedisCache) ListAllRepositoryIDs(ctx context.Context) (map[string]bool, error) {
+	keys, err := r.keys(ctx, "repo_meta:*")
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		// repo_meta:<tenant>:<repository ID>; neither part contains a colon
+		parts := strings.SplitN(key, ":", 3)
+		if len(parts) == 3 {
+			ids[parts[2]] = true
+		}
+	}
+
+	return ids, nil
+}
+
 func (r *RedisCache) DeleteRepositoryMetadata(ctx context.Context, tenantID, repoID string) error {
 	key := r.repositoryMetadataKey(tenantID, repoID)
-	return r.client.Del(ctx, key).Err()
+	return r.del(ctx, key, r.fileHashesKey(tenantID, repoID), r.fileInventoryKey(tenantID, repoID), r.uploadOptionsKey(tenantID, repoID))
+}
+
+// Per-file content hashes of the last ingestion, used to find changed files on re-index.
+// They share the repository metadata's TTL and are removed along with it.
+func (r *RedisCache) SetFileHashes(ctx context.Context, tenantID, repoID string, hashes map[string]string) error {
+	key := r.fileHashesKey(tenantID, repoID)
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		if len(hashes) > 0 {
+			pipe.HSet(ctx, key, hashes)
+			pipe.Expire(ctx, key, r.ttl.RepositoryRouting)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisCache) GetFileHashes(ctx context.Context, tenantID, repoID string) (map[string]string, error) {
+	return r.client.HGetAll(ctx, r.fileHashesKey(tenantID, repoID)).Result()
+}
+
+// File inventory of the last ingestion, keyed by path, for listing a repository's
+// files. Like the file hashes it shares the metadata's TTL and is removed with it.
+func (r *RedisCache) SetFileInventory(ctx context.Context, tenantID, repoID string, files []*repocontextv1.FileEntry) error {
+	key := r.fileInventoryKey(tenantID, repoID)
+
+	fields := make(map[string]interface{}, len(files))
+	for _, file := range files {
+		data, err := json.Marshal(file)
+		if err != nil {
+			return fmt.Errorf("failed to marshal file entry: %w", err)
+		}
+		fields[file.Path] = data
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		if len(fields) > 0 {
+			pipe.HSet(ctx, key, fields)
+			pipe.Expire(ctx, key, r.ttl.RepositoryRouting)
+		}
+		return nil
+	})
+	return err
+}
+
+// GetFileInventory returns the stored file entries sorted by path
+func (r *RedisCache) GetFileInventory(ctx context.Context, tenantID, repoID string) ([]*repocontextv1.FileEntry, error) {
+	fields, err := r.client.HGetAll(ctx, r.fileInventoryKey(tenantID, repoID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*repocontextv1.FileEntry, 0, len(fields))
+	for _, data := range fields {
+		var file repocontextv1.FileEntry
+		if err := json.Unmarshal([]byte(data), &file); err != nil {
+			continue // Skip corrupted entries
+		}
+		files = append(files, &file)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// Upload options the repository was last ingested with, so a re-index chunks and
+// filters it the same way. They share the metadata's TTL and are removed with it.
+func (r *RedisCache) SetUploadOptions(ctx context.Context, tenantID, repoID string, options *repocontextv1.UploadOptions) error {
+	key := r.uploadOptionsKey(tenantID, repoID)
+	if options == nil {
+		return r.client.Del(ctx, key).Err()
+	}
+
+	data, err := json.Marshal(options)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload options: %w", err)
+	}
+	return r.client.Set(ctx, key, data, r.ttl.RepositoryRouting).Err()
+}
+
+// GetUploadOptions returns the stored upload options, or nil if there are none
+func (r *RedisCache) GetUploadOptions(ctx context.Context, tenantID, repoID string) (*repocontextv1.UploadOptions, error) {
+	data, err := r.client.Get(ctx, r.uploadOptionsKey(tenantID, repoID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var options repocontextv1.UploadOptions
+	if err := json.Unmarshal([]byte(data), &options); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal upload options: %w", err)
+	}
+	return &options, nil
+}
+
+// API key store. Keys are stored under the SHA-256 of the raw key so a Redis
+// dump doesn't leak usable credentials; a second entry maps the key ID to the
+// hash for revocation.
+type APIKeyRecord struct {
+	KeyID       string    `json:"key_id"`
+	TenantID    string    `json:"tenant_id"`
+	Scopes      []string  `json:"scopes"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (r *RedisCache) SetAPIKey(ctx context.Context, apiKey string, record *APIKeyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal API key: %w", err)
+	}
+
+	keyHash := hashAPIKey(apiKey)
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.apiKeyKey(keyHash), data, 0)
+		pipe.Set(ctx, r.apiKeyIDKey(record.KeyID), keyHash, 0)
+		return nil
+	})
+	return err
+}
+
+// GetAPIKey returns the record for a raw API key, or nil if the key is unknown or revoked
+func (r *RedisCache) GetAPIKey(ctx context.Context, apiKey string) (*APIKeyRecord, error) {
+	data, err := r.client.Get(ctx, r.apiKeyKey(hashAPIKey(apiKey))).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record APIKeyRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal API key: %w", err)
+	}
+
+	return &record, nil
+}
+
+// DeleteAPIKey revokes the API key with the given ID, reporting whether it existed
+func (r *RedisCache) DeleteAPIKey(ctx context.Context, keyID string) (bool, error) {
+	idKey := r.apiKeyIDKey(keyID)
+	keyHash, err := r.client.Get(ctx, idKey).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.del(ctx, r.apiKeyKey(keyHash), idKey); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// rateLimitScript is a token bucket shared by every replica. Tokens refill at ARGV[1]
+// per second up to ARGV[2]; the script returns 1 when a token was taken. Redis
+// server time is used so replicas with skewed clocks agree on the refill.
+var rateLimitScript = redis.NewScript(`
+redis.replicate_commands()
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+-- Expire once the bucket would have refilled completely
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return allowed
+`)
+
+// AllowRequest takes a token from the tenant's shared rate limit bucket, reporting
+// whether the request is allowed
+func (r *RedisCache) AllowRequest(ctx context.Context, tenantID string, requestsPerSecond float64, burst int) (bool, error) {
+	allowed, err := rateLimitScript.Run(ctx, r.client, []string{r.rateLimitKey(tenantID)}, requestsPerSecond, burst).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to run rate limit script: %w", err)
+	}
+	return allowed == 1, nil
+}
+
+// Embedding cache. Vectors are stored as little-endian float32 bytes keyed by model
+// and content hash, so a model switch never serves vectors of the wrong dimensions.
+func (r *RedisCache) GetEmbeddings(ctx context.Context, model string, hashes []string) (map[string][]float32, error) {
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(hashes))
+	for i, hash := range hashes {
+		keys[i] = r.embeddingKey(model, hash)
+	}
+
+	values, err := r.mget(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	embeddings := make(map[string][]float32, len(hashes))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok || len(data)%4 != 0 {
+			continue
+		}
+		vector := make([]float32, len(data)/4)
+		for j := range vector {
+			vector[j] = math.Float32frombits(binary.LittleEndian.Uint32([]byte(data[j*4 : j*4+4])))
+		}
+		embeddings[hashes[i]] = vector
+	}
+
+	return embeddings, nil
+}
+
+func (r *RedisCache) SetEmbeddings(ctx context.Context, model string, embeddings map[string][]float32) error {
+	if len(embeddings) == 0 {
+		return nil
+	}
+
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for hash, vector := range embeddings {
+			data := make([]byte, len(vector)*4)
+			for i, v := range vector {
+				binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+			}
+			pipe.Set(ctx, r.embeddingKey(model, hash), data, r.ttl.Embeddings)
+		}
+		return nil
+	})
+	return err
+}
+
+// keys returns the keys matching pattern, from every master in cluster mode
+func (r *RedisCache) keys(ctx context.Context, pattern string) ([]string, error) {
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		return r.client.Keys(ctx, pattern).Result()
+	}
+
+	var mu sync.Mutex
+	var keys []string
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		nodeKeys, err := master.Keys(ctx, pattern).Result()
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		keys = append(keys, nodeKeys...)
+		mu.Unlock()
+		return nil
+	})
+	return keys, err
+}
+
+// scan returns the keys matching pattern like keys, iterating with SCAN so a large
+// keyspace does not block the server
+func (r *RedisCache) scan(ctx context.Context, pattern string) ([]string, error) {
+	scanNode := func(ctx context.Context, client redis.Cmdable) ([]string, error) {
+		var keys []string
+		iter := client.Scan(ctx, 0, pattern, 1000).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		return keys, iter.Err()
+	}
+
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(ctx, r.client)
+	}
+
+	var mu sync.Mutex
+	var keys []string
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		nodeKeys, err := scanNode(ctx, master)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		keys = append(keys, nodeKeys...)
+		mu.Unlock()
+		return nil
+	})
+	return keys, err
+}
+
+// mget is MGET, with a nil value for each missing key. In cluster mode the keys
+// may live on different slots, so each is read with its own GET in one pipeline.
+func (r *RedisCache) mget(ctx context.Context, keys []string) ([]interface{}, error) {
+	if !r.cluster {
+		return r.client.MGet(ctx, keys...).Result()
+	}
+
+	cmds := make([]*redis.StringCmd, len(keys))
+	// Missing keys fail their GET with redis.Nil, so errors are checked per command
+	r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
+		return nil
+	})
+
+	values := make([]interface{}, len(keys))
+	for i, cmd := range cmds {
+		value, err := cmd.Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// del deletes keys, one DEL per key in cluster mode for the same reason as mget
+func (r *RedisCache) del(ctx context.Context, keys ...string) error {
+	if !r.cluster {
+		return r.client.Del(ctx, keys...).Err()
+	}
+
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	return err
 }
 
 // Key generation helpers
@@ -254,20 +858,51 @@ func (r *RedisCache) uploadStatusKey(tenantID, uploadID string) string {
 	return fmt.Sprintf("upload_status:%s:%s", sanitizeTenantID(tenantID), sanitizeID(uploadID))
 }
 
-func (r *RedisCache) queryResultKey(tenantID, repoID, query string, topK int) string {
+// queryResultKey keys a query's results by its search filters too, so a filtered
+// query never reads the results of an unfiltered one
+func (r *RedisCache) queryResultKey(tenantID, repoID, query string, topK int, filters map[string]interface{}) string {
 	normalizedQuery := normalizeQuery(query)
 	queryHash := hashString(normalizedQuery)
-	return fmt.Sprintf("ctx_res:%s:%s|%s|k:%d",
+	return fmt.Sprintf("ctx_res:%s:%s|%s|k:%d|f:%s",
 		sanitizeTenantID(tenantID),
 		sanitizeID(repoID),
 		queryHash,
-		topK)
+		topK,
+		hashFilters(filters))
 }
 
 func (r *RedisCache) repositoryMetadataKey(tenantID, repoID string) string {
 	return fmt.Sprintf("repo_meta:%s:%s", sanitizeTenantID(tenantID), sanitizeID(repoID))
 }
 
+func (r *RedisCache) fileHashesKey(tenantID, repoID string) string {
+	return fmt.Sprintf("file_hashes:%s:%s", sanitizeTenantID(tenantID), sanitizeID(repoID))
+}
+
+func (r *RedisCache) fileInventoryKey(tenantID, repoID string) string {
+	return fmt.Sprintf("file_tree:%s:%s", sanitizeTenantID(tenantID), sanitizeID(repoID))
+}
+
+func (r *RedisCache) uploadOptionsKey(tenantID, repoID string) string {
+	return fmt.Sprintf("upload_opts:%s:%s", sanitizeTenantID(tenantID), sanitizeID(repoID))
+}
+
+func (r *RedisCache) embeddingKey(model, hash string) string {
+	return fmt.Sprintf("emb:%s:%s", sanitizeRepoKey(model), sanitizeID(hash))
+}
+
+func (r *RedisCache) apiKeyKey(keyHash string) string {
+	return fmt.Sprintf("api_key:%s", keyHash)
+}
+
+func (r *RedisCache) apiKeyIDKey(keyID string) string {
+	return fmt.Sprintf("api_key_id:%s", sanitizeID(keyID))
+}
+
+func (r *RedisCache) rateLimitKey(tenantID string) string {
+	return fmt.Sprintf("rate_limit:%s", sanitizeTenantID(tenantID))
+}
+
 // Health check
 func (r *RedisCache) HealthCheck(ctx context.Context) error {
 	return r.client.Ping(ctx).Err()
@@ -313,6 +948,24 @@ func hashString(s string) string {
 	return fmt.Sprintf("%x", h)[:16] // Use first 16 chars of hash
 }
 
+// hashFilters hashes search filters independently of map order; json.Marshal sorts
+// map keys
+func hashFilters(filters map[string]interface{}) string {
+	if len(filters) == 0 {
+		return "none"
+	}
+	data, err := json.Marshal(filters)
+	if err != nil {
+		return hashString(fmt.Sprintf("%v", filters))
+	}
+	return hashString(string(data))
+}
+
+func hashAPIKey(apiKey string) string {
+	h := sha256.Sum256([]byte(apiKey))
+	return fmt.Sprintf("%x", h)
+}
+
 // Helper functions for converting between protobuf and cached formats
 
 func (r *RedisCache) toCachedRepo(repo *repocontextv1.Repository) *CachedRepositoryMetadata {
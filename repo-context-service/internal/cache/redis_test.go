@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/protobuf/proto"
+)
+
+// newTestCache returns a cache backed by an in-memory Redis
+func newTestCache(t *testing.T, opts RedisOptions, ttl TTLConfig) (*RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	opts.URL = "redis://" + server.Addr()
+	redisCache, err := NewRedisCache(opts, ttl)
+	if err != nil {
+		t.Fatalf("NewRedisCache error = %v", err)
+	}
+	t.Cleanup(func() { redisCache.Close() })
+	return redisCache, server
+}
+
+func TestRedisPoolSize(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		poolSize     int
+		wantPoolSize int
+		wantMinIdle  int
+	}{
+		{"go-redis default", "redis://localhost:6379", 0, 0, 0},
+		{"configured", "redis://localhost:6379", 20, 20, 5},
+		{"overrides the URL", "redis://localhost:6379?pool_size=50", 8, 8, 2},
+		{"keeps the URL's idle connections", "redis://localhost:6379?min_idle_conns=7", 8, 8, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newRedisClient(RedisOptions{URL: tt.url, PoolSize: tt.poolSize})
+			if err != nil {
+				t.Fatalf("newRedisClient error = %v", err)
+			}
+			defer client.Close()
+			opts := client.(*redis.Client).Options()
+
+			// go-redis sizes an unset pool when the client is created
+			if tt.wantPoolSize != 0 && opts.PoolSize != tt.wantPoolSize {
+				t.Errorf("PoolSize = %d, want %d", opts.PoolSize, tt.wantPoolSize)
+			}
+			if tt.wantPoolSize == 0 && opts.PoolSize <= 0 {
+				t.Errorf("PoolSize = %d, want go-redis's default", opts.PoolSize)
+			}
+			if opts.MinIdleConns != tt.wantMinIdle {
+				t.Errorf("MinIdleConns = %d, want %d", opts.MinIdleConns, tt.wantMinIdle)
+			}
+			if opts.DialTimeout != redisDialTimeout || opts.ReadTimeout != redisReadTimeout || opts.WriteTimeout != redisWriteTimeout || opts.PoolTimeout != redisPoolTimeout {
+				t.Errorf("timeouts = dial %v, read %v, write %v, pool %v", opts.DialTimeout, opts.ReadTimeout, opts.WriteTimeout, opts.PoolTimeout)
+			}
+		})
+	}
+
+	// A sized pool still connects
+	redisCache, _ := newTestCache(t, RedisOptions{PoolSize: 4}, TTLConfig{RepositoryRouting: time.Hour})
+	if err := redisCache.SetRepositoryIndex(context.Background(), "default", "widgets", "repo-1"); err != nil {
+		t.Fatalf("SetRepositoryIndex error = %v", err)
+	}
+}
+
+func TestNewRedisClientModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    RedisOptions
+		wantErr bool
+	}{
+		{"sentinel", RedisOptions{Mode: "sentinel", MasterName: "primary", SentinelAddrs: []string{"sentinel-1:26379", "sentinel-2:26379"}, Password: "secret", DB: 3, PoolSize: 12}, false},
+		{"sentinel without a master", RedisOptions{Mode: "sentinel", SentinelAddrs: []string{"sentinel-1:26379"}}, true},
+		{"sentinel without addresses", RedisOptions{Mode: "sentinel", MasterName: "primary"}, true},
+		{"cluster", RedisOptions{Mode: "cluster", ClusterAddrs: []string{"node-1:6379", "node-2:6379", "node-3:6379"}, Password: "secret", PoolSize: 12}, false},
+		{"cluster without addresses", RedisOptions{Mode: "cluster"}, true},
+		{"unknown mode", RedisOptions{Mode: "replicated", URL: "redis://localhost:6379"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Clients connect lazily, so none of these addresses are dialled
+			client, err := newRedisClient(tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					client.Close()
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newRedisClient error = %v", err)
+			}
+			defer client.Close()
+
+			switch c := client.(type) {
+			case *redis.Client:
+				opts := c.Options()
+				if tt.opts.Mode != "sentinel" || opts.Password != "secret" || opts.DB != 3 || opts.PoolSize != 12 || opts.MinIdleConns != 3 || opts.ReadTimeout != redisReadTimeout {
+					t.Errorf("%s mode built a client with %+v", tt.opts.Mode, opts)
+				}
+			case *redis.ClusterClient:
+				opts := c.Options()
+				if tt.opts.Mode != "cluster" || len(opts.Addrs) != 3 || opts.Password != "secret" || opts.PoolSize != 12 || opts.MinIdleConns != 3 || opts.ReadTimeout != redisReadTimeout {
+					t.Errorf("%s mode built a cluster client with %+v", tt.opts.Mode, opts)
+				}
+			default:
+				t.Errorf("%s mode built a %T", tt.opts.Mode, client)
+			}
+		})
+	}
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	result := &CachedQueryResult{
+		Chunks: []*repocontextv1.CodeChunk{{
+			RepositoryId: "repo-1",
+			FilePath:     "widget.go",
+			StartLine:    1,
+			EndLine:      40,
+			Content:      strings.Repeat("func Widget() {}\n", 40),
+		}},
+		Stats:    &repocontextv1.SearchStats{MergedResults: 1},
+		CachedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	repo := &repocontextv1.Repository{
+		RepositoryId:    "repo-1",
+		Name:            "widgets",
+		Description:     strings.Repeat("Widget factory. ", 20),
+		IngestionStatus: &repocontextv1.IngestionStatus{State: repocontextv1.IngestionStatus_STATE_READY},
+		Stats:           &repocontextv1.RepositoryStats{TotalFiles: 3, TotalLines: 120},
+	}
+
+	tests := []struct {
+		name           string
+		threshold      int
+		wantCompressed bool
+	}{
+		{"compression off", 0, false},
+		{"below the threshold", 1 << 20, false},
+		{"at or above the threshold", 64, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisCache, server := newTestCache(t, RedisOptions{CompressThreshold: tt.threshold}, TTLConfig{RepositoryRouting: time.Hour, QueryResults: time.Hour})
+
+			if err := redisCache.SetQueryResult(ctx, "default", "repo-1", "widgets", 10, nil, result); err != nil {
+				t.Fatal(err)
+			}
+			if err := redisCache.SetRepositoryMetadata(ctx, "default", repo); err != nil {
+				t.Fatal(err)
+			}
+
+			// Stored values are gzip streams or plain JSON objects
+			keys := server.Keys()
+			if len(keys) != 2 {
+				t.Fatalf("stored keys %v, want a query result and metadata", keys)
+			}
+			for _, key := range keys {
+				stored, err := server.Get(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if compressed := strings.HasPrefix(stored, string(gzipMagic)); compressed != tt.wantCompressed {
+					t.Errorf("%s stored compressed = %v, want %v", key, compressed, tt.wantCompressed)
+				}
+				if !tt.wantCompressed && !strings.HasPrefix(stored, "{") {
+					t.Errorf("%s stored as %q, want JSON", key, stored[:10])
+				}
+			}
+
+			gotResult, err := redisCache.GetQueryResult(ctx, "default", "repo-1", "widgets", 10, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotResult == nil || len(gotResult.Chunks) != 1 || !proto.Equal(gotResult.Chunks[0], result.Chunks[0]) || !gotResult.CachedAt.Equal(result.CachedAt) {
+				t.Errorf("query result read back as %+v", gotResult)
+			}
+			gotRepo, err := redisCache.GetRepositoryMetadata(ctx, "default", "repo-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotRepo == nil || gotRepo.Description != repo.Description || gotRepo.GetStats().GetTotalLines() != 120 || gotRepo.GetIngestionStatus().GetState() != repocontextv1.IngestionStatus_STATE_READY {
+				t.Errorf("repository read back as %v", gotRepo)
+			}
+		})
+	}
+
+	// Values written before compression was enabled stay readable
+	plain, server := newTestCache(t, RedisOptions{}, TTLConfig{RepositoryRouting: time.Hour})
+	if err := plain.SetRepositoryMetadata(ctx, "default", repo); err != nil {
+		t.Fatal(err)
+	}
+	compressing, err := NewRedisCache(RedisOptions{URL: "redis://" + server.Addr(), CompressThreshold: 64}, TTLConfig{RepositoryRouting: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer compressing.Close()
+	if gotRepo, err := compressing.GetRepositoryMetadata(ctx, "default", "repo-1"); err != nil || gotRepo.GetDescription() != repo.Description {
+		t.Errorf("uncompressed metadata read back as %v, %v", gotRepo, err)
+	}
+}
+
+func TestMissingRepositoryCache(t *testing.T) {
+	ctx := context.Background()
+	repo := &repocontextv1.Repository{RepositoryId: "repo-1", Name: "widgets"}
+
+	tests := []struct {
+		name       string
+		ttl        time.Duration
+		wantCached bool
+	}{
+		{"negative cache off", 0, false},
+		{"negative cache on", time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisCache, server := newTestCache(t, RedisOptions{}, TTLConfig{RepositoryRouting: time.Hour, MissingRepository: tt.ttl})
+			if got, err := redisCache.GetRepositoryMetadata(ctx, "default", "repo-1"); got != nil || err != nil {
+				t.Fatalf("GetRepositoryMetadata = %v, %v; want a miss", got, err)
+			}
+
+			// Written by another replica, so this process's negative cache isn't cleared
+			other, err := NewRedisCache(RedisOptions{URL: "redis://" + server.Addr()}, TTLConfig{RepositoryRouting: time.Hour})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer other.Close()
+			if err := other.SetRepositoryMetadata(ctx, "default", repo); err != nil {
+				t.Fatal(err)
+			}
+			got, err := redisCache.GetRepositoryMetadata(ctx, "default", "repo-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == nil) != tt.wantCached {
+				t.Errorf("repository found = %v, want the miss cached = %v", got != nil, tt.wantCached)
+			}
+
+			// Creating the repository through this cache clears the miss
+			if err := redisCache.SetRepositoryMetadata(ctx, "default", repo); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := redisCache.GetRepositoryMetadata(ctx, "default", "repo-1"); got.GetName() != "widgets" || err != nil {
+				t.Errorf("GetRepositoryMetadata after create = %v, %v; want widgets", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package composer
+
+import (
+	"context"
+
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+// Composer turns retrieved code chunks into an answer using an LLM
+type Composer interface {
+	ComposeAnswer(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []Turn) (*CompositionResult, error)
+	ComposeAnswerStream(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []Turn, callback func(string) error) (*CompositionResult, error)
+}
+
+// ToolComposer is implemented by composers that can fetch more context through tool calls
+type ToolComposer interface {
+	ComposeAnswerWithTools(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []Turn, fetcher ContextFetcher) (*CompositionResult, error)
+}
@@ -6,20 +6,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"repo-context-service/internal/config"
 	"repo-context-service/internal/observability"
 	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+	"google.golang.org/protobuf/proto"
 )
 
 type DeepSeekClient struct {
-	config     config.DeepSeekConfig
-	httpClient *http.Client
-	metrics    *observability.Metrics
-	tracer     *observability.Tracer
+	config       config.DeepSeekConfig
+	systemPrompt *SystemPrompt
+	httpClient   *http.Client
+	metrics      *observability.Metrics
+	tracer       *observability.Tracer
 }
 
 type ChatRequest struct {
@@ -28,11 +35,14 @@ type ChatRequest struct {
 	Temperature float32   `json:"temperature"`
 	MaxTokens   int       `json:"max_tokens"`
 	Stream      bool      `json:"stream"`
+	Tools       []Tool    `json:"tools,omitempty"`
 }
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
 }
 
 type ChatResponse struct {
@@ -65,16 +75,26 @@ type StreamResponse struct {
 	Choices []Choice `json:"choices"`
 }
 
+// Turn is a previous question and answer in a chat session
+type Turn struct {
+	Query  string
+	Answer string
+}
+
 type CompositionResult struct {
 	FullResponse string
 	Citations    []*repocontextv1.Citation
-	TokenCount   int
-	Duration     time.Duration
+	// Prompt and completion tokens, estimated for streamed answers; TokenCount is their sum
+	PromptTokens     int
+	CompletionTokens int
+	TokenCount       int
+	Duration         time.Duration
 }
 
-func NewDeepSeekClient(cfg config.DeepSeekConfig, metrics *observability.Metrics, tracer *observability.Tracer) *DeepSeekClient {
+func NewDeepSeekClient(cfg config.DeepSeekConfig, systemPrompt *SystemPrompt, metrics *observability.Metrics, tracer *observability.Tracer) *DeepSeekClient {
 	return &DeepSeekClient{
-		config: cfg,
+		config:       cfg,
+		systemPrompt: systemPrompt,
 		httpClient: &http.Client{
 			Timeout: cfg.Timeout,
 		},
@@ -83,7 +103,7 @@ func NewDeepSeekClient(cfg config.DeepSeekConfig, metrics *observability.Metrics
 	}
 }
 
-func (d *DeepSeekClient) ComposeAnswer(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk) (*CompositionResult, error) {
+func (d *DeepSeekClient) ComposeAnswer(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []Turn) (*CompositionResult, error) {
 	ctx, span := d.tracer.StartLLMCall(ctx, d.config.Model)
 	defer span.End()
 
@@ -99,13 +119,7 @@ func (d *DeepSeekClient) ComposeAnswer(ctx context.Context, query string, chunks
 	}()
 
 	// Build prompt
-	systemPrompt := buildSystemPrompt()
-	userPrompt := buildUserPrompt(query, chunks)
-
-	messages := []Message{
-		{Role: "system", Content: systemPrompt},
-		{Role: "user", Content: userPrompt},
-	}
+	messages := buildMessages(d.systemPrompt.Render(ctx), query, chunks, history, d.promptBudget(0))
 
 	// Create request
 	req := ChatRequest{
@@ -124,6 +138,7 @@ func (d *DeepSeekClient) ComposeAnswer(ctx context.Context, query string, chunks
 	}
 
 	d.metrics.RecordLLMRequest(d.config.Model, "success")
+	d.metrics.RecordLLMTokens(d.config.Model, response.Usage.PromptTokens, response.Usage.CompletionTokens)
 
 	// Extract response
 	if len(response.Choices) == 0 {
@@ -134,10 +149,12 @@ func (d *DeepSeekClient) ComposeAnswer(ctx context.Context, query string, chunks
 	citations := extractCitations(fullResponse, chunks)
 
 	result := &CompositionResult{
-		FullResponse: fullResponse,
-		Citations:    citations,
-		TokenCount:   response.Usage.TotalTokens,
-		Duration:     timer.Duration(),
+		FullResponse:     fullResponse,
+		Citations:        citations,
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+		TokenCount:       response.Usage.TotalTokens,
+		Duration:         timer.Duration(),
 	}
 
 	observability.SetSpanAttributes(span,
@@ -147,13 +164,13 @@ func (d *DeepSeekClient) ComposeAnswer(ctx context.Context, query string, chunks
 	return result, nil
 }
 
-func (d *DeepSeekClient) ComposeAnswerStream(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, callback func(string) error) (*CompositionResult, error) {
+func (d *DeepSeekClient) ComposeAnswerStream(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []Turn, callback func(string) error) (*CompositionResult, error) {
 	ctx, span := d.tracer.StartLLMCall(ctx, d.config.Model)
 	defer span.End()
 
 	if !d.config.StreamTokens {
 		// Fallback to non-streaming
-		result, err := d.ComposeAnswer(ctx, query, chunks)
+		result, err := d.ComposeAnswer(ctx, query, chunks, history)
 		if err != nil {
 			return nil, err
 		}
@@ -170,13 +187,7 @@ func (d *DeepSeekClient) ComposeAnswerStream(ctx context.Context, query string,
 	}()
 
 	// Build prompt
-	systemPrompt := buildSystemPrompt()
-	userPrompt := buildUserPrompt(query, chunks)
-
-	messages := []Message{
-		{Role: "system", Content: systemPrompt},
-		{Role: "user", Content: userPrompt},
-	}
+	messages := buildMessages(d.systemPrompt.Render(ctx), query, chunks, history, d.promptBudget(0))
 
 	// Create streaming request
 	req := ChatRequest{
@@ -195,14 +206,20 @@ func (d *DeepSeekClient) ComposeAnswerStream(ctx context.Context, query string,
 	}
 
 	d.metrics.RecordLLMRequest(d.config.Model, "success")
+	// Streamed responses carry no usage; the prompt is estimated and each delta
+	// counts as a completion token
+	promptTokens := estimateMessageTokens(messages)
+	d.metrics.RecordLLMTokens(d.config.Model, promptTokens, tokenCount)
 
 	citations := extractCitations(fullResponse, chunks)
 
 	result := &CompositionResult{
-		FullResponse: fullResponse,
-		Citations:    citations,
-		TokenCount:   tokenCount,
-		Duration:     timer.Duration(),
+		FullResponse:     fullResponse,
+		Citations:        citations,
+		PromptTokens:     promptTokens,
+		CompletionTokens: tokenCount,
+		TokenCount:       promptTokens + tokenCount,
+		Duration:         timer.Duration(),
 	}
 
 	return result, nil
@@ -214,7 +231,7 @@ func (d *DeepSeekClient) makeAPICall(ctx context.Context, req ChatRequest) (*Cha
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", "https://api.deepseek.com/chat/completions", strings.NewReader(string(requestBody)))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", d.config.BaseURL+"/chat/completions", strings.NewReader(string(requestBody)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -241,13 +258,36 @@ func (d *DeepSeekClient) makeAPICall(ctx context.Context, req ChatRequest) (*Cha
 	return &response, nil
 }
 
+// HealthCheck verifies the API is reachable and the key is accepted by listing models,
+// which doesn't consume completion tokens
+func (d *DeepSeekClient) HealthCheck(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", d.config.BaseURL+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+d.config.APIKey)
+
+	resp, err := d.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
 func (d *DeepSeekClient) makeStreamingAPICall(ctx context.Context, req ChatRequest, callback func(string) error) (string, int, error) {
 	requestBody, err := json.Marshal(req)
 	if err != nil {
 		return "", 0, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", "https://api.deepseek.com/chat/completions", strings.NewReader(string(requestBody)))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", d.config.BaseURL+"/chat/completions", strings.NewReader(string(requestBody)))
 	if err != nil {
 		return "", 0, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -318,24 +358,122 @@ func (d *DeepSeekClient) makeStreamingAPICall(ctx context.Context, req ChatReque
 
 // Helper functions
 
-func buildSystemPrompt() string {
-	return `You are an expert code assistant that helps developers understand repositories by analyzing code chunks and answering questions.
+// promptBudget describes how a model's context window is shared out
+type promptBudget struct {
+	ContextTokens    int // Model context window
+	CompletionTokens int // Kept free for the completion
+	HistoryTokens    int // Upper bound for previous turns
+	ReservedTokens   int // Kept free for context added later, e.g. by tool calls
+}
+
+func (d *DeepSeekClient) promptBudget(reservedTokens int) promptBudget {
+	return promptBudget{
+		ContextTokens:    d.config.ContextTokens,
+		CompletionTokens: d.config.MaxTokens,
+		HistoryTokens:    d.config.HistoryTokens,
+		ReservedTokens:   reservedTokens,
+	}
+}
+
+// estimateMessageTokens estimates the prompt tokens of messages
+func estimateMessageTokens(messages []Message) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += estimateTokenCount(message.Content)
+	}
+	return tokens
+}
+
+// buildMessages assembles the conversation sent to the model: system prompt, the most
+// recent history turns that fit the history budget, then the user prompt with as many
+// chunks as fit in what is left of the context window.
+func buildMessages(systemPrompt, query string, chunks []*repocontextv1.CodeChunk, history []Turn, pb promptBudget) []Message {
+	history = trimHistory(history, pb.HistoryTokens)
+
+	budget := pb.ContextTokens - pb.CompletionTokens - pb.ReservedTokens -
+		estimateTokenCount(systemPrompt) - estimateTokenCount(buildUserPrompt(query, nil))
+
+	messages := make([]Message, 0, len(history)*2+2)
+	messages = append(messages, Message{Role: "system", Content: systemPrompt})
+	for _, turn := range history {
+		budget -= estimateTokenCount(turn.Query) + estimateTokenCount(turn.Answer)
+		messages = append(messages,
+			Message{Role: "user", Content: turn.Query},
+			Message{Role: "assistant", Content: turn.Answer},
+		)
+	}
+
+	userPrompt := buildUserPrompt(query, fitChunksToBudget(chunks, budget))
+	return append(messages, Message{Role: "user", Content: userPrompt})
+}
+
+// minTruncatedChunkTokens is the smallest remainder worth filling with a truncated chunk
+const minTruncatedChunkTokens = 100
+
+// fitChunksToBudget keeps the highest-scored chunks whose prompt blocks fit within
+// maxTokens, truncating the first chunk that doesn't fit if enough room is left.
+// Kept chunks stay in their original order.
+func fitChunksToBudget(chunks []*repocontextv1.CodeChunk, maxTokens int) []*repocontextv1.CodeChunk {
+	order := make([]int, len(chunks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return chunks[order[a]].Score > chunks[order[b]].Score
+	})
+
+	kept := make(map[int]*repocontextv1.CodeChunk)
+	remaining := maxTokens
+	for _, idx := range order {
+		chunk := chunks[idx]
+		var block strings.Builder
+		writeChunk(&block, idx+1, chunk)
+		cost := estimateTokenCount(block.String())
+
+		if cost <= remaining {
+			kept[idx] = chunk
+			remaining -= cost
+			continue
+		}
+
+		// Truncate the content of the first chunk that doesn't fit, then stop
+		overhead := cost - estimateTokenCount(chunk.Content)
+		if remaining-overhead >= minTruncatedChunkTokens {
+			truncated := proto.Clone(chunk).(*repocontextv1.CodeChunk)
+			truncated.Content = truncateToTokenLimit(chunk.Content, remaining-overhead)
+			kept[idx] = truncated
+		}
+		break
+	}
 
-Your task is to provide helpful, accurate answers based on the provided code context. Follow these guidelines:
+	fitted := make([]*repocontextv1.CodeChunk, 0, len(kept))
+	for i := range chunks {
+		if chunk, ok := kept[i]; ok {
+			fitted = append(fitted, chunk)
+		}
+	}
 
-1. **Be concise but comprehensive** - Provide direct answers without unnecessary verbosity
-2. **Reference specific code** - When mentioning code elements, reference the file and line numbers
-3. **Explain context** - Help the user understand not just what the code does, but why
-4. **Use proper formatting** - Use markdown for code blocks, lists, and emphasis
-5. **Be honest about limitations** - If the context doesn't contain enough information, say so
-6. **Focus on the question** - Stay relevant to what the user is asking
+	if len(fitted) < len(chunks) {
+		slog.Debug("fitChunksToBudget: Dropped chunks over the token budget", "kept", len(fitted), "chunks", len(chunks), "max_tokens", maxTokens)
+	}
 
-When referencing code:
-- Use the format: ` + "`" + `file_path:line_number` + "`" + ` for specific references
-- Include relevant code snippets when helpful
-- Explain the purpose and context of code elements
+	return fitted
+}
 
-Remember: You can only answer based on the provided code chunks. Don't make assumptions about code that isn't shown.`
+// trimHistory drops the oldest turns until the rest fit within maxTokens
+func trimHistory(history []Turn, maxTokens int) []Turn {
+	tokens := 0
+	start := len(history)
+	for start > 0 {
+		turn := history[start-1]
+		cost := estimateTokenCount(turn.Query) + estimateTokenCount(turn.Answer)
+		if tokens+cost > maxTokens {
+			break
+		}
+		tokens += cost
+		start--
+	}
+	return history[start:]
 }
 
 func buildUserPrompt(query string, chunks []*repocontextv1.CodeChunk) string {
@@ -345,14 +483,7 @@ func buildUserPrompt(query string, chunks []*repocontextv1.CodeChunk) string {
 	prompt.WriteString("**Code Context:**\n\n")
 
 	for i, chunk := range chunks {
-		prompt.WriteString(fmt.Sprintf("**File %d:** `%s` (lines %d-%d)\n", i+1, chunk.FilePath, chunk.StartLine, chunk.EndLine))
-		if chunk.Language != "" && chunk.Language != "unknown" {
-			prompt.WriteString(fmt.Sprintf("```%s\n", chunk.Language))
-		} else {
-			prompt.WriteString("```\n")
-		}
-		prompt.WriteString(chunk.Content)
-		prompt.WriteString("\n```\n\n")
+		writeChunk(&prompt, i+1, chunk)
 	}
 
 	prompt.WriteString("Please analyze this code context and answer the question. Reference specific files and line numbers when relevant.")
@@ -360,33 +491,86 @@ func buildUserPrompt(query string, chunks []*repocontextv1.CodeChunk) string {
 	return prompt.String()
 }
 
+func writeChunk(prompt *strings.Builder, index int, chunk *repocontextv1.CodeChunk) {
+	prompt.WriteString(fmt.Sprintf("**File %d:** `%s` (lines %d-%d)\n", index, chunk.FilePath, chunk.StartLine, chunk.EndLine))
+	if chunk.Language != "" && chunk.Language != "unknown" {
+		prompt.WriteString(fmt.Sprintf("```%s\n", chunk.Language))
+	} else {
+		prompt.WriteString("```\n")
+	}
+	prompt.WriteString(chunk.Content)
+	prompt.WriteString("\n```\n\n")
+}
+
+// citationPattern matches file_path:line_number references such as
+// `internal/api/chat.go:42` or `chat.go:42-50`.
+var citationPattern = regexp.MustCompile(`([\w./-]+\.\w+):(\d+)(?:-\d+)?`)
+
+// extractCitations finds file:line references in the response and resolves them
+// against the supplied chunks. References that don't fall inside a chunk are ignored.
 func extractCitations(response string, chunks []*repocontextv1.CodeChunk) []*repocontextv1.Citation {
 	var citations []*repocontextv1.Citation
+	seen := make(map[string]bool)
 
-	// Look for file references in the response
-	for _, chunk := range chunks {
-		if strings.Contains(response, chunk.FilePath) {
-			// Extract a short excerpt from the chunk for the citation
-			excerpt := chunk.Content
-			if len(excerpt) > 100 {
-				lines := strings.Split(excerpt, "\n")
-				if len(lines) > 2 {
-					excerpt = strings.Join(lines[:2], "\n") + "..."
-				} else {
-					excerpt = excerpt[:100] + "..."
-				}
-			}
+	for _, match := range citationPattern.FindAllStringSubmatch(response, -1) {
+		path := strings.TrimPrefix(match[1], "./")
+		line, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
 
-			citation := &repocontextv1.Citation{
-				FilePath:   chunk.FilePath,
-				LineNumber: chunk.StartLine,
-				Excerpt:    excerpt,
-			}
+		chunk := findChunkForLine(chunks, path, int32(line))
+		if chunk == nil {
+			continue
+		}
 
-			citations = append(citations, citation)
+		key := fmt.Sprintf("%s:%d", chunk.FilePath, line)
+		if seen[key] {
+			continue
 		}
+		seen[key] = true
+
+		citations = append(citations, &repocontextv1.Citation{
+			FilePath:   chunk.FilePath,
+			LineNumber: int32(line),
+			Excerpt:    chunkLine(chunk, int32(line)),
+		})
 	}
 
 	return citations
 }
 
+// findChunkForLine returns the chunk containing line in path. The model may shorten
+// paths, so a suffix match on a path boundary is accepted.
+func findChunkForLine(chunks []*repocontextv1.CodeChunk, path string, line int32) *repocontextv1.CodeChunk {
+	for _, chunk := range chunks {
+		if chunk.FilePath != path && !strings.HasSuffix(chunk.FilePath, "/"+path) {
+			continue
+		}
+		if line >= chunk.StartLine && line <= chunk.EndLine {
+			return chunk
+		}
+	}
+	return nil
+}
+
+// chunkLine returns the referenced line of a chunk, trimmed for use as an excerpt
+func chunkLine(chunk *repocontextv1.CodeChunk, line int32) string {
+	lines := strings.Split(chunk.Content, "\n")
+	idx := int(line - chunk.StartLine)
+	if idx < 0 || idx >= len(lines) {
+		return ""
+	}
+
+	excerpt := strings.TrimSpace(lines[idx])
+	if len(excerpt) > 100 {
+		// Cut on a UTF-8 boundary so the excerpt stays valid text
+		cut := 100
+		for cut > 0 && !utf8.RuneStart(excerpt[cut]) {
+			cut--
+		}
+		excerpt = excerpt[:cut] + "..."
+	}
+	return excerpt
+}
+
@@ -0,0 +1,111 @@
+package composer
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"repo-context-service/internal/config"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+func TestChunkLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		wantLen int
+	}{
+		{"short line", "return nil", len("return nil")},
+		{"ascii cut at 100 bytes", strings.Repeat("a", 150), 103},
+		// "é" is two bytes, so byte 100 falls inside a rune
+		{"multibyte cut before a split rune", "x" + strings.Repeat("é", 80), 99 + len("...")},
+		{"cjk", strings.Repeat("世", 60), 99 + len("...")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk := &repocontextv1.CodeChunk{StartLine: 10, Content: "func f() {\n\t" + tt.line + "\n}"}
+
+			excerpt := chunkLine(chunk, 11)
+			if !utf8.ValidString(excerpt) {
+				t.Fatalf("excerpt isn't valid UTF-8: %q", excerpt)
+			}
+			if len(excerpt) != tt.wantLen {
+				t.Errorf("excerpt is %d bytes, want %d: %q", len(excerpt), tt.wantLen, excerpt)
+			}
+		})
+	}
+
+	if got := chunkLine(&repocontextv1.CodeChunk{StartLine: 10, Content: "x"}, 12); got != "" {
+		t.Errorf("line outside the chunk = %q, want empty", got)
+	}
+}
+
+func TestComposeAnswerSendsHistory(t *testing.T) {
+	api := &fakeChatAPI{replies: []Message{{Role: "assistant", Content: "In widget.go."}}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	client := newTestDeepSeekClient(server.URL, config.DeepSeekConfig{MaxTokens: 500, ContextTokens: 8000, HistoryTokens: 1000})
+	history := []Turn{{Query: "What is a widget?", Answer: "Widgets are structs."}}
+	chunks := []*repocontextv1.CodeChunk{{FilePath: "widget.go", StartLine: 1, EndLine: 5, Content: "type Widget struct{}"}}
+
+	if _, err := client.ComposeAnswer(context.Background(), "Where is it defined?", chunks, history); err != nil {
+		t.Fatalf("ComposeAnswer error = %v", err)
+	}
+
+	if len(api.requests) != 1 {
+		t.Fatalf("API got %d requests, want 1", len(api.requests))
+	}
+	// System prompt, the first exchange, then the new question
+	messages := api.requests[0].Messages
+	if len(messages) != 4 {
+		t.Fatalf("prompt has %d messages, want 4: %+v", len(messages), messages)
+	}
+	if messages[1].Role != "user" || messages[1].Content != "What is a widget?" ||
+		messages[2].Role != "assistant" || messages[2].Content != "Widgets are structs." {
+		t.Errorf("prompt history = %+v, %+v; want the first exchange", messages[1], messages[2])
+	}
+	if messages[3].Role != "user" || !strings.Contains(messages[3].Content, "Where is it defined?") {
+		t.Errorf("last message = %+v, want the new question", messages[3])
+	}
+}
+
+func TestBuildMessagesKeepsPromptUnderBudget(t *testing.T) {
+	budget := promptBudget{ContextTokens: 2000, CompletionTokens: 500, HistoryTokens: 200}
+	chunks := []*repocontextv1.CodeChunk{
+		{FilePath: "low.go", StartLine: 1, EndLine: 100, Score: 0.2, Content: strings.Repeat("l", 4000)},
+		{FilePath: "high.go", StartLine: 1, EndLine: 50, Score: 0.9, Content: strings.Repeat("h", 2000)},
+		{FilePath: "mid.go", StartLine: 1, EndLine: 50, Score: 0.6, Content: strings.Repeat("m", 2000)},
+		{FilePath: "lowest.go", StartLine: 1, EndLine: 100, Score: 0.1, Content: strings.Repeat("x", 4000)},
+	}
+	history := []Turn{{Query: strings.Repeat("q", 2000), Answer: "old"}, {Query: "recent", Answer: "answer"}}
+
+	messages := buildMessages(defaultSystemPrompt, "What does it do?", chunks, history, budget)
+
+	if tokens := estimateMessageTokens(messages); tokens > budget.ContextTokens-budget.CompletionTokens {
+		t.Errorf("prompt is %d tokens, over the %d left for it", tokens, budget.ContextTokens-budget.CompletionTokens)
+	}
+	// Only the recent turn fits the history budget
+	if len(messages) != 4 || messages[1].Content != "recent" {
+		t.Fatalf("prompt = %d messages starting %q, want only the recent turn kept", len(messages), messages[1].Content)
+	}
+
+	prompt := messages[len(messages)-1].Content
+	for _, full := range []*repocontextv1.CodeChunk{chunks[1], chunks[2]} {
+		if !strings.Contains(prompt, full.Content) {
+			t.Errorf("prompt is missing all of %s, one of the highest-scored chunks", full.FilePath)
+		}
+	}
+	// The best of the rest is truncated into the room left, and the lowest is dropped
+	if !strings.Contains(prompt, "low.go") || strings.Contains(prompt, chunks[0].Content) {
+		t.Error("low.go was not truncated into the remaining budget")
+	}
+	if strings.Contains(prompt, "lowest.go") {
+		t.Error("lowest.go was kept though the budget was spent")
+	}
+	if strings.Index(prompt, "low.go") > strings.Index(prompt, "high.go") {
+		t.Error("kept chunks are not in their original order")
+	}
+}
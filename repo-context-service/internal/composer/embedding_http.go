@@ -0,0 +1,150 @@
+package composer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+)
+
+// HTTPEmbeddingClient generates embeddings through an OpenAI-compatible embeddings
+// endpoint, such as a local model server (text-embeddings-inference, vLLM, Ollama)
+// or a hosted API with an OpenAI compatibility layer
+type HTTPEmbeddingClient struct {
+	config     config.EmbeddingConfig
+	httpClient *http.Client
+	metrics    *observability.Metrics
+	tracer     *observability.Tracer
+}
+
+type httpEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type httpEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func NewHTTPEmbeddingClient(cfg config.EmbeddingConfig, metrics *observability.Metrics, tracer *observability.Tracer) *HTTPEmbeddingClient {
+	return &HTTPEmbeddingClient{
+		config: cfg,
+		httpClient: &http.Client{
+			Timeout: cfg.HTTPTimeout,
+		},
+		metrics: metrics,
+		tracer:  tracer,
+	}
+}
+
+func (c *HTTPEmbeddingClient) GenerateEmbeddings(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	ctx, span := c.tracer.Start(ctx, "http.embeddings")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.ModelAttr(model),
+		observability.ResultCountAttr(len(texts)),
+	)
+
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += c.config.HTTPBatchSize {
+		end := start + c.config.HTTPBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		batch, err := c.generateEmbeddingsBatch(ctx, texts[start:end], model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate embeddings for batch %d-%d: %w", start, end, err)
+		}
+		embeddings = append(embeddings, batch...)
+	}
+
+	return embeddings, nil
+}
+
+func (c *HTTPEmbeddingClient) generateEmbeddingsBatch(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	timer := observability.StartTimer()
+	defer func() {
+		c.metrics.RecordBackendLatency("embedding_http", timer.Duration())
+	}()
+
+	requestBody, err := json.Marshal(httpEmbeddingRequest{Model: model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.config.HTTPURL, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.config.HTTPAPIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.config.HTTPAPIKey)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		c.metrics.RecordEmbeddingRequest(model, "error")
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.metrics.RecordEmbeddingRequest(model, "error")
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var response httpEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		c.metrics.RecordEmbeddingRequest(model, "error")
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	c.metrics.RecordEmbeddingRequest(model, "success")
+	c.metrics.RecordEmbeddingTokens(model, estimateBatchTokens(texts))
+
+	if len(response.Data) != len(texts) {
+		return nil, fmt.Errorf("response embedding count mismatch: got %d, expected %d", len(response.Data), len(texts))
+	}
+
+	// Servers may return the embeddings out of order, so place them by index
+	embeddings := make([][]float32, len(texts))
+	for _, data := range response.Data {
+		if data.Index < 0 || data.Index >= len(texts) || embeddings[data.Index] != nil {
+			return nil, fmt.Errorf("response has an invalid embedding index %d", data.Index)
+		}
+		if len(data.Embedding) != c.config.Dimensions {
+			return nil, fmt.Errorf("embedding has %d dimensions, expected %d (EMBEDDING_DIMENSIONS)", len(data.Embedding), c.config.Dimensions)
+		}
+		embeddings[data.Index] = data.Embedding
+	}
+
+	return embeddings, nil
+}
+
+// HealthCheck verifies the endpoint answers by embedding a short text
+func (c *HTTPEmbeddingClient) HealthCheck(ctx context.Context) error {
+	if _, err := c.generateEmbeddingsBatch(ctx, []string{"health check"}, c.GetDefaultModel()); err != nil {
+		return fmt.Errorf("failed to generate embedding: %w", err)
+	}
+	return nil
+}
+
+func (c *HTTPEmbeddingClient) GetDefaultModel() string {
+	return c.config.HTTPModel
+}
+
+func (c *HTTPEmbeddingClient) Dimensions() int {
+	return c.config.Dimensions
+}
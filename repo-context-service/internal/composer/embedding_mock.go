@@ -0,0 +1,73 @@
+package composer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// defaultMockDimensions matches text-embedding-3-small, so mock and OpenAI indexes
+// have the same shape
+const defaultMockDimensions = 1536
+
+// MockEmbeddingClient returns deterministic unit vectors derived from a hash of each
+// text, without calling any API. Identical texts get identical vectors, so indexing
+// and search can be exercised in tests and local development.
+type MockEmbeddingClient struct {
+	dimensions int
+}
+
+func NewMockEmbeddingClient(dimensions int) *MockEmbeddingClient {
+	if dimensions <= 0 {
+		dimensions = defaultMockDimensions
+	}
+	return &MockEmbeddingClient{dimensions: dimensions}
+}
+
+func (c *MockEmbeddingClient) GenerateEmbeddings(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = c.embed(text)
+	}
+	return embeddings, nil
+}
+
+// embed expands a hash of text into a normalized vector
+func (c *MockEmbeddingClient) embed(text string) []float32 {
+	vector := make([]float32, c.dimensions)
+	seed := sha256.Sum256([]byte(text))
+
+	var norm float64
+	var block [sha256.Size]byte
+	for i := range vector {
+		if i%(sha256.Size/4) == 0 {
+			counter := make([]byte, 4)
+			binary.BigEndian.PutUint32(counter, uint32(i))
+			block = sha256.Sum256(append(seed[:], counter...))
+		}
+		offset := (i % (sha256.Size / 4)) * 4
+		value := float64(binary.BigEndian.Uint32(block[offset:]))/math.MaxUint32*2 - 1
+		vector[i] = float32(value)
+		norm += value * value
+	}
+
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
+
+func (c *MockEmbeddingClient) GetDefaultModel() string {
+	return fmt.Sprintf("mock-%d", c.dimensions)
+}
+
+func (c *MockEmbeddingClient) Dimensions() int {
+	return c.dimensions
+}
@@ -2,7 +2,10 @@ package composer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
 	"time"
 
 	"repo-context-service/internal/config"
@@ -18,7 +21,11 @@ type OpenAIEmbeddingClient struct {
 }
 
 func NewOpenAIEmbeddingClient(cfg config.OpenAIConfig, metrics *observability.Metrics, tracer *observability.Tracer) *OpenAIEmbeddingClient {
-	client := openai.NewClient(cfg.APIKey)
+	clientConfig := openai.DefaultConfig(cfg.APIKey)
+	clientConfig.HTTPClient = &http.Client{
+		Transport: &retryAfterTransport{base: http.DefaultTransport},
+	}
+	client := openai.NewClientWithConfig(clientConfig)
 
 	return &OpenAIEmbeddingClient{
 		client:  client,
@@ -41,24 +48,64 @@ func (c *OpenAIEmbeddingClient) GenerateEmbeddings(ctx context.Context, texts []
 		return nil, nil
 	}
 
-	// OpenAI has limits on batch size and token count
-	// Process in batches to stay under limits
-	batchSize := 100 // Conservative batch size
-	var allEmbeddings [][]float32
+	// OpenAI limits the inputs and tokens per request, so texts are sent in batches
+	// bounded by both
+	batches := createBatches(texts, c.config.EmbeddingBatchSize, c.config.EmbeddingBatchTokens)
+	allEmbeddings := make([][]float32, len(texts))
 
-	for i := 0; i < len(texts); i += batchSize {
-		end := i + batchSize
-		if end > len(texts) {
-			end = len(texts)
-		}
+	// Up to EmbeddingConcurrency batches are in flight; the first failure cancels the rest
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-		batch := texts[i:end]
-		embeddings, err := c.generateEmbeddingsBatch(ctx, batch, model)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate embeddings for batch %d-%d: %w", i, end, err)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	jobs := make(chan EmbeddingBatch)
+	workers := c.config.EmbeddingConcurrency
+	if workers > len(batches) {
+		workers = len(batches)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range jobs {
+				embeddings, err := c.generateEmbeddingsBatch(ctx, batch.Texts, model)
+				if err != nil {
+					errOnce.Do(func() {
+						first, last := batch.Indices[0], batch.Indices[len(batch.Indices)-1]
+						firstErr = fmt.Errorf("failed to generate embeddings for batch %d-%d: %w", first, last+1, err)
+						cancel()
+					})
+					continue
+				}
+
+				// Each batch writes its own indexes, so results keep the input order
+				for j, index := range batch.Indices {
+					allEmbeddings[index] = embeddings[j]
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, batch := range batches {
+		select {
+		case jobs <- batch:
+		case <-ctx.Done():
+			break dispatch
 		}
+	}
+	close(jobs)
+	wg.Wait()
 
-		allEmbeddings = append(allEmbeddings, embeddings...)
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
 	return allEmbeddings, nil
@@ -75,16 +122,9 @@ func (c *OpenAIEmbeddingClient) generateEmbeddingsBatch(ctx context.Context, tex
 		return nil, fmt.Errorf("embedding model parameter is empty")
 	}
 
-	// Create request with model string (newer models not supported as constants in v1.15.3)
-	var embeddingModel openai.EmbeddingModel
-	switch model {
-	case "text-embedding-ada-002":
-		embeddingModel = openai.AdaEmbeddingV2
-	default:
-		// For newer models (text-embedding-3-*) that aren't supported as constants,
-		// we need to use a different approach or fall back to ada-002
-		embeddingModel = openai.AdaEmbeddingV2
-		fmt.Printf("WARNING: Model %s not supported as constant, falling back to text-embedding-ada-002\n", model)
+	embeddingModel := requestEmbeddingModel(model)
+	if embeddingModel.String() != model {
+		observability.Logger(ctx).Warn("generateEmbeddingsBatch: Model not supported as constant, falling back", "model", model, "fallback", embeddingModel.String())
 	}
 
 	req := openai.EmbeddingRequestStrings{
@@ -95,15 +135,24 @@ func (c *OpenAIEmbeddingClient) generateEmbeddingsBatch(ctx context.Context, tex
 	// Set timeout context
 	timeoutCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
 	defer cancel()
+	timeoutCtx, retryAfter := withRetryAfterHint(timeoutCtx)
 
 	// Make API call
 	resp, err := c.client.CreateEmbeddings(timeoutCtx, req)
 	if err != nil {
 		c.metrics.RecordEmbeddingRequest(model, "error")
-		return nil, fmt.Errorf("OpenAI embeddings API call failed: %w", err)
+		return nil, &embeddingRequestError{
+			err:        fmt.Errorf("OpenAI embeddings API call failed: %w", err),
+			retryAfter: retryAfter.get(),
+		}
 	}
 
 	c.metrics.RecordEmbeddingRequest(model, "success")
+	tokens := resp.Usage.PromptTokens
+	if tokens == 0 {
+		tokens = estimateBatchTokens(texts)
+	}
+	c.metrics.RecordEmbeddingTokens(model, tokens)
 
 	// Extract embeddings
 	if len(resp.Data) != len(texts) {
@@ -118,6 +167,30 @@ func (c *OpenAIEmbeddingClient) generateEmbeddingsBatch(ctx context.Context, tex
 	return embeddings, nil
 }
 
+// HealthCheck verifies the API is reachable and the key is accepted by listing models
+func (c *OpenAIEmbeddingClient) HealthCheck(ctx context.Context) error {
+	if _, err := c.client.ListModels(ctx); err != nil {
+		return fmt.Errorf("failed to list models: %w", err)
+	}
+	return nil
+}
+
+// requestEmbeddingModel returns the model sent for model: newer models aren't supported
+// as constants in v1.15.3, so everything but ada-002 falls back to it
+func requestEmbeddingModel(model string) openai.EmbeddingModel {
+	switch model {
+	case "text-embedding-ada-002":
+		return openai.AdaEmbeddingV2
+	default:
+		return openai.AdaEmbeddingV2
+	}
+}
+
+// Dimensions is the vector size of the default model, as actually requested
+func (c *OpenAIEmbeddingClient) Dimensions() int {
+	return c.GetEmbeddingDimensions(requestEmbeddingModel(c.GetDefaultModel()).String())
+}
+
 func (c *OpenAIEmbeddingClient) GetEmbeddingDimensions(model string) int {
 	// Return dimensions for known models
 	switch model {
@@ -160,6 +233,15 @@ func estimateTokenCount(text string) int {
 	return len(text) / 4
 }
 
+// estimateBatchTokens estimates the input tokens of an embedding batch
+func estimateBatchTokens(texts []string) int {
+	tokens := 0
+	for _, text := range texts {
+		tokens += estimateTokenCount(text)
+	}
+	return tokens
+}
+
 // Helper function to truncate text to fit within token limits
 func truncateToTokenLimit(text string, maxTokens int) string {
 	estimatedTokens := estimateTokenCount(text)
@@ -217,7 +299,9 @@ func createBatches(texts []string, batchSize int, maxTokensPerBatch int) []Embed
 	return batches
 }
 
-// Advanced embedding generation with retry logic
+// GenerateEmbeddingsWithRetry retries rate limits, server errors and network
+// failures with exponential backoff, waiting as long as a Retry-After header asks
+// instead when there is one. Rejected requests (400, 401, ...) fail immediately.
 func (c *OpenAIEmbeddingClient) GenerateEmbeddingsWithRetry(ctx context.Context, texts []string, model string, maxRetries int) ([][]float32, error) {
 	var lastErr error
 
@@ -229,13 +313,21 @@ func (c *OpenAIEmbeddingClient) GenerateEmbeddingsWithRetry(ctx context.Context,
 
 		lastErr = err
 
+		if !isRetryableEmbeddingError(err) {
+			return nil, err
+		}
+
 		// Don't retry on the last attempt
 		if attempt == maxRetries {
 			break
 		}
 
-		// Exponential backoff
+		// Exponential backoff, unless the API said how long to wait
 		backoffDuration := time.Duration(1<<uint(attempt)) * time.Second
+		var requestErr *embeddingRequestError
+		if errors.As(err, &requestErr) && requestErr.retryAfter > 0 {
+			backoffDuration = requestErr.retryAfter
+		}
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
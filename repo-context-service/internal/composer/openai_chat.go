@@ -0,0 +1,167 @@
+package composer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenAIChatClient composes answers with the OpenAI chat completions API
+type OpenAIChatClient struct {
+	client       *openai.Client
+	config       config.OpenAIConfig
+	systemPrompt *SystemPrompt
+	metrics      *observability.Metrics
+	tracer       *observability.Tracer
+}
+
+func NewOpenAIChatClient(cfg config.OpenAIConfig, systemPrompt *SystemPrompt, metrics *observability.Metrics, tracer *observability.Tracer) *OpenAIChatClient {
+	return &OpenAIChatClient{
+		client:       openai.NewClient(cfg.APIKey),
+		config:       cfg,
+		systemPrompt: systemPrompt,
+		metrics:      metrics,
+		tracer:       tracer,
+	}
+}
+
+func (c *OpenAIChatClient) ComposeAnswer(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []Turn) (*CompositionResult, error) {
+	ctx, span := c.tracer.StartLLMCall(ctx, c.config.ChatModel)
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.ModelAttr(c.config.ChatModel),
+		observability.QueryAttr(query),
+		observability.ResultCountAttr(len(chunks)),
+	)
+
+	timer := observability.StartTimer()
+	defer func() {
+		c.metrics.RecordBackendLatency("openai", timer.Duration())
+	}()
+
+	resp, err := c.client.CreateChatCompletion(ctx, c.buildRequest(ctx, query, chunks, history))
+	if err != nil {
+		c.metrics.RecordLLMRequest(c.config.ChatModel, "error")
+		return nil, fmt.Errorf("OpenAI chat completion failed: %w", err)
+	}
+
+	c.metrics.RecordLLMRequest(c.config.ChatModel, "success")
+	c.metrics.RecordLLMTokens(c.config.ChatModel, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in response")
+	}
+
+	fullResponse := resp.Choices[0].Message.Content
+	citations := extractCitations(fullResponse, chunks)
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(len(citations)),
+	)
+
+	return &CompositionResult{
+		FullResponse:     fullResponse,
+		Citations:        citations,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TokenCount:       resp.Usage.TotalTokens,
+		Duration:         timer.Duration(),
+	}, nil
+}
+
+func (c *OpenAIChatClient) ComposeAnswerStream(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []Turn, callback func(string) error) (*CompositionResult, error) {
+	ctx, span := c.tracer.StartLLMCall(ctx, c.config.ChatModel)
+	defer span.End()
+
+	timer := observability.StartTimer()
+	defer func() {
+		c.metrics.RecordBackendLatency("openai", timer.Duration())
+	}()
+
+	req := c.buildRequest(ctx, query, chunks, history)
+	stream, err := c.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		c.metrics.RecordLLMRequest(c.config.ChatModel, "error")
+		return nil, fmt.Errorf("OpenAI streaming chat completion failed: %w", err)
+	}
+	defer stream.Close()
+
+	var fullResponse strings.Builder
+	tokenCount := 0
+
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			c.metrics.RecordLLMRequest(c.config.ChatModel, "error")
+			return nil, fmt.Errorf("failed to read stream: %w", err)
+		}
+
+		if len(resp.Choices) == 0 {
+			continue
+		}
+
+		delta := resp.Choices[0].Delta.Content
+		if delta == "" {
+			continue
+		}
+
+		fullResponse.WriteString(delta)
+		tokenCount++
+
+		// Send token to callback
+		if err := callback(delta); err != nil {
+			return nil, fmt.Errorf("callback error: %w", err)
+		}
+	}
+
+	c.metrics.RecordLLMRequest(c.config.ChatModel, "success")
+	// No usage arrives on the stream, so tokens are estimated as for DeepSeek
+	promptTokens := 0
+	for _, message := range req.Messages {
+		promptTokens += estimateTokenCount(message.Content)
+	}
+	c.metrics.RecordLLMTokens(c.config.ChatModel, promptTokens, tokenCount)
+
+	return &CompositionResult{
+		FullResponse:     fullResponse.String(),
+		Citations:        extractCitations(fullResponse.String(), chunks),
+		PromptTokens:     promptTokens,
+		CompletionTokens: tokenCount,
+		TokenCount:       promptTokens + tokenCount,
+		Duration:         timer.Duration(),
+	}, nil
+}
+
+func (c *OpenAIChatClient) buildRequest(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []Turn) openai.ChatCompletionRequest {
+	budget := promptBudget{
+		ContextTokens:    c.config.ChatContextTokens,
+		CompletionTokens: c.config.ChatMaxTokens,
+		HistoryTokens:    c.config.ChatHistoryTokens,
+	}
+
+	var messages []openai.ChatCompletionMessage
+	for _, msg := range buildMessages(c.systemPrompt.Render(ctx), query, chunks, history, budget) {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		})
+	}
+
+	return openai.ChatCompletionRequest{
+		Model:       c.config.ChatModel,
+		Messages:    messages,
+		MaxTokens:   c.config.ChatMaxTokens,
+		Temperature: c.config.Temperature,
+	}
+}
@@ -0,0 +1,100 @@
+package composer
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newTestOpenAIChatClient points an OpenAIChatClient at a fake API
+func newTestOpenAIChatClient(baseURL string) *OpenAIChatClient {
+	clientConfig := openai.DefaultConfig("test-key")
+	clientConfig.BaseURL = baseURL
+
+	return &OpenAIChatClient{
+		client: openai.NewClientWithConfig(clientConfig),
+		config: config.OpenAIConfig{
+			ChatModel:         "test-model",
+			ChatMaxTokens:     500,
+			ChatContextTokens: 8000,
+			ChatHistoryTokens: 1000,
+		},
+		metrics: observability.NewMetrics(),
+		tracer:  observability.NewNoOpTracer(),
+	}
+}
+
+func TestComposers(t *testing.T) {
+	const answer = "Widgets are defined in `widget.go:2` as structs."
+	chunks := []*repocontextv1.CodeChunk{{FilePath: "widget.go", StartLine: 1, EndLine: 3, Content: "package widgets\ntype Widget struct{}\n"}}
+	history := []Turn{{Query: "What is this repo?", Answer: "A widget library."}}
+
+	composers := []struct {
+		name string
+		new  func(baseURL string) Composer
+	}{
+		{"deepseek", func(baseURL string) Composer {
+			return newTestDeepSeekClient(baseURL, config.DeepSeekConfig{MaxTokens: 500, ContextTokens: 8000, HistoryTokens: 1000, StreamTokens: true})
+		}},
+		{"openai", func(baseURL string) Composer { return newTestOpenAIChatClient(baseURL) }},
+	}
+	for _, c := range composers {
+		t.Run(c.name, func(t *testing.T) {
+			api := &fakeChatAPI{replies: []Message{{Role: "assistant", Content: answer}}}
+			server := httptest.NewServer(api)
+			defer server.Close()
+			composer := c.new(server.URL)
+
+			result, err := composer.ComposeAnswer(context.Background(), "Where are widgets defined?", chunks, history)
+			if err != nil {
+				t.Fatalf("ComposeAnswer error = %v", err)
+			}
+			if result.FullResponse != answer {
+				t.Errorf("FullResponse = %q, want %q", result.FullResponse, answer)
+			}
+			if len(result.Citations) != 1 || result.Citations[0].FilePath != "widget.go" || result.Citations[0].LineNumber != 2 {
+				t.Errorf("citations = %v, want widget.go:2", result.Citations)
+			}
+			if result.PromptTokens != 50 || result.CompletionTokens != 10 || result.TokenCount != 60 {
+				t.Errorf("usage = %d+%d=%d, want the API's 50+10=60", result.PromptTokens, result.CompletionTokens, result.TokenCount)
+			}
+
+			var tokens []string
+			streamed, err := composer.ComposeAnswerStream(context.Background(), "Where are widgets defined?", chunks, history, func(token string) error {
+				tokens = append(tokens, token)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ComposeAnswerStream error = %v", err)
+			}
+			if len(tokens) != len(strings.Fields(answer)) || strings.Join(tokens, "") != answer {
+				t.Errorf("streamed tokens %q, want the answer a word at a time", tokens)
+			}
+			if streamed.FullResponse != answer || len(streamed.Citations) != 1 {
+				t.Errorf("streamed result = %q with %d citations, want the answer citing widget.go", streamed.FullResponse, len(streamed.Citations))
+			}
+			if streamed.CompletionTokens != len(tokens) || streamed.PromptTokens == 0 || streamed.TokenCount != streamed.PromptTokens+streamed.CompletionTokens {
+				t.Errorf("streamed usage = %d+%d=%d, want an estimate", streamed.PromptTokens, streamed.CompletionTokens, streamed.TokenCount)
+			}
+
+			// Both requests carry the model, the history and the question
+			if len(api.requests) != 2 {
+				t.Fatalf("API got %d requests, want 2", len(api.requests))
+			}
+			for i, req := range api.requests {
+				if req.Model != "test-model" || req.Stream != (i == 1) {
+					t.Errorf("request %d = model %q stream %v", i, req.Model, req.Stream)
+				}
+				if len(req.Messages) != 4 || req.Messages[1].Content != "What is this repo?" || !strings.Contains(req.Messages[3].Content, "Where are widgets defined?") {
+					t.Errorf("request %d messages = %+v", i, req.Messages)
+				}
+			}
+		})
+	}
+}
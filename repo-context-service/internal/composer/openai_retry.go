@@ -0,0 +1,133 @@
+package composer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxRetryAfter caps how long a Retry-After header can make a retry wait
+const maxRetryAfter = 2 * time.Minute
+
+// go-openai doesn't expose response headers, so the transport records Retry-After
+// into a hint carried by the request context
+
+type retryAfterKey struct{}
+
+type retryAfterHint struct {
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+func (h *retryAfterHint) set(delay time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.delay = delay
+}
+
+func (h *retryAfterHint) get() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.delay
+}
+
+func withRetryAfterHint(ctx context.Context) (context.Context, *retryAfterHint) {
+	hint := &retryAfterHint{}
+	return context.WithValue(ctx, retryAfterKey{}, hint), hint
+}
+
+// retryAfterTransport records the Retry-After header of throttled responses
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if hint, ok := req.Context().Value(retryAfterKey{}).(*retryAfterHint); ok {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			hint.set(delay)
+		}
+	}
+	return resp, nil
+}
+
+// parseRetryAfter reads a Retry-After value given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		delay = time.Duration(seconds * float64(time.Second))
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = date.Sub(now)
+	} else {
+		return 0, false
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > maxRetryAfter {
+		delay = maxRetryAfter
+	}
+	return delay, true
+}
+
+// embeddingRequestError is a failed embeddings call with the delay the API asked for
+type embeddingRequestError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *embeddingRequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *embeddingRequestError) Unwrap() error {
+	return e.err
+}
+
+// httpStatusCode returns the HTTP status of a go-openai error, or 0 if the request
+// got no response
+func httpStatusCode(err error) int {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode
+	}
+	return 0
+}
+
+// isRetryableEmbeddingError reports whether retrying err could succeed: rate limits,
+// server errors and network failures are retried, rejected requests are not
+func isRetryableEmbeddingError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	code := httpStatusCode(err)
+	switch {
+	case code == 0:
+		return true
+	case code == http.StatusTooManyRequests || code == http.StatusRequestTimeout:
+		return true
+	case code >= 500:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,208 @@
+package composer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	"github.com/sashabaranov/go-openai"
+)
+
+const embeddingResponse = `{"object":"list","data":[{"object":"embedding","embedding":[0.1,0.2],"index":0}],"model":"text-embedding-ada-002","usage":{"prompt_tokens":1,"total_tokens":1}}`
+
+// newTestEmbeddingClient points an OpenAIEmbeddingClient at a fake API
+func newTestEmbeddingClient(baseURL string) *OpenAIEmbeddingClient {
+	clientConfig := openai.DefaultConfig("test-key")
+	clientConfig.BaseURL = baseURL
+	clientConfig.HTTPClient = &http.Client{
+		Transport: &retryAfterTransport{base: http.DefaultTransport},
+	}
+
+	return &OpenAIEmbeddingClient{
+		client: openai.NewClientWithConfig(clientConfig),
+		config: config.OpenAIConfig{
+			Timeout:              5 * time.Second,
+			EmbeddingBatchSize:   10,
+			EmbeddingBatchTokens: 10000,
+			EmbeddingConcurrency: 1,
+		},
+		metrics: observability.NewMetrics(),
+		tracer:  observability.NewNoOpTracer(),
+	}
+}
+
+func TestGenerateEmbeddingsWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		firstStatus  int
+		retryAfter   string
+		wantErr      bool
+		wantRequests int32
+	}{
+		{"rate limit is retried after Retry-After", http.StatusTooManyRequests, "0.01", false, 2},
+		{"bad request isn't retried", http.StatusBadRequest, "", true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if atomic.AddInt32(&requests, 1) == 1 {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.firstStatus)
+					w.Write([]byte(`{"error":{"message":"rejected","type":"test"}}`))
+					return
+				}
+				w.Write([]byte(embeddingResponse))
+			}))
+			defer server.Close()
+
+			client := newTestEmbeddingClient(server.URL)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			embeddings, err := client.GenerateEmbeddingsWithRetry(ctx, []string{"func main() {}"}, "text-embedding-ada-002", 3)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateEmbeddingsWithRetry error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(embeddings) != 1 {
+				t.Errorf("got %d embeddings, want 1", len(embeddings))
+			}
+			if got := atomic.LoadInt32(&requests); got != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+// embeddingAPI is a fake embeddings endpoint embedding each input as its length,
+// recording the number of inputs in every request and how many were in flight at
+// once. Each request takes at least delay.
+type embeddingAPI struct {
+	delay time.Duration
+
+	mu          sync.Mutex
+	batches     []int
+	inFlight    int
+	maxInFlight int
+}
+
+func (a *embeddingAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req openai.EmbeddingRequestStrings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	a.batches = append(a.batches, len(req.Input))
+	a.inFlight++
+	if a.inFlight > a.maxInFlight {
+		a.maxInFlight = a.inFlight
+	}
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		a.inFlight--
+		a.mu.Unlock()
+	}()
+	time.Sleep(a.delay)
+
+	resp := openai.EmbeddingResponse{Object: "list", Model: req.Model}
+	for i, text := range req.Input {
+		resp.Data = append(resp.Data, openai.Embedding{Object: "embedding", Embedding: []float32{float32(len(text))}, Index: i})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestGenerateEmbeddingsBatchSizes(t *testing.T) {
+	// Each text is 40 characters, so about 10 tokens
+	text := strings.Repeat("x", 40)
+
+	tests := []struct {
+		name        string
+		texts       int
+		batchSize   int
+		batchTokens int
+		want        []int
+	}{
+		{"batch size", 25, 10, 10000, []int{10, 10, 5}},
+		{"token limit", 7, 100, 30, []int{3, 3, 1}},
+		{"one batch", 5, 100, 10000, []int{5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &embeddingAPI{}
+			server := httptest.NewServer(api)
+			defer server.Close()
+			client := newTestEmbeddingClient(server.URL)
+			client.config.EmbeddingBatchSize = tt.batchSize
+			client.config.EmbeddingBatchTokens = tt.batchTokens
+
+			texts := make([]string, tt.texts)
+			for i := range texts {
+				texts[i] = text
+			}
+			embeddings, err := client.GenerateEmbeddings(context.Background(), texts, "text-embedding-ada-002")
+			if err != nil {
+				t.Fatalf("GenerateEmbeddings error = %v", err)
+			}
+
+			if len(embeddings) != tt.texts {
+				t.Errorf("got %d embeddings, want %d", len(embeddings), tt.texts)
+			}
+			if fmt.Sprint(api.batches) != fmt.Sprint(tt.want) {
+				t.Errorf("batches = %v, want %v", api.batches, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateEmbeddingsConcurrency(t *testing.T) {
+	api := &embeddingAPI{delay: 20 * time.Millisecond}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	client := newTestEmbeddingClient(server.URL)
+	client.config.EmbeddingBatchSize = 2
+	client.config.EmbeddingConcurrency = 3
+
+	// Text i is i+1 characters long, so its embedding says which text it was
+	texts := make([]string, 20)
+	for i := range texts {
+		texts[i] = strings.Repeat("x", i+1)
+	}
+	embeddings, err := client.GenerateEmbeddings(context.Background(), texts, "text-embedding-ada-002")
+	if err != nil {
+		t.Fatalf("GenerateEmbeddings error = %v", err)
+	}
+
+	if len(api.batches) != 10 {
+		t.Errorf("sent %d batches, want 10", len(api.batches))
+	}
+	if api.maxInFlight > 3 {
+		t.Errorf("%d batches were in flight at once, over the limit of 3", api.maxInFlight)
+	}
+	if api.maxInFlight < 2 {
+		t.Errorf("at most %d batch was in flight at once, want them sent concurrently", api.maxInFlight)
+	}
+	if len(embeddings) != len(texts) {
+		t.Fatalf("got %d embeddings, want %d", len(embeddings), len(texts))
+	}
+	for i, embedding := range embeddings {
+		if len(embedding) != 1 || embedding[0] != float32(i+1) {
+			t.Errorf("embedding %d = %v, want the one for text %d", i, embedding, i)
+		}
+	}
+}
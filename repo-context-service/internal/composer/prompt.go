@@ -0,0 +1,104 @@
+package composer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+)
+
+// defaultSystemPrompt is sent when no system prompt is configured
+const defaultSystemPrompt = `You are an expert code assistant that helps developers understand repositories by analyzing code chunks and answering questions.
+
+Your task is to provide helpful, accurate answers based on the provided code context. Follow these guidelines:
+
+1. **Be concise but comprehensive** - Provide direct answers without unnecessary verbosity
+2. **Reference specific code** - When mentioning code elements, reference the file and line numbers
+3. **Explain context** - Help the user understand not just what the code does, but why
+4. **Use proper formatting** - Use markdown for code blocks, lists, and emphasis
+5. **Be honest about limitations** - If the context doesn't contain enough information, say so
+6. **Focus on the question** - Stay relevant to what the user is asking
+
+When referencing code:
+- Use the format: ` + "`" + `file_path:line_number` + "`" + ` for specific references
+- Include relevant code snippets when helpful
+- Explain the purpose and context of code elements
+
+Remember: You can only answer based on the provided code chunks. Don't make assumptions about code that isn't shown.`
+
+// PromptVars are the values a system prompt template can refer to, e.g.
+// {{.RepositoryName}}
+type PromptVars struct {
+	RepositoryName string
+	// Language is the repository's main language, by lines of code
+	Language string
+}
+
+type promptVarsKey struct{}
+
+// WithPromptVars returns a context whose compositions render the system prompt with vars
+func WithPromptVars(ctx context.Context, vars PromptVars) context.Context {
+	return context.WithValue(ctx, promptVarsKey{}, vars)
+}
+
+func promptVarsFrom(ctx context.Context) PromptVars {
+	vars, _ := ctx.Value(promptVarsKey{}).(PromptVars)
+	return vars
+}
+
+// SystemPrompt renders the system prompt of each composition from a text/template.
+// A nil SystemPrompt renders the built-in prompt.
+type SystemPrompt struct {
+	template *template.Template
+}
+
+// NewSystemPrompt parses the configured system prompt template, read from
+// COMPOSER_SYSTEM_PROMPT_FILE or COMPOSER_SYSTEM_PROMPT, or the built-in prompt.
+// The template is rendered once with sample values so that mistakes, such as an
+// unknown variable, are reported at startup rather than on the first chat.
+func NewSystemPrompt(cfg config.ComposerConfig) (*SystemPrompt, error) {
+	text := defaultSystemPrompt
+	switch {
+	case cfg.SystemPromptFile != "":
+		data, err := os.ReadFile(cfg.SystemPromptFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read system prompt: %w", err)
+		}
+		text = string(data)
+	case cfg.SystemPrompt != "":
+		text = cfg.SystemPrompt
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("system prompt is empty")
+	}
+
+	tmpl, err := template.New("system_prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid system prompt template: %w", err)
+	}
+	if err := tmpl.Execute(&strings.Builder{}, PromptVars{RepositoryName: "example", Language: "go"}); err != nil {
+		return nil, fmt.Errorf("invalid system prompt template: %w", err)
+	}
+
+	return &SystemPrompt{template: tmpl}, nil
+}
+
+// Render returns the system prompt for a composition, filled in with the prompt
+// variables of ctx
+func (p *SystemPrompt) Render(ctx context.Context) string {
+	if p == nil {
+		return defaultSystemPrompt
+	}
+
+	var prompt strings.Builder
+	if err := p.template.Execute(&prompt, promptVarsFrom(ctx)); err != nil {
+		// The template was checked at startup, so this shouldn't happen
+		observability.Logger(ctx).Warn("SystemPrompt: Failed to render system prompt, using the default", "error", err)
+		return defaultSystemPrompt
+	}
+	return prompt.String()
+}
@@ -0,0 +1,55 @@
+package composer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"repo-context-service/internal/config"
+)
+
+func TestSystemPrompt(t *testing.T) {
+	promptFile := filepath.Join(t.TempDir(), "prompt.tmpl")
+	if err := os.WriteFile(promptFile, []byte("Answer questions about {{.RepositoryName}}."), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	vars := PromptVars{RepositoryName: "payments", Language: "python"}
+
+	tests := []struct {
+		name    string
+		cfg     config.ComposerConfig
+		want    string
+		wantErr bool
+	}{
+		{"built-in", config.ComposerConfig{}, defaultSystemPrompt, false},
+		{"inline template", config.ComposerConfig{SystemPrompt: "You help with {{.RepositoryName}}, written in {{.Language}}."}, "You help with payments, written in python.", false},
+		{"file wins over inline", config.ComposerConfig{SystemPromptFile: promptFile, SystemPrompt: "ignored"}, "Answer questions about payments.", false},
+		{"unknown variable", config.ComposerConfig{SystemPrompt: "You help with {{.Repo}}."}, "", true},
+		{"bad syntax", config.ComposerConfig{SystemPrompt: "You help with {{.RepositoryName"}, "", true},
+		{"blank", config.ComposerConfig{SystemPrompt: "  \n"}, "", true},
+		{"missing file", config.ComposerConfig{SystemPromptFile: filepath.Join(t.TempDir(), "missing")}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, err := NewSystemPrompt(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSystemPrompt error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := prompt.Render(WithPromptVars(context.Background(), vars)); got != tt.want {
+				t.Errorf("Render = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilSystemPromptRendersDefault(t *testing.T) {
+	var prompt *SystemPrompt
+	if got := prompt.Render(context.Background()); got != defaultSystemPrompt {
+		t.Errorf("nil SystemPrompt rendered %q", got)
+	}
+}
@@ -0,0 +1,219 @@
+package composer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+// ContextFetcher gives the composer access to retrieval while composing an answer,
+// so the model can ask for code that wasn't in the initial top-K.
+type ContextFetcher interface {
+	GetFile(ctx context.Context, path string, startLine, endLine int) (*repocontextv1.CodeChunk, error)
+	Search(ctx context.Context, query string, limit int) ([]*repocontextv1.CodeChunk, error)
+}
+
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+const (
+	toolGetFile = "get_file"
+	toolSearch  = "search"
+
+	// Results returned per search tool call
+	toolSearchLimit = 5
+)
+
+func contextTools() []Tool {
+	return []Tool{
+		{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        toolGetFile,
+				Description: "Read a line range of a file in the repository",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path":       map[string]interface{}{"type": "string", "description": "File path relative to the repository root"},
+						"start_line": map[string]interface{}{"type": "integer", "description": "First line to read (1-based)"},
+						"end_line":   map[string]interface{}{"type": "integer", "description": "Last line to read, 0 for end of file"},
+					},
+					"required": []string{"path"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        toolSearch,
+				Description: "Search the repository for code relevant to a query",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"query": map[string]interface{}{"type": "string", "description": "Search query"},
+					},
+					"required": []string{"query"},
+				},
+			},
+		},
+	}
+}
+
+// ComposeAnswerWithTools composes an answer while letting the model call get_file/search
+// to pull in more context. The loop is bounded by MaxToolIterations and the tokens of
+// fetched context by ToolContextTokens; once either is exhausted the model must answer.
+func (d *DeepSeekClient) ComposeAnswerWithTools(ctx context.Context, query string, chunks []*repocontextv1.CodeChunk, history []Turn, fetcher ContextFetcher) (*CompositionResult, error) {
+	ctx, span := d.tracer.StartLLMCall(ctx, d.config.Model)
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.ModelAttr(d.config.Model),
+		observability.QueryAttr(query),
+		observability.ResultCountAttr(len(chunks)),
+	)
+
+	timer := observability.StartTimer()
+	defer func() {
+		d.metrics.RecordBackendLatency("deepseek", timer.Duration())
+	}()
+
+	systemPrompt := d.systemPrompt.Render(ctx) + "\n\nIf the provided context is not enough to answer, use the available tools to fetch more code before answering."
+	messages := buildMessages(systemPrompt, query, chunks, history, d.promptBudget(d.config.ToolContextTokens))
+
+	// Fetched chunks are added to the context so citations can point at them
+	contextChunks := append([]*repocontextv1.CodeChunk(nil), chunks...)
+	fetchedTokens := 0
+	// Usage of every call, including the ones that fetched context
+	var usage Usage
+
+	for iteration := 0; ; iteration++ {
+		req := ChatRequest{
+			Model:       d.config.Model,
+			Messages:    messages,
+			Temperature: d.config.Temperature,
+			MaxTokens:   d.config.MaxTokens,
+		}
+		toolsOffered := iteration < d.config.MaxToolIterations && fetchedTokens < d.config.ToolContextTokens
+		if toolsOffered {
+			req.Tools = contextTools()
+		}
+
+		response, err := d.makeAPICall(ctx, req)
+		if err != nil {
+			d.metrics.RecordLLMRequest(d.config.Model, "error")
+			return nil, fmt.Errorf("DeepSeek API call failed: %w", err)
+		}
+		d.metrics.RecordLLMRequest(d.config.Model, "success")
+		d.metrics.RecordLLMTokens(d.config.Model, response.Usage.PromptTokens, response.Usage.CompletionTokens)
+
+		if len(response.Choices) == 0 {
+			return nil, fmt.Errorf("no choices in response")
+		}
+		usage.PromptTokens += response.Usage.PromptTokens
+		usage.CompletionTokens += response.Usage.CompletionTokens
+		usage.TotalTokens += response.Usage.TotalTokens
+
+		reply := response.Choices[0].Message
+		if !toolsOffered || len(reply.ToolCalls) == 0 {
+			citations := extractCitations(reply.Content, contextChunks)
+			observability.SetSpanAttributes(span,
+				observability.ResultCountAttr(len(citations)),
+			)
+			return &CompositionResult{
+				FullResponse:     reply.Content,
+				Citations:        citations,
+				PromptTokens:     usage.PromptTokens,
+				CompletionTokens: usage.CompletionTokens,
+				TokenCount:       usage.TotalTokens,
+				Duration:         timer.Duration(),
+			}, nil
+		}
+
+		// Run the requested tools and feed the results back
+		messages = append(messages, reply)
+		for _, call := range reply.ToolCalls {
+			content, fetched, tokens := d.runTool(ctx, fetcher, call, d.config.ToolContextTokens-fetchedTokens)
+			fetchedTokens += tokens
+			contextChunks = append(contextChunks, fetched...)
+			messages = append(messages, Message{Role: "tool", ToolCallID: call.ID, Content: content})
+		}
+	}
+}
+
+// runTool executes a single tool call, returning the message content for the model,
+// the chunks it fetched and their estimated token cost. Fetched chunks beyond
+// budget tokens are dropped.
+func (d *DeepSeekClient) runTool(ctx context.Context, fetcher ContextFetcher, call ToolCall, budget int) (string, []*repocontextv1.CodeChunk, int) {
+	var args struct {
+		Path      string `json:"path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+		Query     string `json:"query"`
+	}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+		return fmt.Sprintf("error: invalid arguments: %v", err), nil, 0
+	}
+
+	var results []*repocontextv1.CodeChunk
+	switch call.Function.Name {
+	case toolGetFile:
+		chunk, err := fetcher.GetFile(ctx, args.Path, args.StartLine, args.EndLine)
+		if err != nil {
+			return fmt.Sprintf("error: %v", err), nil, 0
+		}
+		results = []*repocontextv1.CodeChunk{chunk}
+	case toolSearch:
+		chunks, err := fetcher.Search(ctx, args.Query, toolSearchLimit)
+		if err != nil {
+			return fmt.Sprintf("error: %v", err), nil, 0
+		}
+		results = chunks
+	default:
+		return fmt.Sprintf("error: unknown tool %s", call.Function.Name), nil, 0
+	}
+
+	if len(results) == 0 {
+		return "No results found.", nil, 0
+	}
+
+	var content strings.Builder
+	var kept []*repocontextv1.CodeChunk
+	tokens := 0
+	for _, chunk := range results {
+		var block strings.Builder
+		writeChunk(&block, len(kept)+1, chunk)
+		cost := estimateTokenCount(block.String())
+		if tokens+cost > budget {
+			content.WriteString("(remaining results omitted: context budget exhausted)\n")
+			break
+		}
+		content.WriteString(block.String())
+		kept = append(kept, chunk)
+		tokens += cost
+	}
+
+	return content.String(), kept, tokens
+}
@@ -0,0 +1,125 @@
+package composer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+// fakeChatAPI is a chat completions API answering the requests it gets with replies,
+// in order, and recording each request. Streamed replies are sent a word at a time.
+type fakeChatAPI struct {
+	replies []Message
+
+	mu       sync.Mutex
+	requests []ChatRequest
+}
+
+func (f *fakeChatAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	reply := f.replies[(len(f.requests)-1)%len(f.replies)]
+	f.mu.Unlock()
+
+	if req.Stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, word := range strings.SplitAfter(reply.Content, " ") {
+			data, _ := json.Marshal(StreamResponse{Choices: []Choice{{Delta: Message{Role: "assistant", Content: word}}}})
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ChatResponse{
+		Choices: []Choice{{Message: reply}},
+		Usage:   Usage{PromptTokens: 50, CompletionTokens: 10, TotalTokens: 60},
+	})
+}
+
+// newTestDeepSeekClient points a DeepSeekClient at a fake API
+func newTestDeepSeekClient(baseURL string, cfg config.DeepSeekConfig) *DeepSeekClient {
+	cfg.BaseURL = baseURL
+	cfg.Model = "test-model"
+	cfg.Timeout = 5 * time.Second
+	return NewDeepSeekClient(cfg, nil, observability.NewMetrics(), observability.NewNoOpTracer())
+}
+
+// searchFetcher answers every search with chunks, recording the queries
+type searchFetcher struct {
+	chunks  []*repocontextv1.CodeChunk
+	queries []string
+}
+
+func (f *searchFetcher) GetFile(ctx context.Context, path string, startLine, endLine int) (*repocontextv1.CodeChunk, error) {
+	return nil, nil
+}
+
+func (f *searchFetcher) Search(ctx context.Context, query string, limit int) ([]*repocontextv1.CodeChunk, error) {
+	f.queries = append(f.queries, query)
+	return f.chunks, nil
+}
+
+func TestComposeAnswerWithToolsFetchesContext(t *testing.T) {
+	api := &fakeChatAPI{replies: []Message{
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call-1", Type: "function", Function: ToolCallFunction{Name: toolSearch, Arguments: `{"query":"session store"}`}}}},
+		{Role: "assistant", Content: "Sessions are kept in `internal/session/store.go:12`."},
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	client := newTestDeepSeekClient(server.URL, config.DeepSeekConfig{MaxTokens: 500, ContextTokens: 8000, HistoryTokens: 1000, MaxToolIterations: 3, ToolContextTokens: 2000})
+	fetcher := &searchFetcher{chunks: []*repocontextv1.CodeChunk{
+		{FilePath: "internal/session/store.go", StartLine: 10, EndLine: 14, Content: "type Store struct {\n\tsessions map[string]*Session\n}"},
+	}}
+	initial := []*repocontextv1.CodeChunk{{FilePath: "main.go", StartLine: 1, EndLine: 3, Content: "package main"}}
+
+	result, err := client.ComposeAnswerWithTools(context.Background(), "Where are sessions kept?", initial, nil, fetcher)
+	if err != nil {
+		t.Fatalf("ComposeAnswerWithTools error = %v", err)
+	}
+
+	if len(fetcher.queries) != 1 || fetcher.queries[0] != "session store" {
+		t.Fatalf("fetcher searched for %q, want the model's one query", fetcher.queries)
+	}
+	if len(api.requests) != 2 {
+		t.Fatalf("API got %d requests, want the tool call and the answer", len(api.requests))
+	}
+	if len(api.requests[0].Tools) == 0 {
+		t.Error("first request offered no tools")
+	}
+	// The second prompt holds the fetched code as the tool call's result
+	messages := api.requests[1].Messages
+	last := messages[len(messages)-1]
+	if last.Role != "tool" || last.ToolCallID != "call-1" || !strings.Contains(last.Content, "sessions map[string]*Session") {
+		t.Errorf("last message of the second prompt = %+v, want the fetched chunk as the result of call-1", last)
+	}
+	if previous := messages[len(messages)-2]; len(previous.ToolCalls) != 1 {
+		t.Errorf("the model's tool call is missing before its result: %+v", previous)
+	}
+
+	if result.FullResponse != "Sessions are kept in `internal/session/store.go:12`." {
+		t.Errorf("FullResponse = %q", result.FullResponse)
+	}
+	if len(result.Citations) != 1 || result.Citations[0].FilePath != "internal/session/store.go" {
+		t.Errorf("citations = %v, want the fetched file", result.Citations)
+	}
+	if result.PromptTokens != 100 || result.CompletionTokens != 20 || result.TokenCount != 120 {
+		t.Errorf("usage = %d+%d=%d, want both calls counted", result.PromptTokens, result.CompletionTokens, result.TokenCount)
+	}
+}
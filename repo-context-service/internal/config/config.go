@@ -1,7 +1,9 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -10,14 +12,21 @@ import (
 
 type Config struct {
 	Server     ServerConfig
+	Composer   ComposerConfig
+	Embedding  EmbeddingConfig
 	Redis      RedisConfig
+	VectorStore VectorStoreConfig
 	Weaviate   WeaviateConfig
+	Qdrant     QdrantConfig
+	PgVector   PgVectorConfig
 	OpenAI     OpenAIConfig
 	DeepSeek   DeepSeekConfig
 	Upload     UploadConfig
 	Observability ObservabilityConfig
 	Security   SecurityConfig
 	Defaults   DefaultsConfig
+	Merge      MergeConfig
+	Lexical    LexicalConfig
 }
 
 type ServerConfig struct {
@@ -27,20 +36,82 @@ type ServerConfig struct {
 	Environment  string
 	LogLevel     string
 	GracefulShutdownTimeout time.Duration
+	WebSocketPingInterval   time.Duration
+	WebSocketPongTimeout    time.Duration
+	ChatSessionTTL          time.Duration
+	ChatSessionSweepInterval time.Duration
+	// How often backend health is rechecked for the grpc.health.v1 service
+	HealthCheckInterval     time.Duration
+	// Deadline for the backend checks behind /readyz
+	ReadinessTimeout        time.Duration
+	// Include OpenAI/DeepSeek reachability in health checks; off by default since
+	// each check calls the provider API
+	ProviderHealthChecks    bool
+	ProviderHealthTimeout   time.Duration
+	// Certificate and key the gRPC and HTTP servers serve TLS with; both empty
+	// serves plaintext
+	TLSCertFile string
+	TLSKeyFile  string
+	// Name the gateway and WebSocket bridge verify the certificate against when they
+	// dial the gRPC server over loopback; empty picks one the certificate names
+	TLSServerName string
+}
+
+// defaultNoResultsMessage is the chat answer when the search finds no relevant code
+const defaultNoResultsMessage = "I couldn't find any code in this repository relevant to your question. " +
+	"Try rephrasing it, using names that appear in the code, or widening the search options."
+
+type ComposerConfig struct {
+	Provider string // "deepseek" or "openai"
+	// Answer sent, without calling the model, when a chat search finds nothing
+	NoResultsMessage string
+	// System prompt template, inline or read from a file (at most one is set); empty
+	// uses the built-in prompt
+	SystemPrompt     string
+	SystemPromptFile string
+}
+
+type EmbeddingConfig struct {
+	// "openai", "http" (an OpenAI-compatible embeddings endpoint, such as a local
+	// model server) or "mock" (deterministic vectors for tests)
+	Provider string
+	// Vector size of the http and mock providers; OpenAI's follows from the model
+	Dimensions int
+	HTTPURL    string
+	HTTPAPIKey string
+	HTTPModel  string
+	HTTPTimeout   time.Duration
+	HTTPBatchSize int
 }
 
 type RedisConfig struct {
-	URL      string
-	Password string
-	DB       int
-	PoolSize int
-	TTL      TTLConfig
+	// "standalone" connects to URL; "sentinel" and "cluster" connect to the
+	// sentinel or cluster node addresses
+	Mode               string
+	URL                string
+	Password           string
+	DB                 int
+	PoolSize           int
+	SentinelMasterName string
+	SentinelAddrs      []string
+	SentinelPassword   string
+	ClusterAddrs       []string
+	// Cached query results and repository metadata from this size (bytes) are
+	// gzipped; 0 disables compression
+	CompressThreshold int
+	TTL               TTLConfig
 }
 
 type TTLConfig struct {
 	RepositoryRouting time.Duration
 	QueryResults      time.Duration
 	UploadStatus      time.Duration
+	Embeddings        time.Duration
+	MissingRepository time.Duration
+}
+
+type VectorStoreConfig struct {
+	Backend string // "weaviate", "qdrant" or "pgvector"
 }
 
 type WeaviateConfig struct {
@@ -48,6 +119,33 @@ type WeaviateConfig struct {
 	APIKey string
 	Scheme string
 	Host   string
+	// Run searches of both backends as one Weaviate hybrid query instead of ripgrep
+	// plus vector search
+	HybridSearch bool
+	// Hybrid search weighting: 0 is pure BM25, 1 is pure vector search
+	HybridAlpha float32
+	// Objects sent per batch insert
+	BatchSize int
+	// Timeout of each request to Weaviate, including each batch insert
+	Timeout time.Duration
+}
+
+type QdrantConfig struct {
+	URL     string
+	APIKey  string
+	Timeout time.Duration
+	// Points sent per upsert request
+	BatchSize int
+}
+
+type PgVectorConfig struct {
+	// Postgres connection string; the database needs the vector extension available
+	DSN      string
+	MaxConns int32
+	// Rows sent per insert batch
+	BatchSize int
+	// Approximate index built on each repository table: "hnsw" or "ivfflat"
+	IndexType string
 }
 
 type OpenAIConfig struct {
@@ -56,6 +154,16 @@ type OpenAIConfig struct {
 	MaxTokens   int
 	Temperature float32
 	Timeout     time.Duration
+	// Texts and estimated tokens sent per embeddings request
+	EmbeddingBatchSize   int
+	EmbeddingBatchTokens int
+	// Embeddings requests in flight at once
+	EmbeddingConcurrency int
+	// Chat composition, used when COMPOSER_PROVIDER=openai
+	ChatModel         string
+	ChatMaxTokens     int
+	ChatContextTokens int
+	ChatHistoryTokens int
 }
 
 type DeepSeekConfig struct {
@@ -65,6 +173,11 @@ type DeepSeekConfig struct {
 	Temperature float32
 	Timeout     time.Duration
 	StreamTokens bool
+	BaseURL     string
+	MaxToolIterations int
+	ToolContextTokens int
+	HistoryTokens     int
+	ContextTokens     int
 }
 
 type UploadConfig struct {
@@ -74,6 +187,26 @@ type UploadConfig struct {
 	StorageDir    string
 	AllowedTypes  []string
 	ExcludePatterns []string
+	SparsePaths   []string
+	MaxRepoSizeMB int64
+	RejectOversizedRepos bool
+	MaxExtractedSize     int64
+	MaxExtractedFileSize int64
+	// Ingestions run at once; further ones wait as pending, up to MaxQueuedIngestions
+	MaxConcurrentIngestions int
+	MaxQueuedIngestions     int
+	// Remove working trees of repositories that no longer exist on startup, once
+	// they're older than ReconcileGracePeriod
+	ReconcileOnStartup   bool
+	ReconcileGracePeriod time.Duration
+	// Leave vendored and generated files out of the vector index; lexical search
+	// still finds them
+	SkipGeneratedEmbeddings bool
+	// Strip comments and blank lines from the text that's embedded; chunks keep
+	// their original content
+	StripEmbeddingComments bool
+	// Chunk the markdown cells of Jupyter notebooks along with their code cells
+	NotebookMarkdown bool
 }
 
 type ObservabilityConfig struct {
@@ -90,12 +223,30 @@ type SecurityConfig struct {
 	DefaultTenant  string
 	RateLimit      RateLimitConfig
 	CORS           CORSConfig
+	JWT            JWTConfig
+	// Static key with every scope on the default tenant, for bootstrapping API keys
+	AdminAPIKey    string
+}
+
+// JWTConfig controls bearer token verification. Tokens are verified against the
+// PEM public key in PublicKeyFile, or the keys served at JWKSURL.
+type JWTConfig struct {
+	PublicKeyFile       string
+	JWKSURL             string
+	JWKSRefreshInterval time.Duration
+	Issuer              string
+	Audience            string
+	// Claim holding the caller's tenant ID
+	TenantClaim         string
 }
 
 type RateLimitConfig struct {
 	RequestsPerSecond int
 	BurstSize         int
 	WindowSize        time.Duration
+	// "memory" limits each replica independently; "redis" shares one bucket per
+	// tenant across replicas
+	Backend           string
 }
 
 type CORSConfig struct {
@@ -110,90 +261,283 @@ type DefaultsConfig struct {
 	EmbeddingModel   string
 	ChunkSize        int
 	ChunkOverlap     int
+	PageSize         int
+	MaxPageSize      int
+	// Lines of context read from disk around each semantic hit
+	SemanticContextLines int
+	// Multi-repository search: the most repositories one request may search, and
+	// how many of them are searched at once
+	MaxSearchRepositories int
+	SearchConcurrency     int
+	// Lowest certainty (0-1, Weaviate's scale, (1 + cosine similarity) / 2) a
+	// semantic hit may have; 0 keeps the nearest chunks however far they are
+	SemanticMinCertainty float32
 }
 
+// MaxContextLines caps the lines of context added around each search hit
+const MaxContextLines = 200
+
+// MaxChunkSize caps the lines per chunk; a larger chunk would be truncated to the
+// embedding model's input limit and crowd other results out of the prompt
+const MaxChunkSize = 1000
+
+// MergeConfig tunes how lexical and semantic results are combined. In "zscore" mode
+// each backend's scores are z-score normalized and squashed with a sigmoid; in "rrf"
+// mode chunks are scored by Reciprocal Rank Fusion, 1/(RRFK+rank). Backend weights
+// scale either score; the boosts and penalties are then added to it.
+type MergeConfig struct {
+	Mode           string
+	LexicalWeight  float32
+	SemanticWeight float32
+	RRFK           int
+
+	DualSourceBoost   float32 // File found by both backends (z-score mode only)
+	ShortChunkBoost   float32 // Chunks of at most 10 lines
+	LongChunkPenalty  float32 // Chunks of more than 50 lines
+	LanguageBoost     float32 // Go, JavaScript, TypeScript, Python, Java
+	TestFilePenalty   float32
+	EntryFileBoost    float32 // main., index., app. files
+	DenseContentBoost float32 // Chunks that are more than 70% non-blank lines
+	GeneratedFilePenalty float32 // Vendored and generated code, such as *.pb.go
+
+	// MMREnabled re-orders the ranked chunks by Maximal Marginal Relevance, so chunks
+	// whose vectors are close to a higher-ranked chunk's fall back. MMRLambda weighs
+	// relevance against diversity: 1 ranks by relevance alone, 0 by diversity alone.
+	MMREnabled bool
+	MMRLambda  float32
+}
+
+// LexicalConfig selects the lexical search backend. "ripgrep" shells out to rg,
+// "native" scans the repository in-process, and "auto" uses ripgrep when rg is
+// installed and falls back to the native searcher otherwise. SynonymsFile is an
+// optional JSON or YAML map of term to expansions merged over the built-in ones.
+// ContextLines lines around each match are included in its chunk, and matches
+// whose context overlaps or touches in a file are grouped into one chunk.
+type LexicalConfig struct {
+	Backend      string
+	SynonymsFile string
+	ContextLines int
+}
+
+// Load builds the configuration from environment variables, falling back to the YAML
+// file named by CONFIG_FILE and then to the built-in defaults.
 func Load() (*Config, error) {
+	file := &fileSettings{}
+	path := os.Getenv("CONFIG_FILE")
+	if path != "" {
+		var err error
+		if file, err = loadConfigFile(path); err != nil {
+			return nil, err
+		}
+	}
+
 	config := &Config{
 		Server: ServerConfig{
-			HTTPPort:                getEnvInt("HTTP_PORT", 8080),
-			GRPCPort:                getEnvInt("GRPC_PORT", 9090),
-			AdminPort:               getEnvInt("ADMIN_PORT", 8081),
-			Environment:             getEnvString("ENVIRONMENT", "development"),
-			LogLevel:                getEnvString("LOG_LEVEL", "info"),
-			GracefulShutdownTimeout: getEnvDuration("GRACEFUL_SHUTDOWN_TIMEOUT", 30*time.Second),
+			HTTPPort:                getEnvInt(file, "HTTP_PORT", 8080),
+			GRPCPort:                getEnvInt(file, "GRPC_PORT", 9090),
+			AdminPort:               getEnvInt(file, "ADMIN_PORT", 8081),
+			Environment:             getEnvString(file, "ENVIRONMENT", "development"),
+			LogLevel:                getEnvString(file, "LOG_LEVEL", "info"),
+			GracefulShutdownTimeout: getEnvDuration(file, "GRACEFUL_SHUTDOWN_TIMEOUT", 30*time.Second),
+			WebSocketPingInterval:   getEnvDuration(file, "WS_PING_INTERVAL", 30*time.Second),
+			WebSocketPongTimeout:    getEnvDuration(file, "WS_PONG_TIMEOUT", 60*time.Second),
+			ChatSessionTTL:          getEnvDuration(file, "CHAT_SESSION_TTL", time.Hour),
+			ChatSessionSweepInterval: getEnvDuration(file, "CHAT_SESSION_SWEEP_INTERVAL", time.Minute),
+			HealthCheckInterval:     getEnvDuration(file, "HEALTH_CHECK_INTERVAL", 10*time.Second),
+			ReadinessTimeout:        getEnvDuration(file, "READINESS_TIMEOUT", 2*time.Second),
+			ProviderHealthChecks:    getEnvBool(file, "HEALTH_CHECK_PROVIDERS", false),
+			ProviderHealthTimeout:   getEnvDuration(file, "HEALTH_CHECK_PROVIDER_TIMEOUT", 3*time.Second),
+			TLSCertFile:             getEnvString(file, "TLS_CERT_FILE", ""),
+			TLSKeyFile:              getEnvString(file, "TLS_KEY_FILE", ""),
+			TLSServerName:           getEnvString(file, "TLS_SERVER_NAME", ""),
+		},
+		Composer: ComposerConfig{
+			Provider:         getEnvString(file, "COMPOSER_PROVIDER", "deepseek"),
+			NoResultsMessage: getEnvString(file, "CHAT_NO_RESULTS_MESSAGE", defaultNoResultsMessage),
+			SystemPrompt:     getEnvString(file, "COMPOSER_SYSTEM_PROMPT", ""),
+			SystemPromptFile: getEnvString(file, "COMPOSER_SYSTEM_PROMPT_FILE", ""),
+		},
+		Embedding: EmbeddingConfig{
+			Provider:      getEnvString(file, "EMBEDDING_PROVIDER", "openai"),
+			Dimensions:    getEnvInt(file, "EMBEDDING_DIMENSIONS", 0),
+			HTTPURL:       getEnvString(file, "EMBEDDING_HTTP_URL", ""),
+			HTTPAPIKey:    getEnvString(file, "EMBEDDING_HTTP_API_KEY", ""),
+			HTTPModel:     getEnvString(file, "EMBEDDING_HTTP_MODEL", ""),
+			HTTPTimeout:   getEnvDuration(file, "EMBEDDING_HTTP_TIMEOUT", 30*time.Second),
+			HTTPBatchSize: getEnvInt(file, "EMBEDDING_HTTP_BATCH_SIZE", 32),
 		},
 		Redis: RedisConfig{
-			URL:      getEnvString("REDIS_URL", "redis://localhost:6379"),
-			Password: getEnvString("REDIS_PASSWORD", ""),
-			DB:       getEnvInt("REDIS_DB", 0),
-			PoolSize: getEnvInt("REDIS_POOL_SIZE", 10),
+			Mode:               getEnvString(file, "REDIS_MODE", "standalone"),
+			URL:                getEnvString(file, "REDIS_URL", "redis://localhost:6379"),
+			Password:           getEnvString(file, "REDIS_PASSWORD", ""),
+			DB:                 getEnvInt(file, "REDIS_DB", 0),
+			PoolSize:           getEnvInt(file, "REDIS_POOL_SIZE", 10),
+			SentinelMasterName: getEnvString(file, "REDIS_SENTINEL_MASTER", ""),
+			SentinelAddrs:      getEnvStringSlice(file, "REDIS_SENTINEL_ADDRS", nil),
+			SentinelPassword:   getEnvString(file, "REDIS_SENTINEL_PASSWORD", ""),
+			ClusterAddrs:       getEnvStringSlice(file, "REDIS_CLUSTER_ADDRS", nil),
+			CompressThreshold:  getEnvInt(file, "REDIS_COMPRESS_THRESHOLD", 8192),
 			TTL: TTLConfig{
-				RepositoryRouting: getEnvDuration("REDIS_TTL_REPO_ROUTING", 24*time.Hour),
-				QueryResults:      getEnvDuration("REDIS_TTL_QUERY_RESULTS", 5*time.Minute),
-				UploadStatus:      getEnvDuration("REDIS_TTL_UPLOAD_STATUS", 15*time.Minute),
+				RepositoryRouting: getEnvDuration(file, "REDIS_TTL_REPO_ROUTING", 24*time.Hour),
+				QueryResults:      getEnvDuration(file, "REDIS_TTL_QUERY_RESULTS", 5*time.Minute),
+				UploadStatus:      getEnvDuration(file, "REDIS_TTL_UPLOAD_STATUS", 15*time.Minute),
+				Embeddings:        getEnvDuration(file, "REDIS_TTL_EMBEDDINGS", 30*24*time.Hour),
+				MissingRepository: getEnvDuration(file, "REDIS_TTL_MISSING_REPO", 5*time.Second),
 			},
 		},
+		VectorStore: VectorStoreConfig{
+			Backend: getEnvString(file, "VECTOR_BACKEND", "weaviate"),
+		},
 		Weaviate: WeaviateConfig{
-			URL:    getEnvString("WEAVIATE_URL", "https://your-cluster.weaviate.network"),
-			APIKey: getEnvString("WEAVIATE_API_KEY", ""),
-			Scheme: getEnvString("WEAVIATE_SCHEME", "https"),
-			Host:   getEnvString("WEAVIATE_HOST", "your-cluster.weaviate.network"),
+			URL:    getEnvString(file, "WEAVIATE_URL", "https://your-cluster.weaviate.network"),
+			APIKey: getEnvString(file, "WEAVIATE_API_KEY", ""),
+			Scheme: getEnvString(file, "WEAVIATE_SCHEME", "https"),
+			Host:   getEnvString(file, "WEAVIATE_HOST", "your-cluster.weaviate.network"),
+			HybridSearch: getEnvBool(file, "WEAVIATE_HYBRID_SEARCH", false),
+			HybridAlpha: getEnvFloat32(file, "WEAVIATE_HYBRID_ALPHA", 0.5),
+			BatchSize:   getEnvInt(file, "WEAVIATE_BATCH_SIZE", 100),
+			Timeout:     getEnvDuration(file, "WEAVIATE_TIMEOUT", 60*time.Second),
+		},
+		Qdrant: QdrantConfig{
+			URL:       getEnvString(file, "QDRANT_URL", "http://localhost:6333"),
+			APIKey:    getEnvString(file, "QDRANT_API_KEY", ""),
+			Timeout:   getEnvDuration(file, "QDRANT_TIMEOUT", 30*time.Second),
+			BatchSize: getEnvInt(file, "QDRANT_BATCH_SIZE", 100),
+		},
+		PgVector: PgVectorConfig{
+			DSN:       getEnvString(file, "PGVECTOR_DSN", ""),
+			MaxConns:  int32(getEnvInt(file, "PGVECTOR_MAX_CONNS", 10)),
+			BatchSize: getEnvInt(file, "PGVECTOR_BATCH_SIZE", 100),
+			IndexType: getEnvString(file, "PGVECTOR_INDEX_TYPE", "hnsw"),
 		},
 		OpenAI: OpenAIConfig{
-			APIKey:      getEnvString("OPENAI_API_KEY", ""),
-			Model:       getEnvString("OPENAI_MODEL", "text-embedding-3-small"),
-			MaxTokens:   getEnvInt("OPENAI_MAX_TOKENS", 8191),
-			Temperature: getEnvFloat32("OPENAI_TEMPERATURE", 0.0),
-			Timeout:     getEnvDuration("OPENAI_TIMEOUT", 30*time.Second),
+			APIKey:      getEnvString(file, "OPENAI_API_KEY", ""),
+			Model:       getEnvString(file, "OPENAI_MODEL", "text-embedding-3-small"),
+			MaxTokens:   getEnvInt(file, "OPENAI_MAX_TOKENS", 8191),
+			Temperature: getEnvFloat32(file, "OPENAI_TEMPERATURE", 0.0),
+			Timeout:     getEnvDuration(file, "OPENAI_TIMEOUT", 30*time.Second),
+			// OpenAI accepts up to 2048 inputs and 300k tokens per request
+			EmbeddingBatchSize:   getEnvInt(file, "OPENAI_EMBEDDING_BATCH_SIZE", 100),
+			EmbeddingBatchTokens: getEnvInt(file, "OPENAI_EMBEDDING_BATCH_TOKENS", 250000),
+			EmbeddingConcurrency: getEnvInt(file, "OPENAI_EMBEDDING_CONCURRENCY", 4),
+			ChatModel:         getEnvString(file, "OPENAI_CHAT_MODEL", "gpt-4o-mini"),
+			ChatMaxTokens:     getEnvInt(file, "OPENAI_CHAT_MAX_TOKENS", 4096),
+			ChatContextTokens: getEnvInt(file, "OPENAI_CHAT_CONTEXT_TOKENS", 128000),
+			ChatHistoryTokens: getEnvInt(file, "OPENAI_CHAT_HISTORY_TOKENS", 2000),
 		},
 		DeepSeek: DeepSeekConfig{
-			APIKey:       getEnvString("DEEPSEEK_API_KEY", ""),
-			Model:        getEnvString("DEEPSEEK_MODEL", "deepseek-chat"),
-			MaxTokens:    getEnvInt("DEEPSEEK_MAX_TOKENS", 4096),
-			Temperature:  getEnvFloat32("DEEPSEEK_TEMPERATURE", 0.1),
-			Timeout:      getEnvDuration("DEEPSEEK_TIMEOUT", 60*time.Second),
-			StreamTokens: getEnvBool("DEEPSEEK_STREAM_TOKENS", true),
+			APIKey:       getEnvString(file, "DEEPSEEK_API_KEY", ""),
+			Model:        getEnvString(file, "DEEPSEEK_MODEL", "deepseek-chat"),
+			MaxTokens:    getEnvInt(file, "DEEPSEEK_MAX_TOKENS", 4096),
+			Temperature:  getEnvFloat32(file, "DEEPSEEK_TEMPERATURE", 0.1),
+			Timeout:      getEnvDuration(file, "DEEPSEEK_TIMEOUT", 60*time.Second),
+			StreamTokens: getEnvBool(file, "DEEPSEEK_STREAM_TOKENS", true),
+			BaseURL:      getEnvString(file, "DEEPSEEK_BASE_URL", "https://api.deepseek.com"),
+			// Tool calling lets the model fetch more context; 0 disables it
+			MaxToolIterations: getEnvInt(file, "DEEPSEEK_MAX_TOOL_ITERATIONS", 0),
+			ToolContextTokens: getEnvInt(file, "DEEPSEEK_TOOL_CONTEXT_TOKENS", 4000),
+			// Budget for previous turns of a chat session included in each prompt
+			HistoryTokens: getEnvInt(file, "DEEPSEEK_HISTORY_TOKENS", 2000),
+			// Model context window; prompts are trimmed to fit it alongside MaxTokens of completion
+			ContextTokens: getEnvInt(file, "DEEPSEEK_CONTEXT_TOKENS", 64000),
 		},
 		Upload: UploadConfig{
-			MaxFileSize:  getEnvInt64("UPLOAD_MAX_FILE_SIZE", 100*1024*1024), // 100MB
-			MaxFiles:     getEnvInt("UPLOAD_MAX_FILES", 10000),
-			TempDir:      getEnvString("UPLOAD_TEMP_DIR", "/tmp/repo-uploads"),
-			StorageDir:   getEnvString("UPLOAD_STORAGE_DIR", "./data/repositories"),
-			AllowedTypes: getEnvStringSlice("UPLOAD_ALLOWED_TYPES", []string{".zip", ".tar", ".tar.gz", ".tgz"}),
-			ExcludePatterns: getEnvStringSlice("UPLOAD_EXCLUDE_PATTERNS", []string{
+			MaxFileSize:  getEnvInt64(file, "UPLOAD_MAX_FILE_SIZE", 100*1024*1024), // 100MB
+			// Archive entries extracted per upload
+			MaxFiles:     getEnvInt(file, "UPLOAD_MAX_FILES", 10000),
+			TempDir:      getEnvString(file, "UPLOAD_TEMP_DIR", "/tmp/repo-uploads"),
+			StorageDir:   getEnvString(file, "UPLOAD_STORAGE_DIR", "./data/repositories"),
+			AllowedTypes: getEnvStringSlice(file, "UPLOAD_ALLOWED_TYPES", []string{".zip", ".tar", ".tar.gz", ".tgz"}),
+			ExcludePatterns: getEnvStringSlice(file, "UPLOAD_EXCLUDE_PATTERNS", []string{
 				"node_modules/", "vendor/", ".git/", "*.exe", "*.dll", "*.so", "*.dylib",
 				"*.jpg", "*.png", "*.gif", "*.pdf", "*.mp4", "*.zip", "*.tar.gz",
 			}),
+			// Limit git clones to these paths (sparse checkout); empty clones everything
+			SparsePaths:   getEnvStringSlice(file, "UPLOAD_SPARSE_PATHS", nil),
+			// Estimated repository size above which clones are rejected or warned about; 0 disables
+			MaxRepoSizeMB:        getEnvInt64(file, "UPLOAD_MAX_REPO_SIZE_MB", 0),
+			RejectOversizedRepos: getEnvBool(file, "UPLOAD_REJECT_OVERSIZED_REPOS", true),
+			// Uncompressed bytes an uploaded archive may expand to, in total and per file
+			MaxExtractedSize:     getEnvInt64(file, "UPLOAD_MAX_EXTRACTED_SIZE", 1024*1024*1024),   // 1GB
+			MaxExtractedFileSize: getEnvInt64(file, "UPLOAD_MAX_EXTRACTED_FILE_SIZE", 100*1024*1024), // 100MB
+			MaxConcurrentIngestions: getEnvInt(file, "INGEST_MAX_CONCURRENT", 2),
+			MaxQueuedIngestions:     getEnvInt(file, "INGEST_MAX_QUEUED", 100),
+			ReconcileOnStartup:      getEnvBool(file, "UPLOAD_RECONCILE_ON_STARTUP", false),
+			ReconcileGracePeriod:    getEnvDuration(file, "UPLOAD_RECONCILE_GRACE_PERIOD", time.Hour),
+			SkipGeneratedEmbeddings: getEnvBool(file, "INGEST_SKIP_GENERATED", false),
+			StripEmbeddingComments:  getEnvBool(file, "INGEST_EMBED_STRIP_COMMENTS", false),
+			NotebookMarkdown:        getEnvBool(file, "INGEST_NOTEBOOK_MARKDOWN", true),
 		},
 		Observability: ObservabilityConfig{
-			MetricsEnabled:  getEnvBool("METRICS_ENABLED", true),
-			TracingEnabled:  getEnvBool("TRACING_ENABLED", true),
-			PProfEnabled:    getEnvBool("PPROF_ENABLED", true),
-			TracingEndpoint: getEnvString("TRACING_ENDPOINT", "http://localhost:14268/api/traces"),
-			ServiceName:     getEnvString("SERVICE_NAME", "repo-context-service"),
-			ServiceVersion:  getEnvString("SERVICE_VERSION", "1.0.0"),
+			MetricsEnabled:  getEnvBool(file, "METRICS_ENABLED", true),
+			TracingEnabled:  getEnvBool(file, "TRACING_ENABLED", true),
+			PProfEnabled:    getEnvBool(file, "PPROF_ENABLED", true),
+			TracingEndpoint: getEnvString(file, "TRACING_ENDPOINT", "http://localhost:4318/v1/traces"),
+			ServiceName:     getEnvString(file, "SERVICE_NAME", "repo-context-service"),
+			ServiceVersion:  getEnvString(file, "SERVICE_VERSION", "1.0.0"),
 		},
 		Security: SecurityConfig{
-			RequireAuth:   getEnvBool("REQUIRE_AUTH", false),
-			DefaultTenant: getEnvString("DEFAULT_TENANT", "local"),
+			RequireAuth:   getEnvBool(file, "REQUIRE_AUTH", false),
+			DefaultTenant: getEnvString(file, "DEFAULT_TENANT", "local"),
 			RateLimit: RateLimitConfig{
-				RequestsPerSecond: getEnvInt("RATE_LIMIT_RPS", 100),
-				BurstSize:         getEnvInt("RATE_LIMIT_BURST", 200),
-				WindowSize:        getEnvDuration("RATE_LIMIT_WINDOW", time.Minute),
+				RequestsPerSecond: getEnvInt(file, "RATE_LIMIT_RPS", 100),
+				BurstSize:         getEnvInt(file, "RATE_LIMIT_BURST", 200),
+				WindowSize:        getEnvDuration(file, "RATE_LIMIT_WINDOW", time.Minute),
+				Backend:           getEnvString(file, "RATE_LIMIT_BACKEND", "memory"),
+			},
+			AdminAPIKey:   getEnvString(file, "ADMIN_API_KEY", ""),
+			JWT: JWTConfig{
+				PublicKeyFile:       getEnvString(file, "JWT_PUBLIC_KEY_FILE", ""),
+				JWKSURL:             getEnvString(file, "JWT_JWKS_URL", ""),
+				JWKSRefreshInterval: getEnvDuration(file, "JWT_JWKS_REFRESH_INTERVAL", 10*time.Minute),
+				Issuer:              getEnvString(file, "JWT_ISSUER", ""),
+				Audience:            getEnvString(file, "JWT_AUDIENCE", ""),
+				TenantClaim:         getEnvString(file, "JWT_TENANT_CLAIM", "tenant_id"),
 			},
 			CORS: CORSConfig{
-				AllowedOrigins: getEnvStringSlice("CORS_ALLOWED_ORIGINS", []string{"*"}),
-				AllowedMethods: getEnvStringSlice("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-				AllowedHeaders: getEnvStringSlice("CORS_ALLOWED_HEADERS", []string{"*"}),
+				AllowedOrigins: getEnvStringSlice(file, "CORS_ALLOWED_ORIGINS", []string{"*"}),
+				AllowedMethods: getEnvStringSlice(file, "CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
+				AllowedHeaders: getEnvStringSlice(file, "CORS_ALLOWED_HEADERS", []string{"*"}),
 			},
 		},
 		Defaults: DefaultsConfig{
-			MaxSearchResults: getEnvInt("DEFAULT_MAX_SEARCH_RESULTS", 20),
-			SearchTimeout:    getEnvDuration("DEFAULT_SEARCH_TIMEOUT", 5*time.Second),
-			EmbeddingModel:   getEnvString("DEFAULT_EMBEDDING_MODEL", "text-embedding-3-small"),
-			ChunkSize:        getEnvInt("DEFAULT_CHUNK_SIZE", 100),
-			ChunkOverlap:     getEnvInt("DEFAULT_CHUNK_OVERLAP", 10),
+			MaxSearchResults: getEnvInt(file, "DEFAULT_MAX_SEARCH_RESULTS", 20),
+			SearchTimeout:    getEnvDuration(file, "DEFAULT_SEARCH_TIMEOUT", 5*time.Second),
+			EmbeddingModel:   getEnvString(file, "DEFAULT_EMBEDDING_MODEL", "text-embedding-3-small"),
+			ChunkSize:        getEnvInt(file, "DEFAULT_CHUNK_SIZE", 100),
+			ChunkOverlap:     getEnvInt(file, "DEFAULT_CHUNK_OVERLAP", 10),
+			PageSize:         getEnvInt(file, "DEFAULT_PAGE_SIZE", 20),
+			MaxPageSize:      getEnvInt(file, "MAX_PAGE_SIZE", 100),
+			SemanticContextLines: getEnvInt(file, "DEFAULT_SEMANTIC_CONTEXT_LINES", 0),
+			MaxSearchRepositories: getEnvInt(file, "DEFAULT_MAX_SEARCH_REPOSITORIES", 20),
+			SearchConcurrency:     getEnvInt(file, "DEFAULT_SEARCH_CONCURRENCY", 4),
+			SemanticMinCertainty:  getEnvFloat32(file, "SEMANTIC_MIN_CERTAINTY", 0.7),
+		},
+		Merge: MergeConfig{
+			Mode:              getEnvString(file, "MERGE_MODE", "zscore"),
+			LexicalWeight:     getEnvFloat32(file, "MERGE_LEXICAL_WEIGHT", 1.0),
+			SemanticWeight:    getEnvFloat32(file, "MERGE_SEMANTIC_WEIGHT", 1.0),
+			RRFK:              getEnvInt(file, "MERGE_RRF_K", 60),
+			DualSourceBoost:   getEnvFloat32(file, "MERGE_BOOST_DUAL_SOURCE", 0.15),
+			ShortChunkBoost:   getEnvFloat32(file, "MERGE_BOOST_SHORT_CHUNK", 0.05),
+			LongChunkPenalty:  getEnvFloat32(file, "MERGE_PENALTY_LONG_CHUNK", 0.02),
+			LanguageBoost:     getEnvFloat32(file, "MERGE_BOOST_LANGUAGE", 0.02),
+			TestFilePenalty:   getEnvFloat32(file, "MERGE_PENALTY_TEST_FILE", 0.01),
+			EntryFileBoost:    getEnvFloat32(file, "MERGE_BOOST_ENTRY_FILE", 0.02),
+			DenseContentBoost: getEnvFloat32(file, "MERGE_BOOST_DENSE_CONTENT", 0.03),
+			GeneratedFilePenalty: getEnvFloat32(file, "MERGE_PENALTY_GENERATED_FILE", 0.1),
+			MMREnabled:        getEnvBool(file, "MERGE_MMR_ENABLED", false),
+			MMRLambda:         getEnvFloat32(file, "MERGE_MMR_LAMBDA", 0.7),
 		},
+		Lexical: LexicalConfig{
+			Backend:      getEnvString(file, "LEXICAL_BACKEND", "auto"),
+			SynonymsFile: getEnvString(file, "LEXICAL_SYNONYMS_FILE", ""),
+			ContextLines: getEnvInt(file, "LEXICAL_CONTEXT_LINES", 2),
+		},
+	}
+
+	if err := errors.Join(file.errs...); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
 	}
 
 	if err := config.Validate(); err != nil {
@@ -204,26 +548,279 @@ func Load() (*Config, error) {
 }
 
 func (c *Config) Validate() error {
-	if c.OpenAI.APIKey == "" {
+	if c.OpenAI.APIKey == "" && (c.Embedding.Provider == "openai" || c.Composer.Provider == "openai") {
 		return fmt.Errorf("OPENAI_API_KEY is required")
 	}
 
-	if c.DeepSeek.APIKey == "" {
-		return fmt.Errorf("DEEPSEEK_API_KEY is required")
+	switch c.Embedding.Provider {
+	case "openai":
+	case "http":
+		if c.Embedding.HTTPURL == "" {
+			return fmt.Errorf("EMBEDDING_HTTP_URL is required when EMBEDDING_PROVIDER=http")
+		}
+		if c.Embedding.HTTPModel == "" {
+			return fmt.Errorf("EMBEDDING_HTTP_MODEL is required when EMBEDDING_PROVIDER=http")
+		}
+		if c.Embedding.Dimensions <= 0 {
+			return fmt.Errorf("EMBEDDING_DIMENSIONS must be positive when EMBEDDING_PROVIDER=http")
+		}
+		if c.Embedding.HTTPBatchSize <= 0 {
+			return fmt.Errorf("EMBEDDING_HTTP_BATCH_SIZE must be positive")
+		}
+	case "mock":
+		if c.Embedding.Dimensions < 0 {
+			return fmt.Errorf("EMBEDDING_DIMENSIONS must not be negative")
+		}
+	default:
+		return fmt.Errorf("EMBEDDING_PROVIDER must be one of: openai, http, mock")
+	}
+
+	switch c.VectorStore.Backend {
+	case "weaviate":
+	case "qdrant":
+		if c.Qdrant.URL == "" {
+			return fmt.Errorf("QDRANT_URL is required when VECTOR_BACKEND=qdrant")
+		}
+		if c.Qdrant.BatchSize <= 0 {
+			return fmt.Errorf("QDRANT_BATCH_SIZE must be positive")
+		}
+	case "pgvector":
+		if c.PgVector.DSN == "" {
+			return fmt.Errorf("PGVECTOR_DSN is required when VECTOR_BACKEND=pgvector")
+		}
+		if c.PgVector.MaxConns <= 0 {
+			return fmt.Errorf("PGVECTOR_MAX_CONNS must be positive")
+		}
+		if c.PgVector.BatchSize <= 0 {
+			return fmt.Errorf("PGVECTOR_BATCH_SIZE must be positive")
+		}
+		if c.PgVector.IndexType != "hnsw" && c.PgVector.IndexType != "ivfflat" {
+			return fmt.Errorf("PGVECTOR_INDEX_TYPE must be one of: hnsw, ivfflat")
+		}
+	default:
+		return fmt.Errorf("VECTOR_BACKEND must be one of: weaviate, qdrant, pgvector")
+	}
+
+	switch c.Composer.Provider {
+	case "deepseek":
+		if c.DeepSeek.APIKey == "" {
+			return fmt.Errorf("DEEPSEEK_API_KEY is required")
+		}
+
+		if c.DeepSeek.ContextTokens <= c.DeepSeek.MaxTokens {
+			return fmt.Errorf("DEEPSEEK_CONTEXT_TOKENS must be greater than DEEPSEEK_MAX_TOKENS")
+		}
+	case "openai":
+		if c.OpenAI.ChatContextTokens <= c.OpenAI.ChatMaxTokens {
+			return fmt.Errorf("OPENAI_CHAT_CONTEXT_TOKENS must be greater than OPENAI_CHAT_MAX_TOKENS")
+		}
+	default:
+		return fmt.Errorf("COMPOSER_PROVIDER must be one of: deepseek, openai")
+	}
+
+	if c.Composer.SystemPrompt != "" && c.Composer.SystemPromptFile != "" {
+		return fmt.Errorf("set only one of COMPOSER_SYSTEM_PROMPT and COMPOSER_SYSTEM_PROMPT_FILE")
 	}
 
 	if c.Weaviate.URL == "" {
 		return fmt.Errorf("WEAVIATE_URL is required")
 	}
 
+	if c.Weaviate.HybridAlpha < 0 || c.Weaviate.HybridAlpha > 1 {
+		return fmt.Errorf("WEAVIATE_HYBRID_ALPHA must be between 0 and 1")
+	}
+
+	if c.Weaviate.BatchSize <= 0 {
+		return fmt.Errorf("WEAVIATE_BATCH_SIZE must be positive")
+	}
+
+	if c.Weaviate.Timeout <= 0 {
+		return fmt.Errorf("WEAVIATE_TIMEOUT must be positive")
+	}
+
+	if c.OpenAI.EmbeddingBatchSize <= 0 || c.OpenAI.EmbeddingBatchSize > 2048 {
+		return fmt.Errorf("OPENAI_EMBEDDING_BATCH_SIZE must be between 1 and 2048")
+	}
+
+	if c.OpenAI.EmbeddingBatchTokens <= 0 {
+		return fmt.Errorf("OPENAI_EMBEDDING_BATCH_TOKENS must be positive")
+	}
+
+	if c.OpenAI.EmbeddingConcurrency <= 0 {
+		return fmt.Errorf("OPENAI_EMBEDDING_CONCURRENCY must be positive")
+	}
+
 	if c.Server.HTTPPort == c.Server.GRPCPort {
 		return fmt.Errorf("HTTP_PORT and GRPC_PORT cannot be the same")
 	}
 
+	switch c.Redis.Mode {
+	case "standalone":
+		if err := validateRedisURL(c.Redis.URL); err != nil {
+			return err
+		}
+	case "sentinel":
+		if c.Redis.SentinelMasterName == "" {
+			return fmt.Errorf("REDIS_SENTINEL_MASTER is required when REDIS_MODE=sentinel")
+		}
+		if len(c.Redis.SentinelAddrs) == 0 {
+			return fmt.Errorf("REDIS_SENTINEL_ADDRS is required when REDIS_MODE=sentinel")
+		}
+	case "cluster":
+		if len(c.Redis.ClusterAddrs) == 0 {
+			return fmt.Errorf("REDIS_CLUSTER_ADDRS is required when REDIS_MODE=cluster")
+		}
+		if c.Redis.DB != 0 {
+			return fmt.Errorf("REDIS_DB must be 0 when REDIS_MODE=cluster")
+		}
+	default:
+		return fmt.Errorf("REDIS_MODE must be one of: standalone, sentinel, cluster")
+	}
+
+	if c.Redis.DB < 0 || c.Redis.PoolSize < 0 {
+		return fmt.Errorf("REDIS_DB and REDIS_POOL_SIZE must not be negative")
+	}
+
+	if c.Redis.TTL.MissingRepository < 0 {
+		return fmt.Errorf("REDIS_TTL_MISSING_REPO must not be negative")
+	}
+
+	if c.Redis.CompressThreshold < 0 {
+		return fmt.Errorf("REDIS_COMPRESS_THRESHOLD must not be negative")
+	}
+
+	if c.Server.HealthCheckInterval <= 0 {
+		return fmt.Errorf("HEALTH_CHECK_INTERVAL must be positive")
+	}
+
+	if c.Server.ReadinessTimeout <= 0 {
+		return fmt.Errorf("READINESS_TIMEOUT must be positive")
+	}
+
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	switch strings.ToLower(c.Server.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("LOG_LEVEL must be one of: debug, info, warn, error")
+	}
+
+	if c.Server.WebSocketPingInterval >= c.Server.WebSocketPongTimeout {
+		return fmt.Errorf("WS_PING_INTERVAL must be shorter than WS_PONG_TIMEOUT")
+	}
+
+	switch c.Security.RateLimit.Backend {
+	case "memory", "redis":
+	default:
+		return fmt.Errorf("RATE_LIMIT_BACKEND must be one of: memory, redis")
+	}
+
+	if c.Security.RateLimit.RequestsPerSecond <= 0 || c.Security.RateLimit.BurstSize <= 0 {
+		return fmt.Errorf("RATE_LIMIT_RPS and RATE_LIMIT_BURST must be positive")
+	}
+
+	if c.Security.RateLimit.WindowSize < 0 {
+		return fmt.Errorf("RATE_LIMIT_WINDOW must not be negative")
+	}
+
+	if c.Security.JWT.PublicKeyFile != "" && c.Security.JWT.JWKSURL != "" {
+		return fmt.Errorf("only one of JWT_PUBLIC_KEY_FILE and JWT_JWKS_URL can be set")
+	}
+
+	if c.Security.JWT.TenantClaim == "" {
+		return fmt.Errorf("JWT_TENANT_CLAIM is required")
+	}
+
+	if strings.TrimSpace(c.Upload.StorageDir) == "" {
+		return fmt.Errorf("UPLOAD_STORAGE_DIR is required")
+	}
+
+	if strings.TrimSpace(c.Upload.TempDir) == "" {
+		return fmt.Errorf("UPLOAD_TEMP_DIR is required")
+	}
+
 	if c.Upload.MaxFileSize <= 0 {
 		return fmt.Errorf("UPLOAD_MAX_FILE_SIZE must be positive")
 	}
 
+	if c.Upload.MaxFiles <= 0 {
+		return fmt.Errorf("UPLOAD_MAX_FILES must be positive")
+	}
+
+	if c.Upload.MaxExtractedSize <= 0 || c.Upload.MaxExtractedFileSize <= 0 {
+		return fmt.Errorf("UPLOAD_MAX_EXTRACTED_SIZE and UPLOAD_MAX_EXTRACTED_FILE_SIZE must be positive")
+	}
+
+	if c.Upload.MaxConcurrentIngestions <= 0 {
+		return fmt.Errorf("INGEST_MAX_CONCURRENT must be positive")
+	}
+
+	if c.Upload.MaxQueuedIngestions < 0 {
+		return fmt.Errorf("INGEST_MAX_QUEUED must not be negative")
+	}
+
+	if c.Upload.ReconcileGracePeriod < 0 {
+		return fmt.Errorf("UPLOAD_RECONCILE_GRACE_PERIOD must not be negative")
+	}
+
+	if c.Defaults.ChunkSize <= 0 || c.Defaults.ChunkSize > MaxChunkSize {
+		return fmt.Errorf("DEFAULT_CHUNK_SIZE must be between 1 and %d", MaxChunkSize)
+	}
+
+	if c.Defaults.ChunkOverlap < 0 || c.Defaults.ChunkOverlap >= c.Defaults.ChunkSize {
+		return fmt.Errorf("DEFAULT_CHUNK_OVERLAP must be at least 0 and less than DEFAULT_CHUNK_SIZE")
+	}
+
+	if c.Defaults.PageSize <= 0 || c.Defaults.PageSize > c.Defaults.MaxPageSize {
+		return fmt.Errorf("DEFAULT_PAGE_SIZE must be between 1 and MAX_PAGE_SIZE")
+	}
+
+	if c.Defaults.SemanticContextLines < 0 || c.Defaults.SemanticContextLines > MaxContextLines {
+		return fmt.Errorf("DEFAULT_SEMANTIC_CONTEXT_LINES must be between 0 and %d", MaxContextLines)
+	}
+
+	if c.Defaults.MaxSearchRepositories <= 0 {
+		return fmt.Errorf("DEFAULT_MAX_SEARCH_REPOSITORIES must be positive")
+	}
+
+	if c.Defaults.SearchConcurrency <= 0 {
+		return fmt.Errorf("DEFAULT_SEARCH_CONCURRENCY must be positive")
+	}
+
+	if c.Defaults.SemanticMinCertainty < 0 || c.Defaults.SemanticMinCertainty >= 1 {
+		return fmt.Errorf("SEMANTIC_MIN_CERTAINTY must be at least 0 and less than 1")
+	}
+
+	switch c.Merge.Mode {
+	case "zscore", "rrf":
+	default:
+		return fmt.Errorf("MERGE_MODE must be one of: zscore, rrf")
+	}
+
+	if c.Merge.LexicalWeight < 0 || c.Merge.SemanticWeight < 0 || c.Merge.LexicalWeight+c.Merge.SemanticWeight == 0 {
+		return fmt.Errorf("MERGE_LEXICAL_WEIGHT and MERGE_SEMANTIC_WEIGHT must not be negative and cannot both be 0")
+	}
+
+	if c.Merge.RRFK <= 0 {
+		return fmt.Errorf("MERGE_RRF_K must be positive")
+	}
+
+	if c.Merge.MMRLambda < 0 || c.Merge.MMRLambda > 1 {
+		return fmt.Errorf("MERGE_MMR_LAMBDA must be between 0 and 1")
+	}
+
+	switch c.Lexical.Backend {
+	case "auto", "ripgrep", "native":
+	default:
+		return fmt.Errorf("LEXICAL_BACKEND must be one of: auto, ripgrep, native")
+	}
+
+	if c.Lexical.ContextLines < 0 || c.Lexical.ContextLines > MaxContextLines {
+		return fmt.Errorf("LEXICAL_CONTEXT_LINES must be between 0 and %d", MaxContextLines)
+	}
+
 	return nil
 }
 
@@ -235,62 +832,104 @@ func (c *Config) IsProduction() bool {
 	return c.Server.Environment == "production"
 }
 
-// Helper functions for environment variables
-func getEnvString(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+// TLSEnabled reports whether the gRPC and HTTP servers serve TLS
+func (s *ServerConfig) TLSEnabled() bool {
+	return s.TLSCertFile != "" && s.TLSKeyFile != ""
+}
+
+// validateRedisURL rejects REDIS_URL values the Redis client cannot parse, so they fail
+// at startup rather than on the first connection
+func validateRedisURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("REDIS_URL is required")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("REDIS_URL is not a valid URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "redis", "rediss":
+		if u.Host == "" {
+			return fmt.Errorf("REDIS_URL must include a host")
+		}
+	case "unix":
+		if u.Path == "" {
+			return fmt.Errorf("REDIS_URL must include a socket path")
+		}
+	default:
+		return fmt.Errorf("REDIS_URL must use the redis://, rediss:// or unix:// scheme")
+	}
+	return nil
+}
+
+// Helper functions for environment variables, falling back to CONFIG_FILE values
+func getEnvString(file *fileSettings, key, defaultValue string) string {
+	if value, _ := file.lookup(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
 
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
+func getEnvInt(file *fileSettings, key string, defaultValue int) int {
+	if value, fromFile := file.lookup(key); value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err == nil {
 			return intValue
 		}
+		file.invalid(key, fromFile, err)
 	}
 	return defaultValue
 }
 
-func getEnvInt64(key string, defaultValue int64) int64 {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+func getEnvInt64(file *fileSettings, key string, defaultValue int64) int64 {
+	if value, fromFile := file.lookup(key); value != "" {
+		intValue, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
 			return intValue
 		}
+		file.invalid(key, fromFile, err)
 	}
 	return defaultValue
 }
 
-func getEnvFloat32(key string, defaultValue float32) float32 {
-	if value := os.Getenv(key); value != "" {
-		if floatValue, err := strconv.ParseFloat(value, 32); err == nil {
+func getEnvFloat32(file *fileSettings, key string, defaultValue float32) float32 {
+	if value, fromFile := file.lookup(key); value != "" {
+		floatValue, err := strconv.ParseFloat(value, 32)
+		if err == nil {
 			return float32(floatValue)
 		}
+		file.invalid(key, fromFile, err)
 	}
 	return defaultValue
 }
 
-func getEnvBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if boolValue, err := strconv.ParseBool(value); err == nil {
+func getEnvBool(file *fileSettings, key string, defaultValue bool) bool {
+	if value, fromFile := file.lookup(key); value != "" {
+		boolValue, err := strconv.ParseBool(value)
+		if err == nil {
 			return boolValue
 		}
+		file.invalid(key, fromFile, err)
 	}
 	return defaultValue
 }
 
-func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
+func getEnvDuration(file *fileSettings, key string, defaultValue time.Duration) time.Duration {
+	if value, fromFile := file.lookup(key); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err == nil {
 			return duration
 		}
+		file.invalid(key, fromFile, err)
 	}
 	return defaultValue
 }
 
-func getEnvStringSlice(key string, defaultValue []string) []string {
-	if value := os.Getenv(key); value != "" {
+func getEnvStringSlice(file *fileSettings, key string, defaultValue []string) []string {
+	if value, _ := file.lookup(key); value != "" {
 		return strings.Split(value, ",")
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
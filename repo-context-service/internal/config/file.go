@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileSettings holds the settings read from CONFIG_FILE, keyed by environment variable
+// name; values is nil when no file is configured. A file value that doesn't parse as
+// its setting's type is recorded in errs, which Load reports, rather than falling
+// back to the default as an unparseable environment variable does.
+type fileSettings struct {
+	values map[string]string
+	errs   []error
+}
+
+// loadConfigFile reads the YAML file at path into environment-style settings. Keys may
+// be written flat (WEAVIATE_URL: ...) or nested (weaviate: {url: ...}); nested keys
+// are joined with underscores and upper-cased, and lists become comma-separated values.
+func loadConfigFile(path string) (*fileSettings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flattenConfig("", raw, values); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &fileSettings{values: values}, nil
+}
+
+func flattenConfig(prefix string, node map[string]interface{}, values map[string]string) error {
+	keys := make([]string, 0, len(node))
+	for key := range node {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := strings.ToUpper(key)
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		switch value := node[key].(type) {
+		case nil:
+			continue
+		case map[string]interface{}:
+			if err := flattenConfig(name, value, values); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, len(value))
+			for i, item := range value {
+				switch item.(type) {
+				case map[string]interface{}, []interface{}:
+					return fmt.Errorf("%s: list items must be scalar values", name)
+				}
+				items[i] = formatScalar(item)
+			}
+			values[name] = strings.Join(items, ",")
+		default:
+			if _, exists := values[name]; exists {
+				return fmt.Errorf("%s is set more than once", name)
+			}
+			values[name] = formatScalar(value)
+		}
+	}
+	return nil
+}
+
+// formatScalar writes a YAML scalar the way it would be set in the environment. YAML
+// decodes 1e8 and 100000000.0 as floats, which are written out in full so that integer
+// settings still parse.
+func formatScalar(value interface{}) string {
+	switch value := value.(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case time.Time:
+		return value.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// lookup returns the value of a setting: the environment variable when set, then the
+// config file, and "" otherwise so the caller falls back to its default. fromFile
+// reports whether the value came from the config file.
+func (f *fileSettings) lookup(key string) (value string, fromFile bool) {
+	if value := os.Getenv(key); value != "" {
+		return value, false
+	}
+	value, fromFile = f.values[key]
+	return value, fromFile
+}
+
+// invalid records that the value of key didn't parse, if it came from the config file
+func (f *fileSettings) invalid(key string, fromFile bool, err error) {
+	if fromFile {
+		f.errs = append(f.errs, fmt.Errorf("%s: %w", key, err))
+	}
+}
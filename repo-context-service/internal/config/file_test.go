@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("DEEPSEEK_API_KEY", "test-key")
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	writeConfigFile(t, `
+HTTP_PORT: 8088
+upload:
+  max_file_size: 1e8
+  allowed_types: [.zip, .tgz]
+graceful_shutdown_timeout: 45s
+`)
+	t.Setenv("GRPC_PORT", "9099")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load error = %v", err)
+	}
+	if cfg.Server.HTTPPort != 8088 || cfg.Server.GRPCPort != 9099 {
+		t.Errorf("ports = %d, %d; want 8088 from the file and 9099 from the environment", cfg.Server.HTTPPort, cfg.Server.GRPCPort)
+	}
+	if cfg.Upload.MaxFileSize != 100000000 {
+		t.Errorf("MaxFileSize = %d, want 1e8 read as 100000000", cfg.Upload.MaxFileSize)
+	}
+	if strings.Join(cfg.Upload.AllowedTypes, ",") != ".zip,.tgz" {
+		t.Errorf("AllowedTypes = %v", cfg.Upload.AllowedTypes)
+	}
+	if cfg.Server.GracefulShutdownTimeout != 45*time.Second {
+		t.Errorf("GracefulShutdownTimeout = %v", cfg.Server.GracefulShutdownTimeout)
+	}
+}
+
+func TestLoadConfigFileReportsInvalidValues(t *testing.T) {
+	writeConfigFile(t, `
+http_port: 80.5
+graceful_shutdown_timeout: 30
+`)
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected an error for values that don't parse")
+	}
+	for _, key := range []string{"HTTP_PORT", "GRACEFUL_SHUTDOWN_TIMEOUT"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error %q doesn't name %s", err, key)
+		}
+	}
+}
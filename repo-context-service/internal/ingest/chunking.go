@@ -1,11 +1,9 @@
 package ingest
 
 import (
-	"bufio"
 	"context"
 	"crypto/sha256"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -23,37 +21,45 @@ func (ip *InlineProcessor) ChunkFiles(ctx context.Context, extractResult *Extrac
 	excludeRegexes := compilePatterns(options.ExcludePatterns)
 	includeRegexes := compilePatterns(options.IncludePatterns)
 
-	log.Printf("ChunkFiles: Starting with %d files", len(extractResult.Files))
+	logger := ip.loggerFrom(ctx)
+	logger.Info("ChunkFiles: Starting", "files", len(extractResult.Files))
 	if len(extractResult.Files) == 0 {
-		log.Printf("ChunkFiles: No files found in extractResult!")
+		logger.Warn("ChunkFiles: No files found in extract result")
 		return allChunks, nil
 	}
 
-	for _, fileInfo := range extractResult.Files {
-		log.Printf("ChunkFiles: Processing file %s (IsText: %v, IsBinary: %v, Size: %d)",
-			fileInfo.Path, fileInfo.IsText, fileInfo.IsBinary, fileInfo.Size)
+	progress := progressTrackerFrom(ctx)
+	for i, fileInfo := range extractResult.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		progress.FilesChunked(i, len(allChunks))
+
+		logger.Debug("ChunkFiles: Processing file",
+			"path", fileInfo.Path, "is_text", fileInfo.IsText, "is_binary", fileInfo.IsBinary, "size", fileInfo.Size)
 		// Skip if file matches exclude patterns
 		if matchesPatterns(fileInfo.Path, excludeRegexes) {
-			log.Printf("ChunkFiles: Skipping %s (matches exclude pattern)", fileInfo.Path)
+			logger.Debug("ChunkFiles: Skipping file matching exclude pattern", "path", fileInfo.Path)
 			continue
 		}
 
 		// Skip if include patterns are specified and file doesn't match
 		if len(includeRegexes) > 0 && !matchesPatterns(fileInfo.Path, includeRegexes) {
-			log.Printf("ChunkFiles: Skipping %s (doesn't match include pattern)", fileInfo.Path)
+			logger.Debug("ChunkFiles: Skipping file not matching include pattern", "path", fileInfo.Path)
 			continue
 		}
 
 		// Skip if file is too large
 		if options.MaxFileSize > 0 && fileInfo.Size > options.MaxFileSize {
-			log.Printf("ChunkFiles: Skipping %s (too large: %d > %d)", fileInfo.Path, fileInfo.Size, options.MaxFileSize)
+			logger.Debug("ChunkFiles: Skipping file that is too large",
+				"path", fileInfo.Path, "size", fileInfo.Size, "max_size", options.MaxFileSize)
 			continue
 		}
 
 		// Skip binary files
 		if fileInfo.IsBinary || !fileInfo.IsText {
-			log.Printf("ChunkFiles: Skipping %s (binary or not text: IsBinary=%v, IsText=%v)",
-				fileInfo.Path, fileInfo.IsBinary, fileInfo.IsText)
+			logger.Debug("ChunkFiles: Skipping non-text file",
+				"path", fileInfo.Path, "is_text", fileInfo.IsText, "is_binary", fileInfo.IsBinary)
 			continue
 		}
 
@@ -61,87 +67,69 @@ func (ip *InlineProcessor) ChunkFiles(ctx context.Context, extractResult *Extrac
 		chunks, err := ip.chunkFile(ctx, filePath, fileInfo, options)
 		if err != nil {
 			// Log error but continue with other files
-			log.Printf("ChunkFiles: Error chunking file %s: %v", fileInfo.Path, err)
+			logger.Warn("ChunkFiles: Failed to chunk file", "path", fileInfo.Path, "error", err)
 			observability.RecordError(span, err, fmt.Sprintf("failed to chunk file %s", fileInfo.Path))
 			continue
 		}
 
-		log.Printf("ChunkFiles: Successfully created %d chunks for file %s", len(chunks), fileInfo.Path)
+		logger.Debug("ChunkFiles: Chunked file", "path", fileInfo.Path, "chunks", len(chunks))
 		allChunks = append(allChunks, chunks...)
 	}
+	progress.FilesChunked(len(extractResult.Files), len(allChunks))
 
 	observability.SetSpanAttributes(span,
 		observability.ResultCountAttr(len(allChunks)),
 	)
 
-	log.Printf("ChunkFiles: Completed chunking. Total chunks created: %d", len(allChunks))
+	logger.Info("ChunkFiles: Completed chunking", "chunks", len(allChunks))
 	return allChunks, nil
 }
 
 func (ip *InlineProcessor) chunkFile(ctx context.Context, filePath string, fileInfo *FileInfo, options *ChunkOptions) ([]*FileChunk, error) {
-	file, err := os.Open(filePath)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
-	}
-	defer file.Close()
-
-	var chunks []*FileChunk
-	scanner := bufio.NewScanner(file)
-
-	var lines []string
-	lineNumber := 1
-
-	// Read all lines first
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
 	}
 
-	// Create chunks using sliding window
-	chunkSize := options.ChunkSize
-	overlap := options.ChunkOverlap
+	// A trailing newline doesn't start another line
+	content := strings.TrimSuffix(string(data), "\n")
+	language := fileInfo.Language
 
-	for i := 0; i < len(lines); i += chunkSize - overlap {
-		endIdx := i + chunkSize
-		if endIdx > len(lines) {
-			endIdx = len(lines)
+	// Notebooks are chunked by their cell sources rather than their JSON
+	if isNotebook(fileInfo.Path) {
+		content, language, err = notebookSource(data, ip.uploadConfig.NotebookMarkdown)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read notebook %s: %w", filePath, err)
 		}
-
-		// Skip chunks that are too small (less than 10% of chunk size)
-		if endIdx-i < chunkSize/10 && i > 0 {
-			break
+		if content == "" {
+			return nil, nil
 		}
+	}
+	lines := strings.Split(content, "\n")
 
-		chunkLines := lines[i:endIdx]
-		content := strings.Join(chunkLines, "\n")
+	var chunks []*FileChunk
+	for _, boundary := range getChunkingStrategy(language).ChunkContent(content, options) {
+		chunkContent := strings.Join(lines[boundary.StartLine-1:boundary.EndLine], "\n")
 
 		// Skip empty or whitespace-only chunks
-		if strings.TrimSpace(content) == "" {
+		if strings.TrimSpace(chunkContent) == "" {
 			continue
 		}
 
-		// Create chunk
 		chunk := &FileChunk{
-			ID:           generateChunkID(fileInfo.Path, i+lineNumber, endIdx+lineNumber-1),
+			ID:           generateChunkID(fileInfo.Path, boundary.StartLine, boundary.EndLine),
 			RepositoryID: "", // Will be set by caller
 			FilePath:     fileInfo.Path,
-			StartLine:    i + lineNumber,
-			EndLine:      endIdx + lineNumber - 1,
-			Content:      content,
-			Language:     fileInfo.Language,
-			Size:         len(content),
-			Hash:         hashContent(content),
+			StartLine:    boundary.StartLine,
+			EndLine:      boundary.EndLine,
+			Content:      chunkContent,
+			Language:     language,
+			Symbol:       boundary.Name,
+			Size:         len(chunkContent),
+			Hash:         hashContent(chunkContent),
 		}
 
 		chunks = append(chunks, chunk)
-
-		// If we've reached the end, break
-		if endIdx >= len(lines) {
-			break
-		}
 	}
 
 	return chunks, nil
@@ -166,23 +154,24 @@ func (ip *InlineProcessor) GenerateEmbeddings(ctx context.Context, chunks []*Fil
 	// Prepare texts for embedding
 	texts := make([]string, len(chunks))
 	for i, chunk := range chunks {
+		content := chunk.Content
+		// Comments and blank lines are left out of the embedded text only; the
+		// chunk keeps its content for display and lexical search
+		if ip.uploadConfig.StripEmbeddingComments {
+			if stripped := embeddingContent(chunk.Language, content); stripped != "" {
+				content = stripped
+			}
+		}
+
 		// Combine file path and content for better context
 		texts[i] = fmt.Sprintf("File: %s\nLanguage: %s\nContent:\n%s",
-			chunk.FilePath, chunk.Language, chunk.Content)
+			chunk.FilePath, chunk.Language, content)
 	}
 
-	timer := observability.StartTimer()
 	embeddingModel := ip.embeddingClient.GetDefaultModel()
-	embeddings, err := ip.embeddingClient.GenerateEmbeddings(ctx, texts, embeddingModel)
+	embeddings, err := ip.embedWithCache(ctx, texts, embeddingModel)
 	if err != nil {
-		ip.metrics.RecordEmbeddingRequest(embeddingModel, "error")
-		return nil, fmt.Errorf("failed to generate embeddings: %w", err)
-	}
-	ip.metrics.RecordEmbeddingRequest(embeddingModel, "success")
-	ip.metrics.RecordBackendLatency("openai", timer.Duration())
-
-	if len(embeddings) != len(chunks) {
-		return nil, fmt.Errorf("embedding count mismatch: got %d, expected %d", len(embeddings), len(chunks))
+		return nil, err
 	}
 
 	// Create embedded chunks
@@ -191,68 +180,138 @@ func (ip *InlineProcessor) GenerateEmbeddings(ctx context.Context, chunks []*Fil
 		embeddedChunks[i] = &EmbeddedChunk{
 			FileChunk: chunk,
 			Embedding: embeddings[i],
-			Model:     "text-embedding-3-small",
+			Model:     embeddingModel,
 			CreatedAt: time.Now(),
 		}
 	}
 
 	observability.SetSpanAttributes(span,
 		observability.ResultCountAttr(len(embeddedChunks)),
-		observability.ModelAttr("text-embedding-3-small"),
+		observability.ModelAttr(embeddingModel),
 	)
 
 	return embeddedChunks, nil
 }
 
+// embedWithCache returns an embedding per text, only sending texts without a cached
+// vector to the provider and backfilling the cache with the results. Vectors are
+// cached by model and a hash of the exact text embedded, so unchanged chunks are
+// reused across re-ingestions. Cache failures degrade to embedding everything.
+func (ip *InlineProcessor) embedWithCache(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	logger := ip.loggerFrom(ctx)
+
+	hashes := make([]string, len(texts))
+	for i, text := range texts {
+		hashes[i] = hashContent(text)
+	}
+
+	cached, err := ip.cache.GetEmbeddings(ctx, model, hashes)
+	if err != nil {
+		logger.Warn("GenerateEmbeddings: Embedding cache lookup failed", "error", err)
+		cached = nil
+	}
+
+	embeddings := make([][]float32, len(texts))
+	var missTexts []string
+	var missIndexes []int
+	for i, hash := range hashes {
+		if vector, ok := cached[hash]; ok {
+			embeddings[i] = vector
+			continue
+		}
+		missTexts = append(missTexts, texts[i])
+		missIndexes = append(missIndexes, i)
+	}
+
+	ip.metrics.RecordEmbeddingCacheLookups(model, len(texts)-len(missTexts), len(missTexts))
+	logger.Info("GenerateEmbeddings: Embedding cache checked",
+		"chunks", len(texts), "cache_hits", len(texts)-len(missTexts))
+
+	progress := progressTrackerFrom(ctx)
+	embedded := len(texts) - len(missTexts)
+	progress.ChunksEmbedded(embedded)
+
+	// Texts are sent progressStep at a time so progress can be reported, and each
+	// step is cached as soon as it's embedded
+	for start := 0; start < len(missTexts); start += progressStep {
+		end := start + progressStep
+		if end > len(missTexts) {
+			end = len(missTexts)
+		}
+
+		timer := observability.StartTimer()
+		generated, err := EmbedTexts(ctx, ip.embeddingClient, missTexts[start:end], model)
+		if err != nil {
+			ip.metrics.RecordEmbeddingRequest(model, "error")
+			return nil, fmt.Errorf("failed to generate embeddings: %w", err)
+		}
+		ip.metrics.RecordEmbeddingRequest(model, "success")
+		ip.metrics.RecordBackendLatency("openai", timer.Duration())
+
+		if len(generated) != end-start {
+			return nil, fmt.Errorf("embedding count mismatch: got %d, expected %d", len(generated), end-start)
+		}
+
+		backfill := make(map[string][]float32, len(generated))
+		for j, vector := range generated {
+			i := missIndexes[start+j]
+			embeddings[i] = vector
+			backfill[hashes[i]] = vector
+		}
+
+		if err := ip.cache.SetEmbeddings(ctx, model, backfill); err != nil {
+			logger.Warn("GenerateEmbeddings: Failed to backfill embedding cache", "error", err)
+		}
+
+		embedded += len(generated)
+		progress.ChunksEmbedded(embedded)
+	}
+
+	return embeddings, nil
+}
+
 func (ip *InlineProcessor) IndexEmbeddings(ctx context.Context, repoID string, chunks []*EmbeddedChunk) error {
 	ctx, span := ip.tracer.StartIngestion(ctx, repoID, "index_embeddings")
 	defer span.End()
 
-	if len(chunks) == 0 {
-		return nil
+	// Create collection if it doesn't exist, sized for the embedding provider. A
+	// repository without chunks still gets an empty one, so searching a ready
+	// repository never finds its collection missing.
+	dimensions := ip.embeddingClient.Dimensions()
+	for _, chunk := range chunks {
+		if len(chunk.Embedding) != dimensions {
+			return fmt.Errorf("chunk %s has a %d-dimensional embedding, expected %d", chunk.ID, len(chunk.Embedding), dimensions)
+		}
 	}
-
-	// Create collection if it doesn't exist
-	dimensions := len(chunks[0].Embedding)
-	// Convert repoID to valid Weaviate class name (PascalCase, no hyphens)
-	className := toWeaviateClassName(repoID)
+	className := CollectionName(repoID)
 	if err := ip.vectorClient.CreateCollection(ctx, className, dimensions); err != nil {
 		return fmt.Errorf("failed to create collection: %w", err)
 	}
+	if len(chunks) == 0 {
+		return nil
+	}
 
 	// Convert to vectors
 	vectors := make([]*Vector, len(chunks))
 	for i, chunk := range chunks {
-		vectors[i] = &Vector{
-			ID:     chunk.ID,
-			Vector: chunk.Embedding,
-			Metadata: map[string]interface{}{
-				"repository_id": chunk.RepositoryID,
-				"file_path":     chunk.FilePath,
-				"start_line":    chunk.StartLine,
-				"end_line":      chunk.EndLine,
-				"language":      chunk.Language,
-				"size":          chunk.Size,
-				"created_at":    chunk.CreatedAt.Unix(),
-			},
-		}
+		vectors[i] = chunkVector(chunk)
 	}
 
-	// Batch upsert (process in batches of 100)
-	batchSize := 100
-	for i := 0; i < len(vectors); i += batchSize {
-		end := i + batchSize
+	// The vector client splits each upsert into batches; vectors are handed to it
+	// progressStep at a time so progress can be reported
+	progress := progressTrackerFrom(ctx)
+	timer := observability.StartTimer()
+	for start := 0; start < len(vectors); start += progressStep {
+		end := start + progressStep
 		if end > len(vectors) {
 			end = len(vectors)
 		}
-
-		batch := vectors[i:end]
-		timer := observability.StartTimer()
-		if err := ip.vectorClient.UpsertVectors(ctx, className, batch); err != nil {
-			return fmt.Errorf("failed to upsert vectors batch %d-%d: %w", i, end, err)
+		if err := ip.vectorClient.UpsertVectors(ctx, className, vectors[start:end]); err != nil {
+			return fmt.Errorf("failed to upsert vectors: %w", err)
 		}
-		ip.metrics.RecordBackendLatency("weaviate", timer.Duration())
+		progress.ChunksIndexed(end)
 	}
+	ip.metrics.RecordBackendLatency("weaviate", timer.Duration())
 
 	observability.SetSpanAttributes(span,
 		observability.ResultCountAttr(len(vectors)),
@@ -264,10 +323,34 @@ func (ip *InlineProcessor) IndexEmbeddings(ctx context.Context, repoID string, c
 
 // Helper functions
 
-// toWeaviateClassName converts a repository ID to a valid Weaviate class name
-// Weaviate class names must be PascalCase and contain no hyphens or special characters
-func toWeaviateClassName(repoID string) string {
-	return "Repo" + strings.ReplaceAll(strings.TrimPrefix(repoID, "repo-"), "-", "")
+// chunkVector is the vector store record of an embedded chunk
+func chunkVector(chunk *EmbeddedChunk) *Vector {
+	return &Vector{
+		ID:     chunk.ID,
+		Vector: chunk.Embedding,
+		Metadata: map[string]interface{}{
+			"repository_id": chunk.RepositoryID,
+			"file_path":     chunk.FilePath,
+			"start_line":    chunk.StartLine,
+			"end_line":      chunk.EndLine,
+			"content":       chunk.Content,
+			"language":      chunk.Language,
+			"symbol":        chunk.Symbol,
+			"size":          chunk.Size,
+			// Weaviate's date type takes RFC3339
+			"created_at":    chunk.CreatedAt.UTC().Format(time.RFC3339),
+		},
+	}
+}
+
+// CollectionPrefix starts the name of every repository's collection
+const CollectionPrefix = "Repo"
+
+// CollectionName is the vector store collection holding a repository's chunks. It
+// suits every backend: Weaviate class names must be PascalCase with no hyphens or
+// special characters, and Qdrant accepts any letters and digits.
+func CollectionName(repoID string) string {
+	return CollectionPrefix + strings.ReplaceAll(strings.TrimPrefix(repoID, "repo-"), "-", "")
 }
 
 func compilePatterns(patterns []string) []*regexp.Regexp {
@@ -295,14 +378,17 @@ func generateChunkID(filePath string, startLine, endLine int) string {
 	return fmt.Sprintf("%x", hash)[:16]
 }
 
+// hashContent keys cached embeddings, so it keeps the whole digest: a collision would
+// serve one chunk's vector for another's content
 func hashContent(content string) string {
 	hash := sha256.Sum256([]byte(content))
-	return fmt.Sprintf("%x", hash)[:16]
+	return fmt.Sprintf("%x", hash)
 }
 
-// Language-specific chunking strategies (future enhancement)
+// Language-specific chunking strategies
 
 type ChunkingStrategy interface {
+	// ChunkContent returns 1-based, inclusive line ranges covering content
 	ChunkContent(content string, options *ChunkOptions) []ChunkBoundary
 }
 
@@ -317,24 +403,32 @@ type LineBasedStrategy struct{}
 
 func (s *LineBasedStrategy) ChunkContent(content string, options *ChunkOptions) []ChunkBoundary {
 	lines := strings.Split(content, "\n")
-	var boundaries []ChunkBoundary
+	return splitLines(ChunkBoundary{StartLine: 1, EndLine: len(lines), Type: "section"}, options)
+}
 
+// splitLines cuts a boundary into a sliding window of ChunkSize lines overlapping by
+// ChunkOverlap, keeping its type and name. The last window may be shorter than
+// ChunkSize; it is always kept, since the lines past the previous window's end are in
+// no other chunk.
+func splitLines(boundary ChunkBoundary, options *ChunkOptions) []ChunkBoundary {
 	chunkSize := options.ChunkSize
 	overlap := options.ChunkOverlap
 
-	for i := 0; i < len(lines); i += chunkSize - overlap {
-		endIdx := i + chunkSize
-		if endIdx > len(lines) {
-			endIdx = len(lines)
+	var boundaries []ChunkBoundary
+	for start := boundary.StartLine; start <= boundary.EndLine; start += chunkSize - overlap {
+		end := start + chunkSize - 1
+		if end > boundary.EndLine {
+			end = boundary.EndLine
 		}
 
 		boundaries = append(boundaries, ChunkBoundary{
-			StartLine: i + 1,
-			EndLine:   endIdx,
-			Type:      "section",
+			StartLine: start,
+			EndLine:   end,
+			Type:      boundary.Type,
+			Name:      boundary.Name,
 		})
 
-		if endIdx >= len(lines) {
+		if end >= boundary.EndLine {
 			break
 		}
 	}
@@ -342,13 +436,13 @@ func (s *LineBasedStrategy) ChunkContent(content string, options *ChunkOptions)
 	return boundaries
 }
 
-// Future: Language-specific strategies
-// type GoChunkingStrategy struct{}
-// type JavaScriptChunkingStrategy struct{}
-// type PythonChunkingStrategy struct{}
-
 func getChunkingStrategy(language string) ChunkingStrategy {
-	// For now, use line-based strategy for all languages
-	// In the future, implement language-specific strategies
-	return &LineBasedStrategy{}
-}
\ No newline at end of file
+	switch language {
+	case "go":
+		return &GoChunkingStrategy{}
+	case "python":
+		return &PythonChunkingStrategy{}
+	default:
+		return &LineBasedStrategy{}
+	}
+}
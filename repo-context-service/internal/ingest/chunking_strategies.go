@@ -0,0 +1,212 @@
+package ingest
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// GoChunkingStrategy chunks Go source on top-level declarations, so each function,
+// method and type lands in its own chunk named after it. Files that don't parse
+// fall back to line-based chunking.
+type GoChunkingStrategy struct{}
+
+func (s *GoChunkingStrategy) ChunkContent(content string, options *ChunkOptions) []ChunkBoundary {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil || len(file.Decls) == 0 {
+		return (&LineBasedStrategy{}).ChunkContent(content, options)
+	}
+
+	var declarations []ChunkBoundary
+	for _, decl := range file.Decls {
+		start := decl.Pos()
+		boundary := ChunkBoundary{Type: "section"}
+
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			boundary.Type = "function"
+			boundary.Name = goFuncName(d)
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			if d.Tok == token.TYPE {
+				boundary.Type = "type"
+				boundary.Name = goTypeNames(d)
+			}
+		}
+
+		boundary.StartLine = fset.Position(start).Line
+		boundary.EndLine = fset.Position(decl.End()).Line
+		declarations = append(declarations, boundary)
+	}
+
+	totalLines := strings.Count(content, "\n") + 1
+	return splitOversized(coverLines(declarations, totalLines), options)
+}
+
+// goFuncName returns "Name" for functions and "Recv.Name" for methods
+func goFuncName(d *ast.FuncDecl) string {
+	if d.Recv == nil || len(d.Recv.List) == 0 {
+		return d.Name.Name
+	}
+
+	recv := d.Recv.List[0].Type
+	for {
+		switch t := recv.(type) {
+		case *ast.StarExpr:
+			recv = t.X
+			continue
+		case *ast.IndexExpr:
+			recv = t.X
+			continue
+		case *ast.IndexListExpr:
+			recv = t.X
+			continue
+		case *ast.Ident:
+			return t.Name + "." + d.Name.Name
+		}
+		return d.Name.Name
+	}
+}
+
+func goTypeNames(d *ast.GenDecl) string {
+	var names []string
+	for _, spec := range d.Specs {
+		if ts, ok := spec.(*ast.TypeSpec); ok {
+			names = append(names, ts.Name.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// PythonChunkingStrategy chunks Python source on top-level def/class blocks, found
+// by indentation: a block runs until the next line at column zero. Decorators stay
+// with the definition they decorate.
+type PythonChunkingStrategy struct{}
+
+var pythonDefinitionPattern = regexp.MustCompile(`^(?:async\s+def|def|class)\s+([A-Za-z_]\w*)`)
+
+func (s *PythonChunkingStrategy) ChunkContent(content string, options *ChunkOptions) []ChunkBoundary {
+	lines := strings.Split(content, "\n")
+
+	var blocks []ChunkBoundary
+	var current *ChunkBoundary
+	decoratorStart := 0
+
+	for i, line := range lines {
+		lineNumber := i + 1
+		if !startsPythonStatement(line) {
+			continue
+		}
+
+		if strings.HasPrefix(line, "@") {
+			if decoratorStart == 0 {
+				decoratorStart = lineNumber
+			}
+			continue
+		}
+
+		// Any other top-level statement ends the current block
+		if current != nil {
+			current.EndLine = lastCodeLine(lines, current.StartLine, lineNumber-1)
+			blocks = append(blocks, *current)
+			current = nil
+		}
+
+		if match := pythonDefinitionPattern.FindStringSubmatch(line); match != nil {
+			start := lineNumber
+			if decoratorStart != 0 {
+				start = decoratorStart
+			}
+			blockType := "function"
+			if strings.HasPrefix(line, "class") {
+				blockType = "class"
+			}
+			current = &ChunkBoundary{StartLine: start, Type: blockType, Name: match[1]}
+		}
+		decoratorStart = 0
+	}
+
+	if current != nil {
+		current.EndLine = lastCodeLine(lines, current.StartLine, len(lines))
+		blocks = append(blocks, *current)
+	}
+
+	if len(blocks) == 0 {
+		return (&LineBasedStrategy{}).ChunkContent(content, options)
+	}
+
+	return splitOversized(coverLines(blocks, len(lines)), options)
+}
+
+// startsPythonStatement reports whether line is a top-level statement. Blank lines,
+// comments and the closing brackets of multi-line expressions don't count.
+func startsPythonStatement(line string) bool {
+	if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '#' {
+		return false
+	}
+	switch line[0] {
+	case ')', ']', '}':
+		return false
+	}
+	return true
+}
+
+// lastCodeLine trims trailing blank lines from the range [start, end]
+func lastCodeLine(lines []string, start, end int) int {
+	for end > start && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	return end
+}
+
+// coverLines fills the gaps around sorted, non-overlapping blocks with unnamed
+// sections so no line of the file is lost, merging adjacent sections.
+func coverLines(blocks []ChunkBoundary, totalLines int) []ChunkBoundary {
+	var boundaries []ChunkBoundary
+	addSection := func(start, end int) {
+		if start > end {
+			return
+		}
+		if n := len(boundaries); n > 0 && boundaries[n-1].Type == "section" && boundaries[n-1].EndLine == start-1 {
+			boundaries[n-1].EndLine = end
+			return
+		}
+		boundaries = append(boundaries, ChunkBoundary{StartLine: start, EndLine: end, Type: "section"})
+	}
+
+	next := 1
+	for _, block := range blocks {
+		addSection(next, block.StartLine-1)
+		if block.Type == "section" {
+			addSection(block.StartLine, block.EndLine)
+		} else {
+			boundaries = append(boundaries, block)
+		}
+		next = block.EndLine + 1
+	}
+	addSection(next, totalLines)
+
+	return boundaries
+}
+
+// splitOversized breaks boundaries longer than ChunkSize into line windows that keep
+// the symbol name
+func splitOversized(boundaries []ChunkBoundary, options *ChunkOptions) []ChunkBoundary {
+	var result []ChunkBoundary
+	for _, boundary := range boundaries {
+		if boundary.EndLine-boundary.StartLine+1 <= options.ChunkSize {
+			result = append(result, boundary)
+			continue
+		}
+		result = append(result, splitLines(boundary, options)...)
+	}
+	return result
+}
@@ -0,0 +1,132 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoChunkingStrategy(t *testing.T) {
+	source := `package shapes
+
+import "math"
+
+// Circle is round
+type Circle struct {
+	R float64
+}
+
+// Area returns the area
+func (c *Circle) Area() float64 {
+	return math.Pi * c.R * c.R
+}
+
+func NewCircle(r float64) *Circle {
+	return &Circle{R: r}
+}
+`
+	path := filepath.Join(t.TempDir(), "shapes.go")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ip := &InlineProcessor{}
+	chunks, err := ip.chunkFile(context.Background(), path, &FileInfo{Path: "shapes.go", Language: "go"}, &ChunkOptions{ChunkSize: 100, ChunkOverlap: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Doc comments stay with their declaration; the blank lines between are dropped
+	want := []struct {
+		start, end int
+		symbol     string
+		firstLine  string
+	}{
+		{1, 4, "", "package shapes"},
+		{5, 8, "Circle", "// Circle is round"},
+		{10, 13, "Circle.Area", "// Area returns the area"},
+		{15, 17, "NewCircle", "func NewCircle(r float64) *Circle {"},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d", len(chunks), len(want))
+	}
+	for i, w := range want {
+		chunk := chunks[i]
+		if chunk.StartLine != w.start || chunk.EndLine != w.end || chunk.Symbol != w.symbol {
+			t.Errorf("chunk %d = lines %d-%d %q, want lines %d-%d %q", i, chunk.StartLine, chunk.EndLine, chunk.Symbol, w.start, w.end, w.symbol)
+		}
+		if !strings.HasPrefix(chunk.Content, w.firstLine) {
+			t.Errorf("chunk %d starts %q, want %q", i, strings.SplitN(chunk.Content, "\n", 2)[0], w.firstLine)
+		}
+	}
+}
+
+func TestGoChunkingStrategySplitsLongFunctions(t *testing.T) {
+	source := "package long\n\nfunc Long() {\n" + strings.Repeat("\tprintln()\n", 30) + "}"
+
+	boundaries := (&GoChunkingStrategy{}).ChunkContent(source, &ChunkOptions{ChunkSize: 10, ChunkOverlap: 2})
+
+	var parts int
+	for _, boundary := range boundaries {
+		if boundary.EndLine-boundary.StartLine+1 > 10 {
+			t.Errorf("boundary %d-%d is longer than the chunk size", boundary.StartLine, boundary.EndLine)
+		}
+		if boundary.StartLine >= 3 {
+			parts++
+			if boundary.Name != "Long" {
+				t.Errorf("part of Long at %d-%d is named %q", boundary.StartLine, boundary.EndLine, boundary.Name)
+			}
+		}
+	}
+	if parts < 4 {
+		t.Errorf("Long split into %d parts, want at least 4", parts)
+	}
+}
+
+func TestGoChunkingStrategyFallsBackOnParseErrors(t *testing.T) {
+	source := "package broken\n\nfunc Broken( {\n"
+	options := &ChunkOptions{ChunkSize: 100, ChunkOverlap: 10}
+
+	got := (&GoChunkingStrategy{}).ChunkContent(source, options)
+	want := (&LineBasedStrategy{}).ChunkContent(source, options)
+	if len(got) != len(want) || len(got) == 0 || got[0] != want[0] {
+		t.Errorf("unparsable file chunked as %v, want line-based %v", got, want)
+	}
+}
+
+func TestPythonChunkingStrategy(t *testing.T) {
+	source := `import os
+
+
+@cache
+def load(path):
+    return open(path).read()
+
+
+class Loader:
+    def run(self):
+        pass
+
+VERSION = 1`
+
+	got := (&PythonChunkingStrategy{}).ChunkContent(source, &ChunkOptions{ChunkSize: 100, ChunkOverlap: 10})
+
+	// The decorator starts its function, and nested defs stay inside their class
+	want := []ChunkBoundary{
+		{StartLine: 1, EndLine: 3, Type: "section"},
+		{StartLine: 4, EndLine: 6, Type: "function", Name: "load"},
+		{StartLine: 7, EndLine: 8, Type: "section"},
+		{StartLine: 9, EndLine: 11, Type: "class", Name: "Loader"},
+		{StartLine: 12, EndLine: 13, Type: "section"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("boundary %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
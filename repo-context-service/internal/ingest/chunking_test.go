@@ -0,0 +1,94 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHashContentKeepsFullDigest(t *testing.T) {
+	if got := hashContent("func main() {}"); len(got) != 64 {
+		t.Errorf("hashContent = %q, want a 64-character SHA-256 hex digest", got)
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     int
+		size      int
+		overlap   int
+		wantCount int
+	}{
+		{"one window", 100, 100, 10, 1},
+		{"default size", 250, 100, 10, 3},
+		{"smaller chunks", 250, 50, 10, 6},
+		{"short tail", 104, 100, 5, 2},
+		{"no overlap", 101, 100, 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boundaries := splitLines(ChunkBoundary{StartLine: 1, EndLine: tt.lines, Type: "section"}, &ChunkOptions{ChunkSize: tt.size, ChunkOverlap: tt.overlap})
+			if len(boundaries) != tt.wantCount {
+				t.Fatalf("got %d chunks, want %d: %v", len(boundaries), tt.wantCount, boundaries)
+			}
+
+			// Every line lands in a chunk, the last one included
+			next := 1
+			for _, b := range boundaries {
+				if b.StartLine > next {
+					t.Fatalf("lines %d-%d are in no chunk: %v", next, b.StartLine-1, boundaries)
+				}
+				if b.EndLine-b.StartLine+1 > tt.size {
+					t.Errorf("chunk %d-%d is longer than %d lines", b.StartLine, b.EndLine, tt.size)
+				}
+				next = b.EndLine + 1
+			}
+			if next != tt.lines+1 {
+				t.Errorf("chunks end at line %d, want %d", next-1, tt.lines)
+			}
+		})
+	}
+}
+
+func TestEmbedWithCacheReusesVectors(t *testing.T) {
+	ctx := context.Background()
+	embeddings := &gatedEmbeddings{}
+	ip := newPipelineProcessor(t, embeddings, &memoryVectors{collections: make(map[string][]*Vector)}, 1, 0)
+	texts := []string{"func a() {}", "func bb() {}", "func ccc() {}"}
+
+	tests := []struct {
+		name      string
+		texts     []string
+		wantCalls int
+		wantTexts int
+	}{
+		{"first ingestion", texts, 1, 3},
+		{"identical content", texts, 1, 3},
+		{"one chunk changed", []string{texts[0], "func dddd() {}", texts[2]}, 2, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vectors, err := ip.embedWithCache(ctx, tt.texts, "test-embedding")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if calls, sent := embeddings.counts(); calls != tt.wantCalls || sent != tt.wantTexts {
+				t.Errorf("provider got %d requests for %d texts in all, want %d for %d", calls, sent, tt.wantCalls, tt.wantTexts)
+			}
+			for i, text := range tt.texts {
+				if len(vectors[i]) != 2 || vectors[i][0] != float32(len(text)) {
+					t.Errorf("vector for %q = %v, want its own embedding", text, vectors[i])
+				}
+			}
+		})
+	}
+
+	// Vectors are cached per model, so another model embeds everything afresh
+	if _, err := ip.embedWithCache(ctx, texts, "other-embedding"); err != nil {
+		t.Fatal(err)
+	}
+	if _, sent := embeddings.counts(); sent != 7 {
+		t.Errorf("provider got %d texts after a model switch, want 7", sent)
+	}
+}
@@ -0,0 +1,407 @@
+package ingest
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// commentSyntax describes what a language's comments and string literals look like,
+// so comments can be told apart from comment-like text inside strings
+type commentSyntax struct {
+	lineComments []string
+	// '#' only starts a comment at the start of a line or after whitespace, as in
+	// shell where $# and ${#var} aren't comments
+	hashAfterSpace bool
+	blockStart     string
+	blockEnd       string
+	nestedBlocks   bool
+	// Strings with backslash escapes that end at the line
+	quotes string
+	// Strings with backslash escapes that may span lines, like JavaScript templates
+	multilineQuotes string
+	// Strings without escapes that may span lines, like Go raw strings
+	rawQuotes string
+	// Triple-quoted strings, e.g. `"""` and `'''` in Python
+	tripleQuotes []string
+	// ' delimits single character literals only; elsewhere it's an ordinary
+	// character, as in Rust lifetimes
+	charLiterals bool
+	// C# verbatim strings, @"..." with "" for a quote
+	verbatimStrings bool
+	// JavaScript regular expression literals, which may contain //
+	regexLiterals bool
+	// Constructs the scanner doesn't follow, such as heredocs and raw strings with
+	// custom delimiters; content containing one is left as is
+	unsupported []string
+}
+
+var cStyleSyntax = &commentSyntax{
+	lineComments: []string{"//"},
+	blockStart:   "/*",
+	blockEnd:     "*/",
+	quotes:       `"`,
+	charLiterals: true,
+}
+
+var commentSyntaxes = map[string]*commentSyntax{
+	"go": {
+		lineComments: []string{"//"},
+		blockStart:   "/*",
+		blockEnd:     "*/",
+		quotes:       `"`,
+		rawQuotes:    "`",
+		charLiterals: true,
+	},
+	"c":    cStyleSyntax,
+	"java": cStyleSyntax,
+	"cpp": {
+		lineComments: []string{"//"},
+		blockStart:   "/*",
+		blockEnd:     "*/",
+		quotes:       `"`,
+		charLiterals: true,
+		unsupported:  []string{`R"`},
+	},
+	"csharp": {
+		lineComments:    []string{"//"},
+		blockStart:      "/*",
+		blockEnd:        "*/",
+		quotes:          `"`,
+		charLiterals:    true,
+		verbatimStrings: true,
+		unsupported:     []string{`"""`},
+	},
+	"javascript": {
+		lineComments:    []string{"//"},
+		blockStart:      "/*",
+		blockEnd:        "*/",
+		quotes:          `"'`,
+		multilineQuotes: "`",
+		regexLiterals:   true,
+	},
+	"typescript": {
+		lineComments:    []string{"//"},
+		blockStart:      "/*",
+		blockEnd:        "*/",
+		quotes:          `"'`,
+		multilineQuotes: "`",
+		regexLiterals:   true,
+	},
+	"rust": {
+		lineComments: []string{"//"},
+		blockStart:   "/*",
+		blockEnd:     "*/",
+		nestedBlocks: true,
+		quotes:       `"`,
+		charLiterals: true,
+		unsupported:  []string{`r"`, `r#`},
+	},
+	"kotlin": {
+		lineComments: []string{"//"},
+		blockStart:   "/*",
+		blockEnd:     "*/",
+		nestedBlocks: true,
+		quotes:       `"`,
+		tripleQuotes: []string{`"""`},
+		charLiterals: true,
+		unsupported:  []string{"${"},
+	},
+	"scala": {
+		lineComments: []string{"//"},
+		blockStart:   "/*",
+		blockEnd:     "*/",
+		nestedBlocks: true,
+		quotes:       `"`,
+		tripleQuotes: []string{`"""`},
+		charLiterals: true,
+		unsupported:  []string{"${"},
+	},
+	"swift": {
+		lineComments: []string{"//"},
+		blockStart:   "/*",
+		blockEnd:     "*/",
+		nestedBlocks: true,
+		quotes:       `"`,
+		tripleQuotes: []string{`"""`},
+		unsupported:  []string{`\(`, `#"`},
+	},
+	"php": {
+		lineComments: []string{"//"},
+		blockStart:   "/*",
+		blockEnd:     "*/",
+		quotes:       `"'`,
+		unsupported:  []string{"<<<"},
+	},
+	"python": {
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		tripleQuotes: []string{`"""`, `'''`},
+	},
+	"shell": {
+		lineComments:    []string{"#"},
+		hashAfterSpace:  true,
+		multilineQuotes: "\"`",
+		rawQuotes:       "'",
+		unsupported:     []string{"<<", "$'"},
+	},
+}
+
+// regexPrecedingKeywords can come right before a JavaScript regular expression
+var regexPrecedingKeywords = map[string]bool{
+	"return": true, "typeof": true, "case": true, "do": true, "else": true, "in": true,
+	"of": true, "new": true, "delete": true, "void": true, "throw": true, "yield": true,
+	"await": true, "instanceof": true,
+}
+
+// embeddingContent returns the text a chunk's content is embedded as when comments
+// are stripped: comments removed, trailing whitespace trimmed and blank lines
+// dropped. Comments are only removed for languages whose syntax is known, and only
+// if the content can be scanned with certainty; otherwise just the whitespace is
+// collapsed. The chunk itself keeps its original content.
+func embeddingContent(language, content string) string {
+	if syntax, ok := commentSyntaxes[language]; ok {
+		if stripped, ok := stripComments(content, syntax); ok {
+			content = stripped
+		}
+	}
+	return collapseWhitespace(content)
+}
+
+// collapseWhitespace trims trailing whitespace and drops blank lines
+func collapseWhitespace(content string) string {
+	lines := strings.Split(content, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r\f\v")
+		if line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// stripComments removes the comments from content. It reports false if content has
+// a construct the syntax doesn't cover or a string that isn't terminated, since the
+// rest of it can't be told apart from comments then.
+func stripComments(content string, syntax *commentSyntax) (string, bool) {
+	for _, marker := range syntax.unsupported {
+		if strings.Contains(content, marker) {
+			return "", false
+		}
+	}
+
+	var out strings.Builder
+	out.Grow(len(content))
+
+	for i := 0; i < len(content); {
+		rest := content[i:]
+		c := content[i]
+
+		if syntax.blockStart != "" && strings.HasPrefix(rest, syntax.blockStart) {
+			end := skipBlockComment(content, i, syntax)
+			// Newlines are kept so the surrounding lines stay apart, and a space so
+			// the code on either side doesn't run together
+			if newlines := strings.Count(content[i:end], "\n"); newlines > 0 {
+				out.WriteString(strings.Repeat("\n", newlines))
+			} else {
+				out.WriteByte(' ')
+			}
+			i = end
+			continue
+		}
+
+		if startsLineComment(content, i, syntax) {
+			if j := strings.IndexByte(rest, '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(content)
+			}
+			continue
+		}
+
+		end, ok := skipLiteral(content, i, syntax, out.String())
+		if !ok {
+			return "", false
+		}
+		if end > i {
+			out.WriteString(content[i:end])
+			i = end
+			continue
+		}
+
+		out.WriteByte(c)
+		i++
+	}
+
+	return out.String(), true
+}
+
+func startsLineComment(content string, i int, syntax *commentSyntax) bool {
+	for _, prefix := range syntax.lineComments {
+		if !strings.HasPrefix(content[i:], prefix) {
+			continue
+		}
+		if prefix == "#" && syntax.hashAfterSpace && i > 0 && !isSpace(content[i-1]) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// skipBlockComment returns the index just past the block comment starting at i, or
+// the end of content if it isn't closed within it
+func skipBlockComment(content string, i int, syntax *commentSyntax) int {
+	depth := 0
+	for i < len(content) {
+		switch {
+		case strings.HasPrefix(content[i:], syntax.blockStart) && (depth == 0 || syntax.nestedBlocks):
+			depth++
+			i += len(syntax.blockStart)
+		case strings.HasPrefix(content[i:], syntax.blockEnd):
+			depth--
+			i += len(syntax.blockEnd)
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return len(content)
+}
+
+// skipLiteral returns the index just past the string, character or regular
+// expression literal starting at i, or i if none starts there. written is the
+// output so far, which tells a regular expression from a division. It reports
+// false for a literal that isn't terminated.
+func skipLiteral(content string, i int, syntax *commentSyntax, written string) (int, bool) {
+	rest := content[i:]
+	c := content[i]
+
+	for _, delimiter := range syntax.tripleQuotes {
+		if strings.HasPrefix(rest, delimiter) {
+			return skipQuoted(content, i+len(delimiter), delimiter, true, true)
+		}
+	}
+
+	switch {
+	case syntax.verbatimStrings && strings.HasPrefix(rest, `@"`):
+		for j := i + 2; j < len(content); j++ {
+			if content[j] != '"' {
+				continue
+			}
+			if j+1 < len(content) && content[j+1] == '"' {
+				j++
+				continue
+			}
+			return j + 1, true
+		}
+		return i, false
+	case strings.IndexByte(syntax.rawQuotes, c) >= 0:
+		return skipQuoted(content, i+1, string(c), false, true)
+	case strings.IndexByte(syntax.multilineQuotes, c) >= 0:
+		return skipQuoted(content, i+1, string(c), true, true)
+	case strings.IndexByte(syntax.quotes, c) >= 0:
+		return skipQuoted(content, i+1, string(c), true, false)
+	case syntax.charLiterals && c == '\'':
+		return skipCharLiteral(content, i), true
+	case syntax.regexLiterals && c == '/' && startsRegex(written):
+		return skipRegex(content, i)
+	}
+	return i, true
+}
+
+// skipQuoted returns the index just past the delimiter closing a string whose
+// contents start at i
+func skipQuoted(content string, i int, delimiter string, escapes, multiline bool) (int, bool) {
+	for i < len(content) {
+		switch {
+		case escapes && content[i] == '\\':
+			i += 2
+		case strings.HasPrefix(content[i:], delimiter):
+			return i + len(delimiter), true
+		case content[i] == '\n' && !multiline:
+			return i, false
+		default:
+			i++
+		}
+	}
+	return i, false
+}
+
+// skipCharLiteral returns the index just past the character literal starting at i,
+// or i if the quote doesn't start one
+func skipCharLiteral(content string, i int) int {
+	if i+1 >= len(content) {
+		return i
+	}
+	if content[i+1] == '\\' {
+		// Escapes such as '\n', '\x7f' and 'é' are short
+		for j := i + 2; j < len(content) && j < i+12; j++ {
+			switch content[j] {
+			case '\n':
+				return i
+			case '\'':
+				return j + 1
+			}
+		}
+		return i
+	}
+	r, size := utf8.DecodeRuneInString(content[i+1:])
+	if r == '\n' || r == '\'' || i+1+size >= len(content) || content[i+1+size] != '\'' {
+		return i
+	}
+	return i + 2 + size
+}
+
+// startsRegex reports whether a / following written starts a regular expression
+// rather than dividing
+func startsRegex(written string) bool {
+	trimmed := strings.TrimRight(written, " \t")
+	if trimmed == "" || strings.HasSuffix(trimmed, "\n") {
+		return true
+	}
+	if strings.IndexByte("(,=:[!&|?{};+-*%<>~^", trimmed[len(trimmed)-1]) >= 0 {
+		return true
+	}
+	word := trimmed
+	if j := strings.LastIndexFunc(trimmed, func(r rune) bool {
+		return !(r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}); j >= 0 {
+		word = trimmed[j+1:]
+	}
+	return regexPrecedingKeywords[word]
+}
+
+// skipRegex returns the index just past the regular expression literal starting at
+// i, including its flags
+func skipRegex(content string, i int) (int, bool) {
+	inClass := false
+	for j := i + 1; j < len(content); j++ {
+		switch content[j] {
+		case '\\':
+			j++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '\n':
+			return i, false
+		case '/':
+			if inClass {
+				continue
+			}
+			j++
+			for j < len(content) && (content[j] >= 'a' && content[j] <= 'z') {
+				j++
+			}
+			return j, true
+		}
+	}
+	return i, false
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,122 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddingContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		content  string
+		want     string
+	}{
+		{
+			"go comments",
+			"go",
+			"// Package widgets builds widgets.\npackage widgets\n\n/* Widget is\n   a widget. */\ntype Widget struct {\n\tName string // display name\n}\n",
+			"package widgets\ntype Widget struct {\n\tName string\n}",
+		},
+		{
+			"go strings that look like comments",
+			"go",
+			"var url = \"http://example.com\" // homepage\nvar raw = `/* not a comment */`\nvar slash = '/'\n",
+			"var url = \"http://example.com\"\nvar raw = `/* not a comment */`\nvar slash = '/'",
+		},
+		{
+			"go inline block comment",
+			"go",
+			"return a /* first */ + b\n",
+			"return a   + b",
+		},
+		{
+			"python comments",
+			"python",
+			"# Widget helpers\n\ndef build(name):  # build a widget\n    return Widget(name)\n",
+			"def build(name):\n    return Widget(name)",
+		},
+		{
+			"python strings that look like comments",
+			"python",
+			"colour = \"#ff0000\"  # red\nquery = '''\nSELECT 1 -- # not a comment\n'''\n",
+			"colour = \"#ff0000\"\nquery = '''\nSELECT 1 -- # not a comment\n'''",
+		},
+		{
+			"unterminated string keeps the comments",
+			"python",
+			"# header\nbroken = \"unterminated\n\nx = 1\n",
+			"# header\nbroken = \"unterminated\nx = 1",
+		},
+		{
+			"unknown language only drops blank lines",
+			"cobol",
+			"* COMMENT\n\n       DISPLAY 'HI'.   \n",
+			"* COMMENT\n       DISPLAY 'HI'.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := embeddingContent(tt.language, tt.content); got != tt.want {
+				t.Errorf("embeddingContent =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// recordingEmbeddings embeds texts as gatedEmbeddings does, keeping every text sent
+type recordingEmbeddings struct {
+	gatedEmbeddings
+	sent []string
+}
+
+func (e *recordingEmbeddings) GenerateEmbeddings(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	e.sent = append(e.sent, texts...)
+	return e.gatedEmbeddings.GenerateEmbeddings(ctx, texts, model)
+}
+
+func TestGenerateEmbeddingsStripsComments(t *testing.T) {
+	chunks := []*FileChunk{
+		{RepositoryID: "repo-1", FilePath: "widget.go", Language: "go", Content: "// Widget is a widget\ntype Widget struct{} // empty"},
+		{RepositoryID: "repo-1", FilePath: "widget.py", Language: "python", Content: "# Widget is a widget\nclass Widget:  # empty\n    pass"},
+		// Nothing but comments is embedded as is rather than as nothing
+		{RepositoryID: "repo-1", FilePath: "doc.go", Language: "go", Content: "// Package widgets builds widgets."},
+	}
+	original := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		original[i] = chunk.Content
+	}
+
+	tests := []struct {
+		strip bool
+		want  []string
+	}{
+		{false, original},
+		{true, []string{"type Widget struct{}", "class Widget:\n    pass", "// Package widgets builds widgets."}},
+	}
+	for _, tt := range tests {
+		embeddings := &recordingEmbeddings{}
+		ip := newPipelineProcessor(t, embeddings, &memoryVectors{collections: make(map[string][]*Vector)}, 1, 0)
+		ip.uploadConfig.StripEmbeddingComments = tt.strip
+
+		embedded, err := ip.GenerateEmbeddings(context.Background(), chunks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(embeddings.sent) != len(chunks) {
+			t.Fatalf("strip %v: embedded %d texts, want %d", tt.strip, len(embeddings.sent), len(chunks))
+		}
+		for i, text := range embeddings.sent {
+			if !strings.HasSuffix(text, "Content:\n"+tt.want[i]) {
+				t.Errorf("strip %v: embedded %q, want the content %q", tt.strip, text, tt.want[i])
+			}
+		}
+		// The stored chunks keep their comments for display and lexical search
+		for i, chunk := range embedded {
+			if chunk.Content != original[i] {
+				t.Errorf("strip %v: stored content %q, want %q", tt.strip, chunk.Content, original[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ingest
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// generatedPathPatterns match vendored dependencies, build output and files produced
+// by code generators. vendor/ and node_modules/ are never scanned, but checked-in
+// copies under other names still show up in search results.
+var generatedPathPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(^|/)(vendor|node_modules|third_party|third-party|bower_components)/`),
+	regexp.MustCompile(`(^|/)(dist|build|out|target|generated|__generated__)/`),
+	regexp.MustCompile(`\.pb\.go$|\.pb\.gw\.go$|_pb2(_grpc)?\.py$|\.pb\.(cc|h)$`),
+	regexp.MustCompile(`(_generated|\.generated|_gen|\.gen)\.\w+$`),
+	regexp.MustCompile(`(^|/)(zz_generated\.[^/]+|bindata\.go)$`),
+	regexp.MustCompile(`\.min\.(js|css)$|\.bundle\.js$|\.map$`),
+	regexp.MustCompile(`(^|/)(package-lock\.json|yarn\.lock|pnpm-lock\.yaml|go\.sum|Cargo\.lock|poetry\.lock|composer\.lock)$`),
+}
+
+// generatedMarker matches the headers code generators put at the top of their
+// output, such as Go's "// Code generated by protoc-gen-go. DO NOT EDIT."
+var generatedMarker = regexp.MustCompile(`(?i)code generated .*do not edit|@generated\b|<auto-generated|this (file|code) (is|was) (auto(matically)?[- ]?)?generated|generated by .*do not (edit|modify)`)
+
+// generatedHeaderLength bounds how much of a file is searched for a marker
+const generatedHeaderLength = 1024
+
+// IsGeneratedPath reports whether path looks like vendored or generated code
+func IsGeneratedPath(path string) bool {
+	path = strings.ReplaceAll(path, "\\", "/")
+	for _, pattern := range generatedPathPatterns {
+		if pattern.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasGeneratedMarker reports whether content starts with a code generator's header
+func HasGeneratedMarker(content string) bool {
+	if len(content) > generatedHeaderLength {
+		content = content[:generatedHeaderLength]
+	}
+	return generatedMarker.MatchString(content)
+}
+
+// IsGenerated reports whether a file is vendored or generated, by its path or by a
+// marker in content (the start of the file, or of a chunk of it)
+func IsGenerated(path, content string) bool {
+	return IsGeneratedPath(path) || HasGeneratedMarker(content)
+}
+
+// isGeneratedFile checks a file on disk, reading only its header
+func isGeneratedFile(relPath, path string) bool {
+	if IsGeneratedPath(relPath) {
+		return true
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	buf := make([]byte, generatedHeaderLength)
+	n, _ := io.ReadFull(file, buf)
+	return HasGeneratedMarker(string(buf[:n]))
+}
@@ -3,24 +3,30 @@ package ingest
 import (
 	"archive/tar"
 	"archive/zip"
-	"bufio"
+	"bytes"
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
 	"repo-context-service/internal/cache"
+	"repo-context-service/internal/config"
 	"repo-context-service/internal/observability"
 	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -28,21 +34,66 @@ type InlineProcessor struct {
 	cache         *cache.RedisCache
 	metrics       *observability.Metrics
 	tracer        *observability.Tracer
+	logger        *slog.Logger
 	embeddingClient EmbeddingClient
 	vectorClient    VectorClient
+	uploadConfig  config.UploadConfig
+	defaults      config.DefaultsConfig
 	workDir       string
 	tempDir       string
+
+	// Running and queued ingestions keyed by repository ID, and the final status of
+	// the last finished one
+	activeJobs   map[string]*IngestionJob
+	finishedJobs map[string]*repocontextv1.IngestionStatus
+	jobsMutex    sync.Mutex
+	// Holds a token per running ingestion, bounding them to MaxConcurrentIngestions
+	workerSlots chan struct{}
 }
 
+// cancelWaitTimeout bounds how long CancelIndex waits for a job to stop
+const cancelWaitTimeout = 10 * time.Second
+
+// finishedJobRetention is how long GetIndexStatus keeps reporting a finished job; its
+// final status is in the repository record by then
+const finishedJobRetention = time.Hour
+
+// EmbeddingClient is implemented by each embedding provider (see EMBEDDING_PROVIDER)
 type EmbeddingClient interface {
 	GenerateEmbeddings(ctx context.Context, texts []string, model string) ([][]float32, error)
 	GetDefaultModel() string
+	// Dimensions is the vector size of the default model; collections are created with it
+	Dimensions() int
+}
+
+// RetryingEmbeddingClient is implemented by providers that can retry transient
+// failures (rate limits, server errors) themselves
+type RetryingEmbeddingClient interface {
+	GenerateEmbeddingsWithRetry(ctx context.Context, texts []string, model string, maxRetries int) ([][]float32, error)
+}
+
+// embeddingRetries is how many times a failed embeddings request is retried
+const embeddingRetries = 3
+
+// EmbedTexts embeds texts with client, retrying transient failures when the
+// provider supports it
+func EmbedTexts(ctx context.Context, client EmbeddingClient, texts []string, model string) ([][]float32, error) {
+	if retrying, ok := client.(RetryingEmbeddingClient); ok {
+		return retrying.GenerateEmbeddingsWithRetry(ctx, texts, model, embeddingRetries)
+	}
+	return client.GenerateEmbeddings(ctx, texts, model)
 }
 
 type VectorClient interface {
 	CreateCollection(ctx context.Context, name string, dimensions int) error
 	UpsertVectors(ctx context.Context, collectionName string, vectors []*Vector) error
+	DeleteVectorsByFile(ctx context.Context, collectionName, filePath string) (int, error)
 	DeleteCollection(ctx context.Context, name string) error
+	// ScanVectors calls fn with every vector of a collection, a batch at a time,
+	// stopping at the first error fn returns
+	ScanVectors(ctx context.Context, collectionName string, fn func([]*Vector) error) error
+	// CountVectors returns how many vectors a collection holds
+	CountVectors(ctx context.Context, collectionName string) (int, error)
 }
 
 type Vector struct {
@@ -55,18 +106,27 @@ func NewInlineProcessor(
 	cache *cache.RedisCache,
 	metrics *observability.Metrics,
 	tracer *observability.Tracer,
+	logger *slog.Logger,
 	embeddingClient EmbeddingClient,
 	vectorClient VectorClient,
+	uploadConfig config.UploadConfig,
+	defaults config.DefaultsConfig,
 	workDir, tempDir string,
 ) *InlineProcessor {
 	return &InlineProcessor{
 		cache:           cache,
 		metrics:         metrics,
 		tracer:          tracer,
+		logger:          logger,
 		embeddingClient: embeddingClient,
 		vectorClient:    vectorClient,
+		uploadConfig:    uploadConfig,
+		defaults:        defaults,
 		workDir:         workDir,
 		tempDir:         tempDir,
+		activeJobs:      make(map[string]*IngestionJob),
+		finishedJobs:    make(map[string]*repocontextv1.IngestionStatus),
+		workerSlots:     make(chan struct{}, uploadConfig.MaxConcurrentIngestions),
 	}
 }
 
@@ -104,6 +164,27 @@ func (ip *InlineProcessor) CreateRepositoryIndex(ctx context.Context, req *Creat
 		UpdatedAt: time.Now(),
 	}
 
+	// The job context outlives the request and is cancelled through CancelIndex. It
+	// keeps the ID of the request that started it, or the upload ID without one
+	requestID := observability.RequestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = job.ID
+	}
+	jobCtx, cancel := context.WithCancel(context.Background())
+	jobCtx = observability.ContextWithRequestID(jobCtx, requestID)
+	jobCtx = observability.ContextWithLogger(jobCtx, ip.logger.With(
+		observability.LogKeyRequestID, requestID,
+		observability.LogKeyUploadID, job.ID,
+		observability.LogKeyTenantID, job.TenantID,
+		observability.LogKeyRepositoryID, job.RepositoryID,
+	))
+	job.cancel = cancel
+	job.done = make(chan struct{})
+	if err := ip.registerJob(job); err != nil {
+		cancel()
+		return nil, err
+	}
+
 	// Cache initial status
 	cachedStatus := &cache.CachedUploadStatus{
 		UploadID:     req.IdempotencyKey,
@@ -114,16 +195,31 @@ func (ip *InlineProcessor) CreateRepositoryIndex(ctx context.Context, req *Creat
 	}
 
 	if err := ip.cache.SetUploadStatus(ctx, req.TenantID, cachedStatus); err != nil {
+		ip.unregisterJob(job)
+		cancel()
 		return nil, fmt.Errorf("failed to cache upload status: %w", err)
 	}
 
-	// Start ingestion in background
-	go ip.processRepositoryAsync(context.Background(), job)
+	// A re-indexed repository reports the new ingestion until it finishes. This is
+	// written before the job starts so it can't land on top of the job's final state.
+	if req.Incremental {
+		if _, err := ip.cache.UpdateRepositoryMetadata(ctx, req.TenantID, req.RepositoryID, func(repo *repocontextv1.Repository) {
+			job.previousStatus = repo.IngestionStatus
+			repo.IngestionStatus = proto.Clone(job.Status).(*repocontextv1.IngestionStatus)
+			repo.UpdatedAt = timestamppb.Now()
+		}); err != nil {
+			ip.loggerFrom(ctx).Warn("CreateRepositoryIndex: Failed to update repository status", "error", err)
+		}
+	}
+
+	// Start ingestion in background; it stays pending until a worker slot is free
+	acceptedStatus := job.Status
+	go ip.processRepositoryAsync(jobCtx, job)
 
 	return &CreateIndexResponse{
 		RepositoryID: req.RepositoryID,
 		IndexID:      req.RepositoryID,
-		Status:       job.Status,
+		Status:       acceptedStatus,
 		AcceptedAt:   job.CreatedAt,
 	}, nil
 }
@@ -132,13 +228,38 @@ func (ip *InlineProcessor) processRepositoryAsync(ctx context.Context, job *Inge
 	timer := observability.StartTimer()
 	defer func() {
 		ip.metrics.RecordIngestionDuration(timer.Duration())
+		ip.unregisterJob(job)
+		job.cancel()
+		close(job.done)
+	}()
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		ip.heartbeat(heartbeatCtx, job)
 	}()
 
-	if err := ip.processRepository(ctx, job); err != nil {
-		job.Status.State = repocontextv1.IngestionStatus_STATE_FAILED
+	err := ip.acquireWorkerSlot(ctx)
+	if err == nil {
+		defer ip.releaseWorkerSlot()
+		err = ip.processRepository(ctx, job)
+	}
+	stopHeartbeat()
+	<-heartbeatDone
+	if err != nil {
 		job.ErrorMessage = err.Error()
 		job.UpdatedAt = time.Now()
 
+		if ctx.Err() != nil {
+			job.ErrorMessage = "ingestion cancelled"
+			// The job context is gone; record the final status with a fresh one
+			ctx = context.Background()
+		}
+		// A job cancelled in the queue never got as far as extracting its upload
+		ip.removeUploadedArchive(ctx, job.Request.Source)
+		ip.setJobState(job, repocontextv1.IngestionStatus_STATE_FAILED, job.ErrorMessage)
+
 		// Update cache with error
 		cachedStatus := &cache.CachedUploadStatus{
 			UploadID:     job.ID,
@@ -149,124 +270,428 @@ func (ip *InlineProcessor) processRepositoryAsync(ctx context.Context, job *Inge
 			CreatedAt:    job.CreatedAt,
 		}
 		ip.cache.SetUploadStatus(ctx, job.TenantID, cachedStatus)
+
+		// Don't leave the repository reporting an ingestion that's no longer running.
+		// A failed re-index leaves the previous index in place, so a repository that
+		// was ready stays ready.
+		repoStatus := job.Status
+		if job.previousStatus.GetState() == repocontextv1.IngestionStatus_STATE_READY {
+			repoStatus = proto.Clone(job.previousStatus).(*repocontextv1.IngestionStatus)
+			repoStatus.ErrorMessage = "reindex failed: " + job.ErrorMessage
+			repoStatus.UpdatedAt = timestamppb.Now()
+		}
+		if _, err := ip.cache.UpdateRepositoryMetadata(ctx, job.TenantID, job.RepositoryID, func(repo *repocontextv1.Repository) {
+			repo.IngestionStatus = repoStatus
+			repo.UpdatedAt = timestamppb.Now()
+		}); err != nil {
+			ip.loggerFrom(ctx).Warn("processRepositoryAsync: Failed to update repository status", "error", err)
+		}
 	}
 }
 
 func (ip *InlineProcessor) processRepository(ctx context.Context, job *IngestionJob) error {
 	req := job.Request
+	logger := ip.loggerFrom(ctx)
 
-	log.Printf("processRepository: Starting processing for repository %s", req.RepositoryID)
+	logger.Info("processRepository: Starting processing")
 
 	// Update status to extracting
-	job.Status.State = repocontextv1.IngestionStatus_STATE_EXTRACTING
+	ip.setJobState(job, repocontextv1.IngestionStatus_STATE_EXTRACTING, "")
 	ip.updateJobStatus(ctx, job)
+	phaseTimer := observability.StartTimer()
+
+	// Re-indexing extracts next to the current working tree, which lexical search
+	// keeps using until the new one is swapped in
+	repoDir := filepath.Join(ip.workDir, req.RepositoryID)
+	extractDir := repoDir
+	if req.Incremental {
+		extractDir = repoDir + reindexDirSuffix
+		if err := os.RemoveAll(extractDir); err != nil {
+			return fmt.Errorf("failed to clear reindex directory: %w", err)
+		}
+		defer os.RemoveAll(extractDir)
+	}
 
 	// Extract repository
-	extractResult, err := ip.ExtractRepository(ctx, req.Source, filepath.Join(ip.workDir, req.RepositoryID))
+	extractResult, err := ip.ExtractRepository(ctx, req.Source, extractDir)
+	// An uploaded archive can't be extracted again once this attempt is over, so it's
+	// removed whether or not extraction worked
+	ip.removeUploadedArchive(ctx, req.Source)
 	if err != nil {
 		return fmt.Errorf("failed to extract repository: %w", err)
 	}
 
+	// Only new and changed files are chunked and embedded when re-indexing
+	toIndex := extractResult
+	var plan *reindexPlan
+	if req.Incremental {
+		plan, err = ip.planReindex(ctx, req, extractResult.Files)
+		if err != nil {
+			return err
+		}
+		toIndex = &ExtractResult{
+			RepositoryPath: extractResult.RepositoryPath,
+			CommitSHA:      extractResult.CommitSHA,
+			Files:          plan.changed,
+			Stats:          extractResult.Stats,
+		}
+	}
+
+	// Generated and vendored files stay searchable lexically but aren't embedded
+	if ip.uploadConfig.SkipGeneratedEmbeddings {
+		toIndex = withoutGeneratedFiles(toIndex)
+		logger.Info("processRepository: Skipping generated files", "files", len(extractResult.Files)-len(toIndex.Files))
+	}
+
+	ip.metrics.RecordIngestionPhaseDuration("extract", phaseTimer.Duration())
+
 	// Update status to chunking
-	job.Status.State = repocontextv1.IngestionStatus_STATE_CHUNKING
+	ip.setJobState(job, repocontextv1.IngestionStatus_STATE_CHUNKING, "")
 	ip.updateJobStatus(ctx, job)
+	phaseTimer = observability.StartTimer()
 
 	// Create progress tracker
-	progressTracker := NewProgressTracker(int32(len(extractResult.Files)), req.ProgressCallback)
+	totalFiles := int32(len(toIndex.Files))
+	progressTracker := NewProgressTracker(totalFiles, ip.progressCallback(ctx, job))
+	ctx = withProgressTracker(ctx, progressTracker)
 
 	// Chunk files
-	var excludePatterns []string
-	var includePatterns []string
-	var maxFileSizeMb int32 = 10 // Default 10MB
-
-	if req.Options != nil {
-		excludePatterns = req.Options.ExcludePatterns
-		includePatterns = req.Options.IncludePatterns
-		if req.Options.MaxFileSizeMb > 0 {
-			maxFileSizeMb = req.Options.MaxFileSizeMb
-		}
+	chunkOptions := NewChunkOptions(ip.defaults, req.Options)
+	if err := chunkOptions.Validate(); err != nil {
+		return fmt.Errorf("invalid chunk options: %w", err)
 	}
 
-	chunkOptions := &ChunkOptions{
-		ChunkSize:       100,
-		ChunkOverlap:    10,
-		ExcludePatterns: excludePatterns,
-		IncludePatterns: includePatterns,
-		MaxFileSize:     int64(maxFileSizeMb) * 1024 * 1024,
-	}
-
-	log.Printf("processRepository: About to start chunking %d files", len(extractResult.Files))
-	chunks, err := ip.ChunkFiles(ctx, extractResult, chunkOptions)
+	chunks, err := ip.ChunkFiles(ctx, toIndex, chunkOptions)
 	if err != nil {
-		log.Printf("processRepository: ChunkFiles failed: %v", err)
+		logger.Error("processRepository: ChunkFiles failed", "error", err)
 		return fmt.Errorf("failed to chunk files: %w", err)
 	}
 
-	log.Printf("processRepository: ChunkFiles completed successfully. Created %d chunks from %d files",
-		len(chunks), len(extractResult.Files))
-
-	progressTracker.SetCounts(int32(len(extractResult.Files)), int32(len(extractResult.Files)), int32(len(chunks)), 0, 0)
+	progressTracker.SetCounts(totalFiles, totalFiles, int32(len(chunks)), 0, 0)
+	ip.metrics.RecordIngestionPhaseDuration("chunk", phaseTimer.Duration())
 
 	// Update status to embedding
-	job.Status.State = repocontextv1.IngestionStatus_STATE_EMBEDDING
+	ip.setJobState(job, repocontextv1.IngestionStatus_STATE_EMBEDDING, "")
 	ip.updateJobStatus(ctx, job)
+	phaseTimer = observability.StartTimer()
 
-	log.Printf("processRepository: About to generate embeddings for %d chunks", len(chunks))
+	logger.Info("processRepository: Generating embeddings", "chunks", len(chunks))
 	// Generate embeddings
 	embeddedChunks, err := ip.GenerateEmbeddings(ctx, chunks)
 	if err != nil {
-		log.Printf("processRepository: GenerateEmbeddings failed: %v", err)
+		logger.Error("processRepository: GenerateEmbeddings failed", "error", err)
 		return fmt.Errorf("failed to generate embeddings: %w", err)
 	}
 
-	log.Printf("processRepository: GenerateEmbeddings completed. Generated embeddings for %d chunks", len(embeddedChunks))
-
-	progressTracker.SetCounts(int32(len(extractResult.Files)), int32(len(extractResult.Files)), int32(len(chunks)), int32(len(embeddedChunks)), 0)
+	progressTracker.SetCounts(totalFiles, totalFiles, int32(len(chunks)), int32(len(embeddedChunks)), 0)
+	ip.metrics.RecordIngestionPhaseDuration("embed", phaseTimer.Duration())
 
 	// Update status to indexing
-	job.Status.State = repocontextv1.IngestionStatus_STATE_INDEXING
+	ip.setJobState(job, repocontextv1.IngestionStatus_STATE_INDEXING, "")
 	ip.updateJobStatus(ctx, job)
+	phaseTimer = observability.StartTimer()
+
+	// Don't create the collection if the repository was deleted while embedding
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("ingestion cancelled before indexing: %w", err)
+	}
 
-	log.Printf("processRepository: About to index %d embedded chunks to Weaviate", len(embeddedChunks))
+	// A re-index only drops the vectors it replaces once their replacements are
+	// embedded, so a failure before this point leaves the previous index intact
+	keptChunks := 0
+	if plan != nil {
+		keptChunks, err = ip.removeStaleVectors(ctx, req, plan)
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.Info("processRepository: Indexing embeddings", "chunks", len(embeddedChunks))
 	// Index embeddings
 	if err := ip.IndexEmbeddings(ctx, req.RepositoryID, embeddedChunks); err != nil {
-		log.Printf("processRepository: IndexEmbeddings failed: %v", err)
+		logger.Error("processRepository: IndexEmbeddings failed", "error", err)
 		return fmt.Errorf("failed to index embeddings: %w", err)
 	}
 
-	log.Printf("processRepository: IndexEmbeddings completed successfully")
+	logger.Info("processRepository: Indexing completed")
+
+	if req.Incremental {
+		if err := replaceDirectory(extractDir, repoDir); err != nil {
+			return fmt.Errorf("failed to replace working tree: %w", err)
+		}
+		extractResult.RepositoryPath = repoDir
+	}
 
-	progressTracker.SetCounts(int32(len(extractResult.Files)), int32(len(extractResult.Files)), int32(len(chunks)), int32(len(embeddedChunks)), int32(len(embeddedChunks)))
+	progressTracker.SetCounts(totalFiles, totalFiles, int32(len(chunks)), int32(len(embeddedChunks)), int32(len(embeddedChunks)))
+	ip.metrics.RecordIngestionPhaseDuration("index", phaseTimer.Duration())
 
 	// Update status to ready
-	job.Status.State = repocontextv1.IngestionStatus_STATE_READY
+	ip.setJobState(job, repocontextv1.IngestionStatus_STATE_READY, "")
 	job.Progress.ProgressPercent = 100
 	ip.updateJobStatus(ctx, job)
 
-	// Store repository metadata
+	// Store repository metadata. Unchanged files keep their vectors on a re-index,
+	// so they count towards the total too.
+	stats := proto.Clone(extractResult.Stats).(*repocontextv1.RepositoryStats)
+	stats.TotalChunks = int32(keptChunks + len(embeddedChunks))
+
+	// Record the exact commit that was ingested so the routing key identifies it
+	source := proto.Clone(req.Source).(*repocontextv1.RepositorySource)
+	if extractResult.CommitSHA != "" {
+		source.CommitSha = extractResult.CommitSHA
+	}
+
 	repository := &repocontextv1.Repository{
 		RepositoryId:    req.RepositoryID,
-		Name:            extractRepositoryName(req.Source),
-		Source:          req.Source,
+		Name:            extractRepositoryName(source),
+		Source:          source,
 		IngestionStatus: job.Status,
-		Stats:           extractResult.Stats,
+		Stats:           stats,
 		CreatedAt:       timestamppb.New(job.CreatedAt),
 		UpdatedAt:       timestamppb.Now(),
 	}
 
+	// The record written when the ingestion was accepted keeps its name, description
+	// and creation time
+	if existing, err := ip.cache.GetRepositoryMetadata(ctx, req.TenantID, req.RepositoryID); err == nil && existing != nil {
+		repository.Name = existing.Name
+		repository.Description = existing.Description
+		repository.CreatedAt = existing.CreatedAt
+		// Reindexing at a new ref leaves the old routing entry behind
+		if req.Incremental && existing.Source != nil {
+			if oldKey := generateRepoKey(existing.Source); oldKey != generateRepoKey(source) {
+				if err := ip.cache.DeleteRepositoryIndex(ctx, req.TenantID, oldKey); err != nil {
+					logger.Warn("processRepository: Failed to delete old repository routing", "error", err)
+				}
+			}
+		}
+	}
+
 	if err := ip.cache.SetRepositoryMetadata(ctx, req.TenantID, repository); err != nil {
 		return fmt.Errorf("failed to store repository metadata: %w", err)
 	}
 
+	// Results cached against the previous index of a re-indexed repository are stale
+	if err := ip.cache.DeleteQueryResults(ctx, req.TenantID, req.RepositoryID); err != nil {
+		logger.Warn("processRepository: Failed to delete cached query results", "error", err)
+	}
+
 	// Set repository routing
-	repoKey := generateRepoKey(req.Source)
+	repoKey := generateRepoKey(source)
 	if err := ip.cache.SetRepositoryIndex(ctx, req.TenantID, repoKey, req.RepositoryID); err != nil {
 		return fmt.Errorf("failed to set repository routing: %w", err)
 	}
 
+	// Without stored hashes the next re-index falls back to a full rebuild
+	if err := ip.cache.SetFileHashes(ctx, req.TenantID, req.RepositoryID, fileHashes(extractResult.Files)); err != nil {
+		logger.Warn("processRepository: Failed to store file hashes", "error", err)
+	}
+	if err := ip.cache.SetFileInventory(ctx, req.TenantID, req.RepositoryID, fileInventory(extractResult.Files)); err != nil {
+		logger.Warn("processRepository: Failed to store file inventory", "error", err)
+	}
+	// A re-index reuses them so the repository is chunked and filtered the same way
+	if err := ip.cache.SetUploadOptions(ctx, req.TenantID, req.RepositoryID, req.Options); err != nil {
+		logger.Warn("processRepository: Failed to store upload options", "error", err)
+	}
+
 	return nil
 }
 
+// withoutGeneratedFiles returns a copy of result without its generated files
+func withoutGeneratedFiles(result *ExtractResult) *ExtractResult {
+	files := make([]*FileInfo, 0, len(result.Files))
+	for _, file := range result.Files {
+		if !file.Generated {
+			files = append(files, file)
+		}
+	}
+	filtered := *result
+	filtered.Files = files
+	return &filtered
+}
+
+// reindexDirSuffix names the directory a re-index extracts into before it replaces
+// the repository's working tree
+const reindexDirSuffix = ".reindex"
+
+// reindexPlan is what a re-index changes in the repository's index
+type reindexPlan struct {
+	// Files that are new or modified and need to be (re)indexed
+	changed []*FileInfo
+	// Previously indexed paths whose vectors are replaced or removed
+	stale []string
+	// Set when there are no stored hashes to diff against; the index is rebuilt
+	rebuild bool
+}
+
+// planReindex diffs the scanned files against the hashes stored by the last
+// ingestion. Nothing is deleted yet, so the current index keeps serving searches
+// until the new embeddings are ready.
+func (ip *InlineProcessor) planReindex(ctx context.Context, req *CreateIndexRequest, files []*FileInfo) (*reindexPlan, error) {
+	logger := ip.loggerFrom(ctx)
+
+	previous, err := ip.cache.GetFileHashes(ctx, req.TenantID, req.RepositoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load file hashes: %w", err)
+	}
+
+	if len(previous) == 0 {
+		logger.Info("planReindex: No stored file hashes; rebuilding the index")
+		return &reindexPlan{changed: files, rebuild: true}, nil
+	}
+
+	changed, stale := diffFileHashes(previous, files)
+	logger.Info("planReindex: Computed changes",
+		"files", len(files), "changed", len(changed), "stale", len(stale))
+	return &reindexPlan{changed: changed, stale: stale}, nil
+}
+
+// removeStaleVectors deletes the vectors a re-index replaces and returns the number
+// of chunks left in the index
+func (ip *InlineProcessor) removeStaleVectors(ctx context.Context, req *CreateIndexRequest, plan *reindexPlan) (int, error) {
+	className := CollectionName(req.RepositoryID)
+
+	if plan.rebuild {
+		if err := ip.vectorClient.DeleteCollection(ctx, className); err != nil {
+			ip.loggerFrom(ctx).Warn("removeStaleVectors: Failed to delete collection", "error", err)
+		}
+		return 0, nil
+	}
+
+	// The chunk count stored by the last ingestion is what's in the index now
+	indexedChunks := 0
+	if existing, err := ip.cache.GetRepositoryMetadata(ctx, req.TenantID, req.RepositoryID); err == nil && existing != nil {
+		indexedChunks = int(existing.GetStats().GetTotalChunks())
+	}
+
+	for _, path := range plan.stale {
+		deleted, err := ip.vectorClient.DeleteVectorsByFile(ctx, className, path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete vectors for %s: %w", path, err)
+		}
+		indexedChunks -= deleted
+	}
+	if indexedChunks < 0 {
+		indexedChunks = 0
+	}
+	return indexedChunks, nil
+}
+
+// diffFileHashes returns the files that are new or whose hash changed, and the paths
+// of previously indexed files that were modified or removed
+func diffFileHashes(previous map[string]string, files []*FileInfo) (changed []*FileInfo, stale []string) {
+	current := make(map[string]bool, len(files))
+	for _, file := range files {
+		current[file.Path] = true
+
+		hash, indexed := previous[file.Path]
+		if indexed && hash == file.Hash {
+			continue
+		}
+		changed = append(changed, file)
+		if indexed {
+			stale = append(stale, file.Path)
+		}
+	}
+
+	for path := range previous {
+		if !current[path] {
+			stale = append(stale, path)
+		}
+	}
+	sort.Strings(stale)
+
+	return changed, stale
+}
+
+func fileHashes(files []*FileInfo) map[string]string {
+	hashes := make(map[string]string, len(files))
+	for _, file := range files {
+		if file.Hash != "" {
+			hashes[file.Path] = file.Hash
+		}
+	}
+	return hashes
+}
+
+// fileInventory lists the scanned files for ListFiles
+func fileInventory(files []*FileInfo) []*repocontextv1.FileEntry {
+	entries := make([]*repocontextv1.FileEntry, 0, len(files))
+	for _, file := range files {
+		entries = append(entries, &repocontextv1.FileEntry{
+			Path:      filepath.ToSlash(file.Path),
+			SizeBytes: file.Size,
+			Language:  file.Language,
+			LineCount: int32(file.LineCount),
+		})
+	}
+	return entries
+}
+
+// ReconcileWorkDir removes working trees left behind by failed or interrupted
+// ingestions: directories under the work directory that belong to no repository with
+// metadata and no running ingestion. Re-index directories of idle repositories are
+// leftovers too. Anything modified within gracePeriod is kept, as it may belong to an
+// ingestion that's just starting. It returns how many directories were removed.
+func (ip *InlineProcessor) ReconcileWorkDir(ctx context.Context, gracePeriod time.Duration) (int, error) {
+	entries, err := os.ReadDir(ip.workDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list work directory: %w", err)
+	}
+
+	liveRepos, err := ip.cache.ListAllRepositoryIDs(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list repositories: %w", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		repoID := strings.TrimSuffix(name, reindexDirSuffix)
+		isReindexDir := repoID != name
+
+		ip.jobsMutex.Lock()
+		_, running := ip.activeJobs[repoID]
+		ip.jobsMutex.Unlock()
+		if running || (liveRepos[repoID] && !isReindexDir) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) < gracePeriod {
+			continue
+		}
+
+		path := filepath.Join(ip.workDir, name)
+		if err := os.RemoveAll(path); err != nil {
+			ip.logger.Warn("ReconcileWorkDir: Failed to remove orphaned directory", "path", path, "error", err)
+			continue
+		}
+		ip.logger.Info("ReconcileWorkDir: Removed orphaned directory", "path", path)
+		removed++
+	}
+
+	return removed, nil
+}
+
+// replaceDirectory moves src to dst, removing whatever dst held
+func replaceDirectory(src, dst string) error {
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
 func (ip *InlineProcessor) ExtractRepository(ctx context.Context, source *repocontextv1.RepositorySource, targetDir string) (*ExtractResult, error) {
 	ctx, span := ip.tracer.StartIngestion(ctx, "", "extract")
 	defer span.End()
@@ -309,13 +734,26 @@ func (ip *InlineProcessor) cloneGitRepository(ctx context.Context, gitURL, ref,
 	if ref == "" {
 		ref = "main"
 	}
+	if err := ValidateGitSource(gitURL, ref); err != nil {
+		return "", err
+	}
+
+	// Check the estimated size before pulling down a huge working tree
+	if err := ip.checkRepositorySize(ctx, gitURL); err != nil {
+		return "", err
+	}
 
-	// Shallow clone
-	cmd := exec.CommandContext(ctx, "git", "clone", "--depth=1", "--branch", ref, gitURL, targetDir)
+	// Shallow clone. A commit SHA isn't a branch, so it's checked out after a full
+	// clone instead.
+	pinned := commitSHAPattern.MatchString(ref)
+	cmd := exec.CommandContext(ctx, "git", ip.cloneArgs(gitURL, ref, targetDir)...)
+	if pinned {
+		cmd = exec.CommandContext(ctx, "git", ip.pinnedCloneArgs(gitURL, targetDir)...)
+	}
 	if err := cmd.Run(); err != nil {
 		// Try master if main fails
 		if ref == "main" {
-			cmd = exec.CommandContext(ctx, "git", "clone", "--depth=1", "--branch", "master", gitURL, targetDir)
+			cmd = exec.CommandContext(ctx, "git", ip.cloneArgs(gitURL, "master", targetDir)...)
 			if err := cmd.Run(); err != nil {
 				return "", fmt.Errorf("failed to clone repository: %w", err)
 			}
@@ -324,6 +762,22 @@ func (ip *InlineProcessor) cloneGitRepository(ctx context.Context, gitURL, ref,
 		}
 	}
 
+	// Restrict the working tree to the configured paths
+	if len(ip.uploadConfig.SparsePaths) > 0 {
+		args := append([]string{"-C", targetDir, "sparse-checkout", "set"}, ip.uploadConfig.SparsePaths...)
+		cmd = exec.CommandContext(ctx, "git", args...)
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("failed to set sparse checkout paths: %w", err)
+		}
+	}
+
+	if pinned {
+		cmd = exec.CommandContext(ctx, "git", "-C", targetDir, "checkout", "--detach", ref)
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("failed to check out commit %s: %w", ref, err)
+		}
+	}
+
 	// Get commit SHA
 	cmd = exec.CommandContext(ctx, "git", "-C", targetDir, "rev-parse", "HEAD")
 	output, err := cmd.Output()
@@ -334,8 +788,198 @@ func (ip *InlineProcessor) cloneGitRepository(ctx context.Context, gitURL, ref,
 	return strings.TrimSpace(string(output)), nil
 }
 
-func (ip *InlineProcessor) extractUploadedFile(ctx context.Context, filename, targetDir string) (string, error) {
+// cloneArgs builds the git clone arguments. With sparse paths configured the clone
+// skips blobs and starts with only top-level files until sparse-checkout is set.
+func (ip *InlineProcessor) cloneArgs(gitURL, branch, targetDir string) []string {
+	args := []string{"clone", "--depth=1", "--branch", branch}
+	if len(ip.uploadConfig.SparsePaths) > 0 {
+		args = append(args, "--filter=blob:none", "--sparse")
+	}
+	return append(args, "--", gitURL, targetDir)
+}
+
+// pinnedCloneArgs builds the git clone arguments for a commit SHA ref. The whole
+// history is fetched without a checkout, as the commit may be on any branch.
+func (ip *InlineProcessor) pinnedCloneArgs(gitURL, targetDir string) []string {
+	args := []string{"clone", "--no-checkout"}
+	if len(ip.uploadConfig.SparsePaths) > 0 {
+		args = append(args, "--filter=blob:none", "--sparse")
+	}
+	return append(args, "--", gitURL, targetDir)
+}
+
+// gitURLPrefixes are the URL forms accepted for git sources. Local paths and
+// other transports (file://, ext::) are refused so a client can't read the
+// server's filesystem or run commands through git.
+var gitURLPrefixes = []string{"https://", "ssh://", "git@"}
+
+// ValidateGitSource rejects git URLs and refs that git could misread as options
+// or that use a transport other than https or ssh
+func ValidateGitSource(gitURL, ref string) error {
+	if strings.HasPrefix(gitURL, "-") {
+		return fmt.Errorf("invalid git URL: %q", gitURL)
+	}
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid git ref: %q", ref)
+	}
+	for _, prefix := range gitURLPrefixes {
+		if strings.HasPrefix(gitURL, prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported git URL %q: only https, ssh and git@ URLs are accepted", gitURL)
+}
+
+// commitSHAPattern matches a full git commit SHA
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// ResolveGitCommit returns the commit ref points at in the remote repository without
+// cloning it. A full commit SHA is returned as is, and "main" falls back to "master"
+// as it does when cloning.
+func ResolveGitCommit(ctx context.Context, gitURL, ref string) (string, error) {
+	if ref == "" {
+		ref = "main"
+	}
+	if err := ValidateGitSource(gitURL, ref); err != nil {
+		return "", err
+	}
+	if commitSHAPattern.MatchString(ref) {
+		return ref, nil
+	}
+
+	output, err := exec.CommandContext(ctx, "git", "ls-remote", "--", gitURL, ref, ref+"^{}").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to list remote refs: %w", err)
+	}
+
+	// Peeled tags point at the tagged commit rather than the tag object
+	refs := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+		}
+	}
+	for _, name := range []string{"refs/heads/" + ref, "refs/tags/" + ref + "^{}", "refs/tags/" + ref} {
+		if sha, ok := refs[name]; ok {
+			return sha, nil
+		}
+	}
+
+	if ref == "main" {
+		return ResolveGitCommit(ctx, gitURL, "master")
+	}
+	return "", fmt.Errorf("ref %q not found in %s", ref, gitURL)
+}
+
+// checkRepositorySize rejects (or warns about) repositories whose estimated size
+// exceeds MaxRepoSizeMB. Repositories whose size can't be estimated are allowed.
+func (ip *InlineProcessor) checkRepositorySize(ctx context.Context, gitURL string) error {
+	if ip.uploadConfig.MaxRepoSizeMB <= 0 {
+		return nil
+	}
+
+	sizeMB, err := estimateRepositorySizeMB(ctx, gitURL)
+	if err != nil {
+		ip.loggerFrom(ctx).Warn("cloneGitRepository: Could not estimate repository size", "git_url", gitURL, "error", err)
+		return nil
+	}
+
+	if sizeMB <= ip.uploadConfig.MaxRepoSizeMB {
+		return nil
+	}
+
+	if ip.uploadConfig.RejectOversizedRepos {
+		return fmt.Errorf("repository is too large: ~%dMB exceeds limit of %dMB", sizeMB, ip.uploadConfig.MaxRepoSizeMB)
+	}
+
+	ip.loggerFrom(ctx).Warn("cloneGitRepository: Repository is above the size limit; cloning anyway",
+		"git_url", gitURL, "size_mb", sizeMB, "limit_mb", ip.uploadConfig.MaxRepoSizeMB)
+	return nil
+}
+
+// githubAPIURL is the base URL used for repository size estimates
+var githubAPIURL = "https://api.github.com"
+
+// estimateRepositorySizeMB asks the hosting provider for the repository size.
+// Only GitHub is supported; other hosts return an error.
+func estimateRepositorySizeMB(ctx context.Context, gitURL string) (int64, error) {
+	path := strings.TrimSuffix(gitURL, ".git")
+	path = strings.TrimPrefix(path, "https://")
+	path = strings.TrimPrefix(path, "http://")
+	path = strings.TrimPrefix(path, "git@")
+	path = strings.Replace(path, "github.com:", "github.com/", 1)
+	if !strings.HasPrefix(path, "github.com/") {
+		return 0, fmt.Errorf("size estimate not supported for %s", gitURL)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, "github.com/"), "/")
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("invalid GitHub repository URL: %s", gitURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/repos/%s/%s", githubAPIURL, parts[0], parts[1]), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+	}
+
+	var repo struct {
+		Size int64 `json:"size"` // Kilobytes
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return 0, fmt.Errorf("failed to decode GitHub response: %w", err)
+	}
+
+	return repo.Size / 1024, nil
+}
+
+// uploadedArchivePath returns where an uploaded archive is stored, rejecting names
+// that would resolve outside the upload directory
+func (ip *InlineProcessor) uploadedArchivePath(filename string) (string, error) {
 	filePath := filepath.Join(ip.tempDir, filename)
+	if rel, err := filepath.Rel(ip.tempDir, filePath); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid upload filename: %s", filename)
+	}
+	return filePath, nil
+}
+
+// removeUploadedArchive deletes the temporary archive of an uploaded repository and
+// the upload directory holding it
+func (ip *InlineProcessor) removeUploadedArchive(ctx context.Context, source *repocontextv1.RepositorySource) {
+	filename := source.GetUploadedFilename()
+	if filename == "" {
+		return
+	}
+	filePath, err := ip.uploadedArchivePath(filename)
+	if err != nil {
+		return
+	}
+	// Uploads are stored in a directory of their own, which goes with the archive
+	if dir := filepath.Dir(filePath); dir != filepath.Clean(ip.tempDir) {
+		filePath = dir
+	}
+	if err := os.RemoveAll(filePath); err != nil {
+		ip.loggerFrom(ctx).Warn("removeUploadedArchive: Failed to remove uploaded archive", "path", filePath, "error", err)
+	}
+}
+
+func (ip *InlineProcessor) extractUploadedFile(ctx context.Context, filename, targetDir string) (string, error) {
+	filePath, err := ip.uploadedArchivePath(filename)
+	if err != nil {
+		return "", err
+	}
 
 	switch {
 	case strings.HasSuffix(filename, ".zip"):
@@ -349,6 +993,54 @@ func (ip *InlineProcessor) extractUploadedFile(ctx context.Context, filename, ta
 	}
 }
 
+// extractionBudget enforces the upload limits while an archive is expanded. Sizes
+// are counted from the bytes actually written, since archive headers can lie.
+type extractionBudget struct {
+	maxEntries   int
+	maxTotalSize int64
+	maxFileSize  int64
+	entries      int
+	totalSize    int64
+}
+
+func (ip *InlineProcessor) newExtractionBudget() *extractionBudget {
+	return &extractionBudget{
+		maxEntries:   ip.uploadConfig.MaxFiles,
+		maxTotalSize: ip.uploadConfig.MaxExtractedSize,
+		maxFileSize:  ip.uploadConfig.MaxExtractedFileSize,
+	}
+}
+
+func (b *extractionBudget) addEntry() error {
+	b.entries++
+	if b.entries > b.maxEntries {
+		return fmt.Errorf("%w: more than %d entries", ErrArchiveTooLarge, b.maxEntries)
+	}
+	return nil
+}
+
+// copy writes src to dst, failing as soon as the file or archive limit is passed
+func (b *extractionBudget) copy(dst io.Writer, src io.Reader, name string) error {
+	limit := b.maxFileSize
+	if remaining := b.maxTotalSize - b.totalSize; remaining < limit {
+		limit = remaining
+	}
+
+	// Reading one byte past the limit tells a file that's exactly at it from a larger one
+	n, err := io.Copy(dst, io.LimitReader(src, limit+1))
+	b.totalSize += n
+	if err != nil {
+		return err
+	}
+	if n > b.maxFileSize {
+		return fmt.Errorf("%w: %s is larger than %d bytes", ErrArchiveTooLarge, name, b.maxFileSize)
+	}
+	if b.totalSize > b.maxTotalSize {
+		return fmt.Errorf("%w: more than %d bytes uncompressed", ErrArchiveTooLarge, b.maxTotalSize)
+	}
+	return nil
+}
+
 func (ip *InlineProcessor) extractZip(filePath, targetDir string) (string, error) {
 	reader, err := zip.OpenReader(filePath)
 	if err != nil {
@@ -356,7 +1048,11 @@ func (ip *InlineProcessor) extractZip(filePath, targetDir string) (string, error
 	}
 	defer reader.Close()
 
+	budget := ip.newExtractionBudget()
 	for _, file := range reader.File {
+		if err := budget.addEntry(); err != nil {
+			return "", err
+		}
 		path := filepath.Join(targetDir, file.Name)
 
 		if file.FileInfo().IsDir() {
@@ -380,7 +1076,7 @@ func (ip *InlineProcessor) extractZip(filePath, targetDir string) (string, error
 			return "", err
 		}
 
-		_, err = io.Copy(targetFile, fileReader)
+		err = budget.copy(targetFile, fileReader, file.Name)
 		fileReader.Close()
 		targetFile.Close()
 
@@ -419,6 +1115,7 @@ func (ip *InlineProcessor) extractTar(filePath, targetDir string) (string, error
 }
 
 func (ip *InlineProcessor) extractTarReader(tarReader *tar.Reader, targetDir string) (string, error) {
+	budget := ip.newExtractionBudget()
 	for {
 		header, err := tarReader.Next()
 		if err == io.EOF {
@@ -427,6 +1124,9 @@ func (ip *InlineProcessor) extractTarReader(tarReader *tar.Reader, targetDir str
 		if err != nil {
 			return "", err
 		}
+		if err := budget.addEntry(); err != nil {
+			return "", err
+		}
 
 		path := filepath.Join(targetDir, header.Name)
 
@@ -445,7 +1145,7 @@ func (ip *InlineProcessor) extractTarReader(tarReader *tar.Reader, targetDir str
 				return "", err
 			}
 
-			_, err = io.Copy(file, tarReader)
+			err = budget.copy(file, tarReader, header.Name)
 			file.Close()
 
 			if err != nil {
@@ -487,7 +1187,8 @@ func (ip *InlineProcessor) scanDirectory(ctx context.Context, dir string) ([]*Fi
 	var files []*FileInfo
 	stats := &repocontextv1.RepositoryStats{}
 
-	log.Printf("scanDirectory: Starting scan of directory %s", dir)
+	logger := ip.loggerFrom(ctx)
+	logger.Debug("scanDirectory: Starting scan", "dir", dir)
 	languageStats := make(map[string]*repocontextv1.LanguageStats)
 
 	excludePatterns := []*regexp.Regexp{
@@ -503,7 +1204,10 @@ func (ip *InlineProcessor) scanDirectory(ctx context.Context, dir string) ([]*Fi
 
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			log.Printf("scanDirectory: Walk error for path %s: %v", path, err)
+			logger.Warn("scanDirectory: Walk error", "path", path, "error", err)
+			return err
+		}
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
@@ -512,31 +1216,32 @@ func (ip *InlineProcessor) scanDirectory(ctx context.Context, dir string) ([]*Fi
 		}
 
 		relPath, _ := filepath.Rel(dir, path)
-		log.Printf("scanDirectory: Found file %s", relPath)
+		logger.Debug("scanDirectory: Found file", "path", relPath)
 
 		// Check exclude patterns
 		for _, pattern := range excludePatterns {
 			if pattern.MatchString(relPath) {
-				log.Printf("scanDirectory: Excluding %s (matches pattern %s)", relPath, pattern.String())
+				logger.Debug("scanDirectory: Excluding file", "path", relPath, "pattern", pattern.String())
 				return nil
 			}
 		}
 
 		// Check if file is text
-		isText, isBinary := ip.detectFileType(path)
-		log.Printf("scanDirectory: File %s - isText: %v, isBinary: %v", relPath, isText, isBinary)
+		isText, isBinary := ip.detectFileType(logger, path)
 		if isBinary {
-			log.Printf("scanDirectory: Skipping binary file %s", relPath)
+			logger.Debug("scanDirectory: Skipping binary file", "path", relPath)
 			return nil
 		}
 
-		language := detectLanguage(relPath)
-		lineCount := 0
+		language := DetectLanguage(relPath)
+		if language == "unknown" && isText && filepath.Ext(relPath) == "" {
+			language = detectShebangLanguage(path)
+		}
 
-		if isText {
-			if count, err := ip.countLines(path); err == nil {
-				lineCount = count
-			}
+		// Lines are counted while the file is read for its hash
+		fileHash, lineCount, err := hashFile(path, isText)
+		if err != nil {
+			logger.Warn("scanDirectory: Failed to hash file", "path", relPath, "error", err)
 		}
 
 		fileInfo := &FileInfo{
@@ -547,10 +1252,12 @@ func (ip *InlineProcessor) scanDirectory(ctx context.Context, dir string) ([]*Fi
 			IsBinary:     isBinary,
 			LineCount:    lineCount,
 			LastModified: info.ModTime(),
+			Hash:         fileHash,
+			Generated:    isText && isGeneratedFile(relPath, path),
 		}
 
 		files = append(files, fileInfo)
-		log.Printf("scanDirectory: Added file %s to files list", relPath)
+		logger.Debug("scanDirectory: Added file", "path", relPath, "language", language, "lines", lineCount)
 
 		// Update stats
 		stats.TotalFiles++
@@ -572,11 +1279,11 @@ func (ip *InlineProcessor) scanDirectory(ctx context.Context, dir string) ([]*Fi
 	})
 
 	if err != nil {
-		log.Printf("scanDirectory: Walk completed with error: %v", err)
+		logger.Error("scanDirectory: Walk failed", "error", err)
 		return nil, nil, err
 	}
 
-	log.Printf("scanDirectory: Walk completed successfully. Found %d files", len(files))
+	logger.Info("scanDirectory: Scan completed", "files", len(files))
 
 	// Convert language stats
 	for _, langStat := range languageStats {
@@ -586,7 +1293,7 @@ func (ip *InlineProcessor) scanDirectory(ctx context.Context, dir string) ([]*Fi
 	return files, stats, nil
 }
 
-func (ip *InlineProcessor) detectFileType(path string) (isText, isBinary bool) {
+func (ip *InlineProcessor) detectFileType(logger *slog.Logger, path string) (isText, isBinary bool) {
 	// First check by file extension - common text file extensions
 	ext := strings.ToLower(filepath.Ext(path))
 	textExtensions := map[string]bool{
@@ -610,7 +1317,7 @@ func (ip *InlineProcessor) detectFileType(path string) (isText, isBinary bool) {
 
 	file, err := os.Open(path)
 	if err != nil {
-		log.Printf("detectFileType: Cannot open file %s: %v, treating as binary", path, err)
+		logger.Debug("detectFileType: Cannot open file, treating as binary", "path", path, "error", err)
 		return false, true
 	}
 	defer file.Close()
@@ -618,7 +1325,7 @@ func (ip *InlineProcessor) detectFileType(path string) (isText, isBinary bool) {
 	buffer := make([]byte, 512)
 	n, err := file.Read(buffer)
 	if err != nil && err != io.EOF {
-		log.Printf("detectFileType: Cannot read file %s: %v, treating as binary", path, err)
+		logger.Debug("detectFileType: Cannot read file, treating as binary", "path", path, "error", err)
 		return false, true
 	}
 
@@ -648,24 +1355,62 @@ func (ip *InlineProcessor) detectFileType(path string) (isText, isBinary bool) {
 	return false, true
 }
 
-func (ip *InlineProcessor) countLines(path string) (int, error) {
+// hashFile returns the SHA-256 of a file's content and, if countLines is set, its
+// number of lines, reading the file once. Identical content always hashes the same,
+// so re-indexing can tell which files changed.
+func hashFile(path string, countLines bool) (string, int, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return 0, err
+		return "", 0, err
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	hash := sha256.New()
+	buf := make([]byte, 32*1024)
 	lineCount := 0
-	for scanner.Scan() {
+	var size int64
+	var last byte
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			hash.Write(buf[:n])
+			if countLines {
+				lineCount += bytes.Count(buf[:n], []byte{'\n'})
+			}
+			size += int64(n)
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", 0, err
+		}
+	}
+
+	// A last line without a trailing newline still counts
+	if countLines && size > 0 && last != '\n' {
 		lineCount++
 	}
 
-	return lineCount, scanner.Err()
+	return fmt.Sprintf("%x", hash.Sum(nil)), lineCount, nil
+}
+
+// setJobState moves job to state. The status is replaced rather than modified since
+// GetIndexStatus reads it from other goroutines.
+func (ip *InlineProcessor) setJobState(job *IngestionJob, state repocontextv1.IngestionStatus_State, errorMessage string) {
+	status := &repocontextv1.IngestionStatus{
+		State:        state,
+		UpdatedAt:    timestamppb.Now(),
+		ErrorMessage: errorMessage,
+	}
+
+	ip.jobsMutex.Lock()
+	job.Status = status
+	ip.jobsMutex.Unlock()
 }
 
 func (ip *InlineProcessor) updateJobStatus(ctx context.Context, job *IngestionJob) {
-	job.Status.UpdatedAt = timestamppb.Now()
 	job.UpdatedAt = time.Now()
 
 	cachedStatus := &cache.CachedUploadStatus{
@@ -680,18 +1425,163 @@ func (ip *InlineProcessor) updateJobStatus(ctx context.Context, job *IngestionJo
 	ip.cache.SetUploadStatus(ctx, job.TenantID, cachedStatus)
 }
 
+// progressCallback returns a callback that records progress on the job and persists
+// it for GetUploadStatus, before passing it on to the request's own callback
+func (ip *InlineProcessor) progressCallback(ctx context.Context, job *IngestionJob) func(*repocontextv1.IngestionProgress) {
+	return func(progress *repocontextv1.IngestionProgress) {
+		job.Progress = progress
+		ip.updateJobStatus(ctx, job)
+
+		if job.Request.ProgressCallback != nil {
+			job.Request.ProgressCallback(progress)
+		}
+	}
+}
+
+// heartbeat refreshes the job's status in its repository record every
+// IngestionHeartbeatInterval until ctx is done, so other replicas can tell the
+// ingestion is still alive
+func (ip *InlineProcessor) heartbeat(ctx context.Context, job *IngestionJob) {
+	ticker := time.NewTicker(IngestionHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ip.refreshRepositoryStatus(ctx, job)
+		}
+	}
+}
+
+// refreshRepositoryStatus stores the job's current status, stamped now, in its
+// repository record. A record already holding a final state is left alone.
+func (ip *InlineProcessor) refreshRepositoryStatus(ctx context.Context, job *IngestionJob) {
+	ip.jobsMutex.Lock()
+	status := proto.Clone(job.Status).(*repocontextv1.IngestionStatus)
+	ip.jobsMutex.Unlock()
+	status.UpdatedAt = timestamppb.Now()
+
+	_, err := ip.cache.UpdateRepositoryMetadata(ctx, job.TenantID, job.RepositoryID, func(repo *repocontextv1.Repository) {
+		switch repo.GetIngestionStatus().GetState() {
+		case repocontextv1.IngestionStatus_STATE_READY, repocontextv1.IngestionStatus_STATE_FAILED:
+			return
+		}
+		repo.IngestionStatus = status
+	})
+	if err != nil && ctx.Err() == nil {
+		ip.loggerFrom(ctx).Warn("heartbeat: Failed to refresh repository status", "error", err)
+	}
+}
+
+// GetIndexStatus returns the state of the repository's running ingestion, or the
+// final state of its last one. It fails with ErrNoIngestion if this processor hasn't
+// run an ingestion for the repository within finishedJobRetention.
 func (ip *InlineProcessor) GetIndexStatus(ctx context.Context, repoID string) (*repocontextv1.IngestionStatus, error) {
-	// Implementation depends on how you store job status
-	// This is a simplified version
-	return &repocontextv1.IngestionStatus{
-		State:     repocontextv1.IngestionStatus_STATE_READY,
-		UpdatedAt: timestamppb.Now(),
-	}, nil
+	ip.jobsMutex.Lock()
+	defer ip.jobsMutex.Unlock()
+
+	if job, ok := ip.activeJobs[repoID]; ok {
+		return proto.Clone(job.Status).(*repocontextv1.IngestionStatus), nil
+	}
+	if status, ok := ip.finishedJobs[repoID]; ok {
+		return proto.Clone(status).(*repocontextv1.IngestionStatus), nil
+	}
+	return nil, ErrNoIngestion
+}
+
+func (ip *InlineProcessor) CancelIndex(ctx context.Context, tenantID, repoID string) (bool, error) {
+	ip.jobsMutex.Lock()
+	job, ok := ip.activeJobs[repoID]
+	ip.jobsMutex.Unlock()
+
+	if !ok || job.TenantID != tenantID {
+		return false, nil
+	}
+
+	ip.loggerFrom(ctx).Info("CancelIndex: Cancelling ingestion", observability.LogKeyRepositoryID, repoID)
+	job.cancel()
+
+	select {
+	case <-job.done:
+		return true, nil
+	case <-time.After(cancelWaitTimeout):
+		return true, fmt.Errorf("ingestion for repository %s did not stop within %s", repoID, cancelWaitTimeout)
+	case <-ctx.Done():
+		return true, ctx.Err()
+	}
+}
+
+// loggerFrom returns the job logger carried by ctx, falling back to the processor's logger
+func (ip *InlineProcessor) loggerFrom(ctx context.Context) *slog.Logger {
+	return observability.LoggerFromContext(ctx, ip.logger)
+}
+
+// registerJob records job as the repository's ingestion. It fails with
+// ErrIngestionInProgress if another ingestion for the repository hasn't finished, and
+// with ErrIngestionQueueFull if every worker is busy and the queue is full.
+func (ip *InlineProcessor) registerJob(job *IngestionJob) error {
+	ip.jobsMutex.Lock()
+	defer ip.jobsMutex.Unlock()
+	if _, running := ip.activeJobs[job.RepositoryID]; running {
+		return ErrIngestionInProgress
+	}
+	if len(ip.activeJobs) >= ip.uploadConfig.MaxConcurrentIngestions+ip.uploadConfig.MaxQueuedIngestions {
+		return ErrIngestionQueueFull
+	}
+	ip.activeJobs[job.RepositoryID] = job
+	return nil
+}
+
+// acquireWorkerSlot waits until fewer than MaxConcurrentIngestions ingestions are
+// running, or ctx is cancelled. The job's status stays pending while it waits.
+func (ip *InlineProcessor) acquireWorkerSlot(ctx context.Context) error {
+	select {
+	case ip.workerSlots <- struct{}{}:
+		ip.metrics.IncIngestionJobs("running")
+		return nil
+	default:
+	}
+
+	ip.loggerFrom(ctx).Info("processRepositoryAsync: Waiting for a free ingestion worker",
+		"max_concurrent", cap(ip.workerSlots))
+	ip.metrics.IncIngestionJobs("queued")
+	defer ip.metrics.DecIngestionJobs("queued")
+
+	select {
+	case ip.workerSlots <- struct{}{}:
+		ip.metrics.IncIngestionJobs("running")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("ingestion cancelled while queued: %w", ctx.Err())
+	}
+}
+
+func (ip *InlineProcessor) releaseWorkerSlot() {
+	<-ip.workerSlots
+	ip.metrics.DecIngestionJobs("running")
+}
+
+func (ip *InlineProcessor) unregisterJob(job *IngestionJob) {
+	ip.jobsMutex.Lock()
+	defer ip.jobsMutex.Unlock()
+	// A newer job for the same repository may have replaced this one
+	if ip.activeJobs[job.RepositoryID] == job {
+		delete(ip.activeJobs, job.RepositoryID)
+		ip.finishedJobs[job.RepositoryID] = proto.Clone(job.Status).(*repocontextv1.IngestionStatus)
+	}
+
+	for repoID, status := range ip.finishedJobs {
+		if time.Since(status.GetUpdatedAt().AsTime()) > finishedJobRetention {
+			delete(ip.finishedJobs, repoID)
+		}
+	}
 }
 
 func (ip *InlineProcessor) DeleteIndex(ctx context.Context, repoID string) error {
 	// Delete from vector store
-	className := toWeaviateClassName(repoID)
+	className := CollectionName(repoID)
 	if err := ip.vectorClient.DeleteCollection(ctx, className); err != nil {
 		return fmt.Errorf("failed to delete vector collection: %w", err)
 	}
@@ -702,6 +1592,10 @@ func (ip *InlineProcessor) DeleteIndex(ctx context.Context, repoID string) error
 		return fmt.Errorf("failed to clean up work directory: %w", err)
 	}
 
+	ip.jobsMutex.Lock()
+	delete(ip.finishedJobs, repoID)
+	ip.jobsMutex.Unlock()
+
 	return nil
 }
 
@@ -740,42 +1634,161 @@ func generateRepoKey(source *repocontextv1.RepositorySource) string {
 	}
 }
 
-func detectLanguage(path string) string {
-	ext := strings.ToLower(filepath.Ext(path))
+// DetectLanguage names the language of a repository file from its extension, or
+// for files like Dockerfile and BUILD from their name. Search reports hits with it
+// too, so they carry the language stored at ingestion. It returns "unknown" for
+// anything else.
+func DetectLanguage(path string) string {
+	if lang, exists := extensionLanguages[strings.ToLower(filepath.Ext(path))]; exists {
+		return lang
+	}
+
+	if lang := detectLanguageFromFilename(filepath.Base(path)); lang != "" {
+		return lang
+	}
+
+	return "unknown"
+}
+
+// extensionLanguages maps file extensions, lowercased, to languages
+var extensionLanguages = map[string]string{
+	".go":    "go",
+	".js":    "javascript",
+	".ts":    "typescript",
+	".py":    "python",
+	".java":  "java",
+	".cpp":   "cpp",
+	".c":     "c",
+	".h":     "c",
+	".cs":    "csharp",
+	".rb":    "ruby",
+	".php":   "php",
+	".sh":    "shell",
+	".rs":    "rust",
+	".kt":    "kotlin",
+	".swift": "swift",
+	".scala": "scala",
+	".r":     "r",
+	".sql":   "sql",
+	".html":  "html",
+	".css":   "css",
+	".scss":  "scss",
+	".less":  "less",
+	".json":  "json",
+	".xml":   "xml",
+	".yaml":  "yaml",
+	".yml":   "yaml",
+	".toml":  "toml",
+	".md":    "markdown",
+	".ipynb": "jupyter",
+	".txt":   "text",
+	// BUILD.bazel, WORKSPACE.bazel, MODULE.bazel and Starlark extensions
+	".bazel": "starlark",
+	".bzl":   "starlark",
+}
+
+// filenameLanguages maps files recognized by name rather than extension, matched
+// case-insensitively
+var filenameLanguages = map[string]string{
+	"dockerfile":    "dockerfile",
+	"containerfile": "dockerfile",
+	"makefile":      "makefile",
+	"gnumakefile":   "makefile",
+	"jenkinsfile":   "groovy",
+	"rakefile":      "ruby",
+	"gemfile":       "ruby",
+	"vagrantfile":   "ruby",
+	"podfile":       "ruby",
+	"brewfile":      "ruby",
+	"justfile":      "makefile",
+}
+
+// exactFilenameLanguages maps file names that are only recognized as written; a
+// lowercase "build" is as likely a script or output file as a Bazel package
+var exactFilenameLanguages = map[string]string{
+	"BUILD":     "starlark",
+	"WORKSPACE": "starlark",
+}
+
+// detectLanguageFromFilename recognizes files like Dockerfile and Makefile, including
+// variants such as Dockerfile.prod. It returns "" for other names.
+func detectLanguageFromFilename(name string) string {
+	if lang, exists := exactFilenameLanguages[name]; exists {
+		return lang
+	}
+	name = strings.ToLower(name)
+	if lang, exists := filenameLanguages[name]; exists {
+		return lang
+	}
+	if strings.HasPrefix(name, "dockerfile.") {
+		return "dockerfile"
+	}
+	return ""
+}
+
+// shebangLanguages maps script interpreters to languages
+var shebangLanguages = map[string]string{
+	"python":  "python",
+	"python2": "python",
+	"python3": "python",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"deno":    "typescript",
+	"ts-node": "typescript",
+	"sh":      "shell",
+	"bash":    "shell",
+	"zsh":     "shell",
+	"ksh":     "shell",
+	"dash":    "shell",
+	"ruby":    "ruby",
+	"perl":    "perl",
+	"php":     "php",
+	"rscript": "r",
+	"lua":     "lua",
+}
+
+// maxShebangLength bounds how much of a file is read looking for an interpreter line
+const maxShebangLength = 256
+
+// detectShebangLanguage reads the interpreter from a script's "#!" line, such as
+// "#!/usr/bin/env python3", returning "unknown" if there is none it recognizes
+func detectShebangLanguage(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return "unknown"
+	}
+	defer file.Close()
 
-	languageMap := map[string]string{
-		".go":   "go",
-		".js":   "javascript",
-		".ts":   "typescript",
-		".py":   "python",
-		".java": "java",
-		".cpp":  "cpp",
-		".c":    "c",
-		".h":    "c",
-		".cs":   "csharp",
-		".rb":   "ruby",
-		".php":  "php",
-		".sh":   "shell",
-		".rs":   "rust",
-		".kt":   "kotlin",
-		".swift": "swift",
-		".scala": "scala",
-		".r":    "r",
-		".sql":  "sql",
-		".html": "html",
-		".css":  "css",
-		".scss": "scss",
-		".less": "less",
-		".json": "json",
-		".xml":  "xml",
-		".yaml": "yaml",
-		".yml":  "yaml",
-		".toml": "toml",
-		".md":   "markdown",
-		".txt":  "text",
-	}
-
-	if lang, exists := languageMap[ext]; exists {
+	buf := make([]byte, maxShebangLength)
+	n, _ := io.ReadFull(file, buf)
+	line, _, _ := strings.Cut(string(buf[:n]), "\n")
+	if !strings.HasPrefix(line, "#!") {
+		return "unknown"
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return "unknown"
+	}
+
+	// "#!/usr/bin/env [-S] python3" names the interpreter after env and its flags
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = filepath.Base(field)
+				break
+			}
+		}
+	}
+
+	// Versioned interpreters such as python3.11 or ruby2.7
+	interpreter = strings.ToLower(interpreter)
+	if lang, exists := shebangLanguages[interpreter]; exists {
+		return lang
+	}
+	if lang, exists := shebangLanguages[strings.TrimRight(interpreter, "0123456789.")]; exists {
 		return lang
 	}
 
@@ -0,0 +1,986 @@
+package ingest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/config"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestValidateGitSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		ref     string
+		wantErr bool
+	}{
+		{"https", "https://github.com/user/repo.git", "main", false},
+		{"ssh", "ssh://git@github.com/user/repo.git", "v1.0", false},
+		{"scp-like", "git@github.com:user/repo.git", "main", false},
+		{"option as url", "--upload-pack=touch /tmp/pwned", "main", true},
+		{"option as ref", "https://github.com/user/repo.git", "--upload-pack=id", true},
+		{"local path", "/etc", "main", true},
+		{"file transport", "file:///etc", "main", true},
+		{"ext transport", "ext::sh -c id", "main", true},
+		{"plain http", "http://github.com/user/repo.git", "main", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateGitSource(tt.url, tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateGitSource(%q, %q) error = %v, wantErr %v", tt.url, tt.ref, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveGitCommitRejectsOptionURL(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "pwned")
+
+	_, err := ResolveGitCommit(context.Background(), "--upload-pack=touch "+marker, "main")
+	if err == nil {
+		t.Fatal("expected an error for an option-like git URL")
+	}
+	if _, statErr := os.Stat(marker); statErr == nil {
+		t.Fatal("git ran the injected upload-pack command")
+	}
+}
+
+func TestCloneArgsSeparatesURL(t *testing.T) {
+	ip := &InlineProcessor{uploadConfig: config.UploadConfig{}}
+
+	args := ip.cloneArgs("https://github.com/user/repo.git", "main", "/tmp/target")
+
+	if len(args) < 3 {
+		t.Fatalf("unexpected clone args: %v", args)
+	}
+	if got := args[len(args)-3]; got != "--" {
+		t.Errorf("expected -- before the URL, got %v", args)
+	}
+}
+
+func TestCancelIndexIgnoresOtherTenants(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	job := &IngestionJob{RepositoryID: "repo-1", TenantID: "tenant-a", cancel: cancel, done: make(chan struct{})}
+	ip := &InlineProcessor{activeJobs: map[string]*IngestionJob{"repo-1": job}}
+
+	cancelled, err := ip.CancelIndex(context.Background(), "tenant-b", "repo-1")
+	if err != nil || cancelled {
+		t.Fatalf("CancelIndex from another tenant = (%v, %v), want (false, nil)", cancelled, err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("another tenant's CancelIndex cancelled the job")
+	}
+
+	ip.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	go func() {
+		<-ctx.Done()
+		close(job.done)
+	}()
+	cancelled, err = ip.CancelIndex(context.Background(), "tenant-a", "repo-1")
+	if err != nil || !cancelled {
+		t.Fatalf("CancelIndex from the owning tenant = (%v, %v), want (true, nil)", cancelled, err)
+	}
+}
+
+func TestPinnedCloneArgsHaveNoBranch(t *testing.T) {
+	ip := &InlineProcessor{uploadConfig: config.UploadConfig{}}
+
+	args := ip.pinnedCloneArgs("https://github.com/user/repo.git", "/tmp/target")
+
+	for _, arg := range args {
+		if arg == "--branch" || arg == "--depth=1" {
+			t.Fatalf("a commit SHA can't be cloned as a branch: %v", args)
+		}
+	}
+	if got := args[len(args)-3]; got != "--" {
+		t.Errorf("expected -- before the URL, got %v", args)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"cmd/main.go", "go"},
+		{"BUILD", "starlark"},
+		{"pkg/BUILD.bazel", "starlark"},
+		{"WORKSPACE", "starlark"},
+		{"MODULE.bazel", "starlark"},
+		{"tools/defs.bzl", "starlark"},
+		{"scripts/build", "unknown"},
+		{"workspace", "unknown"},
+		{"Dockerfile", "dockerfile"},
+		{"deploy/dockerfile.prod", "dockerfile"},
+		{"GNUmakefile", "makefile"},
+		{"notebooks/eda.ipynb", "jupyter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := DetectLanguage(tt.path); got != tt.want {
+				t.Errorf("DetectLanguage(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshRepositoryStatus(t *testing.T) {
+	ctx := context.Background()
+	ip := newTestProcessor(t, &memoryVectors{collections: make(map[string][]*Vector)})
+	stale := timestamppb.New(time.Now().Add(-time.Hour))
+
+	tests := []struct {
+		name      string
+		stored    repocontextv1.IngestionStatus_State
+		wantState repocontextv1.IngestionStatus_State
+		wantFresh bool
+	}{
+		{"pending record", repocontextv1.IngestionStatus_STATE_PENDING, repocontextv1.IngestionStatus_STATE_EMBEDDING, true},
+		{"finished record", repocontextv1.IngestionStatus_STATE_READY, repocontextv1.IngestionStatus_STATE_READY, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ip.cache.SetRepositoryMetadata(ctx, "tenant-a", &repocontextv1.Repository{
+				RepositoryId:    "repo-1",
+				IngestionStatus: &repocontextv1.IngestionStatus{State: tt.stored, UpdatedAt: stale},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			job := &IngestionJob{
+				RepositoryID: "repo-1",
+				TenantID:     "tenant-a",
+				Status:       &repocontextv1.IngestionStatus{State: repocontextv1.IngestionStatus_STATE_EMBEDDING, UpdatedAt: stale},
+			}
+
+			ip.refreshRepositoryStatus(ctx, job)
+
+			repo, err := ip.cache.GetRepositoryMetadata(ctx, "tenant-a", "repo-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := repo.IngestionStatus.GetState(); got != tt.wantState {
+				t.Errorf("stored state = %v, want %v", got, tt.wantState)
+			}
+			if fresh := time.Since(repo.IngestionStatus.GetUpdatedAt().AsTime()) < time.Minute; fresh != tt.wantFresh {
+				t.Errorf("status refreshed = %v, want %v", fresh, tt.wantFresh)
+			}
+		})
+	}
+}
+
+func TestUnregisterJobPrunesOldFinishedJobs(t *testing.T) {
+	old := &repocontextv1.IngestionStatus{State: repocontextv1.IngestionStatus_STATE_READY, UpdatedAt: timestamppb.New(time.Now().Add(-2 * finishedJobRetention))}
+	job := &IngestionJob{RepositoryID: "repo-new", Status: &repocontextv1.IngestionStatus{State: repocontextv1.IngestionStatus_STATE_READY, UpdatedAt: timestamppb.Now()}}
+	ip := &InlineProcessor{
+		activeJobs:   map[string]*IngestionJob{"repo-new": job},
+		finishedJobs: map[string]*repocontextv1.IngestionStatus{"repo-old": old},
+	}
+
+	ip.unregisterJob(job)
+
+	if _, err := ip.GetIndexStatus(context.Background(), "repo-old"); !errors.Is(err, ErrNoIngestion) {
+		t.Errorf("GetIndexStatus for a job finished long ago error = %v, want ErrNoIngestion", err)
+	}
+	if status, err := ip.GetIndexStatus(context.Background(), "repo-new"); err != nil || status.State != repocontextv1.IngestionStatus_STATE_READY {
+		t.Errorf("GetIndexStatus for the job just finished = %v, %v", status, err)
+	}
+}
+
+// gatedEmbeddings embeds a text as the 2-dimensional vector (length, 1), counting the
+// requests and texts it gets. With gate set, each request first waits for a value
+// from it, or for it to be closed; with err set, every request fails with it.
+type gatedEmbeddings struct {
+	gate chan struct{}
+	err  error
+
+	mu    sync.Mutex
+	calls int
+	texts int
+}
+
+func (e *gatedEmbeddings) GenerateEmbeddings(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	e.mu.Lock()
+	e.calls++
+	e.texts += len(texts)
+	e.mu.Unlock()
+
+	if e.gate != nil {
+		select {
+		case <-e.gate:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = []float32{float32(len(text)), 1}
+	}
+	return embeddings, nil
+}
+
+func (e *gatedEmbeddings) GetDefaultModel() string {
+	return "test-embedding"
+}
+
+func (e *gatedEmbeddings) Dimensions() int {
+	return 2
+}
+
+// counts returns the embedding requests and texts so far
+func (e *gatedEmbeddings) counts() (calls, texts int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls, e.texts
+}
+
+// newPipelineProcessor returns a processor that runs whole ingestions of small
+// archives, maxConcurrent at a time with maxQueued more waiting
+func newPipelineProcessor(t *testing.T, embeddings EmbeddingClient, vectors VectorClient, maxConcurrent, maxQueued int) *InlineProcessor {
+	ip := newTestProcessor(t, vectors)
+	ip.embeddingClient = embeddings
+	ip.uploadConfig = config.UploadConfig{
+		MaxFiles:                100,
+		MaxExtractedSize:        1 << 20,
+		MaxExtractedFileSize:    1 << 20,
+		MaxConcurrentIngestions: maxConcurrent,
+		MaxQueuedIngestions:     maxQueued,
+	}
+	ip.workerSlots = make(chan struct{}, maxConcurrent)
+	ip.defaults = config.DefaultsConfig{ChunkSize: 100, ChunkOverlap: 10}
+	return ip
+}
+
+// writeZipUpload stores a zip archive of files in the processor's upload directory
+// and returns its name there
+func writeZipUpload(t *testing.T, ip *InlineProcessor, name string, files map[string]string) string {
+	t.Helper()
+	f, err := os.Create(filepath.Join(ip.tempDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	archive := zip.NewWriter(f)
+	for path, content := range files {
+		w, err := archive.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// writeTarUpload stores a tar archive of files, gzipped if the name says so, in the
+// processor's upload directory and returns its name there
+func writeTarUpload(t *testing.T, ip *InlineProcessor, name string, files map[string]string) string {
+	t.Helper()
+	f, err := os.Create(filepath.Join(ip.tempDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var w io.Writer = f
+	if strings.HasSuffix(name, ".gz") {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+	archive := tar.NewWriter(w)
+	for path, content := range files {
+		if err := archive.WriteHeader(&tar.Header{Name: path, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(archive, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// startUploadIngestion starts ingesting a zip archive of files as tenant-a's repoID
+func startUploadIngestion(t *testing.T, ip *InlineProcessor, repoID string, files map[string]string, incremental bool) error {
+	t.Helper()
+	filename := writeZipUpload(t, ip, repoID+".zip", files)
+	_, err := ip.CreateRepositoryIndex(context.Background(), &CreateIndexRequest{
+		RepositoryID:   repoID,
+		TenantID:       "tenant-a",
+		Source:         &repocontextv1.RepositorySource{Source: &repocontextv1.RepositorySource_UploadedFilename{UploadedFilename: filename}},
+		IdempotencyKey: "upload-" + repoID,
+		Incremental:    incremental,
+	})
+	return err
+}
+
+// waitForJobState polls until repoID's ingestion reaches one of states
+func waitForJobState(t *testing.T, ip *InlineProcessor, repoID string, states ...repocontextv1.IngestionStatus_State) *repocontextv1.IngestionStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := ip.GetIndexStatus(context.Background(), repoID)
+		if err == nil {
+			for _, state := range states {
+				if status.State == state {
+					return status
+				}
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s never reached %v: last status %v, %v", repoID, states, status, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitForIngestion waits for repoID's ingestion to finish and returns its final
+// status
+func waitForIngestion(t *testing.T, ip *InlineProcessor, repoID string) *repocontextv1.IngestionStatus {
+	t.Helper()
+	ip.jobsMutex.Lock()
+	job, running := ip.activeJobs[repoID]
+	ip.jobsMutex.Unlock()
+	if running {
+		select {
+		case <-job.done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s's ingestion never finished", repoID)
+		}
+	}
+	status, err := ip.GetIndexStatus(context.Background(), repoID)
+	if err != nil {
+		t.Fatalf("GetIndexStatus(%s) error = %v", repoID, err)
+	}
+	return status
+}
+
+// ingestFiles runs an ingestion of files as repoID to completion and returns its
+// final status
+func ingestFiles(t *testing.T, ip *InlineProcessor, repoID string, files map[string]string, incremental bool) *repocontextv1.IngestionStatus {
+	t.Helper()
+	if err := startUploadIngestion(t, ip, repoID, files, incremental); err != nil {
+		t.Fatalf("CreateRepositoryIndex error = %v", err)
+	}
+	return waitForIngestion(t, ip, repoID)
+}
+
+func TestIngestionWorkerSlots(t *testing.T) {
+	embeddings := &gatedEmbeddings{gate: make(chan struct{})}
+	ip := newPipelineProcessor(t, embeddings, &memoryVectors{collections: make(map[string][]*Vector)}, 2, 1)
+	files := map[string]string{"main.go": "package main\n\nfunc main() {}\n"}
+
+	for _, repoID := range []string{"repo-1", "repo-2"} {
+		if err := startUploadIngestion(t, ip, repoID, files, false); err != nil {
+			t.Fatalf("CreateRepositoryIndex(%s) error = %v", repoID, err)
+		}
+		waitForJobState(t, ip, repoID, repocontextv1.IngestionStatus_STATE_EMBEDDING)
+	}
+
+	// Both workers are busy, so a third ingestion waits its turn and a fourth is
+	// turned away
+	if err := startUploadIngestion(t, ip, "repo-3", files, false); err != nil {
+		t.Fatalf("CreateRepositoryIndex(repo-3) error = %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if status, _ := ip.GetIndexStatus(context.Background(), "repo-3"); status.GetState() != repocontextv1.IngestionStatus_STATE_PENDING {
+		t.Errorf("repo-3 = %v with both workers busy, want PENDING", status.GetState())
+	}
+	if calls, _ := embeddings.counts(); calls != 2 {
+		t.Errorf("%d ingestions embedding at once, want 2", calls)
+	}
+	if err := startUploadIngestion(t, ip, "repo-4", files, false); !errors.Is(err, ErrIngestionQueueFull) {
+		t.Errorf("CreateRepositoryIndex past the queue error = %v, want ErrIngestionQueueFull", err)
+	}
+
+	close(embeddings.gate)
+	for _, repoID := range []string{"repo-1", "repo-2", "repo-3"} {
+		if status := waitForIngestion(t, ip, repoID); status.State != repocontextv1.IngestionStatus_STATE_READY {
+			t.Errorf("%s = %v %q, want READY", repoID, status.State, status.ErrorMessage)
+		}
+	}
+	if err := startUploadIngestion(t, ip, "repo-4", files, false); err != nil {
+		t.Fatalf("CreateRepositoryIndex once the queue drained error = %v", err)
+	}
+	waitForIngestion(t, ip, "repo-4")
+}
+
+func TestCancelIndexStopsRunningIngestion(t *testing.T) {
+	ctx := context.Background()
+	embeddings := &gatedEmbeddings{gate: make(chan struct{})}
+	defer close(embeddings.gate)
+	vectors := &memoryVectors{collections: make(map[string][]*Vector)}
+	ip := newPipelineProcessor(t, embeddings, vectors, 1, 0)
+
+	if err := startUploadIngestion(t, ip, "repo-1", map[string]string{"main.go": "package main\n\nfunc main() {}\n"}, false); err != nil {
+		t.Fatalf("CreateRepositoryIndex error = %v", err)
+	}
+	waitForJobState(t, ip, "repo-1", repocontextv1.IngestionStatus_STATE_EMBEDDING)
+
+	cancelled, err := ip.CancelIndex(ctx, "tenant-a", "repo-1")
+	if err != nil || !cancelled {
+		t.Fatalf("CancelIndex = %v, %v; want the running job cancelled", cancelled, err)
+	}
+
+	// CancelIndex waits for the job, so it has already stopped
+	status, err := ip.GetIndexStatus(ctx, "repo-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.State != repocontextv1.IngestionStatus_STATE_FAILED || status.ErrorMessage != "ingestion cancelled" {
+		t.Errorf("status = %v %q, want FAILED \"ingestion cancelled\"", status.State, status.ErrorMessage)
+	}
+	upload, err := ip.cache.GetUploadStatus(ctx, "tenant-a", "upload-repo-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upload.Status.GetState() != repocontextv1.IngestionStatus_STATE_FAILED || upload.ErrorMessage != "ingestion cancelled" {
+		t.Errorf("upload status = %v %q, want FAILED \"ingestion cancelled\"", upload.Status.GetState(), upload.ErrorMessage)
+	}
+	if _, ok := vectors.collection(CollectionName("repo-1")); ok {
+		t.Error("a cancelled ingestion created its collection")
+	}
+}
+
+func TestExtractRejectsArchiveBombs(t *testing.T) {
+	ip := newPipelineProcessor(t, &gatedEmbeddings{}, &memoryVectors{collections: make(map[string][]*Vector)}, 1, 0)
+	ip.uploadConfig.MaxFiles = 10
+	ip.uploadConfig.MaxExtractedSize = 1 << 20
+	ip.uploadConfig.MaxExtractedFileSize = 512 << 10
+
+	// Zeros compress a thousandfold, so the compressed archives are a few kilobytes
+	manyFiles := make(map[string]string)
+	for i := 0; i < 11; i++ {
+		manyFiles[fmt.Sprintf("file%d.txt", i)] = "x"
+	}
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"one huge file", map[string]string{"zeros.bin": strings.Repeat("\x00", 8<<20)}},
+		{"files over the total", map[string]string{"a.bin": strings.Repeat("\x00", 400<<10), "b.bin": strings.Repeat("\x00", 400<<10), "c.bin": strings.Repeat("\x00", 400<<10)}},
+		{"too many entries", manyFiles},
+	}
+	for _, format := range []string{".zip", ".tar.gz", ".tar"} {
+		for i, tt := range tests {
+			t.Run(format+" "+tt.name, func(t *testing.T) {
+				name := fmt.Sprintf("bomb%d%s", i, format)
+				if format == ".zip" {
+					writeZipUpload(t, ip, name, tt.files)
+				} else {
+					writeTarUpload(t, ip, name, tt.files)
+				}
+				if _, err := ip.extractUploadedFile(context.Background(), name, t.TempDir()); !errors.Is(err, ErrArchiveTooLarge) {
+					t.Errorf("extract error = %v, want ErrArchiveTooLarge", err)
+				}
+			})
+		}
+	}
+
+	// An archive within every limit still extracts
+	name := writeZipUpload(t, ip, "fine.zip", map[string]string{"a.bin": strings.Repeat("\x00", 400<<10), "b.bin": strings.Repeat("\x00", 400<<10)})
+	if _, err := ip.extractUploadedFile(context.Background(), name, t.TempDir()); err != nil {
+		t.Errorf("extract of an archive within the limits error = %v", err)
+	}
+}
+
+func TestIncrementalReindex(t *testing.T) {
+	ctx := context.Background()
+	vectors := &memoryVectors{collections: make(map[string][]*Vector)}
+	ip := newPipelineProcessor(t, &gatedEmbeddings{}, vectors, 1, 0)
+
+	original := map[string]string{
+		"a.go": "package a\n\nfunc A() {}\n",
+		"b.go": "package b\n\nfunc B() {}\n",
+		"c.go": "package c\n\nfunc C() {}\n",
+	}
+	if status := ingestFiles(t, ip, "repo-1", original, false); status.State != repocontextv1.IngestionStatus_STATE_READY {
+		t.Fatalf("first ingestion = %v %q", status.State, status.ErrorMessage)
+	}
+	before, _ := vectors.collection(CollectionName("repo-1"))
+
+	// a.go is unchanged, b.go modified, c.go deleted and d.go added
+	updated := map[string]string{
+		"a.go": original["a.go"],
+		"b.go": "package b\n\nfunc B() { println(\"changed\") }\n",
+		"d.go": "package d\n\nfunc D() {}\n",
+	}
+	if status := ingestFiles(t, ip, "repo-1", updated, true); status.State != repocontextv1.IngestionStatus_STATE_READY {
+		t.Fatalf("re-index = %v %q", status.State, status.ErrorMessage)
+	}
+	after, _ := vectors.collection(CollectionName("repo-1"))
+
+	byFile := func(indexed []*Vector) map[string][]*Vector {
+		files := make(map[string][]*Vector)
+		for _, vector := range indexed {
+			path := vector.Metadata["file_path"].(string)
+			files[path] = append(files[path], vector)
+		}
+		return files
+	}
+	old, current := byFile(before), byFile(after)
+	if len(current) != 3 || len(current["c.go"]) != 0 {
+		t.Fatalf("indexed files = %v, want a.go, b.go and d.go", current)
+	}
+
+	// The unchanged file's vectors are left as they were, not indexed again
+	if len(current["a.go"]) != len(old["a.go"]) {
+		t.Errorf("a.go has %d vectors, want its original %d", len(current["a.go"]), len(old["a.go"]))
+	}
+	for i := range current["a.go"] {
+		if i < len(old["a.go"]) && current["a.go"][i] != old["a.go"][i] {
+			t.Errorf("unchanged a.go was re-indexed")
+		}
+	}
+	for _, path := range []string{"b.go", "d.go"} {
+		var content strings.Builder
+		for _, vector := range current[path] {
+			content.WriteString(vector.Metadata["content"].(string))
+		}
+		if !strings.Contains(content.String(), strings.TrimSpace(updated[path][strings.Index(updated[path], "func"):])) {
+			t.Errorf("%s indexed as %q, want its current content", path, content.String())
+		}
+	}
+
+	repo, err := ip.cache.GetRepositoryMetadata(ctx, "tenant-a", "repo-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := repo.GetStats().GetTotalChunks(); int(got) != len(after) {
+		t.Errorf("stored chunk count = %d, want the %d in the index", got, len(after))
+	}
+	hashes, err := ip.cache.GetFileHashes(ctx, "tenant-a", "repo-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := hashes["c.go"]; ok || len(hashes) != 3 {
+		t.Errorf("stored file hashes = %v, want a.go, b.go and d.go", hashes)
+	}
+}
+
+func TestReindexKeepsRepositoryReadyUntilDone(t *testing.T) {
+	ctx := context.Background()
+	vectors := &memoryVectors{collections: make(map[string][]*Vector)}
+	ip := newPipelineProcessor(t, &gatedEmbeddings{}, vectors, 1, 0)
+	files := map[string]string{"a.go": "package a\n\nfunc A() {}\n"}
+	if status := ingestFiles(t, ip, "repo-1", files, false); status.State != repocontextv1.IngestionStatus_STATE_READY {
+		t.Fatalf("first ingestion = %v %q", status.State, status.ErrorMessage)
+	}
+	indexed, _ := vectors.collection(CollectionName("repo-1"))
+
+	// While the re-index runs, the repository reports it rather than the old state
+	embeddings := &gatedEmbeddings{gate: make(chan struct{})}
+	ip.embeddingClient = embeddings
+	files["a.go"] = "package a\n\nfunc A() { println() }\n"
+	if err := startUploadIngestion(t, ip, "repo-1", files, true); err != nil {
+		t.Fatal(err)
+	}
+	waitForJobState(t, ip, "repo-1", repocontextv1.IngestionStatus_STATE_EMBEDDING)
+	repo, err := ip.cache.GetRepositoryMetadata(ctx, "tenant-a", "repo-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.GetRepositoryId() != "repo-1" || repo.GetIngestionStatus().GetState() == repocontextv1.IngestionStatus_STATE_READY {
+		t.Errorf("repository during re-index = %s %v, want repo-1 in progress", repo.GetRepositoryId(), repo.GetIngestionStatus().GetState())
+	}
+	close(embeddings.gate)
+	if status := waitForIngestion(t, ip, "repo-1"); status.State != repocontextv1.IngestionStatus_STATE_READY {
+		t.Fatalf("re-index = %v %q", status.State, status.ErrorMessage)
+	}
+	if repo, _ := ip.cache.GetRepositoryMetadata(ctx, "tenant-a", "repo-1"); repo.GetIngestionStatus().GetState() != repocontextv1.IngestionStatus_STATE_READY {
+		t.Errorf("repository after re-index = %v, want READY", repo.GetIngestionStatus().GetState())
+	}
+	indexed, _ = vectors.collection(CollectionName("repo-1"))
+
+	// A failed re-index leaves the previous index, and the repository, ready
+	ip.embeddingClient = &gatedEmbeddings{err: errors.New("provider down")}
+	files["a.go"] = "package a\n\nfunc A() { println(2) }\n"
+	if status := ingestFiles(t, ip, "repo-1", files, true); status.State != repocontextv1.IngestionStatus_STATE_FAILED {
+		t.Fatalf("re-index with the provider down = %v, want FAILED", status.State)
+	}
+	repo, err = ip.cache.GetRepositoryMetadata(ctx, "tenant-a", "repo-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.GetIngestionStatus().GetState() != repocontextv1.IngestionStatus_STATE_READY || !strings.HasPrefix(repo.GetIngestionStatus().GetErrorMessage(), "reindex failed: ") {
+		t.Errorf("repository after a failed re-index = %v %q, want READY with the failure noted", repo.GetIngestionStatus().GetState(), repo.GetIngestionStatus().GetErrorMessage())
+	}
+	if remaining, _ := vectors.collection(CollectionName("repo-1")); len(remaining) != len(indexed) {
+		t.Errorf("index holds %d vectors after a failed re-index, want the previous %d", len(remaining), len(indexed))
+	}
+}
+
+// newGitRemote commits files to a new local repository on branch main and returns
+// an https URL that git is configured, through the environment, to clone it from
+func newGitRemote(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for path, content := range files {
+		full := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_COUNT", "2")
+	t.Setenv("GIT_CONFIG_KEY_0", "url.file://"+dir+".insteadOf")
+	t.Setenv("GIT_CONFIG_VALUE_0", "https://git.test/acme/widgets.git")
+	t.Setenv("GIT_CONFIG_KEY_1", "uploadpack.allowFilter")
+	t.Setenv("GIT_CONFIG_VALUE_1", "true")
+	return "https://git.test/acme/widgets.git"
+}
+
+func TestCloneGitRepositorySparsePaths(t *testing.T) {
+	gitURL := newGitRemote(t, map[string]string{
+		"README.md":           "# widgets\n",
+		"internal/api/api.go": "package api\n",
+		"docs/guide/intro.md": "# intro\n",
+		"vendor/lib/lib.go":   "package lib\n",
+	})
+	ip := &InlineProcessor{
+		logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		uploadConfig: config.UploadConfig{SparsePaths: []string{"internal"}},
+	}
+	target := filepath.Join(t.TempDir(), "clone")
+
+	sha, err := ip.cloneGitRepository(context.Background(), gitURL, "main", target)
+	if err != nil {
+		t.Fatalf("cloneGitRepository error = %v", err)
+	}
+
+	if !commitSHAPattern.MatchString(sha) {
+		t.Errorf("commit SHA = %q", sha)
+	}
+	// Top-level files are always checked out; of the directories only the sparse path is
+	for path, want := range map[string]bool{
+		"README.md":           true,
+		"internal/api/api.go": true,
+		"docs/guide/intro.md": false,
+		"vendor/lib/lib.go":   false,
+	} {
+		_, err := os.Stat(filepath.Join(target, path))
+		if got := err == nil; got != want {
+			t.Errorf("%s checked out = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestCloneGitRepositoryRejectsOversizedRepos(t *testing.T) {
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"size": 524288}`)) // Kilobytes, so 512MB
+	}))
+	defer server.Close()
+	defer func(original string) { githubAPIURL = original }(githubAPIURL)
+	githubAPIURL = server.URL
+
+	tests := []struct {
+		name    string
+		reject  bool
+		wantErr string
+	}{
+		{"rejected", true, "repository is too large: ~512MB exceeds limit of 100MB"},
+		// The clone goes ahead, and fails on the unreachable host instead
+		{"warned", false, "failed to clone repository"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requested = ""
+			ip := &InlineProcessor{
+				logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+				uploadConfig: config.UploadConfig{MaxRepoSizeMB: 100, RejectOversizedRepos: tt.reject},
+			}
+			t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+			t.Setenv("GIT_CONFIG_COUNT", "1")
+			t.Setenv("GIT_CONFIG_KEY_0", "url.file://"+filepath.Join(t.TempDir(), "missing")+".insteadOf")
+			t.Setenv("GIT_CONFIG_VALUE_0", "https://github.com/acme/huge.git")
+
+			_, err := ip.cloneGitRepository(context.Background(), "https://github.com/acme/huge.git", "v1", filepath.Join(t.TempDir(), "clone"))
+
+			if requested != "/repos/acme/huge" {
+				t.Errorf("size looked up at %q, want /repos/acme/huge", requested)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("cloneGitRepository error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// ingestGitRepository runs an ingestion of the repository at gitURL as tenant-a's
+// repoID to completion and returns its final status
+func ingestGitRepository(t *testing.T, ip *InlineProcessor, repoID, gitURL string) *repocontextv1.IngestionStatus {
+	t.Helper()
+	_, err := ip.CreateRepositoryIndex(context.Background(), &CreateIndexRequest{
+		RepositoryID:   repoID,
+		TenantID:       "tenant-a",
+		Source:         &repocontextv1.RepositorySource{Source: &repocontextv1.RepositorySource_GitUrl{GitUrl: gitURL}, Ref: "main"},
+		IdempotencyKey: "git-" + repoID,
+	})
+	if err != nil {
+		t.Fatalf("CreateRepositoryIndex error = %v", err)
+	}
+	return waitForIngestion(t, ip, repoID)
+}
+
+func TestGitIngestionStoresStats(t *testing.T) {
+	gitURL := newGitRemote(t, map[string]string{
+		"main.go":      "package main\n\nfunc main() {}\n",
+		"util/util.go": "package util\n\nfunc Help() {}\n",
+		"README.md":    "# widgets\n",
+	})
+	vectors := &memoryVectors{collections: make(map[string][]*Vector)}
+	ip := newPipelineProcessor(t, &gatedEmbeddings{}, vectors, 1, 0)
+
+	if status := ingestGitRepository(t, ip, "repo-1", gitURL); status.State != repocontextv1.IngestionStatus_STATE_READY {
+		t.Fatalf("ingestion = %v %q", status.State, status.ErrorMessage)
+	}
+
+	repo, err := ip.cache.GetRepositoryMetadata(context.Background(), "tenant-a", "repo-1")
+	if err != nil || repo == nil {
+		t.Fatalf("repository metadata = %v, %v", repo, err)
+	}
+	stats := repo.GetStats()
+	if stats.GetTotalFiles() != 3 || stats.GetTotalLines() == 0 || stats.GetSizeBytes() == 0 {
+		t.Errorf("stored stats = %v, want 3 files with their lines and size", stats)
+	}
+	indexed, _ := vectors.collection(CollectionName("repo-1"))
+	if stats.GetTotalChunks() == 0 || int(stats.GetTotalChunks()) != len(indexed) {
+		t.Errorf("stored chunk count = %d, want the %d in the index", stats.GetTotalChunks(), len(indexed))
+	}
+}
+
+func TestGitIngestionStoresCommitSHA(t *testing.T) {
+	ctx := context.Background()
+	gitURL := newGitRemote(t, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	output, err := exec.Command("git", "ls-remote", gitURL, "refs/heads/main").Output()
+	if err != nil {
+		t.Fatalf("git ls-remote: %v", err)
+	}
+	head := strings.Fields(string(output))[0]
+	ip := newPipelineProcessor(t, &gatedEmbeddings{}, &memoryVectors{collections: make(map[string][]*Vector)}, 1, 0)
+
+	if status := ingestGitRepository(t, ip, "repo-1", gitURL); status.State != repocontextv1.IngestionStatus_STATE_READY {
+		t.Fatalf("ingestion = %v %q", status.State, status.ErrorMessage)
+	}
+
+	repo, err := ip.cache.GetRepositoryMetadata(ctx, "tenant-a", "repo-1")
+	if err != nil || repo == nil {
+		t.Fatalf("repository metadata = %v, %v", repo, err)
+	}
+	if repo.GetSource().GetCommitSha() != head || repo.GetSource().GetRef() != "main" {
+		t.Errorf("stored source = %v, want ref main at %s", repo.GetSource(), head)
+	}
+	// The routing key names the commit, so the same commit maps to this repository
+	if indexID, err := ip.cache.GetRepositoryIndex(ctx, "tenant-a", gitURL+"@"+head); err != nil || indexID != "repo-1" {
+		t.Errorf("routing for %s@%s = %q, %v; want repo-1", gitURL, head, indexID, err)
+	}
+}
+
+func TestIngestionProgressIncreases(t *testing.T) {
+	ip := newPipelineProcessor(t, &gatedEmbeddings{}, &memoryVectors{collections: make(map[string][]*Vector)}, 1, 0)
+	files := make(map[string]string)
+	for i := 0; i < 8; i++ {
+		files[fmt.Sprintf("pkg%d/file.go", i)] = fmt.Sprintf("package pkg%d\n\nfunc F%d() {}\n", i, i)
+	}
+
+	var (
+		mu      sync.Mutex
+		reports []*repocontextv1.IngestionProgress
+	)
+	_, err := ip.CreateRepositoryIndex(context.Background(), &CreateIndexRequest{
+		RepositoryID:   "repo-1",
+		TenantID:       "tenant-a",
+		Source:         &repocontextv1.RepositorySource{Source: &repocontextv1.RepositorySource_UploadedFilename{UploadedFilename: writeZipUpload(t, ip, "repo-1.zip", files)}},
+		IdempotencyKey: "upload-repo-1",
+		ProgressCallback: func(progress *repocontextv1.IngestionProgress) {
+			mu.Lock()
+			reports = append(reports, proto.Clone(progress).(*repocontextv1.IngestionProgress))
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateRepositoryIndex error = %v", err)
+	}
+	if status := waitForIngestion(t, ip, "repo-1"); status.State != repocontextv1.IngestionStatus_STATE_READY {
+		t.Fatalf("ingestion = %v %q", status.State, status.ErrorMessage)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// At least the end of chunking, embedding and indexing are reported
+	if len(reports) < 3 {
+		t.Fatalf("got %d progress reports, want one per phase at least", len(reports))
+	}
+	for i := 1; i < len(reports); i++ {
+		prev, cur := reports[i-1], reports[i]
+		if cur.ProgressPercent < prev.ProgressPercent || cur.ProcessedFiles < prev.ProcessedFiles ||
+			cur.EmbeddedChunks < prev.EmbeddedChunks || cur.IndexedChunks < prev.IndexedChunks {
+			t.Errorf("progress went back from %v to %v", prev, cur)
+		}
+	}
+	last := reports[len(reports)-1]
+	if last.TotalFiles != 8 || last.ProcessedFiles != 8 || last.TotalChunks == 0 || last.IndexedChunks != last.TotalChunks || last.ProgressPercent != 100 {
+		t.Errorf("last progress = %v, want every file and chunk done", last)
+	}
+
+	// Progress is persisted for clients polling the upload status
+	status, err := ip.cache.GetUploadStatus(context.Background(), "tenant-a", "upload-repo-1")
+	if err != nil || status == nil || status.Progress.GetIndexedChunks() != last.IndexedChunks {
+		t.Errorf("persisted upload status = %v, %v; want the last progress", status, err)
+	}
+}
+
+func TestReconcileWorkDir(t *testing.T) {
+	ctx := context.Background()
+	ip := newTestProcessor(t, &memoryVectors{collections: make(map[string][]*Vector)})
+	if err := ip.cache.SetRepositoryMetadata(ctx, "tenant-a", &repocontextv1.Repository{RepositoryId: "repo-live"}); err != nil {
+		t.Fatal(err)
+	}
+	ip.activeJobs["repo-running"] = &IngestionJob{RepositoryID: "repo-running", TenantID: "tenant-a"}
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{"repo-live", "repo-stray", "repo-live" + reindexDirSuffix, "repo-running", "repo-fresh"} {
+		dir := filepath.Join(ip.workDir, name)
+		if err := os.MkdirAll(filepath.Join(dir, "src"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if name != "repo-fresh" {
+			if err := os.Chtimes(dir, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	removed, err := ip.ReconcileWorkDir(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("ReconcileWorkDir error = %v", err)
+	}
+
+	if removed != 2 {
+		t.Errorf("removed %d directories, want 2", removed)
+	}
+	for name, want := range map[string]bool{
+		"repo-live":                    true,
+		"repo-stray":                   false,
+		"repo-live" + reindexDirSuffix: false, // A re-index that never finished
+		"repo-running":                 true,
+		"repo-fresh":                   true, // Within the grace period
+	} {
+		if _, err := os.Stat(filepath.Join(ip.workDir, name)); (err == nil) != want {
+			t.Errorf("%s kept = %v, want %v", name, err == nil, want)
+		}
+	}
+}
+
+func TestScanDirectoryHashesFiles(t *testing.T) {
+	ip := newTestProcessor(t, nil)
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go":          "package a\n\nfunc A() {}\n",
+		"copy/a.go":     "package a\n\nfunc A() {}\n",
+		"b.go":          "package b\n\nfunc B() {}\n",
+		"no_newline.go": "package c",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scan := func() ([]*FileInfo, map[string]*FileInfo) {
+		t.Helper()
+		scanned, _, err := ip.scanDirectory(context.Background(), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byPath := make(map[string]*FileInfo)
+		for _, file := range scanned {
+			byPath[file.Path] = file
+		}
+		return scanned, byPath
+	}
+
+	scanned, before := scan()
+	if before["a.go"].Hash == "" || before["a.go"].Hash != before["copy/a.go"].Hash {
+		t.Errorf("identical files hashed as %q and %q, want the same hash", before["a.go"].Hash, before["copy/a.go"].Hash)
+	}
+	if before["a.go"].Hash == before["b.go"].Hash {
+		t.Errorf("different files both hashed as %q", before["a.go"].Hash)
+	}
+	for path, want := range map[string]int{"a.go": 3, "no_newline.go": 1} {
+		if got := before[path].LineCount; got != want {
+			t.Errorf("%s line count = %d, want %d", path, got, want)
+		}
+	}
+
+	// Only the modified file's hash changes
+	if err := os.WriteFile(filepath.Join(dir, "b.go"), []byte("package b\n\nfunc B() { println() }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rescanned, after := scan()
+	if after["b.go"].Hash == before["b.go"].Hash {
+		t.Error("modified b.go kept its hash")
+	}
+	if after["a.go"].Hash != before["a.go"].Hash {
+		t.Error("unchanged a.go got a new hash")
+	}
+	changed, stale := diffFileHashes(fileHashes(scanned), rescanned)
+	if len(changed) != 1 || changed[0].Path != "b.go" || fmt.Sprint(stale) != "[b.go]" {
+		t.Errorf("diff = %d changed, stale %v; want only b.go", len(changed), stale)
+	}
+}
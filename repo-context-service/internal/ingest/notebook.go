@@ -0,0 +1,126 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Jupyter notebooks are JSON holding cell sources alongside their outputs, which
+// are mostly base64 images and logs. They're chunked as their cell sources joined
+// with cell markers instead, so chunk line numbers refer to that text rather than
+// to lines of the .ipynb file; search doesn't widen notebook chunks with file lines,
+// and GetFile only returns notebooks whole.
+
+// notebook covers the nbformat 4 layout and the worksheets of nbformat 3
+type notebook struct {
+	Cells      []notebookCell `json:"cells"`
+	Worksheets []struct {
+		Cells []notebookCell `json:"cells"`
+	} `json:"worksheets"`
+	Metadata struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+		Language string `json:"language"`
+	} `json:"metadata"`
+}
+
+type notebookCell struct {
+	CellType string          `json:"cell_type"`
+	Source   json.RawMessage `json:"source"`
+	// nbformat 3 keeps code cell sources under "input"
+	Input json.RawMessage `json:"input"`
+}
+
+func isNotebook(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ipynb")
+}
+
+// notebookSource returns the code cells of a notebook, and its markdown cells if
+// includeMarkdown is set, each preceded by a "# %% [type]" marker line. Outputs and
+// raw cells are dropped. The language is the notebook kernel's, defaulting to
+// python.
+func notebookSource(data []byte, includeMarkdown bool) (string, string, error) {
+	var nb notebook
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return "", "", fmt.Errorf("failed to parse notebook: %w", err)
+	}
+
+	cells := nb.Cells
+	for _, worksheet := range nb.Worksheets {
+		cells = append(cells, worksheet.Cells...)
+	}
+
+	var sections []string
+	for _, cell := range cells {
+		if cell.CellType != "code" && !(includeMarkdown && cell.CellType == "markdown") {
+			continue
+		}
+
+		raw := cell.Source
+		if len(raw) == 0 {
+			raw = cell.Input
+		}
+		source, err := cellSource(raw)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to parse %s cell: %w", cell.CellType, err)
+		}
+
+		source = strings.TrimRight(source, "\n")
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+		sections = append(sections, fmt.Sprintf("# %%%% [%s]\n%s", cell.CellType, source))
+	}
+
+	return strings.Join(sections, "\n\n"), notebookLanguage(&nb), nil
+}
+
+// cellSource decodes a cell source, which nbformat allows as a string or a list of
+// lines that keep their newlines
+func cellSource(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+
+	var source string
+	if err := json.Unmarshal(raw, &source); err == nil {
+		return source, nil
+	}
+
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, ""), nil
+}
+
+func notebookLanguage(nb *notebook) string {
+	for _, name := range []string{
+		nb.Metadata.Kernelspec.Language,
+		nb.Metadata.LanguageInfo.Name,
+		nb.Metadata.Language,
+	} {
+		if name == "" {
+			continue
+		}
+		// Kernels name languages the way detectLanguage does, apart from a few
+		// spellings
+		name = strings.ToLower(name)
+		switch name {
+		case "c++":
+			return "cpp"
+		case "c#":
+			return "csharp"
+		case "bash", "sh":
+			return "shell"
+		}
+		return name
+	}
+	return "python"
+}
@@ -0,0 +1,71 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+func TestIngestNotebookChunksCellSources(t *testing.T) {
+	notebook := `{
+  "cells": [
+    {"cell_type": "markdown", "metadata": {}, "source": ["# Loading data\n", "Reads the sales table."]},
+    {"cell_type": "code", "execution_count": 1, "metadata": {}, "source": ["import pandas as pd\n", "df = pd.read_csv('sales.csv')"],
+     "outputs": [{"output_type": "display_data", "data": {"image/png": "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg=="}}]},
+    {"cell_type": "raw", "metadata": {}, "source": "raw cell text"},
+    {"cell_type": "code", "execution_count": 2, "metadata": {}, "source": "df.describe()",
+     "outputs": [{"output_type": "stream", "name": "stdout", "text": ["count    42\n"]}]}
+  ],
+  "metadata": {"kernelspec": {"language": "python"}},
+  "nbformat": 4,
+  "nbformat_minor": 5
+}`
+
+	tests := []struct {
+		name         string
+		markdown     bool
+		wantContains []string
+		wantMissing  []string
+	}{
+		{"with markdown", true,
+			[]string{"# %% [markdown]", "Reads the sales table.", "# %% [code]", "import pandas as pd", "df.describe()"},
+			[]string{"iVBORw0KGgo", "count    42", "raw cell text", "execution_count"}},
+		{"code only", false,
+			[]string{"import pandas as pd", "df.describe()"},
+			[]string{"Reads the sales table.", "iVBORw0KGgo", "count    42", "raw cell text"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vectors := &memoryVectors{collections: make(map[string][]*Vector)}
+			ip := newPipelineProcessor(t, &gatedEmbeddings{}, vectors, 1, 0)
+			ip.uploadConfig.NotebookMarkdown = tt.markdown
+
+			if status := ingestFiles(t, ip, "repo-1", map[string]string{"analysis.ipynb": notebook}, false); status.State != repocontextv1.IngestionStatus_STATE_READY {
+				t.Fatalf("ingestion = %v %q", status.State, status.ErrorMessage)
+			}
+
+			indexed, _ := vectors.collection(CollectionName("repo-1"))
+			if len(indexed) == 0 {
+				t.Fatal("notebook produced no chunks")
+			}
+			var content strings.Builder
+			for _, vector := range indexed {
+				if vector.Metadata["language"] != "python" {
+					t.Errorf("chunk language = %v, want the kernel's python", vector.Metadata["language"])
+				}
+				content.WriteString(vector.Metadata["content"].(string))
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(content.String(), want) {
+					t.Errorf("chunks are missing %q:\n%s", want, content.String())
+				}
+			}
+			for _, unwanted := range tt.wantMissing {
+				if strings.Contains(content.String(), unwanted) {
+					t.Errorf("chunks include %q:\n%s", unwanted, content.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// EmbeddingSpace is the model and vector size of the embeddings this processor
+// indexes. An exported index can only be imported where they are the same.
+func (ip *InlineProcessor) EmbeddingSpace() (string, int) {
+	return ip.embeddingClient.GetDefaultModel(), ip.embeddingClient.Dimensions()
+}
+
+// ExportIndex calls fn with every chunk of repoID's vector index and its embedding,
+// a batch at a time. A repository without a collection fails with the vector
+// client's not-found error.
+func (ip *InlineProcessor) ExportIndex(ctx context.Context, repoID string, fn func([]*EmbeddedChunk) error) error {
+	ctx, span := ip.tracer.StartIngestion(ctx, repoID, "export_index")
+	defer span.End()
+
+	model, _ := ip.EmbeddingSpace()
+	return ip.vectorClient.ScanVectors(ctx, CollectionName(repoID), func(vectors []*Vector) error {
+		chunks := make([]*EmbeddedChunk, len(vectors))
+		for i, vector := range vectors {
+			chunks[i] = vectorChunk(repoID, model, vector)
+		}
+		return fn(chunks)
+	})
+}
+
+// ImportIndex replaces repoID's vector index with the chunks returned by next,
+// which returns io.EOF once there are no more. The repository's working tree is
+// removed, as it belonged to the index being replaced. The import holds the
+// repository's ingestion slot, so it fails with ErrIngestionInProgress while an
+// ingestion runs and a reindex started meanwhile fails the same way; the
+// repository is reported as indexing until it ends. It returns how many chunks
+// were imported.
+func (ip *InlineProcessor) ImportIndex(ctx context.Context, tenantID, repoID string, next func() ([]*EmbeddedChunk, error)) (imported int, err error) {
+	ctx, span := ip.tracer.StartIngestion(ctx, repoID, "import_index")
+	defer span.End()
+
+	ctx, cancel := context.WithCancel(ctx)
+	job := &IngestionJob{
+		ID:           "import-" + repoID,
+		RepositoryID: repoID,
+		TenantID:     tenantID,
+		Status: &repocontextv1.IngestionStatus{
+			State:     repocontextv1.IngestionStatus_STATE_INDEXING,
+			UpdatedAt: timestamppb.Now(),
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	if err := ip.registerJob(job); err != nil {
+		cancel()
+		return 0, err
+	}
+	defer func() {
+		state := repocontextv1.IngestionStatus_STATE_READY
+		message := ""
+		if err != nil {
+			state, message = repocontextv1.IngestionStatus_STATE_FAILED, err.Error()
+		}
+		ip.setJobState(job, state, message)
+		ip.unregisterJob(job)
+		cancel()
+		close(job.done)
+	}()
+
+	if _, err := ip.cache.UpdateRepositoryMetadata(ctx, tenantID, repoID, func(repo *repocontextv1.Repository) {
+		repo.IngestionStatus = proto.Clone(job.Status).(*repocontextv1.IngestionStatus)
+		repo.UpdatedAt = timestamppb.Now()
+	}); err != nil {
+		ip.loggerFrom(ctx).Warn("ImportIndex: Failed to update repository status", "error", err)
+	}
+
+	if err := ip.DeleteIndex(ctx, repoID); err != nil {
+		return 0, err
+	}
+
+	_, dimensions := ip.EmbeddingSpace()
+	className := CollectionName(repoID)
+	if err := ip.vectorClient.CreateCollection(ctx, className, dimensions); err != nil {
+		return 0, fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	for {
+		chunks, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return imported, err
+		}
+
+		vectors := make([]*Vector, len(chunks))
+		for i, chunk := range chunks {
+			if len(chunk.Embedding) != dimensions {
+				return imported, fmt.Errorf("chunk of %s:%d-%d has a %d-dimensional embedding, expected %d",
+					chunk.FilePath, chunk.StartLine, chunk.EndLine, len(chunk.Embedding), dimensions)
+			}
+			chunk.RepositoryID = repoID
+			chunk.ID = generateChunkID(chunk.FilePath, chunk.StartLine, chunk.EndLine)
+			chunk.Size = len(chunk.Content)
+			vectors[i] = chunkVector(chunk)
+		}
+		if len(vectors) > 0 {
+			if err := ip.vectorClient.UpsertVectors(ctx, className, vectors); err != nil {
+				return imported, fmt.Errorf("failed to upsert vectors: %w", err)
+			}
+		}
+		imported += len(vectors)
+	}
+
+	return imported, nil
+}
+
+// vectorChunk is the embedded chunk a vector store record was made from. Backends
+// return the numbers in metadata as ints or, decoded from JSON, float64s.
+func vectorChunk(repoID, model string, vector *Vector) *EmbeddedChunk {
+	metadata := vector.Metadata
+	chunk := &EmbeddedChunk{
+		FileChunk: &FileChunk{
+			ID:           vector.ID,
+			RepositoryID: repoID,
+			FilePath:     stringValue(metadata["file_path"]),
+			StartLine:    intValue(metadata["start_line"]),
+			EndLine:      intValue(metadata["end_line"]),
+			Content:      stringValue(metadata["content"]),
+			Language:     stringValue(metadata["language"]),
+			Symbol:       stringValue(metadata["symbol"]),
+		},
+		Embedding: vector.Vector,
+		Model:     model,
+	}
+	chunk.Size = len(chunk.Content)
+	if createdAt, err := time.Parse(time.RFC3339, stringValue(metadata["created_at"])); err == nil {
+		chunk.CreatedAt = createdAt
+	}
+	return chunk
+}
+
+func stringValue(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+func intValue(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,244 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/cache"
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// memoryVectors is a VectorClient holding collections in memory
+type memoryVectors struct {
+	mu          sync.Mutex
+	collections map[string][]*Vector
+}
+
+func (m *memoryVectors) CreateCollection(ctx context.Context, name string, dimensions int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.collections[name]; !ok {
+		m.collections[name] = nil
+	}
+	return nil
+}
+
+func (m *memoryVectors) UpsertVectors(ctx context.Context, collectionName string, vectors []*Vector) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collections[collectionName] = append(m.collections[collectionName], vectors...)
+	return nil
+}
+
+func (m *memoryVectors) DeleteVectorsByFile(ctx context.Context, collectionName, filePath string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var kept []*Vector
+	for _, vector := range m.collections[collectionName] {
+		if vector.Metadata["file_path"] != filePath {
+			kept = append(kept, vector)
+		}
+	}
+	deleted := len(m.collections[collectionName]) - len(kept)
+	m.collections[collectionName] = kept
+	return deleted, nil
+}
+
+func (m *memoryVectors) DeleteCollection(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.collections, name)
+	return nil
+}
+
+// ScanVectors returns two vectors per batch
+func (m *memoryVectors) ScanVectors(ctx context.Context, collectionName string, fn func([]*Vector) error) error {
+	vectors, ok := m.collection(collectionName)
+	if !ok {
+		return errors.New("collection not found")
+	}
+	for start := 0; start < len(vectors); start += 2 {
+		end := start + 2
+		if end > len(vectors) {
+			end = len(vectors)
+		}
+		if err := fn(vectors[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryVectors) CountVectors(ctx context.Context, collectionName string) (int, error) {
+	vectors, _ := m.collection(collectionName)
+	return len(vectors), nil
+}
+
+// collection returns a copy of a collection's vectors and whether it exists
+func (m *memoryVectors) collection(name string) ([]*Vector, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	vectors, ok := m.collections[name]
+	return append([]*Vector(nil), vectors...), ok
+}
+
+// fixedEmbeddings stands in for an embedding provider of a fixed vector size
+type fixedEmbeddings struct {
+	dimensions int
+}
+
+func (e fixedEmbeddings) GenerateEmbeddings(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e fixedEmbeddings) GetDefaultModel() string {
+	return "test-embedding"
+}
+
+func (e fixedEmbeddings) Dimensions() int {
+	return e.dimensions
+}
+
+func newTestProcessor(t *testing.T, vectors VectorClient) *InlineProcessor {
+	t.Helper()
+	redisCache, err := cache.NewRedisCache(cache.RedisOptions{URL: "redis://" + miniredis.RunT(t).Addr()}, cache.TTLConfig{RepositoryRouting: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { redisCache.Close() })
+
+	return NewInlineProcessor(redisCache, observability.NewMetrics(), observability.NewNoOpTracer(),
+		slog.New(slog.NewTextHandler(io.Discard, nil)), fixedEmbeddings{dimensions: 2}, vectors,
+		config.UploadConfig{MaxConcurrentIngestions: 1}, config.DefaultsConfig{}, t.TempDir(), t.TempDir())
+}
+
+func TestImportIndexRefusesWhileIngesting(t *testing.T) {
+	ip := &InlineProcessor{
+		tracer:     observability.NewNoOpTracer(),
+		activeJobs: map[string]*IngestionJob{"repo-1": {RepositoryID: "repo-1", TenantID: "tenant-a"}},
+	}
+
+	called := false
+	next := func() ([]*EmbeddedChunk, error) {
+		called = true
+		return nil, nil
+	}
+
+	_, err := ip.ImportIndex(context.Background(), "tenant-a", "repo-1", next)
+	if !errors.Is(err, ErrIngestionInProgress) {
+		t.Fatalf("ImportIndex error = %v, want ErrIngestionInProgress", err)
+	}
+	if called {
+		t.Fatal("ImportIndex read chunks while an ingestion was running")
+	}
+}
+
+func TestExportImportIndexRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	vectors := &memoryVectors{collections: make(map[string][]*Vector)}
+	ip := newTestProcessor(t, vectors)
+
+	source := []*EmbeddedChunk{
+		{FileChunk: &FileChunk{FilePath: "auth.go", StartLine: 1, EndLine: 20, Content: "func Login() {}", Language: "go", Symbol: "Login"}, Embedding: []float32{1, 0}},
+		{FileChunk: &FileChunk{FilePath: "auth.go", StartLine: 21, EndLine: 40, Content: "func Logout() {}", Language: "go"}, Embedding: []float32{0.6, 0.8}},
+		{FileChunk: &FileChunk{FilePath: "db/query.py", StartLine: 1, EndLine: 5, Content: "def query(): pass", Language: "python"}, Embedding: []float32{0, 1}},
+	}
+	for _, chunk := range source {
+		chunk.RepositoryID = "repo-1"
+		chunk.ID = generateChunkID(chunk.FilePath, chunk.StartLine, chunk.EndLine)
+		vectors.collections[CollectionName("repo-1")] = append(vectors.collections[CollectionName("repo-1")], chunkVector(chunk))
+	}
+
+	var batches [][]*EmbeddedChunk
+	if err := ip.ExportIndex(ctx, "repo-1", func(chunks []*EmbeddedChunk) error {
+		batches = append(batches, chunks)
+		return nil
+	}); err != nil {
+		t.Fatalf("ExportIndex error = %v", err)
+	}
+
+	// The target's working tree belonged to the index being replaced
+	staleTree := filepath.Join(ip.workDir, "repo-2", "old.go")
+	if err := os.MkdirAll(filepath.Dir(staleTree), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(staleTree, []byte("package old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vectors.collections[CollectionName("repo-2")] = []*Vector{{ID: "stale"}}
+
+	next := func() ([]*EmbeddedChunk, error) {
+		if len(batches) == 0 {
+			return nil, io.EOF
+		}
+		batch := batches[0]
+		batches = batches[1:]
+		return batch, nil
+	}
+	imported, err := ip.ImportIndex(ctx, "tenant-a", "repo-2", next)
+	if err != nil {
+		t.Fatalf("ImportIndex error = %v", err)
+	}
+	if imported != len(source) {
+		t.Errorf("imported %d chunks, want %d", imported, len(source))
+	}
+	if _, err := os.Stat(staleTree); !os.IsNotExist(err) {
+		t.Errorf("stale working tree left behind: %v", err)
+	}
+	if status, err := ip.GetIndexStatus(ctx, "repo-2"); err != nil || status.State != repocontextv1.IngestionStatus_STATE_READY {
+		t.Errorf("status after import = %v, %v; want READY", status, err)
+	}
+
+	got := vectors.collections[CollectionName("repo-2")]
+	if len(got) != len(source) {
+		t.Fatalf("repo-2 holds %d vectors, want %d", len(got), len(source))
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].ID < got[j].ID })
+	sort.Slice(source, func(i, j int) bool { return source[i].ID < source[j].ID })
+	for i, vector := range got {
+		want := source[i]
+		if vector.ID != want.ID || vector.Metadata["repository_id"] != "repo-2" {
+			t.Errorf("vector %d = %s in %v, want %s in repo-2", i, vector.ID, vector.Metadata["repository_id"], want.ID)
+		}
+		for key, value := range map[string]interface{}{"file_path": want.FilePath, "start_line": want.StartLine, "end_line": want.EndLine, "content": want.Content, "symbol": want.Symbol} {
+			if vector.Metadata[key] != value {
+				t.Errorf("%s %s = %v, want %v", vector.ID, key, vector.Metadata[key], value)
+			}
+		}
+		if len(vector.Vector) != 2 || vector.Vector[0] != want.Embedding[0] || vector.Vector[1] != want.Embedding[1] {
+			t.Errorf("%s embedding = %v, want %v", vector.ID, vector.Vector, want.Embedding)
+		}
+	}
+}
+
+func TestImportIndexRejectsOtherEmbeddingSizes(t *testing.T) {
+	ip := newTestProcessor(t, &memoryVectors{collections: make(map[string][]*Vector)})
+
+	sent := false
+	next := func() ([]*EmbeddedChunk, error) {
+		if sent {
+			return nil, io.EOF
+		}
+		sent = true
+		return []*EmbeddedChunk{{FileChunk: &FileChunk{FilePath: "a.go", StartLine: 1, EndLine: 2}, Embedding: []float32{1, 0, 0}}}, nil
+	}
+
+	if _, err := ip.ImportIndex(context.Background(), "tenant-a", "repo-1", next); err == nil {
+		t.Fatal("expected an error for 3-dimensional embeddings in a 2-dimensional space")
+	}
+	if status, err := ip.GetIndexStatus(context.Background(), "repo-1"); err != nil || status.State != repocontextv1.IngestionStatus_STATE_FAILED {
+		t.Errorf("status after a failed import = %v, %v; want FAILED", status, err)
+	}
+}
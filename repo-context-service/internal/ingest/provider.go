@@ -2,8 +2,12 @@ package ingest
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
+	"repo-context-service/internal/config"
 	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
 )
 
@@ -11,15 +15,55 @@ type Provider interface {
 	CreateRepositoryIndex(ctx context.Context, req *CreateIndexRequest) (*CreateIndexResponse, error)
 	GetIndexStatus(ctx context.Context, repoID string) (*repocontextv1.IngestionStatus, error)
 	DeleteIndex(ctx context.Context, repoID string) error
+	// CancelIndex stops any ingestion tenantID is running for repoID and waits for it
+	// to exit. It reports whether a job was running; another tenant's job is left alone.
+	CancelIndex(ctx context.Context, tenantID, repoID string) (bool, error)
+	// EmbeddingSpace is the embedding model and vector size of indexed chunks
+	EmbeddingSpace() (string, int)
+	// ExportIndex calls fn with every indexed chunk of repoID, a batch at a time
+	ExportIndex(ctx context.Context, repoID string, fn func([]*EmbeddedChunk) error) error
+	// ImportIndex replaces repoID's index with the chunks returned by next until it
+	// returns io.EOF, and reports how many were imported. It fails with
+	// ErrIngestionInProgress while an ingestion of repoID runs.
+	ImportIndex(ctx context.Context, tenantID, repoID string, next func() ([]*EmbeddedChunk, error)) (int, error)
+	// CountIndexedChunks returns how many chunks repoID's vector collection holds
+	CountIndexedChunks(ctx context.Context, repoID string) (int, error)
+	// ScanRepositoryStats recounts the files, lines and languages of repoID's working tree
+	ScanRepositoryStats(ctx context.Context, repoID string) (*repocontextv1.RepositoryStats, error)
 }
 
+// ErrIngestionInProgress is returned when an ingestion is already running for the repository
+var ErrIngestionInProgress = errors.New("an ingestion is already running for this repository")
+
+// ErrNoIngestion is returned by GetIndexStatus for a repository this processor has no
+// ingestion record of, such as one whose ingestion was lost to a restart
+var ErrNoIngestion = errors.New("no ingestion is known for this repository")
+
+// ErrIngestionQueueFull is returned when the maximum number of ingestions are already
+// running or waiting to run
+var ErrIngestionQueueFull = errors.New("too many ingestions are queued")
+
+// IngestionHeartbeatInterval is how often a queued or running ingestion refreshes the
+// status in its repository record. A record that goes unrefreshed for several
+// intervals belongs to an ingestion no process is running anymore.
+const IngestionHeartbeatInterval = 15 * time.Second
+
+// ErrArchiveTooLarge is returned when an uploaded archive expands past the
+// configured extraction limits
+var ErrArchiveTooLarge = errors.New("archive exceeds extraction limits")
+
 type CreateIndexRequest struct {
 	RepositoryID    string
 	TenantID        string
 	Source          *repocontextv1.RepositorySource
 	Options         *repocontextv1.UploadOptions
 	IdempotencyKey  string
+	// ProgressCallback is called as files are chunked and chunks are embedded and
+	// indexed, after the progress has been persisted for GetUploadStatus
 	ProgressCallback func(*repocontextv1.IngestionProgress)
+	// Incremental re-indexes an existing repository, only processing files whose
+	// content hash changed since the last ingestion
+	Incremental bool
 }
 
 type CreateIndexResponse struct {
@@ -51,6 +95,8 @@ type FileInfo struct {
 	IsBinary     bool
 	LineCount    int
 	LastModified time.Time
+	Hash         string // SHA-256 of the file content
+	Generated    bool   // Vendored or generated code (see IsGenerated)
 }
 
 type ChunkOptions struct {
@@ -61,6 +107,45 @@ type ChunkOptions struct {
 	MaxFileSize  int64
 }
 
+// NewChunkOptions builds chunking options from the configured defaults and a
+// request's upload options, which override them when set
+func NewChunkOptions(defaults config.DefaultsConfig, options *repocontextv1.UploadOptions) *ChunkOptions {
+	chunkOptions := &ChunkOptions{
+		ChunkSize:    defaults.ChunkSize,
+		ChunkOverlap: defaults.ChunkOverlap,
+		MaxFileSize:  10 * 1024 * 1024, // Default 10MB
+	}
+
+	if options != nil {
+		chunkOptions.ExcludePatterns = options.ExcludePatterns
+		chunkOptions.IncludePatterns = options.IncludePatterns
+		if options.MaxFileSizeMb > 0 {
+			chunkOptions.MaxFileSize = int64(options.MaxFileSizeMb) * 1024 * 1024
+		}
+		if options.ChunkSize != 0 {
+			chunkOptions.ChunkSize = int(options.ChunkSize)
+		}
+		if options.ChunkOverlap != 0 {
+			chunkOptions.ChunkOverlap = int(options.ChunkOverlap)
+		}
+	}
+
+	return chunkOptions
+}
+
+// Validate rejects options that would stall the sliding window: it advances by
+// ChunkSize-ChunkOverlap lines, so overlap must be strictly less than the size. The
+// size itself is capped at config.MaxChunkSize.
+func (o *ChunkOptions) Validate() error {
+	if o.ChunkSize <= 0 || o.ChunkSize > config.MaxChunkSize {
+		return fmt.Errorf("chunk size must be between 1 and %d, got %d", config.MaxChunkSize, o.ChunkSize)
+	}
+	if o.ChunkOverlap < 0 || o.ChunkOverlap >= o.ChunkSize {
+		return fmt.Errorf("chunk overlap must be between 0 and chunk size (%d), got %d", o.ChunkSize-1, o.ChunkOverlap)
+	}
+	return nil
+}
+
 type FileChunk struct {
 	ID           string
 	RepositoryID string
@@ -69,6 +154,7 @@ type FileChunk struct {
 	EndLine      int
 	Content      string
 	Language     string
+	Symbol       string // Enclosing function/class, when the chunking strategy knows it
 	Size         int
 	Hash         string
 }
@@ -90,6 +176,11 @@ type IngestionJob struct {
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 	ErrorMessage string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	// The repository's status before a re-index started, restored if it fails
+	previousStatus *repocontextv1.IngestionStatus
 }
 
 type JobManager interface {
@@ -101,10 +192,37 @@ type JobManager interface {
 	ListJobs(ctx context.Context, tenantID string, limit int, offset int) ([]*IngestionJob, error)
 }
 
+// progressReportInterval throttles the reports made while a phase is running;
+// SetCounts at phase boundaries always reports
+const progressReportInterval = 500 * time.Millisecond
+
+// progressStep is how many chunks are embedded or indexed between progress reports
+const progressStep = 1000
+
 type ProgressTracker struct {
 	Total     int32
 	Processed int32
 	callback  func(*repocontextv1.IngestionProgress)
+
+	mu             sync.Mutex
+	totalChunks    int32
+	embeddedChunks int32
+	indexedChunks  int32
+	lastReport     time.Time
+}
+
+type progressTrackerKey struct{}
+
+// withProgressTracker carries pt to the ingestion phases run with ctx
+func withProgressTracker(ctx context.Context, pt *ProgressTracker) context.Context {
+	return context.WithValue(ctx, progressTrackerKey{}, pt)
+}
+
+// progressTrackerFrom returns the tracker carried by ctx. It may be nil, which the
+// incremental update methods ignore.
+func progressTrackerFrom(ctx context.Context) *ProgressTracker {
+	pt, _ := ctx.Value(progressTrackerKey{}).(*ProgressTracker)
+	return pt
 }
 
 func NewProgressTracker(total int32, callback func(*repocontextv1.IngestionProgress)) *ProgressTracker {
@@ -131,17 +249,74 @@ func (pt *ProgressTracker) Increment() {
 }
 
 func (pt *ProgressTracker) SetCounts(totalFiles, processedFiles, totalChunks, embeddedChunks, indexedChunks int32) {
-	if pt.callback != nil {
-		progress := &repocontextv1.IngestionProgress{
-			TotalFiles:      totalFiles,
-			ProcessedFiles:  processedFiles,
-			TotalChunks:     totalChunks,
-			EmbeddedChunks:  embeddedChunks,
-			IndexedChunks:   indexedChunks,
-			ProgressPercent: calculateProgress(totalFiles, processedFiles, totalChunks, embeddedChunks, indexedChunks),
-		}
-		pt.callback(progress)
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.Total = totalFiles
+	pt.Processed = processedFiles
+	pt.totalChunks = totalChunks
+	pt.embeddedChunks = embeddedChunks
+	pt.indexedChunks = indexedChunks
+	pt.report(true)
+}
+
+// FilesChunked records that processedFiles files have been chunked into totalChunks chunks
+func (pt *ProgressTracker) FilesChunked(processedFiles, totalChunks int) {
+	if pt == nil {
+		return
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.Processed = int32(processedFiles)
+	pt.totalChunks = int32(totalChunks)
+	pt.report(false)
+}
+
+// ChunksEmbedded records that embeddedChunks chunks have embeddings so far
+func (pt *ProgressTracker) ChunksEmbedded(embeddedChunks int) {
+	if pt == nil {
+		return
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.embeddedChunks = int32(embeddedChunks)
+	pt.report(false)
+}
+
+// ChunksIndexed records that indexedChunks chunks have been stored so far
+func (pt *ProgressTracker) ChunksIndexed(indexedChunks int) {
+	if pt == nil {
+		return
 	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.indexedChunks = int32(indexedChunks)
+	pt.report(false)
+}
+
+// report passes the current counts to the callback, at most once per
+// progressReportInterval unless forced. pt.mu must be held, which also keeps
+// reports in order.
+func (pt *ProgressTracker) report(force bool) {
+	if pt.callback == nil {
+		return
+	}
+	if !force && time.Since(pt.lastReport) < progressReportInterval {
+		return
+	}
+	pt.lastReport = time.Now()
+
+	pt.callback(&repocontextv1.IngestionProgress{
+		TotalFiles:      pt.Total,
+		ProcessedFiles:  pt.Processed,
+		TotalChunks:     pt.totalChunks,
+		EmbeddedChunks:  pt.embeddedChunks,
+		IndexedChunks:   pt.indexedChunks,
+		ProgressPercent: calculateProgress(pt.Total, pt.Processed, pt.totalChunks, pt.embeddedChunks, pt.indexedChunks),
+	})
 }
 
 func calculateProgress(totalFiles, processedFiles, totalChunks, embeddedChunks, indexedChunks int32) float32 {
@@ -0,0 +1,32 @@
+package ingest
+
+import (
+	"testing"
+
+	"repo-context-service/internal/config"
+)
+
+func TestChunkOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		overlap int
+		wantErr bool
+	}{
+		{"default", 100, 10, false},
+		{"largest", config.MaxChunkSize, 0, false},
+		{"too large", config.MaxChunkSize + 1, 0, true},
+		{"zero size", 0, 0, true},
+		{"overlap equals size", 10, 10, true},
+		{"negative overlap", 10, -1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&ChunkOptions{ChunkSize: tt.size, ChunkOverlap: tt.overlap}).Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate(size %d, overlap %d) error = %v, wantErr %v", tt.size, tt.overlap, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+// CountIndexedChunks returns how many chunks repoID's vector collection holds
+func (ip *InlineProcessor) CountIndexedChunks(ctx context.Context, repoID string) (int, error) {
+	return ip.vectorClient.CountVectors(ctx, CollectionName(repoID))
+}
+
+// ScanRepositoryStats counts the files, lines and languages of repoID's working
+// tree the way ingestion does. A repository without a working tree fails with an
+// error matching os.ErrNotExist. TotalChunks is left unset.
+func (ip *InlineProcessor) ScanRepositoryStats(ctx context.Context, repoID string) (*repocontextv1.RepositoryStats, error) {
+	ctx, span := ip.tracer.StartIngestion(ctx, repoID, "scan_stats")
+	defer span.End()
+
+	repoDir := filepath.Join(ip.workDir, repoID)
+	if _, err := os.Stat(repoDir); err != nil {
+		return nil, err
+	}
+
+	_, stats, err := ip.scanDirectory(ctx, repoDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	sort.Slice(stats.Languages, func(i, j int) bool {
+		return stats.Languages[i].Language < stats.Languages[j].Language
+	})
+
+	return stats, nil
+}
@@ -0,0 +1,68 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"repo-context-service/internal/observability"
+)
+
+func TestScanRepositoryStats(t *testing.T) {
+	workDir := t.TempDir()
+	fixture := map[string]string{
+		"main.go":               "package main\n\nfunc main() {}\n",
+		"internal/util/util.go": "package util\n",
+		"scripts/build.py":      "print('build')\nprint('done')\n",
+		"README.md":             "# Fixture\n",
+		"node_modules/x/x.js":   "module.exports = 1\n",
+		".git/HEAD":             "ref: refs/heads/main\n",
+	}
+	for path, content := range fixture {
+		fullPath := filepath.Join(workDir, "repo-1", path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ip := &InlineProcessor{
+		tracer:  observability.NewNoOpTracer(),
+		logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		workDir: workDir,
+	}
+
+	stats, err := ip.ScanRepositoryStats(context.Background(), "repo-1")
+	if err != nil {
+		t.Fatalf("ScanRepositoryStats error = %v", err)
+	}
+
+	if stats.TotalFiles != 4 {
+		t.Errorf("TotalFiles = %d, want 4 (vendored and .git files excluded)", stats.TotalFiles)
+	}
+	if stats.TotalLines != 7 {
+		t.Errorf("TotalLines = %d, want 7", stats.TotalLines)
+	}
+	want := map[string]int32{"go": 2, "markdown": 1, "python": 1}
+	if len(stats.Languages) != len(want) {
+		t.Fatalf("languages = %v, want %v", stats.Languages, want)
+	}
+	for i, language := range stats.Languages {
+		if i > 0 && stats.Languages[i-1].Language > language.Language {
+			t.Errorf("languages not sorted by name: %v", stats.Languages)
+		}
+		if language.FileCount != want[language.Language] {
+			t.Errorf("%s files = %d, want %d", language.Language, language.FileCount, want[language.Language])
+		}
+	}
+
+	if _, err := ip.ScanRepositoryStats(context.Background(), "imported-repo"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ScanRepositoryStats without a working tree error = %v, want os.ErrNotExist", err)
+	}
+}
@@ -2,23 +2,54 @@ package interceptors
 
 import (
 	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
 	"strings"
 
+	"repo-context-service/internal/cache"
 	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
+// API key scopes
+const (
+	ScopeRead   = "read"
+	ScopeUpload = "upload"
+	ScopeChat   = "chat"
+	ScopeAdmin  = "admin"
+)
+
+// AllScopes lists every scope an API key can be granted
+var AllScopes = []string{ScopeRead, ScopeUpload, ScopeChat, ScopeAdmin}
+
+// unauthenticatedScopes are granted to every caller when auth isn't required. Admin
+// isn't among them, so managing API keys and acting for other tenants need auth.
+var unauthenticatedScopes = []string{ScopeRead, ScopeUpload, ScopeChat}
+
 type AuthInterceptor struct {
 	config *config.SecurityConfig
+	// nil when no JWT key material is configured
+	jwt  *jwtVerifier
+	keys *cache.RedisCache
 }
 
-func NewAuthInterceptor(cfg *config.SecurityConfig) *AuthInterceptor {
+func NewAuthInterceptor(cfg *config.SecurityConfig, keyStore *cache.RedisCache) (*AuthInterceptor, error) {
+	verifier, err := newJWTVerifier(cfg.JWT)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up JWT verification: %w", err)
+	}
+
 	return &AuthInterceptor{
 		config: cfg,
-	}
+		jwt:    verifier,
+		keys:   keyStore,
+	}, nil
 }
 
 func (a *AuthInterceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
@@ -39,6 +70,10 @@ func (a *AuthInterceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 			return nil, err
 		}
 
+		if err := authorize(newCtx, info.FullMethod); err != nil {
+			return nil, err
+		}
+
 		return handler(newCtx, req)
 	}
 }
@@ -61,6 +96,10 @@ func (a *AuthInterceptor) StreamServerInterceptor() grpc.StreamServerInterceptor
 			return err
 		}
 
+		if err := authorize(newCtx, info.FullMethod); err != nil {
+			return err
+		}
+
 		// Wrap stream with authenticated context
 		wrappedStream := &authenticatedStream{
 			ServerStream: stream,
@@ -71,10 +110,37 @@ func (a *AuthInterceptor) StreamServerInterceptor() grpc.StreamServerInterceptor
 	}
 }
 
+// HTTPMiddleware authenticates plain HTTP routes that don't go through gRPC, requiring
+// the same scope as fullMethod. Credentials are read from the same headers.
+func (a *AuthInterceptor) HTTPMiddleware(fullMethod string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			md := metadata.MD{}
+			for _, header := range []string{"x-api-key", "authorization"} {
+				if value := r.Header.Get(header); value != "" {
+					md.Set(header, value)
+				}
+			}
+
+			ctx, err := a.authenticate(metadata.NewIncomingContext(r.Context(), md))
+			if err == nil {
+				err = authorize(ctx, fullMethod)
+			}
+			if err != nil {
+				st := status.Convert(err)
+				http.Error(w, st.Message(), runtime.HTTPStatusFromCode(st.Code()))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 func (a *AuthInterceptor) authenticate(ctx context.Context) (context.Context, error) {
 	if !a.config.RequireAuth {
-		// Add default tenant to context
-		return withTenantID(ctx, a.config.DefaultTenant), nil
+		// Add default tenant to context; without auth every caller may use it
+		return withScopes(withTenantID(ctx, a.config.DefaultTenant), unauthenticatedScopes), nil
 	}
 
 	md, ok := metadata.FromIncomingContext(ctx)
@@ -84,65 +150,116 @@ func (a *AuthInterceptor) authenticate(ctx context.Context) (context.Context, er
 
 	// Try API key first
 	if apiKeys := md.Get("x-api-key"); len(apiKeys) > 0 {
-		tenantID, err := a.validateAPIKey(apiKeys[0])
+		tenantID, scopes, err := a.validateAPIKey(ctx, apiKeys[0])
 		if err != nil {
-			return nil, status.Errorf(codes.Unauthenticated, "invalid API key")
+			return nil, err
 		}
-		return withTenantID(ctx, tenantID), nil
+		return withScopes(withTenantID(ctx, tenantID), scopes), nil
 	}
 
 	// Try Authorization header
 	if authHeaders := md.Get("authorization"); len(authHeaders) > 0 {
-		tenantID, err := a.validateAuthHeader(authHeaders[0])
+		tenantID, scopes, err := a.validateAuthHeader(ctx, authHeaders[0])
 		if err != nil {
 			return nil, status.Errorf(codes.Unauthenticated, "invalid authorization")
 		}
-		return withTenantID(ctx, tenantID), nil
+		return withScopes(withTenantID(ctx, tenantID), scopes), nil
 	}
 
 	return nil, status.Errorf(codes.Unauthenticated, "missing authentication")
 }
 
-func (a *AuthInterceptor) validateAPIKey(apiKey string) (string, error) {
-	// In a real implementation, you would validate the API key against a database
-	// or key management service and extract the tenant ID
-
-	// For now, use a simple validation
+func (a *AuthInterceptor) validateAPIKey(ctx context.Context, apiKey string) (string, []string, error) {
 	if apiKey == "" {
-		return "", status.Errorf(codes.Unauthenticated, "empty API key")
+		return "", nil, status.Errorf(codes.Unauthenticated, "empty API key")
+	}
+
+	// The bootstrap admin key is used to create the first stored keys
+	if a.config.AdminAPIKey != "" && subtle.ConstantTimeCompare([]byte(apiKey), []byte(a.config.AdminAPIKey)) == 1 {
+		return a.config.DefaultTenant, AllScopes, nil
 	}
 
-	// Extract tenant from API key (simplified)
-	// Format: tenant-apikey-randomstring
-	parts := strings.Split(apiKey, "-")
-	if len(parts) < 2 {
-		return a.config.DefaultTenant, nil
+	record, err := a.keys.GetAPIKey(ctx, apiKey)
+	if err != nil {
+		observability.Logger(ctx).Error("validateAPIKey: Failed to look up API key", "error", err)
+		return "", nil, status.Errorf(codes.Unavailable, "failed to validate API key")
+	}
+	if record == nil {
+		return "", nil, status.Errorf(codes.Unauthenticated, "invalid API key")
 	}
 
-	return parts[0], nil
+	return record.TenantID, record.Scopes, nil
 }
 
-func (a *AuthInterceptor) validateAuthHeader(authHeader string) (string, error) {
+func (a *AuthInterceptor) validateAuthHeader(ctx context.Context, authHeader string) (string, []string, error) {
 	// Handle Bearer tokens
 	if strings.HasPrefix(authHeader, "Bearer ") {
 		token := strings.TrimPrefix(authHeader, "Bearer ")
-		return a.validateBearerToken(token)
+		return a.validateBearerToken(ctx, token)
 	}
 
-	return "", status.Errorf(codes.Unauthenticated, "unsupported authorization type")
+	return "", nil, status.Errorf(codes.Unauthenticated, "unsupported authorization type")
 }
 
-func (a *AuthInterceptor) validateBearerToken(token string) (string, error) {
-	// In a real implementation, you would validate the JWT token
-	// and extract the tenant ID from the claims
-
-	// For now, use a simple validation
+func (a *AuthInterceptor) validateBearerToken(ctx context.Context, token string) (string, []string, error) {
 	if token == "" {
-		return "", status.Errorf(codes.Unauthenticated, "empty token")
+		return "", nil, status.Errorf(codes.Unauthenticated, "empty token")
+	}
+
+	if a.jwt == nil {
+		return "", nil, status.Errorf(codes.Unauthenticated, "bearer tokens are not accepted")
+	}
+
+	tenantID, scopes, err := a.jwt.Verify(ctx, token)
+	if err != nil {
+		observability.Logger(ctx).Info("validateBearerToken: Rejected token", "error", err)
+		return "", nil, status.Errorf(codes.Unauthenticated, "invalid token")
 	}
 
-	// For demo purposes, return default tenant
-	return a.config.DefaultTenant, nil
+	return tenantID, scopes, nil
+}
+
+// methodScopes maps each RPC to the scope a caller needs to invoke it. The admin
+// scope satisfies every requirement; methods missing from the table require admin.
+// Health checks skip auth entirely.
+var methodScopes = map[string]string{
+	"/repocontext.v1.UploadService/UploadRepository":             ScopeUpload,
+	"/repocontext.v1.UploadService/UploadGitRepository":          ScopeUpload,
+	"/repocontext.v1.UploadService/GetUploadStatus":              ScopeRead,
+	"/repocontext.v1.UploadService/CancelUpload":                 ScopeUpload,
+	"/repocontext.v1.RepositoryService/ListRepositories":         ScopeRead,
+	"/repocontext.v1.RepositoryService/GetRepository":            ScopeRead,
+	"/repocontext.v1.RepositoryService/UpdateRepository":         ScopeUpload,
+	"/repocontext.v1.RepositoryService/DeleteRepository":         ScopeUpload,
+	"/repocontext.v1.RepositoryService/ReindexRepository":        ScopeUpload,
+	"/repocontext.v1.RepositoryService/GetFile":                  ScopeRead,
+	"/repocontext.v1.RepositoryService/ListFiles":                ScopeRead,
+	"/repocontext.v1.RepositoryService/GetRepositoryStats":       ScopeRead,
+	"/repocontext.v1.RepositoryService/RecomputeRepositoryStats": ScopeUpload,
+	"/repocontext.v1.RepositoryService/ExportIndex":              ScopeRead,
+	"/repocontext.v1.RepositoryService/ImportIndex":              ScopeUpload,
+	"/repocontext.v1.ChatService/ChatWithRepository":             ScopeChat,
+	"/repocontext.v1.ChatService/Search":                         ScopeRead,
+	"/repocontext.v1.AdminService/CreateAPIKey":                  ScopeAdmin,
+	"/repocontext.v1.AdminService/RevokeAPIKey":                  ScopeAdmin,
+}
+
+// authorize checks the caller's scopes against the method's requirement
+func authorize(ctx context.Context, fullMethod string) error {
+	if HasScope(ctx, ScopeAdmin) {
+		return nil
+	}
+
+	required, ok := methodScopes[fullMethod]
+	if !ok {
+		required = ScopeAdmin
+	}
+
+	if !HasScope(ctx, required) {
+		return status.Errorf(codes.PermissionDenied, "%s scope required for %s", required, fullMethod)
+	}
+
+	return nil
 }
 
 // Context helpers
@@ -160,6 +277,34 @@ func GetTenantID(ctx context.Context) string {
 	return "unknown"
 }
 
+// AuthenticatedTenantID returns the tenant set by the auth interceptor, if any
+func AuthenticatedTenantID(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(string)
+	return tenantID, ok
+}
+
+type scopesKey struct{}
+
+func withScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// GetScopes returns the scopes granted to the authenticated caller
+func GetScopes(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopesKey{}).([]string)
+	return scopes
+}
+
+// HasScope reports whether the authenticated caller was granted scope
+func HasScope(ctx context.Context, scope string) bool {
+	for _, s := range GetScopes(ctx) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // Stream wrapper
 
 type authenticatedStream struct {
@@ -178,6 +323,8 @@ func isHealthCheckMethod(fullMethod string) bool {
 		"/repocontext.v1.HealthService/Check",
 		"/repocontext.v1.HealthService/Ping",
 		"/grpc.health.v1.Health/Check",
+		"/grpc.health.v1.Health/Watch",
+		"/grpc.health.v1.Health/List",
 	}
 
 	for _, method := range healthMethods {
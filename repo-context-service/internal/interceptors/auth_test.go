@@ -0,0 +1,143 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/cache"
+	"repo-context-service/internal/config"
+
+	"github.com/alicebob/miniredis/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// newTestCache returns a RedisCache backed by an in-memory Redis
+func newTestCache(t *testing.T) *cache.RedisCache {
+	t.Helper()
+	redisCache, err := cache.NewRedisCache(cache.RedisOptions{URL: "redis://" + miniredis.RunT(t).Addr()}, cache.TTLConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { redisCache.Close() })
+	return redisCache
+}
+
+func TestAuthDisabledWithholdsAdminScope(t *testing.T) {
+	a := &AuthInterceptor{config: &config.SecurityConfig{RequireAuth: false, DefaultTenant: "local"}}
+
+	ctx, err := a.authenticate(context.Background())
+	if err != nil {
+		t.Fatalf("authenticate error = %v", err)
+	}
+	if tenantID, _ := AuthenticatedTenantID(ctx); tenantID != "local" {
+		t.Errorf("tenant = %q, want the default tenant", tenantID)
+	}
+
+	tests := []struct {
+		method   string
+		wantCode codes.Code
+	}{
+		{"/repocontext.v1.ChatService/Search", codes.OK},
+		{"/repocontext.v1.ChatService/ChatWithRepository", codes.OK},
+		{"/repocontext.v1.UploadService/UploadGitRepository", codes.OK},
+		{"/repocontext.v1.AdminService/CreateAPIKey", codes.PermissionDenied},
+		{"/repocontext.v1.AdminService/RevokeAPIKey", codes.PermissionDenied},
+		{"/repocontext.v1.SomeService/Unlisted", codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			if got := status.Code(authorize(ctx, tt.method)); got != tt.wantCode {
+				t.Errorf("authorize(%s) = %v, want %v", tt.method, got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRecomputeStatsNeedsUploadScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []string
+		method   string
+		wantCode codes.Code
+	}{
+		{"read key gets stats", []string{ScopeRead}, "/repocontext.v1.RepositoryService/GetRepositoryStats", codes.OK},
+		{"read and chat key recomputes", []string{ScopeRead, ScopeChat}, "/repocontext.v1.RepositoryService/RecomputeRepositoryStats", codes.PermissionDenied},
+		{"upload key recomputes", []string{ScopeUpload}, "/repocontext.v1.RepositoryService/RecomputeRepositoryStats", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := withScopes(context.Background(), tt.scopes)
+			if got := status.Code(authorize(ctx, tt.method)); got != tt.wantCode {
+				t.Errorf("authorize(%s) with %v = %v, want %v", tt.method, tt.scopes, got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	ctx := context.Background()
+	keyStore := newTestCache(t)
+	a := &AuthInterceptor{config: &config.SecurityConfig{RequireAuth: true, DefaultTenant: "default"}, keys: keyStore}
+
+	if err := keyStore.SetAPIKey(ctx, "rcs_valid", &cache.APIKeyRecord{KeyID: "key-1", TenantID: "tenant-a", Scopes: []string{ScopeRead}, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := keyStore.SetAPIKey(ctx, "rcs_revoked", &cache.APIKeyRecord{KeyID: "key-2", TenantID: "tenant-a", Scopes: []string{ScopeAdmin}, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := keyStore.DeleteAPIKey(ctx, "key-2"); err != nil || !revoked {
+		t.Fatalf("DeleteAPIKey = %v, %v", revoked, err)
+	}
+
+	tests := []struct {
+		name     string
+		apiKey   string
+		wantCode codes.Code
+	}{
+		{"valid", "rcs_valid", codes.OK},
+		{"unknown", "rcs_unknown", codes.Unauthenticated},
+		{"revoked", "rcs_revoked", codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authCtx, err := a.authenticate(metadata.NewIncomingContext(ctx, metadata.Pairs("x-api-key", tt.apiKey)))
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("authenticate error = %v, want code %v", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if tenantID, _ := AuthenticatedTenantID(authCtx); tenantID != "tenant-a" {
+				t.Errorf("tenant = %q, want the key's tenant-a", tenantID)
+			}
+			if scopes := GetScopes(authCtx); len(scopes) != 1 || scopes[0] != ScopeRead {
+				t.Errorf("scopes = %v, want the key's [read]", scopes)
+			}
+		})
+	}
+}
+
+func TestAuthorizeReadOnlyKey(t *testing.T) {
+	ctx := withScopes(withTenantID(context.Background(), "tenant-a"), []string{ScopeRead})
+
+	tests := []struct {
+		method   string
+		wantCode codes.Code
+	}{
+		{"/repocontext.v1.RepositoryService/ListRepositories", codes.OK},
+		{"/repocontext.v1.UploadService/GetUploadStatus", codes.OK},
+		{"/repocontext.v1.UploadService/UploadRepository", codes.PermissionDenied},
+		{"/repocontext.v1.RepositoryService/DeleteRepository", codes.PermissionDenied},
+		{"/repocontext.v1.ChatService/ChatWithRepository", codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			if got := status.Code(authorize(ctx, tt.method)); got != tt.wantCode {
+				t.Errorf("authorize(%s) = %v, want %v", tt.method, got, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package interceptors
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"repo-context-service/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// Only asymmetric algorithms are accepted; the service never holds a signing secret
+var jwtSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// jwtVerifier checks bearer token signatures and registered claims and extracts the tenant
+type jwtVerifier struct {
+	config    config.JWTConfig
+	publicKey crypto.PublicKey
+	jwks      *jwksCache
+	parser    *jwt.Parser
+}
+
+func newJWTVerifier(cfg config.JWTConfig) (*jwtVerifier, error) {
+	v := &jwtVerifier{config: cfg}
+
+	switch {
+	case cfg.PublicKeyFile != "":
+		key, err := loadPublicKey(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.publicKey = key
+	case cfg.JWKSURL != "":
+		v.jwks = newJWKSCache(cfg.JWKSURL, cfg.JWKSRefreshInterval)
+	default:
+		// No key material configured; every bearer token is rejected
+		return nil, nil
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(jwtSigningMethods),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(options...)
+
+	return v, nil
+}
+
+// Verify validates the token signature, exp/nbf/iss/aud and returns the tenant claim
+// and the scopes granted by the token's scope claim
+func (v *jwtVerifier) Verify(ctx context.Context, token string) (string, []string, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if v.publicKey != nil {
+			return v.publicKey, nil
+		}
+		kid, _ := t.Header["kid"].(string)
+		return v.jwks.Key(ctx, kid)
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	tenantID, _ := claims[v.config.TenantClaim].(string)
+	if tenantID == "" {
+		return "", nil, fmt.Errorf("token is missing the %s claim", v.config.TenantClaim)
+	}
+
+	return tenantID, tokenScopes(claims), nil
+}
+
+// tokenScopes reads the OAuth2 space-separated "scope" claim, or a "scopes" array
+func tokenScopes(claims jwt.MapClaims) []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+
+	list, _ := claims["scopes"].([]interface{})
+	scopes := make([]string, 0, len(list))
+	for _, item := range list {
+		if scope, ok := item.(string); ok {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT public key: %w", err)
+	}
+
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("JWT public key %s is not an RSA or EC PEM key", path)
+}
+
+// jwksCache holds the keys served at a JWKS URL. Keys are refetched when a token
+// references an unknown kid, at most once per refresh interval. The fetch runs
+// outside the mutex, and concurrent refreshes share one fetch.
+type jwksCache struct {
+	url             string
+	refreshInterval time.Duration
+	client          *http.Client
+	fetches         singleflight.Group
+
+	mutex     sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func newJWKSCache(url string, refreshInterval time.Duration) *jwksCache {
+	return &jwksCache{
+		url:             url,
+		refreshInterval: refreshInterval,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		keys:            make(map[string]crypto.PublicKey),
+	}
+}
+
+func (c *jwksCache) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mutex.Lock()
+	key, ok := c.lookup(kid)
+	fresh := !c.fetchedAt.IsZero() && time.Since(c.fetchedAt) < c.refreshInterval
+	c.mutex.Unlock()
+
+	if fresh {
+		if ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("no JWKS key with kid %q", kid)
+	}
+
+	// Waiting callers share the fetch, so it isn't tied to one caller's context
+	result := c.fetches.DoChan("jwks", func() (interface{}, error) {
+		return nil, c.refresh(context.WithoutCancel(ctx))
+	})
+	var err error
+	select {
+	case res := <-result:
+		err = res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if key, ok := c.lookup(kid); ok {
+		// Keep serving the previous key set if the JWKS endpoint is down
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no JWKS key with kid %q", kid)
+}
+
+// lookup finds a key by kid; tokens without a kid match a single-key set
+func (c *jwksCache) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// refresh fetches the key set and replaces the cached one, taking the mutex only
+// to swap it in
+func (c *jwksCache) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS endpoint returned status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Skip key types we can't use rather than failing the whole set
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	c.mutex.Lock()
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mutex.Unlock()
+	return nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64BigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64BigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBase64BigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64BigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+func decodeBase64BigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWK value: %w", err)
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,186 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestJWKSCacheFetchesOutsideTheLock(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kid": "k1",
+		"kty": "RSA",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+	}}})
+
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		w.Write(jwks)
+	}))
+	defer server.Close()
+
+	cache := newJWKSCache(server.URL, time.Minute)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.Key(context.Background(), "k1")
+			errs <- err
+		}()
+	}
+	for atomic.LoadInt32(&requests) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// A caller that gives up isn't stuck behind the fetch in progress
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := cache.Key(ctx, "k1"); err != context.DeadlineExceeded {
+		t.Errorf("Key during a slow fetch = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Key error = %v", err)
+		}
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("JWKS fetched %d times, want 1 shared fetch", got)
+	}
+
+	// The fresh set answers unknown kids without refetching
+	if _, err := cache.Key(context.Background(), "k2"); err == nil {
+		t.Error("expected an error for an unknown kid")
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("JWKS refetched within the refresh interval: %d fetches", got)
+	}
+}
+
+func TestBearerTokenAuthentication(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "jwt.pub")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewAuthInterceptor(&config.SecurityConfig{
+		RequireAuth: true,
+		JWT: config.JWTConfig{
+			PublicKeyFile: keyFile,
+			Issuer:        "https://issuer.example.com",
+			Audience:      "repo-context",
+			TenantClaim:   "tenant_id",
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":       "https://issuer.example.com",
+			"aud":       "repo-context",
+			"exp":       time.Now().Add(time.Hour).Unix(),
+			"tenant_id": "tenant-a",
+			"scope":     "read chat",
+		}
+	}
+	sign := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(privateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	wrongIssuer := validClaims()
+	wrongIssuer["iss"] = "https://attacker.example.com"
+	wrongAudience := validClaims()
+	wrongAudience["aud"] = "another-service"
+	noTenant := validClaims()
+	delete(noTenant, "tenant_id")
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreignToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims()).SignedString(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode codes.Code
+	}{
+		{"valid", sign(validClaims()), codes.OK},
+		{"expired", sign(expired), codes.Unauthenticated},
+		{"wrong issuer", sign(wrongIssuer), codes.Unauthenticated},
+		{"wrong audience", sign(wrongAudience), codes.Unauthenticated},
+		{"missing tenant", sign(noTenant), codes.Unauthenticated},
+		{"signed by another key", foreignToken, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tt.token))
+			authCtx, err := a.authenticate(ctx)
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("authenticate error = %v, want code %v", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if tenantID, _ := AuthenticatedTenantID(authCtx); tenantID != "tenant-a" {
+				t.Errorf("tenant = %q, want tenant-a", tenantID)
+			}
+			if !HasScope(authCtx, ScopeRead) || !HasScope(authCtx, ScopeChat) || HasScope(authCtx, ScopeUpload) {
+				t.Errorf("scopes = %v, want the token's read and chat", GetScopes(authCtx))
+			}
+		})
+	}
+}
@@ -2,10 +2,15 @@ package interceptors
 
 import (
 	"context"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"repo-context-service/internal/cache"
 	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -14,15 +19,38 @@ import (
 
 type RateLimitInterceptor struct {
 	config   *config.RateLimitConfig
-	limiters map[string]*rate.Limiter
+	limiters map[string]*tenantLimiter
 	mutex    sync.RWMutex
+
+	// Shared limiter store, used when the backend is "redis"
+	store *cache.RedisCache
+}
+
+// tenantLimiter is a tenant's token bucket along with when it was last used,
+// so idle tenants can be evicted without resetting active ones
+type tenantLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen atomic.Int64 // unix nanoseconds
+}
+
+func (t *tenantLimiter) touch(now time.Time) {
+	t.lastSeen.Store(now.UnixNano())
 }
 
-func NewRateLimitInterceptor(cfg *config.RateLimitConfig) *RateLimitInterceptor {
-	return &RateLimitInterceptor{
+func (t *tenantLimiter) idleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, t.lastSeen.Load()))
+}
+
+func NewRateLimitInterceptor(cfg *config.RateLimitConfig, redisCache *cache.RedisCache) *RateLimitInterceptor {
+	r := &RateLimitInterceptor{
 		config:   cfg,
-		limiters: make(map[string]*rate.Limiter),
+		limiters: make(map[string]*tenantLimiter),
 	}
+	if cfg.Backend == "redis" {
+		r.store = redisCache
+	}
+
+	return r
 }
 
 func (r *RateLimitInterceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
@@ -65,29 +93,60 @@ func (r *RateLimitInterceptor) StreamServerInterceptor() grpc.StreamServerInterc
 	}
 }
 
+// HTTPMiddleware applies the tenant's rate limit to plain HTTP routes. It goes after
+// the auth middleware, which puts the tenant in the request context.
+func (r *RateLimitInterceptor) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := r.checkRateLimit(req.Context()); err != nil {
+			st := status.Convert(err)
+			http.Error(w, st.Message(), runtime.HTTPStatusFromCode(st.Code()))
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
 func (r *RateLimitInterceptor) checkRateLimit(ctx context.Context) error {
 	tenantID := GetTenantID(ctx)
 	if tenantID == "" {
 		tenantID = "default"
 	}
 
-	limiter := r.getLimiter(tenantID)
-
-	// Check if request is allowed
-	if !limiter.Allow() {
+	if !r.allow(ctx, tenantID) {
 		return status.Errorf(codes.ResourceExhausted, "rate limit exceeded for tenant %s", tenantID)
 	}
 
 	return nil
 }
 
+// allow checks the tenant's shared bucket when a store is configured, falling back
+// to the local limiter if Redis is unavailable
+func (r *RateLimitInterceptor) allow(ctx context.Context, tenantID string) bool {
+	if r.store != nil {
+		allowed, err := r.store.AllowRequest(ctx, tenantID, float64(r.config.RequestsPerSecond), r.config.BurstSize)
+		if err == nil {
+			return allowed
+		}
+		observability.Logger(ctx).Warn("checkRateLimit: Redis rate limit failed, using local limiter", observability.LogKeyTenantID, tenantID, "error", err)
+	}
+
+	return r.getLimiter(tenantID).Allow()
+}
+
 func (r *RateLimitInterceptor) getLimiter(tenantID string) *rate.Limiter {
+	now := time.Now()
+
 	r.mutex.RLock()
-	limiter, exists := r.limiters[tenantID]
+	entry, exists := r.limiters[tenantID]
+	if exists {
+		// Touch under the read lock so the sweeper can't evict it in between
+		entry.touch(now)
+	}
 	r.mutex.RUnlock()
 
 	if exists {
-		return limiter
+		return entry.limiter
 	}
 
 	// Create new limiter for tenant
@@ -95,31 +154,58 @@ func (r *RateLimitInterceptor) getLimiter(tenantID string) *rate.Limiter {
 	defer r.mutex.Unlock()
 
 	// Double-check after acquiring write lock
-	if limiter, exists := r.limiters[tenantID]; exists {
-		return limiter
+	if entry, exists := r.limiters[tenantID]; exists {
+		entry.touch(now)
+		return entry.limiter
 	}
 
 	// Create rate limiter with per-second rate and burst size
-	limiter = rate.NewLimiter(
-		rate.Limit(r.config.RequestsPerSecond),
-		r.config.BurstSize,
-	)
-
-	r.limiters[tenantID] = limiter
+	entry = &tenantLimiter{
+		limiter: rate.NewLimiter(
+			rate.Limit(r.config.RequestsPerSecond),
+			r.config.BurstSize,
+		),
+	}
+	entry.touch(now)
 
-	// Start cleanup routine for this tenant (simplified)
-	go r.cleanupLimiter(tenantID, r.config.WindowSize*2)
+	r.limiters[tenantID] = entry
 
-	return limiter
+	return entry.limiter
 }
 
-func (r *RateLimitInterceptor) cleanupLimiter(tenantID string, cleanupAfter time.Duration) {
-	time.Sleep(cleanupAfter)
+// SweepLimiters evicts the limiters of tenants idle for two windows, checking every
+// window. It runs until ctx is cancelled.
+func (r *RateLimitInterceptor) SweepLimiters(ctx context.Context) {
+	interval := r.config.WindowSize
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.evictIdleLimiters(now, 2*interval)
+		}
+	}
+}
 
+func (r *RateLimitInterceptor) evictIdleLimiters(now time.Time, idleTimeout time.Duration) int {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	// Remove inactive limiters to prevent memory leaks
-	// In a production system, you'd want more sophisticated cleanup logic
-	delete(r.limiters, tenantID)
+	evicted := 0
+	for tenantID, entry := range r.limiters {
+		if entry.idleFor(now) < idleTimeout {
+			continue
+		}
+		delete(r.limiters, tenantID)
+		evicted++
+	}
+
+	return evicted
 }
\ No newline at end of file
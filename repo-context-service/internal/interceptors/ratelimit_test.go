@@ -0,0 +1,120 @@
+package interceptors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/config"
+)
+
+func TestRateLimitHTTPMiddleware(t *testing.T) {
+	limiter := NewRateLimitInterceptor(&config.RateLimitConfig{Backend: "local", RequestsPerSecond: 1, BurstSize: 2}, nil)
+	handler := limiter.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(tenantID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/upload/archive", nil)
+		req = req.WithContext(withTenantID(context.Background(), tenantID))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := request("tenant-a"); code != http.StatusOK {
+			t.Fatalf("request %d within the burst got %d, want 200", i+1, code)
+		}
+	}
+	if code := request("tenant-a"); code != http.StatusTooManyRequests {
+		t.Fatalf("request past the burst got %d, want 429", code)
+	}
+	if code := request("tenant-b"); code != http.StatusOK {
+		t.Fatalf("another tenant got %d, want 200", code)
+	}
+}
+
+func TestSweepLimitersStopsWhenCancelled(t *testing.T) {
+	limiter := NewRateLimitInterceptor(&config.RateLimitConfig{Backend: "local", RequestsPerSecond: 1, BurstSize: 1, WindowSize: 5 * time.Millisecond}, nil)
+	limiter.getLimiter("idle-tenant")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		limiter.SweepLimiters(ctx)
+		close(stopped)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		limiter.mutex.Lock()
+		remaining := len(limiter.limiters)
+		limiter.mutex.Unlock()
+		if remaining == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("idle limiter never swept")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("SweepLimiters kept running after its context was cancelled")
+	}
+}
+
+func TestRedisRateLimitSharedAcrossReplicas(t *testing.T) {
+	redisCache := newTestCache(t)
+	cfg := &config.RateLimitConfig{Backend: "redis", RequestsPerSecond: 1, BurstSize: 3}
+	replicas := []*RateLimitInterceptor{NewRateLimitInterceptor(cfg, redisCache), NewRateLimitInterceptor(cfg, redisCache)}
+	ctx := withTenantID(context.Background(), "tenant-a")
+
+	// Alternating replicas draw from one bucket, so the burst covers both together
+	allowed := 0
+	for i := 0; i < 6; i++ {
+		if replicas[i%2].checkRateLimit(ctx) == nil {
+			allowed++
+		}
+	}
+	if allowed != cfg.BurstSize {
+		t.Errorf("two replicas allowed %d requests, want %d between them", allowed, cfg.BurstSize)
+	}
+	if err := replicas[1].checkRateLimit(withTenantID(context.Background(), "tenant-b")); err != nil {
+		t.Errorf("another tenant was limited: %v", err)
+	}
+}
+
+func TestSweepLimitersKeepsActiveTenants(t *testing.T) {
+	limiter := NewRateLimitInterceptor(&config.RateLimitConfig{Backend: "local", RequestsPerSecond: 1, BurstSize: 1, WindowSize: 10 * time.Millisecond}, nil)
+	limiter.getLimiter("idle-tenant")
+	active := limiter.getLimiter("active-tenant")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go limiter.SweepLimiters(ctx)
+
+	// The active tenant keeps making requests while the idle one's limiter ages out
+	deadline := time.Now().Add(time.Second)
+	for {
+		if limiter.getLimiter("active-tenant") != active {
+			t.Fatal("the active tenant's limiter was evicted and its bucket reset")
+		}
+		limiter.mutex.RLock()
+		_, idleKept := limiter.limiters["idle-tenant"]
+		limiter.mutex.RUnlock()
+		if !idleKept {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("idle limiter never swept")
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
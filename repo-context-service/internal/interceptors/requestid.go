@@ -0,0 +1,149 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"repo-context-service/internal/observability"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader carries a request's correlation ID, as gRPC metadata and as the
+// HTTP header X-Request-Id. Callers may set it; otherwise one is generated.
+const RequestIDHeader = "x-request-id"
+
+// maxRequestIDLength bounds the request IDs accepted from callers
+const maxRequestIDLength = 128
+
+// RequestIDInterceptor gives every request an ID, puts it and a logger tagged with
+// it in the context, and returns it to the caller in the response headers
+type RequestIDInterceptor struct {
+	logger *slog.Logger
+}
+
+func NewRequestIDInterceptor(logger *slog.Logger) *RequestIDInterceptor {
+	return &RequestIDInterceptor{logger: logger}
+}
+
+func (i *RequestIDInterceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, requestID := i.withRequestID(ctx)
+		if err := grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, requestID)); err != nil {
+			observability.LoggerFromContext(ctx, i.logger).Warn("RequestIDInterceptor: Failed to set response header", "error", err)
+		}
+
+		timer := observability.StartTimer()
+		resp, err := handler(ctx, req)
+		i.logCompleted(ctx, info.FullMethod, timer.Duration(), err)
+		return resp, err
+	}
+}
+
+func (i *RequestIDInterceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, requestID := i.withRequestID(stream.Context())
+		if err := stream.SetHeader(metadata.Pairs(RequestIDHeader, requestID)); err != nil {
+			observability.LoggerFromContext(ctx, i.logger).Warn("RequestIDInterceptor: Failed to set response header", "error", err)
+		}
+
+		timer := observability.StartTimer()
+		err := handler(srv, &requestIDStream{ServerStream: stream, ctx: ctx})
+		i.logCompleted(ctx, info.FullMethod, timer.Duration(), err)
+		return err
+	}
+}
+
+// HTTPMiddleware does the same for plain HTTP routes. The ID is also set on the
+// request's X-Request-Id header, so the gRPC-Gateway, gRPC-Web and the WebSocket
+// bridge forward it to the gRPC server, where it's picked up again.
+func (i *RequestIDInterceptor) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+			r.Header.Set(RequestIDHeader, requestID)
+		}
+
+		ctx := i.contextWithRequestID(r.Context(), requestID)
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// withRequestID returns ctx carrying the request ID from the incoming metadata,
+// or a new one if the caller didn't send a usable one
+func (i *RequestIDInterceptor) withRequestID(ctx context.Context) (context.Context, string) {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDHeader); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+	if !validRequestID(requestID) {
+		requestID = newRequestID()
+	}
+
+	return i.contextWithRequestID(ctx, requestID), requestID
+}
+
+func (i *RequestIDInterceptor) contextWithRequestID(ctx context.Context, requestID string) context.Context {
+	ctx = observability.ContextWithRequestID(ctx, requestID)
+	return observability.ContextWithLogger(ctx, i.logger.With(observability.LogKeyRequestID, requestID))
+}
+
+func (i *RequestIDInterceptor) logCompleted(ctx context.Context, fullMethod string, duration time.Duration, err error) {
+	observability.LoggerFromContext(ctx, i.logger).Debug("RPC completed",
+		"method", fullMethod,
+		"code", status.Code(err).String(),
+		"duration_ms", duration.Milliseconds(),
+	)
+}
+
+// requestIDStream carries the request ID to streaming handlers
+type requestIDStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDStream) Context() context.Context {
+	return s.ctx
+}
+
+// validRequestID reports whether a caller-supplied request ID can be used as is:
+// non-empty, bounded and printable ASCII, so it can't forge log lines or headers
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "req-" + time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
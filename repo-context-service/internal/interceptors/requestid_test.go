@@ -0,0 +1,131 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"repo-context-service/internal/observability"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+// syncBuffer is a log destination shared with the goroutines of a gRPC server
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRequestIDHTTPMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		sent     string
+		wantSent bool
+	}{
+		{"caller's ID", "client-req-42", true},
+		{"no ID", "", false},
+		{"ID that could forge log lines", "req-1\nlevel=ERROR", false},
+		{"oversized ID", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			interceptor := NewRequestIDInterceptor(slog.New(slog.NewTextHandler(&logs, nil)))
+			var seen string
+			handler := interceptor.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = observability.RequestIDFromContext(r.Context())
+				observability.LoggerFromContext(r.Context(), nil).Info("handling request")
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/repositories", nil)
+			if tt.sent != "" {
+				req.Header.Set(RequestIDHeader, tt.sent)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			echoed := rec.Header().Get(RequestIDHeader)
+			if tt.wantSent && echoed != tt.sent {
+				t.Errorf("echoed request ID %q, want the caller's %q", echoed, tt.sent)
+			}
+			if !tt.wantSent && (echoed == tt.sent || !validRequestID(echoed)) {
+				t.Errorf("echoed request ID %q, want a generated one", echoed)
+			}
+			if seen != echoed {
+				t.Errorf("handler saw request ID %q, want the echoed %q", seen, echoed)
+			}
+			if !strings.Contains(logs.String(), observability.LogKeyRequestID+"="+echoed) {
+				t.Errorf("handler's log %q doesn't carry request ID %q", logs.String(), echoed)
+			}
+		})
+	}
+}
+
+func TestRequestIDGRPCInterceptor(t *testing.T) {
+	logs := &syncBuffer{}
+	interceptor := NewRequestIDInterceptor(slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	server := grpc.NewServer(grpc.UnaryInterceptor(interceptor.UnaryServerInterceptor()))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(lis)
+	defer server.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	tests := []struct {
+		name string
+		sent string
+	}{
+		{"caller's ID", "client-req-42"},
+		{"no ID", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.sent != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, RequestIDHeader, tt.sent)
+			}
+			var header metadata.MD
+			if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header)); err != nil {
+				t.Fatalf("Check error = %v", err)
+			}
+
+			values := header.Get(RequestIDHeader)
+			if len(values) != 1 || !validRequestID(values[0]) || (tt.sent != "" && values[0] != tt.sent) {
+				t.Fatalf("response header %s = %v, want the caller's ID or a generated one", RequestIDHeader, values)
+			}
+			if !strings.Contains(logs.String(), observability.LogKeyRequestID+"="+values[0]) {
+				t.Errorf("logs %q don't carry request ID %q", logs.String(), values[0])
+			}
+		})
+	}
+}
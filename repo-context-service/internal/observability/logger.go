@@ -0,0 +1,69 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Structured log attribute keys
+const (
+	LogKeyRequestID    = "request_id"
+	LogKeyUploadID     = "upload_id"
+	LogKeyTenantID     = "tenant_id"
+	LogKeyRepositoryID = "repository_id"
+)
+
+// ParseLogLevel maps LOG_LEVEL values (debug, info, warn, error) to a slog level
+func ParseLogLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+	return l, nil
+}
+
+// NewLogger creates a JSON logger writing to w that drops records below level
+func NewLogger(w io.Writer, level string) (*slog.Logger, error) {
+	l, err := ParseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: l})), nil
+}
+
+type loggerKey struct{}
+
+// ContextWithLogger returns a context carrying logger
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger stored in ctx, or fallback if there is none
+func LoggerFromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return fallback
+}
+
+// Logger returns the logger stored in ctx, or slog's default logger, which main sets
+// up from LOG_LEVEL
+func Logger(ctx context.Context) *slog.Logger {
+	return LoggerFromContext(ctx, slog.Default())
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a context carrying the ID of the request it serves
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "" if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
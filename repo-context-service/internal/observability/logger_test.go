@@ -0,0 +1,54 @@
+package observability
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewLoggerLevel(t *testing.T) {
+	tests := []struct {
+		level string
+		want  []string
+	}{
+		{"debug", []string{"debug line", "info line", "warn line", "error line"}},
+		{"info", []string{"info line", "warn line", "error line"}},
+		{"warn", []string{"warn line", "error line"}},
+		{" ERROR ", []string{"error line"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := NewLogger(&buf, tt.level)
+			if err != nil {
+				t.Fatalf("NewLogger error = %v", err)
+			}
+
+			logger.Debug("debug line")
+			logger.Info("info line")
+			logger.Warn("warn line")
+			logger.Error("error line")
+
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var record struct {
+					Msg string `json:"msg"`
+				}
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatalf("log line %q is not JSON: %v", line, err)
+				}
+				got = append(got, record.Msg)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("logged %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLoggerRejectsUnknownLevel(t *testing.T) {
+	if _, err := NewLogger(&bytes.Buffer{}, "verbose"); err == nil {
+		t.Error("NewLogger accepted level \"verbose\"")
+	}
+}
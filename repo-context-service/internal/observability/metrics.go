@@ -83,6 +83,21 @@ var (
 		},
 	)
 
+	// Chat metrics
+	activeChatSessions = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "active_chat_sessions",
+			Help: "Number of open chat sessions",
+		},
+	)
+
+	activeWebSocketConnections = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "active_websocket_connections",
+			Help: "Number of open WebSocket chat connections",
+		},
+	)
+
 	// Upload metrics
 	uploadRequestsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -108,6 +123,23 @@ var (
 		},
 	)
 
+	ingestionPhaseDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ingestion_phase_duration_seconds",
+			Help:    "Time taken by each completed ingestion phase (extract, chunk, embed, index)",
+			Buckets: []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300, 600, 1200, 3600},
+		},
+		[]string{"phase"},
+	)
+
+	ingestionJobs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ingestion_jobs",
+			Help: "Number of ingestions running or queued for a worker",
+		},
+		[]string{"state"},
+	)
+
 	// Search metrics
 	searchResultsTotal = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -126,6 +158,30 @@ var (
 		[]string{"model", "status"},
 	)
 
+	queryEmbeddingFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "query_embedding_failures_total",
+			Help: "Total number of query embeddings that failed, degrading search to lexical-only",
+		},
+		[]string{"reason"},
+	)
+
+	vectorObjectFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vector_object_failures_total",
+			Help: "Total number of chunks a vector store rejected within an otherwise successful batch insert",
+		},
+		[]string{"backend"},
+	)
+
+	embeddingCacheLookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "embedding_cache_lookups_total",
+			Help: "Total number of chunk embedding cache lookups during ingestion",
+		},
+		[]string{"model", "result"},
+	)
+
 	llmRequestsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "llm_requests_total",
@@ -133,6 +189,22 @@ var (
 		},
 		[]string{"model", "status"},
 	)
+
+	embeddingTokensTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "embedding_tokens_total",
+			Help: "Total number of input tokens sent for embedding, as reported by the provider or estimated",
+		},
+		[]string{"model"},
+	)
+
+	llmTokensTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_tokens_total",
+			Help: "Total number of LLM prompt and completion tokens, as reported by the provider or estimated for streams",
+		},
+		[]string{"model", "type"},
+	)
 )
 
 func init() {
@@ -145,12 +217,21 @@ func init() {
 		cacheMissesTotal,
 		timeToFirstHitMs,
 		timeToSummaryMs,
+		activeChatSessions,
+		activeWebSocketConnections,
 		uploadRequestsTotal,
 		uploadSizeBytes,
 		ingestionDurationSeconds,
+		ingestionPhaseDurationSeconds,
+		ingestionJobs,
 		searchResultsTotal,
 		embeddingRequestsTotal,
+		queryEmbeddingFailuresTotal,
+		vectorObjectFailuresTotal,
+		embeddingCacheLookupsTotal,
 		llmRequestsTotal,
+		embeddingTokensTotal,
+		llmTokensTotal,
 	)
 }
 
@@ -198,6 +279,23 @@ func (m *Metrics) RecordTimeToSummary(duration time.Duration) {
 	timeToSummaryMs.Observe(float64(duration.Nanoseconds()) / 1e6)
 }
 
+// Chat metrics
+func (m *Metrics) IncActiveChatSessions() {
+	activeChatSessions.Inc()
+}
+
+func (m *Metrics) DecActiveChatSessions() {
+	activeChatSessions.Dec()
+}
+
+func (m *Metrics) IncActiveWebSocketConnections() {
+	activeWebSocketConnections.Inc()
+}
+
+func (m *Metrics) DecActiveWebSocketConnections() {
+	activeWebSocketConnections.Dec()
+}
+
 // Upload metrics
 func (m *Metrics) RecordUploadRequest(sourceType, status string) {
 	uploadRequestsTotal.WithLabelValues(sourceType, status).Inc()
@@ -211,6 +309,19 @@ func (m *Metrics) RecordIngestionDuration(duration time.Duration) {
 	ingestionDurationSeconds.Observe(duration.Seconds())
 }
 
+func (m *Metrics) RecordIngestionPhaseDuration(phase string, duration time.Duration) {
+	ingestionPhaseDurationSeconds.WithLabelValues(phase).Observe(duration.Seconds())
+}
+
+// IncIngestionJobs and DecIngestionJobs track ingestions in state "running" or "queued"
+func (m *Metrics) IncIngestionJobs(state string) {
+	ingestionJobs.WithLabelValues(state).Inc()
+}
+
+func (m *Metrics) DecIngestionJobs(state string) {
+	ingestionJobs.WithLabelValues(state).Dec()
+}
+
 // Search metrics
 func (m *Metrics) RecordSearchResults(backend string, count int) {
 	searchResultsTotal.WithLabelValues(backend).Observe(float64(count))
@@ -220,10 +331,33 @@ func (m *Metrics) RecordEmbeddingRequest(model, status string) {
 	embeddingRequestsTotal.WithLabelValues(model, status).Inc()
 }
 
+func (m *Metrics) RecordQueryEmbeddingFailure(reason string) {
+	queryEmbeddingFailuresTotal.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) RecordVectorObjectFailures(backend string, count int) {
+	vectorObjectFailuresTotal.WithLabelValues(backend).Add(float64(count))
+}
+
+func (m *Metrics) RecordEmbeddingCacheLookups(model string, hits, misses int) {
+	embeddingCacheLookupsTotal.WithLabelValues(model, "hit").Add(float64(hits))
+	embeddingCacheLookupsTotal.WithLabelValues(model, "miss").Add(float64(misses))
+}
+
 func (m *Metrics) RecordLLMRequest(model, status string) {
 	llmRequestsTotal.WithLabelValues(model, status).Inc()
 }
 
+func (m *Metrics) RecordEmbeddingTokens(model string, tokens int) {
+	embeddingTokensTotal.WithLabelValues(model).Add(float64(tokens))
+}
+
+// RecordLLMTokens records the prompt and completion tokens of one LLM call
+func (m *Metrics) RecordLLMTokens(model string, promptTokens, completionTokens int) {
+	llmTokensTotal.WithLabelValues(model, "prompt").Add(float64(promptTokens))
+	llmTokensTotal.WithLabelValues(model, "completion").Add(float64(completionTokens))
+}
+
 // gRPC interceptors
 func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	return func(
@@ -268,6 +402,72 @@ func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
 	}
 }
 
+// HTTPMiddleware records requests to a plain HTTP route under the RPC it stands in
+// for, with the same metrics as the gRPC interceptors. The status code is mapped
+// back to the gRPC code the gateway would have answered it for.
+func (m *Metrics) HTTPMiddleware(fullMethod string) func(http.Handler) http.Handler {
+	service, method := splitMethodName(fullMethod)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			m.IncInFlightStreams(service, method)
+			defer m.DecInFlightStreams(service, method)
+
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			m.RecordRPCRequest(service, method, codeFromHTTPStatus(recorder.status), time.Since(start))
+		})
+	}
+}
+
+// statusRecorder remembers the status code written to a response. Unwrap lets
+// http.ResponseController reach the underlying writer for flushes and deadlines.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// codeFromHTTPStatus is the reverse of the gateway's code to status mapping
+func codeFromHTTPStatus(status int) codes.Code {
+	switch {
+	case status < 400:
+		return codes.OK
+	case status == http.StatusBadRequest:
+		return codes.InvalidArgument
+	case status == http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case status == http.StatusForbidden:
+		return codes.PermissionDenied
+	case status == http.StatusNotFound:
+		return codes.NotFound
+	case status == http.StatusConflict:
+		return codes.Aborted
+	case status == http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case status == 499:
+		return codes.Canceled
+	case status == http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case status == http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case status < 500:
+		return codes.FailedPrecondition
+	default:
+		return codes.Internal
+	}
+}
+
 // HTTP handler for metrics endpoint
 func (m *Metrics) Handler() http.Handler {
 	return promhttp.Handler()
@@ -0,0 +1,125 @@
+package observability
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gatheredValue returns the value of the counter or gauge called name with the given
+// labels, or for a histogram its sum and sample count
+func gatheredValue(t *testing.T, name string, labels map[string]string) (float64, uint64) {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			if histogram := metric.GetHistogram(); histogram != nil {
+				return histogram.GetSampleSum(), histogram.GetSampleCount()
+			}
+			return metric.GetCounter().GetValue() + metric.GetGauge().GetValue(), 0
+		}
+	}
+	return 0, 0
+}
+
+func TestActiveSessionGauges(t *testing.T) {
+	m := NewMetrics()
+	sessions, _ := gatheredValue(t, "active_chat_sessions", nil)
+	connections, _ := gatheredValue(t, "active_websocket_connections", nil)
+
+	m.IncActiveChatSessions()
+	m.IncActiveChatSessions()
+	m.IncActiveWebSocketConnections()
+	if got, _ := gatheredValue(t, "active_chat_sessions", nil); got-sessions != 2 {
+		t.Errorf("active_chat_sessions rose by %v, want 2", got-sessions)
+	}
+	if got, _ := gatheredValue(t, "active_websocket_connections", nil); got-connections != 1 {
+		t.Errorf("active_websocket_connections rose by %v, want 1", got-connections)
+	}
+
+	// Closing every session brings the gauges back down
+	m.DecActiveChatSessions()
+	m.DecActiveChatSessions()
+	m.DecActiveWebSocketConnections()
+	if got, _ := gatheredValue(t, "active_chat_sessions", nil); got != sessions {
+		t.Errorf("active_chat_sessions = %v after closing, want %v", got, sessions)
+	}
+	if got, _ := gatheredValue(t, "active_websocket_connections", nil); got != connections {
+		t.Errorf("active_websocket_connections = %v after closing, want %v", got, connections)
+	}
+}
+
+func TestIngestionPhaseDuration(t *testing.T) {
+	m := NewMetrics()
+	phases := map[string]time.Duration{"extract": 2 * time.Second, "embed": 500 * time.Millisecond}
+	before := map[string]float64{}
+	beforeCount := map[string]uint64{}
+	for phase := range phases {
+		before[phase], beforeCount[phase] = gatheredValue(t, "ingestion_phase_duration_seconds", map[string]string{"phase": phase})
+	}
+
+	for phase, duration := range phases {
+		m.RecordIngestionPhaseDuration(phase, duration)
+	}
+	m.RecordIngestionPhaseDuration("extract", time.Second)
+
+	// Each phase is observed under its own label, in seconds
+	tests := []struct {
+		phase     string
+		wantSum   float64
+		wantCount uint64
+	}{
+		{"extract", 3, 2},
+		{"embed", 0.5, 1},
+	}
+	for _, tt := range tests {
+		sum, count := gatheredValue(t, "ingestion_phase_duration_seconds", map[string]string{"phase": tt.phase})
+		if sum-before[tt.phase] != tt.wantSum || count-beforeCount[tt.phase] != tt.wantCount {
+			t.Errorf("%s phase recorded %v seconds over %d samples, want %v over %d", tt.phase, sum-before[tt.phase], count-beforeCount[tt.phase], tt.wantSum, tt.wantCount)
+		}
+	}
+}
+
+func TestTokenCounters(t *testing.T) {
+	m := NewMetrics()
+	counters := []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{"embedding_tokens_total", map[string]string{"model": "embed-small"}, 150},
+		{"embedding_tokens_total", map[string]string{"model": "embed-large"}, 40},
+		{"llm_tokens_total", map[string]string{"model": "chat", "type": "prompt"}, 1200},
+		{"llm_tokens_total", map[string]string{"model": "chat", "type": "completion"}, 300},
+	}
+	before := make([]float64, len(counters))
+	for i, c := range counters {
+		before[i], _ = gatheredValue(t, c.name, c.labels)
+	}
+
+	// Tokens add up per model, and for LLM calls per prompt or completion
+	m.RecordEmbeddingTokens("embed-small", 100)
+	m.RecordEmbeddingTokens("embed-small", 50)
+	m.RecordEmbeddingTokens("embed-large", 40)
+	m.RecordLLMTokens("chat", 1000, 200)
+	m.RecordLLMTokens("chat", 200, 100)
+
+	for i, c := range counters {
+		if got, _ := gatheredValue(t, c.name, c.labels); got-before[i] != c.want {
+			t.Errorf("%s%v rose by %v, want %v", c.name, c.labels, got-before[i], c.want)
+		}
+	}
+}
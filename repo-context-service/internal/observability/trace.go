@@ -2,17 +2,36 @@ package observability
 
 import (
 	"context"
-
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
 )
 
 const TracerName = "repo-context-service"
 
-// Stub implementations for tracing - no OpenTelemetry dependency
-
-type Tracer struct{}
+// Tracer wraps an OpenTelemetry tracer behind the helpers used across the service
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
 
-type Span struct{}
+// Span wraps an OpenTelemetry span. The zero value is a valid no-op span.
+type Span struct {
+	span trace.Span
+}
 
 type Attribute struct {
 	Key   string
@@ -21,38 +40,105 @@ type Attribute struct {
 
 // NewNoOpTracer creates a tracer that does nothing
 func NewNoOpTracer() *Tracer {
-	return &Tracer{}
+	return NewTracerFromProvider(noop.NewTracerProvider())
 }
 
+// NewTracerFromProvider creates a tracer backed by the given provider
+func NewTracerFromProvider(provider trace.TracerProvider) *Tracer {
+	return &Tracer{
+		tracer:     provider.Tracer(TracerName),
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+}
+
+// NewTracer sets up an OTLP/HTTP exporter sending spans to otlpEndpoint
+// (e.g. http://localhost:4318/v1/traces). The returned func flushes and shuts it down.
 func NewTracer(serviceName, serviceVersion, otlpEndpoint string) (*Tracer, func(), error) {
-	// Return a no-op tracer to avoid dependency conflicts
-	return &Tracer{}, func() {}, nil
+	ctx := context.Background()
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(otlpEndpoint))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(serviceVersion),
+	))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	tracer := NewTracerFromProvider(provider)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(tracer.propagator)
+
+	cleanup := func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		provider.Shutdown(shutdownCtx)
+	}
+
+	return tracer, cleanup, nil
 }
 
+// Start starts a span; opts may contain trace.SpanStartOption values
 func (t *Tracer) Start(ctx context.Context, name string, opts ...interface{}) (context.Context, *Span) {
-	return ctx, &Span{}
+	var startOpts []trace.SpanStartOption
+	for _, opt := range opts {
+		if o, ok := opt.(trace.SpanStartOption); ok {
+			startOpts = append(startOpts, o)
+		}
+	}
+
+	ctx, span := t.tracer.Start(ctx, name, startOpts...)
+	return ctx, &Span{span: span}
 }
 
 // Helper methods for common tracing patterns
 
 func (t *Tracer) StartRPC(ctx context.Context, method string) (context.Context, *Span) {
-	return ctx, &Span{}
+	ctx, span := t.Start(ctx, "rpc."+method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("rpc.method", method)),
+	)
+	setRequestIDAttr(ctx, span)
+	return ctx, span
 }
 
 func (t *Tracer) StartBackendCall(ctx context.Context, backend, operation string) (context.Context, *Span) {
-	return ctx, &Span{}
+	return t.Start(ctx, backend+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(toOTelAttribute(BackendAttr(backend))),
+	)
 }
 
 func (t *Tracer) StartIngestion(ctx context.Context, repoID, phase string) (context.Context, *Span) {
-	return ctx, &Span{}
+	return t.Start(ctx, "ingest."+phase,
+		trace.WithAttributes(toOTelAttribute(RepositoryAttr(repoID))),
+	)
 }
 
 func (t *Tracer) StartSearch(ctx context.Context, query string, backend string) (context.Context, *Span) {
-	return ctx, &Span{}
+	return t.Start(ctx, "search."+backend,
+		trace.WithAttributes(
+			toOTelAttribute(QueryAttr(query)),
+			toOTelAttribute(BackendAttr(backend)),
+		),
+	)
 }
 
 func (t *Tracer) StartLLMCall(ctx context.Context, model string) (context.Context, *Span) {
-	return ctx, &Span{}
+	return t.Start(ctx, "llm.compose",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(toOTelAttribute(ModelAttr(model))),
+	)
 }
 
 // gRPC interceptors for automatic tracing
@@ -64,7 +150,14 @@ func (t *Tracer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		return handler(ctx, req)
+		ctx = t.extractTraceContext(ctx)
+		ctx, span := t.Start(ctx, info.FullMethod, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+		setRequestIDAttr(ctx, span)
+
+		resp, err := handler(ctx, req)
+		recordRPCResult(span, err)
+		return resp, err
 	}
 }
 
@@ -75,24 +168,127 @@ func (t *Tracer) StreamServerInterceptor() grpc.StreamServerInterceptor {
 		info *grpc.StreamServerInfo,
 		handler grpc.StreamHandler,
 	) error {
-		return handler(srv, stream)
+		ctx := t.extractTraceContext(stream.Context())
+		ctx, span := t.Start(ctx, info.FullMethod, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+		setRequestIDAttr(ctx, span)
+
+		err := handler(srv, &tracedStream{ServerStream: stream, ctx: ctx})
+		recordRPCResult(span, err)
+		return err
+	}
+}
+
+// tracedStream carries the span context to streaming handlers
+type tracedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedStream) Context() context.Context {
+	return s.ctx
+}
+
+// setRequestIDAttr tags span with the request ID in ctx, if there is one
+func setRequestIDAttr(ctx context.Context, span *Span) {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		span.SetAttributes(RequestIDAttr(requestID))
 	}
 }
 
+func recordRPCResult(span *Span, err error) {
+	if err == nil {
+		RecordSuccess(span, "")
+		return
+	}
+	span.SetAttributes(Attribute{Key: "rpc.grpc.status_code", Value: grpcstatus.Code(err).String()})
+	RecordError(span, err, err.Error())
+}
+
 // Helper for extracting trace context from gRPC metadata
 func (t *Tracer) extractTraceContext(ctx context.Context) context.Context {
-	return ctx
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return t.propagator.Extract(ctx, metadataCarrier(md))
 }
 
-// Span methods (no-op)
+// metadataCarrier adapts gRPC metadata to a propagation.TextMapCarrier
+type metadataCarrier metadata.MD
 
-func (s *Span) End() {}
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Span methods
+
+func (s *Span) End() {
+	if s.span != nil {
+		s.span.End()
+	}
+}
+
+func (s *Span) RecordError(err error) {
+	if s.span != nil && err != nil {
+		s.span.RecordError(err)
+	}
+}
 
-func (s *Span) RecordError(err error) {}
+// SetStatus sets the span status; code is an OpenTelemetry codes.Code value
+func (s *Span) SetStatus(code int, description string) {
+	if s.span != nil {
+		s.span.SetStatus(codes.Code(code), description)
+	}
+}
 
-func (s *Span) SetStatus(code int, description string) {}
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s.span == nil {
+		return
+	}
+	kvs := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		kvs[i] = toOTelAttribute(attr)
+	}
+	s.span.SetAttributes(kvs...)
+}
 
-func (s *Span) SetAttributes(attrs ...Attribute) {}
+func toOTelAttribute(attr Attribute) attribute.KeyValue {
+	switch v := attr.Value.(type) {
+	case string:
+		return attribute.String(attr.Key, v)
+	case int:
+		return attribute.Int(attr.Key, v)
+	case int32:
+		return attribute.Int(attr.Key, int(v))
+	case int64:
+		return attribute.Int64(attr.Key, v)
+	case float32:
+		return attribute.Float64(attr.Key, float64(v))
+	case float64:
+		return attribute.Float64(attr.Key, v)
+	case bool:
+		return attribute.Bool(attr.Key, v)
+	default:
+		return attribute.String(attr.Key, fmt.Sprint(v))
+	}
+}
 
 // Helper functions
 
@@ -106,15 +302,16 @@ func truncateString(s string, maxLen int) string {
 // Span utilities
 
 func SetSpanAttributes(span *Span, attrs ...Attribute) {
-	// No-op
+	span.SetAttributes(attrs...)
 }
 
 func RecordError(span *Span, err error, description string) {
-	// No-op
+	span.RecordError(err)
+	span.SetStatus(int(codes.Error), description)
 }
 
 func RecordSuccess(span *Span, description string) {
-	// No-op
+	span.SetStatus(int(codes.Ok), description)
 }
 
 // Common attribute constructors
@@ -123,6 +320,10 @@ func RepositoryAttr(repoID string) Attribute {
 	return Attribute{Key: "repository.id", Value: repoID}
 }
 
+func RequestIDAttr(requestID string) Attribute {
+	return Attribute{Key: "request.id", Value: requestID}
+}
+
 func TenantAttr(tenantID string) Attribute {
 	return Attribute{Key: "tenant.id", Value: tenantID}
 }
@@ -149,4 +350,12 @@ func FilePathAttr(path string) Attribute {
 
 func ModelAttr(model string) Attribute {
 	return Attribute{Key: "model.name", Value: model}
-}
\ No newline at end of file
+}
+
+func TrimmedCountAttr(count int) Attribute {
+	return Attribute{Key: "index.trimmed_count", Value: count}
+}
+
+func TruncatedAttr(truncated bool) Attribute {
+	return Attribute{Key: "search.truncated", Value: truncated}
+}
@@ -0,0 +1,79 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptorRecordsSpan(t *testing.T) {
+	const parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus codes.Code
+		wantCode   string
+	}{
+		{"success", nil, codes.Ok, ""},
+		{"failure", status.Error(grpccodes.NotFound, "no such repository"), codes.Error, "NotFound"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			defer provider.Shutdown(context.Background())
+			tracer := NewTracerFromProvider(provider)
+
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+				"traceparent", "00-"+parentTraceID+"-00f067aa0ba902b7-01"))
+			ctx = ContextWithRequestID(ctx, "req-1")
+			info := &grpc.UnaryServerInfo{FullMethod: "/repocontext.v1.RepositoryService/GetRepository"}
+			var handlerSpan trace.SpanContext
+			_, err := tracer.UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				handlerSpan = trace.SpanContextFromContext(ctx)
+				return nil, tt.err
+			})
+			if err != tt.err {
+				t.Fatalf("interceptor error = %v, want the handler's %v", err, tt.err)
+			}
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("recorded %d spans, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name != info.FullMethod || span.SpanKind != trace.SpanKindServer {
+				t.Errorf("span = %q kind %v, want a server span named %q", span.Name, span.SpanKind, info.FullMethod)
+			}
+			if span.SpanContext.TraceID().String() != parentTraceID {
+				t.Errorf("trace ID = %s, want the caller's %s", span.SpanContext.TraceID(), parentTraceID)
+			}
+			if handlerSpan.SpanID() != span.SpanContext.SpanID() {
+				t.Error("handler did not run inside the RPC span")
+			}
+			if span.Status.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", span.Status.Code, tt.wantStatus)
+			}
+
+			attrs := make(map[string]string)
+			for _, attr := range span.Attributes {
+				attrs[string(attr.Key)] = attr.Value.Emit()
+			}
+			if attrs["request.id"] != "req-1" {
+				t.Errorf("request.id attribute = %q, want req-1", attrs["request.id"])
+			}
+			if attrs["rpc.grpc.status_code"] != tt.wantCode {
+				t.Errorf("rpc.grpc.status_code attribute = %q, want %q", attrs["rpc.grpc.status_code"], tt.wantCode)
+			}
+		})
+	}
+}
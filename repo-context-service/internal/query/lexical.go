@@ -0,0 +1,324 @@
+package query
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrSearchTimeout is returned when a lexical search runs past its deadline
+var ErrSearchTimeout = errors.New("lexical search timed out")
+
+// ErrInvalidFilePath is returned for paths that aren't a file inside the repository
+var ErrInvalidFilePath = errors.New("invalid file path")
+
+// ErrLineRangeOutOfBounds is returned when a requested line range is past the end of
+// the file
+var ErrLineRangeOutOfBounds = errors.New("line range out of bounds")
+
+// ErrFileTooLarge is returned when the lines read from a file add up to more than
+// MaxReadFileBytes
+var ErrFileTooLarge = errors.New("file too large")
+
+// ErrBinaryFile is returned for files that aren't UTF-8 text
+var ErrBinaryFile = errors.New("binary file")
+
+// ErrNotebookLineRange is returned for line ranges of Jupyter notebooks. Their
+// chunks number the lines of the cell sources, which don't match the lines of the
+// .ipynb JSON, so only whole notebooks are read.
+var ErrNotebookLineRange = errors.New("line ranges aren't supported for notebooks")
+
+// MaxReadFileBytes bounds the content ReadFile returns, keeping responses under
+// gRPC's default 4MB message limit
+const MaxReadFileBytes = 3 << 20
+
+// binarySniffBytes is how much of a file is checked for NUL bytes, as git does
+const binarySniffBytes = 8000
+
+// LexicalClient runs keyword searches against the extracted repository working copies
+type LexicalClient interface {
+	SearchLexical(ctx context.Context, repoID, query string, limit int, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error)
+	ReadFile(ctx context.Context, repoID, path string, startLine, endLine int) (*repocontextv1.CodeChunk, error)
+	HealthCheck(ctx context.Context) error
+}
+
+// NewLexicalClient returns the lexical backend selected by cfg. In "auto" mode the
+// ripgrep client is used when rg runs, and the native searcher otherwise. Each
+// search is cut off after searchTimeout.
+func NewLexicalClient(cfg config.LexicalConfig, searchTimeout time.Duration, metrics *observability.Metrics, tracer *observability.Tracer, workDir string) (LexicalClient, error) {
+	synonyms, err := LoadSynonyms(cfg.SynonymsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Backend {
+	case "ripgrep":
+		return NewRipgrepClient(cfg, searchTimeout, metrics, tracer, workDir, synonyms), nil
+	case "native":
+		return NewNativeSearchClient(cfg, searchTimeout, metrics, tracer, workDir, synonyms), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := exec.CommandContext(ctx, "rg", "--version").Run(); err != nil {
+		slog.Info("NewLexicalClient: ripgrep unavailable, using native lexical search", "error", err)
+		return NewNativeSearchClient(cfg, searchTimeout, metrics, tracer, workDir, synonyms), nil
+	}
+	return NewRipgrepClient(cfg, searchTimeout, metrics, tracer, workDir, synonyms), nil
+}
+
+// LoadSynonyms returns the built-in fuzzy-matching synonyms with the entries of the
+// JSON or YAML file at path merged over them; a term in the file replaces the
+// built-in expansions for that term. An empty path returns the built-in map.
+func LoadSynonyms(path string) (map[string][]string, error) {
+	synonyms := make(map[string][]string, len(defaultSynonyms))
+	for term, expansions := range defaultSynonyms {
+		synonyms[term] = expansions
+	}
+	if path == "" {
+		return synonyms, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read synonyms file: %w", err)
+	}
+
+	// YAML is a superset of JSON, so one decoder handles both formats
+	var custom map[string][]string
+	if err := yaml.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("failed to parse synonyms file %s: %w", path, err)
+	}
+
+	// Query terms are lowercased before lookup
+	for term, expansions := range custom {
+		synonyms[strings.ToLower(term)] = expansions
+	}
+
+	return synonyms, nil
+}
+
+// lexicalFlags are the exact-match switches read from the search filters
+type lexicalFlags struct {
+	regex         bool
+	caseSensitive bool
+	wholeWord     bool
+}
+
+func lexicalFlagsFrom(filters map[string]interface{}) lexicalFlags {
+	var flags lexicalFlags
+	flags.regex, _ = filters["regex"].(bool)
+	flags.caseSensitive, _ = filters["case_sensitive"].(bool)
+	flags.wholeWord, _ = filters["whole_word"].(bool)
+	return flags
+}
+
+// exact reports whether the query is searched as given rather than fuzzy-expanded
+func (f lexicalFlags) exact() bool {
+	return f.regex || f.caseSensitive || f.wholeWord
+}
+
+// lexicalContextLines returns the per-query "context_lines" filter, or fallback when
+// the query doesn't set one
+func lexicalContextLines(filters map[string]interface{}, fallback int) int {
+	if lines, ok := filters["context_lines"].(int); ok && lines >= 0 {
+		return lines
+	}
+	return fallback
+}
+
+// lexicalPattern returns the search regex for query: the fuzzy term expansion by
+// default, or the query itself, as a regex or quoted literal, when flags ask for
+// an exact match
+func lexicalPattern(query string, flags lexicalFlags, synonyms map[string][]string) (string, error) {
+	if !flags.exact() {
+		return queryToRegex(query, synonyms)
+	}
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("empty query")
+	}
+	if flags.regex {
+		if _, err := regexp.Compile(query); err != nil {
+			return "", fmt.Errorf("invalid regex: %w", err)
+		}
+		return query, nil
+	}
+	return regexp.QuoteMeta(query), nil
+}
+
+// hasWorkingTree reports whether repoID has a working copy under workDir.
+// Repositories loaded with ImportIndex have none until they are reindexed.
+func hasWorkingTree(workDir, repoID string) bool {
+	info, err := os.Stat(filepath.Join(workDir, repoID))
+	return err == nil && info.IsDir()
+}
+
+// readRepositoryFile returns lines startLine..endLine (1-based, inclusive) of a file
+// in the repository working copy under workDir. An endLine of 0 reads to the end.
+// Binary files fail with ErrBinaryFile, and ranges over MaxReadFileBytes with
+// ErrFileTooLarge. Paths are relative to the repository root; ones that lead outside it, directly or
+// through a symlink, fail with ErrInvalidFilePath.
+func readRepositoryFile(workDir, repoID, path string, startLine, endLine int) (*repocontextv1.CodeChunk, error) {
+	repoPath := filepath.Join(workDir, repoID)
+	fullPath, err := resolveRepositoryPath(repoPath, path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if startLine < 1 {
+		startLine = 1
+	}
+	if isNotebookPath(path) && (startLine > 1 || endLine > 0) {
+		return nil, fmt.Errorf("%w: %s", ErrNotebookLineRange, path)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%w: %s is a directory", ErrInvalidFilePath, path)
+	}
+	// Whole-file reads are refused before reading anything
+	if startLine == 1 && endLine == 0 && info.Size() > MaxReadFileBytes {
+		return nil, fmt.Errorf("%w: %s is %d bytes; request a line range", ErrFileTooLarge, path, info.Size())
+	}
+
+	reader := bufio.NewReader(file)
+	if head, _ := reader.Peek(binarySniffBytes); bytes.IndexByte(head, 0) >= 0 {
+		return nil, fmt.Errorf("%w: %s", ErrBinaryFile, path)
+	}
+
+	var lines []string
+	lineNum := 0
+	size := 0
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lineNum++
+		if lineNum < startLine {
+			continue
+		}
+		if endLine > 0 && lineNum > endLine {
+			break
+		}
+		line := scanner.Text()
+		if size += len(line) + 1; size > MaxReadFileBytes {
+			return nil, fmt.Errorf("%w: lines %d-%d of %s are over %d bytes; request a smaller range", ErrFileTooLarge, startLine, lineNum, path, MaxReadFileBytes)
+		}
+		if !utf8.ValidString(line) {
+			return nil, fmt.Errorf("%w: %s is not valid UTF-8", ErrBinaryFile, path)
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// An empty file still reads as empty from the start
+	if len(lines) == 0 && (lineNum > 0 || startLine > 1) {
+		return nil, fmt.Errorf("%w: no lines in range %d-%d of %s", ErrLineRangeOutOfBounds, startLine, endLine, path)
+	}
+
+	relPath := strings.TrimPrefix(fullPath, repoPath+string(filepath.Separator))
+	return &repocontextv1.CodeChunk{
+		RepositoryId: repoID,
+		FilePath:     relPath,
+		StartLine:    int32(startLine),
+		EndLine:      int32(startLine + len(lines) - 1),
+		Content:      strings.Join(lines, "\n"),
+		Language:     detectLanguageFromPath(relPath),
+		Source:       repocontextv1.SearchSource_SEARCH_SOURCE_LEXICAL,
+	}, nil
+}
+
+// isNotebookPath reports whether path is a Jupyter notebook, which ingestion chunks
+// by cell source rather than by file line
+func isNotebookPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ipynb")
+}
+
+// resolveRepositoryPath joins a repository-relative path onto repoPath. A leading "/"
+// is taken as the repository root. Paths that climb out with "..", or whose target
+// is a symlink outside the repository, are rejected.
+func resolveRepositoryPath(repoPath, path string) (string, error) {
+	cleaned := filepath.Clean(strings.TrimLeft(filepath.ToSlash(path), "/"))
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: %s", ErrInvalidFilePath, path)
+	}
+	fullPath := filepath.Join(repoPath, filepath.FromSlash(cleaned))
+
+	// Archives can contain symlinks; follow them and check where they land
+	resolved, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		// Missing files fail when they're opened
+		return fullPath, nil
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(repoPath)
+	if err != nil {
+		return fullPath, nil
+	}
+	if !strings.HasPrefix(resolved, resolvedRoot+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidFilePath, path)
+	}
+	return fullPath, nil
+}
+
+// ExpandContext widens each chunk by lines of surrounding code read from the
+// repository working copy, like ripgrep's --context. Chunks whose file can't be
+// read, and notebook chunks, whose lines aren't file lines, are kept as they are.
+func ExpandContext(ctx context.Context, reader LexicalClient, chunks []*repocontextv1.CodeChunk, lines int) []*repocontextv1.CodeChunk {
+	if lines <= 0 {
+		return chunks
+	}
+
+	expanded := make([]*repocontextv1.CodeChunk, len(chunks))
+	for i, chunk := range chunks {
+		expanded[i] = chunk
+		if isNotebookPath(chunk.FilePath) {
+			continue
+		}
+
+		startLine := int(chunk.StartLine) - lines
+		if startLine < 1 {
+			startLine = 1
+		}
+		withContext, err := reader.ReadFile(ctx, chunk.RepositoryId, chunk.FilePath, startLine, int(chunk.EndLine)+lines)
+		if err != nil {
+			observability.Logger(ctx).Debug("ExpandContext: Keeping chunk without context", "path", chunk.FilePath, "start_line", chunk.StartLine, "end_line", chunk.EndLine, "error", err)
+			continue
+		}
+
+		// Keep the search metadata; only the line range and content change
+		widened := proto.Clone(chunk).(*repocontextv1.CodeChunk)
+		widened.StartLine = withContext.StartLine
+		widened.EndLine = withContext.EndLine
+		widened.Content = withContext.Content
+		expanded[i] = widened
+	}
+
+	return expanded
+}
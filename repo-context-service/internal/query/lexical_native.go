@@ -0,0 +1,368 @@
+package query
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+// binarySniffSize is how much of a file is checked for NUL bytes, as ripgrep does
+const binarySniffSize = 8 * 1024
+
+// NativeSearchClient is a pure-Go lexical searcher for hosts without ripgrep. It
+// matches the same patterns and filters as RipgrepClient by scanning files line by
+// line; like ripgrep it skips hidden and binary files, but it doesn't read .gitignore.
+type NativeSearchClient struct {
+	config     config.LexicalConfig
+	timeout    time.Duration
+	metrics    *observability.Metrics
+	tracer     *observability.Tracer
+	workDir    string
+	maxMatches int
+	synonyms   map[string][]string
+}
+
+func NewNativeSearchClient(cfg config.LexicalConfig, timeout time.Duration, metrics *observability.Metrics, tracer *observability.Tracer, workDir string, synonyms map[string][]string) *NativeSearchClient {
+	return &NativeSearchClient{
+		config:     cfg,
+		timeout:    timeout,
+		metrics:    metrics,
+		tracer:     tracer,
+		workDir:    workDir,
+		maxMatches: 1000, // Per file and in total, as for ripgrep
+		synonyms:   synonyms,
+	}
+}
+
+func (n *NativeSearchClient) SearchLexical(ctx context.Context, repoID, query string, limit int, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	ctx, span := n.tracer.StartSearch(ctx, query, "lexical")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("native"),
+		observability.RepositoryAttr(repoID),
+		observability.QueryAttr(query),
+	)
+
+	timer := observability.StartTimer()
+	defer func() {
+		n.metrics.RecordBackendLatency("native", timer.Duration())
+	}()
+
+	// Without a working copy there is nothing to search lexically
+	if !hasWorkingTree(n.workDir, repoID) {
+		n.metrics.RecordSearchResults("lexical", 0)
+		return nil, nil
+	}
+
+	flags := lexicalFlagsFrom(filters)
+	pattern, err := lexicalPattern(query, flags, n.synonyms)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert query to regex: %w", err)
+	}
+	if flags.wholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	// Smart case, as ripgrep does it: case-insensitive unless the pattern has capitals
+	if !flags.caseSensitive && !hasUppercase(pattern) {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search pattern: %w", err)
+	}
+
+	contextLines := lexicalContextLines(filters, n.config.ContextLines)
+	filter := newPathFilter(filters)
+	repoPath := filepath.Join(n.workDir, repoID)
+
+	// Bound the search so a pathological pattern can't run indefinitely
+	searchCtx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	var matches []*repocontextv1.CodeChunk
+	capped := false
+	err = filepath.WalkDir(repoPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctxErr := searchCtx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if len(matches) >= n.maxMatches {
+			capped = true
+			return fs.SkipAll
+		}
+
+		if path != repoPath && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(repoPath, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if !filter.matches(relPath) {
+			return nil
+		}
+
+		fileMatches, err := n.searchFile(path, relPath, repoID, re, contextLines)
+		if err != nil {
+			return fmt.Errorf("failed to search %s: %w", relPath, err)
+		}
+		matches = append(matches, fileMatches...)
+		return nil
+	})
+	switch {
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case searchCtx.Err() == context.DeadlineExceeded:
+		return nil, fmt.Errorf("%w after %s", ErrSearchTimeout, n.timeout)
+	case err != nil:
+		return nil, fmt.Errorf("native search failed: %w", err)
+	case capped:
+		observability.SetSpanAttributes(span, observability.TruncatedAttr(true))
+	}
+
+	chunks := rankLexicalChunks(groupMatchChunks(matches), query, limit)
+
+	n.metrics.RecordSearchResults("lexical", len(chunks))
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(len(chunks)),
+	)
+
+	return chunks, nil
+}
+
+// searchFile returns a chunk for each line of the file matching re, widened by up
+// to contextLines lines on each side. Binary files yield no matches.
+func (n *NativeSearchClient) searchFile(path, relPath, repoID string, re *regexp.Regexp, contextLines int) ([]*repocontextv1.CodeChunk, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	head, err := reader.Peek(binarySniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+
+	language := detectLanguageFromPath(relPath)
+
+	// Lines are only kept when they're needed for context
+	var lines []string
+	var matches []*repocontextv1.CodeChunk
+	lineNum := 0
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if contextLines > 0 {
+			lines = append(lines, line)
+		}
+		if len(matches) >= n.maxMatches {
+			if contextLines == 0 || lineNum > int(matches[len(matches)-1].StartLine)+contextLines {
+				break
+			}
+			continue
+		}
+		found := re.FindAllStringIndex(line, -1)
+		if len(found) == 0 {
+			continue
+		}
+		highlights := make([]*repocontextv1.HighlightRange, 0, len(found))
+		for _, loc := range found {
+			if loc[0] < loc[1] {
+				highlights = append(highlights, &repocontextv1.HighlightRange{
+					Line:  int32(lineNum),
+					Start: int32(loc[0]),
+					End:   int32(loc[1]),
+				})
+			}
+		}
+		matches = append(matches, &repocontextv1.CodeChunk{
+			RepositoryId: repoID,
+			FilePath:     relPath,
+			StartLine:    int32(lineNum),
+			EndLine:      int32(lineNum),
+			Content:      line,
+			Language:     language,
+			Source:       repocontextv1.SearchSource_SEARCH_SOURCE_LEXICAL,
+			Score:        1.0, // Calculated when ranking
+			Highlights:   highlights,
+		})
+	}
+	if err := scanner.Err(); err != nil && err != bufio.ErrTooLong {
+		return nil, err
+	}
+
+	for _, match := range matches {
+		widenMatch(match, contextLines, func(lineNum int) (string, bool) {
+			if lineNum < 1 || lineNum > len(lines) {
+				return "", false
+			}
+			return lines[lineNum-1], true
+		})
+	}
+
+	return matches, nil
+}
+
+func (n *NativeSearchClient) ReadFile(ctx context.Context, repoID, path string, startLine, endLine int) (*repocontextv1.CodeChunk, error) {
+	return readRepositoryFile(n.workDir, repoID, path, startLine, endLine)
+}
+
+// HealthCheck verifies the repository storage directory is readable
+func (n *NativeSearchClient) HealthCheck(ctx context.Context) error {
+	info, err := os.Stat(n.workDir)
+	if err != nil {
+		return fmt.Errorf("repository storage unavailable: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("repository storage %s is not a directory", n.workDir)
+	}
+	return nil
+}
+
+func hasUppercase(s string) bool {
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathFilter applies the search filters that RipgrepClient turns into --type and
+// --glob arguments
+type pathFilter struct {
+	languages  map[string]bool
+	include    []string
+	exclude    []string
+	pathPrefix string
+}
+
+func newPathFilter(filters map[string]interface{}) *pathFilter {
+	f := &pathFilter{}
+
+	// Only languages ripgrep has a type for restrict the search, as with --type
+	if languages, ok := filters["languages"].([]string); ok {
+		for _, lang := range languages {
+			if mapLanguageToRipgrepType(lang) == "" {
+				continue
+			}
+			if f.languages == nil {
+				f.languages = make(map[string]bool)
+			}
+			f.languages[lang] = true
+		}
+	}
+
+	if patterns, ok := filters["file_patterns"].([]string); ok {
+		for _, pattern := range patterns {
+			if strings.HasPrefix(pattern, "!") {
+				f.exclude = append(f.exclude, strings.TrimPrefix(pattern, "!"))
+			} else {
+				f.include = append(f.include, pattern)
+			}
+		}
+	}
+
+	if pathPrefix, ok := filters["path_prefix"].(string); ok {
+		f.pathPrefix = pathPrefix
+	}
+
+	return f
+}
+
+func (f *pathFilter) matches(relPath string) bool {
+	if f.languages != nil && !f.languages[detectLanguageFromPath(relPath)] {
+		return false
+	}
+	if f.pathPrefix != "" && !strings.HasPrefix(relPath, f.pathPrefix) {
+		return false
+	}
+	for _, pattern := range f.exclude {
+		if matchGlob(pattern, relPath) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, pattern := range f.include {
+		if matchGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches patterns without a slash against the file name and the rest
+// against the whole relative path, like ripgrep's --glob. As there, "**" matches
+// any number of directories.
+func matchGlob(pattern, relPath string) bool {
+	target := relPath
+	if !strings.Contains(pattern, "/") {
+		target = filepath.Base(relPath)
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	if strings.Contains(pattern, "**") {
+		re, err := regexp.Compile(globToRegex(pattern))
+		return err == nil && re.MatchString(target)
+	}
+	matched, _ := filepath.Match(pattern, target)
+	return matched
+}
+
+// globToRegex translates a glob with "**" segments into an anchored regex; "*" and
+// "?" don't cross a "/"
+func globToRegex(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,129 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+)
+
+// writeRepository writes files, keyed by slash-separated path, into a working copy
+// of repoID under workDir
+func writeRepository(t *testing.T, workDir, repoID string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(workDir, repoID, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNativeSearchLexical(t *testing.T) {
+	workDir := t.TempDir()
+	writeRepository(t, workDir, "repo-1", map[string]string{
+		"main.go":                 "package main\n\n// NewWidget builds a widget\nfunc NewWidget() {}\n",
+		"internal/api/handler.go": "package api\n\nvar widget = 1\n",
+		"README.md":               "# Widget\n",
+		"notes.txt":               "nothing to see\n",
+		".cache/widget.go":        "package cache // widget\n",
+		"widget.bin":              "widget\x00\x01",
+	})
+	client := NewNativeSearchClient(config.LexicalConfig{}, time.Second, observability.NewMetrics(), observability.NewNoOpTracer(), workDir, nil)
+
+	tests := []struct {
+		name    string
+		filters map[string]interface{}
+		want    []string
+	}{
+		// Hidden and binary files are skipped, as ripgrep skips them
+		{"no filters", nil, []string{"README.md:1", "internal/api/handler.go:3", "main.go:3"}},
+		{"path prefix", map[string]interface{}{"path_prefix": "internal/"}, []string{"internal/api/handler.go:3"}},
+		{"languages", map[string]interface{}{"languages": []string{"go"}}, []string{"internal/api/handler.go:3", "main.go:3"}},
+		{"file patterns", map[string]interface{}{"file_patterns": []string{"*.go", "!internal/**"}}, []string{"main.go:3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := client.SearchLexical(context.Background(), "repo-1", "widget", 10, tt.filters)
+			if err != nil {
+				t.Fatalf("SearchLexical error = %v", err)
+			}
+
+			var got []string
+			for _, chunk := range chunks {
+				if chunk.RepositoryId != "repo-1" || !strings.Contains(strings.ToLower(chunk.Content), "widget") {
+					t.Errorf("chunk %s:%d = %q in %q, want a widget match in repo-1", chunk.FilePath, chunk.StartLine, chunk.Content, chunk.RepositoryId)
+				}
+				got = append(got, fmt.Sprintf("%s:%d", chunk.FilePath, chunk.StartLine))
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLexicalClientFallsBackToNative(t *testing.T) {
+	// Without rg on the PATH, auto mode picks the native searcher
+	t.Setenv("PATH", t.TempDir())
+	metrics := observability.NewMetrics()
+	tracer := observability.NewNoOpTracer()
+
+	for _, backend := range []string{"auto", "native"} {
+		client, err := NewLexicalClient(config.LexicalConfig{Backend: backend}, time.Second, metrics, tracer, t.TempDir())
+		if err != nil {
+			t.Fatalf("NewLexicalClient(%s) error = %v", backend, err)
+		}
+		if _, ok := client.(*NativeSearchClient); !ok {
+			t.Errorf("NewLexicalClient(%s) = %T, want the native searcher", backend, client)
+		}
+	}
+
+	// A forced ripgrep backend is kept even when rg is missing
+	client, err := NewLexicalClient(config.LexicalConfig{Backend: "ripgrep"}, time.Second, metrics, tracer, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLexicalClient(ripgrep) error = %v", err)
+	}
+	if _, ok := client.(*RipgrepClient); !ok {
+		t.Errorf("NewLexicalClient(ripgrep) = %T, want the ripgrep client", client)
+	}
+}
+
+func TestNativeSearchHighlights(t *testing.T) {
+	workDir := t.TempDir()
+	writeRepository(t, workDir, "repo-1", map[string]string{
+		"main.go": "package main\n\nfunc handleConfig(cfg Config) {\n\t// load it\n\treturn loadCONFIG()\n}\n",
+	})
+	client := NewNativeSearchClient(config.LexicalConfig{}, time.Second, observability.NewMetrics(), observability.NewNoOpTracer(), workDir, nil)
+
+	chunks, err := client.SearchLexical(context.Background(), "repo-1", "config", 10, map[string]interface{}{"context_lines": 1})
+	if err != nil {
+		t.Fatalf("SearchLexical error = %v", err)
+	}
+
+	if len(chunks) != 1 || chunks[0].StartLine != 2 || chunks[0].EndLine != 6 {
+		t.Fatalf("got chunks %v, want one for lines 2-6", chunks)
+	}
+	var lines []int32
+	for _, h := range chunks[0].Highlights {
+		lines = append(lines, h.Line)
+	}
+	if fmt.Sprint(lines) != "[3 3 5]" {
+		t.Errorf("highlighted lines = %v, want both matches on line 3 and the one on line 5", lines)
+	}
+	// The fuzzy pattern matches whole words containing the term
+	if got := highlightedText(t, chunks[0]); fmt.Sprint(got) != "[handleConfig Config loadCONFIG]" {
+		t.Errorf("highlights cover %q, want the words matching config", got)
+	}
+}
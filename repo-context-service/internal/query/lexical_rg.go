@@ -2,24 +2,70 @@ package query
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/ingest"
 	"repo-context-service/internal/observability"
 	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
 )
 
+// defaultSynonyms are the built-in tech abbreviations and expansions used for fuzzy
+// term matching. LEXICAL_SYNONYMS_FILE entries are merged over them.
+var defaultSynonyms = map[string][]string{
+	"auth":           {"authentication", "authorization", "authorize", "authenticated", "authenticator"},
+	"authentication": {"auth", "authenticator", "authenticate"},
+	"authorization":  {"auth", "authorize", "authz"},
+	"config":         {"configuration", "configure", "conf"},
+	"configuration":  {"config", "conf"},
+	"db":             {"database", "data_base"},
+	"database":       {"db", "data_base"},
+	"api":            {"endpoint", "service", "rest", "graphql"},
+	"endpoint":       {"api", "route", "handler"},
+	"handler":        {"handle", "controller", "processor"},
+	"service":        {"svc", "server", "api"},
+	"server":         {"srv", "service", "daemon"},
+	"client":         {"cli", "consumer"},
+	"response":       {"resp", "result", "reply"},
+	"request":        {"req", "query", "input"},
+	"error":          {"err", "exception", "failure"},
+	"function":       {"func", "method", "procedure"},
+	"method":         {"func", "function"},
+	"variable":       {"var", "field", "property"},
+	"parameter":      {"param", "arg", "argument"},
+	"middleware":     {"middleware", "interceptor", "filter"},
+	"route":          {"router", "routing", "path"},
+	"controller":     {"ctrl", "handler", "processor"},
+	"model":          {"schema", "entity", "data"},
+	"view":           {"template", "render", "display"},
+	"user":           {"users", "account", "profile"},
+	"password":       {"pwd", "pass", "secret"},
+	"token":          {"jwt", "bearer", "session"},
+	"session":        {"sess", "cookie", "token"},
+}
+
+// maxRipgrepLineSize bounds a single line of ripgrep's JSON output
+const maxRipgrepLineSize = 4 * 1024 * 1024
+
 type RipgrepClient struct {
+	config     config.LexicalConfig
+	timeout    time.Duration
 	metrics    *observability.Metrics
 	tracer     *observability.Tracer
 	workDir    string
 	maxMatches int
+	synonyms   map[string][]string
 }
 
 type RipgrepMatch struct {
@@ -43,12 +89,15 @@ type RipgrepMatch struct {
 	} `json:"data"`
 }
 
-func NewRipgrepClient(metrics *observability.Metrics, tracer *observability.Tracer, workDir string) *RipgrepClient {
+func NewRipgrepClient(cfg config.LexicalConfig, timeout time.Duration, metrics *observability.Metrics, tracer *observability.Tracer, workDir string, synonyms map[string][]string) *RipgrepClient {
 	return &RipgrepClient{
+		config:     cfg,
+		timeout:    timeout,
 		metrics:    metrics,
 		tracer:     tracer,
 		workDir:    workDir,
-		maxMatches: 1000, // Prevent runaway searches
+		maxMatches: 1000, // Per file and in total; prevents runaway searches
+		synonyms:   synonyms,
 	}
 }
 
@@ -67,6 +116,12 @@ func (r *RipgrepClient) SearchLexical(ctx context.Context, repoID, query string,
 		r.metrics.RecordBackendLatency("ripgrep", timer.Duration())
 	}()
 
+	// Without a working copy there is nothing to search lexically
+	if !hasWorkingTree(r.workDir, repoID) {
+		r.metrics.RecordSearchResults("lexical", 0)
+		return nil, nil
+	}
+
 	// Build ripgrep command
 	args, err := r.buildRipgrepArgs(query, limit, filters)
 	if err != nil {
@@ -76,25 +131,52 @@ func (r *RipgrepClient) SearchLexical(ctx context.Context, repoID, query string,
 	// Set working directory to repository path
 	repoPath := filepath.Join(r.workDir, repoID)
 
-	// Execute ripgrep
-	cmd := exec.CommandContext(ctx, "rg", args...)
+	// Bound the search so a pathological pattern can't run indefinitely
+	searchCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// Execute ripgrep, reading its output as it's produced
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(searchCtx, "rg", args...)
 	cmd.Dir = repoPath
+	cmd.Stderr = &stderr
+	// Don't block on the output pipe for long once the process is killed
+	cmd.WaitDelay = time.Second
 
-	// Get output
-	output, err := cmd.Output()
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		// Ripgrep returns exit code 1 when no matches found, which is not an error
-		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
-			r.metrics.RecordSearchResults("lexical", 0)
-			return nil, nil
-		}
+		return nil, fmt.Errorf("failed to open ripgrep output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("ripgrep execution failed: %w", err)
 	}
 
-	// Parse results
-	chunks, err := r.parseRipgrepOutput(output, repoID, query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse ripgrep output: %w", err)
+	// Parse results; once maxMatches matches are in, the rest of the output is dropped
+	chunks, capped, parseErr := r.parseRipgrepOutput(stdout, repoID, query, limit, lexicalContextLines(filters, r.config.ContextLines))
+	if capped || parseErr != nil {
+		cancel()
+	}
+	err = cmd.Wait()
+
+	switch {
+	case capped:
+		// ripgrep was killed on purpose after enough matches
+		observability.SetSpanAttributes(span, observability.TruncatedAttr(true))
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case searchCtx.Err() == context.DeadlineExceeded:
+		return nil, fmt.Errorf("%w after %s", ErrSearchTimeout, r.timeout)
+	case parseErr != nil:
+		return nil, fmt.Errorf("failed to parse ripgrep output: %w", parseErr)
+	case err != nil:
+		// Ripgrep returns exit code 1 when no matches found, which is not an error.
+		// Filters that leave no files to search are reported with exit code 2.
+		if exitError, ok := err.(*exec.ExitError); ok && (exitError.ExitCode() == 1 ||
+			exitError.ExitCode() == 2 && strings.Contains(stderr.String(), noFilesSearched)) {
+			r.metrics.RecordSearchResults("lexical", 0)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ripgrep execution failed: %w: %s", err, strings.TrimSpace(stderr.String()))
 	}
 
 	r.metrics.RecordSearchResults("lexical", len(chunks))
@@ -106,17 +188,35 @@ func (r *RipgrepClient) SearchLexical(ctx context.Context, repoID, query string,
 	return chunks, nil
 }
 
+// ReadFile returns lines startLine..endLine (1-based, inclusive) of a file in the
+// repository working copy. An endLine of 0 reads to the end of the file.
+func (r *RipgrepClient) ReadFile(ctx context.Context, repoID, path string, startLine, endLine int) (*repocontextv1.CodeChunk, error) {
+	return readRepositoryFile(r.workDir, repoID, path, startLine, endLine)
+}
+
+// noFilesSearched is how ripgrep reports that its filters matched no files
+const noFilesSearched = "No files were searched"
+
 func (r *RipgrepClient) buildRipgrepArgs(query string, limit int, filters map[string]interface{}) ([]string, error) {
 	args := []string{
 		"--json",              // Output in JSON format
 		"--line-number",       // Include line numbers
 		"--column",            // Include column numbers
-		"--context", "2",      // Include 2 lines of context before/after
+		"--context", strconv.Itoa(lexicalContextLines(filters, r.config.ContextLines)), // Lines of context before/after
 		"--max-count", strconv.Itoa(r.maxMatches), // Limit matches per file
-		"--smart-case",        // Smart case matching
 		// Binary files are automatically skipped by ripgrep by default
 	}
 
+	flags := lexicalFlagsFrom(filters)
+	if flags.caseSensitive {
+		args = append(args, "--case-sensitive")
+	} else {
+		args = append(args, "--smart-case")
+	}
+	if flags.wholeWord {
+		args = append(args, "--word-regexp")
+	}
+
 	// Add language filters
 	if languages, ok := filters["languages"].([]string); ok && len(languages) > 0 {
 		for _, lang := range languages {
@@ -133,23 +233,25 @@ func (r *RipgrepClient) buildRipgrepArgs(query string, limit int, filters map[st
 		}
 	}
 
-	// Add path prefix filter
-	if pathPrefix, ok := filters["path_prefix"].(string); ok && pathPrefix != "" {
-		args = append(args, "--glob", pathPrefix+"*")
+	// Add path prefix filter. A * in a glob doesn't cross "/", so the directory is
+	// matched with ** to include its subdirectories, as the semantic backends do.
+	if pathPrefix, ok := filters["path_prefix"].(string); ok && strings.TrimSuffix(pathPrefix, "/") != "" {
+		args = append(args, "--glob", strings.TrimSuffix(pathPrefix, "/")+"/**")
 	}
 
 	// Convert query to regex pattern
-	pattern, err := r.queryToRegex(query)
+	pattern, err := lexicalPattern(query, flags, r.synonyms)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert query to regex: %w", err)
 	}
 
-	args = append(args, pattern)
+	// --regexp keeps patterns starting with a dash from being read as flags
+	args = append(args, "--regexp", pattern)
 
 	return args, nil
 }
 
-func (r *RipgrepClient) queryToRegex(query string) (string, error) {
+func queryToRegex(query string, synonyms map[string][]string) (string, error) {
 	// Split query into terms
 	terms := strings.Fields(query)
 	if len(terms) == 0 {
@@ -175,7 +277,7 @@ func (r *RipgrepClient) queryToRegex(query string) (string, error) {
 		}
 
 		// 3. Fuzzy matching for common abbreviations and variations
-		fuzzyPatterns := r.generateFuzzyPatterns(term)
+		fuzzyPatterns := generateFuzzyPatterns(term, synonyms)
 		termPatterns = append(termPatterns, fuzzyPatterns...)
 
 		// Combine all patterns for this term with OR logic
@@ -194,44 +296,11 @@ func (r *RipgrepClient) queryToRegex(query string) (string, error) {
 	return "(" + strings.Join(patterns, "|") + ")", nil
 }
 
-func (r *RipgrepClient) generateFuzzyPatterns(term string) []string {
+func generateFuzzyPatterns(term string, synonyms map[string][]string) []string {
 	var patterns []string
 
-	// Common tech abbreviations and expansions
-	fuzzyMap := map[string][]string{
-		"auth":           {"authentication", "authorization", "authorize", "authenticated", "authenticator"},
-		"authentication": {"auth", "authenticator", "authenticate"},
-		"authorization":  {"auth", "authorize", "authz"},
-		"config":         {"configuration", "configure", "conf"},
-		"configuration":  {"config", "conf"},
-		"db":             {"database", "data_base"},
-		"database":       {"db", "data_base"},
-		"api":            {"endpoint", "service", "rest", "graphql"},
-		"endpoint":       {"api", "route", "handler"},
-		"handler":        {"handle", "controller", "processor"},
-		"service":        {"svc", "server", "api"},
-		"server":         {"srv", "service", "daemon"},
-		"client":         {"cli", "consumer"},
-		"response":       {"resp", "result", "reply"},
-		"request":        {"req", "query", "input"},
-		"error":          {"err", "exception", "failure"},
-		"function":       {"func", "method", "procedure"},
-		"method":         {"func", "function"},
-		"variable":       {"var", "field", "property"},
-		"parameter":      {"param", "arg", "argument"},
-		"middleware":     {"middleware", "interceptor", "filter"},
-		"route":          {"router", "routing", "path"},
-		"controller":     {"ctrl", "handler", "processor"},
-		"model":          {"schema", "entity", "data"},
-		"view":           {"template", "render", "display"},
-		"user":           {"users", "account", "profile"},
-		"password":       {"pwd", "pass", "secret"},
-		"token":          {"jwt", "bearer", "session"},
-		"session":        {"sess", "cookie", "token"},
-	}
-
 	// Add fuzzy matches if term exists in map
-	if expansions, exists := fuzzyMap[term]; exists {
+	if expansions, exists := synonyms[term]; exists {
 		for _, expansion := range expansions {
 			pattern := "(?i)" + regexp.QuoteMeta(expansion)
 			patterns = append(patterns, pattern)
@@ -239,7 +308,7 @@ func (r *RipgrepClient) generateFuzzyPatterns(term string) []string {
 	}
 
 	// Also check if term is an expansion of something
-	for key, expansions := range fuzzyMap {
+	for key, expansions := range synonyms {
 		for _, expansion := range expansions {
 			if expansion == term {
 				pattern := "(?i)" + regexp.QuoteMeta(key)
@@ -263,25 +332,36 @@ func (r *RipgrepClient) generateFuzzyPatterns(term string) []string {
 	return patterns
 }
 
-func (r *RipgrepClient) parseRipgrepOutput(output []byte, repoID, query string) ([]*repocontextv1.CodeChunk, error) {
-	var chunks []*repocontextv1.CodeChunk
-	var chunkMap = make(map[string]*repocontextv1.CodeChunk)
+// parseRipgrepOutput groups matches, widened by up to contextLines of the context
+// lines ripgrep printed around them, into chunks and returns the top limit chunks
+// by relevance score. A limit of 0 or less returns every chunk. Reading stops after
+// maxMatches matches, which is reported as capped.
+func (r *RipgrepClient) parseRipgrepOutput(output io.Reader, repoID, query string, limit, contextLines int) ([]*repocontextv1.CodeChunk, bool, error) {
+	var matches []*repocontextv1.CodeChunk
+	fileLines := make(map[string]map[int]string)
+	capped := false
 
 	// Parse JSON lines
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
+	reader := bufio.NewReaderSize(output, 64*1024)
+	for {
+		line, err := nextJSONLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
 			continue
 		}
 
 		var match RipgrepMatch
-		if err := json.Unmarshal([]byte(line), &match); err != nil {
+		if err := json.Unmarshal(line, &match); err != nil {
 			continue // Skip invalid JSON lines
 		}
 
-		// Only process match lines
-		if match.Type != "match" {
+		// Only match and context lines carry file content
+		if match.Type != "match" && match.Type != "context" {
 			continue
 		}
 
@@ -290,42 +370,175 @@ func (r *RipgrepClient) parseRipgrepOutput(output []byte, repoID, query string)
 			continue
 		}
 
-		// Group matches by file and merge nearby matches
-		key := fmt.Sprintf("%s:%d", chunk.FilePath, chunk.StartLine/10) // Group by file and line region
-		if existingChunk, exists := chunkMap[key]; exists {
-			// Merge chunks from the same file region
-			existingChunk.Content += "\n" + chunk.Content
-			if chunk.EndLine > existingChunk.EndLine {
-				existingChunk.EndLine = chunk.EndLine
+		lines, ok := fileLines[chunk.FilePath]
+		if !ok {
+			lines = make(map[int]string)
+			fileLines[chunk.FilePath] = lines
+		}
+		lines[int(chunk.StartLine)] = chunk.Content
+
+		if match.Type == "match" {
+			matches = append(matches, chunk)
+			if len(matches) >= r.maxMatches {
+				capped = true
+				break
 			}
-			if chunk.StartLine < existingChunk.StartLine {
-				existingChunk.StartLine = chunk.StartLine
+		}
+	}
+
+	for _, chunk := range matches {
+		lines := fileLines[chunk.FilePath]
+		widenMatch(chunk, contextLines, func(lineNum int) (string, bool) {
+			text, ok := lines[lineNum]
+			return text, ok
+		})
+	}
+
+	return rankLexicalChunks(groupMatchChunks(matches), query, limit), capped, nil
+}
+
+// nextJSONLine returns the next line of ripgrep's output, skipping lines longer
+// than maxRipgrepLineSize whole, such as a match in a minified file, so one huge
+// line doesn't fail the search. It returns io.EOF after the last line.
+func nextJSONLine(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	oversized := false
+	for {
+		fragment, err := reader.ReadSlice('\n')
+		if !oversized {
+			if len(line)+len(fragment) > maxRipgrepLineSize {
+				oversized, line = true, nil
+			} else {
+				line = append(line, fragment...)
 			}
+		}
+
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err != nil && err != io.EOF:
+			return nil, err
+		case oversized && err == nil:
+			// Start over on the line after the skipped one
+			oversized = false
+			continue
+		case oversized || len(line) == 0:
+			return nil, io.EOF
+		default:
+			return line, nil
+		}
+	}
+}
+
+// widenMatch extends a single-line match by up to contextLines lines on each side,
+// stopping at the first line lineAt doesn't have
+func widenMatch(match *repocontextv1.CodeChunk, contextLines int, lineAt func(lineNum int) (string, bool)) {
+	matchLine := int(match.StartLine)
+
+	start := matchLine
+	for start > matchLine-contextLines {
+		if _, ok := lineAt(start - 1); !ok {
+			break
+		}
+		start--
+	}
+	end := matchLine
+	for end < matchLine+contextLines {
+		if _, ok := lineAt(end + 1); !ok {
+			break
+		}
+		end++
+	}
+	if start == matchLine && end == matchLine {
+		return
+	}
+
+	lines := make([]string, 0, end-start+1)
+	for lineNum := start; lineNum <= end; lineNum++ {
+		if lineNum == matchLine {
+			lines = append(lines, match.Content)
+			continue
+		}
+		text, _ := lineAt(lineNum)
+		lines = append(lines, text)
+	}
+
+	match.StartLine = int32(start)
+	match.EndLine = int32(end)
+	match.Content = strings.Join(lines, "\n")
+}
+
+// groupMatchChunks merges matches into one chunk per run of matches in the same
+// file whose line ranges, context included, overlap or are adjacent. Matches
+// further apart stay separate, so a chunk's content is every line from its first
+// to its last, each kept once.
+func groupMatchChunks(matches []*repocontextv1.CodeChunk) []*repocontextv1.CodeChunk {
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].FilePath != matches[j].FilePath {
+			return matches[i].FilePath < matches[j].FilePath
+		}
+		return matches[i].StartLine < matches[j].StartLine
+	})
+
+	var chunks []*repocontextv1.CodeChunk
+	var current *repocontextv1.CodeChunk
+
+	for _, match := range matches {
+		if current != nil && current.FilePath == match.FilePath && match.StartLine <= current.EndLine+1 {
+			// Append the lines past the end of the current chunk
+			if match.EndLine > current.EndLine {
+				lines := strings.Split(match.Content, "\n")
+				if overlap := int(current.EndLine - match.StartLine + 1); overlap > 0 {
+					lines = lines[overlap:]
+				}
+				current.Content += "\n" + strings.Join(lines, "\n")
+				current.EndLine = match.EndLine
+			}
+			// Highlights are anchored to file lines, so they carry over unchanged
+			current.Highlights = append(current.Highlights, match.Highlights...)
 			// Update score (simple max for now)
-			if chunk.Score > existingChunk.Score {
-				existingChunk.Score = chunk.Score
+			if match.Score > current.Score {
+				current.Score = match.Score
 			}
-		} else {
-			chunkMap[key] = chunk
+			continue
 		}
-	}
 
-	// Convert map to slice
-	for _, chunk := range chunkMap {
-		chunks = append(chunks, chunk)
+		current = match
+		chunks = append(chunks, current)
 	}
 
-	// Sort by relevance score (descending)
-	// For now, use a simple heuristic based on match quality
+	return chunks
+}
+
+// rankLexicalChunks scores chunks against the query and returns the top limit by
+// relevance. A limit of 0 or less returns every chunk.
+func rankLexicalChunks(chunks []*repocontextv1.CodeChunk, query string, limit int) []*repocontextv1.CodeChunk {
+	// Score with a simple heuristic based on match quality
 	for _, chunk := range chunks {
-		chunk.Score = r.calculateRelevanceScore(chunk, query)
+		chunk.Score = calculateRelevanceScore(chunk, query)
 	}
 
-	return chunks, nil
+	// Sort by relevance score (descending); file and line break ties so the
+	// order doesn't depend on map iteration
+	sort.Slice(chunks, func(i, j int) bool {
+		if chunks[i].Score != chunks[j].Score {
+			return chunks[i].Score > chunks[j].Score
+		}
+		if chunks[i].FilePath != chunks[j].FilePath {
+			return chunks[i].FilePath < chunks[j].FilePath
+		}
+		return chunks[i].StartLine < chunks[j].StartLine
+	})
+
+	if limit > 0 && len(chunks) > limit {
+		chunks = chunks[:limit]
+	}
+
+	return chunks
 }
 
 func (r *RipgrepClient) convertMatchToChunk(match RipgrepMatch, repoID string) *repocontextv1.CodeChunk {
-	if match.Data.Path.Text == "" || match.Data.Lines.Text == "" {
+	if match.Data.Path.Text == "" {
 		return nil
 	}
 
@@ -340,16 +553,32 @@ func (r *RipgrepClient) convertMatchToChunk(match RipgrepMatch, repoID string) *
 		FilePath:     filePath,
 		StartLine:    int32(match.Data.LineNumber),
 		EndLine:      int32(match.Data.LineNumber), // Will be updated when merging
-		Content:      match.Data.Lines.Text,
+		Content:      strings.TrimRight(match.Data.Lines.Text, "\r\n"),
 		Language:     language,
 		Source:       repocontextv1.SearchSource_SEARCH_SOURCE_LEXICAL,
 		Score:        1.0, // Will be calculated later
 	}
 
+	// Submatch offsets are bytes into the line ripgrep printed
+	for _, submatch := range match.Data.Submatches {
+		end := submatch.End
+		if end > len(chunk.Content) {
+			end = len(chunk.Content)
+		}
+		if submatch.Start >= end {
+			continue
+		}
+		chunk.Highlights = append(chunk.Highlights, &repocontextv1.HighlightRange{
+			Line:  chunk.StartLine,
+			Start: int32(submatch.Start),
+			End:   int32(end),
+		})
+	}
+
 	return chunk
 }
 
-func (r *RipgrepClient) calculateRelevanceScore(chunk *repocontextv1.CodeChunk, query string) float32 {
+func calculateRelevanceScore(chunk *repocontextv1.CodeChunk, query string) float32 {
 	content := strings.ToLower(chunk.Content)
 	queryLower := strings.ToLower(query)
 	terms := strings.Fields(queryLower)
@@ -429,51 +658,20 @@ func mapLanguageToRipgrepType(language string) string {
 		"xml":        "xml",
 		"yaml":       "yaml",
 		"markdown":   "md",
+		"dockerfile": "docker",
+		"makefile":   "make",
+		"groovy":     "groovy",
+		"perl":       "perl",
+		"lua":        "lua",
+		"starlark":   "bazel",
 	}
 
 	return languageMap[language]
 }
 
+// detectLanguageFromPath names a hit's language the way ingestion does
 func detectLanguageFromPath(path string) string {
-	ext := strings.ToLower(filepath.Ext(path))
-
-	languageMap := map[string]string{
-		".go":   "go",
-		".js":   "javascript",
-		".ts":   "typescript",
-		".py":   "python",
-		".java": "java",
-		".cpp":  "cpp",
-		".c":    "c",
-		".h":    "c",
-		".cs":   "csharp",
-		".rb":   "ruby",
-		".php":  "php",
-		".sh":   "shell",
-		".rs":   "rust",
-		".kt":   "kotlin",
-		".swift": "swift",
-		".scala": "scala",
-		".r":    "r",
-		".sql":  "sql",
-		".html": "html",
-		".css":  "css",
-		".scss": "scss",
-		".less": "less",
-		".json": "json",
-		".xml":  "xml",
-		".yaml": "yaml",
-		".yml":  "yaml",
-		".toml": "toml",
-		".md":   "markdown",
-		".txt":  "text",
-	}
-
-	if lang, exists := languageMap[ext]; exists {
-		return lang
-	}
-
-	return "unknown"
+	return ingest.DetectLanguage(path)
 }
 
 func (r *RipgrepClient) HealthCheck(ctx context.Context) error {
@@ -0,0 +1,364 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+func newTestRipgrepClient() *RipgrepClient {
+	return NewRipgrepClient(config.LexicalConfig{}, time.Second, observability.NewMetrics(), observability.NewNoOpTracer(), "", nil)
+}
+
+// globArgs returns the values passed to --glob
+func globArgs(args []string) []string {
+	var globs []string
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "--glob" {
+			globs = append(globs, args[i+1])
+		}
+	}
+	return globs
+}
+
+func TestBuildRipgrepArgsFilters(t *testing.T) {
+	tests := []struct {
+		name      string
+		filters   map[string]interface{}
+		wantGlobs []string
+		wantType  string
+	}{
+		{
+			name:      "path prefix without slash",
+			filters:   map[string]interface{}{"path_prefix": "internal/api"},
+			wantGlobs: []string{"internal/api/**"},
+		},
+		{
+			name:      "path prefix with trailing slash",
+			filters:   map[string]interface{}{"path_prefix": "internal/api/"},
+			wantGlobs: []string{"internal/api/**"},
+		},
+		{
+			name:      "root path prefix",
+			filters:   map[string]interface{}{"path_prefix": "/"},
+			wantGlobs: nil,
+		},
+		{
+			name:      "file patterns",
+			filters:   map[string]interface{}{"file_patterns": []string{"*.go", "!*_test.go"}},
+			wantGlobs: []string{"*.go", "!*_test.go"},
+		},
+		{
+			name:     "languages",
+			filters:  map[string]interface{}{"languages": []string{"go"}},
+			wantType: "go",
+		},
+	}
+
+	r := newTestRipgrepClient()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := r.buildRipgrepArgs("handler", 10, tt.filters)
+			if err != nil {
+				t.Fatalf("buildRipgrepArgs: %v", err)
+			}
+
+			globs := globArgs(args)
+			if strings.Join(globs, ",") != strings.Join(tt.wantGlobs, ",") {
+				t.Errorf("globs = %v, want %v", globs, tt.wantGlobs)
+			}
+			if tt.wantType != "" && !strings.Contains(strings.Join(args, " "), "--type "+tt.wantType) {
+				t.Errorf("args %v have no --type %s", args, tt.wantType)
+			}
+		})
+	}
+}
+
+// argValue returns the value following flag in args, or "" if flag isn't there
+func argValue(args []string, flag string) string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == flag {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBuildRipgrepArgsExactQueries(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		filters     map[string]interface{}
+		wantPattern string
+		wantFlags   []string
+		notFlags    []string
+	}{
+		{"regex", `Err[A-Z]\w+`, map[string]interface{}{"regex": true}, `Err[A-Z]\w+`, []string{"--smart-case"}, []string{"--case-sensitive", "--word-regexp"}},
+		{"case sensitive", "ErrNotFound", map[string]interface{}{"case_sensitive": true}, "ErrNotFound", []string{"--case-sensitive"}, []string{"--smart-case", "--word-regexp"}},
+		{"whole word", "user.ID", map[string]interface{}{"whole_word": true}, `user\.ID`, []string{"--smart-case", "--word-regexp"}, []string{"--case-sensitive"}},
+		{"case-sensitive regex", `^func New`, map[string]interface{}{"regex": true, "case_sensitive": true}, `^func New`, []string{"--case-sensitive"}, []string{"--smart-case"}},
+	}
+
+	r := newTestRipgrepClient()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := r.buildRipgrepArgs(tt.query, 10, tt.filters)
+			if err != nil {
+				t.Fatalf("buildRipgrepArgs: %v", err)
+			}
+
+			// The query reaches ripgrep as given, without fuzzy expansions
+			if got := argValue(args, "--regexp"); got != tt.wantPattern {
+				t.Errorf("pattern = %q, want %q", got, tt.wantPattern)
+			}
+			for _, flag := range tt.wantFlags {
+				if !containsString(args, flag) {
+					t.Errorf("args %v have no %s", args, flag)
+				}
+			}
+			for _, flag := range tt.notFlags {
+				if containsString(args, flag) {
+					t.Errorf("args %v have %s", args, flag)
+				}
+			}
+		})
+	}
+
+	// Without flags the same query is expanded
+	args, err := r.buildRipgrepArgs("ErrNotFound", 10, nil)
+	if err != nil {
+		t.Fatalf("buildRipgrepArgs: %v", err)
+	}
+	if got := argValue(args, "--regexp"); got == "ErrNotFound" || !strings.Contains(got, "(?i)") {
+		t.Errorf("fuzzy pattern = %q, want a case-insensitive expansion", got)
+	}
+
+	if _, err := r.buildRipgrepArgs("(unclosed", 10, map[string]interface{}{"regex": true}); err == nil {
+		t.Error("invalid regex accepted")
+	}
+}
+
+func TestBuildRipgrepArgsContextLines(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured int
+		filters    map[string]interface{}
+		want       string
+	}{
+		{"unconfigured", 0, nil, "0"},
+		{"configured", 5, nil, "5"},
+		{"query override", 5, map[string]interface{}{"context_lines": 1}, "1"},
+		{"query override to none", 5, map[string]interface{}{"context_lines": 0}, "0"},
+		{"negative override ignored", 5, map[string]interface{}{"context_lines": -1}, "5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRipgrepClient(config.LexicalConfig{ContextLines: tt.configured}, time.Second, observability.NewMetrics(), observability.NewNoOpTracer(), "", nil)
+
+			args, err := r.buildRipgrepArgs("handler", 10, tt.filters)
+			if err != nil {
+				t.Fatalf("buildRipgrepArgs: %v", err)
+			}
+			if got := argValue(args, "--context"); got != tt.want {
+				t.Errorf("--context = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// contextChunk is a match on line with context lines around it, whose content
+// is its line numbers
+func contextChunk(path string, line, context int) *repocontextv1.CodeChunk {
+	start := line - context
+	if start < 1 {
+		start = 1
+	}
+	var lines []string
+	for n := start; n <= line+context; n++ {
+		lines = append(lines, fmt.Sprint(n))
+	}
+	return &repocontextv1.CodeChunk{
+		FilePath:  path,
+		StartLine: int32(start),
+		EndLine:   int32(line + context),
+		Content:   strings.Join(lines, "\n"),
+	}
+}
+
+func TestGroupMatchChunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		context int
+		lines   []int
+		want    [][2]int32
+	}{
+		{"overlapping context merges", 2, []int{10, 13}, [][2]int32{{8, 15}}},
+		{"adjacent context merges", 2, []int{10, 15}, [][2]int32{{8, 17}}},
+		{"a gap between contexts splits", 2, []int{10, 16}, [][2]int32{{8, 12}, {14, 18}}},
+		{"no context merges only neighbours", 0, []int{9, 10, 12}, [][2]int32{{9, 10}, {12, 12}}},
+		{"wide context merges distant matches", 10, []int{20, 40}, [][2]int32{{10, 50}}},
+		{"runs of matches chain", 1, []int{5, 7, 9, 20}, [][2]int32{{4, 10}, {19, 21}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var matches []*repocontextv1.CodeChunk
+			for _, line := range tt.lines {
+				matches = append(matches, contextChunk("main.go", line, tt.context))
+			}
+
+			chunks := groupMatchChunks(matches)
+			if len(chunks) != len(tt.want) {
+				t.Fatalf("got %d chunks, want %d", len(chunks), len(tt.want))
+			}
+			for i, chunk := range chunks {
+				if chunk.StartLine != tt.want[i][0] || chunk.EndLine != tt.want[i][1] {
+					t.Errorf("chunk %d spans %d-%d, want %d-%d", i, chunk.StartLine, chunk.EndLine, tt.want[i][0], tt.want[i][1])
+				}
+				// Every line of the range is in the content exactly once
+				lines := strings.Split(chunk.Content, "\n")
+				if len(lines) != int(chunk.EndLine-chunk.StartLine+1) {
+					t.Fatalf("chunk %d has %d lines for range %d-%d: %q", i, len(lines), chunk.StartLine, chunk.EndLine, chunk.Content)
+				}
+				for j, line := range lines {
+					if want := fmt.Sprint(int(chunk.StartLine) + j); line != want {
+						t.Errorf("chunk %d line %d = %q, want %q", i, j, line, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGroupMatchChunksKeepsFilesApart(t *testing.T) {
+	chunks := groupMatchChunks([]*repocontextv1.CodeChunk{
+		contextChunk("b.go", 10, 2),
+		contextChunk("a.go", 11, 2),
+	})
+	if len(chunks) != 2 {
+		t.Fatalf("matches in different files were merged: %d chunks", len(chunks))
+	}
+}
+
+func TestParseRipgrepOutputSkipsOversizedLines(t *testing.T) {
+	long := strings.Repeat("x", maxRipgrepLineSize)
+	output := strings.Join([]string{
+		`{"type":"begin","data":{"path":{"text":"dist/app.min.js"}}}`,
+		`{"type":"match","data":{"path":{"text":"dist/app.min.js"},"lines":{"text":"config` + long + `\n"},"line_number":1,"submatches":[{"match":{"text":"config"},"start":0,"end":6}]}}`,
+		`{"type":"match","data":{"path":{"text":"main.go"},"lines":{"text":"cfg := config.Load()\n"},"line_number":12,"submatches":[{"match":{"text":"config"},"start":7,"end":13}]}}`,
+	}, "\n")
+
+	chunks, capped, err := newTestRipgrepClient().parseRipgrepOutput(strings.NewReader(output), "repo-1", "config", 10, 0)
+	if err != nil {
+		t.Fatalf("parseRipgrepOutput error = %v", err)
+	}
+	if capped {
+		t.Error("output reported as capped")
+	}
+	if len(chunks) != 1 || chunks[0].FilePath != "main.go" || chunks[0].StartLine != 12 {
+		t.Fatalf("got %d chunks, want only the main.go match: %v", len(chunks), chunks)
+	}
+}
+
+func TestParseRipgrepOutputKeepsTopScoredChunks(t *testing.T) {
+	lines := map[string]string{
+		"plural.go": "configs",
+		"one_b.go":  "config",
+		"three.go":  "config config config",
+		"one_a.go":  "config",
+		"two.go":    "config config",
+	}
+	var output []string
+	for path, text := range lines {
+		output = append(output, fmt.Sprintf(`{"type":"match","data":{"path":{"text":%q},"lines":{"text":"%s\n"},"line_number":1,"submatches":[]}}`, path, text))
+	}
+
+	chunks, _, err := newTestRipgrepClient().parseRipgrepOutput(strings.NewReader(strings.Join(output, "\n")), "repo-1", "config", 3, 0)
+	if err != nil {
+		t.Fatalf("parseRipgrepOutput error = %v", err)
+	}
+
+	// More occurrences score higher; equal scores are ordered by path
+	want := []string{"three.go", "two.go", "one_a.go"}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want the top %d", len(chunks), len(want))
+	}
+	for i, path := range want {
+		if chunks[i].FilePath != path {
+			t.Errorf("chunk %d = %s, want %s", i, chunks[i].FilePath, path)
+		}
+		if i > 0 && chunks[i].Score > chunks[i-1].Score {
+			t.Errorf("chunk %d scores %v, above the %v before it", i, chunks[i].Score, chunks[i-1].Score)
+		}
+	}
+}
+
+// highlightedText returns the text each highlight of chunk covers
+func highlightedText(t *testing.T, chunk *repocontextv1.CodeChunk) []string {
+	t.Helper()
+	lines := strings.Split(chunk.Content, "\n")
+	var texts []string
+	for _, h := range chunk.Highlights {
+		index := int(h.Line - chunk.StartLine)
+		if index < 0 || index >= len(lines) || h.Start < 0 || int(h.End) > len(lines[index]) || h.Start >= h.End {
+			t.Fatalf("highlight %v is outside chunk lines %d-%d", h, chunk.StartLine, chunk.EndLine)
+		}
+		texts = append(texts, lines[index][h.Start:h.End])
+	}
+	return texts
+}
+
+func TestParseRipgrepOutputHighlights(t *testing.T) {
+	lines := []string{"package main", "func handleConfig(cfg Config) {", "\t// load it", "\treturn loadConfig()", "}"}
+	var output []string
+	for i, text := range lines {
+		lineNum := i + 9
+		kind, submatches := "context", ""
+		var parts []string
+		for offset := 0; strings.Contains(text[offset:], "Config"); {
+			start := offset + strings.Index(text[offset:], "Config")
+			parts = append(parts, fmt.Sprintf(`{"match":{"text":"Config"},"start":%d,"end":%d}`, start, start+6))
+			offset = start + 6
+		}
+		if len(parts) > 0 {
+			kind, submatches = "match", strings.Join(parts, ",")
+		}
+		output = append(output, fmt.Sprintf(`{"type":%q,"data":{"path":{"text":"main.go"},"lines":{"text":%q},"line_number":%d,"submatches":[%s]}}`, kind, text+"\n", lineNum, submatches))
+	}
+
+	chunks, _, err := newTestRipgrepClient().parseRipgrepOutput(strings.NewReader(strings.Join(output, "\n")), "repo-1", "config", 10, 1)
+	if err != nil {
+		t.Fatalf("parseRipgrepOutput error = %v", err)
+	}
+
+	// Both matches and their context are grouped into one chunk, lines 9-13
+	if len(chunks) != 1 || chunks[0].StartLine != 9 || chunks[0].EndLine != 13 {
+		t.Fatalf("got chunks %v, want one for lines 9-13", chunks)
+	}
+	var got []string
+	for _, h := range chunks[0].Highlights {
+		got = append(got, fmt.Sprintf("%d:%d-%d", h.Line, h.Start, h.End))
+	}
+	if want := "[10:11-17 10:22-28 12:12-18]"; fmt.Sprint(got) != want {
+		t.Errorf("highlights = %v, want %s", got, want)
+	}
+	for _, text := range highlightedText(t, chunks[0]) {
+		if text != "Config" {
+			t.Errorf("highlight covers %q, want the matched Config", text)
+		}
+	}
+}
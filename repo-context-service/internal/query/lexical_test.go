@@ -0,0 +1,224 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+func TestSearchLexicalWithoutWorkingTree(t *testing.T) {
+	workDir := t.TempDir()
+	metrics := observability.NewMetrics()
+	tracer := observability.NewNoOpTracer()
+
+	clients := map[string]LexicalClient{
+		"ripgrep": NewRipgrepClient(config.LexicalConfig{}, time.Second, metrics, tracer, workDir, nil),
+		"native":  NewNativeSearchClient(config.LexicalConfig{}, time.Second, metrics, tracer, workDir, nil),
+	}
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			chunks, err := client.SearchLexical(context.Background(), "imported-repo", "main", 10, nil)
+			if err != nil {
+				t.Fatalf("SearchLexical error = %v, want none for a repository without a working tree", err)
+			}
+			if len(chunks) != 0 {
+				t.Fatalf("SearchLexical returned %d chunks, want 0", len(chunks))
+			}
+		})
+	}
+}
+
+func TestReadRepositoryFile(t *testing.T) {
+	workDir := t.TempDir()
+	repoPath := filepath.Join(workDir, "repo")
+	if err := os.MkdirAll(repoPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"main.go":    "package main\n\nfunc main() {}\n",
+		"image.png":  "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"latin1.txt": "caf\xe9\n",
+		"large.txt":  strings.Repeat(strings.Repeat("x", 1023)+"\n", MaxReadFileBytes/1024+1),
+		"nb.ipynb":   "{\n \"cells\": []\n}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(repoPath, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		startLine int
+		endLine   int
+		wantErr   error
+		wantLines int32
+	}{
+		{"whole file", "main.go", 0, 0, nil, 3},
+		{"line range", "main.go", 3, 3, nil, 1},
+		{"binary", "image.png", 0, 0, ErrBinaryFile, 0},
+		{"invalid utf-8", "latin1.txt", 0, 0, ErrBinaryFile, 0},
+		{"too large", "large.txt", 0, 0, ErrFileTooLarge, 0},
+		{"range of a large file", "large.txt", 10, 20, nil, 11},
+		{"outside the repository", "../secret", 0, 0, ErrInvalidFilePath, 0},
+		{"whole notebook", "nb.ipynb", 0, 0, nil, 3},
+		{"notebook line range", "nb.ipynb", 1, 2, ErrNotebookLineRange, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk, err := readRepositoryFile(workDir, "repo", tt.path, tt.startLine, tt.endLine)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("readRepositoryFile error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readRepositoryFile error = %v", err)
+			}
+			if got := chunk.EndLine - chunk.StartLine + 1; got != tt.wantLines {
+				t.Errorf("read %d lines, want %d", got, tt.wantLines)
+			}
+		})
+	}
+}
+
+// countingReader records the ReadFile calls ExpandContext makes
+type countingReader struct {
+	LexicalClient
+	reads []string
+}
+
+func (c *countingReader) ReadFile(ctx context.Context, repoID, path string, startLine, endLine int) (*repocontextv1.CodeChunk, error) {
+	c.reads = append(c.reads, path)
+	return &repocontextv1.CodeChunk{FilePath: path, StartLine: int32(startLine), EndLine: int32(endLine), Content: "widened"}, nil
+}
+
+func TestExpandContextSkipsNotebooks(t *testing.T) {
+	reader := &countingReader{}
+	chunks := []*repocontextv1.CodeChunk{
+		{RepositoryId: "repo", FilePath: "main.go", StartLine: 10, EndLine: 12, Content: "original"},
+		{RepositoryId: "repo", FilePath: "analysis.ipynb", StartLine: 10, EndLine: 12, Content: "original"},
+	}
+
+	expanded := ExpandContext(context.Background(), reader, chunks, 3)
+
+	if len(reader.reads) != 1 || reader.reads[0] != "main.go" {
+		t.Fatalf("ExpandContext read %v, want only main.go", reader.reads)
+	}
+	if expanded[0].StartLine != 7 || expanded[0].Content != "widened" {
+		t.Errorf("main.go chunk not widened: %+v", expanded[0])
+	}
+	if expanded[1] != chunks[1] {
+		t.Errorf("notebook chunk changed: %+v", expanded[1])
+	}
+}
+
+func TestLoadSynonymsFromFile(t *testing.T) {
+	files := map[string]string{
+		"synonyms.yaml": "billing: [invoice, ledger]\nAuth: [sso]\n",
+		"synonyms.json": `{"billing": ["invoice", "ledger"], "Auth": ["sso"]}`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			synonyms, err := LoadSynonyms(path)
+			if err != nil {
+				t.Fatalf("LoadSynonyms error = %v", err)
+			}
+
+			tests := []struct {
+				term    string
+				want    []string
+				notWant []string
+			}{
+				{"billing", []string{"(?i)invoice", "(?i)ledger"}, nil},
+				// A term in the file replaces its built-in expansions
+				{"auth", []string{"(?i)sso"}, []string{"(?i)authenticated"}},
+				// Terms the file doesn't mention keep the defaults
+				{"db", []string{"(?i)database"}, nil},
+			}
+			for _, tt := range tests {
+				patterns := generateFuzzyPatterns(tt.term, synonyms)
+				for _, want := range tt.want {
+					if !containsString(patterns, want) {
+						t.Errorf("patterns for %q = %v, want %s", tt.term, patterns, want)
+					}
+				}
+				for _, notWant := range tt.notWant {
+					if containsString(patterns, notWant) {
+						t.Errorf("patterns for %q = %v, still expanding to %s", tt.term, patterns, notWant)
+					}
+				}
+			}
+		})
+	}
+
+	if _, err := LoadSynonyms(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadSynonyms accepted a missing file")
+	}
+}
+
+func TestExpandContextReadsNeighbouringLines(t *testing.T) {
+	workDir := t.TempDir()
+	var lines []string
+	for n := 1; n <= 20; n++ {
+		lines = append(lines, fmt.Sprintf("line %d", n))
+	}
+	writeRepository(t, workDir, "repo-1", map[string]string{"main.go": strings.Join(lines, "\n") + "\n"})
+	reader := NewNativeSearchClient(config.LexicalConfig{}, time.Second, observability.NewMetrics(), observability.NewNoOpTracer(), workDir, nil)
+
+	tests := []struct {
+		name               string
+		startLine, endLine int32
+		wantStart, wantEnd int32
+	}{
+		{"middle of the file", 10, 12, 8, 14},
+		{"clamped at the start", 1, 2, 1, 4},
+		{"clamped at the end", 19, 20, 17, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk := &repocontextv1.CodeChunk{
+				RepositoryId: "repo-1",
+				FilePath:     "main.go",
+				StartLine:    tt.startLine,
+				EndLine:      tt.endLine,
+				Content:      strings.Join(lines[tt.startLine-1:tt.endLine], "\n"),
+				Score:        0.9,
+				Source:       repocontextv1.SearchSource_SEARCH_SOURCE_SEMANTIC,
+			}
+
+			expanded := ExpandContext(context.Background(), reader, []*repocontextv1.CodeChunk{chunk}, 2)
+
+			got := expanded[0]
+			if got.StartLine != tt.wantStart || got.EndLine != tt.wantEnd {
+				t.Fatalf("expanded to lines %d-%d, want %d-%d", got.StartLine, got.EndLine, tt.wantStart, tt.wantEnd)
+			}
+			if want := strings.Join(lines[tt.wantStart-1:tt.wantEnd], "\n"); got.Content != want {
+				t.Errorf("content = %q, want %q", got.Content, want)
+			}
+			if got.Score != 0.9 || got.Source != repocontextv1.SearchSource_SEARCH_SOURCE_SEMANTIC {
+				t.Errorf("search metadata changed to score %v, source %v", got.Score, got.Source)
+			}
+			if chunk.StartLine != tt.startLine {
+				t.Error("the original chunk was modified")
+			}
+		})
+	}
+}
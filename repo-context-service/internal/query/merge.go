@@ -7,9 +7,17 @@ import (
 	"strings"
 	"time"
 
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/ingest"
 	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
 )
 
+// Merge modes, see config.MergeConfig
+const (
+	MergeModeZScore = "zscore"
+	MergeModeRRF    = "rrf"
+)
+
 type MergedResults struct {
 	Chunks  []*repocontextv1.CodeChunk
 	Timings *repocontextv1.SearchTimings
@@ -19,37 +27,59 @@ type MergedResults struct {
 type SearchResults struct {
 	LexicalChunks  []*repocontextv1.CodeChunk
 	SemanticChunks []*repocontextv1.CodeChunk
-	LexicalTime    time.Duration
-	SemanticTime   time.Duration
-	CacheHit       bool
+	// Stored vectors of the semantic chunks, for MMR re-ranking; see SearchSemanticVectors
+	SemanticVectors map[*repocontextv1.CodeChunk][]float32
+	LexicalTime     time.Duration
+	SemanticTime    time.Duration
+	CacheHit        bool
 }
 
 type ResultMerger struct {
 	maxResults int
+	config     config.MergeConfig
 }
 
-func NewResultMerger(maxResults int) *ResultMerger {
+func NewResultMerger(maxResults int, cfg config.MergeConfig) *ResultMerger {
 	return &ResultMerger{
 		maxResults: maxResults,
+		config:     cfg,
 	}
 }
 
 func (rm *ResultMerger) MergeAndRank(results *SearchResults) *MergedResults {
+	return rm.MergeAndRankTop(results, rm.maxResults)
+}
+
+// MergeAndRankTop is MergeAndRank keeping the top maxResults chunks instead of the
+// configured number, for callers paging through the ranking
+func (rm *ResultMerger) MergeAndRankTop(results *SearchResults, maxResults int) *MergedResults {
 	startTime := time.Now()
 
-	// Normalize scores for each backend
-	lexicalNormalized := rm.normalizeScores(results.LexicalChunks)
-	semanticNormalized := rm.normalizeScores(results.SemanticChunks)
+	// Normalize scores for each backend. Only backends that returned results count
+	// towards the total weight, so a single-source query still scores on the full scale.
+	var totalWeight float32
+	if len(results.LexicalChunks) > 0 {
+		totalWeight += rm.config.LexicalWeight
+	}
+	if len(results.SemanticChunks) > 0 {
+		totalWeight += rm.config.SemanticWeight
+	}
+	lexicalNormalized := rm.scoreResults(results.LexicalChunks, rm.config.LexicalWeight, totalWeight)
+	semanticNormalized := rm.scoreResults(results.SemanticChunks, rm.config.SemanticWeight, totalWeight)
 
 	// Merge results
 	merged := rm.mergeResults(lexicalNormalized, semanticNormalized)
 
 	// Deduplicate and rank
 	final := rm.deduplicateAndRank(merged)
+	if rm.config.MMREnabled {
+		final = rm.diversify(final, results)
+	}
 
 	// Truncate to max results
-	if len(final) > rm.maxResults {
-		final = final[:rm.maxResults]
+	truncated := len(final) > maxResults
+	if truncated {
+		final = final[:maxResults]
 	}
 
 	// Calculate merge time
@@ -68,12 +98,53 @@ func (rm *ResultMerger) MergeAndRank(results *SearchResults) *MergedResults {
 			LexicalCandidates:  int32(len(results.LexicalChunks)),
 			SemanticCandidates: int32(len(results.SemanticChunks)),
 			MergedResults:      int32(len(final)),
-			ResultsTruncated:   len(final) == rm.maxResults,
+			ResultsTruncated:   truncated,
 		},
 	}
 }
 
-func (rm *ResultMerger) normalizeScores(chunks []*repocontextv1.CodeChunk) []*repocontextv1.CodeChunk {
+// scoreResults puts one backend's scores on a common 0-1 scale and applies its weight.
+// totalWeight is the summed weight of the backends that returned results.
+func (rm *ResultMerger) scoreResults(chunks []*repocontextv1.CodeChunk, weight, totalWeight float32) []*repocontextv1.CodeChunk {
+	if rm.config.Mode == MergeModeRRF {
+		return rm.rankFusionScores(chunks, weight, totalWeight)
+	}
+	return rm.normalizeScores(chunks, weight)
+}
+
+// rankFusionScores scores chunks by Reciprocal Rank Fusion, weight/(k+rank), ignoring
+// the raw score magnitudes. Scores are scaled so a chunk ranked first by every
+// backend that returned results sums to 1.
+func (rm *ResultMerger) rankFusionScores(chunks []*repocontextv1.CodeChunk, weight, totalWeight float32) []*repocontextv1.CodeChunk {
+	if len(chunks) == 0 {
+		return chunks
+	}
+
+	order := make([]int, len(chunks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return chunks[order[a]].Score > chunks[order[b]].Score
+	})
+
+	k := float32(rm.config.RRFK)
+	if totalWeight == 0 {
+		// Only a zero-weighted backend returned results; every score is 0
+		totalWeight = 1
+	}
+	scale := (k + 1) / totalWeight
+
+	scored := make([]*repocontextv1.CodeChunk, len(chunks))
+	for rank, i := range order {
+		scored[i] = rm.copyChunk(chunks[i])
+		scored[i].Score = weight / (k + float32(rank+1)) * scale
+	}
+
+	return scored
+}
+
+func (rm *ResultMerger) normalizeScores(chunks []*repocontextv1.CodeChunk, weight float32) []*repocontextv1.CodeChunk {
 	if len(chunks) == 0 {
 		return chunks
 	}
@@ -109,13 +180,15 @@ func (rm *ResultMerger) normalizeScores(chunks []*repocontextv1.CodeChunk) []*re
 			Language:     chunk.Language,
 			Symbol:       chunk.Symbol,
 			Source:       chunk.Source,
+			Highlights:   chunk.Highlights,
+			CreatedAt:    chunk.CreatedAt,
 		}
 
 		// Z-score normalization
 		zScore := (float64(chunk.Score) - mean) / stdDev
 
 		// Map to 0-1 range using sigmoid
-		normalized[i].Score = float32(1.0 / (1.0 + math.Exp(-zScore)))
+		normalized[i].Score = weight * float32(1.0/(1.0+math.Exp(-zScore)))
 	}
 
 	return normalized
@@ -144,10 +217,12 @@ func (rm *ResultMerger) deduplicateAndRank(chunks []*repocontextv1.CodeChunk) []
 		return chunks
 	}
 
-	// Group chunks by file
+	// Group chunks by file; results may span repositories, so the repository is part
+	// of the key
 	fileGroups := make(map[string][]*repocontextv1.CodeChunk)
 	for _, chunk := range chunks {
-		fileGroups[chunk.FilePath] = append(fileGroups[chunk.FilePath], chunk)
+		key := chunk.RepositoryId + "\x00" + chunk.FilePath
+		fileGroups[key] = append(fileGroups[key], chunk)
 	}
 
 	var final []*repocontextv1.CodeChunk
@@ -206,7 +281,7 @@ func (rm *ResultMerger) deduplicateFileChunks(chunks []*repocontextv1.CodeChunk)
 }
 
 func (rm *ResultMerger) hasOverlap(chunk1, chunk2 *repocontextv1.CodeChunk) bool {
-	if chunk1.FilePath != chunk2.FilePath {
+	if chunk1.RepositoryId != chunk2.RepositoryId || chunk1.FilePath != chunk2.FilePath {
 		return false
 	}
 
@@ -217,6 +292,15 @@ func (rm *ResultMerger) hasOverlap(chunk1, chunk2 *repocontextv1.CodeChunk) bool
 func (rm *ResultMerger) mergeOverlappingChunks(chunk1, chunk2 *repocontextv1.CodeChunk) *repocontextv1.CodeChunk {
 	merged := rm.copyChunk(chunk1)
 
+	// Contiguous chunks are joined line by line, so the content keeps matching the
+	// line range and highlights still point at the right lines
+	if content, ok := spliceContent(chunk1, chunk2); ok {
+		merged.Content = content
+	} else if !strings.Contains(merged.Content, chunk2.Content) {
+		// Combine content (avoid duplication)
+		merged.Content = rm.combineContent(merged.Content, chunk2.Content)
+	}
+
 	// Expand line range
 	if chunk2.StartLine < merged.StartLine {
 		merged.StartLine = chunk2.StartLine
@@ -225,13 +309,16 @@ func (rm *ResultMerger) mergeOverlappingChunks(chunk1, chunk2 *repocontextv1.Cod
 		merged.EndLine = chunk2.EndLine
 	}
 
-	// Combine content (avoid duplication)
-	if !strings.Contains(merged.Content, chunk2.Content) {
-		merged.Content = rm.combineContent(merged.Content, chunk2.Content)
+	merged.Highlights = mergeHighlights(chunk1.Highlights, chunk2.Highlights)
+	if merged.CreatedAt == nil {
+		merged.CreatedAt = chunk2.CreatedAt
 	}
 
-	// Use higher score
-	if chunk2.Score > merged.Score {
+	// Rank fusion adds up the scores a region earns from each backend; otherwise
+	// the higher score wins
+	if rm.config.Mode == MergeModeRRF && chunk1.Source != chunk2.Source {
+		merged.Score += chunk2.Score
+	} else if chunk2.Score > merged.Score {
 		merged.Score = chunk2.Score
 	}
 
@@ -241,6 +328,60 @@ func (rm *ResultMerger) mergeOverlappingChunks(chunk1, chunk2 *repocontextv1.Cod
 	return merged
 }
 
+// spliceContent joins the content of two chunks whose line ranges overlap or touch,
+// taking each line once. It fails if either chunk's content doesn't span exactly its
+// line range or the ranges are apart.
+func spliceContent(chunk1, chunk2 *repocontextv1.CodeChunk) (string, bool) {
+	if chunk2.StartLine < chunk1.StartLine {
+		chunk1, chunk2 = chunk2, chunk1
+	}
+	lines1 := strings.Split(chunk1.Content, "\n")
+	lines2 := strings.Split(chunk2.Content, "\n")
+	if len(lines1) != int(chunk1.EndLine-chunk1.StartLine+1) || len(lines2) != int(chunk2.EndLine-chunk2.StartLine+1) {
+		return "", false
+	}
+	if chunk2.StartLine > chunk1.EndLine+1 {
+		return "", false
+	}
+
+	if chunk2.EndLine > chunk1.EndLine {
+		lines1 = append(lines1, lines2[chunk1.EndLine-chunk2.StartLine+1:]...)
+	}
+	return strings.Join(lines1, "\n"), true
+}
+
+// mergeHighlights combines the highlights of two chunks in line and offset order,
+// dropping duplicates
+func mergeHighlights(a, b []*repocontextv1.HighlightRange) []*repocontextv1.HighlightRange {
+	if len(b) == 0 {
+		return a
+	}
+	if len(a) == 0 {
+		return b
+	}
+
+	combined := append(append([]*repocontextv1.HighlightRange{}, a...), b...)
+	sort.SliceStable(combined, func(i, j int) bool {
+		if combined[i].Line != combined[j].Line {
+			return combined[i].Line < combined[j].Line
+		}
+		if combined[i].Start != combined[j].Start {
+			return combined[i].Start < combined[j].Start
+		}
+		return combined[i].End < combined[j].End
+	})
+
+	deduplicated := combined[:1]
+	for _, highlight := range combined[1:] {
+		last := deduplicated[len(deduplicated)-1]
+		if highlight.Line == last.Line && highlight.Start == last.Start && highlight.End == last.End {
+			continue
+		}
+		deduplicated = append(deduplicated, highlight)
+	}
+	return deduplicated
+}
+
 func (rm *ResultMerger) combineContent(content1, content2 string) string {
 	// Simple combination - in practice, you might want more sophisticated merging
 	lines1 := strings.Split(content1, "\n")
@@ -268,35 +409,40 @@ func (rm *ResultMerger) applyBoosts(chunk *repocontextv1.CodeChunk, allFileChunk
 		}
 	}
 
-	if hasLexical && hasSemantic {
-		score += 0.15 // File appears in both backends
+	// Rank fusion already rewards agreement between the backends
+	if hasLexical && hasSemantic && rm.config.Mode != MergeModeRRF {
+		score += rm.config.DualSourceBoost // File appears in both backends
 	}
 
 	// Shorter span boost
 	lineSpan := chunk.EndLine - chunk.StartLine + 1
 	if lineSpan <= 10 {
-		score += 0.05 // Prefer focused, shorter chunks
+		score += rm.config.ShortChunkBoost // Prefer focused, shorter chunks
 	} else if lineSpan > 50 {
-		score -= 0.02 // Penalize very long chunks
+		score -= rm.config.LongChunkPenalty // Penalize very long chunks
 	}
 
 	// Language boost for popular languages
 	switch chunk.Language {
 	case "go", "javascript", "typescript", "python", "java":
-		score += 0.02
+		score += rm.config.LanguageBoost
 	}
 
 	// File type boost
 	if strings.HasSuffix(chunk.FilePath, "_test.go") ||
 	   strings.HasSuffix(chunk.FilePath, ".test.js") ||
 	   strings.Contains(chunk.FilePath, "test/") {
-		score -= 0.01 // Slightly penalize test files
+		score -= rm.config.TestFilePenalty // Slightly penalize test files
 	}
 
 	if strings.Contains(chunk.FilePath, "main.") ||
 	   strings.Contains(chunk.FilePath, "index.") ||
 	   strings.Contains(chunk.FilePath, "app.") {
-		score += 0.02 // Boost main/entry files
+		score += rm.config.EntryFileBoost // Boost main/entry files
+	}
+
+	if ingest.IsGenerated(chunk.FilePath, chunk.Content) {
+		score -= rm.config.GeneratedFilePenalty // Vendored and generated code
 	}
 
 	// Content quality boost
@@ -311,7 +457,7 @@ func (rm *ResultMerger) applyBoosts(chunk *repocontextv1.CodeChunk, allFileChunk
 	if nonEmptyLines > 0 {
 		density := float32(nonEmptyLines) / float32(len(contentLines))
 		if density > 0.7 {
-			score += 0.03 // Boost for dense, non-empty content
+			score += rm.config.DenseContentBoost // Boost for dense, non-empty content
 		}
 	}
 
@@ -337,6 +483,8 @@ func (rm *ResultMerger) copyChunk(chunk *repocontextv1.CodeChunk) *repocontextv1
 		Source:       chunk.Source,
 		Language:     chunk.Language,
 		Symbol:       chunk.Symbol,
+		Highlights:   chunk.Highlights,
+		CreatedAt:    chunk.CreatedAt,
 	}
 }
 
@@ -358,6 +506,16 @@ func (rm *ResultMerger) TruncateContent(chunks []*repocontextv1.CodeChunk, maxLe
 			}
 
 			chunk.Content = strings.Join(truncated, "\n") + "..."
+
+			// Drop highlights on lines that were cut
+			lastLine := chunk.StartLine + int32(len(truncated)) - 1
+			var highlights []*repocontextv1.HighlightRange
+			for _, highlight := range chunk.Highlights {
+				if highlight.Line <= lastLine {
+					highlights = append(highlights, highlight)
+				}
+			}
+			chunk.Highlights = highlights
 		}
 	}
 }
@@ -0,0 +1,114 @@
+package query
+
+import (
+	"fmt"
+	"testing"
+
+	"repo-context-service/internal/config"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+func TestMergeModesRankDifferently(t *testing.T) {
+	hits := func(prefix string, scores ...float32) []*repocontextv1.CodeChunk {
+		chunks := make([]*repocontextv1.CodeChunk, len(scores))
+		for i, score := range scores {
+			chunks[i] = &repocontextv1.CodeChunk{FilePath: fmt.Sprintf("%s%d.go", prefix, i+1), StartLine: 1, EndLine: 20, Content: "x", Score: score}
+		}
+		return chunks
+	}
+	// One lexical match stands far above the rest; the semantic scores are close together
+	results := func() *SearchResults {
+		return &SearchResults{
+			LexicalChunks:  hits("lexical", 10, 1, 1, 1),
+			SemanticChunks: hits("semantic", 0.9, 0.89, 0.88, 0.2),
+		}
+	}
+
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		// Z-scores keep how far the lexical match stands out, so it ranks first
+		{MergeModeZScore, []string{"lexical1.go", "semantic1.go", "semantic2.go", "semantic3.go"}},
+		// Rank fusion only sees ranks, so the higher-weighted semantic ranking wins
+		{MergeModeRRF, []string{"semantic1.go", "semantic2.go", "semantic3.go", "semantic4.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			merger := NewResultMerger(10, config.MergeConfig{Mode: tt.mode, LexicalWeight: 0.45, SemanticWeight: 0.55, RRFK: 60})
+
+			merged := merger.MergeAndRank(results())
+
+			if len(merged.Chunks) != 8 {
+				t.Fatalf("merged %d chunks, want all 8", len(merged.Chunks))
+			}
+			for i, want := range tt.want {
+				if got := merged.Chunks[i].FilePath; got != want {
+					t.Errorf("rank %d = %s, want %s", i+1, got, want)
+				}
+			}
+			for i := 1; i < len(merged.Chunks); i++ {
+				if merged.Chunks[i].Score > merged.Chunks[i-1].Score {
+					t.Errorf("rank %d scores %v above rank %d's %v", i+1, merged.Chunks[i].Score, i, merged.Chunks[i-1].Score)
+				}
+			}
+			if top := merged.Chunks[0].Score; top <= 0 || top > 1 {
+				t.Errorf("top score %v is outside (0, 1]", top)
+			}
+		})
+	}
+}
+
+func TestGeneratedCodeRanksBelowHandWritten(t *testing.T) {
+	tests := []struct {
+		name               string
+		generated, written *repocontextv1.CodeChunk
+	}{
+		{"protobuf output", &repocontextv1.CodeChunk{FilePath: "api/user.pb.go"}, &repocontextv1.CodeChunk{FilePath: "api/user.go"}},
+		{"vendored package", &repocontextv1.CodeChunk{FilePath: "vendor/github.com/acme/user/user.go"}, &repocontextv1.CodeChunk{FilePath: "user/user.go"}},
+		{"minified bundle", &repocontextv1.CodeChunk{FilePath: "dist/app.min.js"}, &repocontextv1.CodeChunk{FilePath: "src/app.js"}},
+		{"generated marker", &repocontextv1.CodeChunk{FilePath: "models/user_gen.go", Content: "// Code generated by sqlc. DO NOT EDIT.\n"}, &repocontextv1.CodeChunk{FilePath: "models/user.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The same match in both files, with the generated one found first
+			var chunks []*repocontextv1.CodeChunk
+			for _, chunk := range []*repocontextv1.CodeChunk{tt.generated, tt.written} {
+				chunk.StartLine, chunk.EndLine, chunk.Score = 1, 20, 0.8
+				chunk.Content += "type User struct{}"
+				chunks = append(chunks, chunk)
+			}
+			merger := NewResultMerger(10, config.MergeConfig{Mode: MergeModeRRF, LexicalWeight: 1, SemanticWeight: 1, RRFK: 60, GeneratedFilePenalty: 0.1})
+
+			merged := merger.MergeAndRank(&SearchResults{LexicalChunks: chunks})
+
+			if len(merged.Chunks) != 2 || merged.Chunks[0].FilePath != tt.written.FilePath {
+				t.Fatalf("ranking = %v, want %s first", merged.Chunks, tt.written.FilePath)
+			}
+			if merged.Chunks[1].Score >= merged.Chunks[0].Score {
+				t.Errorf("generated file scores %v, not below the hand-written %v", merged.Chunks[1].Score, merged.Chunks[0].Score)
+			}
+		})
+	}
+}
+
+func TestMergeKeepsHighlightsOnTheirLines(t *testing.T) {
+	// Two lexical hits in main.go whose context overlaps on line 11
+	first := &repocontextv1.CodeChunk{FilePath: "main.go", StartLine: 9, EndLine: 11, Score: 0.8,
+		Content:    "package main\nfunc handleConfig() {\n\t// load it",
+		Highlights: []*repocontextv1.HighlightRange{{Line: 10, Start: 11, End: 17}}}
+	second := &repocontextv1.CodeChunk{FilePath: "main.go", StartLine: 11, EndLine: 13, Score: 0.6,
+		Content:    "\t// load it\n\treturn loadConfig()\n}",
+		Highlights: []*repocontextv1.HighlightRange{{Line: 12, Start: 12, End: 18}}}
+	merger := NewResultMerger(10, config.MergeConfig{})
+
+	merged := merger.MergeAndRank(&SearchResults{LexicalChunks: []*repocontextv1.CodeChunk{first, second}})
+
+	if len(merged.Chunks) != 1 || merged.Chunks[0].StartLine != 9 || merged.Chunks[0].EndLine != 13 {
+		t.Fatalf("merged chunks = %v, want one for lines 9-13", merged.Chunks)
+	}
+	texts := highlightedText(t, merged.Chunks[0])
+	if len(texts) != 2 || texts[0] != "Config" || texts[1] != "Config" {
+		t.Errorf("highlights cover %q, want both Config matches", texts)
+	}
+}
@@ -0,0 +1,150 @@
+package query
+
+import (
+	"context"
+	"math"
+
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+// SearchSemanticVectors is client.SearchSemantic that also returns the stored vector
+// of each hit, keyed by chunk, when the client is a VectorSearcher. MMR re-ranking
+// compares chunks by these vectors.
+func SearchSemanticVectors(ctx context.Context, client SemanticClient, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, map[*repocontextv1.CodeChunk][]float32, error) {
+	searcher, ok := client.(VectorSearcher)
+	if !ok {
+		chunks, err := client.SearchSemantic(ctx, repoID, queryVector, limit, offset, minCertainty, filters)
+		return chunks, nil, err
+	}
+
+	hits, err := searcher.SearchSemanticHits(ctx, repoID, queryVector, limit, offset, minCertainty, filters)
+	if err != nil {
+		return nil, nil, err
+	}
+	vectors := make(map[*repocontextv1.CodeChunk][]float32, len(hits))
+	for _, hit := range hits {
+		if len(hit.Vector) > 0 {
+			vectors[hit.Chunk] = hit.Vector
+		}
+	}
+	return hitChunks(hits), vectors, nil
+}
+
+// UsesVectors reports whether ranking compares the semantic hits' vectors, so
+// searches should fetch them with SearchSemanticVectors
+func (rm *ResultMerger) UsesVectors() bool {
+	return rm.config.MMREnabled
+}
+
+// diversify re-orders ranked chunks by Maximal Marginal Relevance: each pick is the
+// chunk with the best lambda*score - (1-lambda)*similarity, where similarity is its
+// highest cosine similarity to a chunk already picked. Chunks without a vector are
+// never penalized, so lexical-only hits keep their relevance order. Scores are kept,
+// so they no longer decrease down the list.
+func (rm *ResultMerger) diversify(chunks []*repocontextv1.CodeChunk, results *SearchResults) []*repocontextv1.CodeChunk {
+	if len(chunks) <= 1 || len(results.SemanticVectors) == 0 {
+		return chunks
+	}
+
+	vectors := chunkVectors(chunks, results)
+	lambda := float64(rm.config.MMRLambda)
+
+	// similarity[i] is chunk i's highest similarity to the picked chunks
+	similarity := make([]float64, len(chunks))
+	picked := make([]bool, len(chunks))
+	reranked := make([]*repocontextv1.CodeChunk, 0, len(chunks))
+	for len(reranked) < len(chunks) {
+		best := -1
+		var bestValue float64
+		for i, chunk := range chunks {
+			if picked[i] {
+				continue
+			}
+			value := lambda*float64(chunk.Score) - (1-lambda)*similarity[i]
+			// Ties keep the relevance order
+			if best < 0 || value > bestValue {
+				best, bestValue = i, value
+			}
+		}
+
+		picked[best] = true
+		reranked = append(reranked, chunks[best])
+		if vectors[best] == nil {
+			continue
+		}
+		for i := range chunks {
+			if picked[i] || vectors[i] == nil {
+				continue
+			}
+			if sim := cosineSimilarity(vectors[i], vectors[best]); sim > similarity[i] {
+				similarity[i] = sim
+			}
+		}
+	}
+
+	return reranked
+}
+
+// chunkVectors returns the vector of each ranked chunk: the mean vector of the
+// semantic hits whose lines it covers, or nil when it covers none, as for a lexical
+// match far from any semantic hit
+func chunkVectors(chunks []*repocontextv1.CodeChunk, results *SearchResults) [][]float32 {
+	// Results may span repositories, so the repository is part of the key
+	hitsByFile := make(map[string][]*repocontextv1.CodeChunk)
+	for _, hit := range results.SemanticChunks {
+		if results.SemanticVectors[hit] == nil {
+			continue
+		}
+		key := hit.RepositoryId + "\x00" + hit.FilePath
+		hitsByFile[key] = append(hitsByFile[key], hit)
+	}
+
+	vectors := make([][]float32, len(chunks))
+	for i, chunk := range chunks {
+		var sum []float64
+		count := 0
+		for _, hit := range hitsByFile[chunk.RepositoryId+"\x00"+chunk.FilePath] {
+			if hit.EndLine < chunk.StartLine || hit.StartLine > chunk.EndLine {
+				continue
+			}
+			vector := results.SemanticVectors[hit]
+			if sum == nil {
+				sum = make([]float64, len(vector))
+			} else if len(vector) != len(sum) {
+				continue
+			}
+			for j, value := range vector {
+				sum[j] += float64(value)
+			}
+			count++
+		}
+		if count == 0 {
+			continue
+		}
+
+		mean := make([]float32, len(sum))
+		for j := range sum {
+			mean[j] = float32(sum[j] / float64(count))
+		}
+		vectors[i] = mean
+	}
+	return vectors
+}
+
+// cosineSimilarity returns the cosine similarity of two vectors, or 0 if their
+// dimensions differ or either is zero
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,128 @@
+package query
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"repo-context-service/internal/config"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"identical", []float32{1, 2}, []float32{1, 2}, 1},
+		{"scaled", []float32{1, 2}, []float32{2, 4}, 1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 3}, 0},
+		{"opposite", []float32{1, 0}, []float32{-1, 0}, -1},
+		{"dimensions differ", []float32{1, 0}, []float32{1, 0, 0}, 0},
+		{"zero vector", []float32{0, 0}, []float32{1, 0}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cosineSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("cosineSimilarity(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkVectors(t *testing.T) {
+	hit := func(repo, path string, start, end int) *repocontextv1.CodeChunk {
+		return &repocontextv1.CodeChunk{RepositoryId: repo, FilePath: path, StartLine: int32(start), EndLine: int32(end)}
+	}
+	first, second := hit("repo-1", "auth.go", 1, 10), hit("repo-1", "auth.go", 21, 30)
+	other, unstored := hit("repo-2", "auth.go", 1, 10), hit("repo-1", "db.go", 1, 10)
+	results := &SearchResults{
+		SemanticChunks: []*repocontextv1.CodeChunk{first, second, other, unstored},
+		SemanticVectors: map[*repocontextv1.CodeChunk][]float32{
+			first:  {1, 0},
+			second: {0, 1},
+			other:  {-1, 0},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		chunk *repocontextv1.CodeChunk
+		want  []float32
+	}{
+		{"one hit", hit("repo-1", "auth.go", 5, 8), []float32{1, 0}},
+		{"mean of covered hits", hit("repo-1", "auth.go", 1, 40), []float32{0.5, 0.5}},
+		{"other repository", hit("repo-2", "auth.go", 1, 40), []float32{-1, 0}},
+		{"between hits", hit("repo-1", "auth.go", 11, 20), nil},
+		{"hit without a vector", hit("repo-1", "db.go", 1, 10), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chunkVectors([]*repocontextv1.CodeChunk{tt.chunk}, results)[0]
+			if len(got) != len(tt.want) {
+				t.Fatalf("vector = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("vector = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDiversify(t *testing.T) {
+	// Two near-duplicate hits on session handling outrank a distinct hit on the
+	// database; the lexical-only match has no vector
+	chunk := func(path string, score float32) *repocontextv1.CodeChunk {
+		return &repocontextv1.CodeChunk{RepositoryId: "repo-1", FilePath: path, StartLine: 1, EndLine: 10, Score: score}
+	}
+	session, sessionCopy, db, lexical := chunk("session.go", 0.9), chunk("session_v2.go", 0.88), chunk("db.go", 0.8), chunk("README.md", 0.7)
+	ranked := []*repocontextv1.CodeChunk{session, sessionCopy, db, lexical}
+	results := &SearchResults{
+		SemanticChunks: []*repocontextv1.CodeChunk{session, sessionCopy, db},
+		SemanticVectors: map[*repocontextv1.CodeChunk][]float32{
+			session:     {1, 0},
+			sessionCopy: {0.99, 0.05},
+			db:          {0.1, 1},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		lambda  float32
+		results *SearchResults
+		want    []string
+	}{
+		{"duplicate pushed down", 0.7, results, []string{"session.go", "db.go", "README.md", "session_v2.go"}},
+		{"lambda 1 keeps relevance order", 1, results, []string{"session.go", "session_v2.go", "db.go", "README.md"}},
+		{"no vectors", 0.7, &SearchResults{}, []string{"session.go", "session_v2.go", "db.go", "README.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merger := NewResultMerger(10, config.MergeConfig{MMREnabled: true, MMRLambda: tt.lambda})
+			reranked := merger.diversify(ranked, tt.results)
+
+			var got []string
+			for _, chunk := range reranked {
+				got = append(got, chunk.FilePath)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("diversify order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The top two results are less alike after re-ranking
+	vectors := results.SemanticVectors
+	reranked := NewResultMerger(10, config.MergeConfig{MMREnabled: true, MMRLambda: 0.7}).diversify(ranked, results)
+	before := cosineSimilarity(vectors[ranked[0]], vectors[ranked[1]])
+	after := cosineSimilarity(vectors[reranked[0]], vectors[reranked[1]])
+	if after >= before {
+		t.Errorf("top two similarity %.2f after MMR, %.2f before; want it reduced", after, before)
+	}
+}
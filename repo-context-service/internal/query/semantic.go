@@ -0,0 +1,193 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/ingest"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+// SemanticClient searches a repository's indexed chunks by vector similarity. Scores
+// are similarities in 0-1, higher being closer, and hits scoring below minCertainty
+// are left out. The limit nearest hits after the first offset are returned, so
+// results can be paged through.
+type SemanticClient interface {
+	SearchSemantic(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error)
+	HealthCheck(ctx context.Context) error
+}
+
+// HybridSearcher is implemented by semantic clients that can fuse keyword and
+// vector search over the indexed chunks in a single query
+type HybridSearcher interface {
+	SearchHybrid(ctx context.Context, repoID, queryText string, queryVector []float32, limit, offset int, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error)
+}
+
+// SemanticHit is a semantic search hit with the vector stored for its chunk, if the
+// backend returned one
+type SemanticHit struct {
+	Chunk  *repocontextv1.CodeChunk
+	Vector []float32
+}
+
+// VectorSearcher is implemented by semantic clients that can also return the stored
+// vector of each hit, which MMR re-ranking compares chunks by. Hits are those
+// SearchSemantic would return, in the same order.
+type VectorSearcher interface {
+	SearchSemanticHits(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]SemanticHit, error)
+}
+
+// hitChunks returns the chunks of semantic hits
+func hitChunks(hits []SemanticHit) []*repocontextv1.CodeChunk {
+	if hits == nil {
+		return nil
+	}
+	chunks := make([]*repocontextv1.CodeChunk, len(hits))
+	for i, hit := range hits {
+		chunks[i] = hit.Chunk
+	}
+	return chunks
+}
+
+// VectorStore is a vector database backend. Ingestion writes chunks to it as an
+// ingest.VectorClient and chat searches them as a SemanticClient; both name a
+// repository's collection with ingest.CollectionName.
+type VectorStore interface {
+	ingest.VectorClient
+	SemanticClient
+}
+
+// SchemaMigrator is implemented by vector stores that update the schema of
+// collections created by older versions at startup
+type SchemaMigrator interface {
+	MigrateSchema(ctx context.Context) error
+}
+
+// ErrCollectionNotFound is returned when searching a repository that has no collection
+// in the vector store, because it never finished indexing or its index was deleted
+var ErrCollectionNotFound = errors.New("vector collection not found")
+
+// collectionNotFound reports a missing collection for repoID
+func collectionNotFound(repoID string) error {
+	return fmt.Errorf("%w: repository %s has not been indexed", ErrCollectionNotFound, repoID)
+}
+
+// NewVectorStore returns the vector store selected by VECTOR_BACKEND
+func NewVectorStore(cfg *config.Config, metrics *observability.Metrics, tracer *observability.Tracer) (VectorStore, error) {
+	switch cfg.VectorStore.Backend {
+	case "weaviate":
+		return NewWeaviateClient(cfg.Weaviate, metrics, tracer)
+	case "qdrant":
+		return NewQdrantClient(cfg.Qdrant, metrics, tracer), nil
+	case "pgvector":
+		return NewPgVectorClient(cfg.PgVector, metrics, tracer)
+	default:
+		return nil, fmt.Errorf("unknown vector backend %q", cfg.VectorStore.Backend)
+	}
+}
+
+// certaintyToSimilarity maps a Weaviate certainty (0 to 1) back onto cosine
+// similarity (-1 to 1), for backends that threshold on similarity
+func certaintyToSimilarity(certainty float32) float64 {
+	return 2*float64(certainty) - 1
+}
+
+// similarityToCertainty maps a cosine similarity (-1 to 1) onto Weaviate's certainty
+// scale (0 to 1), so every backend's scores merge the same way
+func similarityToCertainty(similarity float64) float32 {
+	return float32((1 + similarity) / 2)
+}
+
+// parseCreatedAt reads the RFC3339 created_at property stored with a chunk. Chunks
+// indexed before it was stored as a date have none.
+func parseCreatedAt(value interface{}) *timestamppb.Timestamp {
+	text, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	createdAt, err := time.Parse(time.RFC3339, text)
+	if err != nil {
+		return nil
+	}
+	return timestamppb.New(createdAt)
+}
+
+// filePatternFilter returns a check for the file_patterns search filter, which no
+// vector backend can express, or nil without one. Patterns match as in lexical
+// search, with a leading ! excluding files.
+func filePatternFilter(filters map[string]interface{}) func(*repocontextv1.CodeChunk) bool {
+	patterns, ok := filters["file_patterns"].([]string)
+	if !ok || len(patterns) == 0 {
+		return nil
+	}
+
+	f := newPathFilter(map[string]interface{}{"file_patterns": patterns})
+	return func(chunk *repocontextv1.CodeChunk) bool {
+		return f.matches(chunk.FilePath)
+	}
+}
+
+// maxFilteredHits bounds how many hits a search filtered after the backend query
+// reads from the backend
+const maxFilteredHits = 1000
+
+// searchFiltered returns the limit hits after the first offset that keep accepts.
+// Hits filtered out after the backend query would leave pages short and shift
+// their boundaries, so pages are read from the start until enough hits are kept,
+// the backend runs out or maxFilteredHits have been read. A nil keep queries the
+// page directly.
+func searchFiltered(limit, offset int, keep func(*repocontextv1.CodeChunk) bool, search func(limit, offset int) ([]SemanticHit, error)) ([]SemanticHit, error) {
+	if keep == nil {
+		return search(limit, offset)
+	}
+
+	want := offset + limit
+	batch := 2 * want
+	var kept []SemanticHit
+	read := 0
+	for len(kept) < want && read < maxFilteredHits {
+		if batch > maxFilteredHits-read {
+			batch = maxFilteredHits - read
+		}
+		hits, err := search(batch, read)
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range hits {
+			if keep(hit.Chunk) {
+				kept = append(kept, hit)
+			}
+		}
+		read += len(hits)
+		if len(hits) < batch {
+			break
+		}
+	}
+
+	if offset >= len(kept) {
+		return nil, nil
+	}
+	kept = kept[offset:]
+	if len(kept) > limit {
+		kept = kept[:limit]
+	}
+	return kept, nil
+}
+
+// filterLanguages returns the languages a semantic search is restricted to: the
+// languages search filter, or the single language filter
+func filterLanguages(filters map[string]interface{}) []string {
+	if languages, ok := filters["languages"].([]string); ok && len(languages) > 0 {
+		return languages
+	}
+	if language, ok := filters["language"].(string); ok && language != "" {
+		return []string{language}
+	}
+	return nil
+}
@@ -0,0 +1,595 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/ingest"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+// pgUndefinedTable is the Postgres error code for a table that doesn't exist
+const pgUndefinedTable = "42P01"
+
+// PgVectorClient stores and searches chunks in Postgres with the pgvector extension.
+// Each repository is a table named after its collection, holding the chunk properties
+// as columns and the embedding under a cosine-distance HNSW or IVFFlat index.
+type PgVectorClient struct {
+	pool    *pgxpool.Pool
+	config  config.PgVectorConfig
+	metrics *observability.Metrics
+	tracer  *observability.Tracer
+
+	// Vector dimension of each table, read from its embedding column on first use
+	dimensions      map[string]int
+	dimensionsMutex sync.RWMutex
+}
+
+func NewPgVectorClient(cfg config.PgVectorConfig, metrics *observability.Metrics, tracer *observability.Tracer) (*PgVectorClient, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PGVECTOR_DSN: %w", err)
+	}
+	poolConfig.MaxConns = cfg.MaxConns
+
+	// Connections are opened lazily, so this doesn't fail when Postgres is down
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create postgres pool: %w", err)
+	}
+
+	return &PgVectorClient{
+		pool:       pool,
+		config:     cfg,
+		metrics:    metrics,
+		tracer:     tracer,
+		dimensions: make(map[string]int),
+	}, nil
+}
+
+// Close releases the connection pool
+func (p *PgVectorClient) Close() {
+	p.pool.Close()
+}
+
+// isPgUndefinedTable reports whether err is Postgres saying the table doesn't exist
+func isPgUndefinedTable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUndefinedTable
+}
+
+// tableName quotes a collection name for use as a table identifier, keeping its case
+func tableName(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+// vectorLiteral formats a vector in pgvector's text form, e.g. [0.1,0.2,0.3]
+func vectorLiteral(vector []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, value := range vector {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(value), 'f', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// parseVectorLiteral reads pgvector's text form of a vector, e.g. [0.1,-0.2,0.3]
+func parseVectorLiteral(literal string) ([]float32, error) {
+	literal = strings.TrimSuffix(strings.TrimPrefix(literal, "["), "]")
+	if literal == "" {
+		return nil, nil
+	}
+	parts := strings.Split(literal, ",")
+	vector := make([]float32, len(parts))
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(part, 32)
+		if err != nil {
+			return nil, err
+		}
+		vector[i] = float32(value)
+	}
+	return vector, nil
+}
+
+// CreateCollection creates a repository's table and indexes if they don't exist yet
+func (p *PgVectorClient) CreateCollection(ctx context.Context, name string, dimensions int) error {
+	ctx, span := p.tracer.StartBackendCall(ctx, "pgvector", "create_collection")
+	defer span.End()
+
+	timer := observability.StartTimer()
+	defer func() { p.metrics.RecordBackendLatency("pgvector", timer.Duration()) }()
+
+	existing, err := p.tableDimensions(ctx, name)
+	if err != nil && !isPgUndefinedTable(err) {
+		return fmt.Errorf("failed to check table existence: %w", err)
+	}
+	if err == nil {
+		if existing != dimensions {
+			return fmt.Errorf("table %s has %d dimensions, expected %d", name, existing, dimensions)
+		}
+		return nil
+	}
+
+	table := tableName(name)
+	// Cosine distance matches the other backends. pgvector can't index vectors with
+	// more than 2000 dimensions, so larger embeddings fail here rather than at search.
+	indexMethod := "hnsw (embedding vector_cosine_ops)"
+	if p.config.IndexType == "ivfflat" {
+		indexMethod = "ivfflat (embedding vector_cosine_ops) WITH (lists = 100)"
+	}
+
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS vector",
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			chunk_id      text PRIMARY KEY,
+			repository_id text NOT NULL,
+			file_path     text NOT NULL,
+			language      text NOT NULL DEFAULT '',
+			symbol        text NOT NULL DEFAULT '',
+			start_line    integer NOT NULL,
+			end_line      integer NOT NULL,
+			content       text NOT NULL,
+			created_at    timestamptz,
+			embedding     vector(%d) NOT NULL
+		)`, table, dimensions),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (file_path)", tableName(name+"_file_path_idx"), table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING %s", tableName(name+"_embedding_idx"), table, indexMethod),
+	}
+	for _, statement := range statements {
+		if _, err := p.pool.Exec(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create table: %w", err)
+		}
+	}
+
+	p.setDimensions(name, dimensions)
+	return nil
+}
+
+func (p *PgVectorClient) UpsertVectors(ctx context.Context, collectionName string, vectors []*ingest.Vector) error {
+	ctx, span := p.tracer.StartBackendCall(ctx, "pgvector", "upsert_vectors")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("pgvector"),
+		observability.ResultCountAttr(len(vectors)),
+	)
+
+	query := fmt.Sprintf(`INSERT INTO %s
+		(chunk_id, repository_id, file_path, language, symbol, start_line, end_line, content, created_at, embedding)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, '')::timestamptz, $10::vector)
+		ON CONFLICT (chunk_id) DO UPDATE SET
+			repository_id = EXCLUDED.repository_id,
+			file_path     = EXCLUDED.file_path,
+			language      = EXCLUDED.language,
+			symbol        = EXCLUDED.symbol,
+			start_line    = EXCLUDED.start_line,
+			end_line      = EXCLUDED.end_line,
+			content       = EXCLUDED.content,
+			created_at    = EXCLUDED.created_at,
+			embedding     = EXCLUDED.embedding`, tableName(collectionName))
+
+	trimmed := 0
+	// PGVECTOR_BATCH_SIZE rows per round trip
+	for i := 0; i < len(vectors); i += p.config.BatchSize {
+		end := i + p.config.BatchSize
+		if end > len(vectors) {
+			end = len(vectors)
+		}
+
+		batch := &pgx.Batch{}
+		for _, vector := range vectors[i:end] {
+			properties := make(map[string]interface{}, len(vector.Metadata))
+			for key, value := range vector.Metadata {
+				properties[key] = value
+			}
+
+			// Same cap as Weaviate, so every backend returns the same content
+			if trimContentProperty(properties, maxContentPropertyBytes) {
+				trimmed++
+				observability.Logger(ctx).Debug("UpsertVectors: Trimmed chunk content", "chunk_id", vector.ID, "max_bytes", maxContentPropertyBytes)
+			}
+
+			batch.Queue(query,
+				vector.ID,
+				stringProperty(properties, "repository_id"),
+				stringProperty(properties, "file_path"),
+				stringProperty(properties, "language"),
+				stringProperty(properties, "symbol"),
+				intProperty(properties, "start_line"),
+				intProperty(properties, "end_line"),
+				stringProperty(properties, "content"),
+				stringProperty(properties, "created_at"),
+				vectorLiteral(vector.Vector),
+			)
+		}
+
+		timer := observability.StartTimer()
+		err := p.pool.SendBatch(ctx, batch).Close()
+		p.metrics.RecordBackendLatency("pgvector", timer.Duration())
+		if err != nil {
+			return fmt.Errorf("failed to insert rows %d-%d: %w", i, end, err)
+		}
+	}
+
+	if trimmed > 0 {
+		observability.SetSpanAttributes(span, observability.TrimmedCountAttr(trimmed))
+	}
+
+	return nil
+}
+
+func stringProperty(properties map[string]interface{}, key string) string {
+	value, _ := properties[key].(string)
+	return value
+}
+
+func intProperty(properties map[string]interface{}, key string) int {
+	switch value := properties[key].(type) {
+	case int:
+		return value
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	default:
+		return 0
+	}
+}
+
+// DeleteVectorsByFile removes every chunk of filePath from the table and returns how
+// many were deleted. A missing table has nothing to delete.
+func (p *PgVectorClient) DeleteVectorsByFile(ctx context.Context, collectionName, filePath string) (int, error) {
+	ctx, span := p.tracer.StartBackendCall(ctx, "pgvector", "delete_vectors_by_file")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("pgvector"),
+	)
+
+	timer := observability.StartTimer()
+	tag, err := p.pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE file_path = $1", tableName(collectionName)), filePath)
+	p.metrics.RecordBackendLatency("pgvector", timer.Duration())
+	if err != nil {
+		if isPgUndefinedTable(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to delete rows for %s: %w", filePath, err)
+	}
+
+	deleted := int(tag.RowsAffected())
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(deleted),
+	)
+
+	return deleted, nil
+}
+
+// CountVectors returns how many rows a collection's table holds
+func (p *PgVectorClient) CountVectors(ctx context.Context, collectionName string) (int, error) {
+	ctx, span := p.tracer.StartBackendCall(ctx, "pgvector", "count_vectors")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("pgvector"),
+	)
+
+	var count int
+	timer := observability.StartTimer()
+	err := p.pool.QueryRow(ctx, fmt.Sprintf("SELECT count(*) FROM %s", tableName(collectionName))).Scan(&count)
+	p.metrics.RecordBackendLatency("pgvector", timer.Duration())
+	if err != nil {
+		if isPgUndefinedTable(err) {
+			return 0, fmt.Errorf("%w: %s", ErrCollectionNotFound, collectionName)
+		}
+		return 0, fmt.Errorf("failed to count rows: %w", err)
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(count),
+	)
+
+	return count, nil
+}
+
+// ScanVectors calls fn with every row of a collection's table and its vector,
+// PGVECTOR_BATCH_SIZE rows at a time, paging through the rows in chunk ID order
+func (p *PgVectorClient) ScanVectors(ctx context.Context, collectionName string, fn func([]*ingest.Vector) error) error {
+	ctx, span := p.tracer.StartBackendCall(ctx, "pgvector", "scan_vectors")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("pgvector"),
+	)
+
+	query := fmt.Sprintf(`SELECT chunk_id, repository_id, file_path, language, symbol, start_line, end_line,
+			content, created_at, embedding::text
+		FROM %s
+		WHERE chunk_id > $1
+		ORDER BY chunk_id
+		LIMIT $2`, tableName(collectionName))
+
+	scanned := 0
+	after := ""
+	for {
+		timer := observability.StartTimer()
+		rows, err := p.pool.Query(ctx, query, after, p.config.BatchSize)
+		if err != nil {
+			p.metrics.RecordBackendLatency("pgvector", timer.Duration())
+			if isPgUndefinedTable(err) {
+				return fmt.Errorf("%w: %s", ErrCollectionNotFound, collectionName)
+			}
+			return fmt.Errorf("failed to read rows: %w", err)
+		}
+
+		var vectors []*ingest.Vector
+		for rows.Next() {
+			var id, repositoryID, filePath, language, symbol, content, embedding string
+			var startLine, endLine int
+			var createdAt *time.Time
+			if err := rows.Scan(&id, &repositoryID, &filePath, &language, &symbol, &startLine, &endLine,
+				&content, &createdAt, &embedding); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read row: %w", err)
+			}
+
+			vector, err := parseVectorLiteral(embedding)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read embedding of %s: %w", id, err)
+			}
+			metadata := map[string]interface{}{
+				"repository_id": repositoryID,
+				"file_path":     filePath,
+				"language":      language,
+				"symbol":        symbol,
+				"start_line":    startLine,
+				"end_line":      endLine,
+				"content":       content,
+			}
+			if createdAt != nil {
+				metadata["created_at"] = createdAt.UTC().Format(time.RFC3339)
+			}
+			vectors = append(vectors, &ingest.Vector{ID: id, Vector: vector, Metadata: metadata})
+			after = id
+		}
+		rows.Close()
+		p.metrics.RecordBackendLatency("pgvector", timer.Duration())
+		if err := rows.Err(); err != nil {
+			if isPgUndefinedTable(err) {
+				return fmt.Errorf("%w: %s", ErrCollectionNotFound, collectionName)
+			}
+			return fmt.Errorf("failed to read rows: %w", err)
+		}
+
+		if len(vectors) > 0 {
+			if err := fn(vectors); err != nil {
+				return err
+			}
+			scanned += len(vectors)
+		}
+		if len(vectors) < p.config.BatchSize {
+			break
+		}
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(scanned),
+	)
+
+	return nil
+}
+
+func (p *PgVectorClient) DeleteCollection(ctx context.Context, name string) error {
+	ctx, span := p.tracer.StartBackendCall(ctx, "pgvector", "delete_collection")
+	defer span.End()
+
+	if _, err := p.pool.Exec(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", tableName(name))); err != nil {
+		return fmt.Errorf("failed to drop table: %w", err)
+	}
+
+	p.setDimensions(name, 0)
+
+	return nil
+}
+
+func (p *PgVectorClient) SearchSemantic(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	hits, err := p.searchSemantic(ctx, repoID, queryVector, limit, offset, minCertainty, filters, false)
+	if err != nil {
+		return nil, err
+	}
+	return hitChunks(hits), nil
+}
+
+// SearchSemanticHits is SearchSemantic returning each chunk's stored vector too
+func (p *PgVectorClient) SearchSemanticHits(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]SemanticHit, error) {
+	return p.searchSemantic(ctx, repoID, queryVector, limit, offset, minCertainty, filters, true)
+}
+
+// searchSemantic runs a semantic search, reading the rows' embeddings if withVectors
+func (p *PgVectorClient) searchSemantic(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}, withVectors bool) ([]SemanticHit, error) {
+	ctx, span := p.tracer.StartSearch(ctx, "", "semantic")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("pgvector"),
+		observability.RepositoryAttr(repoID),
+	)
+
+	collectionName := ingest.CollectionName(repoID)
+	dimensions, err := p.indexedDimensions(ctx, collectionName)
+	if isPgUndefinedTable(err) {
+		return nil, collectionNotFound(repoID)
+	}
+	if err != nil {
+		observability.Logger(ctx).Warn("SearchSemantic: Could not determine indexed dimension", "collection", collectionName, "error", err)
+	} else if len(queryVector) != dimensions {
+		return nil, fmt.Errorf("%w: query vector has %d dimensions but repository %s was indexed with %d; the query must be embedded with the model used at ingestion",
+			ErrDimensionMismatch, len(queryVector), repoID, dimensions)
+	}
+
+	// <=> is cosine distance, 1 - similarity
+	args := []interface{}{vectorLiteral(queryVector), 1 - certaintyToSimilarity(minCertainty)}
+	conditions := []string{"embedding <=> $1::vector <= $2"}
+	if repositoryID, ok := filters["repository_id"].(string); ok {
+		args = append(args, repositoryID)
+		conditions = append(conditions, fmt.Sprintf("repository_id = $%d", len(args)))
+	}
+	if languages := filterLanguages(filters); len(languages) > 0 {
+		args = append(args, languages)
+		conditions = append(conditions, fmt.Sprintf("language = ANY($%d)", len(args)))
+	}
+	if pathPrefix, ok := filters["path_prefix"].(string); ok && pathPrefix != "" {
+		args = append(args, pathPrefix)
+		conditions = append(conditions, fmt.Sprintf("starts_with(file_path, $%d)", len(args)))
+	}
+
+	hits, err := searchFiltered(limit, offset, filePatternFilter(filters), func(limit, offset int) ([]SemanticHit, error) {
+		return p.nearestChunks(ctx, collectionName, repoID, append(args, limit, offset), conditions, withVectors)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.metrics.RecordSearchResults("semantic", len(hits))
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(len(hits)),
+	)
+
+	return hits, nil
+}
+
+// nearestChunks runs one page of a nearest-neighbour query on collectionName. The
+// last two args are the LIMIT and OFFSET.
+func (p *PgVectorClient) nearestChunks(ctx context.Context, collectionName, repoID string, args []interface{}, conditions []string, withVectors bool) ([]SemanticHit, error) {
+	query := nearestChunksQuery(collectionName, conditions, len(args), withVectors)
+
+	timer := observability.StartTimer()
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		p.metrics.RecordBackendLatency("pgvector", timer.Duration())
+		if isPgUndefinedTable(err) {
+			return nil, collectionNotFound(repoID)
+		}
+		return nil, fmt.Errorf("failed to execute search query: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []SemanticHit
+	for rows.Next() {
+		chunk := &repocontextv1.CodeChunk{
+			RepositoryId: repoID,
+			Source:       repocontextv1.SearchSource_SEARCH_SOURCE_SEMANTIC,
+		}
+		var createdAt *time.Time
+		var similarity float64
+		var embedding string
+		if err := rows.Scan(&chunk.FilePath, &chunk.Content, &chunk.Language, &chunk.Symbol,
+			&chunk.StartLine, &chunk.EndLine, &createdAt, &similarity, &embedding); err != nil {
+			return nil, fmt.Errorf("failed to read search result: %w", err)
+		}
+		if createdAt != nil {
+			chunk.CreatedAt = timestamppb.New(*createdAt)
+		}
+		chunk.Score = similarityToCertainty(similarity)
+		vector, err := parseVectorLiteral(embedding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read search result: %w", err)
+		}
+		hits = append(hits, SemanticHit{Chunk: chunk, Vector: vector})
+	}
+	p.metrics.RecordBackendLatency("pgvector", timer.Duration())
+	if err := rows.Err(); err != nil {
+		if isPgUndefinedTable(err) {
+			return nil, collectionNotFound(repoID)
+		}
+		return nil, fmt.Errorf("failed to execute search query: %w", err)
+	}
+	return hits, nil
+}
+
+// nearestChunksQuery builds the query nearestChunks runs: the closest chunks by
+// cosine distance to $1 come first, and the last two of nargs are LIMIT and OFFSET
+func nearestChunksQuery(collectionName string, conditions []string, nargs int, withVectors bool) string {
+	// The embedding is read as text, like ScanVectors does, only when asked for
+	embedding := "''"
+	if withVectors {
+		embedding = "embedding::text"
+	}
+
+	return fmt.Sprintf(`SELECT file_path, content, language, symbol, start_line, end_line, created_at,
+			1 - (embedding <=> $1::vector) AS similarity, %s
+		FROM %s
+		WHERE %s
+		ORDER BY embedding <=> $1::vector
+		LIMIT $%d OFFSET $%d`, embedding, tableName(collectionName), strings.Join(conditions, " AND "), nargs-1, nargs)
+}
+
+// tableDimensions reads a table's vector dimension from the type modifier of its
+// embedding column, returning an undefined_table error when the table doesn't exist
+func (p *PgVectorClient) tableDimensions(ctx context.Context, name string) (int, error) {
+	var dimensions *int
+	err := p.pool.QueryRow(ctx,
+		`SELECT atttypmod FROM pg_attribute WHERE attrelid = to_regclass($1) AND attname = 'embedding'`,
+		tableName(name)).Scan(&dimensions)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && dimensions == nil) {
+		return 0, &pgconn.PgError{Code: pgUndefinedTable, Message: fmt.Sprintf("relation %s does not exist", tableName(name))}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return *dimensions, nil
+}
+
+// indexedDimensions returns a table's vector dimension, remembering it
+func (p *PgVectorClient) indexedDimensions(ctx context.Context, name string) (int, error) {
+	p.dimensionsMutex.RLock()
+	dimensions, ok := p.dimensions[name]
+	p.dimensionsMutex.RUnlock()
+	if ok {
+		return dimensions, nil
+	}
+
+	dimensions, err := p.tableDimensions(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	p.setDimensions(name, dimensions)
+	return dimensions, nil
+}
+
+// setDimensions records a table's vector dimension; 0 forgets it
+func (p *PgVectorClient) setDimensions(name string, dimensions int) {
+	p.dimensionsMutex.Lock()
+	defer p.dimensionsMutex.Unlock()
+	if dimensions == 0 {
+		delete(p.dimensions, name)
+		return
+	}
+	p.dimensions[name] = dimensions
+}
+
+func (p *PgVectorClient) HealthCheck(ctx context.Context) error {
+	ctx, span := p.tracer.StartBackendCall(ctx, "pgvector", "health_check")
+	defer span.End()
+
+	return p.pool.Ping(ctx)
+}
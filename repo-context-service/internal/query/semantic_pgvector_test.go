@@ -0,0 +1,127 @@
+package query
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/ingest"
+	"repo-context-service/internal/observability"
+)
+
+func TestVectorLiteralRoundTrip(t *testing.T) {
+	vector := []float32{0.25, -1, 3.5e-05}
+
+	literal := vectorLiteral(vector)
+	if literal != "[0.25,-1,0.000035]" {
+		t.Errorf("vectorLiteral = %q", literal)
+	}
+	parsed, err := parseVectorLiteral(literal)
+	if err != nil {
+		t.Fatalf("parseVectorLiteral error = %v", err)
+	}
+	for i := range vector {
+		if parsed[i] != vector[i] {
+			t.Fatalf("round trip = %v, want %v", parsed, vector)
+		}
+	}
+}
+
+func TestNearestChunksQuery(t *testing.T) {
+	conditions := []string{"embedding <=> $1::vector <= $2", "starts_with(file_path, $3)"}
+
+	query := nearestChunksQuery("Repo_abc", conditions, 5, false)
+
+	// Nearest neighbours come first: ascending cosine distance to the query vector,
+	// the same distance the similarity and the certainty threshold are taken from
+	for _, want := range []string{
+		"1 - (embedding <=> $1::vector) AS similarity",
+		`FROM "Repo_abc"`,
+		"WHERE embedding <=> $1::vector <= $2 AND starts_with(file_path, $3)",
+		"LIMIT $4 OFFSET $5",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query %q is missing %q", query, want)
+		}
+	}
+	order := query[strings.Index(query, "ORDER BY"):strings.Index(query, "LIMIT")]
+	if strings.TrimSpace(order) != "ORDER BY embedding <=> $1::vector" {
+		t.Errorf("query orders by %q, want ascending cosine distance", strings.TrimSpace(order))
+	}
+	if strings.Contains(query, "embedding::text") {
+		t.Error("query reads embeddings that were not asked for")
+	}
+	if !strings.Contains(nearestChunksQuery("Repo_abc", conditions, 5, true), "embedding::text") {
+		t.Error("query with vectors does not read the embedding")
+	}
+
+	// A certainty threshold of 0.75 is a cosine similarity of 0.5, so a distance of 0.5
+	if distance := 1 - certaintyToSimilarity(0.75); distance != 0.5 {
+		t.Errorf("distance threshold for certainty 0.75 = %v, want 0.5", distance)
+	}
+}
+
+// TestPgVectorNearestNeighbours runs against the Postgres named by
+// PGVECTOR_TEST_DSN, which needs the vector extension available
+func TestPgVectorNearestNeighbours(t *testing.T) {
+	dsn := os.Getenv("PGVECTOR_TEST_DSN")
+	if dsn == "" {
+		t.Skip("PGVECTOR_TEST_DSN not set")
+	}
+
+	ctx := context.Background()
+	client, err := NewPgVectorClient(config.PgVectorConfig{DSN: dsn, MaxConns: 2, BatchSize: 2, IndexType: "hnsw"}, observability.NewMetrics(), observability.NewNoOpTracer())
+	if err != nil {
+		t.Fatalf("NewPgVectorClient error = %v", err)
+	}
+	defer client.Close()
+
+	collection := ingest.CollectionName("repo-pgvectortest")
+	client.DeleteCollection(ctx, collection)
+	if err := client.CreateCollection(ctx, collection, 2); err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+	defer client.DeleteCollection(ctx, collection)
+
+	vectors := []*ingest.Vector{
+		chunkVector("db.go", 1, []float32{0, 1}),
+		chunkVector("auth.go", 1, []float32{1, 0}),
+		chunkVector("internal/session.go", 11, []float32{0.8, 0.6}),
+	}
+	for _, vector := range vectors {
+		vector.Metadata["repository_id"] = "repo-pgvectortest"
+	}
+	if err := client.UpsertVectors(ctx, collection, vectors); err != nil {
+		t.Fatalf("UpsertVectors error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		filters map[string]interface{}
+		want    []string
+	}{
+		{"nearest first", nil, []string{"auth.go", "internal/session.go", "db.go"}},
+		{"path prefix", map[string]interface{}{"path_prefix": "internal/"}, []string{"internal/session.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := client.SearchSemantic(ctx, "repo-pgvectortest", []float32{1, 0.1}, 3, 0, 0, tt.filters)
+			if err != nil {
+				t.Fatalf("SearchSemantic error = %v", err)
+			}
+			if len(chunks) != len(tt.want) {
+				t.Fatalf("got %d chunks, want %d", len(chunks), len(tt.want))
+			}
+			for i, chunk := range chunks {
+				if chunk.FilePath != tt.want[i] {
+					t.Errorf("chunk %d = %s, want %s", i, chunk.FilePath, tt.want[i])
+				}
+				if i > 0 && chunk.Score > chunks[i-1].Score {
+					t.Errorf("chunk %d scores %v above the previous chunk's %v", i, chunk.Score, chunks[i-1].Score)
+				}
+			}
+		})
+	}
+}
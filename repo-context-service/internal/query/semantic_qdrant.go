@@ -0,0 +1,566 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/ingest"
+	"repo-context-service/internal/observability"
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+// QdrantClient stores and searches chunks in Qdrant through its REST API. Each
+// repository is a collection of cosine-distance points whose payload holds the same
+// chunk properties as the Weaviate schema.
+type QdrantClient struct {
+	httpClient *http.Client
+	config     config.QdrantConfig
+	metrics    *observability.Metrics
+	tracer     *observability.Tracer
+
+	// Vector dimension of each collection, read from its config on first use
+	dimensions      map[string]int
+	dimensionsMutex sync.RWMutex
+}
+
+func NewQdrantClient(cfg config.QdrantConfig, metrics *observability.Metrics, tracer *observability.Tracer) *QdrantClient {
+	return &QdrantClient{
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+		config:     cfg,
+		metrics:    metrics,
+		tracer:     tracer,
+		dimensions: make(map[string]int),
+	}
+}
+
+// qdrantStatusError is a non-2xx response from Qdrant
+type qdrantStatusError struct {
+	statusCode int
+	message    string
+}
+
+func (e *qdrantStatusError) Error() string {
+	return fmt.Sprintf("qdrant returned status %d: %s", e.statusCode, e.message)
+}
+
+// isQdrantNotFound reports whether err is Qdrant saying the collection doesn't exist
+func isQdrantNotFound(err error) bool {
+	var statusErr *qdrantStatusError
+	return errors.As(err, &statusErr) && statusErr.statusCode == http.StatusNotFound
+}
+
+// do sends a request to Qdrant and decodes the "result" field of its response into
+// result, if given
+func (q *QdrantClient) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(q.config.URL, "/")+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if q.config.APIKey != "" {
+		req.Header.Set("api-key", q.config.APIKey)
+	}
+
+	timer := observability.StartTimer()
+	resp, err := q.httpClient.Do(req)
+	q.metrics.RecordBackendLatency("qdrant", timer.Duration())
+	if err != nil {
+		return fmt.Errorf("qdrant request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read qdrant response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errResp struct {
+			Status struct {
+				Error string `json:"error"`
+			} `json:"status"`
+		}
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &errResp) == nil && errResp.Status.Error != "" {
+			message = errResp.Status.Error
+		}
+		return &qdrantStatusError{statusCode: resp.StatusCode, message: message}
+	}
+
+	if result == nil {
+		return nil
+	}
+	envelope := struct {
+		Result interface{} `json:"result"`
+	}{Result: result}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("failed to decode qdrant response: %w", err)
+	}
+	return nil
+}
+
+func collectionPath(name string, parts ...string) string {
+	return "/collections/" + url.PathEscape(name) + strings.Join(parts, "")
+}
+
+// CreateCollection creates a cosine-distance collection if it doesn't exist yet, with
+// keyword indexes on the payload fields searches and deletes filter on
+func (q *QdrantClient) CreateCollection(ctx context.Context, name string, dimensions int) error {
+	ctx, span := q.tracer.StartBackendCall(ctx, "qdrant", "create_collection")
+	defer span.End()
+
+	existing, err := q.collectionDimensions(ctx, name)
+	if err != nil && !isQdrantNotFound(err) {
+		return fmt.Errorf("failed to check collection existence: %w", err)
+	}
+	if err == nil {
+		if existing != dimensions {
+			return fmt.Errorf("collection %s has %d dimensions, expected %d", name, existing, dimensions)
+		}
+		return nil
+	}
+
+	body := map[string]interface{}{
+		"vectors": map[string]interface{}{
+			"size":     dimensions,
+			"distance": "Cosine",
+		},
+	}
+	if err := q.do(ctx, http.MethodPut, collectionPath(name), body, nil); err != nil {
+		return fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	for _, field := range []string{"repository_id", "file_path", "language"} {
+		index := map[string]interface{}{"field_name": field, "field_schema": "keyword"}
+		if err := q.do(ctx, http.MethodPut, collectionPath(name, "/index?wait=true"), index, nil); err != nil {
+			return fmt.Errorf("failed to index %s: %w", field, err)
+		}
+	}
+
+	q.setDimensions(name, dimensions)
+	return nil
+}
+
+func (q *QdrantClient) UpsertVectors(ctx context.Context, collectionName string, vectors []*ingest.Vector) error {
+	ctx, span := q.tracer.StartBackendCall(ctx, "qdrant", "upsert_vectors")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("qdrant"),
+		observability.ResultCountAttr(len(vectors)),
+	)
+
+	type point struct {
+		ID      string                 `json:"id"`
+		Vector  []float32              `json:"vector"`
+		Payload map[string]interface{} `json:"payload"`
+	}
+
+	points := make([]point, len(vectors))
+	trimmed := 0
+	for i, vector := range vectors {
+		payload := make(map[string]interface{}, len(vector.Metadata)+1)
+		for key, value := range vector.Metadata {
+			payload[key] = value
+		}
+		payload["chunk_id"] = vector.ID
+
+		// Same cap as Weaviate, so both backends return the same content
+		if trimContentProperty(payload, maxContentPropertyBytes) {
+			trimmed++
+			observability.Logger(ctx).Debug("UpsertVectors: Trimmed chunk content", "chunk_id", vector.ID, "max_bytes", maxContentPropertyBytes)
+		}
+
+		points[i] = point{
+			ID:      qdrantPointID(vector.ID),
+			Vector:  vector.Vector,
+			Payload: payload,
+		}
+	}
+
+	if trimmed > 0 {
+		observability.SetSpanAttributes(span, observability.TrimmedCountAttr(trimmed))
+	}
+
+	// QDRANT_BATCH_SIZE points per request
+	for i := 0; i < len(points); i += q.config.BatchSize {
+		end := i + q.config.BatchSize
+		if end > len(points) {
+			end = len(points)
+		}
+
+		body := map[string]interface{}{"points": points[i:end]}
+		if err := q.do(ctx, http.MethodPut, collectionPath(collectionName, "/points?wait=true"), body, nil); err != nil {
+			return fmt.Errorf("failed to upsert points %d-%d: %w", i, end, err)
+		}
+	}
+
+	return nil
+}
+
+// qdrantPointID derives a UUID from a chunk ID, since Qdrant only accepts UUIDs and
+// integers as point IDs. The same chunk always maps to the same point.
+func qdrantPointID(chunkID string) string {
+	sum := sha256.Sum256([]byte(chunkID))
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// fileFilter matches the points of one file
+func fileFilter(filePath string) map[string]interface{} {
+	return map[string]interface{}{
+		"must": []map[string]interface{}{
+			{"key": "file_path", "match": map[string]interface{}{"value": filePath}},
+		},
+	}
+}
+
+// DeleteVectorsByFile removes every chunk of filePath from the collection and returns
+// how many were deleted. A missing collection has nothing to delete.
+func (q *QdrantClient) DeleteVectorsByFile(ctx context.Context, collectionName, filePath string) (int, error) {
+	ctx, span := q.tracer.StartBackendCall(ctx, "qdrant", "delete_vectors_by_file")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("qdrant"),
+	)
+
+	var count struct {
+		Count int `json:"count"`
+	}
+	body := map[string]interface{}{"filter": fileFilter(filePath), "exact": true}
+	if err := q.do(ctx, http.MethodPost, collectionPath(collectionName, "/points/count"), body, &count); err != nil {
+		if isQdrantNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to count points for %s: %w", filePath, err)
+	}
+	if count.Count == 0 {
+		return 0, nil
+	}
+
+	body = map[string]interface{}{"filter": fileFilter(filePath)}
+	if err := q.do(ctx, http.MethodPost, collectionPath(collectionName, "/points/delete?wait=true"), body, nil); err != nil {
+		return 0, fmt.Errorf("failed to delete points for %s: %w", filePath, err)
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(count.Count),
+	)
+
+	return count.Count, nil
+}
+
+// CountVectors returns how many points a collection holds
+func (q *QdrantClient) CountVectors(ctx context.Context, collectionName string) (int, error) {
+	ctx, span := q.tracer.StartBackendCall(ctx, "qdrant", "count_vectors")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("qdrant"),
+	)
+
+	var count struct {
+		Count int `json:"count"`
+	}
+	body := map[string]interface{}{"exact": true}
+	if err := q.do(ctx, http.MethodPost, collectionPath(collectionName, "/points/count"), body, &count); err != nil {
+		if isQdrantNotFound(err) {
+			return 0, fmt.Errorf("%w: %s", ErrCollectionNotFound, collectionName)
+		}
+		return 0, fmt.Errorf("failed to count points: %w", err)
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(count.Count),
+	)
+
+	return count.Count, nil
+}
+
+// ScanVectors calls fn with every point of a collection and its vector,
+// QDRANT_BATCH_SIZE points at a time, scrolling in point ID order
+func (q *QdrantClient) ScanVectors(ctx context.Context, collectionName string, fn func([]*ingest.Vector) error) error {
+	ctx, span := q.tracer.StartBackendCall(ctx, "qdrant", "scan_vectors")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("qdrant"),
+	)
+
+	scanned := 0
+	var offset interface{}
+	for {
+		body := map[string]interface{}{
+			"limit":        q.config.BatchSize,
+			"with_payload": true,
+			"with_vector":  true,
+		}
+		if offset != nil {
+			body["offset"] = offset
+		}
+
+		var page struct {
+			Points []struct {
+				ID      interface{}            `json:"id"`
+				Vector  []float32              `json:"vector"`
+				Payload map[string]interface{} `json:"payload"`
+			} `json:"points"`
+			NextPageOffset interface{} `json:"next_page_offset"`
+		}
+		if err := q.do(ctx, http.MethodPost, collectionPath(collectionName, "/points/scroll"), body, &page); err != nil {
+			if isQdrantNotFound(err) {
+				return fmt.Errorf("%w: %s", ErrCollectionNotFound, collectionName)
+			}
+			return fmt.Errorf("failed to scroll points: %w", err)
+		}
+
+		vectors := make([]*ingest.Vector, 0, len(page.Points))
+		for _, point := range page.Points {
+			chunkID, _ := point.Payload["chunk_id"].(string)
+			metadata := make(map[string]interface{}, len(point.Payload))
+			for key, value := range point.Payload {
+				if key != "chunk_id" {
+					metadata[key] = value
+				}
+			}
+			vectors = append(vectors, &ingest.Vector{ID: chunkID, Vector: point.Vector, Metadata: metadata})
+		}
+
+		if len(vectors) > 0 {
+			if err := fn(vectors); err != nil {
+				return err
+			}
+			scanned += len(vectors)
+		}
+		if page.NextPageOffset == nil {
+			break
+		}
+		offset = page.NextPageOffset
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(scanned),
+	)
+
+	return nil
+}
+
+func (q *QdrantClient) DeleteCollection(ctx context.Context, name string) error {
+	ctx, span := q.tracer.StartBackendCall(ctx, "qdrant", "delete_collection")
+	defer span.End()
+
+	if err := q.do(ctx, http.MethodDelete, collectionPath(name), nil, nil); err != nil && !isQdrantNotFound(err) {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+
+	q.setDimensions(name, 0)
+
+	return nil
+}
+
+func (q *QdrantClient) SearchSemantic(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	hits, err := q.searchSemantic(ctx, repoID, queryVector, limit, offset, minCertainty, filters, false)
+	if err != nil {
+		return nil, err
+	}
+	return hitChunks(hits), nil
+}
+
+// SearchSemanticHits is SearchSemantic returning each chunk's stored vector too
+func (q *QdrantClient) SearchSemanticHits(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]SemanticHit, error) {
+	return q.searchSemantic(ctx, repoID, queryVector, limit, offset, minCertainty, filters, true)
+}
+
+// searchSemantic runs a semantic search, fetching the points' vectors if withVectors
+func (q *QdrantClient) searchSemantic(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}, withVectors bool) ([]SemanticHit, error) {
+	ctx, span := q.tracer.StartSearch(ctx, "", "semantic")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("qdrant"),
+		observability.RepositoryAttr(repoID),
+	)
+
+	collectionName := ingest.CollectionName(repoID)
+	dimensions, err := q.indexedDimensions(ctx, collectionName)
+	if isQdrantNotFound(err) {
+		return nil, collectionNotFound(repoID)
+	}
+	if err != nil {
+		observability.Logger(ctx).Warn("SearchSemantic: Could not determine indexed dimension", "collection", collectionName, "error", err)
+	} else if len(queryVector) != dimensions {
+		return nil, fmt.Errorf("%w: query vector has %d dimensions but repository %s was indexed with %d; the query must be embedded with the model used at ingestion",
+			ErrDimensionMismatch, len(queryVector), repoID, dimensions)
+	}
+
+	hits, err := searchFiltered(limit, offset, qdrantPostFilter(filters), func(limit, offset int) ([]SemanticHit, error) {
+		return q.searchPoints(ctx, collectionName, repoID, queryVector, limit, offset, minCertainty, filters, withVectors)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	q.metrics.RecordSearchResults("semantic", len(hits))
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(len(hits)),
+	)
+
+	return hits, nil
+}
+
+// searchPoints runs one page of a vector search on collectionName
+func (q *QdrantClient) searchPoints(ctx context.Context, collectionName, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}, withVectors bool) ([]SemanticHit, error) {
+	body := map[string]interface{}{
+		"vector":          queryVector,
+		"limit":           limit,
+		"offset":          offset,
+		"with_payload":    true,
+		"with_vector":     withVectors,
+		"score_threshold": certaintyToSimilarity(minCertainty),
+	}
+	if filter := buildQdrantFilter(filters); filter != nil {
+		body["filter"] = filter
+	}
+
+	var points []struct {
+		Score   float64                `json:"score"`
+		Payload map[string]interface{} `json:"payload"`
+		Vector  []float32              `json:"vector"`
+	}
+	if err := q.do(ctx, http.MethodPost, collectionPath(collectionName, "/points/search"), body, &points); err != nil {
+		if isQdrantNotFound(err) {
+			return nil, collectionNotFound(repoID)
+		}
+		return nil, fmt.Errorf("failed to execute search query: %w", err)
+	}
+
+	hits := make([]SemanticHit, 0, len(points))
+	for _, point := range points {
+		chunk := chunkFromPayload(point.Payload, repoID)
+		chunk.Score = similarityToCertainty(point.Score)
+		hits = append(hits, SemanticHit{Chunk: chunk, Vector: point.Vector})
+	}
+	return hits, nil
+}
+
+// qdrantPostFilter returns a check for the search filters Qdrant can't run, or nil
+// without any: file_patterns, and path_prefix since Qdrant's keyword match has no
+// prefix form. searchFiltered reads enough points that the pages stay full.
+func qdrantPostFilter(filters map[string]interface{}) func(*repocontextv1.CodeChunk) bool {
+	patterns := filePatternFilter(filters)
+	pathPrefix, _ := filters["path_prefix"].(string)
+	if pathPrefix == "" {
+		return patterns
+	}
+	return func(chunk *repocontextv1.CodeChunk) bool {
+		return strings.HasPrefix(chunk.FilePath, pathPrefix) && (patterns == nil || patterns(chunk))
+	}
+}
+
+// chunkFromPayload reads back the properties IndexEmbeddings stores with each vector
+func chunkFromPayload(payload map[string]interface{}, repoID string) *repocontextv1.CodeChunk {
+	chunk := &repocontextv1.CodeChunk{
+		RepositoryId: repoID,
+		Source:       repocontextv1.SearchSource_SEARCH_SOURCE_SEMANTIC,
+	}
+	chunk.FilePath, _ = payload["file_path"].(string)
+	chunk.Content, _ = payload["content"].(string)
+	chunk.Language, _ = payload["language"].(string)
+	chunk.Symbol, _ = payload["symbol"].(string)
+	if startLine, ok := payload["start_line"].(float64); ok {
+		chunk.StartLine = int32(startLine)
+	}
+	if endLine, ok := payload["end_line"].(float64); ok {
+		chunk.EndLine = int32(endLine)
+	}
+	chunk.CreatedAt = parseCreatedAt(payload["created_at"])
+	return chunk
+}
+
+// buildQdrantFilter translates the repository_id and language(s) search filters
+func buildQdrantFilter(filterMap map[string]interface{}) map[string]interface{} {
+	var must []map[string]interface{}
+	if value, ok := filterMap["repository_id"].(string); ok {
+		must = append(must, map[string]interface{}{"key": "repository_id", "match": map[string]interface{}{"value": value}})
+	}
+	if languages := filterLanguages(filterMap); len(languages) > 0 {
+		must = append(must, map[string]interface{}{"key": "language", "match": map[string]interface{}{"any": languages}})
+	}
+	if len(must) == 0 {
+		return nil
+	}
+	return map[string]interface{}{"must": must}
+}
+
+// collectionDimensions reads a collection's vector size from its config
+func (q *QdrantClient) collectionDimensions(ctx context.Context, name string) (int, error) {
+	var info struct {
+		Config struct {
+			Params struct {
+				Vectors struct {
+					Size int `json:"size"`
+				} `json:"vectors"`
+			} `json:"params"`
+		} `json:"config"`
+	}
+	if err := q.do(ctx, http.MethodGet, collectionPath(name), nil, &info); err != nil {
+		return 0, err
+	}
+	return info.Config.Params.Vectors.Size, nil
+}
+
+// indexedDimensions returns a collection's vector dimension, remembering it
+func (q *QdrantClient) indexedDimensions(ctx context.Context, name string) (int, error) {
+	q.dimensionsMutex.RLock()
+	dimensions, ok := q.dimensions[name]
+	q.dimensionsMutex.RUnlock()
+	if ok {
+		return dimensions, nil
+	}
+
+	dimensions, err := q.collectionDimensions(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	q.setDimensions(name, dimensions)
+	return dimensions, nil
+}
+
+// setDimensions records a collection's vector dimension; 0 forgets it
+func (q *QdrantClient) setDimensions(name string, dimensions int) {
+	q.dimensionsMutex.Lock()
+	defer q.dimensionsMutex.Unlock()
+	if dimensions == 0 {
+		delete(q.dimensions, name)
+		return
+	}
+	q.dimensions[name] = dimensions
+}
+
+func (q *QdrantClient) HealthCheck(ctx context.Context) error {
+	ctx, span := q.tracer.StartBackendCall(ctx, "qdrant", "health_check")
+	defer span.End()
+
+	// Simple check by listing collections
+	return q.do(ctx, http.MethodGet, "/collections", nil, nil)
+}
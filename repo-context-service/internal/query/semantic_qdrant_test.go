@@ -0,0 +1,237 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/ingest"
+	"repo-context-service/internal/observability"
+)
+
+// fakeQdrant serves the parts of the Qdrant REST API QdrantClient uses, from memory
+type fakeQdrant struct {
+	mu         sync.Mutex
+	dimensions map[string]int
+	points     map[string]map[string]fakePoint
+}
+
+type fakePoint struct {
+	Vector  []float32              `json:"vector"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+func newFakeQdrant() *fakeQdrant {
+	return &fakeQdrant{dimensions: make(map[string]int), points: make(map[string]map[string]fakePoint)}
+}
+
+func (f *fakeQdrant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/collections/"), "/")
+	name, action := parts[0], strings.Join(parts[1:], "/")
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	reply := func(result interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": result, "status": "ok"})
+	}
+	if _, ok := f.dimensions[name]; !ok && !(r.Method == http.MethodPut && action == "") {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": map[string]string{"error": "Not found: Collection " + name}})
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && action == "":
+		reply(map[string]interface{}{"config": map[string]interface{}{"params": map[string]interface{}{"vectors": map[string]int{"size": f.dimensions[name]}}}})
+	case r.Method == http.MethodPut && action == "":
+		vectors := body["vectors"].(map[string]interface{})
+		f.dimensions[name] = int(vectors["size"].(float64))
+		f.points[name] = make(map[string]fakePoint)
+		reply(true)
+	case r.Method == http.MethodPut && action == "index":
+		reply(map[string]string{"status": "completed"})
+	case r.Method == http.MethodPut && action == "points":
+		for _, raw := range body["points"].([]interface{}) {
+			data, _ := json.Marshal(raw)
+			var point struct {
+				ID string `json:"id"`
+				fakePoint
+			}
+			json.Unmarshal(data, &point)
+			f.points[name][point.ID] = point.fakePoint
+		}
+		reply(map[string]string{"status": "completed"})
+	case r.Method == http.MethodPost && action == "points/search":
+		f.search(w, name, body, reply)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// search ranks every point by cosine similarity, as a Cosine collection does
+func (f *fakeQdrant) search(w http.ResponseWriter, name string, body map[string]interface{}, reply func(interface{})) {
+	var query []float32
+	for _, value := range body["vector"].([]interface{}) {
+		query = append(query, float32(value.(float64)))
+	}
+	type scored struct {
+		Score   float64                `json:"score"`
+		Payload map[string]interface{} `json:"payload"`
+	}
+	var results []scored
+	for _, point := range f.points[name] {
+		score := float64(cosineSimilarity(query, point.Vector))
+		if threshold, ok := body["score_threshold"].(float64); ok && score < threshold {
+			continue
+		}
+		results = append(results, scored{Score: score, Payload: point.Payload})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+
+	offset, limit := int(body["offset"].(float64)), int(body["limit"].(float64))
+	if offset > len(results) {
+		offset = len(results)
+	}
+	results = results[offset:]
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	reply(results)
+}
+
+func newTestQdrantClient(t *testing.T) (*QdrantClient, *fakeQdrant) {
+	fake := newFakeQdrant()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cfg := config.QdrantConfig{URL: server.URL, Timeout: 5 * time.Second, BatchSize: 2}
+	return NewQdrantClient(cfg, observability.NewMetrics(), observability.NewNoOpTracer()), fake
+}
+
+// chunkVector is a stored chunk of path starting at line
+func chunkVector(path string, line int, vector []float32) *ingest.Vector {
+	return &ingest.Vector{
+		ID:     fmt.Sprintf("%s:%d", path, line),
+		Vector: vector,
+		Metadata: map[string]interface{}{
+			"repository_id": "repo-1",
+			"file_path":     path,
+			"start_line":    line,
+			"end_line":      line + 9,
+			"content":       "func " + path,
+			"language":      "go",
+		},
+	}
+}
+
+func TestQdrantUpsertAndSearch(t *testing.T) {
+	client, fake := newTestQdrantClient(t)
+	ctx := context.Background()
+	collection := ingest.CollectionName("repo-1")
+
+	if err := client.CreateCollection(ctx, collection, 2); err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+	vectors := []*ingest.Vector{
+		chunkVector("auth.go", 1, []float32{1, 0}),
+		chunkVector("db.go", 1, []float32{0, 1}),
+		chunkVector("session.go", 11, []float32{0.8, 0.6}),
+	}
+	if err := client.UpsertVectors(ctx, collection, vectors); err != nil {
+		t.Fatalf("UpsertVectors error = %v", err)
+	}
+	if got := len(fake.points[collection]); got != len(vectors) {
+		t.Fatalf("Qdrant holds %d points, want %d", got, len(vectors))
+	}
+
+	chunks, err := client.SearchSemantic(ctx, "repo-1", []float32{1, 0.1}, 2, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("SearchSemantic error = %v", err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+	if chunks[0].FilePath != "auth.go" || chunks[1].FilePath != "session.go" {
+		t.Errorf("chunks ranked %s, %s; want auth.go, session.go", chunks[0].FilePath, chunks[1].FilePath)
+	}
+	if chunks[1].StartLine != 11 || chunks[1].EndLine != 20 || chunks[1].Language != "go" {
+		t.Errorf("payload not read back: %+v", chunks[1])
+	}
+	if chunks[0].Score <= chunks[1].Score || chunks[0].Score > 1 {
+		t.Errorf("scores %v, %v aren't descending certainties", chunks[0].Score, chunks[1].Score)
+	}
+
+	if _, err := client.SearchSemantic(ctx, "repo-1", []float32{1, 0, 0}, 2, 0, 0, nil); err == nil {
+		t.Error("expected an error for a query vector of the wrong dimension")
+	}
+	if _, err := client.SearchSemantic(ctx, "repo-2", []float32{1, 0}, 2, 0, 0, nil); err == nil {
+		t.Error("expected an error for a repository without a collection")
+	}
+}
+
+func TestQdrantPathPrefixFillsPages(t *testing.T) {
+	client, _ := newTestQdrantClient(t)
+	ctx := context.Background()
+	collection := ingest.CollectionName("repo-1")
+
+	if err := client.CreateCollection(ctx, collection, 2); err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+	// The closest points are all outside the prefix
+	var vectors []*ingest.Vector
+	for i := 0; i < 6; i++ {
+		vectors = append(vectors, chunkVector(fmt.Sprintf("vendor/lib%d.go", i), 1, []float32{1, float32(i) * 0.01}))
+	}
+	for i := 0; i < 4; i++ {
+		vectors = append(vectors, chunkVector(fmt.Sprintf("internal/api/h%d.go", i), 1, []float32{1, 0.5 + float32(i)*0.1}))
+	}
+	if err := client.UpsertVectors(ctx, collection, vectors); err != nil {
+		t.Fatalf("UpsertVectors error = %v", err)
+	}
+
+	filters := map[string]interface{}{"path_prefix": "internal/"}
+	tests := []struct {
+		offset int
+		want   []string
+	}{
+		{0, []string{"internal/api/h0.go", "internal/api/h1.go"}},
+		{2, []string{"internal/api/h2.go", "internal/api/h3.go"}},
+		{4, nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("offset %d", tt.offset), func(t *testing.T) {
+			chunks, err := client.SearchSemantic(ctx, "repo-1", []float32{1, 0}, 2, tt.offset, 0, filters)
+			if err != nil {
+				t.Fatalf("SearchSemantic error = %v", err)
+			}
+			var got []string
+			for _, chunk := range chunks {
+				got = append(got, chunk.FilePath)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("page at offset %d = %v, want %v", tt.offset, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQdrantSearchMissingCollection(t *testing.T) {
+	client, _ := newTestQdrantClient(t)
+
+	chunks, err := client.SearchSemantic(context.Background(), "repo-1", []float32{1, 0}, 10, 0, 0, nil)
+	if !errors.Is(err, ErrCollectionNotFound) {
+		t.Fatalf("SearchSemantic = %v, %v; want ErrCollectionNotFound", chunks, err)
+	}
+}
@@ -0,0 +1,87 @@
+package query
+
+import (
+	"fmt"
+	"testing"
+
+	repocontextv1 "repo-context-service/proto/gen/repocontext/v1"
+)
+
+// pagedBackend serves hits by offset and limit like a vector store, counting queries
+type pagedBackend struct {
+	hits    []SemanticHit
+	queries int
+}
+
+func (b *pagedBackend) search(limit, offset int) ([]SemanticHit, error) {
+	b.queries++
+	if offset >= len(b.hits) {
+		return nil, nil
+	}
+	end := offset + limit
+	if end > len(b.hits) {
+		end = len(b.hits)
+	}
+	return b.hits[offset:end], nil
+}
+
+// alternatingHits returns n hits whose files alternate between Go and Markdown
+func alternatingHits(n int) []SemanticHit {
+	hits := make([]SemanticHit, n)
+	for i := range hits {
+		ext := "go"
+		if i%2 == 1 {
+			ext = "md"
+		}
+		hits[i] = SemanticHit{Chunk: &repocontextv1.CodeChunk{FilePath: fmt.Sprintf("file%d.%s", i, ext)}}
+	}
+	return hits
+}
+
+func TestSearchFilteredFillsPages(t *testing.T) {
+	keep := filePatternFilter(map[string]interface{}{"file_patterns": []string{"*.go"}})
+
+	tests := []struct {
+		name   string
+		total  int
+		limit  int
+		offset int
+		want   []string
+	}{
+		{"first page", 20, 3, 0, []string{"file0.go", "file2.go", "file4.go"}},
+		{"second page", 20, 3, 3, []string{"file6.go", "file8.go", "file10.go"}},
+		{"past the last page", 6, 3, 3, nil},
+		{"partial last page", 10, 3, 3, []string{"file6.go", "file8.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &pagedBackend{hits: alternatingHits(tt.total)}
+
+			hits, err := searchFiltered(tt.limit, tt.offset, keep, backend.search)
+			if err != nil {
+				t.Fatalf("searchFiltered: %v", err)
+			}
+
+			var got []string
+			for _, hit := range hits {
+				got = append(got, hit.Chunk.FilePath)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("searchFiltered = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchFilteredWithoutFilter(t *testing.T) {
+	backend := &pagedBackend{hits: alternatingHits(10)}
+
+	hits, err := searchFiltered(2, 4, nil, backend.search)
+	if err != nil {
+		t.Fatalf("searchFiltered: %v", err)
+	}
+	if len(hits) != 2 || hits[0].Chunk.FilePath != "file4.go" || backend.queries != 1 {
+		t.Errorf("unfiltered search should query the page directly; got %d hits in %d queries", len(hits), backend.queries)
+	}
+}
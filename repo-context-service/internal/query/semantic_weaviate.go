@@ -2,8 +2,12 @@ package query
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"repo-context-service/internal/config"
 	"repo-context-service/internal/ingest"
@@ -16,11 +20,25 @@ import (
 	"github.com/weaviate/weaviate/entities/models"
 )
 
+// maxContentPropertyBytes caps the size of the "content" text property sent to
+// Weaviate. A chunk made of a few extremely long lines (minified JS, embedded
+// data) can otherwise exceed the object size limit and fail the whole batch.
+const maxContentPropertyBytes = 32 * 1024
+
+// ErrDimensionMismatch is returned when a query vector's length differs from the
+// dimension the collection was indexed with, usually because the embedding model changed
+var ErrDimensionMismatch = errors.New("query vector dimension does not match the indexed dimension")
+
 type WeaviateClient struct {
 	client  *weaviate.Client
 	config  config.WeaviateConfig
 	metrics *observability.Metrics
 	tracer  *observability.Tracer
+
+	// Vector dimension of each collection, recorded on creation or read back from a
+	// stored object
+	dimensions      map[string]int
+	dimensionsMutex sync.RWMutex
 }
 
 func NewWeaviateClient(cfg config.WeaviateConfig, metrics *observability.Metrics, tracer *observability.Tracer) (*WeaviateClient, error) {
@@ -29,10 +47,12 @@ func NewWeaviateClient(cfg config.WeaviateConfig, metrics *observability.Metrics
 		authConfig = &auth.ApiKey{Value: cfg.APIKey}
 	}
 
+	// One client, and so one pool of keep-alive connections, serves every request
 	config := weaviate.Config{
 		Host:       cfg.Host,
 		Scheme:     cfg.Scheme,
 		AuthConfig: authConfig,
+		Timeout:    cfg.Timeout,
 	}
 
 	client, err := weaviate.NewClient(config)
@@ -41,10 +61,11 @@ func NewWeaviateClient(cfg config.WeaviateConfig, metrics *observability.Metrics
 	}
 
 	return &WeaviateClient{
-		client:  client,
-		config:  cfg,
-		metrics: metrics,
-		tracer:  tracer,
+		client:     client,
+		config:     cfg,
+		metrics:    metrics,
+		tracer:     tracer,
+		dimensions: make(map[string]int),
 	}, nil
 }
 
@@ -63,9 +84,16 @@ func (w *WeaviateClient) CreateCollection(ctx context.Context, name string, dime
 	}
 
 	if exists {
-		return nil // Class already exists
+		// Classes created by an older version may lack properties queries now fetch
+		class, err := w.client.Schema().ClassGetter().WithClassName(name).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get class: %w", err)
+		}
+		return w.addMissingProperties(ctx, class)
 	}
 
+	w.setDimensions(name, dimensions)
+
 	// Create class schema
 	classObj := &models.Class{
 		Class:       name,
@@ -81,6 +109,8 @@ func (w *WeaviateClient) CreateCollection(ctx context.Context, name string, dime
 				Name:        "file_path",
 				DataType:    []string{"string"},
 				Description: "Path to the file within the repository",
+				// Match whole paths so deleting one file's vectors can't hit paths sharing a token
+				Tokenization: models.PropertyTokenizationField,
 			},
 			{
 				Name:        "start_line",
@@ -102,6 +132,7 @@ func (w *WeaviateClient) CreateCollection(ctx context.Context, name string, dime
 				DataType:    []string{"string"},
 				Description: "Programming language of the code",
 			},
+			symbolProperty(),
 			{
 				Name:        "size",
 				DataType:    []string{"int"},
@@ -149,6 +180,7 @@ func (w *WeaviateClient) UpsertVectors(ctx context.Context, collectionName strin
 
 	// Convert to Weaviate objects
 	objects := make([]*models.Object, len(vectors))
+	trimmed := 0
 	for i, vector := range vectors {
 		// Convert metadata to properties
 		properties := make(map[string]interface{})
@@ -156,28 +188,262 @@ func (w *WeaviateClient) UpsertVectors(ctx context.Context, collectionName strin
 			properties[key] = value
 		}
 
-		strVector := make(models.Vector, len(vector.Vector))
-		for j, v := range vector.Vector {
-			strVector[j] = v
+		// Trim oversized content; the vector was computed on the full text and is kept as-is
+		if trimContentProperty(properties, maxContentPropertyBytes) {
+			trimmed++
+			observability.Logger(ctx).Debug("UpsertVectors: Trimmed chunk content", "chunk_id", vector.ID, "max_bytes", maxContentPropertyBytes)
 		}
 
 		objects[i] = &models.Object{
 			Class:      collectionName,
 			Properties: properties,
+			Vector:     models.C11yVector(vector.Vector),
+		}
+	}
+
+	if trimmed > 0 {
+		observability.SetSpanAttributes(span, observability.TrimmedCountAttr(trimmed))
+	}
+
+	// Batch insert, WEAVIATE_BATCH_SIZE objects at a time
+	for i := 0; i < len(objects); i += w.config.BatchSize {
+		end := i + w.config.BatchSize
+		if end > len(objects) {
+			end = len(objects)
+		}
+
+		batcher := w.client.Batch().ObjectsBatcher()
+		for _, obj := range objects[i:end] {
+			batcher.WithObject(obj)
+		}
+
+		responses, err := batcher.Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to batch insert objects %d-%d: %w", i, end, err)
+		}
+		if failures := batchObjectFailures(responses, vectors[i:end]); len(failures) > 0 {
+			w.metrics.RecordVectorObjectFailures("weaviate", len(failures))
+			for _, failure := range failures {
+				observability.Logger(ctx).Warn("UpsertVectors: Weaviate rejected an object", "failure", failure)
+			}
+			// The first few are enough to diagnose a batch without flooding the error
+			shown := failures
+			if len(shown) > 3 {
+				shown = shown[:3]
+			}
+			return fmt.Errorf("failed to batch insert objects %d-%d: %d of %d objects failed: %s",
+				i, end, len(failures), len(responses), strings.Join(shown, "; "))
+		}
+	}
+
+	return nil
+}
+
+// batchObjectFailures describes the objects of a batch that Weaviate rejected. A batch
+// request succeeds even when some of its objects fail; those carry their errors in
+// the per-object results, which are in the order the objects were sent.
+func batchObjectFailures(responses []models.ObjectsGetResponse, vectors []*ingest.Vector) []string {
+	var failures []string
+	for i, response := range responses {
+		if response.Result == nil || response.Result.Errors == nil {
+			continue
+		}
+		var messages []string
+		for _, item := range response.Result.Errors.Error {
+			if item != nil {
+				messages = append(messages, item.Message)
+			}
+		}
+		if len(messages) == 0 {
+			continue
+		}
+
+		object := fmt.Sprintf("object %d", i)
+		if i < len(vectors) {
+			object = vectors[i].ID
 		}
+		failures = append(failures, fmt.Sprintf("%s: %s", object, strings.Join(messages, ", ")))
 	}
+	return failures
+}
+
+// DeleteVectorsByFile removes every chunk of filePath from the collection and returns
+// how many were deleted. Weaviate caps a batch delete at QUERY_MAXIMUM_RESULTS
+// objects, so the delete is repeated until nothing matches. A missing collection has
+// nothing to delete.
+func (w *WeaviateClient) DeleteVectorsByFile(ctx context.Context, collectionName, filePath string) (int, error) {
+	ctx, span := w.tracer.StartBackendCall(ctx, "weaviate", "delete_vectors_by_file")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("weaviate"),
+	)
 
-	// Batch insert
-	batcher := w.client.Batch().ObjectsBatcher()
-	for _, obj := range objects {
-		batcher.WithObject(obj)
+	exists, err := w.client.Schema().ClassExistenceChecker().WithClassName(collectionName).Do(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check class existence: %w", err)
 	}
+	if !exists {
+		return 0, nil
+	}
+
+	where := filters.Where().
+		WithPath([]string{"file_path"}).
+		WithOperator(filters.Equal).
+		WithValueText(filePath)
+
+	var deleted int64
+	for {
+		timer := observability.StartTimer()
+		resp, err := w.client.Batch().ObjectsBatchDeleter().
+			WithClassName(collectionName).
+			WithWhere(where).
+			Do(ctx)
+		w.metrics.RecordBackendLatency("weaviate", timer.Duration())
+
+		if err != nil {
+			return int(deleted), fmt.Errorf("failed to delete objects for %s: %w", filePath, err)
+		}
+		if resp.Results == nil {
+			break
+		}
+
+		results := resp.Results
+		deleted += results.Successful
+		if results.Failed > 0 {
+			return int(deleted), fmt.Errorf("failed to delete %d of %d objects for %s", results.Failed, results.Matches, filePath)
+		}
+
+		// Fewer matches than the limit means this round caught them all
+		if results.Matches < results.Limit || results.Successful == 0 {
+			break
+		}
+	}
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(int(deleted)),
+	)
+
+	return int(deleted), nil
+}
+
+// CountVectors returns how many objects a collection holds, from an aggregate query
+func (w *WeaviateClient) CountVectors(ctx context.Context, collectionName string) (int, error) {
+	ctx, span := w.tracer.StartBackendCall(ctx, "weaviate", "count_vectors")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("weaviate"),
+	)
 
-	_, err := batcher.Do(ctx)
+	timer := observability.StartTimer()
+	result, err := w.client.GraphQL().Aggregate().
+		WithClassName(collectionName).
+		WithFields(graphql.Field{Name: "meta", Fields: []graphql.Field{{Name: "count"}}}).
+		Do(ctx)
+	w.metrics.RecordBackendLatency("weaviate", timer.Duration())
 	if err != nil {
-		return fmt.Errorf("failed to batch insert objects: %w", err)
+		return 0, fmt.Errorf("failed to count objects: %w", err)
+	}
+	if isMissingClassError(result.Errors, collectionName) {
+		return 0, fmt.Errorf("%w: %s", ErrCollectionNotFound, collectionName)
+	}
+	if len(result.Errors) > 0 {
+		return 0, fmt.Errorf("GraphQL errors: %v", result.Errors[0].Message)
+	}
+
+	// {"Aggregate": {"<class>": [{"meta": {"count": n}}]}}
+	aggregate, _ := result.Data["Aggregate"].(map[string]interface{})
+	groups, _ := aggregate[collectionName].([]interface{})
+	if len(groups) == 0 {
+		return 0, nil
+	}
+	group, _ := groups[0].(map[string]interface{})
+	meta, _ := group["meta"].(map[string]interface{})
+	count, _ := meta["count"].(float64)
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(int(count)),
+	)
+
+	return int(count), nil
+}
+
+// ScanVectors calls fn with every object of a collection and its vector,
+// WEAVIATE_BATCH_SIZE objects at a time. Objects are read with Weaviate's cursor,
+// in ID order.
+func (w *WeaviateClient) ScanVectors(ctx context.Context, collectionName string, fn func([]*ingest.Vector) error) error {
+	ctx, span := w.tracer.StartBackendCall(ctx, "weaviate", "scan_vectors")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("weaviate"),
+	)
+
+	scanned := 0
+	after := ""
+	for {
+		query := w.client.GraphQL().Get().
+			WithClassName(collectionName).
+			WithFields(chunkFields(graphql.Field{Name: "id"}, graphql.Field{Name: "vector"})...).
+			WithLimit(w.config.BatchSize)
+		if after != "" {
+			query = query.WithAfter(after)
+		}
+
+		timer := observability.StartTimer()
+		result, err := query.Do(ctx)
+		w.metrics.RecordBackendLatency("weaviate", timer.Duration())
+		if err != nil {
+			return fmt.Errorf("failed to read objects: %w", err)
+		}
+		if isMissingClassError(result.Errors, collectionName) {
+			return fmt.Errorf("%w: %s", ErrCollectionNotFound, collectionName)
+		}
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("GraphQL errors: %v", result.Errors[0].Message)
+		}
+
+		get, _ := result.Data["Get"].(map[string]interface{})
+		objects, _ := get[collectionName].([]interface{})
+		vectors := make([]*ingest.Vector, 0, len(objects))
+		for _, object := range objects {
+			properties, ok := object.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			additional, _ := properties["_additional"].(map[string]interface{})
+			id, _ := additional["id"].(string)
+
+			vector := &ingest.Vector{
+				ID:       id,
+				Vector:   parseVector(additional["vector"]),
+				Metadata: make(map[string]interface{}, len(properties)),
+			}
+			for key, value := range properties {
+				if key != "_additional" {
+					vector.Metadata[key] = value
+				}
+			}
+			vectors = append(vectors, vector)
+			after = id
+		}
+
+		if len(vectors) > 0 {
+			if err := fn(vectors); err != nil {
+				return err
+			}
+			scanned += len(vectors)
+		}
+		if len(objects) < w.config.BatchSize || after == "" {
+			break
+		}
 	}
 
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(scanned),
+	)
+
 	return nil
 }
 
@@ -193,10 +459,21 @@ func (w *WeaviateClient) DeleteCollection(ctx context.Context, name string) erro
 		return fmt.Errorf("failed to delete class: %w", err)
 	}
 
+	w.setDimensions(name, 0)
+
 	return nil
 }
 
-func (w *WeaviateClient) SearchSemantic(ctx context.Context, repoID string, queryVector []float32, limit int, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+func (w *WeaviateClient) SearchSemantic(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	hits, err := w.SearchSemanticHits(ctx, repoID, queryVector, limit, offset, minCertainty, filters)
+	if err != nil {
+		return nil, err
+	}
+	return hitChunks(hits), nil
+}
+
+// SearchSemanticHits is SearchSemantic returning each chunk's stored vector too
+func (w *WeaviateClient) SearchSemanticHits(ctx context.Context, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]SemanticHit, error) {
 	ctx, span := w.tracer.StartSearch(ctx, "", "semantic")
 	defer span.End()
 
@@ -210,31 +487,50 @@ func (w *WeaviateClient) SearchSemantic(ctx context.Context, repoID string, quer
 		w.metrics.RecordBackendLatency("weaviate", timer.Duration())
 	}()
 
-	// Build GraphQL query
-	fields := []graphql.Field{
-		{Name: "repository_id"},
-		{Name: "file_path"},
-		{Name: "start_line"},
-		{Name: "end_line"},
-		{Name: "content"},
-		{Name: "language"},
-		{Name: "size"},
-		{Name: "_additional", Fields: []graphql.Field{
-			{Name: "certainty"},
-			{Name: "id"},
-			{Name: "vector"},
-		}},
+	className := ingest.CollectionName(repoID)
+	if err := w.checkQueryDimensions(ctx, className, repoID, queryVector); err != nil {
+		return nil, err
+	}
+
+	hits, err := searchFiltered(limit, offset, filePatternFilter(filters), func(limit, offset int) ([]SemanticHit, error) {
+		return w.nearVector(ctx, className, repoID, queryVector, limit, offset, minCertainty, filters)
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	w.metrics.RecordSearchResults("semantic", len(hits))
+
+	observability.SetSpanAttributes(span,
+		observability.ResultCountAttr(len(hits)),
+	)
+
+	return hits, nil
+}
+
+// nearVector runs one page of a nearVector query on className
+func (w *WeaviateClient) nearVector(ctx context.Context, className, repoID string, queryVector []float32, limit, offset int, minCertainty float32, filters map[string]interface{}) ([]SemanticHit, error) {
+	// Build GraphQL query
+	fields := chunkFields(
+		graphql.Field{Name: "certainty"},
+		graphql.Field{Name: "id"},
+		graphql.Field{Name: "vector"},
+	)
+
 	nearVector := w.client.GraphQL().NearVectorArgBuilder().
-		WithVector(queryVector).
-		WithCertainty(0.7)
+		WithVector(queryVector)
+	if minCertainty > 0 {
+		nearVector = nearVector.WithCertainty(minCertainty)
+	}
 
 	query := w.client.GraphQL().Get().
-		WithClassName(toWeaviateClassName(repoID)).
+		WithClassName(className).
 		WithFields(fields...).
 		WithNearVector(nearVector).
 		WithLimit(limit)
+	if offset > 0 {
+		query = query.WithOffset(offset)
+	}
 
 	// Add filters if specified
 	if len(filters) > 0 {
@@ -250,12 +546,69 @@ func (w *WeaviateClient) SearchSemantic(ctx context.Context, repoID string, quer
 	}
 
 	// Parse results
-	chunks, err := w.parseSearchResults(result, repoID)
+	hits, err := w.parseSearchResults(result, className, repoID, repocontextv1.SearchSource_SEARCH_SOURCE_SEMANTIC)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse search results: %w", err)
 	}
+	return hits, nil
+}
+
+// SearchHybrid runs a single Weaviate hybrid query that fuses BM25 over the chunk
+// text with vector search on queryVector, weighted by the configured alpha. Chunks
+// carry the fused score.
+func (w *WeaviateClient) SearchHybrid(ctx context.Context, repoID, queryText string, queryVector []float32, limit, offset int, filters map[string]interface{}) ([]*repocontextv1.CodeChunk, error) {
+	ctx, span := w.tracer.StartSearch(ctx, queryText, "hybrid")
+	defer span.End()
+
+	observability.SetSpanAttributes(span,
+		observability.BackendAttr("weaviate"),
+		observability.RepositoryAttr(repoID),
+	)
+
+	timer := observability.StartTimer()
+	defer func() {
+		w.metrics.RecordBackendLatency("weaviate", timer.Duration())
+	}()
+
+	className := ingest.CollectionName(repoID)
+	if err := w.checkQueryDimensions(ctx, className, repoID, queryVector); err != nil {
+		return nil, err
+	}
+
+	hits, err := searchFiltered(limit, offset, filePatternFilter(filters), func(limit, offset int) ([]SemanticHit, error) {
+		query := w.client.GraphQL().Get().
+			WithClassName(className).
+			WithFields(chunkFields(graphql.Field{Name: "score"}, graphql.Field{Name: "id"})...).
+			WithHybrid(w.hybridArgument(queryText, queryVector)).
+			WithLimit(limit)
+		if offset > 0 {
+			query = query.WithOffset(offset)
+		}
+
+		if len(filters) > 0 {
+			whereFilter := buildWhereFilter(filters)
+			if whereFilter != nil {
+				query = query.WithWhere(whereFilter)
+			}
+		}
+
+		result, err := query.Do(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute hybrid query: %w", err)
+		}
+
+		hits, err := w.parseSearchResults(result, className, repoID, repocontextv1.SearchSource_SEARCH_SOURCE_HYBRID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse search results: %w", err)
+		}
+		return hits, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	chunks := hitChunks(hits)
 
-	w.metrics.RecordSearchResults("semantic", len(chunks))
+	w.metrics.RecordSearchResults("hybrid", len(chunks))
 
 	observability.SetSpanAttributes(span,
 		observability.ResultCountAttr(len(chunks)),
@@ -264,7 +617,154 @@ func (w *WeaviateClient) SearchSemantic(ctx context.Context, repoID string, quer
 	return chunks, nil
 }
 
-func (w *WeaviateClient) parseSearchResults(result *models.GraphQLResponse, repoID string) ([]*repocontextv1.CodeChunk, error) {
+// symbolProperty is the chunk property holding the enclosing function or class.
+// It was added after the first collections were created.
+func symbolProperty() *models.Property {
+	return &models.Property{
+		Name:        "symbol",
+		DataType:    []string{"string"},
+		Description: "Function or class the chunk belongs to, if known",
+	}
+}
+
+// MigrateSchema adds properties introduced since a repository's class was created
+// to every existing class, so queries fetching them don't fail on older repositories
+func (w *WeaviateClient) MigrateSchema(ctx context.Context) error {
+	schema, err := w.client.Schema().Getter().Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get schema: %w", err)
+	}
+
+	for _, class := range schema.Classes {
+		if !strings.HasPrefix(class.Class, ingest.CollectionPrefix) {
+			continue
+		}
+		if err := w.addMissingProperties(ctx, class); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addMissingProperties adds the symbol property to class if it lacks it
+func (w *WeaviateClient) addMissingProperties(ctx context.Context, class *models.Class) error {
+	for _, property := range class.Properties {
+		if property.Name == "symbol" {
+			return nil
+		}
+	}
+
+	observability.Logger(ctx).Info("MigrateSchema: Adding the symbol property", "class", class.Class)
+	if err := w.client.Schema().PropertyCreator().WithClassName(class.Class).WithProperty(symbolProperty()).Do(ctx); err != nil {
+		return fmt.Errorf("failed to add symbol property to %s: %w", class.Class, err)
+	}
+	return nil
+}
+
+// hybridArgument builds the hybrid clause. Relative score fusion keeps the BM25 and
+// vector score magnitudes instead of only their ranks.
+func (w *WeaviateClient) hybridArgument(queryText string, queryVector []float32) *graphql.HybridArgumentBuilder {
+	return w.client.GraphQL().HybridArgumentBuilder().
+		WithQuery(queryText).
+		WithVector(queryVector).
+		WithAlpha(w.config.HybridAlpha).
+		WithProperties([]string{"content", "symbol"}).
+		WithFusionType(graphql.RelativeScore)
+}
+
+// chunkFields lists the chunk properties to fetch plus the given _additional fields
+func chunkFields(additional ...graphql.Field) []graphql.Field {
+	return []graphql.Field{
+		{Name: "repository_id"},
+		{Name: "file_path"},
+		{Name: "start_line"},
+		{Name: "end_line"},
+		{Name: "content"},
+		{Name: "language"},
+		{Name: "symbol"},
+		{Name: "size"},
+		{Name: "created_at"},
+		{Name: "_additional", Fields: additional},
+	}
+}
+
+// checkQueryDimensions catches a query embedded with a different model than the
+// repository before Weaviate fails on it with an opaque error
+func (w *WeaviateClient) checkQueryDimensions(ctx context.Context, className, repoID string, queryVector []float32) error {
+	dimensions, err := w.indexedDimensions(ctx, className)
+	if errors.Is(err, ErrCollectionNotFound) {
+		return collectionNotFound(repoID)
+	}
+	if err != nil {
+		observability.Logger(ctx).Warn("checkQueryDimensions: Could not determine indexed dimension", "class", className, "error", err)
+		return nil
+	}
+
+	if dimensions > 0 && len(queryVector) != dimensions {
+		return fmt.Errorf("%w: query vector has %d dimensions but repository %s was indexed with %d; the query must be embedded with the model used at ingestion",
+			ErrDimensionMismatch, len(queryVector), repoID, dimensions)
+	}
+	return nil
+}
+
+// indexedDimensions returns the vector dimension of a collection. Collections created
+// before this process started are inspected by reading back one stored vector. It
+// returns 0 when the collection holds no objects yet.
+func (w *WeaviateClient) indexedDimensions(ctx context.Context, className string) (int, error) {
+	w.dimensionsMutex.RLock()
+	dimensions, ok := w.dimensions[className]
+	w.dimensionsMutex.RUnlock()
+	if ok {
+		return dimensions, nil
+	}
+
+	result, err := w.client.GraphQL().Get().
+		WithClassName(className).
+		WithFields(graphql.Field{Name: "_additional", Fields: []graphql.Field{{Name: "vector"}}}).
+		WithLimit(1).
+		Do(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if isMissingClassError(result.Errors, className) {
+		return 0, ErrCollectionNotFound
+	}
+	if len(result.Errors) > 0 {
+		return 0, fmt.Errorf("GraphQL errors: %v", result.Errors[0].Message)
+	}
+
+	get, _ := result.Data["Get"].(map[string]interface{})
+	objects, _ := get[className].([]interface{})
+	if len(objects) == 0 {
+		return 0, nil
+	}
+
+	object, _ := objects[0].(map[string]interface{})
+	additional, _ := object["_additional"].(map[string]interface{})
+	vector, _ := additional["vector"].([]interface{})
+	if len(vector) == 0 {
+		return 0, nil
+	}
+
+	w.setDimensions(className, len(vector))
+	return len(vector), nil
+}
+
+// setDimensions records a collection's vector dimension; 0 forgets it
+func (w *WeaviateClient) setDimensions(className string, dimensions int) {
+	w.dimensionsMutex.Lock()
+	defer w.dimensionsMutex.Unlock()
+	if dimensions == 0 {
+		delete(w.dimensions, className)
+		return
+	}
+	w.dimensions[className] = dimensions
+}
+
+func (w *WeaviateClient) parseSearchResults(result *models.GraphQLResponse, className, repoID string, source repocontextv1.SearchSource) ([]SemanticHit, error) {
+	if isMissingClassError(result.Errors, className) {
+		return nil, collectionNotFound(repoID)
+	}
 	if result.Errors != nil && len(result.Errors) > 0 {
 		return nil, fmt.Errorf("GraphQL errors: %v", result.Errors)
 	}
@@ -274,33 +774,62 @@ func (w *WeaviateClient) parseSearchResults(result *models.GraphQLResponse, repo
 		return nil, fmt.Errorf("invalid response structure: missing Get")
 	}
 
-	classData, ok := data[repoID].([]interface{})
+	// Results are keyed by class name, not repository ID
+	classData, ok := data[className].([]interface{})
 	if !ok {
 		return nil, nil // No results found
 	}
 
-	var chunks []*repocontextv1.CodeChunk
+	var hits []SemanticHit
 	for _, item := range classData {
 		itemMap, ok := item.(map[string]interface{})
 		if !ok {
 			continue
 		}
 
-		chunk, err := w.parseChunkFromResult(itemMap, repoID)
+		chunk, err := w.parseChunkFromResult(itemMap, repoID, source)
 		if err != nil {
 			continue // Skip invalid chunks
 		}
 
-		chunks = append(chunks, chunk)
+		// The vector is only there when the query asked for it
+		additional, _ := itemMap["_additional"].(map[string]interface{})
+		hits = append(hits, SemanticHit{Chunk: chunk, Vector: parseVector(additional["vector"])})
 	}
 
-	return chunks, nil
+	return hits, nil
+}
+
+// parseVector reads a vector returned in _additional
+func parseVector(value interface{}) []float32 {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	vector := make([]float32, 0, len(values))
+	for _, value := range values {
+		if f, ok := value.(float64); ok {
+			vector = append(vector, float32(f))
+		}
+	}
+	return vector
+}
+
+// isMissingClassError reports whether a GraphQL response failed because className
+// isn't in the schema, which Weaviate reports as an unknown field of Get
+func isMissingClassError(errs []*models.GraphQLError, className string) bool {
+	for _, err := range errs {
+		if err != nil && strings.Contains(err.Message, fmt.Sprintf("Cannot query field %q", className)) {
+			return true
+		}
+	}
+	return false
 }
 
-func (w *WeaviateClient) parseChunkFromResult(data map[string]interface{}, repoID string) (*repocontextv1.CodeChunk, error) {
+func (w *WeaviateClient) parseChunkFromResult(data map[string]interface{}, repoID string, source repocontextv1.SearchSource) (*repocontextv1.CodeChunk, error) {
 	chunk := &repocontextv1.CodeChunk{
 		RepositoryId: repoID,
-		Source:       repocontextv1.SearchSource_SEARCH_SOURCE_SEMANTIC,
+		Source:       source,
 	}
 
 	// Extract properties
@@ -316,6 +845,10 @@ func (w *WeaviateClient) parseChunkFromResult(data map[string]interface{}, repoI
 		chunk.Language = language
 	}
 
+	if symbol, ok := data["symbol"].(string); ok {
+		chunk.Symbol = symbol
+	}
+
 	if startLine, ok := data["start_line"].(float64); ok {
 		chunk.StartLine = int32(startLine)
 	}
@@ -324,11 +857,19 @@ func (w *WeaviateClient) parseChunkFromResult(data map[string]interface{}, repoI
 		chunk.EndLine = int32(endLine)
 	}
 
+	chunk.CreatedAt = parseCreatedAt(data["created_at"])
+
 	// Extract score from _additional
 	if additional, ok := data["_additional"].(map[string]interface{}); ok {
 		if certainty, ok := additional["certainty"].(float64); ok {
 			chunk.Score = float32(certainty)
 		}
+		// Hybrid scores come back as strings
+		if score, ok := additional["score"].(string); ok {
+			if value, err := strconv.ParseFloat(score, 32); err == nil {
+				chunk.Score = float32(value)
+			}
+		}
 	}
 
 	return chunk, nil
@@ -347,35 +888,43 @@ func buildWhereFilter(filterMap map[string]interface{}) *filters.WhereBuilder {
 		if whereBuilder == nil {
 			whereBuilder = condition
 		} else {
-			whereBuilder = whereBuilder.WithOperator(filters.And).WithOperands([]*filters.WhereBuilder{whereBuilder, condition})
+			whereBuilder = filters.Where().WithOperator(filters.And).WithOperands([]*filters.WhereBuilder{whereBuilder, condition})
 		}
 	}
 
-	// Add language filter
-	if language, ok := filterMap["language"].(string); ok {
-		condition := filters.Where().
-			WithPath([]string{"language"}).
-			WithOperator(filters.Equal).
-			WithValueText(language)
+	// Add language filter, matching any of the languages
+	if languages := filterLanguages(filterMap); len(languages) > 0 {
+		var condition *filters.WhereBuilder
+		for _, language := range languages {
+			equal := filters.Where().
+				WithPath([]string{"language"}).
+				WithOperator(filters.Equal).
+				WithValueText(language)
+			if condition == nil {
+				condition = equal
+			} else {
+				condition = filters.Where().WithOperator(filters.Or).WithOperands([]*filters.WhereBuilder{condition, equal})
+			}
+		}
 
 		if whereBuilder == nil {
 			whereBuilder = condition
 		} else {
-			whereBuilder = whereBuilder.WithOperator(filters.And).WithOperands([]*filters.WhereBuilder{whereBuilder, condition})
+			whereBuilder = filters.Where().WithOperator(filters.And).WithOperands([]*filters.WhereBuilder{whereBuilder, condition})
 		}
 	}
 
 	// Add file path prefix filter
-	if pathPrefix, ok := filterMap["path_prefix"].(string); ok {
+	if pathPrefix, ok := filterMap["path_prefix"].(string); ok && pathPrefix != "" {
 		condition := filters.Where().
 			WithPath([]string{"file_path"}).
 			WithOperator(filters.Like).
-			WithValueText(pathPrefix)
+			WithValueText(pathPrefix + "*")
 
 		if whereBuilder == nil {
 			whereBuilder = condition
 		} else {
-			whereBuilder = whereBuilder.WithOperator(filters.And).WithOperands([]*filters.WhereBuilder{whereBuilder, condition})
+			whereBuilder = filters.Where().WithOperator(filters.And).WithOperands([]*filters.WhereBuilder{whereBuilder, condition})
 		}
 	}
 
@@ -410,8 +959,18 @@ func intToPointer(i int) *int {
 	return &i
 }
 
-// toWeaviateClassName converts a repository ID to a valid Weaviate class name
-// Weaviate class names must be PascalCase and contain no hyphens or special characters
-func toWeaviateClassName(repoID string) string {
-	return "Repo" + strings.ReplaceAll(strings.TrimPrefix(repoID, "repo-"), "-", "")
-}
\ No newline at end of file
+// trimContentProperty truncates the "content" property to at most maxBytes,
+// cutting on a UTF-8 boundary. It reports whether the content was trimmed.
+func trimContentProperty(properties map[string]interface{}, maxBytes int) bool {
+	content, ok := properties["content"].(string)
+	if !ok || len(content) <= maxBytes {
+		return false
+	}
+
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	properties["content"] = content[:cut]
+	return true
+}
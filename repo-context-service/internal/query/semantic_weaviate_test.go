@@ -0,0 +1,593 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"repo-context-service/internal/config"
+	"repo-context-service/internal/ingest"
+	"repo-context-service/internal/observability"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+func TestBuildWhereFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters map[string]interface{}
+		want    []string
+	}{
+		{
+			name:    "path prefix",
+			filters: map[string]interface{}{"path_prefix": "internal/api"},
+			want:    []string{`path: ["file_path"]`, `operator: Like`, `"internal/api*"`},
+		},
+		{
+			name:    "languages",
+			filters: map[string]interface{}{"languages": []string{"go", "python"}},
+			want:    []string{`operator: Or`, `"go"`, `"python"`},
+		},
+		{
+			name:    "path prefix and language",
+			filters: map[string]interface{}{"path_prefix": "cmd/", "language": "go"},
+			want:    []string{`operator: And`, `path: ["language"]`, `"cmd/*"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where := buildWhereFilter(tt.filters)
+			if where == nil {
+				t.Fatal("buildWhereFilter returned no filter")
+			}
+			got := where.String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("where filter %s does not contain %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildWhereFilterIgnoresFilePatterns(t *testing.T) {
+	if where := buildWhereFilter(map[string]interface{}{"file_patterns": []string{"*.go"}}); where != nil {
+		t.Errorf("file_patterns can't be expressed in Weaviate; got %s", where.String())
+	}
+}
+
+func TestMigrateSchemaAddsSymbolProperty(t *testing.T) {
+	var added []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/schema":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"classes": [
+				{"class": "Repo123", "properties": [{"name": "content", "dataType": ["text"]}]},
+				{"class": "Repo456", "properties": [{"name": "symbol", "dataType": ["string"]}]},
+				{"class": "Other", "properties": []}
+			]}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/properties"):
+			added = append(added, strings.Split(r.URL.Path, "/")[3])
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"name": "symbol", "dataType": ["string"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewWeaviateClient(config.WeaviateConfig{
+		Host:    strings.TrimPrefix(server.URL, "http://"),
+		Scheme:  "http",
+		Timeout: time.Second,
+	}, observability.NewMetrics(), observability.NewNoOpTracer())
+	if err != nil {
+		t.Fatalf("NewWeaviateClient: %v", err)
+	}
+
+	if err := client.MigrateSchema(context.Background()); err != nil {
+		t.Fatalf("MigrateSchema: %v", err)
+	}
+	if len(added) != 1 || added[0] != "Repo123" {
+		t.Errorf("symbol added to %v, want only Repo123", added)
+	}
+}
+
+func TestSearchSemanticSendsMinCertainty(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		queries = append(queries, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"Get": {"` + ingest.CollectionName("repo-1") + `": []}}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewWeaviateClient(config.WeaviateConfig{
+		Host:    strings.TrimPrefix(server.URL, "http://"),
+		Scheme:  "http",
+		Timeout: time.Second,
+	}, observability.NewMetrics(), observability.NewNoOpTracer())
+	if err != nil {
+		t.Fatalf("NewWeaviateClient: %v", err)
+	}
+	client.setDimensions(ingest.CollectionName("repo-1"), 2)
+
+	tests := []struct {
+		name         string
+		minCertainty float32
+		want         string
+	}{
+		{"configured", 0.7, "certainty: 0.7"},
+		{"unset", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries = nil
+			if _, err := client.SearchSemantic(context.Background(), "repo-1", []float32{1, 0}, 10, 0, tt.minCertainty, nil); err != nil {
+				t.Fatalf("SearchSemantic error = %v", err)
+			}
+			if len(queries) != 1 {
+				t.Fatalf("sent %d queries, want 1", len(queries))
+			}
+			nearVector := queries[0][strings.Index(queries[0], "nearVector"):]
+			nearVector = nearVector[:strings.Index(nearVector, "}")]
+			if tt.want != "" && !strings.Contains(nearVector, tt.want) {
+				t.Errorf("nearVector %s doesn't carry %s", nearVector, tt.want)
+			}
+			if tt.want == "" && strings.Contains(nearVector, "certainty") {
+				t.Errorf("nearVector %s sets a certainty though none is configured", nearVector)
+			}
+		})
+	}
+}
+
+// fakeWeaviateBatch is a Weaviate holding the objects of the batches it gets. Each
+// batch succeeds, but objects of rejectPath fail within it. A batch delete removes at
+// most deleteLimit objects, if set. A GraphQL Get answers with every object of the
+// class it names, in upsert order.
+type fakeWeaviateBatch struct {
+	deleteLimit int64
+	rejectPath  string
+
+	batches [][]*models.Object
+	objects []*models.Object
+	deletes int
+}
+
+func (f *fakeWeaviateBatch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/batch/objects":
+		f.upsert(w, r)
+	case r.Method == http.MethodDelete && r.URL.Path == "/v1/batch/objects":
+		f.delete(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/graphql":
+		f.get(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/schema/"):
+		class := strings.TrimPrefix(r.URL.Path, "/v1/schema/")
+		for _, object := range f.objects {
+			if object.Class == class {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"class": "` + class + `"}`))
+				return
+			}
+		}
+		http.NotFound(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeWeaviateBatch) upsert(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Objects []*models.Object `json:"objects"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.batches = append(f.batches, body.Objects)
+
+	responses := make([]models.ObjectsGetResponse, len(body.Objects))
+	for i, object := range body.Objects {
+		result := &models.ObjectsGetResponseAO2Result{}
+		if path := object.Properties.(map[string]interface{})["file_path"]; f.rejectPath != "" && path == f.rejectPath {
+			result.Errors = &models.ErrorResponse{Error: []*models.ErrorResponseErrorItems0{
+				{Message: "invalid text property 'file_path' on class '" + object.Class + "'"},
+			}}
+		} else {
+			f.objects = append(f.objects, object)
+		}
+		responses[i] = models.ObjectsGetResponse{Object: *object, Result: result}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(responses)
+}
+
+func (f *fakeWeaviateBatch) get(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Query string `json:"query"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := make(map[string][]map[string]interface{})
+	for _, object := range f.objects {
+		if !strings.Contains(body.Query, object.Class+"(") && !strings.Contains(body.Query, object.Class+" ") {
+			continue
+		}
+		result := map[string]interface{}{"_additional": map[string]interface{}{"vector": object.Vector, "certainty": 1}}
+		for name, value := range object.Properties.(map[string]interface{}) {
+			result[name] = value
+		}
+		results[object.Class] = append(results[object.Class], result)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"Get": results}})
+}
+
+// delete handles a batch delete matching a file_path, the only filter the client sends
+func (f *fakeWeaviateBatch) delete(w http.ResponseWriter, r *http.Request) {
+	var body models.BatchDelete
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.deletes++
+	where := body.Match.Where
+	if len(where.Path) != 1 || where.Path[0] != "file_path" || where.Operator != "Equal" || where.ValueText == nil {
+		http.Error(w, "unexpected filter", http.StatusUnprocessableEntity)
+		return
+	}
+
+	limit := f.deleteLimit
+	if limit == 0 {
+		limit = 10000
+	}
+	var kept []*models.Object
+	var matches int64
+	for _, object := range f.objects {
+		if object.Class == body.Match.Class && object.Properties.(map[string]interface{})["file_path"] == *where.ValueText && matches < limit {
+			matches++
+			continue
+		}
+		kept = append(kept, object)
+	}
+	f.objects = kept
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.BatchDeleteResponse{
+		Results: &models.BatchDeleteResponseResults{Matches: matches, Limit: limit, Successful: matches},
+	})
+}
+
+// newTestWeaviateClient points a WeaviateClient at a fake server
+func newTestWeaviateClient(t *testing.T, handler http.Handler, batchSize int) *WeaviateClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client, err := NewWeaviateClient(config.WeaviateConfig{
+		Host:      strings.TrimPrefix(server.URL, "http://"),
+		Scheme:    "http",
+		Timeout:   time.Second,
+		BatchSize: batchSize,
+	}, observability.NewMetrics(), observability.NewNoOpTracer())
+	if err != nil {
+		t.Fatalf("NewWeaviateClient: %v", err)
+	}
+	return client
+}
+
+func TestUpsertVectorsTrimsOversizedContent(t *testing.T) {
+	fake := &fakeWeaviateBatch{}
+	client := newTestWeaviateClient(t, fake, 100)
+	// "é" is two bytes, so the limit falls inside a rune
+	oversized := chunkVector("big.go", 1, []float32{1, 0})
+	oversized.Metadata["content"] = "x" + strings.Repeat("é", maxContentPropertyBytes)
+	small := chunkVector("small.go", 1, []float32{0, 1})
+
+	if err := client.UpsertVectors(context.Background(), ingest.CollectionName("repo-1"), []*ingest.Vector{oversized, small}); err != nil {
+		t.Fatalf("UpsertVectors error = %v", err)
+	}
+
+	if len(fake.batches) != 1 || len(fake.batches[0]) != 2 {
+		t.Fatalf("sent batches %v, want one of both objects", fake.batches)
+	}
+	sent := fake.batches[0]
+	content := sent[0].Properties.(map[string]interface{})["content"].(string)
+	if len(content) > maxContentPropertyBytes || !utf8.ValidString(content) {
+		t.Errorf("oversized content sent as %d bytes, valid UTF-8 %v; want at most %d", len(content), utf8.ValidString(content), maxContentPropertyBytes)
+	}
+	if !strings.HasPrefix(oversized.Metadata["content"].(string), content) || len(content) < maxContentPropertyBytes-1 {
+		t.Errorf("trimmed content is not the longest prefix that fits: %d bytes", len(content))
+	}
+	if len(sent[0].Vector) != 2 || sent[0].Vector[0] != 1 {
+		t.Errorf("trimmed object's vector = %v, want the original", sent[0].Vector)
+	}
+	if got := sent[1].Properties.(map[string]interface{})["content"]; got != "func small.go" {
+		t.Errorf("small content sent as %q, want it unchanged", got)
+	}
+}
+
+func TestDeleteVectorsByFile(t *testing.T) {
+	fake := &fakeWeaviateBatch{deleteLimit: 2}
+	client := newTestWeaviateClient(t, fake, 100)
+	ctx := context.Background()
+	collection := ingest.CollectionName("repo-1")
+	if err := client.UpsertVectors(ctx, collection, []*ingest.Vector{
+		chunkVector("a.go", 1, []float32{1, 0}),
+		chunkVector("b.go", 1, []float32{0, 1}),
+		chunkVector("a.go", 11, []float32{1, 0}),
+		chunkVector("a.go", 21, []float32{1, 0}),
+		chunkVector("b.go", 11, []float32{0, 1}),
+	}); err != nil {
+		t.Fatalf("UpsertVectors error = %v", err)
+	}
+
+	// a.go has more chunks than a batch delete removes, so it takes rounds
+	deleted, err := client.DeleteVectorsByFile(ctx, collection, "a.go")
+	if err != nil {
+		t.Fatalf("DeleteVectorsByFile error = %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("deleted %d vectors, want a.go's 3", deleted)
+	}
+	if fake.deletes != 2 {
+		t.Errorf("sent %d batch deletes, want 2 rounds of at most 2", fake.deletes)
+	}
+	var remaining []string
+	for _, object := range fake.objects {
+		remaining = append(remaining, object.Properties.(map[string]interface{})["file_path"].(string))
+	}
+	if strings.Join(remaining, ",") != "b.go,b.go" {
+		t.Errorf("remaining objects are from %v, want only b.go's 2", remaining)
+	}
+
+	// A collection that was never created has nothing to delete
+	fake.deletes = 0
+	if deleted, err := client.DeleteVectorsByFile(ctx, ingest.CollectionName("repo-2"), "a.go"); err != nil || deleted != 0 {
+		t.Errorf("DeleteVectorsByFile on a missing collection = (%d, %v), want (0, nil)", deleted, err)
+	}
+	if fake.deletes != 0 {
+		t.Error("sent a batch delete for a missing collection")
+	}
+}
+
+func TestSearchSemanticRejectsDimensionMismatch(t *testing.T) {
+	className := ingest.CollectionName("repo-1")
+	var searches int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(string(body), "nearVector") {
+			searches++
+			w.Write([]byte(`{"data": {"Get": {"` + className + `": []}}}`))
+			return
+		}
+		// The probe reading back one stored vector
+		w.Write([]byte(`{"data": {"Get": {"` + className + `": [{"_additional": {"vector": [0.1, 0.2, 0.3]}}]}}}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		cached  int
+		query   []float32
+		wantErr bool
+	}{
+		{"stored dimension matches", 0, []float32{1, 0, 0}, false},
+		{"stored dimension differs", 0, []float32{1, 0}, true},
+		{"cached dimension differs", 4, []float32{1, 0, 0}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searches = 0
+			client, err := NewWeaviateClient(config.WeaviateConfig{
+				Host:    strings.TrimPrefix(server.URL, "http://"),
+				Scheme:  "http",
+				Timeout: time.Second,
+			}, observability.NewMetrics(), observability.NewNoOpTracer())
+			if err != nil {
+				t.Fatalf("NewWeaviateClient: %v", err)
+			}
+			client.setDimensions(className, tt.cached)
+
+			_, err = client.SearchSemantic(context.Background(), "repo-1", tt.query, 10, 0, 0, nil)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrDimensionMismatch) {
+					t.Fatalf("SearchSemantic error = %v, want ErrDimensionMismatch", err)
+				}
+				if searches != 0 {
+					t.Error("the mismatched query was sent to Weaviate")
+				}
+				return
+			}
+			if err != nil || searches != 1 {
+				t.Errorf("SearchSemantic = %v after %d searches, want one successful search", err, searches)
+			}
+		})
+	}
+}
+
+func TestUpsertVectorsHonoursBatchSize(t *testing.T) {
+	fake := &fakeWeaviateBatch{}
+	client := newTestWeaviateClient(t, fake, 3)
+	var vectors []*ingest.Vector
+	for i := 0; i < 7; i++ {
+		vectors = append(vectors, chunkVector(fmt.Sprintf("file%d.go", i), 1, []float32{1, 0}))
+	}
+
+	if err := client.UpsertVectors(context.Background(), ingest.CollectionName("repo-1"), vectors); err != nil {
+		t.Fatalf("UpsertVectors error = %v", err)
+	}
+
+	var sizes []int
+	var paths []string
+	for _, batch := range fake.batches {
+		sizes = append(sizes, len(batch))
+		for _, object := range batch {
+			paths = append(paths, object.Properties.(map[string]interface{})["file_path"].(string))
+		}
+	}
+	if fmt.Sprint(sizes) != "[3 3 1]" {
+		t.Errorf("batch sizes = %v, want [3 3 1]", sizes)
+	}
+	if want := "[file0.go file1.go file2.go file3.go file4.go file5.go file6.go]"; fmt.Sprint(paths) != want {
+		t.Errorf("sent %v, want every vector once in order", paths)
+	}
+}
+
+func TestSearchSemanticMissingCollection(t *testing.T) {
+	className := ingest.CollectionName("repo-1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Weaviate's answer for a class that isn't in the schema
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"errors": [{"message": "Cannot query field \"` + className + `\" on type \"GetObjectsObj\".", "locations": [{"line": 1, "column": 7}]}]}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		cached int
+	}{
+		{"dimension probe", -1},
+		{"search", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewWeaviateClient(config.WeaviateConfig{
+				Host:    strings.TrimPrefix(server.URL, "http://"),
+				Scheme:  "http",
+				Timeout: time.Second,
+			}, observability.NewMetrics(), observability.NewNoOpTracer())
+			if err != nil {
+				t.Fatalf("NewWeaviateClient: %v", err)
+			}
+			if tt.cached >= 0 {
+				client.setDimensions(className, tt.cached)
+			}
+
+			chunks, err := client.SearchSemantic(context.Background(), "repo-1", []float32{1, 0}, 10, 0, 0, nil)
+			if !errors.Is(err, ErrCollectionNotFound) {
+				t.Fatalf("SearchSemantic = %v, %v; want ErrCollectionNotFound", chunks, err)
+			}
+			if !strings.Contains(err.Error(), "repository repo-1 has not been indexed") {
+				t.Errorf("error %q doesn't name the repository", err)
+			}
+		})
+	}
+}
+
+func TestCreatedAtRoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+	vector := chunkVector("widget.go", 1, []float32{1, 0})
+	// As IndexEmbeddings stores it
+	vector.Metadata["created_at"] = createdAt.Format(time.RFC3339)
+
+	tests := []struct {
+		name  string
+		store func(t *testing.T) VectorStore
+	}{
+		{"weaviate", func(t *testing.T) VectorStore { return newTestWeaviateClient(t, &fakeWeaviateBatch{}, 100) }},
+		{"qdrant", func(t *testing.T) VectorStore {
+			client, _ := newTestQdrantClient(t)
+			if err := client.CreateCollection(context.Background(), ingest.CollectionName("repo-1"), 2); err != nil {
+				t.Fatalf("CreateCollection error = %v", err)
+			}
+			return client
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := tt.store(t)
+			if err := store.UpsertVectors(ctx, ingest.CollectionName("repo-1"), []*ingest.Vector{vector}); err != nil {
+				t.Fatalf("UpsertVectors error = %v", err)
+			}
+
+			chunks, err := store.SearchSemantic(ctx, "repo-1", []float32{1, 0}, 10, 0, 0, nil)
+			if err != nil {
+				t.Fatalf("SearchSemantic error = %v", err)
+			}
+			if len(chunks) != 1 {
+				t.Fatalf("got %d chunks, want 1", len(chunks))
+			}
+			if got := chunks[0].GetCreatedAt(); got == nil || !got.AsTime().Equal(createdAt) {
+				t.Errorf("created_at = %v, want %v", got, createdAt)
+			}
+		})
+	}
+}
+
+func TestUpsertVectorsReportsRejectedObjects(t *testing.T) {
+	fake := &fakeWeaviateBatch{rejectPath: "file2.go"}
+	client := newTestWeaviateClient(t, fake, 10)
+	var vectors []*ingest.Vector
+	for i := 0; i < 4; i++ {
+		vectors = append(vectors, chunkVector(fmt.Sprintf("file%d.go", i), 1, []float32{1, 0}))
+	}
+	var logs bytes.Buffer
+	ctx := observability.ContextWithLogger(context.Background(), slog.New(slog.NewTextHandler(&logs, nil)))
+	failuresBefore := counterValue(t, "vector_object_failures_total", map[string]string{"backend": "weaviate"})
+
+	err := client.UpsertVectors(ctx, ingest.CollectionName("repo-1"), vectors)
+	if err == nil {
+		t.Fatal("UpsertVectors succeeded though Weaviate rejected an object")
+	}
+	for _, want := range []string{"1 of 4 objects failed", "file2.go:1", "invalid text property"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+	if len(fake.objects) != 3 {
+		t.Errorf("Weaviate holds %d objects, want the 3 it accepted", len(fake.objects))
+	}
+
+	// Each rejected chunk is logged and counted
+	if got := counterValue(t, "vector_object_failures_total", map[string]string{"backend": "weaviate"}) - failuresBefore; got != 1 {
+		t.Errorf("counted %v rejected objects, want 1", got)
+	}
+	if !strings.Contains(logs.String(), "Weaviate rejected an object") || !strings.Contains(logs.String(), "file2.go:1") {
+		t.Errorf("logs %q don't report the rejected file2.go chunk", logs.String())
+	}
+}
+
+// counterValue reads the value of a registered counter with the given labels
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
@@ -80,6 +80,7 @@ const (
 	SearchSource_SEARCH_SOURCE_LEXICAL     SearchSource = 1
 	SearchSource_SEARCH_SOURCE_SEMANTIC    SearchSource = 2
 	SearchSource_SEARCH_SOURCE_MERGED      SearchSource = 3
+	SearchSource_SEARCH_SOURCE_HYBRID      SearchSource = 4
 )
 
 // Enum value maps for SearchSource.
@@ -89,12 +90,14 @@ var (
 		1: "SEARCH_SOURCE_LEXICAL",
 		2: "SEARCH_SOURCE_SEMANTIC",
 		3: "SEARCH_SOURCE_MERGED",
+		4: "SEARCH_SOURCE_HYBRID",
 	}
 	SearchSource_value = map[string]int32{
 		"SEARCH_SOURCE_UNSPECIFIED": 0,
 		"SEARCH_SOURCE_LEXICAL":     1,
 		"SEARCH_SOURCE_SEMANTIC":    2,
 		"SEARCH_SOURCE_MERGED":      3,
+		"SEARCH_SOURCE_HYBRID":      4,
 	}
 )
 
@@ -125,6 +128,60 @@ func (SearchSource) EnumDescriptor() ([]byte, []int) {
 	return file_repocontext_proto_rawDescGZIP(), []int{1}
 }
 
+// SearchMode selects the backends a query runs against. Lexical-only skips the
+// query embedding entirely.
+type SearchMode int32
+
+const (
+	SearchMode_SEARCH_MODE_UNSPECIFIED SearchMode = 0
+	SearchMode_SEARCH_MODE_BOTH        SearchMode = 1
+	SearchMode_SEARCH_MODE_LEXICAL     SearchMode = 2
+	SearchMode_SEARCH_MODE_SEMANTIC    SearchMode = 3
+)
+
+// Enum value maps for SearchMode.
+var (
+	SearchMode_name = map[int32]string{
+		0: "SEARCH_MODE_UNSPECIFIED",
+		1: "SEARCH_MODE_BOTH",
+		2: "SEARCH_MODE_LEXICAL",
+		3: "SEARCH_MODE_SEMANTIC",
+	}
+	SearchMode_value = map[string]int32{
+		"SEARCH_MODE_UNSPECIFIED": 0,
+		"SEARCH_MODE_BOTH":        1,
+		"SEARCH_MODE_LEXICAL":     2,
+		"SEARCH_MODE_SEMANTIC":    3,
+	}
+)
+
+func (x SearchMode) Enum() *SearchMode {
+	p := new(SearchMode)
+	*p = x
+	return p
+}
+
+func (x SearchMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SearchMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_repocontext_proto_enumTypes[2].Descriptor()
+}
+
+func (SearchMode) Type() protoreflect.EnumType {
+	return &file_repocontext_proto_enumTypes[2]
+}
+
+func (x SearchMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SearchMode.Descriptor instead.
+func (SearchMode) EnumDescriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{2}
+}
+
 type IngestionStatus_State int32
 
 const (
@@ -173,11 +230,11 @@ func (x IngestionStatus_State) String() string {
 }
 
 func (IngestionStatus_State) Descriptor() protoreflect.EnumDescriptor {
-	return file_repocontext_proto_enumTypes[2].Descriptor()
+	return file_repocontext_proto_enumTypes[3].Descriptor()
 }
 
 func (IngestionStatus_State) Type() protoreflect.EnumType {
-	return &file_repocontext_proto_enumTypes[2]
+	return &file_repocontext_proto_enumTypes[3]
 }
 
 func (x IngestionStatus_State) Number() protoreflect.EnumNumber {
@@ -186,7 +243,7 @@ func (x IngestionStatus_State) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use IngestionStatus_State.Descriptor instead.
 func (IngestionStatus_State) EnumDescriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{9, 0}
+	return file_repocontext_proto_rawDescGZIP(), []int{11, 0}
 }
 
 type HealthCheckResponse_ServingStatus int32
@@ -225,11 +282,11 @@ func (x HealthCheckResponse_ServingStatus) String() string {
 }
 
 func (HealthCheckResponse_ServingStatus) Descriptor() protoreflect.EnumDescriptor {
-	return file_repocontext_proto_enumTypes[3].Descriptor()
+	return file_repocontext_proto_enumTypes[4].Descriptor()
 }
 
 func (HealthCheckResponse_ServingStatus) Type() protoreflect.EnumType {
-	return &file_repocontext_proto_enumTypes[3]
+	return &file_repocontext_proto_enumTypes[4]
 }
 
 func (x HealthCheckResponse_ServingStatus) Number() protoreflect.EnumNumber {
@@ -238,7 +295,7 @@ func (x HealthCheckResponse_ServingStatus) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use HealthCheckResponse_ServingStatus.Descriptor instead.
 func (HealthCheckResponse_ServingStatus) EnumDescriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{38, 0}
+	return file_repocontext_proto_rawDescGZIP(), []int{66, 0}
 }
 
 // Upload Messages
@@ -354,8 +411,10 @@ type UploadGitRepositoryRequest struct {
 	TenantId       string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
 	IdempotencyKey string                 `protobuf:"bytes,3,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
 	Options        *UploadOptions         `protobuf:"bytes,4,opt,name=options,proto3" json:"options,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+	// Ingest again even if this commit of the repository is already indexed
+	Force         bool `protobuf:"varint,5,opt,name=force,proto3" json:"force,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *UploadGitRepositoryRequest) Reset() {
@@ -416,6 +475,13 @@ func (x *UploadGitRepositoryRequest) GetOptions() *UploadOptions {
 	return nil
 }
 
+func (x *UploadGitRepositoryRequest) GetForce() bool {
+	if x != nil {
+		return x.Force
+	}
+	return false
+}
+
 type FileUpload struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Filename      string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
@@ -594,8 +660,11 @@ type UploadOptions struct {
 	ExcludePatterns []string               `protobuf:"bytes,2,rep,name=exclude_patterns,json=excludePatterns,proto3" json:"exclude_patterns,omitempty"`
 	MaxFileSizeMb   int32                  `protobuf:"varint,3,opt,name=max_file_size_mb,json=maxFileSizeMb,proto3" json:"max_file_size_mb,omitempty"`
 	SkipBinaries    bool                   `protobuf:"varint,4,opt,name=skip_binaries,json=skipBinaries,proto3" json:"skip_binaries,omitempty"`
-	unknownFields   protoimpl.UnknownFields
-	sizeCache       protoimpl.SizeCache
+	// Lines per chunk and lines shared between consecutive chunks; 0 uses the server default
+	ChunkSize     int32 `protobuf:"varint,5,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`
+	ChunkOverlap  int32 `protobuf:"varint,6,opt,name=chunk_overlap,json=chunkOverlap,proto3" json:"chunk_overlap,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *UploadOptions) Reset() {
@@ -656,6 +725,20 @@ func (x *UploadOptions) GetSkipBinaries() bool {
 	return false
 }
 
+func (x *UploadOptions) GetChunkSize() int32 {
+	if x != nil {
+		return x.ChunkSize
+	}
+	return 0
+}
+
+func (x *UploadOptions) GetChunkOverlap() int32 {
+	if x != nil {
+		return x.ChunkOverlap
+	}
+	return 0
+}
+
 type UploadRepositoryResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UploadId      string                 `protobuf:"bytes,1,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
@@ -852,17 +935,141 @@ func (x *GetUploadStatusResponse) GetErrorMessage() string {
 	return ""
 }
 
-type IngestionStatus struct {
+type CancelUploadRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	State         IngestionStatus_State  `protobuf:"varint,1,opt,name=state,proto3,enum=repocontext.v1.IngestionStatus_State" json:"state,omitempty"`
-	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	UploadId      string                 `protobuf:"bytes,1,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	TenantId      string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelUploadRequest) Reset() {
+	*x = CancelUploadRequest{}
+	mi := &file_repocontext_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelUploadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelUploadRequest) ProtoMessage() {}
+
+func (x *CancelUploadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelUploadRequest.ProtoReflect.Descriptor instead.
+func (*CancelUploadRequest) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CancelUploadRequest) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+func (x *CancelUploadRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type CancelUploadResponse struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	UploadId     string                 `protobuf:"bytes,1,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	RepositoryId string                 `protobuf:"bytes,2,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	// Status after cancelling
+	Status *IngestionStatus `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	// False if the ingestion had already finished
+	Cancelled     bool `protobuf:"varint,4,opt,name=cancelled,proto3" json:"cancelled,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelUploadResponse) Reset() {
+	*x = CancelUploadResponse{}
+	mi := &file_repocontext_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelUploadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelUploadResponse) ProtoMessage() {}
+
+func (x *CancelUploadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelUploadResponse.ProtoReflect.Descriptor instead.
+func (*CancelUploadResponse) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *CancelUploadResponse) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+func (x *CancelUploadResponse) GetRepositoryId() string {
+	if x != nil {
+		return x.RepositoryId
+	}
+	return ""
+}
+
+func (x *CancelUploadResponse) GetStatus() *IngestionStatus {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *CancelUploadResponse) GetCancelled() bool {
+	if x != nil {
+		return x.Cancelled
+	}
+	return false
+}
+
+type IngestionStatus struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	State     IngestionStatus_State  `protobuf:"varint,1,opt,name=state,proto3,enum=repocontext.v1.IngestionStatus_State" json:"state,omitempty"`
+	UpdatedAt *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	// Why ingestion failed; set with STATE_FAILED
+	ErrorMessage  string `protobuf:"bytes,3,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *IngestionStatus) Reset() {
 	*x = IngestionStatus{}
-	mi := &file_repocontext_proto_msgTypes[9]
+	mi := &file_repocontext_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -874,7 +1081,7 @@ func (x *IngestionStatus) String() string {
 func (*IngestionStatus) ProtoMessage() {}
 
 func (x *IngestionStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[9]
+	mi := &file_repocontext_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -887,7 +1094,7 @@ func (x *IngestionStatus) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use IngestionStatus.ProtoReflect.Descriptor instead.
 func (*IngestionStatus) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{9}
+	return file_repocontext_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *IngestionStatus) GetState() IngestionStatus_State {
@@ -904,6 +1111,13 @@ func (x *IngestionStatus) GetUpdatedAt() *timestamppb.Timestamp {
 	return nil
 }
 
+func (x *IngestionStatus) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
 type IngestionProgress struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
 	TotalFiles      int32                  `protobuf:"varint,1,opt,name=total_files,json=totalFiles,proto3" json:"total_files,omitempty"`
@@ -918,7 +1132,7 @@ type IngestionProgress struct {
 
 func (x *IngestionProgress) Reset() {
 	*x = IngestionProgress{}
-	mi := &file_repocontext_proto_msgTypes[10]
+	mi := &file_repocontext_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -930,7 +1144,7 @@ func (x *IngestionProgress) String() string {
 func (*IngestionProgress) ProtoMessage() {}
 
 func (x *IngestionProgress) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[10]
+	mi := &file_repocontext_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -943,7 +1157,7 @@ func (x *IngestionProgress) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use IngestionProgress.ProtoReflect.Descriptor instead.
 func (*IngestionProgress) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{10}
+	return file_repocontext_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *IngestionProgress) GetTotalFiles() int32 {
@@ -1003,7 +1217,7 @@ type ChatRequest struct {
 
 func (x *ChatRequest) Reset() {
 	*x = ChatRequest{}
-	mi := &file_repocontext_proto_msgTypes[11]
+	mi := &file_repocontext_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1015,7 +1229,7 @@ func (x *ChatRequest) String() string {
 func (*ChatRequest) ProtoMessage() {}
 
 func (x *ChatRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[11]
+	mi := &file_repocontext_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1028,7 +1242,7 @@ func (x *ChatRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatRequest.ProtoReflect.Descriptor instead.
 func (*ChatRequest) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{11}
+	return file_repocontext_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *ChatRequest) GetMessage() isChatRequest_Message {
@@ -1098,7 +1312,7 @@ type ChatStart struct {
 
 func (x *ChatStart) Reset() {
 	*x = ChatStart{}
-	mi := &file_repocontext_proto_msgTypes[12]
+	mi := &file_repocontext_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1110,7 +1324,7 @@ func (x *ChatStart) String() string {
 func (*ChatStart) ProtoMessage() {}
 
 func (x *ChatStart) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[12]
+	mi := &file_repocontext_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1123,7 +1337,7 @@ func (x *ChatStart) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatStart.ProtoReflect.Descriptor instead.
 func (*ChatStart) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{12}
+	return file_repocontext_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *ChatStart) GetRepositoryId() string {
@@ -1148,17 +1362,18 @@ func (x *ChatStart) GetOptions() *ChatOptions {
 }
 
 type ChatMessage struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
-	SessionId     string                 `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
-	Filters       *SearchFilters         `protobuf:"bytes,3,opt,name=filters,proto3" json:"filters,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Query          string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	SessionId      string                 `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Filters        *SearchFilters         `protobuf:"bytes,3,opt,name=filters,proto3" json:"filters,omitempty"`
+	LexicalOptions *LexicalOptions        `protobuf:"bytes,4,opt,name=lexical_options,json=lexicalOptions,proto3" json:"lexical_options,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *ChatMessage) Reset() {
 	*x = ChatMessage{}
-	mi := &file_repocontext_proto_msgTypes[13]
+	mi := &file_repocontext_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1170,7 +1385,7 @@ func (x *ChatMessage) String() string {
 func (*ChatMessage) ProtoMessage() {}
 
 func (x *ChatMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[13]
+	mi := &file_repocontext_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1183,7 +1398,7 @@ func (x *ChatMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatMessage.ProtoReflect.Descriptor instead.
 func (*ChatMessage) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{13}
+	return file_repocontext_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *ChatMessage) GetQuery() string {
@@ -1207,6 +1422,13 @@ func (x *ChatMessage) GetFilters() *SearchFilters {
 	return nil
 }
 
+func (x *ChatMessage) GetLexicalOptions() *LexicalOptions {
+	if x != nil {
+		return x.LexicalOptions
+	}
+	return nil
+}
+
 type ChatCancel struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
@@ -1216,7 +1438,7 @@ type ChatCancel struct {
 
 func (x *ChatCancel) Reset() {
 	*x = ChatCancel{}
-	mi := &file_repocontext_proto_msgTypes[14]
+	mi := &file_repocontext_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1228,7 +1450,7 @@ func (x *ChatCancel) String() string {
 func (*ChatCancel) ProtoMessage() {}
 
 func (x *ChatCancel) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[14]
+	mi := &file_repocontext_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1241,7 +1463,7 @@ func (x *ChatCancel) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatCancel.ProtoReflect.Descriptor instead.
 func (*ChatCancel) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{14}
+	return file_repocontext_proto_rawDescGZIP(), []int{16}
 }
 
 func (x *ChatCancel) GetSessionId() string {
@@ -1252,17 +1474,24 @@ func (x *ChatCancel) GetSessionId() string {
 }
 
 type ChatOptions struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	MaxResults    int32                  `protobuf:"varint,1,opt,name=max_results,json=maxResults,proto3" json:"max_results,omitempty"`
-	StreamTokens  bool                   `protobuf:"varint,2,opt,name=stream_tokens,json=streamTokens,proto3" json:"stream_tokens,omitempty"`
-	Model         string                 `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	MaxResults   int32                  `protobuf:"varint,1,opt,name=max_results,json=maxResults,proto3" json:"max_results,omitempty"`
+	StreamTokens bool                   `protobuf:"varint,2,opt,name=stream_tokens,json=streamTokens,proto3" json:"stream_tokens,omitempty"`
+	Model        string                 `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	// Which search backends to query; unspecified searches both
+	SearchMode SearchMode `protobuf:"varint,4,opt,name=search_mode,json=searchMode,proto3,enum=repocontext.v1.SearchMode" json:"search_mode,omitempty"`
+	// Lines of surrounding code read from the repository around each semantic hit;
+	// 0 uses the server default
+	ContextLines int32 `protobuf:"varint,5,opt,name=context_lines,json=contextLines,proto3" json:"context_lines,omitempty"`
+	// Restricts every search of the session; a chat message's own filters replace these
+	Filters       *SearchFilters `protobuf:"bytes,6,opt,name=filters,proto3" json:"filters,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ChatOptions) Reset() {
 	*x = ChatOptions{}
-	mi := &file_repocontext_proto_msgTypes[15]
+	mi := &file_repocontext_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1274,7 +1503,7 @@ func (x *ChatOptions) String() string {
 func (*ChatOptions) ProtoMessage() {}
 
 func (x *ChatOptions) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[15]
+	mi := &file_repocontext_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1287,7 +1516,7 @@ func (x *ChatOptions) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatOptions.ProtoReflect.Descriptor instead.
 func (*ChatOptions) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{15}
+	return file_repocontext_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *ChatOptions) GetMaxResults() int32 {
@@ -1311,30 +1540,57 @@ func (x *ChatOptions) GetModel() string {
 	return ""
 }
 
-type SearchFilters struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Languages     []string               `protobuf:"bytes,1,rep,name=languages,proto3" json:"languages,omitempty"`
-	FilePatterns  []string               `protobuf:"bytes,2,rep,name=file_patterns,json=filePatterns,proto3" json:"file_patterns,omitempty"`
-	PathPrefix    string                 `protobuf:"bytes,3,opt,name=path_prefix,json=pathPrefix,proto3" json:"path_prefix,omitempty"`
+func (x *ChatOptions) GetSearchMode() SearchMode {
+	if x != nil {
+		return x.SearchMode
+	}
+	return SearchMode_SEARCH_MODE_UNSPECIFIED
+}
+
+func (x *ChatOptions) GetContextLines() int32 {
+	if x != nil {
+		return x.ContextLines
+	}
+	return 0
+}
+
+func (x *ChatOptions) GetFilters() *SearchFilters {
+	if x != nil {
+		return x.Filters
+	}
+	return nil
+}
+
+// LexicalOptions tunes lexical search. Setting any of the regex, case_sensitive or
+// whole_word flags switches from fuzzy term expansion to searching for the query as given.
+type LexicalOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Treat the query as a regular expression instead of a literal string
+	Regex         bool `protobuf:"varint,1,opt,name=regex,proto3" json:"regex,omitempty"`
+	CaseSensitive bool `protobuf:"varint,2,opt,name=case_sensitive,json=caseSensitive,proto3" json:"case_sensitive,omitempty"`
+	// Only match whole words
+	WholeWord bool `protobuf:"varint,3,opt,name=whole_word,json=wholeWord,proto3" json:"whole_word,omitempty"`
+	// Lines of context around each lexical match; unset uses the server default
+	ContextLines  *int32 `protobuf:"varint,4,opt,name=context_lines,json=contextLines,proto3,oneof" json:"context_lines,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SearchFilters) Reset() {
-	*x = SearchFilters{}
-	mi := &file_repocontext_proto_msgTypes[16]
+func (x *LexicalOptions) Reset() {
+	*x = LexicalOptions{}
+	mi := &file_repocontext_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SearchFilters) String() string {
+func (x *LexicalOptions) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SearchFilters) ProtoMessage() {}
+func (*LexicalOptions) ProtoMessage() {}
 
-func (x *SearchFilters) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[16]
+func (x *LexicalOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1345,36 +1601,107 @@ func (x *SearchFilters) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SearchFilters.ProtoReflect.Descriptor instead.
-func (*SearchFilters) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{16}
+// Deprecated: Use LexicalOptions.ProtoReflect.Descriptor instead.
+func (*LexicalOptions) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{18}
 }
 
-func (x *SearchFilters) GetLanguages() []string {
+func (x *LexicalOptions) GetRegex() bool {
 	if x != nil {
-		return x.Languages
+		return x.Regex
 	}
-	return nil
+	return false
 }
 
-func (x *SearchFilters) GetFilePatterns() []string {
+func (x *LexicalOptions) GetCaseSensitive() bool {
 	if x != nil {
-		return x.FilePatterns
+		return x.CaseSensitive
 	}
-	return nil
+	return false
 }
 
-func (x *SearchFilters) GetPathPrefix() string {
+func (x *LexicalOptions) GetWholeWord() bool {
 	if x != nil {
-		return x.PathPrefix
+		return x.WholeWord
 	}
-	return ""
+	return false
 }
 
-type ChatResponse struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	// Types that are valid to be assigned to Message:
-	//
+func (x *LexicalOptions) GetContextLines() int32 {
+	if x != nil && x.ContextLines != nil {
+		return *x.ContextLines
+	}
+	return 0
+}
+
+// SearchFilters restricts lexical and semantic search to some of a repository's files
+type SearchFilters struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Languages as detected at ingestion, e.g. "go" or "python"; any of them matches
+	Languages []string `protobuf:"bytes,1,rep,name=languages,proto3" json:"languages,omitempty"`
+	// Globs matched like ripgrep's --glob; a leading ! excludes matching files
+	FilePatterns []string `protobuf:"bytes,2,rep,name=file_patterns,json=filePatterns,proto3" json:"file_patterns,omitempty"`
+	// Repository-relative directory or path prefix, e.g. "internal/api/"
+	PathPrefix    string `protobuf:"bytes,3,opt,name=path_prefix,json=pathPrefix,proto3" json:"path_prefix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchFilters) Reset() {
+	*x = SearchFilters{}
+	mi := &file_repocontext_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchFilters) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchFilters) ProtoMessage() {}
+
+func (x *SearchFilters) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchFilters.ProtoReflect.Descriptor instead.
+func (*SearchFilters) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *SearchFilters) GetLanguages() []string {
+	if x != nil {
+		return x.Languages
+	}
+	return nil
+}
+
+func (x *SearchFilters) GetFilePatterns() []string {
+	if x != nil {
+		return x.FilePatterns
+	}
+	return nil
+}
+
+func (x *SearchFilters) GetPathPrefix() string {
+	if x != nil {
+		return x.PathPrefix
+	}
+	return ""
+}
+
+type ChatResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Message:
+	//
 	//	*ChatResponse_SearchStarted
 	//	*ChatResponse_SearchHit
 	//	*ChatResponse_CompositionStarted
@@ -1389,7 +1716,7 @@ type ChatResponse struct {
 
 func (x *ChatResponse) Reset() {
 	*x = ChatResponse{}
-	mi := &file_repocontext_proto_msgTypes[17]
+	mi := &file_repocontext_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1401,7 +1728,7 @@ func (x *ChatResponse) String() string {
 func (*ChatResponse) ProtoMessage() {}
 
 func (x *ChatResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[17]
+	mi := &file_repocontext_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1414,7 +1741,7 @@ func (x *ChatResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatResponse.ProtoReflect.Descriptor instead.
 func (*ChatResponse) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{17}
+	return file_repocontext_proto_rawDescGZIP(), []int{20}
 }
 
 func (x *ChatResponse) GetMessage() isChatResponse_Message {
@@ -1543,7 +1870,7 @@ type SearchStarted struct {
 
 func (x *SearchStarted) Reset() {
 	*x = SearchStarted{}
-	mi := &file_repocontext_proto_msgTypes[18]
+	mi := &file_repocontext_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1555,7 +1882,7 @@ func (x *SearchStarted) String() string {
 func (*SearchStarted) ProtoMessage() {}
 
 func (x *SearchStarted) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[18]
+	mi := &file_repocontext_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1568,7 +1895,7 @@ func (x *SearchStarted) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SearchStarted.ProtoReflect.Descriptor instead.
 func (*SearchStarted) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{18}
+	return file_repocontext_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *SearchStarted) GetSessionId() string {
@@ -1598,7 +1925,7 @@ type SearchHit struct {
 
 func (x *SearchHit) Reset() {
 	*x = SearchHit{}
-	mi := &file_repocontext_proto_msgTypes[19]
+	mi := &file_repocontext_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1610,7 +1937,7 @@ func (x *SearchHit) String() string {
 func (*SearchHit) ProtoMessage() {}
 
 func (x *SearchHit) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[19]
+	mi := &file_repocontext_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1623,7 +1950,7 @@ func (x *SearchHit) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SearchHit.ProtoReflect.Descriptor instead.
 func (*SearchHit) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{19}
+	return file_repocontext_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *SearchHit) GetSessionId() string {
@@ -1672,7 +1999,7 @@ type CompositionStarted struct {
 
 func (x *CompositionStarted) Reset() {
 	*x = CompositionStarted{}
-	mi := &file_repocontext_proto_msgTypes[20]
+	mi := &file_repocontext_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1684,7 +2011,7 @@ func (x *CompositionStarted) String() string {
 func (*CompositionStarted) ProtoMessage() {}
 
 func (x *CompositionStarted) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[20]
+	mi := &file_repocontext_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1697,7 +2024,7 @@ func (x *CompositionStarted) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CompositionStarted.ProtoReflect.Descriptor instead.
 func (*CompositionStarted) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{20}
+	return file_repocontext_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *CompositionStarted) GetSessionId() string {
@@ -1732,7 +2059,7 @@ type CompositionToken struct {
 
 func (x *CompositionToken) Reset() {
 	*x = CompositionToken{}
-	mi := &file_repocontext_proto_msgTypes[21]
+	mi := &file_repocontext_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1744,7 +2071,7 @@ func (x *CompositionToken) String() string {
 func (*CompositionToken) ProtoMessage() {}
 
 func (x *CompositionToken) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[21]
+	mi := &file_repocontext_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1757,7 +2084,7 @@ func (x *CompositionToken) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CompositionToken.ProtoReflect.Descriptor instead.
 func (*CompositionToken) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{21}
+	return file_repocontext_proto_rawDescGZIP(), []int{24}
 }
 
 func (x *CompositionToken) GetSessionId() string {
@@ -1787,13 +2114,14 @@ type CompositionComplete struct {
 	QueryId       string                 `protobuf:"bytes,2,opt,name=query_id,json=queryId,proto3" json:"query_id,omitempty"`
 	FullResponse  string                 `protobuf:"bytes,3,opt,name=full_response,json=fullResponse,proto3" json:"full_response,omitempty"`
 	Citations     []*Citation            `protobuf:"bytes,4,rep,name=citations,proto3" json:"citations,omitempty"`
+	Usage         *TokenUsage            `protobuf:"bytes,5,opt,name=usage,proto3" json:"usage,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *CompositionComplete) Reset() {
 	*x = CompositionComplete{}
-	mi := &file_repocontext_proto_msgTypes[22]
+	mi := &file_repocontext_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1805,7 +2133,7 @@ func (x *CompositionComplete) String() string {
 func (*CompositionComplete) ProtoMessage() {}
 
 func (x *CompositionComplete) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[22]
+	mi := &file_repocontext_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1818,7 +2146,7 @@ func (x *CompositionComplete) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CompositionComplete.ProtoReflect.Descriptor instead.
 func (*CompositionComplete) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{22}
+	return file_repocontext_proto_rawDescGZIP(), []int{25}
 }
 
 func (x *CompositionComplete) GetSessionId() string {
@@ -1849,6 +2177,13 @@ func (x *CompositionComplete) GetCitations() []*Citation {
 	return nil
 }
 
+func (x *CompositionComplete) GetUsage() *TokenUsage {
+	if x != nil {
+		return x.Usage
+	}
+	return nil
+}
+
 type ChatError struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
@@ -1860,7 +2195,7 @@ type ChatError struct {
 
 func (x *ChatError) Reset() {
 	*x = ChatError{}
-	mi := &file_repocontext_proto_msgTypes[23]
+	mi := &file_repocontext_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1872,7 +2207,7 @@ func (x *ChatError) String() string {
 func (*ChatError) ProtoMessage() {}
 
 func (x *ChatError) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[23]
+	mi := &file_repocontext_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1885,7 +2220,7 @@ func (x *ChatError) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatError.ProtoReflect.Descriptor instead.
 func (*ChatError) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{23}
+	return file_repocontext_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *ChatError) GetSessionId() string {
@@ -1915,13 +2250,14 @@ type ChatComplete struct {
 	QueryId       string                 `protobuf:"bytes,2,opt,name=query_id,json=queryId,proto3" json:"query_id,omitempty"`
 	Timings       *SearchTimings         `protobuf:"bytes,3,opt,name=timings,proto3" json:"timings,omitempty"`
 	Stats         *SearchStats           `protobuf:"bytes,4,opt,name=stats,proto3" json:"stats,omitempty"`
+	Usage         *TokenUsage            `protobuf:"bytes,5,opt,name=usage,proto3" json:"usage,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ChatComplete) Reset() {
 	*x = ChatComplete{}
-	mi := &file_repocontext_proto_msgTypes[24]
+	mi := &file_repocontext_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1933,7 +2269,7 @@ func (x *ChatComplete) String() string {
 func (*ChatComplete) ProtoMessage() {}
 
 func (x *ChatComplete) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[24]
+	mi := &file_repocontext_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1946,7 +2282,7 @@ func (x *ChatComplete) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatComplete.ProtoReflect.Descriptor instead.
 func (*ChatComplete) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{24}
+	return file_repocontext_proto_rawDescGZIP(), []int{27}
 }
 
 func (x *ChatComplete) GetSessionId() string {
@@ -1977,24 +2313,98 @@ func (x *ChatComplete) GetStats() *SearchStats {
 	return nil
 }
 
+func (x *ChatComplete) GetUsage() *TokenUsage {
+	if x != nil {
+		return x.Usage
+	}
+	return nil
+}
+
+// Tokens the answer used. Streamed answers report no usage, so their prompt is
+// estimated and each streamed delta counts as one completion token.
+type TokenUsage struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	PromptTokens     int32                  `protobuf:"varint,1,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
+	CompletionTokens int32                  `protobuf:"varint,2,opt,name=completion_tokens,json=completionTokens,proto3" json:"completion_tokens,omitempty"`
+	TotalTokens      int32                  `protobuf:"varint,3,opt,name=total_tokens,json=totalTokens,proto3" json:"total_tokens,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *TokenUsage) Reset() {
+	*x = TokenUsage{}
+	mi := &file_repocontext_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TokenUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TokenUsage) ProtoMessage() {}
+
+func (x *TokenUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TokenUsage.ProtoReflect.Descriptor instead.
+func (*TokenUsage) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *TokenUsage) GetPromptTokens() int32 {
+	if x != nil {
+		return x.PromptTokens
+	}
+	return 0
+}
+
+func (x *TokenUsage) GetCompletionTokens() int32 {
+	if x != nil {
+		return x.CompletionTokens
+	}
+	return 0
+}
+
+func (x *TokenUsage) GetTotalTokens() int32 {
+	if x != nil {
+		return x.TotalTokens
+	}
+	return 0
+}
+
 type CodeChunk struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	RepositoryId  string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
-	FilePath      string                 `protobuf:"bytes,2,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
-	StartLine     int32                  `protobuf:"varint,3,opt,name=start_line,json=startLine,proto3" json:"start_line,omitempty"`
-	EndLine       int32                  `protobuf:"varint,4,opt,name=end_line,json=endLine,proto3" json:"end_line,omitempty"`
-	Content       string                 `protobuf:"bytes,5,opt,name=content,proto3" json:"content,omitempty"`
-	Score         float32                `protobuf:"fixed32,6,opt,name=score,proto3" json:"score,omitempty"`
-	Source        SearchSource           `protobuf:"varint,7,opt,name=source,proto3,enum=repocontext.v1.SearchSource" json:"source,omitempty"`
-	Language      string                 `protobuf:"bytes,8,opt,name=language,proto3" json:"language,omitempty"`
-	Symbol        string                 `protobuf:"bytes,9,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	RepositoryId string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	FilePath     string                 `protobuf:"bytes,2,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	StartLine    int32                  `protobuf:"varint,3,opt,name=start_line,json=startLine,proto3" json:"start_line,omitempty"`
+	EndLine      int32                  `protobuf:"varint,4,opt,name=end_line,json=endLine,proto3" json:"end_line,omitempty"`
+	Content      string                 `protobuf:"bytes,5,opt,name=content,proto3" json:"content,omitempty"`
+	Score        float32                `protobuf:"fixed32,6,opt,name=score,proto3" json:"score,omitempty"`
+	Source       SearchSource           `protobuf:"varint,7,opt,name=source,proto3,enum=repocontext.v1.SearchSource" json:"source,omitempty"`
+	Language     string                 `protobuf:"bytes,8,opt,name=language,proto3" json:"language,omitempty"`
+	Symbol       string                 `protobuf:"bytes,9,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	// Where the query matched in content, for lexical results
+	Highlights []*HighlightRange `protobuf:"bytes,10,rep,name=highlights,proto3" json:"highlights,omitempty"`
+	// When the chunk was written to the vector index, for semantic and hybrid
+	// results; shows how fresh the index is
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *CodeChunk) Reset() {
 	*x = CodeChunk{}
-	mi := &file_repocontext_proto_msgTypes[25]
+	mi := &file_repocontext_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2006,7 +2416,7 @@ func (x *CodeChunk) String() string {
 func (*CodeChunk) ProtoMessage() {}
 
 func (x *CodeChunk) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[25]
+	mi := &file_repocontext_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2019,7 +2429,7 @@ func (x *CodeChunk) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CodeChunk.ProtoReflect.Descriptor instead.
 func (*CodeChunk) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{25}
+	return file_repocontext_proto_rawDescGZIP(), []int{29}
 }
 
 func (x *CodeChunk) GetRepositoryId() string {
@@ -2085,6 +2495,82 @@ func (x *CodeChunk) GetSymbol() string {
 	return ""
 }
 
+func (x *CodeChunk) GetHighlights() []*HighlightRange {
+	if x != nil {
+		return x.Highlights
+	}
+	return nil
+}
+
+func (x *CodeChunk) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+// A match within one line of a chunk. start and end are byte offsets into that
+// line of the file, end exclusive.
+type HighlightRange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Line          int32                  `protobuf:"varint,1,opt,name=line,proto3" json:"line,omitempty"`
+	Start         int32                  `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	End           int32                  `protobuf:"varint,3,opt,name=end,proto3" json:"end,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HighlightRange) Reset() {
+	*x = HighlightRange{}
+	mi := &file_repocontext_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HighlightRange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HighlightRange) ProtoMessage() {}
+
+func (x *HighlightRange) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HighlightRange.ProtoReflect.Descriptor instead.
+func (*HighlightRange) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *HighlightRange) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *HighlightRange) GetStart() int32 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *HighlightRange) GetEnd() int32 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
 type Citation struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	FilePath      string                 `protobuf:"bytes,1,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
@@ -2096,7 +2582,7 @@ type Citation struct {
 
 func (x *Citation) Reset() {
 	*x = Citation{}
-	mi := &file_repocontext_proto_msgTypes[26]
+	mi := &file_repocontext_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2108,7 +2594,7 @@ func (x *Citation) String() string {
 func (*Citation) ProtoMessage() {}
 
 func (x *Citation) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[26]
+	mi := &file_repocontext_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2121,7 +2607,7 @@ func (x *Citation) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Citation.ProtoReflect.Descriptor instead.
 func (*Citation) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{26}
+	return file_repocontext_proto_rawDescGZIP(), []int{31}
 }
 
 func (x *Citation) GetFilePath() string {
@@ -2145,32 +2631,45 @@ func (x *Citation) GetExcerpt() string {
 	return ""
 }
 
-type SearchTimings struct {
+// SearchRequest searches the given repositories, or every READY repository of the
+// tenant when none are given
+type SearchRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	LexicalMs     int32                  `protobuf:"varint,1,opt,name=lexical_ms,json=lexicalMs,proto3" json:"lexical_ms,omitempty"`
-	SemanticMs    int32                  `protobuf:"varint,2,opt,name=semantic_ms,json=semanticMs,proto3" json:"semantic_ms,omitempty"`
-	MergeMs       int32                  `protobuf:"varint,3,opt,name=merge_ms,json=mergeMs,proto3" json:"merge_ms,omitempty"`
-	CompositionMs int32                  `protobuf:"varint,4,opt,name=composition_ms,json=compositionMs,proto3" json:"composition_ms,omitempty"`
-	CacheHit      bool                   `protobuf:"varint,5,opt,name=cache_hit,json=cacheHit,proto3" json:"cache_hit,omitempty"`
+	TenantId      string                 `protobuf:"bytes,1,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	Query         string                 `protobuf:"bytes,2,opt,name=query,proto3" json:"query,omitempty"`
+	RepositoryIds []string               `protobuf:"bytes,3,rep,name=repository_ids,json=repositoryIds,proto3" json:"repository_ids,omitempty"`
+	// Results returned across all repositories; 0 uses the default
+	MaxResults     int32           `protobuf:"varint,4,opt,name=max_results,json=maxResults,proto3" json:"max_results,omitempty"`
+	SearchMode     SearchMode      `protobuf:"varint,5,opt,name=search_mode,json=searchMode,proto3,enum=repocontext.v1.SearchMode" json:"search_mode,omitempty"`
+	LexicalOptions *LexicalOptions `protobuf:"bytes,6,opt,name=lexical_options,json=lexicalOptions,proto3" json:"lexical_options,omitempty"`
+	// Lines of surrounding code read around each semantic hit; 0 uses the server default
+	ContextLines int32 `protobuf:"varint,7,opt,name=context_lines,json=contextLines,proto3" json:"context_lines,omitempty"`
+	// Lowest certainty (0-1) a semantic hit may have, overriding SEMANTIC_MIN_CERTAINTY;
+	// 0 disables the threshold
+	MinCertainty *float32 `protobuf:"fixed32,8,opt,name=min_certainty,json=minCertainty,proto3,oneof" json:"min_certainty,omitempty"`
+	// Ranked results skipped before the first returned, for paging (at most 1000);
+	// stats.results_truncated says whether another page follows
+	Offset        int32          `protobuf:"varint,9,opt,name=offset,proto3" json:"offset,omitempty"`
+	Filters       *SearchFilters `protobuf:"bytes,10,opt,name=filters,proto3" json:"filters,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SearchTimings) Reset() {
-	*x = SearchTimings{}
-	mi := &file_repocontext_proto_msgTypes[27]
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_repocontext_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SearchTimings) String() string {
+func (x *SearchRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SearchTimings) ProtoMessage() {}
+func (*SearchRequest) ProtoMessage() {}
 
-func (x *SearchTimings) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[27]
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2181,16 +2680,216 @@ func (x *SearchTimings) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SearchTimings.ProtoReflect.Descriptor instead.
-func (*SearchTimings) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{27}
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{32}
 }
 
-func (x *SearchTimings) GetLexicalMs() int32 {
+func (x *SearchRequest) GetTenantId() string {
 	if x != nil {
-		return x.LexicalMs
+		return x.TenantId
 	}
-	return 0
+	return ""
+}
+
+func (x *SearchRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetRepositoryIds() []string {
+	if x != nil {
+		return x.RepositoryIds
+	}
+	return nil
+}
+
+func (x *SearchRequest) GetMaxResults() int32 {
+	if x != nil {
+		return x.MaxResults
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetSearchMode() SearchMode {
+	if x != nil {
+		return x.SearchMode
+	}
+	return SearchMode_SEARCH_MODE_UNSPECIFIED
+}
+
+func (x *SearchRequest) GetLexicalOptions() *LexicalOptions {
+	if x != nil {
+		return x.LexicalOptions
+	}
+	return nil
+}
+
+func (x *SearchRequest) GetContextLines() int32 {
+	if x != nil {
+		return x.ContextLines
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetMinCertainty() float32 {
+	if x != nil && x.MinCertainty != nil {
+		return *x.MinCertainty
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetFilters() *SearchFilters {
+	if x != nil {
+		return x.Filters
+	}
+	return nil
+}
+
+type SearchResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Ranked across repositories; each chunk's repository_id says where it's from
+	Chunks  []*CodeChunk   `protobuf:"bytes,1,rep,name=chunks,proto3" json:"chunks,omitempty"`
+	Timings *SearchTimings `protobuf:"bytes,2,opt,name=timings,proto3" json:"timings,omitempty"`
+	Stats   *SearchStats   `protobuf:"bytes,3,opt,name=stats,proto3" json:"stats,omitempty"`
+	// Repositories that were searched
+	RepositoryIds []string `protobuf:"bytes,4,rep,name=repository_ids,json=repositoryIds,proto3" json:"repository_ids,omitempty"`
+	// Repositories whose search failed; their results are missing
+	FailedRepositoryIds []string `protobuf:"bytes,5,rep,name=failed_repository_ids,json=failedRepositoryIds,proto3" json:"failed_repository_ids,omitempty"`
+	// The tenant has more READY repositories than one search covers; only the most
+	// recently updated were searched
+	RepositoriesTruncated bool `protobuf:"varint,6,opt,name=repositories_truncated,json=repositoriesTruncated,proto3" json:"repositories_truncated,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *SearchResponse) Reset() {
+	*x = SearchResponse{}
+	mi := &file_repocontext_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResponse) ProtoMessage() {}
+
+func (x *SearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *SearchResponse) GetChunks() []*CodeChunk {
+	if x != nil {
+		return x.Chunks
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetTimings() *SearchTimings {
+	if x != nil {
+		return x.Timings
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetStats() *SearchStats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetRepositoryIds() []string {
+	if x != nil {
+		return x.RepositoryIds
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetFailedRepositoryIds() []string {
+	if x != nil {
+		return x.FailedRepositoryIds
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetRepositoriesTruncated() bool {
+	if x != nil {
+		return x.RepositoriesTruncated
+	}
+	return false
+}
+
+type SearchTimings struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LexicalMs     int32                  `protobuf:"varint,1,opt,name=lexical_ms,json=lexicalMs,proto3" json:"lexical_ms,omitempty"`
+	SemanticMs    int32                  `protobuf:"varint,2,opt,name=semantic_ms,json=semanticMs,proto3" json:"semantic_ms,omitempty"`
+	MergeMs       int32                  `protobuf:"varint,3,opt,name=merge_ms,json=mergeMs,proto3" json:"merge_ms,omitempty"`
+	CompositionMs int32                  `protobuf:"varint,4,opt,name=composition_ms,json=compositionMs,proto3" json:"composition_ms,omitempty"`
+	CacheHit      bool                   `protobuf:"varint,5,opt,name=cache_hit,json=cacheHit,proto3" json:"cache_hit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchTimings) Reset() {
+	*x = SearchTimings{}
+	mi := &file_repocontext_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchTimings) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchTimings) ProtoMessage() {}
+
+func (x *SearchTimings) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchTimings.ProtoReflect.Descriptor instead.
+func (*SearchTimings) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *SearchTimings) GetLexicalMs() int32 {
+	if x != nil {
+		return x.LexicalMs
+	}
+	return 0
 }
 
 func (x *SearchTimings) GetSemanticMs() int32 {
@@ -2233,7 +2932,7 @@ type SearchStats struct {
 
 func (x *SearchStats) Reset() {
 	*x = SearchStats{}
-	mi := &file_repocontext_proto_msgTypes[28]
+	mi := &file_repocontext_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2245,7 +2944,7 @@ func (x *SearchStats) String() string {
 func (*SearchStats) ProtoMessage() {}
 
 func (x *SearchStats) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[28]
+	mi := &file_repocontext_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2258,7 +2957,7 @@ func (x *SearchStats) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SearchStats.ProtoReflect.Descriptor instead.
 func (*SearchStats) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{28}
+	return file_repocontext_proto_rawDescGZIP(), []int{35}
 }
 
 func (x *SearchStats) GetLexicalCandidates() int32 {
@@ -2301,7 +3000,7 @@ type ListRepositoriesRequest struct {
 
 func (x *ListRepositoriesRequest) Reset() {
 	*x = ListRepositoriesRequest{}
-	mi := &file_repocontext_proto_msgTypes[29]
+	mi := &file_repocontext_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2313,7 +3012,7 @@ func (x *ListRepositoriesRequest) String() string {
 func (*ListRepositoriesRequest) ProtoMessage() {}
 
 func (x *ListRepositoriesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[29]
+	mi := &file_repocontext_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2326,7 +3025,7 @@ func (x *ListRepositoriesRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListRepositoriesRequest.ProtoReflect.Descriptor instead.
 func (*ListRepositoriesRequest) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{29}
+	return file_repocontext_proto_rawDescGZIP(), []int{36}
 }
 
 func (x *ListRepositoriesRequest) GetTenantId() string {
@@ -2354,13 +3053,15 @@ type ListRepositoriesResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Repositories  []*Repository          `protobuf:"bytes,1,rep,name=repositories,proto3" json:"repositories,omitempty"`
 	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	// Page size actually applied after defaulting/clamping the requested one
+	PageSize      int32 `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ListRepositoriesResponse) Reset() {
 	*x = ListRepositoriesResponse{}
-	mi := &file_repocontext_proto_msgTypes[30]
+	mi := &file_repocontext_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2372,7 +3073,7 @@ func (x *ListRepositoriesResponse) String() string {
 func (*ListRepositoriesResponse) ProtoMessage() {}
 
 func (x *ListRepositoriesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[30]
+	mi := &file_repocontext_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2385,7 +3086,7 @@ func (x *ListRepositoriesResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListRepositoriesResponse.ProtoReflect.Descriptor instead.
 func (*ListRepositoriesResponse) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{30}
+	return file_repocontext_proto_rawDescGZIP(), []int{37}
 }
 
 func (x *ListRepositoriesResponse) GetRepositories() []*Repository {
@@ -2402,6 +3103,13 @@ func (x *ListRepositoriesResponse) GetNextPageToken() string {
 	return ""
 }
 
+func (x *ListRepositoriesResponse) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
 type GetRepositoryRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	RepositoryId  string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
@@ -2412,7 +3120,7 @@ type GetRepositoryRequest struct {
 
 func (x *GetRepositoryRequest) Reset() {
 	*x = GetRepositoryRequest{}
-	mi := &file_repocontext_proto_msgTypes[31]
+	mi := &file_repocontext_proto_msgTypes[38]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2424,7 +3132,7 @@ func (x *GetRepositoryRequest) String() string {
 func (*GetRepositoryRequest) ProtoMessage() {}
 
 func (x *GetRepositoryRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[31]
+	mi := &file_repocontext_proto_msgTypes[38]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2437,45 +3145,1265 @@ func (x *GetRepositoryRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetRepositoryRequest.ProtoReflect.Descriptor instead.
 func (*GetRepositoryRequest) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{31}
+	return file_repocontext_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *GetRepositoryRequest) GetRepositoryId() string {
+	if x != nil {
+		return x.RepositoryId
+	}
+	return ""
+}
+
+func (x *GetRepositoryRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type GetRepositoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Repository    *Repository            `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRepositoryResponse) Reset() {
+	*x = GetRepositoryResponse{}
+	mi := &file_repocontext_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRepositoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRepositoryResponse) ProtoMessage() {}
+
+func (x *GetRepositoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRepositoryResponse.ProtoReflect.Descriptor instead.
+func (*GetRepositoryResponse) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *GetRepositoryResponse) GetRepository() *Repository {
+	if x != nil {
+		return x.Repository
+	}
+	return nil
+}
+
+// Only the fields that are set are changed
+type UpdateRepositoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RepositoryId  string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	TenantId      string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	Name          *string                `protobuf:"bytes,3,opt,name=name,proto3,oneof" json:"name,omitempty"`
+	Description   *string                `protobuf:"bytes,4,opt,name=description,proto3,oneof" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateRepositoryRequest) Reset() {
+	*x = UpdateRepositoryRequest{}
+	mi := &file_repocontext_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateRepositoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateRepositoryRequest) ProtoMessage() {}
+
+func (x *UpdateRepositoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateRepositoryRequest.ProtoReflect.Descriptor instead.
+func (*UpdateRepositoryRequest) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *UpdateRepositoryRequest) GetRepositoryId() string {
+	if x != nil {
+		return x.RepositoryId
+	}
+	return ""
+}
+
+func (x *UpdateRepositoryRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *UpdateRepositoryRequest) GetName() string {
+	if x != nil && x.Name != nil {
+		return *x.Name
+	}
+	return ""
+}
+
+func (x *UpdateRepositoryRequest) GetDescription() string {
+	if x != nil && x.Description != nil {
+		return *x.Description
+	}
+	return ""
+}
+
+type UpdateRepositoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Repository    *Repository            `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateRepositoryResponse) Reset() {
+	*x = UpdateRepositoryResponse{}
+	mi := &file_repocontext_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateRepositoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateRepositoryResponse) ProtoMessage() {}
+
+func (x *UpdateRepositoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateRepositoryResponse.ProtoReflect.Descriptor instead.
+func (*UpdateRepositoryResponse) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *UpdateRepositoryResponse) GetRepository() *Repository {
+	if x != nil {
+		return x.Repository
+	}
+	return nil
+}
+
+type DeleteRepositoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RepositoryId  string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	TenantId      string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRepositoryRequest) Reset() {
+	*x = DeleteRepositoryRequest{}
+	mi := &file_repocontext_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRepositoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRepositoryRequest) ProtoMessage() {}
+
+func (x *DeleteRepositoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRepositoryRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRepositoryRequest) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *DeleteRepositoryRequest) GetRepositoryId() string {
+	if x != nil {
+		return x.RepositoryId
+	}
+	return ""
+}
+
+func (x *DeleteRepositoryRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type ReindexRepositoryRequest struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	RepositoryId string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	TenantId     string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	// Branch, tag or commit to reindex at; defaults to the stored source's ref
+	Ref           *string `protobuf:"bytes,3,opt,name=ref,proto3,oneof" json:"ref,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReindexRepositoryRequest) Reset() {
+	*x = ReindexRepositoryRequest{}
+	mi := &file_repocontext_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReindexRepositoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReindexRepositoryRequest) ProtoMessage() {}
+
+func (x *ReindexRepositoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReindexRepositoryRequest.ProtoReflect.Descriptor instead.
+func (*ReindexRepositoryRequest) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *ReindexRepositoryRequest) GetRepositoryId() string {
+	if x != nil {
+		return x.RepositoryId
+	}
+	return ""
+}
+
+func (x *ReindexRepositoryRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *ReindexRepositoryRequest) GetRef() string {
+	if x != nil && x.Ref != nil {
+		return *x.Ref
+	}
+	return ""
+}
+
+type GetFileRequest struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	RepositoryId string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	TenantId     string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	// Relative to the repository root, e.g. "internal/api/chat.go"
+	FilePath string `protobuf:"bytes,3,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	// 1-based and inclusive; 0 reads from the start or to the end of the file
+	StartLine     int32 `protobuf:"varint,4,opt,name=start_line,json=startLine,proto3" json:"start_line,omitempty"`
+	EndLine       int32 `protobuf:"varint,5,opt,name=end_line,json=endLine,proto3" json:"end_line,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetFileRequest) Reset() {
+	*x = GetFileRequest{}
+	mi := &file_repocontext_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFileRequest) ProtoMessage() {}
+
+func (x *GetFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFileRequest.ProtoReflect.Descriptor instead.
+func (*GetFileRequest) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *GetFileRequest) GetRepositoryId() string {
+	if x != nil {
+		return x.RepositoryId
+	}
+	return ""
+}
+
+func (x *GetFileRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *GetFileRequest) GetFilePath() string {
+	if x != nil {
+		return x.FilePath
+	}
+	return ""
+}
+
+func (x *GetFileRequest) GetStartLine() int32 {
+	if x != nil {
+		return x.StartLine
+	}
+	return 0
+}
+
+func (x *GetFileRequest) GetEndLine() int32 {
+	if x != nil {
+		return x.EndLine
+	}
+	return 0
+}
+
+type GetFileResponse struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	RepositoryId string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	FilePath     string                 `protobuf:"bytes,2,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	Language     string                 `protobuf:"bytes,3,opt,name=language,proto3" json:"language,omitempty"`
+	// Lines start_line..end_line of the file, joined by newlines
+	Content       string `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	StartLine     int32  `protobuf:"varint,5,opt,name=start_line,json=startLine,proto3" json:"start_line,omitempty"`
+	EndLine       int32  `protobuf:"varint,6,opt,name=end_line,json=endLine,proto3" json:"end_line,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetFileResponse) Reset() {
+	*x = GetFileResponse{}
+	mi := &file_repocontext_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetFileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFileResponse) ProtoMessage() {}
+
+func (x *GetFileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFileResponse.ProtoReflect.Descriptor instead.
+func (*GetFileResponse) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *GetFileResponse) GetRepositoryId() string {
+	if x != nil {
+		return x.RepositoryId
+	}
+	return ""
+}
+
+func (x *GetFileResponse) GetFilePath() string {
+	if x != nil {
+		return x.FilePath
+	}
+	return ""
+}
+
+func (x *GetFileResponse) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *GetFileResponse) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *GetFileResponse) GetStartLine() int32 {
+	if x != nil {
+		return x.StartLine
+	}
+	return 0
+}
+
+func (x *GetFileResponse) GetEndLine() int32 {
+	if x != nil {
+		return x.EndLine
+	}
+	return 0
+}
+
+type ListFilesRequest struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	RepositoryId string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	TenantId     string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	// Only list the files under this directory, e.g. "internal/api"
+	PathPrefix    string `protobuf:"bytes,3,opt,name=path_prefix,json=pathPrefix,proto3" json:"path_prefix,omitempty"`
+	PageSize      int32  `protobuf:"varint,4,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string `protobuf:"bytes,5,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFilesRequest) Reset() {
+	*x = ListFilesRequest{}
+	mi := &file_repocontext_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFilesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFilesRequest) ProtoMessage() {}
+
+func (x *ListFilesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFilesRequest.ProtoReflect.Descriptor instead.
+func (*ListFilesRequest) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *ListFilesRequest) GetRepositoryId() string {
+	if x != nil {
+		return x.RepositoryId
+	}
+	return ""
+}
+
+func (x *ListFilesRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *ListFilesRequest) GetPathPrefix() string {
+	if x != nil {
+		return x.PathPrefix
+	}
+	return ""
+}
+
+func (x *ListFilesRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListFilesRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListFilesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Files         []*FileEntry           `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	// Page size actually applied after defaulting/clamping the requested one
+	PageSize int32 `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// Files under path_prefix across all pages
+	TotalFiles    int32 `protobuf:"varint,4,opt,name=total_files,json=totalFiles,proto3" json:"total_files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFilesResponse) Reset() {
+	*x = ListFilesResponse{}
+	mi := &file_repocontext_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFilesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFilesResponse) ProtoMessage() {}
+
+func (x *ListFilesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFilesResponse.ProtoReflect.Descriptor instead.
+func (*ListFilesResponse) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *ListFilesResponse) GetFiles() []*FileEntry {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *ListFilesResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+func (x *ListFilesResponse) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListFilesResponse) GetTotalFiles() int32 {
+	if x != nil {
+		return x.TotalFiles
+	}
+	return 0
+}
+
+// A file found when the repository was last ingested
+type FileEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	SizeBytes     int64                  `protobuf:"varint,2,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	Language      string                 `protobuf:"bytes,3,opt,name=language,proto3" json:"language,omitempty"`
+	LineCount     int32                  `protobuf:"varint,4,opt,name=line_count,json=lineCount,proto3" json:"line_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileEntry) Reset() {
+	*x = FileEntry{}
+	mi := &file_repocontext_proto_msgTypes[48]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileEntry) ProtoMessage() {}
+
+func (x *FileEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[48]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileEntry.ProtoReflect.Descriptor instead.
+func (*FileEntry) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *FileEntry) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *FileEntry) GetSizeBytes() int64 {
+	if x != nil {
+		return x.SizeBytes
+	}
+	return 0
+}
+
+func (x *FileEntry) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *FileEntry) GetLineCount() int32 {
+	if x != nil {
+		return x.LineCount
+	}
+	return 0
+}
+
+type GetRepositoryStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RepositoryId  string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	TenantId      string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRepositoryStatsRequest) Reset() {
+	*x = GetRepositoryStatsRequest{}
+	mi := &file_repocontext_proto_msgTypes[49]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRepositoryStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRepositoryStatsRequest) ProtoMessage() {}
+
+func (x *GetRepositoryStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[49]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRepositoryStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetRepositoryStatsRequest) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *GetRepositoryStatsRequest) GetRepositoryId() string {
+	if x != nil {
+		return x.RepositoryId
+	}
+	return ""
+}
+
+func (x *GetRepositoryStatsRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type RecomputeRepositoryStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RepositoryId  string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	TenantId      string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecomputeRepositoryStatsRequest) Reset() {
+	*x = RecomputeRepositoryStatsRequest{}
+	mi := &file_repocontext_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecomputeRepositoryStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecomputeRepositoryStatsRequest) ProtoMessage() {}
+
+func (x *RecomputeRepositoryStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecomputeRepositoryStatsRequest.ProtoReflect.Descriptor instead.
+func (*RecomputeRepositoryStatsRequest) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *RecomputeRepositoryStatsRequest) GetRepositoryId() string {
+	if x != nil {
+		return x.RepositoryId
+	}
+	return ""
+}
+
+func (x *RecomputeRepositoryStatsRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type GetRepositoryStatsResponse struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	RepositoryId string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	// Files, lines and languages from the file list (or the working tree when
+	// recomputing); total_chunks is the number of vectors in the vector store
+	Stats *RepositoryStats `protobuf:"bytes,2,opt,name=stats,proto3" json:"stats,omitempty"`
+	// The stats stored when the repository was last ingested or recomputed
+	StoredStats *RepositoryStats `protobuf:"bytes,3,opt,name=stored_stats,json=storedStats,proto3" json:"stored_stats,omitempty"`
+	// Whether stats replaced the stored stats
+	Recomputed    bool `protobuf:"varint,4,opt,name=recomputed,proto3" json:"recomputed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRepositoryStatsResponse) Reset() {
+	*x = GetRepositoryStatsResponse{}
+	mi := &file_repocontext_proto_msgTypes[51]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRepositoryStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRepositoryStatsResponse) ProtoMessage() {}
+
+func (x *GetRepositoryStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[51]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRepositoryStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetRepositoryStatsResponse) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *GetRepositoryStatsResponse) GetRepositoryId() string {
+	if x != nil {
+		return x.RepositoryId
+	}
+	return ""
+}
+
+func (x *GetRepositoryStatsResponse) GetStats() *RepositoryStats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+func (x *GetRepositoryStatsResponse) GetStoredStats() *RepositoryStats {
+	if x != nil {
+		return x.StoredStats
+	}
+	return nil
+}
+
+func (x *GetRepositoryStatsResponse) GetRecomputed() bool {
+	if x != nil {
+		return x.Recomputed
+	}
+	return false
+}
+
+type ExportIndexRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RepositoryId  string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	TenantId      string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportIndexRequest) Reset() {
+	*x = ExportIndexRequest{}
+	mi := &file_repocontext_proto_msgTypes[52]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportIndexRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportIndexRequest) ProtoMessage() {}
+
+func (x *ExportIndexRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[52]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportIndexRequest.ProtoReflect.Descriptor instead.
+func (*ExportIndexRequest) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *ExportIndexRequest) GetRepositoryId() string {
+	if x != nil {
+		return x.RepositoryId
+	}
+	return ""
+}
+
+func (x *ExportIndexRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+// One message of an exported index: the header comes first, then the chunks
+type IndexRecord struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Record:
+	//
+	//	*IndexRecord_Header
+	//	*IndexRecord_Chunk
+	Record        isIndexRecord_Record `protobuf_oneof:"record"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IndexRecord) Reset() {
+	*x = IndexRecord{}
+	mi := &file_repocontext_proto_msgTypes[53]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IndexRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IndexRecord) ProtoMessage() {}
+
+func (x *IndexRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[53]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IndexRecord.ProtoReflect.Descriptor instead.
+func (*IndexRecord) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{53}
+}
+
+func (x *IndexRecord) GetRecord() isIndexRecord_Record {
+	if x != nil {
+		return x.Record
+	}
+	return nil
+}
+
+func (x *IndexRecord) GetHeader() *IndexHeader {
+	if x != nil {
+		if x, ok := x.Record.(*IndexRecord_Header); ok {
+			return x.Header
+		}
+	}
+	return nil
+}
+
+func (x *IndexRecord) GetChunk() *IndexedChunk {
+	if x != nil {
+		if x, ok := x.Record.(*IndexRecord_Chunk); ok {
+			return x.Chunk
+		}
+	}
+	return nil
+}
+
+type isIndexRecord_Record interface {
+	isIndexRecord_Record()
+}
+
+type IndexRecord_Header struct {
+	Header *IndexHeader `protobuf:"bytes,1,opt,name=header,proto3,oneof"`
+}
+
+type IndexRecord_Chunk struct {
+	Chunk *IndexedChunk `protobuf:"bytes,2,opt,name=chunk,proto3,oneof"`
+}
+
+func (*IndexRecord_Header) isIndexRecord_Record() {}
+
+func (*IndexRecord_Chunk) isIndexRecord_Record() {}
+
+type IndexHeader struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Version of the export format, currently 1
+	FormatVersion int32       `protobuf:"varint,1,opt,name=format_version,json=formatVersion,proto3" json:"format_version,omitempty"`
+	Repository    *Repository `protobuf:"bytes,2,opt,name=repository,proto3" json:"repository,omitempty"`
+	// Model and vector size of the embeddings; imports require the same
+	EmbeddingModel string                 `protobuf:"bytes,3,opt,name=embedding_model,json=embeddingModel,proto3" json:"embedding_model,omitempty"`
+	Dimensions     int32                  `protobuf:"varint,4,opt,name=dimensions,proto3" json:"dimensions,omitempty"`
+	ExportedAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=exported_at,json=exportedAt,proto3" json:"exported_at,omitempty"`
+	// Content hash of each file, by path, so a reindex after import only
+	// re-embeds files that changed
+	FileHashes    map[string]string `protobuf:"bytes,6,rep,name=file_hashes,json=fileHashes,proto3" json:"file_hashes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Files         []*FileEntry      `protobuf:"bytes,7,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IndexHeader) Reset() {
+	*x = IndexHeader{}
+	mi := &file_repocontext_proto_msgTypes[54]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IndexHeader) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IndexHeader) ProtoMessage() {}
+
+func (x *IndexHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[54]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IndexHeader.ProtoReflect.Descriptor instead.
+func (*IndexHeader) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *IndexHeader) GetFormatVersion() int32 {
+	if x != nil {
+		return x.FormatVersion
+	}
+	return 0
+}
+
+func (x *IndexHeader) GetRepository() *Repository {
+	if x != nil {
+		return x.Repository
+	}
+	return nil
+}
+
+func (x *IndexHeader) GetEmbeddingModel() string {
+	if x != nil {
+		return x.EmbeddingModel
+	}
+	return ""
+}
+
+func (x *IndexHeader) GetDimensions() int32 {
+	if x != nil {
+		return x.Dimensions
+	}
+	return 0
+}
+
+func (x *IndexHeader) GetExportedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExportedAt
+	}
+	return nil
+}
+
+func (x *IndexHeader) GetFileHashes() map[string]string {
+	if x != nil {
+		return x.FileHashes
+	}
+	return nil
+}
+
+func (x *IndexHeader) GetFiles() []*FileEntry {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type IndexedChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FilePath      string                 `protobuf:"bytes,1,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	StartLine     int32                  `protobuf:"varint,2,opt,name=start_line,json=startLine,proto3" json:"start_line,omitempty"`
+	EndLine       int32                  `protobuf:"varint,3,opt,name=end_line,json=endLine,proto3" json:"end_line,omitempty"`
+	Content       string                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	Language      string                 `protobuf:"bytes,5,opt,name=language,proto3" json:"language,omitempty"`
+	Symbol        string                 `protobuf:"bytes,6,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Embedding     []float32              `protobuf:"fixed32,7,rep,packed,name=embedding,proto3" json:"embedding,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IndexedChunk) Reset() {
+	*x = IndexedChunk{}
+	mi := &file_repocontext_proto_msgTypes[55]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IndexedChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IndexedChunk) ProtoMessage() {}
+
+func (x *IndexedChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[55]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IndexedChunk.ProtoReflect.Descriptor instead.
+func (*IndexedChunk) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{55}
+}
+
+func (x *IndexedChunk) GetFilePath() string {
+	if x != nil {
+		return x.FilePath
+	}
+	return ""
+}
+
+func (x *IndexedChunk) GetStartLine() int32 {
+	if x != nil {
+		return x.StartLine
+	}
+	return 0
+}
+
+func (x *IndexedChunk) GetEndLine() int32 {
+	if x != nil {
+		return x.EndLine
+	}
+	return 0
+}
+
+func (x *IndexedChunk) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *IndexedChunk) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *IndexedChunk) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *IndexedChunk) GetEmbedding() []float32 {
+	if x != nil {
+		return x.Embedding
+	}
+	return nil
+}
+
+func (x *IndexedChunk) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+type ImportIndexRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Set on the first message only: an existing repository whose index is
+	// replaced; empty imports into a new repository
+	RepositoryId  string       `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	TenantId      string       `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	Record        *IndexRecord `protobuf:"bytes,3,opt,name=record,proto3" json:"record,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportIndexRequest) Reset() {
+	*x = ImportIndexRequest{}
+	mi := &file_repocontext_proto_msgTypes[56]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportIndexRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportIndexRequest) ProtoMessage() {}
+
+func (x *ImportIndexRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[56]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportIndexRequest.ProtoReflect.Descriptor instead.
+func (*ImportIndexRequest) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{56}
 }
 
-func (x *GetRepositoryRequest) GetRepositoryId() string {
+func (x *ImportIndexRequest) GetRepositoryId() string {
 	if x != nil {
 		return x.RepositoryId
 	}
 	return ""
 }
 
-func (x *GetRepositoryRequest) GetTenantId() string {
+func (x *ImportIndexRequest) GetTenantId() string {
 	if x != nil {
 		return x.TenantId
 	}
 	return ""
 }
 
-type GetRepositoryResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Repository    *Repository            `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *ImportIndexRequest) GetRecord() *IndexRecord {
+	if x != nil {
+		return x.Record
+	}
+	return nil
 }
 
-func (x *GetRepositoryResponse) Reset() {
-	*x = GetRepositoryResponse{}
-	mi := &file_repocontext_proto_msgTypes[32]
+type ImportIndexResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	RepositoryId   string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	ChunksImported int32                  `protobuf:"varint,2,opt,name=chunks_imported,json=chunksImported,proto3" json:"chunks_imported,omitempty"`
+	Repository     *Repository            `protobuf:"bytes,3,opt,name=repository,proto3" json:"repository,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ImportIndexResponse) Reset() {
+	*x = ImportIndexResponse{}
+	mi := &file_repocontext_proto_msgTypes[57]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetRepositoryResponse) String() string {
+func (x *ImportIndexResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetRepositoryResponse) ProtoMessage() {}
+func (*ImportIndexResponse) ProtoMessage() {}
 
-func (x *GetRepositoryResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[32]
+func (x *ImportIndexResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[57]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2486,41 +4414,58 @@ func (x *GetRepositoryResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetRepositoryResponse.ProtoReflect.Descriptor instead.
-func (*GetRepositoryResponse) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{32}
+// Deprecated: Use ImportIndexResponse.ProtoReflect.Descriptor instead.
+func (*ImportIndexResponse) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{57}
 }
 
-func (x *GetRepositoryResponse) GetRepository() *Repository {
+func (x *ImportIndexResponse) GetRepositoryId() string {
+	if x != nil {
+		return x.RepositoryId
+	}
+	return ""
+}
+
+func (x *ImportIndexResponse) GetChunksImported() int32 {
+	if x != nil {
+		return x.ChunksImported
+	}
+	return 0
+}
+
+func (x *ImportIndexResponse) GetRepository() *Repository {
 	if x != nil {
 		return x.Repository
 	}
 	return nil
 }
 
-type DeleteRepositoryRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	RepositoryId  string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
-	TenantId      string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+type ReindexRepositoryResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Track progress with GetUploadStatus
+	UploadId      string                 `protobuf:"bytes,1,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	RepositoryId  string                 `protobuf:"bytes,2,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
+	AcceptedAt    *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=accepted_at,json=acceptedAt,proto3" json:"accepted_at,omitempty"`
+	Status        *IngestionStatus       `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *DeleteRepositoryRequest) Reset() {
-	*x = DeleteRepositoryRequest{}
-	mi := &file_repocontext_proto_msgTypes[33]
+func (x *ReindexRepositoryResponse) Reset() {
+	*x = ReindexRepositoryResponse{}
+	mi := &file_repocontext_proto_msgTypes[58]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *DeleteRepositoryRequest) String() string {
+func (x *ReindexRepositoryResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DeleteRepositoryRequest) ProtoMessage() {}
+func (*ReindexRepositoryResponse) ProtoMessage() {}
 
-func (x *DeleteRepositoryRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[33]
+func (x *ReindexRepositoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[58]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2531,25 +4476,39 @@ func (x *DeleteRepositoryRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DeleteRepositoryRequest.ProtoReflect.Descriptor instead.
-func (*DeleteRepositoryRequest) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{33}
+// Deprecated: Use ReindexRepositoryResponse.ProtoReflect.Descriptor instead.
+func (*ReindexRepositoryResponse) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{58}
 }
 
-func (x *DeleteRepositoryRequest) GetRepositoryId() string {
+func (x *ReindexRepositoryResponse) GetUploadId() string {
 	if x != nil {
-		return x.RepositoryId
+		return x.UploadId
 	}
 	return ""
 }
 
-func (x *DeleteRepositoryRequest) GetTenantId() string {
+func (x *ReindexRepositoryResponse) GetRepositoryId() string {
 	if x != nil {
-		return x.TenantId
+		return x.RepositoryId
 	}
 	return ""
 }
 
+func (x *ReindexRepositoryResponse) GetAcceptedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.AcceptedAt
+	}
+	return nil
+}
+
+func (x *ReindexRepositoryResponse) GetStatus() *IngestionStatus {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
 type Repository struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
 	RepositoryId    string                 `protobuf:"bytes,1,opt,name=repository_id,json=repositoryId,proto3" json:"repository_id,omitempty"`
@@ -2566,7 +4525,7 @@ type Repository struct {
 
 func (x *Repository) Reset() {
 	*x = Repository{}
-	mi := &file_repocontext_proto_msgTypes[34]
+	mi := &file_repocontext_proto_msgTypes[59]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2578,7 +4537,7 @@ func (x *Repository) String() string {
 func (*Repository) ProtoMessage() {}
 
 func (x *Repository) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[34]
+	mi := &file_repocontext_proto_msgTypes[59]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2591,7 +4550,7 @@ func (x *Repository) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Repository.ProtoReflect.Descriptor instead.
 func (*Repository) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{34}
+	return file_repocontext_proto_rawDescGZIP(), []int{59}
 }
 
 func (x *Repository) GetRepositoryId() string {
@@ -2665,7 +4624,7 @@ type RepositorySource struct {
 
 func (x *RepositorySource) Reset() {
 	*x = RepositorySource{}
-	mi := &file_repocontext_proto_msgTypes[35]
+	mi := &file_repocontext_proto_msgTypes[60]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2677,7 +4636,7 @@ func (x *RepositorySource) String() string {
 func (*RepositorySource) ProtoMessage() {}
 
 func (x *RepositorySource) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[35]
+	mi := &file_repocontext_proto_msgTypes[60]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2690,7 +4649,7 @@ func (x *RepositorySource) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RepositorySource.ProtoReflect.Descriptor instead.
 func (*RepositorySource) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{35}
+	return file_repocontext_proto_rawDescGZIP(), []int{60}
 }
 
 func (x *RepositorySource) GetSource() isRepositorySource_Source {
@@ -2761,7 +4720,7 @@ type RepositoryStats struct {
 
 func (x *RepositoryStats) Reset() {
 	*x = RepositoryStats{}
-	mi := &file_repocontext_proto_msgTypes[36]
+	mi := &file_repocontext_proto_msgTypes[61]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2773,7 +4732,7 @@ func (x *RepositoryStats) String() string {
 func (*RepositoryStats) ProtoMessage() {}
 
 func (x *RepositoryStats) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[36]
+	mi := &file_repocontext_proto_msgTypes[61]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2786,7 +4745,7 @@ func (x *RepositoryStats) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RepositoryStats.ProtoReflect.Descriptor instead.
 func (*RepositoryStats) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{36}
+	return file_repocontext_proto_rawDescGZIP(), []int{61}
 }
 
 func (x *RepositoryStats) GetTotalFiles() int32 {
@@ -2835,7 +4794,7 @@ type LanguageStats struct {
 
 func (x *LanguageStats) Reset() {
 	*x = LanguageStats{}
-	mi := &file_repocontext_proto_msgTypes[37]
+	mi := &file_repocontext_proto_msgTypes[62]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2847,7 +4806,7 @@ func (x *LanguageStats) String() string {
 func (*LanguageStats) ProtoMessage() {}
 
 func (x *LanguageStats) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[37]
+	mi := &file_repocontext_proto_msgTypes[62]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2860,7 +4819,7 @@ func (x *LanguageStats) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LanguageStats.ProtoReflect.Descriptor instead.
 func (*LanguageStats) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{37}
+	return file_repocontext_proto_rawDescGZIP(), []int{62}
 }
 
 func (x *LanguageStats) GetLanguage() string {
@@ -2884,6 +4843,188 @@ func (x *LanguageStats) GetLineCount() int32 {
 	return 0
 }
 
+// Admin Messages
+type CreateAPIKeyRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	TenantId string                 `protobuf:"bytes,1,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	// Scopes granted to the key: read, upload, chat, admin
+	Scopes        []string `protobuf:"bytes,2,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	Description   string   `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateAPIKeyRequest) Reset() {
+	*x = CreateAPIKeyRequest{}
+	mi := &file_repocontext_proto_msgTypes[63]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateAPIKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateAPIKeyRequest) ProtoMessage() {}
+
+func (x *CreateAPIKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[63]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateAPIKeyRequest.ProtoReflect.Descriptor instead.
+func (*CreateAPIKeyRequest) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{63}
+}
+
+func (x *CreateAPIKeyRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *CreateAPIKeyRequest) GetScopes() []string {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+func (x *CreateAPIKeyRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+type CreateAPIKeyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	KeyId         string                 `protobuf:"bytes,1,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	ApiKey        string                 `protobuf:"bytes,2,opt,name=api_key,json=apiKey,proto3" json:"api_key,omitempty"`
+	TenantId      string                 `protobuf:"bytes,3,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	Scopes        []string               `protobuf:"bytes,4,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateAPIKeyResponse) Reset() {
+	*x = CreateAPIKeyResponse{}
+	mi := &file_repocontext_proto_msgTypes[64]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateAPIKeyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateAPIKeyResponse) ProtoMessage() {}
+
+func (x *CreateAPIKeyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[64]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateAPIKeyResponse.ProtoReflect.Descriptor instead.
+func (*CreateAPIKeyResponse) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{64}
+}
+
+func (x *CreateAPIKeyResponse) GetKeyId() string {
+	if x != nil {
+		return x.KeyId
+	}
+	return ""
+}
+
+func (x *CreateAPIKeyResponse) GetApiKey() string {
+	if x != nil {
+		return x.ApiKey
+	}
+	return ""
+}
+
+func (x *CreateAPIKeyResponse) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *CreateAPIKeyResponse) GetScopes() []string {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+func (x *CreateAPIKeyResponse) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+type RevokeAPIKeyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	KeyId         string                 `protobuf:"bytes,1,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevokeAPIKeyRequest) Reset() {
+	*x = RevokeAPIKeyRequest{}
+	mi := &file_repocontext_proto_msgTypes[65]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevokeAPIKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevokeAPIKeyRequest) ProtoMessage() {}
+
+func (x *RevokeAPIKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_repocontext_proto_msgTypes[65]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevokeAPIKeyRequest.ProtoReflect.Descriptor instead.
+func (*RevokeAPIKeyRequest) Descriptor() ([]byte, []int) {
+	return file_repocontext_proto_rawDescGZIP(), []int{65}
+}
+
+func (x *RevokeAPIKeyRequest) GetKeyId() string {
+	if x != nil {
+		return x.KeyId
+	}
+	return ""
+}
+
 // Health Messages
 type HealthCheckResponse struct {
 	state         protoimpl.MessageState            `protogen:"open.v1"`
@@ -2895,7 +5036,7 @@ type HealthCheckResponse struct {
 
 func (x *HealthCheckResponse) Reset() {
 	*x = HealthCheckResponse{}
-	mi := &file_repocontext_proto_msgTypes[38]
+	mi := &file_repocontext_proto_msgTypes[66]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2907,7 +5048,7 @@ func (x *HealthCheckResponse) String() string {
 func (*HealthCheckResponse) ProtoMessage() {}
 
 func (x *HealthCheckResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[38]
+	mi := &file_repocontext_proto_msgTypes[66]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2920,7 +5061,7 @@ func (x *HealthCheckResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HealthCheckResponse.ProtoReflect.Descriptor instead.
 func (*HealthCheckResponse) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{38}
+	return file_repocontext_proto_rawDescGZIP(), []int{66}
 }
 
 func (x *HealthCheckResponse) GetStatus() HealthCheckResponse_ServingStatus {
@@ -2948,7 +5089,7 @@ type ComponentHealth struct {
 
 func (x *ComponentHealth) Reset() {
 	*x = ComponentHealth{}
-	mi := &file_repocontext_proto_msgTypes[39]
+	mi := &file_repocontext_proto_msgTypes[67]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2960,7 +5101,7 @@ func (x *ComponentHealth) String() string {
 func (*ComponentHealth) ProtoMessage() {}
 
 func (x *ComponentHealth) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[39]
+	mi := &file_repocontext_proto_msgTypes[67]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2973,7 +5114,7 @@ func (x *ComponentHealth) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ComponentHealth.ProtoReflect.Descriptor instead.
 func (*ComponentHealth) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{39}
+	return file_repocontext_proto_rawDescGZIP(), []int{67}
 }
 
 func (x *ComponentHealth) GetName() string {
@@ -3007,7 +5148,7 @@ type PingResponse struct {
 
 func (x *PingResponse) Reset() {
 	*x = PingResponse{}
-	mi := &file_repocontext_proto_msgTypes[40]
+	mi := &file_repocontext_proto_msgTypes[68]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3019,7 +5160,7 @@ func (x *PingResponse) String() string {
 func (*PingResponse) ProtoMessage() {}
 
 func (x *PingResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_repocontext_proto_msgTypes[40]
+	mi := &file_repocontext_proto_msgTypes[68]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3032,7 +5173,7 @@ func (x *PingResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PingResponse.ProtoReflect.Descriptor instead.
 func (*PingResponse) Descriptor() ([]byte, []int) {
-	return file_repocontext_proto_rawDescGZIP(), []int{40}
+	return file_repocontext_proto_rawDescGZIP(), []int{68}
 }
 
 func (x *PingResponse) GetMessage() string {
@@ -3061,12 +5202,13 @@ const file_repocontext_proto_rawDesc = "" +
 	"\ttenant_id\x18\x03 \x01(\tR\btenantId\x12'\n" +
 	"\x0fidempotency_key\x18\x04 \x01(\tR\x0eidempotencyKey\x127\n" +
 	"\aoptions\x18\x05 \x01(\v2\x1d.repocontext.v1.UploadOptionsR\aoptionsB\b\n" +
-	"\x06source\"\xe1\x01\n" +
+	"\x06source\"\xf7\x01\n" +
 	"\x1aUploadGitRepositoryRequest\x12D\n" +
 	"\x0egit_repository\x18\x01 \x01(\v2\x1d.repocontext.v1.GitRepositoryR\rgitRepository\x12\x1b\n" +
 	"\ttenant_id\x18\x02 \x01(\tR\btenantId\x12'\n" +
 	"\x0fidempotency_key\x18\x03 \x01(\tR\x0eidempotencyKey\x127\n" +
-	"\aoptions\x18\x04 \x01(\v2\x1d.repocontext.v1.UploadOptionsR\aoptions\"Y\n" +
+	"\aoptions\x18\x04 \x01(\v2\x1d.repocontext.v1.UploadOptionsR\aoptions\x12\x14\n" +
+	"\x05force\x18\x05 \x01(\bR\x05force\"Y\n" +
 	"\n" +
 	"FileUpload\x12\x1a\n" +
 	"\bfilename\x18\x01 \x01(\tR\bfilename\x12\x14\n" +
@@ -3078,12 +5220,15 @@ const file_repocontext_proto_rawDesc = "" +
 	"\vcredentials\x18\x03 \x01(\v2\x1e.repocontext.v1.GitCredentialsR\vcredentials\"H\n" +
 	"\x0eGitCredentials\x12\x1a\n" +
 	"\busername\x18\x01 \x01(\tR\busername\x12\x1a\n" +
-	"\bpassword\x18\x02 \x01(\tR\bpassword\"\xb3\x01\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\"\xf7\x01\n" +
 	"\rUploadOptions\x12)\n" +
 	"\x10include_patterns\x18\x01 \x03(\tR\x0fincludePatterns\x12)\n" +
 	"\x10exclude_patterns\x18\x02 \x03(\tR\x0fexcludePatterns\x12'\n" +
 	"\x10max_file_size_mb\x18\x03 \x01(\x05R\rmaxFileSizeMb\x12#\n" +
-	"\rskip_binaries\x18\x04 \x01(\bR\fskipBinaries\"\xd2\x01\n" +
+	"\rskip_binaries\x18\x04 \x01(\bR\fskipBinaries\x12\x1d\n" +
+	"\n" +
+	"chunk_size\x18\x05 \x01(\x05R\tchunkSize\x12#\n" +
+	"\rchunk_overlap\x18\x06 \x01(\x05R\fchunkOverlap\"\xd2\x01\n" +
 	"\x18UploadRepositoryResponse\x12\x1b\n" +
 	"\tupload_id\x18\x01 \x01(\tR\buploadId\x12#\n" +
 	"\rrepository_id\x18\x02 \x01(\tR\frepositoryId\x12;\n" +
@@ -3098,11 +5243,20 @@ const file_repocontext_proto_rawDesc = "" +
 	"\rrepository_id\x18\x02 \x01(\tR\frepositoryId\x127\n" +
 	"\x06status\x18\x03 \x01(\v2\x1f.repocontext.v1.IngestionStatusR\x06status\x12=\n" +
 	"\bprogress\x18\x04 \x01(\v2!.repocontext.v1.IngestionProgressR\bprogress\x12#\n" +
-	"\rerror_message\x18\x05 \x01(\tR\ferrorMessage\"\xb3\x02\n" +
+	"\rerror_message\x18\x05 \x01(\tR\ferrorMessage\"O\n" +
+	"\x13CancelUploadRequest\x12\x1b\n" +
+	"\tupload_id\x18\x01 \x01(\tR\buploadId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\"\xaf\x01\n" +
+	"\x14CancelUploadResponse\x12\x1b\n" +
+	"\tupload_id\x18\x01 \x01(\tR\buploadId\x12#\n" +
+	"\rrepository_id\x18\x02 \x01(\tR\frepositoryId\x127\n" +
+	"\x06status\x18\x03 \x01(\v2\x1f.repocontext.v1.IngestionStatusR\x06status\x12\x1c\n" +
+	"\tcancelled\x18\x04 \x01(\bR\tcancelled\"\xd8\x02\n" +
 	"\x0fIngestionStatus\x12;\n" +
 	"\x05state\x18\x01 \x01(\x0e2%.repocontext.v1.IngestionStatus.StateR\x05state\x129\n" +
 	"\n" +
-	"updated_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"\xa7\x01\n" +
+	"updated_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12#\n" +
+	"\rerror_message\x18\x03 \x01(\tR\ferrorMessage\"\xa7\x01\n" +
 	"\x05State\x12\x15\n" +
 	"\x11STATE_UNSPECIFIED\x10\x00\x12\x11\n" +
 	"\rSTATE_PENDING\x10\x01\x12\x14\n" +
@@ -3128,21 +5282,33 @@ const file_repocontext_proto_rawDesc = "" +
 	"\tChatStart\x12#\n" +
 	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x1b\n" +
 	"\ttenant_id\x18\x02 \x01(\tR\btenantId\x125\n" +
-	"\aoptions\x18\x03 \x01(\v2\x1b.repocontext.v1.ChatOptionsR\aoptions\"{\n" +
+	"\aoptions\x18\x03 \x01(\v2\x1b.repocontext.v1.ChatOptionsR\aoptions\"\xc4\x01\n" +
 	"\vChatMessage\x12\x14\n" +
 	"\x05query\x18\x01 \x01(\tR\x05query\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x02 \x01(\tR\tsessionId\x127\n" +
-	"\afilters\x18\x03 \x01(\v2\x1d.repocontext.v1.SearchFiltersR\afilters\"+\n" +
+	"\afilters\x18\x03 \x01(\v2\x1d.repocontext.v1.SearchFiltersR\afilters\x12G\n" +
+	"\x0flexical_options\x18\x04 \x01(\v2\x1e.repocontext.v1.LexicalOptionsR\x0elexicalOptions\"+\n" +
 	"\n" +
 	"ChatCancel\x12\x1d\n" +
 	"\n" +
-	"session_id\x18\x01 \x01(\tR\tsessionId\"i\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"\x84\x02\n" +
 	"\vChatOptions\x12\x1f\n" +
 	"\vmax_results\x18\x01 \x01(\x05R\n" +
 	"maxResults\x12#\n" +
 	"\rstream_tokens\x18\x02 \x01(\bR\fstreamTokens\x12\x14\n" +
-	"\x05model\x18\x03 \x01(\tR\x05model\"s\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\x12;\n" +
+	"\vsearch_mode\x18\x04 \x01(\x0e2\x1a.repocontext.v1.SearchModeR\n" +
+	"searchMode\x12#\n" +
+	"\rcontext_lines\x18\x05 \x01(\x05R\fcontextLines\x127\n" +
+	"\afilters\x18\x06 \x01(\v2\x1d.repocontext.v1.SearchFiltersR\afilters\"\xa8\x01\n" +
+	"\x0eLexicalOptions\x12\x14\n" +
+	"\x05regex\x18\x01 \x01(\bR\x05regex\x12%\n" +
+	"\x0ecase_sensitive\x18\x02 \x01(\bR\rcaseSensitive\x12\x1d\n" +
+	"\n" +
+	"whole_word\x18\x03 \x01(\bR\twholeWord\x12(\n" +
+	"\rcontext_lines\x18\x04 \x01(\x05H\x00R\fcontextLines\x88\x01\x01B\x10\n" +
+	"\x0e_context_lines\"s\n" +
 	"\rSearchFilters\x12\x1c\n" +
 	"\tlanguages\x18\x01 \x03(\tR\tlanguages\x12#\n" +
 	"\rfile_patterns\x18\x02 \x03(\tR\ffilePatterns\x12\x1f\n" +
@@ -3178,25 +5344,32 @@ const file_repocontext_proto_rawDesc = "" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x19\n" +
 	"\bquery_id\x18\x02 \x01(\tR\aqueryId\x12\x12\n" +
-	"\x04text\x18\x03 \x01(\tR\x04text\"\xac\x01\n" +
+	"\x04text\x18\x03 \x01(\tR\x04text\"\xde\x01\n" +
 	"\x13CompositionComplete\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x19\n" +
 	"\bquery_id\x18\x02 \x01(\tR\aqueryId\x12#\n" +
 	"\rfull_response\x18\x03 \x01(\tR\ffullResponse\x126\n" +
-	"\tcitations\x18\x04 \x03(\v2\x18.repocontext.v1.CitationR\tcitations\"n\n" +
+	"\tcitations\x18\x04 \x03(\v2\x18.repocontext.v1.CitationR\tcitations\x120\n" +
+	"\x05usage\x18\x05 \x01(\v2\x1a.repocontext.v1.TokenUsageR\x05usage\"n\n" +
 	"\tChatError\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x1d\n" +
 	"\n" +
 	"error_code\x18\x02 \x01(\tR\terrorCode\x12#\n" +
-	"\rerror_message\x18\x03 \x01(\tR\ferrorMessage\"\xb4\x01\n" +
+	"\rerror_message\x18\x03 \x01(\tR\ferrorMessage\"\xe6\x01\n" +
 	"\fChatComplete\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x19\n" +
 	"\bquery_id\x18\x02 \x01(\tR\aqueryId\x127\n" +
 	"\atimings\x18\x03 \x01(\v2\x1d.repocontext.v1.SearchTimingsR\atimings\x121\n" +
-	"\x05stats\x18\x04 \x01(\v2\x1b.repocontext.v1.SearchStatsR\x05stats\"\xa1\x02\n" +
+	"\x05stats\x18\x04 \x01(\v2\x1b.repocontext.v1.SearchStatsR\x05stats\x120\n" +
+	"\x05usage\x18\x05 \x01(\v2\x1a.repocontext.v1.TokenUsageR\x05usage\"\x81\x01\n" +
+	"\n" +
+	"TokenUsage\x12#\n" +
+	"\rprompt_tokens\x18\x01 \x01(\x05R\fpromptTokens\x12+\n" +
+	"\x11completion_tokens\x18\x02 \x01(\x05R\x10completionTokens\x12!\n" +
+	"\ftotal_tokens\x18\x03 \x01(\x05R\vtotalTokens\"\x9c\x03\n" +
 	"\tCodeChunk\x12#\n" +
 	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x1b\n" +
 	"\tfile_path\x18\x02 \x01(\tR\bfilePath\x12\x1d\n" +
@@ -3207,12 +5380,44 @@ const file_repocontext_proto_rawDesc = "" +
 	"\x05score\x18\x06 \x01(\x02R\x05score\x124\n" +
 	"\x06source\x18\a \x01(\x0e2\x1c.repocontext.v1.SearchSourceR\x06source\x12\x1a\n" +
 	"\blanguage\x18\b \x01(\tR\blanguage\x12\x16\n" +
-	"\x06symbol\x18\t \x01(\tR\x06symbol\"b\n" +
+	"\x06symbol\x18\t \x01(\tR\x06symbol\x12>\n" +
+	"\n" +
+	"highlights\x18\n" +
+	" \x03(\v2\x1e.repocontext.v1.HighlightRangeR\n" +
+	"highlights\x129\n" +
+	"\n" +
+	"created_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"L\n" +
+	"\x0eHighlightRange\x12\x12\n" +
+	"\x04line\x18\x01 \x01(\x05R\x04line\x12\x14\n" +
+	"\x05start\x18\x02 \x01(\x05R\x05start\x12\x10\n" +
+	"\x03end\x18\x03 \x01(\x05R\x03end\"b\n" +
 	"\bCitation\x12\x1b\n" +
 	"\tfile_path\x18\x01 \x01(\tR\bfilePath\x12\x1f\n" +
 	"\vline_number\x18\x02 \x01(\x05R\n" +
 	"lineNumber\x12\x18\n" +
-	"\aexcerpt\x18\x03 \x01(\tR\aexcerpt\"\xae\x01\n" +
+	"\aexcerpt\x18\x03 \x01(\tR\aexcerpt\"\xc2\x03\n" +
+	"\rSearchRequest\x12\x1b\n" +
+	"\ttenant_id\x18\x01 \x01(\tR\btenantId\x12\x14\n" +
+	"\x05query\x18\x02 \x01(\tR\x05query\x12%\n" +
+	"\x0erepository_ids\x18\x03 \x03(\tR\rrepositoryIds\x12\x1f\n" +
+	"\vmax_results\x18\x04 \x01(\x05R\n" +
+	"maxResults\x12;\n" +
+	"\vsearch_mode\x18\x05 \x01(\x0e2\x1a.repocontext.v1.SearchModeR\n" +
+	"searchMode\x12G\n" +
+	"\x0flexical_options\x18\x06 \x01(\v2\x1e.repocontext.v1.LexicalOptionsR\x0elexicalOptions\x12#\n" +
+	"\rcontext_lines\x18\a \x01(\x05R\fcontextLines\x12(\n" +
+	"\rmin_certainty\x18\b \x01(\x02H\x00R\fminCertainty\x88\x01\x01\x12\x16\n" +
+	"\x06offset\x18\t \x01(\x05R\x06offset\x127\n" +
+	"\afilters\x18\n" +
+	" \x01(\v2\x1d.repocontext.v1.SearchFiltersR\afiltersB\x10\n" +
+	"\x0e_min_certainty\"\xc1\x02\n" +
+	"\x0eSearchResponse\x121\n" +
+	"\x06chunks\x18\x01 \x03(\v2\x19.repocontext.v1.CodeChunkR\x06chunks\x127\n" +
+	"\atimings\x18\x02 \x01(\v2\x1d.repocontext.v1.SearchTimingsR\atimings\x121\n" +
+	"\x05stats\x18\x03 \x01(\v2\x1b.repocontext.v1.SearchStatsR\x05stats\x12%\n" +
+	"\x0erepository_ids\x18\x04 \x03(\tR\rrepositoryIds\x122\n" +
+	"\x15failed_repository_ids\x18\x05 \x03(\tR\x13failedRepositoryIds\x125\n" +
+	"\x16repositories_truncated\x18\x06 \x01(\bR\x15repositoriesTruncated\"\xae\x01\n" +
 	"\rSearchTimings\x12\x1d\n" +
 	"\n" +
 	"lexical_ms\x18\x01 \x01(\x05R\tlexicalMs\x12\x1f\n" +
@@ -3230,20 +5435,137 @@ const file_repocontext_proto_rawDesc = "" +
 	"\ttenant_id\x18\x01 \x01(\tR\btenantId\x12\x1b\n" +
 	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
 	"\n" +
-	"page_token\x18\x03 \x01(\tR\tpageToken\"\x82\x01\n" +
+	"page_token\x18\x03 \x01(\tR\tpageToken\"\x9f\x01\n" +
 	"\x18ListRepositoriesResponse\x12>\n" +
 	"\frepositories\x18\x01 \x03(\v2\x1a.repocontext.v1.RepositoryR\frepositories\x12&\n" +
-	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"X\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\x12\x1b\n" +
+	"\tpage_size\x18\x03 \x01(\x05R\bpageSize\"X\n" +
 	"\x14GetRepositoryRequest\x12#\n" +
 	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x1b\n" +
 	"\ttenant_id\x18\x02 \x01(\tR\btenantId\"S\n" +
 	"\x15GetRepositoryResponse\x12:\n" +
 	"\n" +
 	"repository\x18\x01 \x01(\v2\x1a.repocontext.v1.RepositoryR\n" +
+	"repository\"\xb4\x01\n" +
+	"\x17UpdateRepositoryRequest\x12#\n" +
+	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\x12\x17\n" +
+	"\x04name\x18\x03 \x01(\tH\x00R\x04name\x88\x01\x01\x12%\n" +
+	"\vdescription\x18\x04 \x01(\tH\x01R\vdescription\x88\x01\x01B\a\n" +
+	"\x05_nameB\x0e\n" +
+	"\f_description\"V\n" +
+	"\x18UpdateRepositoryResponse\x12:\n" +
+	"\n" +
+	"repository\x18\x01 \x01(\v2\x1a.repocontext.v1.RepositoryR\n" +
 	"repository\"[\n" +
 	"\x17DeleteRepositoryRequest\x12#\n" +
 	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x1b\n" +
-	"\ttenant_id\x18\x02 \x01(\tR\btenantId\"\x9a\x03\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\"{\n" +
+	"\x18ReindexRepositoryRequest\x12#\n" +
+	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\x12\x15\n" +
+	"\x03ref\x18\x03 \x01(\tH\x00R\x03ref\x88\x01\x01B\x06\n" +
+	"\x04_ref\"\xa9\x01\n" +
+	"\x0eGetFileRequest\x12#\n" +
+	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\x12\x1b\n" +
+	"\tfile_path\x18\x03 \x01(\tR\bfilePath\x12\x1d\n" +
+	"\n" +
+	"start_line\x18\x04 \x01(\x05R\tstartLine\x12\x19\n" +
+	"\bend_line\x18\x05 \x01(\x05R\aendLine\"\xc3\x01\n" +
+	"\x0fGetFileResponse\x12#\n" +
+	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x1b\n" +
+	"\tfile_path\x18\x02 \x01(\tR\bfilePath\x12\x1a\n" +
+	"\blanguage\x18\x03 \x01(\tR\blanguage\x12\x18\n" +
+	"\acontent\x18\x04 \x01(\tR\acontent\x12\x1d\n" +
+	"\n" +
+	"start_line\x18\x05 \x01(\x05R\tstartLine\x12\x19\n" +
+	"\bend_line\x18\x06 \x01(\x05R\aendLine\"\xb1\x01\n" +
+	"\x10ListFilesRequest\x12#\n" +
+	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\x12\x1f\n" +
+	"\vpath_prefix\x18\x03 \x01(\tR\n" +
+	"pathPrefix\x12\x1b\n" +
+	"\tpage_size\x18\x04 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x05 \x01(\tR\tpageToken\"\xaa\x01\n" +
+	"\x11ListFilesResponse\x12/\n" +
+	"\x05files\x18\x01 \x03(\v2\x19.repocontext.v1.FileEntryR\x05files\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\x12\x1b\n" +
+	"\tpage_size\x18\x03 \x01(\x05R\bpageSize\x12\x1f\n" +
+	"\vtotal_files\x18\x04 \x01(\x05R\n" +
+	"totalFiles\"y\n" +
+	"\tFileEntry\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x1d\n" +
+	"\n" +
+	"size_bytes\x18\x02 \x01(\x03R\tsizeBytes\x12\x1a\n" +
+	"\blanguage\x18\x03 \x01(\tR\blanguage\x12\x1d\n" +
+	"\n" +
+	"line_count\x18\x04 \x01(\x05R\tlineCount\"n\n" +
+	"\x19GetRepositoryStatsRequest\x12#\n" +
+	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantIdJ\x04\b\x03\x10\x04R\trecompute\"c\n" +
+	"\x1fRecomputeRepositoryStatsRequest\x12#\n" +
+	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\"\xdc\x01\n" +
+	"\x1aGetRepositoryStatsResponse\x12#\n" +
+	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x125\n" +
+	"\x05stats\x18\x02 \x01(\v2\x1f.repocontext.v1.RepositoryStatsR\x05stats\x12B\n" +
+	"\fstored_stats\x18\x03 \x01(\v2\x1f.repocontext.v1.RepositoryStatsR\vstoredStats\x12\x1e\n" +
+	"\n" +
+	"recomputed\x18\x04 \x01(\bR\n" +
+	"recomputed\"V\n" +
+	"\x12ExportIndexRequest\x12#\n" +
+	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\"\x84\x01\n" +
+	"\vIndexRecord\x125\n" +
+	"\x06header\x18\x01 \x01(\v2\x1b.repocontext.v1.IndexHeaderH\x00R\x06header\x124\n" +
+	"\x05chunk\x18\x02 \x01(\v2\x1c.repocontext.v1.IndexedChunkH\x00R\x05chunkB\b\n" +
+	"\x06record\"\xb4\x03\n" +
+	"\vIndexHeader\x12%\n" +
+	"\x0eformat_version\x18\x01 \x01(\x05R\rformatVersion\x12:\n" +
+	"\n" +
+	"repository\x18\x02 \x01(\v2\x1a.repocontext.v1.RepositoryR\n" +
+	"repository\x12'\n" +
+	"\x0fembedding_model\x18\x03 \x01(\tR\x0eembeddingModel\x12\x1e\n" +
+	"\n" +
+	"dimensions\x18\x04 \x01(\x05R\n" +
+	"dimensions\x12;\n" +
+	"\vexported_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"exportedAt\x12L\n" +
+	"\vfile_hashes\x18\x06 \x03(\v2+.repocontext.v1.IndexHeader.FileHashesEntryR\n" +
+	"fileHashes\x12/\n" +
+	"\x05files\x18\a \x03(\v2\x19.repocontext.v1.FileEntryR\x05files\x1a=\n" +
+	"\x0fFileHashesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x8c\x02\n" +
+	"\fIndexedChunk\x12\x1b\n" +
+	"\tfile_path\x18\x01 \x01(\tR\bfilePath\x12\x1d\n" +
+	"\n" +
+	"start_line\x18\x02 \x01(\x05R\tstartLine\x12\x19\n" +
+	"\bend_line\x18\x03 \x01(\x05R\aendLine\x12\x18\n" +
+	"\acontent\x18\x04 \x01(\tR\acontent\x12\x1a\n" +
+	"\blanguage\x18\x05 \x01(\tR\blanguage\x12\x16\n" +
+	"\x06symbol\x18\x06 \x01(\tR\x06symbol\x12\x1c\n" +
+	"\tembedding\x18\a \x03(\x02R\tembedding\x129\n" +
+	"\n" +
+	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"\x8b\x01\n" +
+	"\x12ImportIndexRequest\x12#\n" +
+	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\x123\n" +
+	"\x06record\x18\x03 \x01(\v2\x1b.repocontext.v1.IndexRecordR\x06record\"\x9f\x01\n" +
+	"\x13ImportIndexResponse\x12#\n" +
+	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12'\n" +
+	"\x0fchunks_imported\x18\x02 \x01(\x05R\x0echunksImported\x12:\n" +
+	"\n" +
+	"repository\x18\x03 \x01(\v2\x1a.repocontext.v1.RepositoryR\n" +
+	"repository\"\xd3\x01\n" +
+	"\x19ReindexRepositoryResponse\x12\x1b\n" +
+	"\tupload_id\x18\x01 \x01(\tR\buploadId\x12#\n" +
+	"\rrepository_id\x18\x02 \x01(\tR\frepositoryId\x12;\n" +
+	"\vaccepted_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"acceptedAt\x127\n" +
+	"\x06status\x18\x04 \x01(\v2\x1f.repocontext.v1.IngestionStatusR\x06status\"\x9a\x03\n" +
 	"\n" +
 	"Repository\x12#\n" +
 	"\rrepository_id\x18\x01 \x01(\tR\frepositoryId\x12\x12\n" +
@@ -3277,7 +5599,20 @@ const file_repocontext_proto_rawDesc = "" +
 	"\n" +
 	"file_count\x18\x02 \x01(\x05R\tfileCount\x12\x1d\n" +
 	"\n" +
-	"line_count\x18\x03 \x01(\x05R\tlineCount\"\xb3\x02\n" +
+	"line_count\x18\x03 \x01(\x05R\tlineCount\"l\n" +
+	"\x13CreateAPIKeyRequest\x12\x1b\n" +
+	"\ttenant_id\x18\x01 \x01(\tR\btenantId\x12\x16\n" +
+	"\x06scopes\x18\x02 \x03(\tR\x06scopes\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\"\xb6\x01\n" +
+	"\x14CreateAPIKeyResponse\x12\x15\n" +
+	"\x06key_id\x18\x01 \x01(\tR\x05keyId\x12\x17\n" +
+	"\aapi_key\x18\x02 \x01(\tR\x06apiKey\x12\x1b\n" +
+	"\ttenant_id\x18\x03 \x01(\tR\btenantId\x12\x16\n" +
+	"\x06scopes\x18\x04 \x03(\tR\x06scopes\x129\n" +
+	"\n" +
+	"created_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\",\n" +
+	"\x13RevokeAPIKeyRequest\x12\x15\n" +
+	"\x06key_id\x18\x01 \x01(\tR\x05keyId\"\xb3\x02\n" +
 	"\x13HealthCheckResponse\x12I\n" +
 	"\x06status\x18\x01 \x01(\x0e21.repocontext.v1.HealthCheckResponse.ServingStatusR\x06status\x12?\n" +
 	"\n" +
@@ -3298,22 +5633,43 @@ const file_repocontext_proto_rawDesc = "" +
 	"\bHitPhase\x12\x19\n" +
 	"\x15HIT_PHASE_UNSPECIFIED\x10\x00\x12\x13\n" +
 	"\x0fHIT_PHASE_EARLY\x10\x01\x12\x13\n" +
-	"\x0fHIT_PHASE_FINAL\x10\x02*~\n" +
+	"\x0fHIT_PHASE_FINAL\x10\x02*\x98\x01\n" +
 	"\fSearchSource\x12\x1d\n" +
 	"\x19SEARCH_SOURCE_UNSPECIFIED\x10\x00\x12\x19\n" +
 	"\x15SEARCH_SOURCE_LEXICAL\x10\x01\x12\x1a\n" +
 	"\x16SEARCH_SOURCE_SEMANTIC\x10\x02\x12\x18\n" +
-	"\x14SEARCH_SOURCE_MERGED\x10\x032\x8f\x03\n" +
+	"\x14SEARCH_SOURCE_MERGED\x10\x03\x12\x18\n" +
+	"\x14SEARCH_SOURCE_HYBRID\x10\x04*r\n" +
+	"\n" +
+	"SearchMode\x12\x1b\n" +
+	"\x17SEARCH_MODE_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10SEARCH_MODE_BOTH\x10\x01\x12\x17\n" +
+	"\x13SEARCH_MODE_LEXICAL\x10\x02\x12\x18\n" +
+	"\x14SEARCH_MODE_SEMANTIC\x10\x032\x95\x04\n" +
 	"\rUploadService\x12i\n" +
 	"\x10UploadRepository\x12'.repocontext.v1.UploadRepositoryRequest\x1a(.repocontext.v1.UploadRepositoryResponse\"\x00(\x01\x12\x86\x01\n" +
 	"\x13UploadGitRepository\x12*.repocontext.v1.UploadGitRepositoryRequest\x1a(.repocontext.v1.UploadRepositoryResponse\"\x19\x82\xd3\xe4\x93\x02\x13:\x01*\"\x0e/v1/upload/git\x12\x89\x01\n" +
-	"\x0fGetUploadStatus\x12&.repocontext.v1.GetUploadStatusRequest\x1a'.repocontext.v1.GetUploadStatusResponse\"%\x82\xd3\xe4\x93\x02\x1f\x12\x1d/v1/upload/{upload_id}/status2d\n" +
+	"\x0fGetUploadStatus\x12&.repocontext.v1.GetUploadStatusRequest\x1a'.repocontext.v1.GetUploadStatusResponse\"%\x82\xd3\xe4\x93\x02\x1f\x12\x1d/v1/upload/{upload_id}/status\x12\x83\x01\n" +
+	"\fCancelUpload\x12#.repocontext.v1.CancelUploadRequest\x1a$.repocontext.v1.CancelUploadResponse\"(\x82\xd3\xe4\x93\x02\":\x01*\"\x1d/v1/upload/{upload_id}/cancel2\xc4\x01\n" +
 	"\vChatService\x12U\n" +
-	"\x12ChatWithRepository\x12\x1b.repocontext.v1.ChatRequest\x1a\x1c.repocontext.v1.ChatResponse\"\x00(\x010\x012\x9c\x03\n" +
+	"\x12ChatWithRepository\x12\x1b.repocontext.v1.ChatRequest\x1a\x1c.repocontext.v1.ChatResponse\"\x00(\x010\x01\x12^\n" +
+	"\x06Search\x12\x1d.repocontext.v1.SearchRequest\x1a\x1e.repocontext.v1.SearchResponse\"\x15\x82\xd3\xe4\x93\x02\x0f:\x01*\"\n" +
+	"/v1/search2\x8a\f\n" +
 	"\x11RepositoryService\x12\x7f\n" +
 	"\x10ListRepositories\x12'.repocontext.v1.ListRepositoriesRequest\x1a(.repocontext.v1.ListRepositoriesResponse\"\x18\x82\xd3\xe4\x93\x02\x12\x12\x10/v1/repositories\x12\x86\x01\n" +
-	"\rGetRepository\x12$.repocontext.v1.GetRepositoryRequest\x1a%.repocontext.v1.GetRepositoryResponse\"(\x82\xd3\xe4\x93\x02\"\x12 /v1/repositories/{repository_id}\x12}\n" +
-	"\x10DeleteRepository\x12'.repocontext.v1.DeleteRepositoryRequest\x1a\x16.google.protobuf.Empty\"(\x82\xd3\xe4\x93\x02\"* /v1/repositories/{repository_id}2\xb3\x01\n" +
+	"\rGetRepository\x12$.repocontext.v1.GetRepositoryRequest\x1a%.repocontext.v1.GetRepositoryResponse\"(\x82\xd3\xe4\x93\x02\"\x12 /v1/repositories/{repository_id}\x12\x92\x01\n" +
+	"\x10UpdateRepository\x12'.repocontext.v1.UpdateRepositoryRequest\x1a(.repocontext.v1.UpdateRepositoryResponse\"+\x82\xd3\xe4\x93\x02%:\x01*2 /v1/repositories/{repository_id}\x12}\n" +
+	"\x10DeleteRepository\x12'.repocontext.v1.DeleteRepositoryRequest\x1a\x16.google.protobuf.Empty\"(\x82\xd3\xe4\x93\x02\"* /v1/repositories/{repository_id}\x12\x9d\x01\n" +
+	"\x11ReindexRepository\x12(.repocontext.v1.ReindexRepositoryRequest\x1a).repocontext.v1.ReindexRepositoryResponse\"3\x82\xd3\xe4\x93\x02-:\x01*\"(/v1/repositories/{repository_id}:reindex\x12y\n" +
+	"\aGetFile\x12\x1e.repocontext.v1.GetFileRequest\x1a\x1f.repocontext.v1.GetFileResponse\"-\x82\xd3\xe4\x93\x02'\x12%/v1/repositories/{repository_id}/file\x12\x80\x01\n" +
+	"\tListFiles\x12 .repocontext.v1.ListFilesRequest\x1a!.repocontext.v1.ListFilesResponse\".\x82\xd3\xe4\x93\x02(\x12&/v1/repositories/{repository_id}/files\x12\x9b\x01\n" +
+	"\x12GetRepositoryStats\x12).repocontext.v1.GetRepositoryStatsRequest\x1a*.repocontext.v1.GetRepositoryStatsResponse\".\x82\xd3\xe4\x93\x02(\x12&/v1/repositories/{repository_id}/stats\x12\xb4\x01\n" +
+	"\x18RecomputeRepositoryStats\x12/.repocontext.v1.RecomputeRepositoryStatsRequest\x1a*.repocontext.v1.GetRepositoryStatsResponse\";\x82\xd3\xe4\x93\x025:\x01*\"0/v1/repositories/{repository_id}/stats:recompute\x12\x87\x01\n" +
+	"\vExportIndex\x12\".repocontext.v1.ExportIndexRequest\x1a\x1b.repocontext.v1.IndexRecord\"5\x82\xd3\xe4\x93\x02/\x12-/v1/repositories/{repository_id}/index:export0\x01\x12Z\n" +
+	"\vImportIndex\x12\".repocontext.v1.ImportIndexRequest\x1a#.repocontext.v1.ImportIndexResponse\"\x00(\x012\xfa\x01\n" +
+	"\fAdminService\x12x\n" +
+	"\fCreateAPIKey\x12#.repocontext.v1.CreateAPIKeyRequest\x1a$.repocontext.v1.CreateAPIKeyResponse\"\x1d\x82\xd3\xe4\x93\x02\x17:\x01*\"\x12/v1/admin/api-keys\x12p\n" +
+	"\fRevokeAPIKey\x12#.repocontext.v1.RevokeAPIKeyRequest\x1a\x16.google.protobuf.Empty\"#\x82\xd3\xe4\x93\x02\x1d*\x1b/v1/admin/api-keys/{key_id}2\xb3\x01\n" +
 	"\rHealthService\x12U\n" +
 	"\x05Check\x12\x16.google.protobuf.Empty\x1a#.repocontext.v1.HealthCheckResponse\"\x0f\x82\xd3\xe4\x93\x02\t\x12\a/health\x12K\n" +
 	"\x04Ping\x12\x16.google.protobuf.Empty\x1a\x1c.repocontext.v1.PingResponse\"\r\x82\xd3\xe4\x93\x02\a\x12\x05/pingBHZFgithub.com/repo-context-service/proto/gen/repocontext/v1;repocontextv1b\x06proto3"
@@ -3330,123 +5686,207 @@ func file_repocontext_proto_rawDescGZIP() []byte {
 	return file_repocontext_proto_rawDescData
 }
 
-var file_repocontext_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
-var file_repocontext_proto_msgTypes = make([]protoimpl.MessageInfo, 41)
+var file_repocontext_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
+var file_repocontext_proto_msgTypes = make([]protoimpl.MessageInfo, 70)
 var file_repocontext_proto_goTypes = []any{
-	(HitPhase)(0),                          // 0: repocontext.v1.HitPhase
-	(SearchSource)(0),                      // 1: repocontext.v1.SearchSource
-	(IngestionStatus_State)(0),             // 2: repocontext.v1.IngestionStatus.State
-	(HealthCheckResponse_ServingStatus)(0), // 3: repocontext.v1.HealthCheckResponse.ServingStatus
-	(*UploadRepositoryRequest)(nil),        // 4: repocontext.v1.UploadRepositoryRequest
-	(*UploadGitRepositoryRequest)(nil),     // 5: repocontext.v1.UploadGitRepositoryRequest
-	(*FileUpload)(nil),                     // 6: repocontext.v1.FileUpload
-	(*GitRepository)(nil),                  // 7: repocontext.v1.GitRepository
-	(*GitCredentials)(nil),                 // 8: repocontext.v1.GitCredentials
-	(*UploadOptions)(nil),                  // 9: repocontext.v1.UploadOptions
-	(*UploadRepositoryResponse)(nil),       // 10: repocontext.v1.UploadRepositoryResponse
-	(*GetUploadStatusRequest)(nil),         // 11: repocontext.v1.GetUploadStatusRequest
-	(*GetUploadStatusResponse)(nil),        // 12: repocontext.v1.GetUploadStatusResponse
-	(*IngestionStatus)(nil),                // 13: repocontext.v1.IngestionStatus
-	(*IngestionProgress)(nil),              // 14: repocontext.v1.IngestionProgress
-	(*ChatRequest)(nil),                    // 15: repocontext.v1.ChatRequest
-	(*ChatStart)(nil),                      // 16: repocontext.v1.ChatStart
-	(*ChatMessage)(nil),                    // 17: repocontext.v1.ChatMessage
-	(*ChatCancel)(nil),                     // 18: repocontext.v1.ChatCancel
-	(*ChatOptions)(nil),                    // 19: repocontext.v1.ChatOptions
-	(*SearchFilters)(nil),                  // 20: repocontext.v1.SearchFilters
-	(*ChatResponse)(nil),                   // 21: repocontext.v1.ChatResponse
-	(*SearchStarted)(nil),                  // 22: repocontext.v1.SearchStarted
-	(*SearchHit)(nil),                      // 23: repocontext.v1.SearchHit
-	(*CompositionStarted)(nil),             // 24: repocontext.v1.CompositionStarted
-	(*CompositionToken)(nil),               // 25: repocontext.v1.CompositionToken
-	(*CompositionComplete)(nil),            // 26: repocontext.v1.CompositionComplete
-	(*ChatError)(nil),                      // 27: repocontext.v1.ChatError
-	(*ChatComplete)(nil),                   // 28: repocontext.v1.ChatComplete
-	(*CodeChunk)(nil),                      // 29: repocontext.v1.CodeChunk
-	(*Citation)(nil),                       // 30: repocontext.v1.Citation
-	(*SearchTimings)(nil),                  // 31: repocontext.v1.SearchTimings
-	(*SearchStats)(nil),                    // 32: repocontext.v1.SearchStats
-	(*ListRepositoriesRequest)(nil),        // 33: repocontext.v1.ListRepositoriesRequest
-	(*ListRepositoriesResponse)(nil),       // 34: repocontext.v1.ListRepositoriesResponse
-	(*GetRepositoryRequest)(nil),           // 35: repocontext.v1.GetRepositoryRequest
-	(*GetRepositoryResponse)(nil),          // 36: repocontext.v1.GetRepositoryResponse
-	(*DeleteRepositoryRequest)(nil),        // 37: repocontext.v1.DeleteRepositoryRequest
-	(*Repository)(nil),                     // 38: repocontext.v1.Repository
-	(*RepositorySource)(nil),               // 39: repocontext.v1.RepositorySource
-	(*RepositoryStats)(nil),                // 40: repocontext.v1.RepositoryStats
-	(*LanguageStats)(nil),                  // 41: repocontext.v1.LanguageStats
-	(*HealthCheckResponse)(nil),            // 42: repocontext.v1.HealthCheckResponse
-	(*ComponentHealth)(nil),                // 43: repocontext.v1.ComponentHealth
-	(*PingResponse)(nil),                   // 44: repocontext.v1.PingResponse
-	(*timestamppb.Timestamp)(nil),          // 45: google.protobuf.Timestamp
-	(*emptypb.Empty)(nil),                  // 46: google.protobuf.Empty
+	(HitPhase)(0),                           // 0: repocontext.v1.HitPhase
+	(SearchSource)(0),                       // 1: repocontext.v1.SearchSource
+	(SearchMode)(0),                         // 2: repocontext.v1.SearchMode
+	(IngestionStatus_State)(0),              // 3: repocontext.v1.IngestionStatus.State
+	(HealthCheckResponse_ServingStatus)(0),  // 4: repocontext.v1.HealthCheckResponse.ServingStatus
+	(*UploadRepositoryRequest)(nil),         // 5: repocontext.v1.UploadRepositoryRequest
+	(*UploadGitRepositoryRequest)(nil),      // 6: repocontext.v1.UploadGitRepositoryRequest
+	(*FileUpload)(nil),                      // 7: repocontext.v1.FileUpload
+	(*GitRepository)(nil),                   // 8: repocontext.v1.GitRepository
+	(*GitCredentials)(nil),                  // 9: repocontext.v1.GitCredentials
+	(*UploadOptions)(nil),                   // 10: repocontext.v1.UploadOptions
+	(*UploadRepositoryResponse)(nil),        // 11: repocontext.v1.UploadRepositoryResponse
+	(*GetUploadStatusRequest)(nil),          // 12: repocontext.v1.GetUploadStatusRequest
+	(*GetUploadStatusResponse)(nil),         // 13: repocontext.v1.GetUploadStatusResponse
+	(*CancelUploadRequest)(nil),             // 14: repocontext.v1.CancelUploadRequest
+	(*CancelUploadResponse)(nil),            // 15: repocontext.v1.CancelUploadResponse
+	(*IngestionStatus)(nil),                 // 16: repocontext.v1.IngestionStatus
+	(*IngestionProgress)(nil),               // 17: repocontext.v1.IngestionProgress
+	(*ChatRequest)(nil),                     // 18: repocontext.v1.ChatRequest
+	(*ChatStart)(nil),                       // 19: repocontext.v1.ChatStart
+	(*ChatMessage)(nil),                     // 20: repocontext.v1.ChatMessage
+	(*ChatCancel)(nil),                      // 21: repocontext.v1.ChatCancel
+	(*ChatOptions)(nil),                     // 22: repocontext.v1.ChatOptions
+	(*LexicalOptions)(nil),                  // 23: repocontext.v1.LexicalOptions
+	(*SearchFilters)(nil),                   // 24: repocontext.v1.SearchFilters
+	(*ChatResponse)(nil),                    // 25: repocontext.v1.ChatResponse
+	(*SearchStarted)(nil),                   // 26: repocontext.v1.SearchStarted
+	(*SearchHit)(nil),                       // 27: repocontext.v1.SearchHit
+	(*CompositionStarted)(nil),              // 28: repocontext.v1.CompositionStarted
+	(*CompositionToken)(nil),                // 29: repocontext.v1.CompositionToken
+	(*CompositionComplete)(nil),             // 30: repocontext.v1.CompositionComplete
+	(*ChatError)(nil),                       // 31: repocontext.v1.ChatError
+	(*ChatComplete)(nil),                    // 32: repocontext.v1.ChatComplete
+	(*TokenUsage)(nil),                      // 33: repocontext.v1.TokenUsage
+	(*CodeChunk)(nil),                       // 34: repocontext.v1.CodeChunk
+	(*HighlightRange)(nil),                  // 35: repocontext.v1.HighlightRange
+	(*Citation)(nil),                        // 36: repocontext.v1.Citation
+	(*SearchRequest)(nil),                   // 37: repocontext.v1.SearchRequest
+	(*SearchResponse)(nil),                  // 38: repocontext.v1.SearchResponse
+	(*SearchTimings)(nil),                   // 39: repocontext.v1.SearchTimings
+	(*SearchStats)(nil),                     // 40: repocontext.v1.SearchStats
+	(*ListRepositoriesRequest)(nil),         // 41: repocontext.v1.ListRepositoriesRequest
+	(*ListRepositoriesResponse)(nil),        // 42: repocontext.v1.ListRepositoriesResponse
+	(*GetRepositoryRequest)(nil),            // 43: repocontext.v1.GetRepositoryRequest
+	(*GetRepositoryResponse)(nil),           // 44: repocontext.v1.GetRepositoryResponse
+	(*UpdateRepositoryRequest)(nil),         // 45: repocontext.v1.UpdateRepositoryRequest
+	(*UpdateRepositoryResponse)(nil),        // 46: repocontext.v1.UpdateRepositoryResponse
+	(*DeleteRepositoryRequest)(nil),         // 47: repocontext.v1.DeleteRepositoryRequest
+	(*ReindexRepositoryRequest)(nil),        // 48: repocontext.v1.ReindexRepositoryRequest
+	(*GetFileRequest)(nil),                  // 49: repocontext.v1.GetFileRequest
+	(*GetFileResponse)(nil),                 // 50: repocontext.v1.GetFileResponse
+	(*ListFilesRequest)(nil),                // 51: repocontext.v1.ListFilesRequest
+	(*ListFilesResponse)(nil),               // 52: repocontext.v1.ListFilesResponse
+	(*FileEntry)(nil),                       // 53: repocontext.v1.FileEntry
+	(*GetRepositoryStatsRequest)(nil),       // 54: repocontext.v1.GetRepositoryStatsRequest
+	(*RecomputeRepositoryStatsRequest)(nil), // 55: repocontext.v1.RecomputeRepositoryStatsRequest
+	(*GetRepositoryStatsResponse)(nil),      // 56: repocontext.v1.GetRepositoryStatsResponse
+	(*ExportIndexRequest)(nil),              // 57: repocontext.v1.ExportIndexRequest
+	(*IndexRecord)(nil),                     // 58: repocontext.v1.IndexRecord
+	(*IndexHeader)(nil),                     // 59: repocontext.v1.IndexHeader
+	(*IndexedChunk)(nil),                    // 60: repocontext.v1.IndexedChunk
+	(*ImportIndexRequest)(nil),              // 61: repocontext.v1.ImportIndexRequest
+	(*ImportIndexResponse)(nil),             // 62: repocontext.v1.ImportIndexResponse
+	(*ReindexRepositoryResponse)(nil),       // 63: repocontext.v1.ReindexRepositoryResponse
+	(*Repository)(nil),                      // 64: repocontext.v1.Repository
+	(*RepositorySource)(nil),                // 65: repocontext.v1.RepositorySource
+	(*RepositoryStats)(nil),                 // 66: repocontext.v1.RepositoryStats
+	(*LanguageStats)(nil),                   // 67: repocontext.v1.LanguageStats
+	(*CreateAPIKeyRequest)(nil),             // 68: repocontext.v1.CreateAPIKeyRequest
+	(*CreateAPIKeyResponse)(nil),            // 69: repocontext.v1.CreateAPIKeyResponse
+	(*RevokeAPIKeyRequest)(nil),             // 70: repocontext.v1.RevokeAPIKeyRequest
+	(*HealthCheckResponse)(nil),             // 71: repocontext.v1.HealthCheckResponse
+	(*ComponentHealth)(nil),                 // 72: repocontext.v1.ComponentHealth
+	(*PingResponse)(nil),                    // 73: repocontext.v1.PingResponse
+	nil,                                     // 74: repocontext.v1.IndexHeader.FileHashesEntry
+	(*timestamppb.Timestamp)(nil),           // 75: google.protobuf.Timestamp
+	(*emptypb.Empty)(nil),                   // 76: google.protobuf.Empty
 }
 var file_repocontext_proto_depIdxs = []int32{
-	6,  // 0: repocontext.v1.UploadRepositoryRequest.file_upload:type_name -> repocontext.v1.FileUpload
-	7,  // 1: repocontext.v1.UploadRepositoryRequest.git_repository:type_name -> repocontext.v1.GitRepository
-	9,  // 2: repocontext.v1.UploadRepositoryRequest.options:type_name -> repocontext.v1.UploadOptions
-	7,  // 3: repocontext.v1.UploadGitRepositoryRequest.git_repository:type_name -> repocontext.v1.GitRepository
-	9,  // 4: repocontext.v1.UploadGitRepositoryRequest.options:type_name -> repocontext.v1.UploadOptions
-	8,  // 5: repocontext.v1.GitRepository.credentials:type_name -> repocontext.v1.GitCredentials
-	45, // 6: repocontext.v1.UploadRepositoryResponse.accepted_at:type_name -> google.protobuf.Timestamp
-	13, // 7: repocontext.v1.UploadRepositoryResponse.status:type_name -> repocontext.v1.IngestionStatus
-	13, // 8: repocontext.v1.GetUploadStatusResponse.status:type_name -> repocontext.v1.IngestionStatus
-	14, // 9: repocontext.v1.GetUploadStatusResponse.progress:type_name -> repocontext.v1.IngestionProgress
-	2,  // 10: repocontext.v1.IngestionStatus.state:type_name -> repocontext.v1.IngestionStatus.State
-	45, // 11: repocontext.v1.IngestionStatus.updated_at:type_name -> google.protobuf.Timestamp
-	16, // 12: repocontext.v1.ChatRequest.start:type_name -> repocontext.v1.ChatStart
-	17, // 13: repocontext.v1.ChatRequest.chat_message:type_name -> repocontext.v1.ChatMessage
-	18, // 14: repocontext.v1.ChatRequest.cancel:type_name -> repocontext.v1.ChatCancel
-	19, // 15: repocontext.v1.ChatStart.options:type_name -> repocontext.v1.ChatOptions
-	20, // 16: repocontext.v1.ChatMessage.filters:type_name -> repocontext.v1.SearchFilters
-	22, // 17: repocontext.v1.ChatResponse.search_started:type_name -> repocontext.v1.SearchStarted
-	23, // 18: repocontext.v1.ChatResponse.search_hit:type_name -> repocontext.v1.SearchHit
-	24, // 19: repocontext.v1.ChatResponse.composition_started:type_name -> repocontext.v1.CompositionStarted
-	25, // 20: repocontext.v1.ChatResponse.composition_token:type_name -> repocontext.v1.CompositionToken
-	26, // 21: repocontext.v1.ChatResponse.composition_complete:type_name -> repocontext.v1.CompositionComplete
-	27, // 22: repocontext.v1.ChatResponse.error:type_name -> repocontext.v1.ChatError
-	28, // 23: repocontext.v1.ChatResponse.complete:type_name -> repocontext.v1.ChatComplete
-	0,  // 24: repocontext.v1.SearchHit.phase:type_name -> repocontext.v1.HitPhase
-	29, // 25: repocontext.v1.SearchHit.chunk:type_name -> repocontext.v1.CodeChunk
-	30, // 26: repocontext.v1.CompositionComplete.citations:type_name -> repocontext.v1.Citation
-	31, // 27: repocontext.v1.ChatComplete.timings:type_name -> repocontext.v1.SearchTimings
-	32, // 28: repocontext.v1.ChatComplete.stats:type_name -> repocontext.v1.SearchStats
-	1,  // 29: repocontext.v1.CodeChunk.source:type_name -> repocontext.v1.SearchSource
-	38, // 30: repocontext.v1.ListRepositoriesResponse.repositories:type_name -> repocontext.v1.Repository
-	38, // 31: repocontext.v1.GetRepositoryResponse.repository:type_name -> repocontext.v1.Repository
-	39, // 32: repocontext.v1.Repository.source:type_name -> repocontext.v1.RepositorySource
-	13, // 33: repocontext.v1.Repository.ingestion_status:type_name -> repocontext.v1.IngestionStatus
-	40, // 34: repocontext.v1.Repository.stats:type_name -> repocontext.v1.RepositoryStats
-	45, // 35: repocontext.v1.Repository.created_at:type_name -> google.protobuf.Timestamp
-	45, // 36: repocontext.v1.Repository.updated_at:type_name -> google.protobuf.Timestamp
-	41, // 37: repocontext.v1.RepositoryStats.languages:type_name -> repocontext.v1.LanguageStats
-	3,  // 38: repocontext.v1.HealthCheckResponse.status:type_name -> repocontext.v1.HealthCheckResponse.ServingStatus
-	43, // 39: repocontext.v1.HealthCheckResponse.components:type_name -> repocontext.v1.ComponentHealth
-	3,  // 40: repocontext.v1.ComponentHealth.status:type_name -> repocontext.v1.HealthCheckResponse.ServingStatus
-	45, // 41: repocontext.v1.PingResponse.timestamp:type_name -> google.protobuf.Timestamp
-	4,  // 42: repocontext.v1.UploadService.UploadRepository:input_type -> repocontext.v1.UploadRepositoryRequest
-	5,  // 43: repocontext.v1.UploadService.UploadGitRepository:input_type -> repocontext.v1.UploadGitRepositoryRequest
-	11, // 44: repocontext.v1.UploadService.GetUploadStatus:input_type -> repocontext.v1.GetUploadStatusRequest
-	15, // 45: repocontext.v1.ChatService.ChatWithRepository:input_type -> repocontext.v1.ChatRequest
-	33, // 46: repocontext.v1.RepositoryService.ListRepositories:input_type -> repocontext.v1.ListRepositoriesRequest
-	35, // 47: repocontext.v1.RepositoryService.GetRepository:input_type -> repocontext.v1.GetRepositoryRequest
-	37, // 48: repocontext.v1.RepositoryService.DeleteRepository:input_type -> repocontext.v1.DeleteRepositoryRequest
-	46, // 49: repocontext.v1.HealthService.Check:input_type -> google.protobuf.Empty
-	46, // 50: repocontext.v1.HealthService.Ping:input_type -> google.protobuf.Empty
-	10, // 51: repocontext.v1.UploadService.UploadRepository:output_type -> repocontext.v1.UploadRepositoryResponse
-	10, // 52: repocontext.v1.UploadService.UploadGitRepository:output_type -> repocontext.v1.UploadRepositoryResponse
-	12, // 53: repocontext.v1.UploadService.GetUploadStatus:output_type -> repocontext.v1.GetUploadStatusResponse
-	21, // 54: repocontext.v1.ChatService.ChatWithRepository:output_type -> repocontext.v1.ChatResponse
-	34, // 55: repocontext.v1.RepositoryService.ListRepositories:output_type -> repocontext.v1.ListRepositoriesResponse
-	36, // 56: repocontext.v1.RepositoryService.GetRepository:output_type -> repocontext.v1.GetRepositoryResponse
-	46, // 57: repocontext.v1.RepositoryService.DeleteRepository:output_type -> google.protobuf.Empty
-	42, // 58: repocontext.v1.HealthService.Check:output_type -> repocontext.v1.HealthCheckResponse
-	44, // 59: repocontext.v1.HealthService.Ping:output_type -> repocontext.v1.PingResponse
-	51, // [51:60] is the sub-list for method output_type
-	42, // [42:51] is the sub-list for method input_type
-	42, // [42:42] is the sub-list for extension type_name
-	42, // [42:42] is the sub-list for extension extendee
-	0,  // [0:42] is the sub-list for field type_name
+	7,  // 0: repocontext.v1.UploadRepositoryRequest.file_upload:type_name -> repocontext.v1.FileUpload
+	8,  // 1: repocontext.v1.UploadRepositoryRequest.git_repository:type_name -> repocontext.v1.GitRepository
+	10, // 2: repocontext.v1.UploadRepositoryRequest.options:type_name -> repocontext.v1.UploadOptions
+	8,  // 3: repocontext.v1.UploadGitRepositoryRequest.git_repository:type_name -> repocontext.v1.GitRepository
+	10, // 4: repocontext.v1.UploadGitRepositoryRequest.options:type_name -> repocontext.v1.UploadOptions
+	9,  // 5: repocontext.v1.GitRepository.credentials:type_name -> repocontext.v1.GitCredentials
+	75, // 6: repocontext.v1.UploadRepositoryResponse.accepted_at:type_name -> google.protobuf.Timestamp
+	16, // 7: repocontext.v1.UploadRepositoryResponse.status:type_name -> repocontext.v1.IngestionStatus
+	16, // 8: repocontext.v1.GetUploadStatusResponse.status:type_name -> repocontext.v1.IngestionStatus
+	17, // 9: repocontext.v1.GetUploadStatusResponse.progress:type_name -> repocontext.v1.IngestionProgress
+	16, // 10: repocontext.v1.CancelUploadResponse.status:type_name -> repocontext.v1.IngestionStatus
+	3,  // 11: repocontext.v1.IngestionStatus.state:type_name -> repocontext.v1.IngestionStatus.State
+	75, // 12: repocontext.v1.IngestionStatus.updated_at:type_name -> google.protobuf.Timestamp
+	19, // 13: repocontext.v1.ChatRequest.start:type_name -> repocontext.v1.ChatStart
+	20, // 14: repocontext.v1.ChatRequest.chat_message:type_name -> repocontext.v1.ChatMessage
+	21, // 15: repocontext.v1.ChatRequest.cancel:type_name -> repocontext.v1.ChatCancel
+	22, // 16: repocontext.v1.ChatStart.options:type_name -> repocontext.v1.ChatOptions
+	24, // 17: repocontext.v1.ChatMessage.filters:type_name -> repocontext.v1.SearchFilters
+	23, // 18: repocontext.v1.ChatMessage.lexical_options:type_name -> repocontext.v1.LexicalOptions
+	2,  // 19: repocontext.v1.ChatOptions.search_mode:type_name -> repocontext.v1.SearchMode
+	24, // 20: repocontext.v1.ChatOptions.filters:type_name -> repocontext.v1.SearchFilters
+	26, // 21: repocontext.v1.ChatResponse.search_started:type_name -> repocontext.v1.SearchStarted
+	27, // 22: repocontext.v1.ChatResponse.search_hit:type_name -> repocontext.v1.SearchHit
+	28, // 23: repocontext.v1.ChatResponse.composition_started:type_name -> repocontext.v1.CompositionStarted
+	29, // 24: repocontext.v1.ChatResponse.composition_token:type_name -> repocontext.v1.CompositionToken
+	30, // 25: repocontext.v1.ChatResponse.composition_complete:type_name -> repocontext.v1.CompositionComplete
+	31, // 26: repocontext.v1.ChatResponse.error:type_name -> repocontext.v1.ChatError
+	32, // 27: repocontext.v1.ChatResponse.complete:type_name -> repocontext.v1.ChatComplete
+	0,  // 28: repocontext.v1.SearchHit.phase:type_name -> repocontext.v1.HitPhase
+	34, // 29: repocontext.v1.SearchHit.chunk:type_name -> repocontext.v1.CodeChunk
+	36, // 30: repocontext.v1.CompositionComplete.citations:type_name -> repocontext.v1.Citation
+	33, // 31: repocontext.v1.CompositionComplete.usage:type_name -> repocontext.v1.TokenUsage
+	39, // 32: repocontext.v1.ChatComplete.timings:type_name -> repocontext.v1.SearchTimings
+	40, // 33: repocontext.v1.ChatComplete.stats:type_name -> repocontext.v1.SearchStats
+	33, // 34: repocontext.v1.ChatComplete.usage:type_name -> repocontext.v1.TokenUsage
+	1,  // 35: repocontext.v1.CodeChunk.source:type_name -> repocontext.v1.SearchSource
+	35, // 36: repocontext.v1.CodeChunk.highlights:type_name -> repocontext.v1.HighlightRange
+	75, // 37: repocontext.v1.CodeChunk.created_at:type_name -> google.protobuf.Timestamp
+	2,  // 38: repocontext.v1.SearchRequest.search_mode:type_name -> repocontext.v1.SearchMode
+	23, // 39: repocontext.v1.SearchRequest.lexical_options:type_name -> repocontext.v1.LexicalOptions
+	24, // 40: repocontext.v1.SearchRequest.filters:type_name -> repocontext.v1.SearchFilters
+	34, // 41: repocontext.v1.SearchResponse.chunks:type_name -> repocontext.v1.CodeChunk
+	39, // 42: repocontext.v1.SearchResponse.timings:type_name -> repocontext.v1.SearchTimings
+	40, // 43: repocontext.v1.SearchResponse.stats:type_name -> repocontext.v1.SearchStats
+	64, // 44: repocontext.v1.ListRepositoriesResponse.repositories:type_name -> repocontext.v1.Repository
+	64, // 45: repocontext.v1.GetRepositoryResponse.repository:type_name -> repocontext.v1.Repository
+	64, // 46: repocontext.v1.UpdateRepositoryResponse.repository:type_name -> repocontext.v1.Repository
+	53, // 47: repocontext.v1.ListFilesResponse.files:type_name -> repocontext.v1.FileEntry
+	66, // 48: repocontext.v1.GetRepositoryStatsResponse.stats:type_name -> repocontext.v1.RepositoryStats
+	66, // 49: repocontext.v1.GetRepositoryStatsResponse.stored_stats:type_name -> repocontext.v1.RepositoryStats
+	59, // 50: repocontext.v1.IndexRecord.header:type_name -> repocontext.v1.IndexHeader
+	60, // 51: repocontext.v1.IndexRecord.chunk:type_name -> repocontext.v1.IndexedChunk
+	64, // 52: repocontext.v1.IndexHeader.repository:type_name -> repocontext.v1.Repository
+	75, // 53: repocontext.v1.IndexHeader.exported_at:type_name -> google.protobuf.Timestamp
+	74, // 54: repocontext.v1.IndexHeader.file_hashes:type_name -> repocontext.v1.IndexHeader.FileHashesEntry
+	53, // 55: repocontext.v1.IndexHeader.files:type_name -> repocontext.v1.FileEntry
+	75, // 56: repocontext.v1.IndexedChunk.created_at:type_name -> google.protobuf.Timestamp
+	58, // 57: repocontext.v1.ImportIndexRequest.record:type_name -> repocontext.v1.IndexRecord
+	64, // 58: repocontext.v1.ImportIndexResponse.repository:type_name -> repocontext.v1.Repository
+	75, // 59: repocontext.v1.ReindexRepositoryResponse.accepted_at:type_name -> google.protobuf.Timestamp
+	16, // 60: repocontext.v1.ReindexRepositoryResponse.status:type_name -> repocontext.v1.IngestionStatus
+	65, // 61: repocontext.v1.Repository.source:type_name -> repocontext.v1.RepositorySource
+	16, // 62: repocontext.v1.Repository.ingestion_status:type_name -> repocontext.v1.IngestionStatus
+	66, // 63: repocontext.v1.Repository.stats:type_name -> repocontext.v1.RepositoryStats
+	75, // 64: repocontext.v1.Repository.created_at:type_name -> google.protobuf.Timestamp
+	75, // 65: repocontext.v1.Repository.updated_at:type_name -> google.protobuf.Timestamp
+	67, // 66: repocontext.v1.RepositoryStats.languages:type_name -> repocontext.v1.LanguageStats
+	75, // 67: repocontext.v1.CreateAPIKeyResponse.created_at:type_name -> google.protobuf.Timestamp
+	4,  // 68: repocontext.v1.HealthCheckResponse.status:type_name -> repocontext.v1.HealthCheckResponse.ServingStatus
+	72, // 69: repocontext.v1.HealthCheckResponse.components:type_name -> repocontext.v1.ComponentHealth
+	4,  // 70: repocontext.v1.ComponentHealth.status:type_name -> repocontext.v1.HealthCheckResponse.ServingStatus
+	75, // 71: repocontext.v1.PingResponse.timestamp:type_name -> google.protobuf.Timestamp
+	5,  // 72: repocontext.v1.UploadService.UploadRepository:input_type -> repocontext.v1.UploadRepositoryRequest
+	6,  // 73: repocontext.v1.UploadService.UploadGitRepository:input_type -> repocontext.v1.UploadGitRepositoryRequest
+	12, // 74: repocontext.v1.UploadService.GetUploadStatus:input_type -> repocontext.v1.GetUploadStatusRequest
+	14, // 75: repocontext.v1.UploadService.CancelUpload:input_type -> repocontext.v1.CancelUploadRequest
+	18, // 76: repocontext.v1.ChatService.ChatWithRepository:input_type -> repocontext.v1.ChatRequest
+	37, // 77: repocontext.v1.ChatService.Search:input_type -> repocontext.v1.SearchRequest
+	41, // 78: repocontext.v1.RepositoryService.ListRepositories:input_type -> repocontext.v1.ListRepositoriesRequest
+	43, // 79: repocontext.v1.RepositoryService.GetRepository:input_type -> repocontext.v1.GetRepositoryRequest
+	45, // 80: repocontext.v1.RepositoryService.UpdateRepository:input_type -> repocontext.v1.UpdateRepositoryRequest
+	47, // 81: repocontext.v1.RepositoryService.DeleteRepository:input_type -> repocontext.v1.DeleteRepositoryRequest
+	48, // 82: repocontext.v1.RepositoryService.ReindexRepository:input_type -> repocontext.v1.ReindexRepositoryRequest
+	49, // 83: repocontext.v1.RepositoryService.GetFile:input_type -> repocontext.v1.GetFileRequest
+	51, // 84: repocontext.v1.RepositoryService.ListFiles:input_type -> repocontext.v1.ListFilesRequest
+	54, // 85: repocontext.v1.RepositoryService.GetRepositoryStats:input_type -> repocontext.v1.GetRepositoryStatsRequest
+	55, // 86: repocontext.v1.RepositoryService.RecomputeRepositoryStats:input_type -> repocontext.v1.RecomputeRepositoryStatsRequest
+	57, // 87: repocontext.v1.RepositoryService.ExportIndex:input_type -> repocontext.v1.ExportIndexRequest
+	61, // 88: repocontext.v1.RepositoryService.ImportIndex:input_type -> repocontext.v1.ImportIndexRequest
+	68, // 89: repocontext.v1.AdminService.CreateAPIKey:input_type -> repocontext.v1.CreateAPIKeyRequest
+	70, // 90: repocontext.v1.AdminService.RevokeAPIKey:input_type -> repocontext.v1.RevokeAPIKeyRequest
+	76, // 91: repocontext.v1.HealthService.Check:input_type -> google.protobuf.Empty
+	76, // 92: repocontext.v1.HealthService.Ping:input_type -> google.protobuf.Empty
+	11, // 93: repocontext.v1.UploadService.UploadRepository:output_type -> repocontext.v1.UploadRepositoryResponse
+	11, // 94: repocontext.v1.UploadService.UploadGitRepository:output_type -> repocontext.v1.UploadRepositoryResponse
+	13, // 95: repocontext.v1.UploadService.GetUploadStatus:output_type -> repocontext.v1.GetUploadStatusResponse
+	15, // 96: repocontext.v1.UploadService.CancelUpload:output_type -> repocontext.v1.CancelUploadResponse
+	25, // 97: repocontext.v1.ChatService.ChatWithRepository:output_type -> repocontext.v1.ChatResponse
+	38, // 98: repocontext.v1.ChatService.Search:output_type -> repocontext.v1.SearchResponse
+	42, // 99: repocontext.v1.RepositoryService.ListRepositories:output_type -> repocontext.v1.ListRepositoriesResponse
+	44, // 100: repocontext.v1.RepositoryService.GetRepository:output_type -> repocontext.v1.GetRepositoryResponse
+	46, // 101: repocontext.v1.RepositoryService.UpdateRepository:output_type -> repocontext.v1.UpdateRepositoryResponse
+	76, // 102: repocontext.v1.RepositoryService.DeleteRepository:output_type -> google.protobuf.Empty
+	63, // 103: repocontext.v1.RepositoryService.ReindexRepository:output_type -> repocontext.v1.ReindexRepositoryResponse
+	50, // 104: repocontext.v1.RepositoryService.GetFile:output_type -> repocontext.v1.GetFileResponse
+	52, // 105: repocontext.v1.RepositoryService.ListFiles:output_type -> repocontext.v1.ListFilesResponse
+	56, // 106: repocontext.v1.RepositoryService.GetRepositoryStats:output_type -> repocontext.v1.GetRepositoryStatsResponse
+	56, // 107: repocontext.v1.RepositoryService.RecomputeRepositoryStats:output_type -> repocontext.v1.GetRepositoryStatsResponse
+	58, // 108: repocontext.v1.RepositoryService.ExportIndex:output_type -> repocontext.v1.IndexRecord
+	62, // 109: repocontext.v1.RepositoryService.ImportIndex:output_type -> repocontext.v1.ImportIndexResponse
+	69, // 110: repocontext.v1.AdminService.CreateAPIKey:output_type -> repocontext.v1.CreateAPIKeyResponse
+	76, // 111: repocontext.v1.AdminService.RevokeAPIKey:output_type -> google.protobuf.Empty
+	71, // 112: repocontext.v1.HealthService.Check:output_type -> repocontext.v1.HealthCheckResponse
+	73, // 113: repocontext.v1.HealthService.Ping:output_type -> repocontext.v1.PingResponse
+	93, // [93:114] is the sub-list for method output_type
+	72, // [72:93] is the sub-list for method input_type
+	72, // [72:72] is the sub-list for extension type_name
+	72, // [72:72] is the sub-list for extension extendee
+	0,  // [0:72] is the sub-list for field type_name
 }
 
 func init() { file_repocontext_proto_init() }
@@ -3458,12 +5898,13 @@ func file_repocontext_proto_init() {
 		(*UploadRepositoryRequest_FileUpload)(nil),
 		(*UploadRepositoryRequest_GitRepository)(nil),
 	}
-	file_repocontext_proto_msgTypes[11].OneofWrappers = []any{
+	file_repocontext_proto_msgTypes[13].OneofWrappers = []any{
 		(*ChatRequest_Start)(nil),
 		(*ChatRequest_ChatMessage)(nil),
 		(*ChatRequest_Cancel)(nil),
 	}
-	file_repocontext_proto_msgTypes[17].OneofWrappers = []any{
+	file_repocontext_proto_msgTypes[18].OneofWrappers = []any{}
+	file_repocontext_proto_msgTypes[20].OneofWrappers = []any{
 		(*ChatResponse_SearchStarted)(nil),
 		(*ChatResponse_SearchHit)(nil),
 		(*ChatResponse_CompositionStarted)(nil),
@@ -3472,7 +5913,14 @@ func file_repocontext_proto_init() {
 		(*ChatResponse_Error)(nil),
 		(*ChatResponse_Complete)(nil),
 	}
-	file_repocontext_proto_msgTypes[35].OneofWrappers = []any{
+	file_repocontext_proto_msgTypes[32].OneofWrappers = []any{}
+	file_repocontext_proto_msgTypes[40].OneofWrappers = []any{}
+	file_repocontext_proto_msgTypes[43].OneofWrappers = []any{}
+	file_repocontext_proto_msgTypes[53].OneofWrappers = []any{
+		(*IndexRecord_Header)(nil),
+		(*IndexRecord_Chunk)(nil),
+	}
+	file_repocontext_proto_msgTypes[60].OneofWrappers = []any{
 		(*RepositorySource_GitUrl)(nil),
 		(*RepositorySource_UploadedFilename)(nil),
 	}
@@ -3481,10 +5929,10 @@ func file_repocontext_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_repocontext_proto_rawDesc), len(file_repocontext_proto_rawDesc)),
-			NumEnums:      4,
-			NumMessages:   41,
+			NumEnums:      5,
+			NumMessages:   70,
 			NumExtensions: 0,
-			NumServices:   4,
+			NumServices:   5,
 		},
 		GoTypes:           file_repocontext_proto_goTypes,
 		DependencyIndexes: file_repocontext_proto_depIdxs,
@@ -23,6 +23,7 @@ const (
 	UploadService_UploadRepository_FullMethodName    = "/repocontext.v1.UploadService/UploadRepository"
 	UploadService_UploadGitRepository_FullMethodName = "/repocontext.v1.UploadService/UploadGitRepository"
 	UploadService_GetUploadStatus_FullMethodName     = "/repocontext.v1.UploadService/GetUploadStatus"
+	UploadService_CancelUpload_FullMethodName        = "/repocontext.v1.UploadService/CancelUpload"
 )
 
 // UploadServiceClient is the client API for UploadService service.
@@ -37,6 +38,8 @@ type UploadServiceClient interface {
 	UploadGitRepository(ctx context.Context, in *UploadGitRepositoryRequest, opts ...grpc.CallOption) (*UploadRepositoryResponse, error)
 	// Get upload and ingestion status
 	GetUploadStatus(ctx context.Context, in *GetUploadStatusRequest, opts ...grpc.CallOption) (*GetUploadStatusResponse, error)
+	// Stop an upload's ingestion; it finishes as STATE_FAILED
+	CancelUpload(ctx context.Context, in *CancelUploadRequest, opts ...grpc.CallOption) (*CancelUploadResponse, error)
 }
 
 type uploadServiceClient struct {
@@ -80,6 +83,16 @@ func (c *uploadServiceClient) GetUploadStatus(ctx context.Context, in *GetUpload
 	return out, nil
 }
 
+func (c *uploadServiceClient) CancelUpload(ctx context.Context, in *CancelUploadRequest, opts ...grpc.CallOption) (*CancelUploadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelUploadResponse)
+	err := c.cc.Invoke(ctx, UploadService_CancelUpload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UploadServiceServer is the server API for UploadService service.
 // All implementations must embed UnimplementedUploadServiceServer
 // for forward compatibility.
@@ -92,6 +105,8 @@ type UploadServiceServer interface {
 	UploadGitRepository(context.Context, *UploadGitRepositoryRequest) (*UploadRepositoryResponse, error)
 	// Get upload and ingestion status
 	GetUploadStatus(context.Context, *GetUploadStatusRequest) (*GetUploadStatusResponse, error)
+	// Stop an upload's ingestion; it finishes as STATE_FAILED
+	CancelUpload(context.Context, *CancelUploadRequest) (*CancelUploadResponse, error)
 	mustEmbedUnimplementedUploadServiceServer()
 }
 
@@ -111,6 +126,9 @@ func (UnimplementedUploadServiceServer) UploadGitRepository(context.Context, *Up
 func (UnimplementedUploadServiceServer) GetUploadStatus(context.Context, *GetUploadStatusRequest) (*GetUploadStatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUploadStatus not implemented")
 }
+func (UnimplementedUploadServiceServer) CancelUpload(context.Context, *CancelUploadRequest) (*CancelUploadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelUpload not implemented")
+}
 func (UnimplementedUploadServiceServer) mustEmbedUnimplementedUploadServiceServer() {}
 func (UnimplementedUploadServiceServer) testEmbeddedByValue()                       {}
 
@@ -175,6 +193,24 @@ func _UploadService_GetUploadStatus_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UploadService_CancelUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UploadServiceServer).CancelUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UploadService_CancelUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UploadServiceServer).CancelUpload(ctx, req.(*CancelUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // UploadService_ServiceDesc is the grpc.ServiceDesc for UploadService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -190,6 +226,10 @@ var UploadService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUploadStatus",
 			Handler:    _UploadService_GetUploadStatus_Handler,
 		},
+		{
+			MethodName: "CancelUpload",
+			Handler:    _UploadService_CancelUpload_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -203,6 +243,7 @@ var UploadService_ServiceDesc = grpc.ServiceDesc{
 
 const (
 	ChatService_ChatWithRepository_FullMethodName = "/repocontext.v1.ChatService/ChatWithRepository"
+	ChatService_Search_FullMethodName             = "/repocontext.v1.ChatService/Search"
 )
 
 // ChatServiceClient is the client API for ChatService service.
@@ -213,6 +254,8 @@ const (
 type ChatServiceClient interface {
 	// Chat with a repository using streaming responses
 	ChatWithRepository(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ChatRequest, ChatResponse], error)
+	// Search several repositories at once, returning one ranked list of chunks
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
 }
 
 type chatServiceClient struct {
@@ -236,6 +279,16 @@ func (c *chatServiceClient) ChatWithRepository(ctx context.Context, opts ...grpc
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type ChatService_ChatWithRepositoryClient = grpc.BidiStreamingClient[ChatRequest, ChatResponse]
 
+func (c *chatServiceClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, ChatService_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ChatServiceServer is the server API for ChatService service.
 // All implementations must embed UnimplementedChatServiceServer
 // for forward compatibility.
@@ -244,6 +297,8 @@ type ChatService_ChatWithRepositoryClient = grpc.BidiStreamingClient[ChatRequest
 type ChatServiceServer interface {
 	// Chat with a repository using streaming responses
 	ChatWithRepository(grpc.BidiStreamingServer[ChatRequest, ChatResponse]) error
+	// Search several repositories at once, returning one ranked list of chunks
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
 	mustEmbedUnimplementedChatServiceServer()
 }
 
@@ -257,6 +312,9 @@ type UnimplementedChatServiceServer struct{}
 func (UnimplementedChatServiceServer) ChatWithRepository(grpc.BidiStreamingServer[ChatRequest, ChatResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method ChatWithRepository not implemented")
 }
+func (UnimplementedChatServiceServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
 func (UnimplementedChatServiceServer) mustEmbedUnimplementedChatServiceServer() {}
 func (UnimplementedChatServiceServer) testEmbeddedByValue()                     {}
 
@@ -285,13 +343,36 @@ func _ChatService_ChatWithRepository_Handler(srv interface{}, stream grpc.Server
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type ChatService_ChatWithRepositoryServer = grpc.BidiStreamingServer[ChatRequest, ChatResponse]
 
+func _ChatService_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ChatService_ServiceDesc is the grpc.ServiceDesc for ChatService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var ChatService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "repocontext.v1.ChatService",
 	HandlerType: (*ChatServiceServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Search",
+			Handler:    _ChatService_Search_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "ChatWithRepository",
@@ -304,9 +385,17 @@ var ChatService_ServiceDesc = grpc.ServiceDesc{
 }
 
 const (
-	RepositoryService_ListRepositories_FullMethodName = "/repocontext.v1.RepositoryService/ListRepositories"
-	RepositoryService_GetRepository_FullMethodName    = "/repocontext.v1.RepositoryService/GetRepository"
-	RepositoryService_DeleteRepository_FullMethodName = "/repocontext.v1.RepositoryService/DeleteRepository"
+	RepositoryService_ListRepositories_FullMethodName         = "/repocontext.v1.RepositoryService/ListRepositories"
+	RepositoryService_GetRepository_FullMethodName            = "/repocontext.v1.RepositoryService/GetRepository"
+	RepositoryService_UpdateRepository_FullMethodName         = "/repocontext.v1.RepositoryService/UpdateRepository"
+	RepositoryService_DeleteRepository_FullMethodName         = "/repocontext.v1.RepositoryService/DeleteRepository"
+	RepositoryService_ReindexRepository_FullMethodName        = "/repocontext.v1.RepositoryService/ReindexRepository"
+	RepositoryService_GetFile_FullMethodName                  = "/repocontext.v1.RepositoryService/GetFile"
+	RepositoryService_ListFiles_FullMethodName                = "/repocontext.v1.RepositoryService/ListFiles"
+	RepositoryService_GetRepositoryStats_FullMethodName       = "/repocontext.v1.RepositoryService/GetRepositoryStats"
+	RepositoryService_RecomputeRepositoryStats_FullMethodName = "/repocontext.v1.RepositoryService/RecomputeRepositoryStats"
+	RepositoryService_ExportIndex_FullMethodName              = "/repocontext.v1.RepositoryService/ExportIndex"
+	RepositoryService_ImportIndex_FullMethodName              = "/repocontext.v1.RepositoryService/ImportIndex"
 )
 
 // RepositoryServiceClient is the client API for RepositoryService service.
@@ -319,8 +408,25 @@ type RepositoryServiceClient interface {
 	ListRepositories(ctx context.Context, in *ListRepositoriesRequest, opts ...grpc.CallOption) (*ListRepositoriesResponse, error)
 	// Get repository details
 	GetRepository(ctx context.Context, in *GetRepositoryRequest, opts ...grpc.CallOption) (*GetRepositoryResponse, error)
+	// Update a repository's name or description
+	UpdateRepository(ctx context.Context, in *UpdateRepositoryRequest, opts ...grpc.CallOption) (*UpdateRepositoryResponse, error)
 	// Delete a repository
 	DeleteRepository(ctx context.Context, in *DeleteRepositoryRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Re-index a repository from its source, only re-embedding files that changed
+	ReindexRepository(ctx context.Context, in *ReindexRepositoryRequest, opts ...grpc.CallOption) (*ReindexRepositoryResponse, error)
+	// Get the contents of a file, or a range of its lines, from a repository
+	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (*GetFileResponse, error)
+	// List the files indexed in a repository, sorted by path
+	ListFiles(ctx context.Context, in *ListFilesRequest, opts ...grpc.CallOption) (*ListFilesResponse, error)
+	// Get a repository's current file, line, language and chunk counts
+	GetRepositoryStats(ctx context.Context, in *GetRepositoryStatsRequest, opts ...grpc.CallOption) (*GetRepositoryStatsResponse, error)
+	// Recount a ready repository's files, lines and languages from its working tree
+	// and store the result as its stats
+	RecomputeRepositoryStats(ctx context.Context, in *RecomputeRepositoryStatsRequest, opts ...grpc.CallOption) (*GetRepositoryStatsResponse, error)
+	// Export a ready repository's index: a header, then every chunk with its embedding
+	ExportIndex(ctx context.Context, in *ExportIndexRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[IndexRecord], error)
+	// Import an exported index, replacing the repository's index without re-embedding
+	ImportIndex(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportIndexRequest, ImportIndexResponse], error)
 }
 
 type repositoryServiceClient struct {
@@ -351,6 +457,16 @@ func (c *repositoryServiceClient) GetRepository(ctx context.Context, in *GetRepo
 	return out, nil
 }
 
+func (c *repositoryServiceClient) UpdateRepository(ctx context.Context, in *UpdateRepositoryRequest, opts ...grpc.CallOption) (*UpdateRepositoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateRepositoryResponse)
+	err := c.cc.Invoke(ctx, RepositoryService_UpdateRepository_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *repositoryServiceClient) DeleteRepository(ctx context.Context, in *DeleteRepositoryRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
@@ -361,6 +477,88 @@ func (c *repositoryServiceClient) DeleteRepository(ctx context.Context, in *Dele
 	return out, nil
 }
 
+func (c *repositoryServiceClient) ReindexRepository(ctx context.Context, in *ReindexRepositoryRequest, opts ...grpc.CallOption) (*ReindexRepositoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReindexRepositoryResponse)
+	err := c.cc.Invoke(ctx, RepositoryService_ReindexRepository_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repositoryServiceClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (*GetFileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetFileResponse)
+	err := c.cc.Invoke(ctx, RepositoryService_GetFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repositoryServiceClient) ListFiles(ctx context.Context, in *ListFilesRequest, opts ...grpc.CallOption) (*ListFilesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListFilesResponse)
+	err := c.cc.Invoke(ctx, RepositoryService_ListFiles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repositoryServiceClient) GetRepositoryStats(ctx context.Context, in *GetRepositoryStatsRequest, opts ...grpc.CallOption) (*GetRepositoryStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetRepositoryStatsResponse)
+	err := c.cc.Invoke(ctx, RepositoryService_GetRepositoryStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repositoryServiceClient) RecomputeRepositoryStats(ctx context.Context, in *RecomputeRepositoryStatsRequest, opts ...grpc.CallOption) (*GetRepositoryStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetRepositoryStatsResponse)
+	err := c.cc.Invoke(ctx, RepositoryService_RecomputeRepositoryStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repositoryServiceClient) ExportIndex(ctx context.Context, in *ExportIndexRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[IndexRecord], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RepositoryService_ServiceDesc.Streams[0], RepositoryService_ExportIndex_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportIndexRequest, IndexRecord]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RepositoryService_ExportIndexClient = grpc.ServerStreamingClient[IndexRecord]
+
+func (c *repositoryServiceClient) ImportIndex(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportIndexRequest, ImportIndexResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RepositoryService_ServiceDesc.Streams[1], RepositoryService_ImportIndex_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImportIndexRequest, ImportIndexResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RepositoryService_ImportIndexClient = grpc.ClientStreamingClient[ImportIndexRequest, ImportIndexResponse]
+
 // RepositoryServiceServer is the server API for RepositoryService service.
 // All implementations must embed UnimplementedRepositoryServiceServer
 // for forward compatibility.
@@ -371,8 +569,25 @@ type RepositoryServiceServer interface {
 	ListRepositories(context.Context, *ListRepositoriesRequest) (*ListRepositoriesResponse, error)
 	// Get repository details
 	GetRepository(context.Context, *GetRepositoryRequest) (*GetRepositoryResponse, error)
+	// Update a repository's name or description
+	UpdateRepository(context.Context, *UpdateRepositoryRequest) (*UpdateRepositoryResponse, error)
 	// Delete a repository
 	DeleteRepository(context.Context, *DeleteRepositoryRequest) (*emptypb.Empty, error)
+	// Re-index a repository from its source, only re-embedding files that changed
+	ReindexRepository(context.Context, *ReindexRepositoryRequest) (*ReindexRepositoryResponse, error)
+	// Get the contents of a file, or a range of its lines, from a repository
+	GetFile(context.Context, *GetFileRequest) (*GetFileResponse, error)
+	// List the files indexed in a repository, sorted by path
+	ListFiles(context.Context, *ListFilesRequest) (*ListFilesResponse, error)
+	// Get a repository's current file, line, language and chunk counts
+	GetRepositoryStats(context.Context, *GetRepositoryStatsRequest) (*GetRepositoryStatsResponse, error)
+	// Recount a ready repository's files, lines and languages from its working tree
+	// and store the result as its stats
+	RecomputeRepositoryStats(context.Context, *RecomputeRepositoryStatsRequest) (*GetRepositoryStatsResponse, error)
+	// Export a ready repository's index: a header, then every chunk with its embedding
+	ExportIndex(*ExportIndexRequest, grpc.ServerStreamingServer[IndexRecord]) error
+	// Import an exported index, replacing the repository's index without re-embedding
+	ImportIndex(grpc.ClientStreamingServer[ImportIndexRequest, ImportIndexResponse]) error
 	mustEmbedUnimplementedRepositoryServiceServer()
 }
 
@@ -389,9 +604,33 @@ func (UnimplementedRepositoryServiceServer) ListRepositories(context.Context, *L
 func (UnimplementedRepositoryServiceServer) GetRepository(context.Context, *GetRepositoryRequest) (*GetRepositoryResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetRepository not implemented")
 }
+func (UnimplementedRepositoryServiceServer) UpdateRepository(context.Context, *UpdateRepositoryRequest) (*UpdateRepositoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRepository not implemented")
+}
 func (UnimplementedRepositoryServiceServer) DeleteRepository(context.Context, *DeleteRepositoryRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteRepository not implemented")
 }
+func (UnimplementedRepositoryServiceServer) ReindexRepository(context.Context, *ReindexRepositoryRequest) (*ReindexRepositoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReindexRepository not implemented")
+}
+func (UnimplementedRepositoryServiceServer) GetFile(context.Context, *GetFileRequest) (*GetFileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFile not implemented")
+}
+func (UnimplementedRepositoryServiceServer) ListFiles(context.Context, *ListFilesRequest) (*ListFilesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFiles not implemented")
+}
+func (UnimplementedRepositoryServiceServer) GetRepositoryStats(context.Context, *GetRepositoryStatsRequest) (*GetRepositoryStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRepositoryStats not implemented")
+}
+func (UnimplementedRepositoryServiceServer) RecomputeRepositoryStats(context.Context, *RecomputeRepositoryStatsRequest) (*GetRepositoryStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecomputeRepositoryStats not implemented")
+}
+func (UnimplementedRepositoryServiceServer) ExportIndex(*ExportIndexRequest, grpc.ServerStreamingServer[IndexRecord]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportIndex not implemented")
+}
+func (UnimplementedRepositoryServiceServer) ImportIndex(grpc.ClientStreamingServer[ImportIndexRequest, ImportIndexResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ImportIndex not implemented")
+}
 func (UnimplementedRepositoryServiceServer) mustEmbedUnimplementedRepositoryServiceServer() {}
 func (UnimplementedRepositoryServiceServer) testEmbeddedByValue()                           {}
 
@@ -449,6 +688,24 @@ func _RepositoryService_GetRepository_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RepositoryService_UpdateRepository_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRepositoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepositoryServiceServer).UpdateRepository(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RepositoryService_UpdateRepository_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepositoryServiceServer).UpdateRepository(ctx, req.(*UpdateRepositoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _RepositoryService_DeleteRepository_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteRepositoryRequest)
 	if err := dec(in); err != nil {
@@ -467,6 +724,114 @@ func _RepositoryService_DeleteRepository_Handler(srv interface{}, ctx context.Co
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RepositoryService_ReindexRepository_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReindexRepositoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepositoryServiceServer).ReindexRepository(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RepositoryService_ReindexRepository_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepositoryServiceServer).ReindexRepository(ctx, req.(*ReindexRepositoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RepositoryService_GetFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepositoryServiceServer).GetFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RepositoryService_GetFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepositoryServiceServer).GetFile(ctx, req.(*GetFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RepositoryService_ListFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepositoryServiceServer).ListFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RepositoryService_ListFiles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepositoryServiceServer).ListFiles(ctx, req.(*ListFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RepositoryService_GetRepositoryStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRepositoryStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepositoryServiceServer).GetRepositoryStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RepositoryService_GetRepositoryStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepositoryServiceServer).GetRepositoryStats(ctx, req.(*GetRepositoryStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RepositoryService_RecomputeRepositoryStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecomputeRepositoryStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepositoryServiceServer).RecomputeRepositoryStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RepositoryService_RecomputeRepositoryStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepositoryServiceServer).RecomputeRepositoryStats(ctx, req.(*RecomputeRepositoryStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RepositoryService_ExportIndex_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportIndexRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RepositoryServiceServer).ExportIndex(m, &grpc.GenericServerStream[ExportIndexRequest, IndexRecord]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RepositoryService_ExportIndexServer = grpc.ServerStreamingServer[IndexRecord]
+
+func _RepositoryService_ImportIndex_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RepositoryServiceServer).ImportIndex(&grpc.GenericServerStream[ImportIndexRequest, ImportIndexResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RepositoryService_ImportIndexServer = grpc.ClientStreamingServer[ImportIndexRequest, ImportIndexResponse]
+
 // RepositoryService_ServiceDesc is the grpc.ServiceDesc for RepositoryService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -482,10 +847,193 @@ var RepositoryService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetRepository",
 			Handler:    _RepositoryService_GetRepository_Handler,
 		},
+		{
+			MethodName: "UpdateRepository",
+			Handler:    _RepositoryService_UpdateRepository_Handler,
+		},
 		{
 			MethodName: "DeleteRepository",
 			Handler:    _RepositoryService_DeleteRepository_Handler,
 		},
+		{
+			MethodName: "ReindexRepository",
+			Handler:    _RepositoryService_ReindexRepository_Handler,
+		},
+		{
+			MethodName: "GetFile",
+			Handler:    _RepositoryService_GetFile_Handler,
+		},
+		{
+			MethodName: "ListFiles",
+			Handler:    _RepositoryService_ListFiles_Handler,
+		},
+		{
+			MethodName: "GetRepositoryStats",
+			Handler:    _RepositoryService_GetRepositoryStats_Handler,
+		},
+		{
+			MethodName: "RecomputeRepositoryStats",
+			Handler:    _RepositoryService_RecomputeRepositoryStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportIndex",
+			Handler:       _RepositoryService_ExportIndex_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportIndex",
+			Handler:       _RepositoryService_ImportIndex_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "repocontext.proto",
+}
+
+const (
+	AdminService_CreateAPIKey_FullMethodName = "/repocontext.v1.AdminService/CreateAPIKey"
+	AdminService_RevokeAPIKey_FullMethodName = "/repocontext.v1.AdminService/RevokeAPIKey"
+)
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// AdminService manages API keys
+type AdminServiceClient interface {
+	// Create an API key for a tenant. The key is only returned once.
+	CreateAPIKey(ctx context.Context, in *CreateAPIKeyRequest, opts ...grpc.CallOption) (*CreateAPIKeyResponse, error)
+	// Revoke an API key by ID
+	RevokeAPIKey(ctx context.Context, in *RevokeAPIKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) CreateAPIKey(ctx context.Context, in *CreateAPIKeyRequest, opts ...grpc.CallOption) (*CreateAPIKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateAPIKeyResponse)
+	err := c.cc.Invoke(ctx, AdminService_CreateAPIKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) RevokeAPIKey(ctx context.Context, in *RevokeAPIKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, AdminService_RevokeAPIKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility.
+//
+// AdminService manages API keys
+type AdminServiceServer interface {
+	// Create an API key for a tenant. The key is only returned once.
+	CreateAPIKey(context.Context, *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error)
+	// Revoke an API key by ID
+	RevokeAPIKey(context.Context, *RevokeAPIKeyRequest) (*emptypb.Empty, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAdminServiceServer struct{}
+
+func (UnimplementedAdminServiceServer) CreateAPIKey(context.Context, *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAPIKey not implemented")
+}
+func (UnimplementedAdminServiceServer) RevokeAPIKey(context.Context, *RevokeAPIKeyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeAPIKey not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+func (UnimplementedAdminServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	// If the following call pancis, it indicates UnimplementedAdminServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_CreateAPIKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAPIKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).CreateAPIKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_CreateAPIKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).CreateAPIKey(ctx, req.(*CreateAPIKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_RevokeAPIKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeAPIKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).RevokeAPIKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_RevokeAPIKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).RevokeAPIKey(ctx, req.(*RevokeAPIKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "repocontext.v1.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateAPIKey",
+			Handler:    _AdminService_CreateAPIKey_Handler,
+		},
+		{
+			MethodName: "RevokeAPIKey",
+			Handler:    _AdminService_RevokeAPIKey_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "repocontext.proto",